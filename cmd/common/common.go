@@ -50,6 +50,7 @@ func Init(cmd *cobra.Command, dbName string) {
 	internal.AddConfigFlags(cmd, hiddenConfigFlagAnnotation)
 
 	cmd.PersistentFlags().StringVar(&internal.CfgFile, "config", "", "config file (default is $HOME/.walg.json)")
+	cmd.PersistentFlags().StringVar(&internal.CfgProfile, "profile", "", "config file profile to use on top of the top-level settings")
 
 	initHelp(cmd)
 
@@ -67,6 +68,7 @@ func Init(cmd *cobra.Command, dbName string) {
 	persistentPostRun := cmd.PersistentPostRun
 
 	var p internal.ProfileStopper
+	var stopTracing func()
 	cmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
 		if persistentPreRun != nil {
 			persistentPreRun(cmd, args)
@@ -75,6 +77,7 @@ func Init(cmd *cobra.Command, dbName string) {
 		var err error
 		p, err = internal.Profile()
 		tracelog.ErrorLogger.FatalOnError(err)
+		stopTracing = internal.StartTracing(cmd.CommandPath())
 	}
 	cmd.PersistentPostRun = func(cmd *cobra.Command, args []string) {
 		if persistentPostRun != nil {
@@ -83,6 +86,9 @@ func Init(cmd *cobra.Command, dbName string) {
 
 		// metrics hook
 		internal.PushMetrics()
+		if stopTracing != nil {
+			stopTracing()
+		}
 
 		if p != nil {
 			p.Stop()
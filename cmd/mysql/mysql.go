@@ -36,7 +36,9 @@ var cmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main().
 func Execute() {
-	if err := cmd.Execute(); err != nil {
+	err := cmd.Execute()
+	internal.RemoveScratchDir()
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,57 @@
+package pg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	backupCheckShortDescription = "Checks the latest backup for monitoring, exits with 0, 1, 2 or 3 for OK, WARNING, CRITICAL or UNKNOWN"
+	backupCheckLongDescription  = `Checks that the latest backup is not older than the thresholds, that its sentinel
+is parseable and that the WAL segments from its start to its finish are archived.
+Prints a one-line summary and exits with 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN) like a Nagios plugin.`
+
+	warnAgeFlag        = "warn-age"
+	warnAgeDescription = "Warn if the latest backup is older than this, e.g. 26h. 0 disables the check"
+	critAgeFlag        = "crit-age"
+	critAgeDescription = "Fail if the latest backup is older than this, e.g. 50h. 0 disables the check"
+)
+
+var (
+	backupCheckCmd = &cobra.Command{
+		Use:   "backup-check",
+		Short: backupCheckShortDescription,
+		Long:  backupCheckLongDescription,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if warnAge < 0 || critAge < 0 {
+				postgres.ReportBackupCheck(postgres.BackupCheckResult{Status: postgres.BackupCheckUnknown,
+					Summary: fmt.Sprintf("--%s and --%s can't be negative", warnAgeFlag, critAgeFlag)})
+			}
+			if warnAge > 0 && critAge > 0 && critAge < warnAge {
+				postgres.ReportBackupCheck(postgres.BackupCheckResult{Status: postgres.BackupCheckUnknown,
+					Summary: fmt.Sprintf("--%s can't be less than --%s", critAgeFlag, warnAgeFlag)})
+			}
+
+			folder, err := internal.ConfigureFolder()
+			if err != nil {
+				postgres.ReportBackupCheck(postgres.BackupCheckResult{Status: postgres.BackupCheckUnknown,
+					Summary: "failed to configure storage: " + err.Error()})
+			}
+
+			postgres.HandleBackupCheck(folder, postgres.BackupCheckThresholds{WarnAge: warnAge, CritAge: critAge})
+		},
+	}
+	warnAge time.Duration
+	critAge time.Duration
+)
+
+func init() {
+	backupCheckCmd.Flags().DurationVar(&warnAge, warnAgeFlag, 26*time.Hour, warnAgeDescription)
+	backupCheckCmd.Flags().DurationVar(&critAge, critAgeFlag, 50*time.Hour, critAgeDescription)
+	Cmd.AddCommand(backupCheckCmd)
+}
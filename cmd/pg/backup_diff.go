@@ -0,0 +1,54 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	backupDiffShortDescription = "Compares the backup with another backup or with the data directory of the running cluster"
+	backupDiffLongDescription  = `If the second argument is a directory, compares the checksums recorded in the files metadata
+of the backup with the checksums of the files in the data directory. The files written after the backup start
+(by the modification time or the page LSNs) are reported as changed, the rest of the different files are reported
+as failures and the command exits with code 65.
+Otherwise the second argument is the newer backup, and the files added, removed and changed between the two backups
+are reported with their size deltas and the per-tablespace summaries.`
+)
+
+var backupDiffCmd = &cobra.Command{
+	Use:   "backup-diff backup_name | LATEST (PGDATA | other_backup_name | LATEST)",
+	Short: backupDiffShortDescription,
+	Long:  backupDiffLongDescription,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		backupSelector, err := internal.NewTargetBackupSelector("", args[0], postgres.NewGenericMetaFetcher())
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		folder, err := internal.ConfigureFolder()
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		if fileInfo, err := os.Stat(args[1]); err == nil && fileInfo.IsDir() {
+			postgres.HandleBackupDiff(folder, backupSelector, args[1], backupDiffJSON, backupDiffPretty)
+			return
+		}
+		newBackupSelector, err := internal.NewTargetBackupSelector("", args[1], postgres.NewGenericMetaFetcher())
+		tracelog.ErrorLogger.FatalOnError(err)
+		postgres.HandleBackupsDiff(folder, backupSelector, newBackupSelector, backupDiffJSON, backupDiffPretty)
+	},
+}
+
+var (
+	backupDiffJSON   bool
+	backupDiffPretty bool
+)
+
+func init() {
+	Cmd.AddCommand(backupDiffCmd)
+
+	backupDiffCmd.Flags().BoolVar(&backupDiffJSON, JSONFlag, false, "Prints output in json format")
+	backupDiffCmd.Flags().BoolVar(&backupDiffPretty, PrettyFlag, false, "Prints more readable json")
+}
@@ -2,6 +2,10 @@ package pg
 
 import (
 	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/wal-g/wal-g/internal/databases/postgres"
 
@@ -9,6 +13,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/azure"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
 
@@ -17,10 +22,79 @@ const (
 	maskFlagDescription         = `Fetches only files which path relative to destination_directory
 matches given shell file pattern.
 For information about pattern syntax view: https://golang.org/pkg/path/filepath/#Match`
-	restoreSpecDescription        = "Path to file containing tablespace restore specification"
-	reverseDeltaUnpackDescription = "Unpack delta backups in reverse order (beta feature)"
-	skipRedundantTarsDescription  = "Skip tars with no useful data (requires reverse delta unpack)"
-	targetUserDataDescription     = "Fetch storage backup which has the specified user data"
+	restoreSpecDescription         = "Path to file containing tablespace restore specification"
+	reverseDeltaUnpackDescription  = "Unpack delta backups in reverse order (beta feature)"
+	skipRedundantTarsDescription   = "Skip tars with no useful data (requires reverse delta unpack)"
+	targetUserDataDescription      = "Fetch storage backup which has the specified user data"
+	downloadConcurrencyFlag        = "download-concurrency"
+	downloadConcurrencyDescription = "Number of tar members to download and extract in parallel (overrides " +
+		internal.DownloadConcurrencySetting + ")"
+	restoreOnlyFlag        = "restore-only"
+	restoreOnlyDescription = "Restore only the data of the specified databases (comma-separated names), " +
+		"the relation files of other databases are created empty"
+	skipRelFileNodeFlag        = "skip-relfilenode"
+	skipRelFileNodeDescription = "Do not restore the data of the relations with the specified relfilenodes " +
+		"(comma-separated), all their segments are created empty"
+	catalogsOnlyFlag        = "catalogs-only"
+	catalogsOnlyDescription = "Restore only the system catalogs for the schema inspection, the relation files " +
+		"of the user objects are created empty and the restored cluster is configured as read-only"
+	verifyOnFetchFlag        = "verify-on-fetch"
+	verifyOnFetchDescription = "Verify the restored files against the checksums recorded in the backup, " +
+		"fail the restore on mismatch"
+	tablespaceMappingFlag        = "tablespace-mapping"
+	tablespaceMappingDescription = "Restore the tablespace located at /old/path to /new/path (repeatable, " +
+		"in the /old/path=/new/path form), every tablespace of the backup must be mapped, " +
+		"map it to the same path to keep the original location"
+	flattenTablespacesFlag        = "flatten-tablespaces"
+	flattenTablespacesDescription = "Restore the tablespaces as directories under pg_tblspc/<oid> " +
+		"instead of the symlinks to their original paths"
+	targetTimeFlag        = "target-time"
+	targetTimeDescription = "Fetch the newest backup finished before the specified time (RFC 3339, " +
+		"e.g. 2024-01-02T03:04:05Z) to start the point-in-time recovery from"
+	targetLsnFlag        = "target-lsn"
+	targetLsnDescription = "Fetch the newest backup finished before the specified LSN (e.g. 1/AB000000), " +
+		"check that the WAL up to it is archived and configure the recovery to stop at it"
+	waitRehydrateFlag        = "wait-rehydrate"
+	waitRehydrateDescription = "Wait for the rehydration of the backup objects in the Azure Archive tier " +
+		"instead of failing after it is started"
+	noProgressFlag        = "no-progress"
+	noProgressDescription = "Do not print the extraction progress to stderr (overrides " +
+		internal.FetchProgressSetting + ")"
+	validateOnlyFlag        = "validate-only"
+	validateOnlyDescription = "Only check that the restored files fit into the free disk space " +
+		"of the destination directory and the tablespace locations, do not extract anything"
+	restorePointFlag        = "restore-point"
+	restorePointDescription = "Look up the named restore point created by pg_create_restore_point " +
+		"in the WAL archived after the backup and configure the recovery to stop at it"
+	streamFlag        = "stream"
+	streamDescription = "Write the full backup to stdout as a single tar stream instead of the destination directory, " +
+		"the backup name is the only argument"
+	inplaceFlag        = "inplace"
+	inplaceDescription = "Restore the full backup into the existing data directory of the same stopped cluster, " +
+		"rewrite only the files which differ from the backup and remove the files which are not in it"
+	fsyncFlag        = "fsync"
+	fsyncDescription = "Fsync the extracted files and their directories before reporting the success " +
+		"(overrides " + internal.TarDisableFsyncSetting + ")"
+	noFsyncFlag              = "no-fsync"
+	noFsyncDescription       = "Do not fsync the extracted files, for the throwaway restores where the speed matters"
+	maxDeltaStepsFlag        = "max-delta-steps"
+	maxDeltaStepsDescription = "Check that the restore of the backup traverses at most the specified number " +
+		"of delta backups before fetching anything (overrides " + internal.FetchMaxDeltaSteps + ")"
+	fileFlag        = "file"
+	fileDescription = "Restore only the specified file (e.g. base/16384/12345) under destination_directory, " +
+		"the uncompressed tar member of the file is read from the file entry instead of whole"
+	keepGoingFlag        = "keep-going"
+	keepGoingDescription = "Continue the restore when a tar member fails to extract after the retries, " +
+		"report the files which are not restored and exit with a non-zero code at the end (overrides " +
+		internal.FetchKeepGoingSetting + ")"
+	deltaStepsActionFlag        = "delta-steps-action"
+	deltaStepsActionDescription = "What to do when --" + maxDeltaStepsFlag + " is exceeded: 'fail' or 'warn' " +
+		"(overrides " + internal.FetchDeltaStepsAction + ")"
+	promoteFlag        = "promote"
+	promoteDescription = "Configure the recovery to promote the restored cluster once the recovery target is reached, " +
+		"or to recover up to the end of the archived WAL if there is no target"
+	standbyFlag        = "standby"
+	standbyDescription = "Configure the restored cluster to start as a standby fetching the archived WAL"
 )
 
 var fileMask string
@@ -28,40 +102,272 @@ var restoreSpec string
 var reverseDeltaUnpack bool
 var skipRedundantTars bool
 var fetchTargetUserData string
+var downloadConcurrency int
+var restoreOnly []string
+var verifyOnFetch bool
+var skipRelFileNodes []uint
+var catalogsOnly bool
+var streamFetch bool
+var fetchTargetTime string
+var fetchTargetLsn string
+var tablespaceMappings []string
+var flattenTablespaces bool
+var waitRehydrate bool
+var noProgress bool
+var validateOnly bool
+var fetchRestorePoint string
+var inplaceFetch bool
+var fetchFsync bool
+var noFetchFsync bool
+var maxDeltaSteps int
+var deltaStepsAction string
+var fetchFile string
+var keepGoing bool
+var fetchPromote bool
+var fetchStandby bool
 
 var backupFetchCmd = &cobra.Command{
-	Use:   "backup-fetch destination_directory [backup_name | --target-user-data <data>]",
+	Use:   "backup-fetch {destination_directory | --stream} [backup_name | target flags]",
 	Short: backupFetchShortDescription, // TODO : improve description
-	Args:  cobra.RangeArgs(1, 2),
+	Args:  cobra.RangeArgs(0, 2),
 	Run: func(cmd *cobra.Command, args []string) {
+		destinationDirectory, targetName := parseBackupFetchArgs(cmd, args)
+
+		if cmd.Flags().Changed(downloadConcurrencyFlag) {
+			viper.Set(internal.DownloadConcurrencySetting, downloadConcurrency)
+		}
+		_, err := internal.GetMaxDownloadConcurrency()
+		internal.FatalUsageOnError(err)
+		if verifyOnFetch {
+			viper.Set(internal.VerifyOnFetchSetting, true)
+		}
+		if waitRehydrate {
+			viper.Set(azure.WaitRehydrateSetting, true)
+		}
+		if noProgress {
+			viper.Set(internal.FetchProgressSetting, false)
+		}
+		if keepGoing {
+			viper.Set(internal.FetchKeepGoingSetting, true)
+		}
+		if cmd.Flags().Changed(fsyncFlag) && noFetchFsync {
+			internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s and %s options can't be used together", fsyncFlag, noFsyncFlag)
+		}
+		if cmd.Flags().Changed(fsyncFlag) || noFetchFsync {
+			viper.Set(internal.TarDisableFsyncSetting, !fetchFsync || noFetchFsync)
+		}
+
 		if fetchTargetUserData == "" {
 			fetchTargetUserData = viper.GetString(internal.FetchTargetUserDataSetting)
 		}
-		targetBackupSelector, err := createTargetFetchBackupSelector(cmd, args, fetchTargetUserData)
-		tracelog.ErrorLogger.FatalOnError(err)
+		targetBackupSelector, err := createTargetFetchBackupSelector(cmd, targetName, fetchTargetUserData, fetchTargetTime,
+			fetchTargetLsn)
+		internal.FatalUsageOnError(err)
+		if fetchTargetLsn != "" && fetchRestorePoint != "" {
+			internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s and %s options can't be used together",
+				targetLsnFlag, restorePointFlag)
+		}
+		recoveryConfig, err := createRecoveryConfig()
+		internal.FatalUsageOnError(err)
+		if (fetchPromote || fetchStandby) && (streamFetch || fetchFile != "" || validateOnly) {
+			internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s and %s options can't be used with --%s, --%s or --%s",
+				promoteFlag, standbyFlag, streamFlag, fileFlag, validateOnlyFlag)
+		}
 
 		folder, err := internal.ConfigureFolder()
-		tracelog.ErrorLogger.FatalOnError(err)
+		internal.FatalOnError(err)
+
+		relFileNodes := make([]uint32, 0, len(skipRelFileNodes))
+		for _, relFileNode := range skipRelFileNodes {
+			if relFileNode > math.MaxUint32 {
+				internal.FatalfWithExitCode(internal.ExitCodeUsage, "Invalid %s value: %d", skipRelFileNodeFlag, relFileNode)
+			}
+			relFileNodes = append(relFileNodes, uint32(relFileNode))
+		}
+
+		tablespaceMapping, err := postgres.ParseTablespaceMapping(tablespaceMappings)
+		internal.FatalUsageOnError(err)
+		if catalogsOnly && (len(restoreOnly) > 0 || len(relFileNodes) > 0) {
+			internal.FatalfWithExitCode(internal.ExitCodeUsage,
+				"%s option can't be used with --%s or --%s options", catalogsOnlyFlag, restoreOnlyFlag, skipRelFileNodeFlag)
+		}
 
 		var pgFetcher func(folder storage.Folder, backup internal.Backup)
 		reverseDeltaUnpack = reverseDeltaUnpack || viper.GetBool(internal.UseReverseUnpackSetting)
 		skipRedundantTars = skipRedundantTars || viper.GetBool(internal.SkipRedundantTarsSetting)
-		if reverseDeltaUnpack {
-			pgFetcher = postgres.GetPgFetcherNew(args[0], fileMask, restoreSpec, skipRedundantTars)
-		} else {
-			pgFetcher = postgres.GetPgFetcherOld(args[0], fileMask, restoreSpec)
+		fetchKeepGoing := viper.GetBool(internal.FetchKeepGoingSetting)
+		if fetchKeepGoing && (streamFetch || fetchFile != "" || validateOnly || inplaceFetch || reverseDeltaUnpack) {
+			internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option can't be used with --%s, --%s, --%s, --%s "+
+				"or the reverse delta unpack", keepGoingFlag, streamFlag, fileFlag, validateOnlyFlag, inplaceFlag)
+		}
+		switch {
+		case fetchFile != "":
+			if streamFetch || inplaceFetch || validateOnly || fileMask != "" || restoreSpec != "" ||
+				len(tablespaceMapping) > 0 || flattenTablespaces || len(restoreOnly) > 0 || len(relFileNodes) > 0 ||
+				catalogsOnly || fetchRestorePoint != "" || fetchTargetLsn != "" {
+				internal.FatalfWithExitCode(internal.ExitCodeUsage,
+					"%s option can't be used with the options restoring the whole backup", fileFlag)
+			}
+			pgFetcher = postgres.GetPgFileFetcher(destinationDirectory, fetchFile)
+		case streamFetch:
+			if reverseDeltaUnpack || restoreSpec != "" || len(tablespaceMapping) > 0 || flattenTablespaces ||
+				len(restoreOnly) > 0 || len(relFileNodes) > 0 || viper.GetBool(internal.VerifyOnFetchSetting) ||
+				validateOnly || fetchRestorePoint != "" || fetchTargetLsn != "" || inplaceFetch || catalogsOnly {
+				internal.FatalfWithExitCode(internal.ExitCodeUsage,
+					"%s option can be used only with --mask and --target-user-data options", streamFlag)
+			}
+			pgFetcher = postgres.GetPgStreamFetcher(os.Stdout, fileMask)
+		case inplaceFetch:
+			if reverseDeltaUnpack || fileMask != "" || restoreSpec != "" || len(tablespaceMapping) > 0 || flattenTablespaces ||
+				len(restoreOnly) > 0 || len(relFileNodes) > 0 || validateOnly || catalogsOnly {
+				internal.FatalfWithExitCode(internal.ExitCodeUsage,
+					"%s option can't be used with the options changing the restored files or their locations", inplaceFlag)
+			}
+			pgFetcher = postgres.GetPgInplaceFetcher(destinationDirectory)
+		case validateOnly:
+			pgFetcher = postgres.GetPgValidateOnlyFetcher(destinationDirectory, fileMask, restoreSpec, tablespaceMapping,
+				flattenTablespaces, restoreOnly, relFileNodes, catalogsOnly)
+		case reverseDeltaUnpack:
+			pgFetcher = postgres.GetPgFetcherNew(destinationDirectory, fileMask, restoreSpec, tablespaceMapping,
+				flattenTablespaces, skipRedundantTars, restoreOnly, relFileNodes, catalogsOnly)
+		default:
+			pgFetcher = postgres.GetPgFetcherOld(destinationDirectory, fileMask, restoreSpec, tablespaceMapping,
+				flattenTablespaces, restoreOnly, relFileNodes, catalogsOnly)
+		}
+
+		if fetchKeepGoing {
+			pgFetcher = postgres.GetPgKeepGoingFetcher(pgFetcher)
+		}
+		if !streamFetch && fetchFile == "" {
+			pgFetcher = configureMaxDeltaSteps(cmd, pgFetcher)
+			pgFetcher = postgres.GetPgRestoreChecksFetcher(pgFetcher)
+		}
+		if fetchFile == "" {
+			pgFetcher = postgres.GetPgRehydrateFetcher(pgFetcher)
+		}
+		if fetchRestorePoint != "" {
+			pgFetcher = postgres.GetPgRestorePointFetcher(pgFetcher, fetchRestorePoint)
+		}
+		if fetchTargetLsn != "" {
+			targetLsn, err := postgres.ParseTargetLsn(fetchTargetLsn)
+			internal.FatalUsageOnError(err)
+			pgFetcher = postgres.GetPgTargetLsnFetcher(pgFetcher, targetLsn)
+		}
+		if recoveryConfig != nil && !streamFetch && fetchFile == "" && !validateOnly {
+			pgFetcher = postgres.GetPgRecoveryConfigFetcher(pgFetcher, destinationDirectory, recoveryConfig)
 		}
 
 		internal.HandleBackupFetch(folder, targetBackupSelector, pgFetcher)
 	},
 }
 
+// configureMaxDeltaSteps wraps the fetcher to check the length of the delta chain if the maximum delta steps are set
+func configureMaxDeltaSteps(cmd *cobra.Command,
+	pgFetcher func(folder storage.Folder, backup internal.Backup)) func(folder storage.Folder, backup internal.Backup) {
+	if cmd.Flags().Changed(maxDeltaStepsFlag) {
+		viper.Set(internal.FetchMaxDeltaSteps, maxDeltaSteps)
+	}
+	if cmd.Flags().Changed(deltaStepsActionFlag) {
+		viper.Set(internal.FetchDeltaStepsAction, deltaStepsAction)
+	}
+	maxDeltaStepsValue, ok := internal.GetSetting(internal.FetchMaxDeltaSteps)
+	if !ok {
+		return pgFetcher
+	}
+	maxSteps, err := strconv.Atoi(maxDeltaStepsValue)
+	if err != nil || maxSteps < 0 {
+		internal.FatalfWithExitCode(internal.ExitCodeUsage, "Invalid %s value: %s", internal.FetchMaxDeltaSteps, maxDeltaStepsValue)
+	}
+	action, err := postgres.ParseDeltaStepsAction(viper.GetString(internal.FetchDeltaStepsAction))
+	internal.FatalUsageOnError(err)
+	return postgres.GetPgMaxDeltaStepsFetcher(pgFetcher, maxSteps, action)
+}
+
+// createRecoveryConfig returns the recovery configuration to write to the restored data directory,
+// there is none unless the recovery target, --promote or --standby is specified
+func createRecoveryConfig() (*postgres.RecoveryConfig, error) {
+	if fetchTargetTime == "" && fetchTargetLsn == "" && fetchRestorePoint == "" && !fetchPromote && !fetchStandby {
+		return nil, nil
+	}
+	if fetchPromote && fetchStandby {
+		return nil, fmt.Errorf("%s and %s options can't be used together", promoteFlag, standbyFlag)
+	}
+	if fetchTargetTime != "" && fetchRestorePoint != "" {
+		return nil, fmt.Errorf("%s and %s options can't be used together", targetTimeFlag, restorePointFlag)
+	}
+
+	recoveryConfig := postgres.NewRecoveryConfig(fetchPromote, fetchStandby)
+	switch {
+	case fetchTargetTime != "":
+		targetTime, err := time.Parse(time.RFC3339, fetchTargetTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s, expected RFC 3339 time: %v", targetTimeFlag, err)
+		}
+		recoveryConfig.SetTargetTime(targetTime)
+	case fetchTargetLsn != "":
+		targetLsn, err := postgres.ParseTargetLsn(fetchTargetLsn)
+		if err != nil {
+			return nil, err
+		}
+		recoveryConfig.SetTargetLsn(targetLsn)
+	case fetchRestorePoint != "":
+		recoveryConfig.SetTargetName(fetchRestorePoint)
+	}
+	return recoveryConfig, nil
+}
+
+// parseBackupFetchArgs returns the destination directory and the backup name,
+// there is no destination directory when the backup is streamed
+func parseBackupFetchArgs(cmd *cobra.Command, args []string) (destinationDirectory, targetName string) {
+	if streamFetch {
+		if len(args) > 1 {
+			fmt.Println(cmd.UsageString())
+			internal.FatalfWithExitCode(internal.ExitCodeUsage, "only the backup name is expected with the %s option", streamFlag)
+		}
+		if len(args) == 1 {
+			targetName = args[0]
+		}
+		return "", targetName
+	}
+
+	if len(args) == 0 {
+		fmt.Println(cmd.UsageString())
+		internal.FatalfWithExitCode(internal.ExitCodeUsage, "destination_directory is required")
+	}
+	if len(args) == 2 {
+		targetName = args[1]
+	}
+	return args[0], targetName
+}
+
 // create the BackupSelector to select the backup to fetch
 func createTargetFetchBackupSelector(cmd *cobra.Command,
-	args []string, targetUserData string) (internal.BackupSelector, error) {
-	targetName := ""
-	if len(args) >= 2 {
-		targetName = args[1]
+	targetName, targetUserData, targetTime, targetLsn string) (internal.BackupSelector, error) {
+	if targetLsn != "" {
+		if targetName != "" || targetUserData != "" || targetTime != "" {
+			fmt.Println(cmd.UsageString())
+			return nil, fmt.Errorf("incorrect arguments. Specify target backup name, target userdata, " +
+				"target time OR target LSN, not several of them")
+		}
+		parsedLsn, err := postgres.ParseTargetLsn(targetLsn)
+		if err != nil {
+			return nil, err
+		}
+		tracelog.InfoLogger.Printf("Selecting the newest backup finished before LSN %s...\n", targetLsn)
+		return postgres.NewTargetLsnBackupSelector(parsedLsn), nil
+	}
+	if targetTime != "" {
+		if targetName != "" || targetUserData != "" {
+			fmt.Println(cmd.UsageString())
+			return nil, fmt.Errorf("incorrect arguments. Specify target backup name, target userdata " +
+				"OR target time, not several of them")
+		}
+		parsedTime, err := time.Parse(time.RFC3339, targetTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s, expected RFC 3339 time: %v", targetTimeFlag, err)
+		}
+		tracelog.InfoLogger.Printf("Selecting the newest backup finished before %s...\n", targetTime)
+		return internal.NewBeforeTimeSelector(parsedTime, postgres.NewGenericMetaFetcher()), nil
 	}
 
 	backupSelector, err := internal.NewTargetBackupSelector(targetUserData, targetName, postgres.NewGenericMetaFetcher())
@@ -81,5 +387,51 @@ func init() {
 		false, skipRedundantTarsDescription)
 	backupFetchCmd.Flags().StringVar(&fetchTargetUserData, "target-user-data",
 		"", targetUserDataDescription)
+	backupFetchCmd.Flags().IntVar(&downloadConcurrency, downloadConcurrencyFlag,
+		0, downloadConcurrencyDescription)
+	backupFetchCmd.Flags().StringSliceVar(&restoreOnly, restoreOnlyFlag,
+		nil, restoreOnlyDescription)
+	backupFetchCmd.Flags().UintSliceVar(&skipRelFileNodes, skipRelFileNodeFlag,
+		nil, skipRelFileNodeDescription)
+	backupFetchCmd.Flags().BoolVar(&catalogsOnly, catalogsOnlyFlag,
+		false, catalogsOnlyDescription)
+	backupFetchCmd.Flags().BoolVar(&verifyOnFetch, verifyOnFetchFlag,
+		false, verifyOnFetchDescription)
+	backupFetchCmd.Flags().StringArrayVar(&tablespaceMappings, tablespaceMappingFlag,
+		nil, tablespaceMappingDescription)
+	backupFetchCmd.Flags().BoolVar(&flattenTablespaces, flattenTablespacesFlag,
+		false, flattenTablespacesDescription)
+	backupFetchCmd.Flags().StringVar(&fetchTargetTime, targetTimeFlag,
+		"", targetTimeDescription)
+	backupFetchCmd.Flags().StringVar(&fetchTargetLsn, targetLsnFlag,
+		"", targetLsnDescription)
+	backupFetchCmd.Flags().BoolVar(&waitRehydrate, waitRehydrateFlag,
+		false, waitRehydrateDescription)
+	backupFetchCmd.Flags().BoolVar(&noProgress, noProgressFlag,
+		false, noProgressDescription)
+	backupFetchCmd.Flags().BoolVar(&validateOnly, validateOnlyFlag,
+		false, validateOnlyDescription)
+	backupFetchCmd.Flags().StringVar(&fetchRestorePoint, restorePointFlag,
+		"", restorePointDescription)
+	backupFetchCmd.Flags().BoolVar(&streamFetch, streamFlag,
+		false, streamDescription)
+	backupFetchCmd.Flags().BoolVar(&inplaceFetch, inplaceFlag,
+		false, inplaceDescription)
+	backupFetchCmd.Flags().BoolVar(&fetchFsync, fsyncFlag,
+		true, fsyncDescription)
+	backupFetchCmd.Flags().BoolVar(&noFetchFsync, noFsyncFlag,
+		false, noFsyncDescription)
+	backupFetchCmd.Flags().IntVar(&maxDeltaSteps, maxDeltaStepsFlag,
+		0, maxDeltaStepsDescription)
+	backupFetchCmd.Flags().StringVar(&deltaStepsAction, deltaStepsActionFlag,
+		postgres.DeltaStepsActionFail, deltaStepsActionDescription)
+	backupFetchCmd.Flags().StringVar(&fetchFile, fileFlag,
+		"", fileDescription)
+	backupFetchCmd.Flags().BoolVar(&keepGoing, keepGoingFlag,
+		false, keepGoingDescription)
+	backupFetchCmd.Flags().BoolVar(&fetchPromote, promoteFlag,
+		false, promoteDescription)
+	backupFetchCmd.Flags().BoolVar(&fetchStandby, standbyFlag,
+		false, standbyDescription)
 	Cmd.AddCommand(backupFetchCmd)
 }
@@ -1,6 +1,8 @@
 package pg
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
@@ -13,6 +15,20 @@ const (
 	PrettyFlag                 = "pretty"
 	JSONFlag                   = "json"
 	DetailFlag                 = "detail"
+	SinceFlag                  = "since"
+	UntilFlag                  = "until"
+	PermanentOnlyFlag          = "permanent-only"
+	StorageUsageFlag           = "storage-usage"
+	PricePerGBFlag             = "price-per-gb"
+
+	sinceDescription = "Prints only the backups finished at or after the specified time: " +
+		"now, a RFC 3339 timestamp or a duration back from now like 7d or 12h"
+	untilDescription = "Prints only the backups finished at or before the specified time: " +
+		"now, a RFC 3339 timestamp or a duration back from now like 7d or 12h"
+	permanentOnlyDescription = "Prints only the permanent backups"
+	storageUsageDescription  = "Prints the compressed size of each backup with its delta chain, " +
+		"of the WAL archive and their total, counting the backups shared by several delta chains once"
+	pricePerGBDescription = "Prints the estimated monthly cost of the storage usage for the specified price per GiB-month"
 )
 
 var (
@@ -22,18 +38,43 @@ var (
 		Short: backupListShortDescription, // TODO : improve description
 		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
+			filter, err := postgres.NewBackupListFilter(since, until, permanentOnly, time.Now())
+			internal.FatalUsageOnError(err)
+			if pricePerGB < 0 {
+				internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s must not be negative", PricePerGBFlag)
+			}
+			if pricePerGB > 0 && !storageUsage {
+				internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option can be used only with %s option",
+					PricePerGBFlag, StorageUsageFlag)
+			}
+			if storageUsage && (detail || !filter.IsEmpty()) {
+				internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s, %s, %s options",
+					StorageUsageFlag, DetailFlag, SinceFlag, UntilFlag, PermanentOnlyFlag)
+			}
 			folder, err := internal.ConfigureFolder()
 			tracelog.ErrorLogger.FatalOnError(err)
-			if detail {
-				postgres.HandleDetailedBackupList(folder.GetSubFolder(utility.BaseBackupPath), pretty, json)
-			} else {
+			switch {
+			case storageUsage:
+				postgres.HandleBackupStorageUsage(folder, pretty, json, pricePerGB)
+			case detail:
+				postgres.HandleDetailedBackupList(folder.GetSubFolder(utility.BaseBackupPath), pretty, json, filter)
+			case json:
+				postgres.HandleBackupListJSON(folder.GetSubFolder(utility.BaseBackupPath), pretty, filter)
+			case !filter.IsEmpty():
+				postgres.HandleFilteredBackupList(folder.GetSubFolder(utility.BaseBackupPath), pretty, filter)
+			default:
 				internal.DefaultHandleBackupList(folder.GetSubFolder(utility.BaseBackupPath), pretty, json)
 			}
 		},
 	}
-	pretty = false
-	json   = false
-	detail = false
+	pretty        = false
+	json          = false
+	detail        = false
+	since         = ""
+	until         = ""
+	permanentOnly = false
+	storageUsage  = false
+	pricePerGB    float64
 )
 
 func init() {
@@ -44,4 +85,9 @@ func init() {
 	backupListCmd.Flags().BoolVar(&pretty, PrettyFlag, false, "Prints more readable output")
 	backupListCmd.Flags().BoolVar(&json, JSONFlag, false, "Prints output in json format")
 	backupListCmd.Flags().BoolVar(&detail, DetailFlag, false, "Prints extra backup details")
+	backupListCmd.Flags().StringVar(&since, SinceFlag, "", sinceDescription)
+	backupListCmd.Flags().StringVar(&until, UntilFlag, "", untilDescription)
+	backupListCmd.Flags().BoolVar(&permanentOnly, PermanentOnlyFlag, false, permanentOnlyDescription)
+	backupListCmd.Flags().BoolVar(&storageUsage, StorageUsageFlag, false, storageUsageDescription)
+	backupListCmd.Flags().Float64Var(&pricePerGB, PricePerGBFlag, 0, pricePerGBDescription)
 }
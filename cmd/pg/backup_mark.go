@@ -10,7 +10,11 @@ import (
 const (
 	BackupMarkShortDescription = "Marks a backup permanent or impermanent"
 	BackupMarkLongDescription  = `Marks a backup permanent by default, or impermanent when flag is provided.
-	Permanent backups are prevented from being removed when running delete.`
+	Permanent backups are prevented from being removed when running delete.
+	Marking a delta backup permanent also marks all the backups it depends on,
+	a backup can't be marked impermanent while a permanent delta backup depends on it.`
+	PermanentDescription   = "Marks a backup permanent (default)"
+	PermanentFlag          = "permanent"
 	ImpermanentDescription = "Marks a backup impermanent"
 	ImpermanentFlag        = "impermanent"
 )
@@ -23,15 +27,21 @@ var (
 		Long:  BackupMarkLongDescription,
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
+			if toPermanent && toImpermanent {
+				tracelog.ErrorLogger.Fatalf("only one of the --%s and --%s flags can be provided",
+					PermanentFlag, ImpermanentFlag)
+			}
 			uploader, err := postgres.ConfigureWalUploader()
 			tracelog.ErrorLogger.FatalOnError(err)
 			internal.HandleBackupMark(uploader.Uploader, args[0], !toImpermanent, postgres.NewGenericMetaInteractor())
 		},
 	}
+	toPermanent   = false
 	toImpermanent = false
 )
 
 func init() {
+	backupMarkCmd.Flags().BoolVarP(&toPermanent, PermanentFlag, "p", false, PermanentDescription)
 	backupMarkCmd.Flags().BoolVarP(&toImpermanent, ImpermanentFlag, "i", false, ImpermanentDescription)
 	Cmd.AddCommand(backupMarkCmd)
 }
@@ -0,0 +1,47 @@
+package pg
+
+import (
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	backupMountShortDescription = "Mounts the backup read-only via FUSE"
+	backupMountLongDescription  = `Exposes the files of the full backup at the mount point read-only, until the command
+is interrupted. The tar member of a file is fetched and extracted into the cache directory on the first open of the file,
+and is reused by the next mounts of the backup.`
+
+	mountCacheDirFlag        = "cache-dir"
+	mountCacheDirDescription = "Directory of the extracted tar members, wal-g-mount-cache in the scratch directory by default"
+)
+
+var mountCacheDir string
+
+var backupMountCmd = &cobra.Command{
+	Use:   "backup-mount backup_name | LATEST mountpoint",
+	Short: backupMountShortDescription,
+	Long:  backupMountLongDescription,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		backupSelector, err := internal.NewTargetBackupSelector("", args[0], postgres.NewGenericMetaFetcher())
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		folder, err := internal.ConfigureFolder()
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		cacheDirectory := mountCacheDir
+		if cacheDirectory == "" {
+			cacheDirectory = filepath.Join(internal.GetScratchDir(), "wal-g-mount-cache")
+		}
+		postgres.HandleBackupMount(folder, backupSelector, args[1], cacheDirectory)
+	},
+}
+
+func init() {
+	backupMountCmd.Flags().StringVar(&mountCacheDir, mountCacheDirFlag, "", mountCacheDirDescription)
+	Cmd.AddCommand(backupMountCmd)
+}
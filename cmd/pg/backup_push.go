@@ -2,6 +2,7 @@ package pg
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/wal-g/wal-g/utility"
 
@@ -24,10 +25,34 @@ const (
 	useRatingComposerFlag     = "rating-composer"
 	useCopyComposerFlag       = "copy-composer"
 	useGpComposerFlag         = "gp-composer"
+	useTablespaceComposerFlag = "tablespace-composer"
 	deltaFromUserDataFlag     = "delta-from-user-data"
 	deltaFromNameFlag         = "delta-from-name"
 	addUserDataFlag           = "add-user-data"
 	withoutFilesMetadataFlag  = "without-files-metadata"
+	minimalFilesMetadataFlag  = "minimal-files-metadata"
+	rateLimitFlag             = "rate-limit"
+	dryRunFlag                = "dry-run"
+	resumeFlag                = "resume"
+	tarSizeThresholdFlag      = "tar-size-threshold"
+	partialUserDataMatchFlag  = "partial-user-data-match"
+	fullIfOlderThanFlag       = "full-if-older-than"
+	reuseRatingStatsFlag      = "reuse-rating-stats"
+	dumpRatingFlag            = "dump-rating"
+	deltaDetectionFlag        = "delta-detection"
+	timeoutFlag               = "timeout"
+	skipWalValidationFlag     = "skip-wal-validation"
+	dedupSmallFilesFlag       = "dedup-small-files"
+	targetStorageClassFlag    = "target-storage-class"
+	parallelTablespacesFlag   = "parallel-tablespaces"
+	skipUnloggedFlag          = "skip-unlogged"
+	whileStandbyFlag          = "while-standby"
+	fromStdinFlag             = "from-stdin"
+	splitLargeFilesFlag       = "split-large-files"
+	fromSnapshotFlag          = "from-snapshot"
+	followSymlinksFlag        = "follow-symlinks"
+	verifyChecksumsOnlyFlag   = "verify-checksums-only"
+	uploadLabelFilesFlag      = "upload-label-files"
 
 	permanentShorthand             = "p"
 	fullBackupShorthand            = "f"
@@ -50,10 +75,55 @@ var (
 				dataDirectory = args[0]
 			}
 
+			if cmd.Flags().Changed(rateLimitFlag) {
+				viper.Set(internal.UploadRateLimitSetting, uploadRateLimit)
+				err := internal.ConfigureUploadRateLimiter()
+				internal.FatalUsageOnError(err)
+			}
+			if cmd.Flags().Changed(tarSizeThresholdFlag) {
+				viper.Set(internal.TarSizeThresholdSetting, tarSizeThreshold)
+			}
+			_, err := internal.GetTarSizeThreshold()
+			internal.FatalUsageOnError(err)
+			if cmd.Flags().Changed(fullIfOlderThanFlag) {
+				viper.Set(internal.FullIfOlderThanSetting, fullIfOlderThan)
+			}
+			fullIfOlderThanDuration, err := internal.GetFullIfOlderThan()
+			internal.FatalUsageOnError(err)
+
 			verifyPageChecksums = verifyPageChecksums || viper.GetBool(internal.VerifyPageChecksumsSetting)
 			storeAllCorruptBlocks = storeAllCorruptBlocks || viper.GetBool(internal.StoreAllCorruptBlocksSetting)
 
 			tarBallComposerType := chooseTarBallComposer()
+			if cmd.Flags().Changed(deltaDetectionFlag) {
+				viper.Set(internal.DeltaDetectionSetting, deltaDetection)
+			}
+			deltaDetectionMode, err := postgres.GetDeltaDetection()
+			internal.FatalUsageOnError(err)
+			if deltaDetectionMode == postgres.DeltaDetectionLsn && tarBallComposerType == postgres.CopyComposer {
+				// the copy composer reuses the tarballs of the unchanged files detected by the modification time
+				internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s=%s cannot be used with %s option",
+					deltaDetectionFlag, postgres.DeltaDetectionLsn, useCopyComposerFlag)
+			}
+			if reuseRatingStats {
+				viper.Set(internal.ReuseRatingStatsSetting, true)
+			}
+			if cmd.Flags().Changed(dumpRatingFlag) {
+				viper.Set(internal.DumpRatingSetting, dumpRating)
+			}
+			if viper.GetString(internal.DumpRatingSetting) != "" && tarBallComposerType != postgres.RatingComposer {
+				internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option can be used only with %s option",
+					dumpRatingFlag, useRatingComposerFlag)
+			}
+			if cmd.Flags().Changed(targetStorageClassFlag) {
+				viper.Set(internal.StorageClassSetting, targetStorageClass)
+			}
+			if cmd.Flags().Changed(followSymlinksFlag) {
+				viper.Set(internal.FollowSymlinksSetting, followSymlinks)
+			}
+			if cmd.Flags().Changed(uploadLabelFilesFlag) {
+				viper.Set(internal.UploadLabelFilesSetting, uploadLabelFiles)
+			}
 
 			if deltaFromName == "" {
 				deltaFromName = viper.GetString(internal.DeltaFromNameSetting)
@@ -64,37 +134,162 @@ var (
 			if userDataRaw == "" {
 				userDataRaw = viper.GetString(internal.SentinelUserDataSetting)
 			}
+			if tarBallComposerType == postgres.CopyComposer && deltaFromName == "" && deltaFromUserData == "" {
+				// the copy composer makes a delta backup only against an explicitly selected base
+				fullBackup = true
+			}
 			withoutFilesMetadata = withoutFilesMetadata || viper.GetBool(internal.WithoutFilesMetadataSetting)
 			if withoutFilesMetadata {
 				// files metadata tracking is required for delta backups and copy/rating composers
 				if tarBallComposerType != postgres.RegularComposer {
-					tracelog.ErrorLogger.Fatalf(
+					internal.FatalfWithExitCode(internal.ExitCodeUsage,
 						"%s option cannot be used with non-regular tar ball composer",
 						withoutFilesMetadataFlag)
 				}
 				if deltaFromName != "" || deltaFromUserData != "" || userDataRaw != "" {
-					tracelog.ErrorLogger.Fatalf(
+					internal.FatalfWithExitCode(internal.ExitCodeUsage,
 						"%s option cannot be used with %s, %s, %s options",
 						withoutFilesMetadataFlag, deltaFromNameFlag, deltaFromUserDataFlag, addUserDataFlag)
 				}
 				tracelog.InfoLogger.Print("Files metadata tracking is disabled")
 				fullBackup = true
 			}
+			minimalFilesMetadata = minimalFilesMetadata || viper.GetBool(internal.MinimalFilesMetadataSetting)
+			if minimalFilesMetadata {
+				// only the tar members and their sizes are tracked, which is not enough for delta backups
+				if withoutFilesMetadata {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s option",
+						minimalFilesMetadataFlag, withoutFilesMetadataFlag)
+				}
+				if tarBallComposerType != postgres.RegularComposer {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage,
+						"%s option cannot be used with non-regular tar ball composer",
+						minimalFilesMetadataFlag)
+				}
+				if deltaFromName != "" || deltaFromUserData != "" {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s options",
+						minimalFilesMetadataFlag, deltaFromNameFlag, deltaFromUserDataFlag)
+				}
+				tracelog.InfoLogger.Print("Only the tar members and their sizes are tracked in the files metadata")
+				fullBackup = true
+			}
+			if resumeBackupName != "" {
+				// the tarballs of the unfinished backup are matched using the files metadata
+				if withoutFilesMetadata {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s option",
+						resumeFlag, withoutFilesMetadataFlag)
+				}
+				if minimalFilesMetadata {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s option",
+						resumeFlag, minimalFilesMetadataFlag)
+				}
+				fullBackup = true
+			}
+			dedupSmallFiles = dedupSmallFiles || viper.GetBool(internal.DedupSmallFilesSetting)
+			if dedupSmallFiles {
+				// the copies are recorded in the files metadata and only the regular composer packs them once
+				if tarBallComposerType != postgres.RegularComposer {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage,
+						"%s option cannot be used with non-regular tar ball composer", dedupSmallFilesFlag)
+				}
+				if withoutFilesMetadata || minimalFilesMetadata || resumeBackupName != "" {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s, %s options",
+						dedupSmallFilesFlag, withoutFilesMetadataFlag, minimalFilesMetadataFlag, resumeFlag)
+				}
+				_, err = internal.GetDedupMaxFileSize()
+				internal.FatalUsageOnError(err)
+			}
+			splitLargeFiles = splitLargeFiles || viper.GetBool(internal.SplitLargeFilesSetting)
+			if splitLargeFiles {
+				// the parts are recorded in the files metadata and only the regular composer packs them in parallel
+				if tarBallComposerType != postgres.RegularComposer {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage,
+						"%s option cannot be used with non-regular tar ball composer", splitLargeFilesFlag)
+				}
+				if withoutFilesMetadata || minimalFilesMetadata || resumeBackupName != "" {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s, %s options",
+						splitLargeFilesFlag, withoutFilesMetadataFlag, minimalFilesMetadataFlag, resumeFlag)
+				}
+				// the pages of the file are verified in a single pass over the whole file
+				if verifyPageChecksums || viper.GetBool(internal.VerifyPageChecksumsSetting) {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s option",
+						splitLargeFilesFlag, verifyPagesFlag)
+				}
+				_, err = internal.GetSplitFileSize()
+				internal.FatalUsageOnError(err)
+			}
+			if parallelTablespaces {
+				// each tablespace walker feeds its own regular composer
+				if tarBallComposerType != postgres.RegularComposer {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage,
+						"%s option cannot be used with non-regular tar ball composer", parallelTablespacesFlag)
+				}
+				if resumeBackupName != "" {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s option",
+						parallelTablespacesFlag, resumeFlag)
+				}
+			}
+
+			skipUnlogged = skipUnlogged || viper.GetBool(internal.SkipUnloggedSetting)
+			if skipUnlogged && (withoutFilesMetadata || minimalFilesMetadata) {
+				// the skipped forks are recorded in the files metadata
+				internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s options",
+					skipUnloggedFlag, withoutFilesMetadataFlag, minimalFilesMetadataFlag)
+			}
+
+			whileStandby = whileStandby || viper.GetBool(internal.WhileStandbySetting)
+			if fromStdin {
+				checkFromStdinFlags(tarBallComposerType)
+				fullBackup = true
+			}
+			if fromSnapshot {
+				checkFromSnapshotFlags(tarBallComposerType, dataDirectory)
+			}
+			// the whole data directory is verified as by the full backup, nothing is packed
+			if verifyChecksumsOnly && (dryRun || fromStdin || resumeBackupName != "") {
+				internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s, %s options",
+					verifyChecksumsOnlyFlag, dryRunFlag, fromStdinFlag, resumeFlag)
+			}
 
-			deltaBaseSelector, err := createDeltaBaseSelector(cmd, deltaFromName, deltaFromUserData)
-			tracelog.ErrorLogger.FatalOnError(err)
+			deltaBaseSelector, err := createDeltaBaseSelector(cmd, deltaFromName, deltaFromUserData, partialUserDataMatch)
+			internal.FatalUsageOnError(err)
 
 			userData, err := internal.UnmarshalSentinelUserData(userDataRaw)
-			tracelog.ErrorLogger.FatalfOnError("Failed to unmarshal the provided UserData: %s", err)
+			internal.FatalUsageOnError(errors.Wrap(err, "Failed to unmarshal the provided UserData"))
 
-			arguments := postgres.NewBackupArguments(dataDirectory, utility.BaseBackupPath,
-				permanent, verifyPageChecksums || viper.GetBool(internal.VerifyPageChecksumsSetting),
-				fullBackup, storeAllCorruptBlocks || viper.GetBool(internal.StoreAllCorruptBlocksSetting),
-				tarBallComposerType, deltaBaseSelector, userData, withoutFilesMetadata)
+			arguments := postgres.NewBackupArguments(postgres.BackupPushOptions{
+				DataDirectory:         dataDirectory,
+				BackupsFolder:         utility.BaseBackupPath,
+				Permanent:             permanent,
+				VerifyPageChecksums:   verifyPageChecksums || viper.GetBool(internal.VerifyPageChecksumsSetting),
+				FullBackup:            fullBackup,
+				StoreAllCorruptBlocks: storeAllCorruptBlocks || viper.GetBool(internal.StoreAllCorruptBlocksSetting),
+				TarBallComposerType:   tarBallComposerType,
+				DeltaBaseSelector:     deltaBaseSelector,
+				UserData:              userData,
+				WithoutFilesMetadata:  withoutFilesMetadata,
+				MinimalFilesMetadata:  minimalFilesMetadata,
+				DryRun:                dryRun,
+				ResumeBackupName:      resumeBackupName,
+				FullIfOlderThan:       fullIfOlderThanDuration,
+				Timeout:               backupTimeout,
+				SkipWalValidation:     skipWalValidation,
+				DedupSmallFiles:       dedupSmallFiles,
+				ParallelTablespaces:   parallelTablespaces,
+				SkipUnlogged:          skipUnlogged,
+				WhileStandby:          whileStandby,
+				FromStdin:             fromStdin,
+				SplitLargeFiles:       splitLargeFiles,
+				FromSnapshot:          fromSnapshot,
+				VerifyChecksumsOnly:   verifyChecksumsOnly,
+			})
 
-			backupHandler, err := postgres.NewBackupHandler(arguments)
-			tracelog.ErrorLogger.FatalOnError(err)
-			backupHandler.HandleBackupPush()
+			uploader, err := postgres.ConfigureWalUploader()
+			internal.FatalOnError(err)
+			ctx, stop := postgres.NewInterruptContext()
+			defer stop()
+			_, err = postgres.RunBackupPush(ctx, arguments, uploader)
+			internal.FatalOnError(err)
 		},
 	}
 	permanent             = false
@@ -104,12 +299,75 @@ var (
 	useRatingComposer     = false
 	useCopyComposer       = false
 	useGpComposer         = false
+	useTablespaceComposer = false
 	deltaFromName         = ""
 	deltaFromUserData     = ""
 	userDataRaw           = ""
 	withoutFilesMetadata  = false
+	minimalFilesMetadata  = false
+	uploadRateLimit       = ""
+	dryRun                = false
+	resumeBackupName      = ""
+	tarSizeThreshold      = ""
+	partialUserDataMatch  = false
+	fullIfOlderThan       = ""
+	reuseRatingStats      = false
+	dumpRating            = ""
+	deltaDetection        = ""
+	backupTimeout         time.Duration
+	skipWalValidation     = false
+	dedupSmallFiles       = false
+	targetStorageClass    = ""
+	parallelTablespaces   = false
+	skipUnlogged          = false
+	whileStandby          = false
+	fromStdin             = false
+	splitLargeFiles       = false
+	fromSnapshot          = false
+	followSymlinks        = ""
+	verifyChecksumsOnly   = false
+	uploadLabelFiles      = false
 )
 
+// checkFromStdinFlags fails on the options which need the walk of the data directory or the delta base,
+// the tar stream of pg_basebackup is only re-framed into the tar members
+func checkFromStdinFlags(tarBallComposerType postgres.TarBallComposerType) {
+	if tarBallComposerType != postgres.RegularComposer {
+		internal.FatalfWithExitCode(internal.ExitCodeUsage,
+			"%s option cannot be used with non-regular tar ball composer", fromStdinFlag)
+	}
+	if deltaFromName != "" || deltaFromUserData != "" || resumeBackupName != "" {
+		internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s, %s options",
+			fromStdinFlag, deltaFromNameFlag, deltaFromUserDataFlag, resumeFlag)
+	}
+	if minimalFilesMetadata || dedupSmallFiles || parallelTablespaces || skipUnlogged || whileStandby || splitLargeFiles {
+		internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s, %s, %s, %s, %s options",
+			fromStdinFlag, minimalFilesMetadataFlag, dedupSmallFilesFlag, parallelTablespacesFlag, skipUnloggedFlag,
+			whileStandbyFlag, splitLargeFilesFlag)
+	}
+	if dryRun || verifyPageChecksums || backupTimeout > 0 {
+		internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s, %s options",
+			fromStdinFlag, dryRunFlag, verifyPagesFlag, timeoutFlag)
+	}
+}
+
+// checkFromSnapshotFlags fails on the options which need the connection to the live cluster,
+// the snapshot is backed up as already stopped by pg_backup_stop
+func checkFromSnapshotFlags(tarBallComposerType postgres.TarBallComposerType, dataDirectory string) {
+	if dataDirectory == "" {
+		internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option requires the path of the mounted snapshot",
+			fromSnapshotFlag)
+	}
+	if tarBallComposerType != postgres.RegularComposer {
+		internal.FatalfWithExitCode(internal.ExitCodeUsage,
+			"%s option cannot be used with non-regular tar ball composer", fromSnapshotFlag)
+	}
+	if fromStdin || resumeBackupName != "" || whileStandby || skipWalValidation {
+		internal.FatalfWithExitCode(internal.ExitCodeUsage, "%s option cannot be used with %s, %s, %s, %s options",
+			fromSnapshotFlag, fromStdinFlag, resumeFlag, whileStandbyFlag, skipWalValidationFlag)
+	}
+}
+
 func chooseTarBallComposer() postgres.TarBallComposerType {
 	tarBallComposerType := postgres.RegularComposer
 
@@ -119,9 +377,12 @@ func chooseTarBallComposer() postgres.TarBallComposerType {
 	}
 	useCopyComposer = useCopyComposer || viper.GetBool(internal.UseCopyComposerSetting)
 	if useCopyComposer {
-		fullBackup = true
 		tarBallComposerType = postgres.CopyComposer
 	}
+	useTablespaceComposer = useTablespaceComposer || viper.GetBool(internal.UseTablespaceComposerSetting)
+	if useTablespaceComposer {
+		tarBallComposerType = postgres.TablespaceComposer
+	}
 	if useGpComposer {
 		fullBackup = true
 		tarBallComposerType = postgres.GreenplumComposer
@@ -132,7 +393,7 @@ func chooseTarBallComposer() postgres.TarBallComposerType {
 
 // create the BackupSelector for delta backup base according to the provided flags
 func createDeltaBaseSelector(cmd *cobra.Command,
-	targetBackupName, targetUserData string) (internal.BackupSelector, error) {
+	targetBackupName, targetUserData string, partialUserDataMatch bool) (internal.BackupSelector, error) {
 	switch {
 	case targetUserData != "" && targetBackupName != "":
 		fmt.Println(cmd.UsageString())
@@ -143,6 +404,11 @@ func createDeltaBaseSelector(cmd *cobra.Command,
 			targetBackupName)
 		return internal.NewBackupNameSelector(targetBackupName, true)
 
+	case targetUserData != "" && partialUserDataMatch:
+		tracelog.InfoLogger.Println(
+			"Selecting the latest backup containing specified user data as the base for the current delta backup...")
+		return internal.NewPartialUserDataBackupSelector(targetUserData, postgres.NewGenericMetaFetcher())
+
 	case targetUserData != "":
 		tracelog.InfoLogger.Println(
 			"Selecting the backup with specified user data as the base for the current delta backup...")
@@ -168,14 +434,79 @@ func init() {
 		false, "Use rating tar composer (beta)")
 	backupPushCmd.Flags().BoolVarP(&useCopyComposer, useCopyComposerFlag, useCopyComposerShorthand,
 		false, "Use copy tar composer (beta)")
+	backupPushCmd.Flags().BoolVar(&useTablespaceComposer, useTablespaceComposerFlag,
+		false, "Use tablespace tar composer which keeps the files of each tablespace in their own tarballs")
+	backupPushCmd.Flags().BoolVar(&reuseRatingStats, reuseRatingStatsFlag,
+		false, "Make the rating composer reuse the relations statistics stored by the previous backups")
+	backupPushCmd.Flags().StringVar(&dumpRating, dumpRatingFlag,
+		"", "Write the relations statistics and the files packed into each tarball by the rating composer "+
+			"to the specified JSON file")
 	backupPushCmd.Flags().BoolVar(&useGpComposer, useGpComposerFlag, false, "Use gp tar composer (beta)")
 	_ = backupPushCmd.Flags().MarkHidden(useGpComposerFlag)
 	backupPushCmd.Flags().StringVar(&deltaFromName, deltaFromNameFlag,
 		"", "Select the backup specified by name as the target for the delta backup")
 	backupPushCmd.Flags().StringVar(&deltaFromUserData, deltaFromUserDataFlag,
 		"", "Select the backup specified by UserData as the target for the delta backup")
+	backupPushCmd.Flags().BoolVar(&partialUserDataMatch, partialUserDataMatchFlag,
+		false, "Select the latest backup whose UserData contains the keys and values of the "+
+			deltaFromUserDataFlag+" JSON object as the target for the delta backup")
 	backupPushCmd.Flags().StringVar(&userDataRaw, addUserDataFlag,
 		"", "Write the provided user data to the backup sentinel and metadata files.")
 	backupPushCmd.Flags().BoolVar(&withoutFilesMetadata, withoutFilesMetadataFlag,
 		false, "Do not track files metadata, significantly reducing memory usage")
+	backupPushCmd.Flags().BoolVar(&minimalFilesMetadata, minimalFilesMetadataFlag,
+		false, "Track only the tar members and their sizes instead of the files metadata, "+
+			"the backup is always full")
+	backupPushCmd.Flags().StringVar(&uploadRateLimit, rateLimitFlag,
+		"", "Limit the total upload bandwidth in bytes per second, accepts size suffixes (e.g. 50M), 0 means unlimited")
+	backupPushCmd.Flags().BoolVar(&dryRun, dryRunFlag,
+		false, "Report the files to backup and their total size without uploading anything")
+	backupPushCmd.Flags().StringVar(&resumeBackupName, resumeFlag,
+		"", "Resume the unfinished backup with the specified name, reusing its uploaded tarballs")
+	backupPushCmd.Flags().StringVar(&tarSizeThreshold, tarSizeThresholdFlag,
+		"", "The size of one backup tarball in bytes, accepts size suffixes (e.g. 512M)")
+	backupPushCmd.Flags().StringVar(&fullIfOlderThan, fullIfOlderThanFlag,
+		"", "Make a full backup instead of a delta if the full backup of the delta base is older "+
+			"than the specified duration (e.g. 7d or 12h)")
+	backupPushCmd.Flags().StringVar(&deltaDetection, deltaDetectionFlag,
+		"", "Detect the changed files of the delta backup by the modification time (mtime) "+
+			"or by the page LSNs compared with the delta base start LSN (lsn)")
+	backupPushCmd.Flags().DurationVar(&backupTimeout, timeoutFlag,
+		0, fmt.Sprintf("Abort the backup running longer than the specified duration (e.g. 3h), "+
+			"deleting its uploaded files, and exit with code %d", postgres.ExitCodeBackupTimeout))
+	backupPushCmd.Flags().BoolVar(&skipWalValidation, skipWalValidationFlag,
+		false, "Do not wait for the backup WAL to be archived and mark the backup as WAL unverified "+
+			"(for non-production clusters without the WAL archiving)")
+	backupPushCmd.Flags().BoolVar(&dedupSmallFiles, dedupSmallFilesFlag,
+		false, "Store the byte-identical files not larger than WALG_DEDUP_MAX_FILE_SIZE once, "+
+			"the copies are restored from the same tar member")
+	backupPushCmd.Flags().StringVar(&targetStorageClass, targetStorageClassFlag,
+		"", "Upload the backup into the specified storage class of S3 or GCS (overrides "+
+			internal.StorageClassSetting+")")
+	backupPushCmd.Flags().BoolVar(&parallelTablespaces, parallelTablespacesFlag,
+		false, "Walk the tablespaces concurrently with the data directory, each by its own walker")
+	backupPushCmd.Flags().BoolVar(&skipUnlogged, skipUnloggedFlag,
+		false, "Pack only the init forks of the unlogged relations, their other forks are recreated "+
+			"by the recovery")
+	backupPushCmd.Flags().BoolVar(&whileStandby, whileStandbyFlag,
+		false, "Pause the WAL replay of the standby while the files are read, "+
+			"the replay is resumed once they are packed even if the backup fails")
+	backupPushCmd.Flags().BoolVar(&fromStdin, fromStdinFlag,
+		false, "Upload the full backup made by 'pg_basebackup --format=tar -D -' and read from stdin "+
+			"instead of walking the data directory")
+	backupPushCmd.Flags().BoolVar(&splitLargeFiles, splitLargeFilesFlag,
+		false, "Split the files larger than WALG_SPLIT_FILE_SIZE into the parts of that size, "+
+			"packed into the separate tar members and uploaded in parallel")
+	backupPushCmd.Flags().BoolVar(&fromSnapshot, fromSnapshotFlag,
+		false, "Back up the mounted filesystem snapshot of the data directory, reading its backup_label "+
+			"instead of starting and stopping the backup on the server")
+	backupPushCmd.Flags().StringVar(&followSymlinks, followSymlinksFlag,
+		"", "Comma-separated list of the symlinks relative to the data directory (e.g. pg_wal), the targets of "+
+			"which are backed up in place of them, the rest of the symlinks are backed up as the symlinks")
+	backupPushCmd.Flags().BoolVar(&verifyChecksumsOnly, verifyChecksumsOnlyFlag,
+		false, "Only verify the page checksums of the data directory and report the corrupt blocks per relation, "+
+			"without packing or uploading anything")
+	backupPushCmd.Flags().BoolVar(&uploadLabelFiles, uploadLabelFilesFlag,
+		false, "Also upload backup_label and tablespace_map as the separate objects next to the sentinel, "+
+			"so backup-show-label reads them without downloading the tar members")
 }
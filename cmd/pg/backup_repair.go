@@ -0,0 +1,36 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	backupRepairShortDescription = "Re-uploads the missing and corrupt tar members of the backup from the running cluster"
+	backupRepairLongDescription  = `Verifies the backup as backup-verify does and re-packs its missing and corrupt tar members
+from the data directory of the running cluster the backup is taken from. Every file of these tar members must be
+the same as in the backup by the checksums recorded in the files metadata, otherwise nothing is uploaded
+and the command exits with code 65.`
+)
+
+var backupRepairCmd = &cobra.Command{
+	Use:   "backup-repair backup_name | LATEST PGDATA",
+	Short: backupRepairShortDescription,
+	Long:  backupRepairLongDescription,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		backupSelector, err := internal.NewTargetBackupSelector("", args[0], postgres.NewGenericMetaFetcher())
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		uploader, err := internal.ConfigureUploader()
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		postgres.HandleBackupRepair(uploader, backupSelector, args[1])
+	},
+}
+
+func init() {
+	Cmd.AddCommand(backupRepairCmd)
+}
@@ -0,0 +1,55 @@
+package pg
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	backupRetainShortDescription = "Keeps a backup and the WAL segments needed to restore it, optionally until the expiry time"
+	backupRetainLongDescription  = `Marks the backup and the backups of its delta chain permanent and pins the WAL segments
+their restore needs, so delete never removes them. The pinned WAL segments are recorded in the backup metadata
+and checked by wal-verify retained. Once the hold set by --until expires, the backups are no longer permanent.
+The existing hold is extended, never shortened, and backup-mark replaces the hold.`
+	backupRetainUntilFlag        = "until"
+	backupRetainUntilDescription = "Keep the backup until this time (RFC 3339), indefinitely if not set"
+)
+
+var (
+	backupRetainCmd = &cobra.Command{
+		Use:   "backup-retain backup_name | LATEST",
+		Short: backupRetainShortDescription,
+		Long:  backupRetainLongDescription,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var until *time.Time
+			if backupRetainUntil != "" {
+				parsedTime, err := time.Parse(time.RFC3339, backupRetainUntil)
+				internal.FatalUsageOnError(err)
+				if !parsedTime.After(time.Now()) {
+					internal.FatalfWithExitCode(internal.ExitCodeUsage, "--%s %s is in the past\n",
+						backupRetainUntilFlag, backupRetainUntil)
+				}
+				until = &parsedTime
+			}
+
+			backupSelector, err := internal.NewTargetBackupSelector("", args[0], postgres.NewGenericMetaFetcher())
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			folder, err := internal.ConfigureFolder()
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			postgres.HandleBackupRetain(folder, backupSelector, until)
+		},
+	}
+	backupRetainUntil string
+)
+
+func init() {
+	backupRetainCmd.Flags().StringVar(&backupRetainUntil, backupRetainUntilFlag, "", backupRetainUntilDescription)
+	Cmd.AddCommand(backupRetainCmd)
+}
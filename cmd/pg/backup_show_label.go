@@ -0,0 +1,35 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	backupShowLabelShortDescription = "Prints the backup_label and tablespace_map of the backup"
+	backupShowLabelLongDescription  = `Prints the backup_label and tablespace_map of the backup without downloading
+its tar members. The label files are stored as the separate objects only by the backups made
+with WALG_UPLOAD_LABEL_FILES or backup-push --upload-label-files.`
+)
+
+var backupShowLabelCmd = &cobra.Command{
+	Use:   "backup-show-label backup_name | LATEST",
+	Short: backupShowLabelShortDescription,
+	Long:  backupShowLabelLongDescription,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		backupSelector, err := internal.NewTargetBackupSelector("", args[0], postgres.NewGenericMetaFetcher())
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		folder, err := internal.ConfigureFolder()
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		postgres.HandleBackupShowLabel(folder, backupSelector)
+	},
+}
+
+func init() {
+	Cmd.AddCommand(backupShowLabelCmd)
+}
@@ -0,0 +1,35 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	backupVerifyShortDescription = "Checks that the backup can be restored without restoring it"
+	backupVerifyLongDescription  = `Downloads every tar member of the backup without writing anything to disk,
+checks that it is decrypted and decompressed successfully and contains the files recorded in the backup metadata,
+and that pg_control and backup_label are present. Exits with non-zero code if any check fails.`
+)
+
+var backupVerifyCmd = &cobra.Command{
+	Use:   "backup-verify backup_name | LATEST",
+	Short: backupVerifyShortDescription,
+	Long:  backupVerifyLongDescription,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		backupSelector, err := internal.NewTargetBackupSelector("", args[0], postgres.NewGenericMetaFetcher())
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		folder, err := internal.ConfigureFolder()
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		postgres.HandleBackupVerify(folder, backupSelector)
+	},
+}
+
+func init() {
+	Cmd.AddCommand(backupVerifyCmd)
+}
@@ -22,7 +22,7 @@ var (
 			folder, err := internal.ConfigureFolder()
 			tracelog.ErrorLogger.FatalOnError(err)
 			if detail {
-				postgres.HandleDetailedBackupList(folder.GetSubFolder(utility.CatchupPath), pretty, json)
+				postgres.HandleDetailedBackupList(folder.GetSubFolder(utility.CatchupPath), pretty, json, postgres.BackupListFilter{})
 			} else {
 				internal.DefaultHandleBackupList(folder.GetSubFolder(utility.CatchupPath), pretty, json)
 			}
@@ -26,6 +26,9 @@ const (
 	withoutHistoryFlag        = "without-history"
 	withoutHistoryShorthand   = "w"
 	withoutHistoryDescription = "Copy backup without history"
+
+	withDeltaChainFlag        = "with-delta-chain"
+	withDeltaChainDescription = "Copy the delta backup together with the backups it is based on"
 )
 
 var (
@@ -33,6 +36,7 @@ var (
 	fromConfigFile string
 	toConfigFile   string
 	withoutHistory = false
+	withDeltaChain = false
 
 	backupCopyCmd = &cobra.Command{
 		Use:   backupCopyUsage,
@@ -48,7 +52,7 @@ var (
 )
 
 func runBackupCopy(cmd *cobra.Command, args []string) {
-	postgres.HandleCopy(fromConfigFile, toConfigFile, backupName, withoutHistory)
+	postgres.HandleCopy(fromConfigFile, toConfigFile, backupName, withoutHistory, withDeltaChain)
 }
 
 func init() {
@@ -62,6 +66,7 @@ func init() {
 		withoutHistoryShorthand,
 		false,
 		withoutHistoryDescription)
+	backupCopyCmd.Flags().BoolVar(&withDeltaChain, withDeltaChainFlag, false, withDeltaChainDescription)
 
 	_ = backupCopyCmd.MarkFlagRequired(toFlag)
 	_ = backupCopyCmd.MarkFlagRequired(fromFlag)
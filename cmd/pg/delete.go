@@ -5,6 +5,7 @@ import (
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/utility"
 )
 
 const UseSentinelTimeFlag = "use-sentinel-time"
@@ -13,10 +14,14 @@ const DeleteGarbageExamples = `  garbage           Deletes outdated WAL archives
   garbage ARCHIVES  Deletes only outdated WAL archives from storage
   garbage BACKUPS   Deletes only leftover backups files from storage`
 const DeleteGarbageUse = "garbage [ARCHIVES|BACKUPS]"
+const DeleteGarbageDryRunFlag = "dry-run"
+const DeleteGarbageOrphanMinAgeFlag = "orphan-min-age"
 
 var confirmed = false
 var useSentinelTime = false
 var deleteTargetUserData = ""
+var deleteGarbageDryRun = false
+var deleteGarbageOrphanMinAge = ""
 
 // deleteCmd represents the delete command
 var deleteCmd = &cobra.Command{
@@ -121,12 +126,21 @@ func runDeleteGarbage(cmd *cobra.Command, args []string) {
 	folder, err := internal.ConfigureFolder()
 	tracelog.ErrorLogger.FatalOnError(err)
 
+	orphanMinAge := postgres.DefaultOrphanMinAge
+	if deleteGarbageOrphanMinAge != "" {
+		orphanMinAge, err = utility.ParseDuration(deleteGarbageOrphanMinAge)
+		tracelog.ErrorLogger.FatalfOnError("Failed to parse --"+DeleteGarbageOrphanMinAgeFlag+": %v", err)
+	}
+	if deleteGarbageDryRun {
+		confirmed = false
+	}
+
 	permanentBackups, permanentWals := postgres.GetPermanentBackupsAndWals(folder)
 
 	deleteHandler, err := postgres.NewDeleteHandler(folder, permanentBackups, permanentWals, false)
 	tracelog.ErrorLogger.FatalOnError(err)
 
-	err = deleteHandler.HandleDeleteGarbage(args, folder, confirmed)
+	err = deleteHandler.HandleDeleteGarbage(args, folder, confirmed, orphanMinAge)
 	tracelog.ErrorLogger.FatalOnError(err)
 }
 
@@ -141,6 +155,12 @@ func init() {
 	deleteTargetCmd.Flags().StringVar(
 		&deleteTargetUserData, internal.DeleteTargetUserDataFlag, "", internal.DeleteTargetUserDataDescription)
 
+	deleteGarbageCmd.Flags().BoolVar(&deleteGarbageDryRun, DeleteGarbageDryRunFlag, false,
+		"Only list the objects which would be deleted, even if the deletion is confirmed")
+	deleteGarbageCmd.Flags().StringVar(&deleteGarbageOrphanMinAge, DeleteGarbageOrphanMinAgeFlag, "",
+		"Keep the backup objects not referenced by any backup if they are younger than the specified duration "+
+			"(e.g. 12h or 2d), since the backup in progress has no sentinel yet. Default: 24h")
+
 	deleteCmd.AddCommand(deleteRetainCmd, deleteBeforeCmd, deleteEverythingCmd, deleteTargetCmd, deleteGarbageCmd)
 	deleteCmd.PersistentFlags().BoolVar(&confirmed, internal.ConfirmFlag, false, "Confirms backup deletion")
 	deleteCmd.PersistentFlags().BoolVar(&useSentinelTime, UseSentinelTimeFlag, false, UseSentinelTimeDescription)
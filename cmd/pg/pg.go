@@ -11,8 +11,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
 )
 
 const WalgShortDescription = "PostgreSQL backup tool"
@@ -29,11 +29,12 @@ var (
 		Version: strings.Join([]string{walgVersion, gitRevision, buildDate, "PostgreSQL"}, "\t"),
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			err := internal.AssertRequiredSettingsSet()
-			tracelog.ErrorLogger.FatalOnError(err)
+			internal.FatalOnError(err)
 
 			if viper.IsSet(internal.PgWalSize) {
 				postgres.SetWalSize(viper.GetUint64(internal.PgWalSize))
 			}
+			zstd.SetDictionaryLoader(postgres.LoadWalZstdDictionary)
 		},
 	}
 )
@@ -42,9 +43,10 @@ var (
 // This is called by main.main(). It only needs to happen once to the PgCmd.
 func Execute() {
 	configureCommand()
+	// cobra fails only on the invalid arguments and flags, the commands exit by themselves
 	if err := Cmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(internal.ExitCodeUsage)
 	}
 }
 
@@ -0,0 +1,42 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	rewrapKeysShortDescription = "Rewraps the data keys of the backups by the new envelope master key"
+	rewrapKeysLongDescription  = `Unwraps the data key stored in the sentinel of every backup by the old master keys
+(the comma-separated WALG_ENVELOPE_OLD_MASTER_KEY or WALG_ENVELOPE_OLD_MASTER_KEY_PATH) and wraps it by the new one
+(WALG_ENVELOPE_MASTER_KEY or WALG_ENVELOPE_MASTER_KEY_PATH). Only the sentinels are rewritten, the tarballs are not moved.`
+)
+
+var rewrapKeysCmd = &cobra.Command{
+	Use:   "rewrap-keys",
+	Short: rewrapKeysShortDescription,
+	Long:  rewrapKeysLongDescription,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		newMasterKey, err := internal.ConfigureEnvelopeMasterKey(internal.EnvelopeKeySetting, internal.EnvelopeKeyPathSetting)
+		tracelog.ErrorLogger.FatalOnError(err)
+		oldMasterKeys, err := internal.ConfigureEnvelopeOldMasterKeys()
+		tracelog.ErrorLogger.FatalOnError(err)
+		if newMasterKey == nil || len(oldMasterKeys) == 0 {
+			internal.FatalfWithExitCode(internal.ExitCodeUsage, "rewrap-keys requires %s (or %s) and %s (or %s) to be set",
+				internal.EnvelopeKeySetting, internal.EnvelopeKeyPathSetting,
+				internal.EnvelopeOldKeySetting, internal.EnvelopeOldKeyPathSetting)
+		}
+
+		folder, err := internal.ConfigureFolder()
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		postgres.HandleRewrapKeys(folder, oldMasterKeys, newMasterKey)
+	},
+}
+
+func init() {
+	Cmd.AddCommand(rewrapKeysCmd)
+}
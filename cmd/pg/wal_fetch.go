@@ -2,7 +2,6 @@ package pg
 
 import (
 	"github.com/spf13/cobra"
-	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
 )
@@ -16,8 +15,10 @@ var walFetchCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		folder, err := internal.ConfigureFolder()
-		tracelog.ErrorLogger.FatalOnError(err)
-		postgres.HandleWALFetch(folder, args[0], args[1], true)
+		internal.FatalOnError(err)
+		failoverStorages, err := internal.ConfigureFailoverStorages()
+		internal.FatalOnError(err)
+		postgres.HandleWALFetch(folder, failoverStorages, args[0], args[1], true)
 	},
 }
 
@@ -19,6 +19,9 @@ var walPushCmd = &cobra.Command{
 		uploader, err := postgres.ConfigureWalUploader()
 		tracelog.ErrorLogger.FatalOnError(err)
 
+		uploader.FailoverStorages, err = internal.ConfigureFailoverStorages()
+		tracelog.ErrorLogger.FatalOnError(err)
+
 		archiveStatusManager, err := internal.ConfigureArchiveStatusManager()
 		if err == nil {
 			uploader.ArchiveStatusManager = asm.NewDataFolderASM(archiveStatusManager)
@@ -15,20 +15,29 @@ import (
 
 const (
 	WalVerifyUsage            = "wal-verify"
-	WalVerifyShortDescription = "Verify WAL storage folder. Available checks: integrity, timeline."
+	WalVerifyShortDescription = "Verify WAL storage folder. Available checks: integrity, timeline, retained."
 	WalVerifyLongDescription  = "Run a set of specified checks to ensure WAL storage health."
 
 	useJSONOutputFlag        = "json"
 	useJSONOutputDescription = "Show output in JSON format."
 
+	startBackupNameFlag        = "backup-name"
+	startBackupNameDescription = "Start the integrity check from the specified backup instead of the earliest one. " +
+		"Use LATEST to start from the latest backup."
+
+	fullScanFlag        = "full"
+	fullScanDescription = "Verify the whole WAL range instead of the segments archived after the previous run."
+
 	checkIntegrityArg = "integrity"
 	checkTimelineArg  = "timeline"
+	checkRetainedArg  = "retained"
 )
 
 var (
 	availableChecks = map[string]postgres.WalVerifyCheckType{
 		checkIntegrityArg: postgres.WalVerifyIntegrityCheck,
 		checkTimelineArg:  postgres.WalVerifyTimelineCheck,
+		checkRetainedArg:  postgres.WalVerifyRetainedCheck,
 	}
 	// walVerifyCmd represents the walVerify command
 	walVerifyCmd = &cobra.Command{
@@ -46,10 +55,13 @@ var (
 			outputWriter := postgres.NewWalVerifyOutputWriter(outputType, os.Stdout)
 			checkTypes := parseChecks(checks)
 
-			postgres.HandleWalVerify(checkTypes, folder, postgres.QueryCurrentWalSegment(), outputWriter)
+			postgres.HandleWalVerify(checkTypes, folder, postgres.QueryCurrentWalSegment(), startBackupName, fullScan,
+				outputWriter)
 		},
 	}
-	useJSONOutput bool
+	useJSONOutput   bool
+	startBackupName string
+	fullScan        bool
 )
 
 func parseChecks(checks []string) []postgres.WalVerifyCheckType {
@@ -90,4 +102,6 @@ func checkArgs(cmd *cobra.Command, args []string) error {
 func init() {
 	Cmd.AddCommand(walVerifyCmd)
 	walVerifyCmd.Flags().BoolVar(&useJSONOutput, useJSONOutputFlag, false, useJSONOutputDescription)
+	walVerifyCmd.Flags().StringVar(&startBackupName, startBackupNameFlag, "", startBackupNameDescription)
+	walVerifyCmd.Flags().BoolVar(&fullScan, fullScanFlag, false, fullScanDescription)
 }
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	AutoConcurrency = "auto"
+
+	autoConcurrencyCacheFile = ".walg_concurrency_cache"
+	autoConcurrencyCacheTTL  = 24 * time.Hour
+	autoConcurrencyProbeSize = 8 << 20
+	// autoCompressedBytesPerWorker is the rough output of one packer worker compressing with lz4 or zstd
+	autoCompressedBytesPerWorker = 64 << 20
+	autoMaxUploadConcurrency     = 64
+)
+
+type InvalidConcurrencyModeError struct {
+	error
+}
+
+func newInvalidConcurrencyModeError(mode string) InvalidConcurrencyModeError {
+	return InvalidConcurrencyModeError{errors.Errorf(
+		"%s '%s' is not supported, the only supported value is '%s'", ConcurrencySetting, mode, AutoConcurrency)}
+}
+
+func (err InvalidConcurrencyModeError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err InvalidConcurrencyModeError) ExitCode() int {
+	return ExitCodeUsage
+}
+
+// autoConcurrency is the worker pool sizes chosen by WALG_CONCURRENCY=auto
+type autoConcurrency struct {
+	uploadDisk int
+	upload     int
+}
+
+// chooseAutoConcurrency balances the CPU-bound packer workers against the I/O-bound upload streams.
+// Half of the CPUs compress, the rest is left to the upload streams, encryption and the database itself.
+// There are enough upload streams to carry the compressed output of the packers at the probed bandwidth
+// of one stream, and at least one stream per packer. The zero bandwidth means the probe failed.
+func chooseAutoConcurrency(cpus int, streamBytesPerSecond float64) autoConcurrency {
+	uploadDisk := cpus / 2
+	if uploadDisk < MinAllowedConcurrency {
+		uploadDisk = MinAllowedConcurrency
+	}
+	upload := 2 * uploadDisk
+	if streamBytesPerSecond > 0 {
+		upload = int(math.Ceil(float64(uploadDisk*autoCompressedBytesPerWorker) / streamBytesPerSecond))
+	}
+	if upload < uploadDisk {
+		upload = uploadDisk
+	}
+	if upload > autoMaxUploadConcurrency {
+		upload = autoMaxUploadConcurrency
+	}
+	return autoConcurrency{uploadDisk: uploadDisk, upload: upload}
+}
+
+// ConfigureAutoConcurrency sizes WALG_UPLOAD_DISK_CONCURRENCY and WALG_UPLOAD_CONCURRENCY from the CPUs
+// and the upload bandwidth if WALG_CONCURRENCY is auto. The chosen values replace the defaults only,
+// so it must be called before the settings are bound to the environment, and the numbers set
+// in the config file, the environment or the flags stay authoritative. The storage is not probed
+// if both numbers are set.
+func ConfigureAutoConcurrency(config *viper.Viper) error {
+	mode := config.GetString(ConcurrencySetting)
+	if mode == "" {
+		return nil
+	}
+	if mode != AutoConcurrency {
+		return newInvalidConcurrencyModeError(mode)
+	}
+	if isSetByUser(config, UploadDiskConcurrencySetting) && isSetByUser(config, UploadConcurrencySetting) {
+		return nil
+	}
+
+	cpus := runtime.GOMAXPROCS(0)
+	bandwidth, err := getUploadStreamBandwidth(config)
+	if err != nil {
+		tracelog.WarningLogger.Printf("%s=%s: failed to probe the upload bandwidth, sizing by the CPUs only: %v",
+			ConcurrencySetting, AutoConcurrency, err)
+	}
+	concurrency := chooseAutoConcurrency(cpus, bandwidth)
+	// the defaults are strings, as only the string settings are bound to the environment
+	config.SetDefault(UploadDiskConcurrencySetting, strconv.Itoa(concurrency.uploadDisk))
+	config.SetDefault(UploadConcurrencySetting, strconv.Itoa(concurrency.upload))
+
+	bandwidthDescription := "unknown"
+	if bandwidth > 0 {
+		bandwidthDescription = fmt.Sprintf("%.1f MiB/s", bandwidth/(1<<20))
+	}
+	tracelog.InfoLogger.Printf("%s=%s: %d CPUs, %s per upload stream: %s=%d, %s=%d",
+		ConcurrencySetting, AutoConcurrency, cpus, bandwidthDescription,
+		UploadDiskConcurrencySetting, config.GetInt(UploadDiskConcurrencySetting),
+		UploadConcurrencySetting, config.GetInt(UploadConcurrencySetting))
+	return nil
+}
+
+// isSetByUser tells whether the setting is set in the config file or the environment.
+// viper.IsSet can't be used here, since it reports the settings with the defaults too.
+func isSetByUser(config *viper.Viper, setting string) bool {
+	_, inEnv := os.LookupEnv(setting)
+	return inEnv || config.InConfig(setting)
+}
+
+// CachedUploadBandwidth is the probed bandwidth of one upload stream to the storage
+type CachedUploadBandwidth struct {
+	Storage        string
+	BytesPerSecond float64
+	ProbedAt       time.Time
+}
+
+// getUploadStreamBandwidth returns the bandwidth of one upload stream. The probe result is cached
+// for a day, so the frequent commands like wal-push do not probe the storage on every run.
+func getUploadStreamBandwidth(config *viper.Viper) (float64, error) {
+	storageName := configuredStorageName(config)
+	cacheFilename := ""
+	if usr, err := user.Current(); err == nil {
+		cacheFilename = filepath.Join(usr.HomeDir, autoConcurrencyCacheFile)
+		var cache CachedUploadBandwidth
+		file, err := os.ReadFile(cacheFilename)
+		if err == nil && json.Unmarshal(file, &cache) == nil && cache.Storage == storageName &&
+			time.Since(cache.ProbedAt) < autoConcurrencyCacheTTL {
+			return cache.BytesPerSecond, nil
+		}
+	}
+
+	bandwidth, err := probeUploadStreamBandwidth(config)
+	if err != nil {
+		return 0, err
+	}
+	if cacheFilename != "" {
+		cache, err := json.Marshal(CachedUploadBandwidth{
+			Storage:        storageName,
+			BytesPerSecond: bandwidth,
+			ProbedAt:       time.Now(),
+		})
+		if err == nil {
+			err = os.WriteFile(cacheFilename, cache, 0644)
+		}
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to cache the upload bandwidth in %s: %v", cacheFilename, err)
+		}
+	}
+	return bandwidth, nil
+}
+
+// probeUploadStreamBandwidth times the upload of the incompressible probe object, which is deleted afterwards.
+// The probe is uploaded to the base backups folder, so nothing is written outside of the WAL-G prefix.
+func probeUploadStreamBandwidth(config *viper.Viper) (float64, error) {
+	folder, err := ConfigureFolderForSpecificConfig(config)
+	if err != nil {
+		return 0, err
+	}
+	if prefix := config.GetString(StoragePrefixSetting); prefix != "" {
+		folder = folder.GetSubFolder(prefix)
+	}
+	folder = folder.GetSubFolder(utility.BaseBackupPath)
+	probe := make([]byte, autoConcurrencyProbeSize)
+	rand.Read(probe) //nolint:gosec
+	probeName := fmt.Sprintf("walg_concurrency_probe_%d", os.Getpid())
+
+	start := time.Now()
+	err = folder.PutObject(probeName, bytes.NewReader(probe))
+	elapsed := time.Since(start)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to upload %s", probeName)
+	}
+	err = folder.DeleteObjects([]string{probeName})
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to delete %s: %v", probeName, err)
+	}
+	return float64(autoConcurrencyProbeSize) / elapsed.Seconds(), nil
+}
+
+// configuredStorageName returns the storage prefix setting with its value, which tells the cached
+// bandwidths of the different storages apart
+func configuredStorageName(config *viper.Viper) string {
+	for _, adapter := range StorageAdapters {
+		if prefix, ok := getWaleCompatibleSettingFrom(adapter.prefixName, config); ok {
+			return adapter.prefixName + "=" + prefix
+		}
+	}
+	return ""
+}
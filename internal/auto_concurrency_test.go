@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChooseAutoConcurrency(t *testing.T) {
+	for _, testCase := range []struct {
+		name       string
+		cpus       int
+		bandwidth  float64
+		uploadDisk int
+		upload     int
+	}{
+		{"one CPU without the probe", 1, 0, 1, 2},
+		{"eight CPUs without the probe", 8, 0, 4, 8},
+		{"fast link", 8, 1 << 30, 4, 4},
+		{"slow link", 8, 16 << 20, 4, 16},
+		{"very slow link", 64, 1 << 20, 32, autoMaxUploadConcurrency},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			concurrency := chooseAutoConcurrency(testCase.cpus, testCase.bandwidth)
+			assert.Equal(t, testCase.uploadDisk, concurrency.uploadDisk)
+			assert.Equal(t, testCase.upload, concurrency.upload)
+		})
+	}
+}
+
+// unsetConcurrencyEnv removes the upload concurrency exported to the environment by InitConfig,
+// the environment is restored after the test
+func unsetConcurrencyEnv(t *testing.T) {
+	for _, setting := range []string{UploadDiskConcurrencySetting, UploadConcurrencySetting} {
+		t.Setenv(setting, "")
+		require.NoError(t, os.Unsetenv(setting))
+	}
+}
+
+func resetConfig(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		ConfigureSettings(PG)
+		InitConfig()
+	})
+}
+
+func TestConfigureAutoConcurrency_InvalidMode(t *testing.T) {
+	config := viper.New()
+	config.Set(ConcurrencySetting, "8")
+
+	err := ConfigureAutoConcurrency(config)
+	assert.IsType(t, InvalidConcurrencyModeError{}, err)
+}
+
+func TestConfigureAutoConcurrency_ExplicitSettingIsAuthoritative(t *testing.T) {
+	unsetConcurrencyEnv(t)
+	t.Setenv(UploadConcurrencySetting, "5")
+	config := viper.New()
+	config.AutomaticEnv()
+	SetDefaultValues(config)
+	config.Set(ConcurrencySetting, AutoConcurrency)
+
+	// no storage is configured, so the workers are sized by the CPUs only
+	assert.NoError(t, ConfigureAutoConcurrency(config))
+	assert.Equal(t, 5, config.GetInt(UploadConcurrencySetting))
+	assert.Equal(t, chooseAutoConcurrency(runtime.GOMAXPROCS(0), 0).uploadDisk,
+		config.GetInt(UploadDiskConcurrencySetting))
+}
+
+func TestInitConfig_AutoConcurrency(t *testing.T) {
+	unsetConcurrencyEnv(t)
+	resetConfig(t)
+	t.Setenv(ConcurrencySetting, AutoConcurrency)
+	viper.Reset()
+	ConfigureSettings(PG)
+
+	InitConfig()
+	concurrency := chooseAutoConcurrency(runtime.GOMAXPROCS(0), 0)
+	assert.Equal(t, concurrency.uploadDisk, viper.GetInt(UploadDiskConcurrencySetting))
+	assert.Equal(t, concurrency.upload, viper.GetInt(UploadConcurrencySetting))
+	// the commands read the settings bound to the environment, which carry the chosen values
+	assert.Equal(t, strconv.Itoa(concurrency.uploadDisk), os.Getenv(UploadDiskConcurrencySetting))
+	assert.Equal(t, strconv.Itoa(concurrency.upload), os.Getenv(UploadConcurrencySetting))
+}
+
+func TestInitConfig_AutoConcurrencyKeepsExplicitSettings(t *testing.T) {
+	unsetConcurrencyEnv(t)
+	resetConfig(t)
+	t.Setenv(ConcurrencySetting, AutoConcurrency)
+	t.Setenv(UploadDiskConcurrencySetting, "3")
+	t.Setenv(UploadConcurrencySetting, "7")
+	viper.Reset()
+	ConfigureSettings(PG)
+
+	InitConfig()
+	assert.Equal(t, 3, viper.GetInt(UploadDiskConcurrencySetting))
+	assert.Equal(t, 7, viper.GetInt(UploadConcurrencySetting))
+}
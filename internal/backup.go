@@ -6,7 +6,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
-	"github.com/wal-g/wal-g/utility"
 )
 
 //region errors
@@ -54,7 +53,7 @@ func (backup *Backup) getStopSentinelPath() string {
 }
 
 func (backup *Backup) getMetadataPath() string {
-	return backup.Name + "/" + utility.MetadataFileName
+	return MetadataNameFromBackup(backup.Name)
 }
 
 // SentinelExists checks that the sentinel file of the specified backup exists.
@@ -27,18 +27,22 @@ func (err BackupNonExistenceError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+func (err BackupNonExistenceError) ExitCode() int {
+	return ExitCodeNotFound
+}
+
 // GetBackupToCommandFetcher returns function that copies all bytes from backup to cmd's stdin
 func GetBackupToCommandFetcher(cmd *exec.Cmd) func(folder storage.Folder, backup Backup) {
 	return func(folder storage.Folder, backup Backup) {
 		stdin, err := cmd.StdinPipe()
-		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
+		FatalfOnError("Failed to fetch backup: %v\n", err)
 		stderr := &bytes.Buffer{}
 		cmd.Stderr = stderr
 		err = cmd.Start()
-		tracelog.ErrorLogger.FatalfOnError("Failed to start restore command: %v\n", err)
+		FatalfOnError("Failed to start restore command: %v\n", err)
 
 		fetcher, err := GetBackupStreamFetcher(backup)
-		tracelog.ErrorLogger.FatalfOnError("Failed to detect backup format: %v\n", err)
+		FatalfOnError("Failed to detect backup format: %v\n", err)
 
 		err = fetcher(backup, stdin)
 
@@ -52,7 +56,7 @@ func GetBackupToCommandFetcher(cmd *exec.Cmd) func(folder storage.Folder, backup
 			}
 			err = cmdErr
 		}
-		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
+		FatalfOnError("Failed to fetch backup: %v\n", err)
 	}
 }
 
@@ -87,10 +91,11 @@ func HandleBackupFetch(folder storage.Folder,
 	targetBackupSelector BackupSelector,
 	fetcher func(folder storage.Folder, backup Backup)) {
 	backupName, err := targetBackupSelector.Select(folder)
-	tracelog.ErrorLogger.FatalOnError(err)
+	FatalOnError(err)
 	tracelog.DebugLogger.Printf("HandleBackupFetch(%s)\n", backupName)
+	AddLogFields(LogFields{"operation": "backup-fetch", "backup_name": backupName})
 	backup, err := GetBackupByName(backupName, utility.BaseBackupPath, folder)
-	tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
+	FatalfOnError("Failed to fetch backup: %v\n", err)
 
 	fetcher(folder, backup)
 }
@@ -13,10 +13,28 @@ type BackupFileDescription struct {
 	MTime         time.Time
 	CorruptBlocks *CorruptBlocksInfo `json:",omitempty"`
 	UpdatesCount  uint64
+	// Crc32c is the checksum of the file contents stored in the tar member, not set for the increments
+	Crc32c *uint32 `json:",omitempty"`
+	// Size is the size of the restored file, it is not recorded by the older versions
+	Size int64 `json:",omitempty"`
+	// DedupOf is the identical file whose tar member restores this file as well,
+	// set only for the small files deduplicated by the backup-push --dedup-small-files
+	DedupOf string `json:",omitempty"`
+	// Holes are the unallocated regions of the sparse file, which are restored as holes instead of the zeros
+	Holes []FileRegion `json:",omitempty"`
+	// Parts are the regions of the large file split by the backup-push --split-large-files,
+	// each of them is packed as a separate entry into its own tar member
+	Parts []FileRegion `json:",omitempty"`
+}
+
+// FileRegion is the byte range of the file
+type FileRegion struct {
+	Offset int64
+	Length int64
 }
 
 func NewBackupFileDescription(isIncremented, isSkipped bool, modTime time.Time) *BackupFileDescription {
-	return &BackupFileDescription{isIncremented, isSkipped, modTime, nil, 0}
+	return &BackupFileDescription{isIncremented, isSkipped, modTime, nil, 0, nil, 0, "", nil, nil}
 }
 
 type CorruptBlocksInfo struct {
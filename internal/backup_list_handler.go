@@ -25,16 +25,26 @@ type Logging struct {
 	ErrorLogger ErrorLogger
 }
 
+// ListSortedBackups returns the backups with the sentinels in the folder sorted from the oldest to the newest,
+// the list is empty if there are no backups
+func ListSortedBackups(folder storage.Folder) ([]BackupTime, error) {
+	backups, err := GetBackups(folder)
+	if _, ok := err.(NoBackupsFoundError); ok {
+		return []BackupTime{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	SortBackupTimeSlices(backups)
+	return backups, nil
+}
+
+// DefaultHandleBackupList prints the backups of the folder from the oldest to the newest
 func DefaultHandleBackupList(folder storage.Folder, pretty, json bool) {
 	getBackupsFunc := func() ([]BackupTime, error) {
-		res, err := GetBackups(folder)
-		if _, ok := err.(NoBackupsFoundError); ok {
-			err = nil
-		}
-		return res, err
+		return ListSortedBackups(folder)
 	}
 	writeBackupListFunc := func(backups []BackupTime) {
-		SortBackupTimeSlices(backups)
 		switch {
 		case json:
 			err := WriteAsJSON(backups, os.Stdout, pretty)
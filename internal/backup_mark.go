@@ -22,16 +22,20 @@ func NewBackupMarkHandler(metaInteractor GenericMetaInteractor, storageRootFolde
 }
 
 // MarkBackup marks a backup as permanent or impermanent
-func (h *BackupMarkHandler) MarkBackup(backupName string, toPermanent bool) {
+func (h *BackupMarkHandler) MarkBackup(backupName string, toPermanent bool) error {
 	tracelog.InfoLogger.Printf("Retrieving previous related backups to be marked: toPermanent=%t", toPermanent)
 	backupsToMark, err := h.GetBackupsToMark(backupName, toPermanent)
-
-	tracelog.ErrorLogger.FatalfOnError("Failed to get previous backups: %v", err)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get previous backups")
+	}
 	tracelog.InfoLogger.Printf("Retrieved backups to be marked, marking: %v", backupsToMark)
 	for _, backupName := range backupsToMark {
 		err = h.metaInteractor.SetIsPermanent(backupName, h.baseBackupFolder, toPermanent)
-		tracelog.ErrorLogger.FatalfOnError("Failed to mark backups: %v", err)
+		if err != nil {
+			return errors.Wrap(err, "Failed to mark backups")
+		}
 	}
+	return nil
 }
 
 // GetBackupsToMark retrieves all previous permanent or
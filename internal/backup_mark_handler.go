@@ -10,5 +10,6 @@ func HandleBackupMark(uploader *Uploader, backupName string, toPermanent bool, m
 	uploader.UploadingFolder = baseBackupFolder
 
 	markHandler := NewBackupMarkHandler(metaInteractor, folder)
-	markHandler.MarkBackup(backupName, toPermanent)
+	err := markHandler.MarkBackup(backupName, toPermanent)
+	FatalOnError(err)
 }
@@ -3,7 +3,9 @@ package internal
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/wal-g/wal-g/utility"
 
@@ -37,8 +39,9 @@ func (s LatestBackupSelector) Select(folder storage.Folder) (string, error) {
 
 // Select backup which has the provided user data
 type UserDataBackupSelector struct {
-	userData    interface{}
-	metaFetcher GenericMetaFetcher
+	userData     interface{}
+	metaFetcher  GenericMetaFetcher
+	partialMatch bool
 }
 
 func NewUserDataBackupSelector(userDataRaw string, metaFetcher GenericMetaFetcher) (UserDataBackupSelector, error) {
@@ -52,6 +55,17 @@ func NewUserDataBackupSelector(userDataRaw string, metaFetcher GenericMetaFetche
 	}, nil
 }
 
+// NewPartialUserDataBackupSelector creates the selector of the latest backup
+// whose user data contains all the keys of the provided JSON object with the same values
+func NewPartialUserDataBackupSelector(userDataRaw string, metaFetcher GenericMetaFetcher) (UserDataBackupSelector, error) {
+	selector, err := NewUserDataBackupSelector(userDataRaw, metaFetcher)
+	if err != nil {
+		return UserDataBackupSelector{}, err
+	}
+	selector.partialMatch = true
+	return selector, nil
+}
+
 func (s UserDataBackupSelector) Select(folder storage.Folder) (string, error) {
 	backupName, err := s.findBackupByUserData(s.userData, folder)
 	if err != nil {
@@ -60,10 +74,14 @@ func (s UserDataBackupSelector) Select(folder storage.Folder) (string, error) {
 	return backupName, nil
 }
 
-// Find backup with UserData exactly matching the provided one
+// Find backup with UserData exactly matching the provided one,
+// or the latest backup with UserData containing the provided one if the partial match is enabled
 func (s UserDataBackupSelector) findBackupByUserData(userData interface{}, folder storage.Folder) (string, error) {
 	foundBackups, err := searchInMetadata(
 		func(d GenericMetadata) bool {
+			if s.partialMatch {
+				return isUserDataSubset(userData, d.UserData)
+			}
 			return reflect.DeepEqual(userData, d.UserData)
 		}, folder, s.metaFetcher)
 	if err != nil {
@@ -74,6 +92,13 @@ func (s UserDataBackupSelector) findBackupByUserData(userData interface{}, folde
 		return "", errors.New("no backups found with specified user data")
 	}
 
+	if s.partialMatch {
+		sort.Slice(foundBackups, func(i, j int) bool {
+			return foundBackups[i].StartTime.Before(foundBackups[j].StartTime)
+		})
+		return foundBackups[len(foundBackups)-1].BackupName, nil
+	}
+
 	if len(foundBackups) > 1 {
 		var backupNames []string
 		for idx := range foundBackups {
@@ -86,6 +111,26 @@ func (s UserDataBackupSelector) findBackupByUserData(userData interface{}, folde
 	return foundBackups[0].BackupName, nil
 }
 
+// isUserDataSubset checks if every key of the expected JSON object is present in the actual one
+// with the matching value, the nested objects are matched in the same way
+func isUserDataSubset(expected, actual interface{}) bool {
+	expectedObject, ok := expected.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(expected, actual)
+	}
+	actualObject, ok := actual.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for key, expectedValue := range expectedObject {
+		actualValue, ok := actualObject[key]
+		if !ok || !isUserDataSubset(expectedValue, actualValue) {
+			return false
+		}
+	}
+	return true
+}
+
 // Search backups in storage using specified criteria
 func searchInMetadata(
 	criteria func(GenericMetadata) bool,
@@ -134,6 +179,64 @@ func (s BackupNameSelector) Select(folder storage.Folder) (string, error) {
 	return s.backupName, nil
 }
 
+type NoBackupBeforeTimeError struct {
+	error
+}
+
+func newNoBackupBeforeTimeError(targetTime time.Time, backups []GenericMetadata) NoBackupBeforeTimeError {
+	backupTimes := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		backupTimes = append(backupTimes,
+			fmt.Sprintf("%s (finished at %s)", backup.BackupName, backup.FinishTime.Format(time.RFC3339)))
+	}
+	return NoBackupBeforeTimeError{errors.Errorf("no backup finished before %s, available backups: [%s]",
+		targetTime.Format(time.RFC3339), strings.Join(backupTimes, ", "))}
+}
+
+func (err NoBackupBeforeTimeError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err NoBackupBeforeTimeError) ExitCode() int {
+	return ExitCodeNotFound
+}
+
+// BeforeTimeBackupSelector selects the newest backup finished before the target time,
+// so the point-in-time recovery to the target time can start from it
+type BeforeTimeBackupSelector struct {
+	targetTime  time.Time
+	metaFetcher GenericMetaFetcher
+}
+
+func NewBeforeTimeSelector(targetTime time.Time, metaFetcher GenericMetaFetcher) BeforeTimeBackupSelector {
+	return BeforeTimeBackupSelector{targetTime: targetTime, metaFetcher: metaFetcher}
+}
+
+func (s BeforeTimeBackupSelector) Select(folder storage.Folder) (string, error) {
+	backups, err := searchInMetadata(func(GenericMetadata) bool { return true }, folder, s.metaFetcher)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch the backups metadata")
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].FinishTime.Before(backups[j].FinishTime)
+	})
+
+	var selected *GenericMetadata
+	for i := range backups {
+		if backups[i].FinishTime.IsZero() || !backups[i].FinishTime.Before(s.targetTime) {
+			continue
+		}
+		selected = &backups[i]
+	}
+	if selected == nil {
+		return "", newNoBackupBeforeTimeError(s.targetTime, backups)
+	}
+
+	tracelog.InfoLogger.Printf("Backup %s finished at %s is the newest backup finished before %s\n",
+		selected.BackupName, selected.FinishTime.Format(time.RFC3339), s.targetTime.Format(time.RFC3339))
+	return selected.BackupName, nil
+}
+
 func NewTargetBackupSelector(targetUserData, targetName string, metaFetcher GenericMetaFetcher) (BackupSelector, error) {
 	var err error
 	switch {
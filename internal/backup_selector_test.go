@@ -0,0 +1,130 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type testUserDataMetaFetcher map[string]internal.GenericMetadata
+
+func (fetcher testUserDataMetaFetcher) Fetch(backupName string, _ storage.Folder) (internal.GenericMetadata, error) {
+	return fetcher[backupName], nil
+}
+
+func prepareUserDataBackups(t *testing.T) (storage.Folder, testUserDataMetaFetcher) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	fetcher := testUserDataMetaFetcher{}
+	userDatas := []struct {
+		backupName  string
+		userDataRaw string
+	}{
+		{"base_000000010000000000000001", `{"env":"prod","region":"us"}`},
+		{"base_000000010000000000000002", `{"env":"prod","region":"eu","tags":{"tier":"gold","zone":"a"}}`},
+		{"base_000000010000000000000003", `{"env":"dev","region":"us"}`},
+	}
+	for i, backup := range userDatas {
+		backupName, userDataRaw := backup.backupName, backup.userDataRaw
+		err := folder.PutObject(utility.BaseBackupPath+backupName+utility.SentinelSuffix, strings.NewReader("{}"))
+		assert.NoError(t, err)
+		userData, err := internal.UnmarshalSentinelUserData(userDataRaw)
+		assert.NoError(t, err)
+		fetcher[backupName] = internal.GenericMetadata{
+			BackupName: backupName,
+			StartTime:  time.Date(2022, 1, i+1, 0, 0, 0, 0, time.UTC),
+			UserData:   userData,
+		}
+	}
+	return folder, fetcher
+}
+
+func TestUserDataBackupSelector_ExactMatch(t *testing.T) {
+	folder, fetcher := prepareUserDataBackups(t)
+
+	selector, err := internal.NewUserDataBackupSelector(`{"env":"dev","region":"us"}`, fetcher)
+	assert.NoError(t, err)
+	backupName, err := selector.Select(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000003", backupName)
+
+	selector, err = internal.NewUserDataBackupSelector(`{"env":"prod"}`, fetcher)
+	assert.NoError(t, err)
+	_, err = selector.Select(folder)
+	assert.Error(t, err)
+}
+
+func TestUserDataBackupSelector_PartialMatch(t *testing.T) {
+	folder, fetcher := prepareUserDataBackups(t)
+
+	selector, err := internal.NewPartialUserDataBackupSelector(`{"env":"prod"}`, fetcher)
+	assert.NoError(t, err)
+	backupName, err := selector.Select(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000002", backupName)
+
+	selector, err = internal.NewPartialUserDataBackupSelector(`{"region":"us"}`, fetcher)
+	assert.NoError(t, err)
+	backupName, err = selector.Select(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000003", backupName)
+
+	selector, err = internal.NewPartialUserDataBackupSelector(`{"tags":{"tier":"gold"}}`, fetcher)
+	assert.NoError(t, err)
+	backupName, err = selector.Select(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000002", backupName)
+
+	selector, err = internal.NewPartialUserDataBackupSelector(`{"env":"prod","region":"asia"}`, fetcher)
+	assert.NoError(t, err)
+	_, err = selector.Select(folder)
+	assert.Error(t, err)
+}
+
+func prepareFinishedBackups(t *testing.T) (storage.Folder, testUserDataMetaFetcher) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	fetcher := testUserDataMetaFetcher{}
+	for i, backupName := range []string{
+		"base_000000010000000000000001",
+		"base_000000010000000000000003",
+		"base_000000010000000000000002",
+	} {
+		err := folder.PutObject(utility.BaseBackupPath+backupName+utility.SentinelSuffix, strings.NewReader("{}"))
+		assert.NoError(t, err)
+		fetcher[backupName] = internal.GenericMetadata{
+			BackupName: backupName,
+			FinishTime: time.Date(2022, 1, i+1, 12, 0, 0, 0, time.UTC),
+		}
+	}
+	return folder, fetcher
+}
+
+func TestBeforeTimeSelector(t *testing.T) {
+	folder, fetcher := prepareFinishedBackups(t)
+
+	backupName, err := internal.NewBeforeTimeSelector(time.Date(2022, 1, 2, 13, 0, 0, 0, time.UTC), fetcher).Select(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000003", backupName)
+
+	backupName, err = internal.NewBeforeTimeSelector(time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC), fetcher).Select(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000002", backupName)
+
+	// the backup finished exactly at the target time is not selected
+	backupName, err = internal.NewBeforeTimeSelector(time.Date(2022, 1, 2, 12, 0, 0, 0, time.UTC), fetcher).Select(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000001", backupName)
+}
+
+func TestBeforeTimeSelector_NoBackupBeforeTarget(t *testing.T) {
+	folder, fetcher := prepareFinishedBackups(t)
+
+	_, err := internal.NewBeforeTimeSelector(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), fetcher).Select(folder)
+	assert.IsType(t, internal.NoBackupBeforeTimeError{}, err)
+	assert.Contains(t, err.Error(), "base_000000010000000000000001 (finished at 2022-01-01T12:00:00Z)")
+}
@@ -39,6 +39,10 @@ func (err NoBackupsFoundError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+func (err NoBackupsFoundError) ExitCode() int {
+	return ExitCodeNotFound
+}
+
 func GetLatestBackupName(folder storage.Folder) (string, error) {
 	backupTimes, err := GetBackups(folder)
 	SortBackupTimeSlices(backupTimes)
@@ -56,7 +60,7 @@ func GetBackupSentinelObjects(folder storage.Folder) ([]storage.Object, error) {
 	}
 	sentinelObjects := make([]storage.Object, 0, len(objects))
 	for _, object := range objects {
-		if !strings.HasSuffix(object.GetName(), utility.SentinelSuffix) {
+		if !strings.HasSuffix(object.GetName(), SentinelSuffix()) {
 			continue
 		}
 		sentinelObjects = append(sentinelObjects, object)
@@ -88,7 +92,7 @@ func GetBackupsAndGarbage(folder storage.Folder) (backups []BackupTime, garbage
 	}
 
 	sortTimes := GetBackupTimeSlices(backupObjects)
-	garbage = GetGarbageFromPrefix(subFolders, sortTimes)
+	garbage = GetGarbageFromPrefix(subFolders, getAnyNamespaceBackupTimeSlices(backupObjects))
 
 	return sortTimes, garbage, nil
 }
@@ -97,7 +101,7 @@ func GetBackupTimeSlices(backups []storage.Object) []BackupTime {
 	backupTimes := make([]BackupTime, 0)
 	for _, object := range backups {
 		key := object.GetName()
-		if !strings.HasSuffix(key, utility.SentinelSuffix) {
+		if !strings.HasSuffix(key, SentinelSuffix()) {
 			continue
 		}
 		time := object.GetLastModified()
@@ -107,6 +111,19 @@ func GetBackupTimeSlices(backups []storage.Object) []BackupTime {
 	return backupTimes
 }
 
+// getAnyNamespaceBackupTimeSlices lists the backups of all the sentinel namespaces
+func getAnyNamespaceBackupTimeSlices(backups []storage.Object) []BackupTime {
+	backupTimes := make([]BackupTime, 0)
+	for _, object := range backups {
+		key := object.GetName()
+		if IsSentinelOfAnyNamespace(key) {
+			backupTimes = append(backupTimes, BackupTime{utility.StripRightmostBackupName(key), object.GetLastModified(),
+				utility.StripWalFileName(key)})
+		}
+	}
+	return backupTimes
+}
+
 func SortBackupTimeSlices(backupTimes []BackupTime) {
 	sort.Slice(backupTimes, func(i, j int) bool {
 		return backupTimes[i].Time.Before(backupTimes[j].Time)
@@ -130,11 +147,11 @@ func GetGarbageFromPrefix(folders []storage.Folder, nonGarbage []BackupTime) []s
 }
 
 func SentinelNameFromBackup(backupName string) string {
-	return backupName + utility.SentinelSuffix
+	return backupName + SentinelSuffix()
 }
 
 func MetadataNameFromBackup(backupName string) string {
-	return backupName + "/" + utility.MetadataFileName
+	return backupName + "/" + NamespacedName(utility.MetadataFileName)
 }
 
 func StreamMetadataNameFromBackup(backupName string) string {
@@ -13,6 +13,8 @@ type BundleFiles interface {
 	AddFileDescription(name string, backupFileDescription BackupFileDescription)
 	AddFileWithCorruptBlocks(tarHeader *tar.Header, fileInfo os.FileInfo, isIncremented bool,
 		corruptedBlocks []uint32, storeAllBlocks bool)
+	AddFileChecksum(name string, crc32c uint32)
+	AddFileHoles(name string, holes []FileRegion)
 	GetUnderlyingMap() *sync.Map
 }
 
@@ -22,12 +24,13 @@ type RegularBundleFiles struct {
 
 func (files *RegularBundleFiles) AddSkippedFile(tarHeader *tar.Header, fileInfo os.FileInfo) {
 	files.AddFileDescription(tarHeader.Name,
-		BackupFileDescription{IsSkipped: true, IsIncremented: false, MTime: fileInfo.ModTime()})
+		BackupFileDescription{IsSkipped: true, IsIncremented: false, MTime: fileInfo.ModTime(), Size: fileInfo.Size()})
 }
 
 func (files *RegularBundleFiles) AddFile(tarHeader *tar.Header, fileInfo os.FileInfo, isIncremented bool) {
 	files.AddFileDescription(tarHeader.Name,
-		BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime()})
+		BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime(),
+			Size: fileInfo.Size()})
 }
 
 func (files *RegularBundleFiles) AddFileDescription(name string, backupFileDescription BackupFileDescription) {
@@ -36,11 +39,20 @@ func (files *RegularBundleFiles) AddFileDescription(name string, backupFileDescr
 
 func (files *RegularBundleFiles) AddFileWithCorruptBlocks(tarHeader *tar.Header, fileInfo os.FileInfo,
 	isIncremented bool, corruptedBlocks []uint32, storeAllBlocks bool) {
-	fileDescription := BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime()}
+	fileDescription := BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime(),
+		Size: fileInfo.Size()}
 	fileDescription.SetCorruptBlocks(corruptedBlocks, storeAllBlocks)
 	files.AddFileDescription(tarHeader.Name, fileDescription)
 }
 
+func (files *RegularBundleFiles) AddFileChecksum(name string, crc32c uint32) {
+	SetFileChecksum(&files.Map, name, crc32c)
+}
+
+func (files *RegularBundleFiles) AddFileHoles(name string, holes []FileRegion) {
+	SetFileHoles(&files.Map, name, holes)
+}
+
 func (files *RegularBundleFiles) GetUnderlyingMap() *sync.Map {
 	return &files.Map
 }
@@ -61,6 +73,66 @@ func (files *NopBundleFiles) AddFileWithCorruptBlocks(tarHeader *tar.Header, fil
 	isIncremented bool, corruptedBlocks []uint32, storeAllBlocks bool) {
 }
 
+func (files *NopBundleFiles) AddFileChecksum(name string, crc32c uint32) {
+}
+
+func (files *NopBundleFiles) AddFileHoles(name string, holes []FileRegion) {
+}
+
 func (files *NopBundleFiles) GetUnderlyingMap() *sync.Map {
 	return &sync.Map{}
 }
+
+// MinimalBundleFiles tracks only the sizes of the files, the rest of the files metadata is not tracked
+type MinimalBundleFiles struct {
+	sync.Map
+}
+
+func (files *MinimalBundleFiles) AddSkippedFile(tarHeader *tar.Header, fileInfo os.FileInfo) {
+	files.AddFileDescription(tarHeader.Name, BackupFileDescription{Size: fileInfo.Size()})
+}
+
+func (files *MinimalBundleFiles) AddFile(tarHeader *tar.Header, fileInfo os.FileInfo, isIncremented bool) {
+	files.AddFileDescription(tarHeader.Name, BackupFileDescription{Size: fileInfo.Size()})
+}
+
+func (files *MinimalBundleFiles) AddFileDescription(name string, backupFileDescription BackupFileDescription) {
+	files.Store(name, BackupFileDescription{Size: backupFileDescription.Size})
+}
+
+func (files *MinimalBundleFiles) AddFileWithCorruptBlocks(tarHeader *tar.Header, fileInfo os.FileInfo,
+	isIncremented bool, corruptedBlocks []uint32, storeAllBlocks bool) {
+	files.AddFile(tarHeader, fileInfo, isIncremented)
+}
+
+func (files *MinimalBundleFiles) AddFileChecksum(name string, crc32c uint32) {
+}
+
+func (files *MinimalBundleFiles) AddFileHoles(name string, holes []FileRegion) {
+}
+
+func (files *MinimalBundleFiles) GetUnderlyingMap() *sync.Map {
+	return &files.Map
+}
+
+// SetFileChecksum sets the checksum of the file which is already added to the files map
+func SetFileChecksum(files *sync.Map, name string, crc32c uint32) {
+	description, ok := files.Load(name)
+	if !ok {
+		return
+	}
+	fileDescription := description.(BackupFileDescription)
+	fileDescription.Crc32c = &crc32c
+	files.Store(name, fileDescription)
+}
+
+// SetFileHoles sets the holes of the sparse file which is already added to the files map
+func SetFileHoles(files *sync.Map, name string, holes []FileRegion) {
+	description, ok := files.Load(name)
+	if !ok {
+		return
+	}
+	fileDescription := description.(BackupFileDescription)
+	fileDescription.Holes = holes
+	files.Store(name, fileDescription)
+}
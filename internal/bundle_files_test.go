@@ -0,0 +1,34 @@
+package internal_test
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+)
+
+func TestMinimalBundleFiles_TracksOnlySizes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1259")
+	assert.NoError(t, os.WriteFile(path, make([]byte, 8192), 0600))
+	fileInfo, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	files := &internal.MinimalBundleFiles{}
+	files.AddFileWithCorruptBlocks(&tar.Header{Name: "/base/1/1259"}, fileInfo, true, []uint32{1}, false)
+	files.AddFileChecksum("/base/1/1259", 42)
+	files.AddFileDescription("/base/1/1249", internal.BackupFileDescription{IsSkipped: true, MTime: time.Now(), Size: 100})
+
+	descriptions := make(map[string]internal.BackupFileDescription)
+	files.GetUnderlyingMap().Range(func(k, v interface{}) bool {
+		descriptions[k.(string)] = v.(internal.BackupFileDescription)
+		return true
+	})
+	assert.Equal(t, map[string]internal.BackupFileDescription{
+		"/base/1/1259": {Size: 8192},
+		"/base/1/1249": {Size: 100},
+	}, descriptions)
+}
@@ -26,7 +26,7 @@ func (err CompressAndEncryptError) Error() string {
 }
 
 // CompressAndEncrypt compresses input to a pipe reader. Output must be used or
-// pipe will block.
+// pipe will block. The failure to set up the encryption is returned by the reads of the output.
 func CompressAndEncrypt(source io.Reader, compressor compression.Compressor, crypter crypto.Crypter) io.Reader {
 	compressedReader, dstWriter := io.Pipe()
 
@@ -36,7 +36,8 @@ func CompressAndEncrypt(source io.Reader, compressor compression.Compressor, cry
 		writeCloser, err = crypter.Encrypt(dstWriter)
 
 		if err != nil {
-			panic(err)
+			_ = dstWriter.CloseWithError(CompressAndEncryptError{errors.Wrap(err, "CompressAndEncrypt: encryption setup failed")})
+			return compressedReader
 		}
 	}
 
@@ -10,14 +10,20 @@ import (
 )
 
 const (
-	AlgorithmName = "brotli"
-	FileExtension = "br"
+	AlgorithmName  = "brotli"
+	FileExtension  = "br"
+	MinQuality     = 0
+	MaxQuality     = 11
+	DefaultQuality = 3
 )
 
-type Compressor struct{}
+type Compressor struct {
+	// Quality trades the speed for the compression ratio, from MinQuality to MaxQuality
+	Quality int
+}
 
 func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
-	return cbrotli.NewWriter(writer, cbrotli.WriterOptions{Quality: 3})
+	return cbrotli.NewWriter(writer, cbrotli.WriterOptions{Quality: compressor.Quality})
 }
 
 func (compressor Compressor) FileExtension() string {
@@ -7,6 +7,6 @@ import "github.com/wal-g/wal-g/internal/compression/brotli"
 
 func init() {
 	Decompressors = append(Decompressors, brotli.Decompressor{})
-	Compressors[brotli.AlgorithmName] = brotli.Compressor{}
+	Compressors[brotli.AlgorithmName] = brotli.Compressor{Quality: brotli.DefaultQuality}
 	CompressingAlgorithms = append(CompressingAlgorithms, brotli.AlgorithmName)
 }
@@ -0,0 +1,22 @@
+//go:build brotli && !windows
+// +build brotli,!windows
+
+package compression
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/wal-g/wal-g/internal/compression/brotli"
+)
+
+func TestBrotliQualities(t *testing.T) {
+	const DataSize = 1 << 20
+	randomReader := io.LimitReader(NewBiasedRandomReader(), DataSize)
+	var testData bytes.Buffer
+	io.Copy(&testData, randomReader)
+	for _, quality := range []int{brotli.MinQuality, brotli.DefaultQuality, brotli.MaxQuality} {
+		testCompressor(brotli.Compressor{Quality: quality}, testData, t)
+	}
+}
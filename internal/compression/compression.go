@@ -14,6 +14,36 @@ type Decompressor interface {
 	FileExtension() string
 }
 
+// MetadataDecompressor is the Decompressor of the data which depends on the metadata the object is stored with,
+// e.g. on the dictionary the data is compressed with
+type MetadataDecompressor interface {
+	Decompressor
+	DecompressWithMetadata(src io.Reader, metadata map[string]string) (io.ReadCloser, error)
+}
+
+// objectDecompressor decompresses the object stored with the metadata
+type objectDecompressor struct {
+	MetadataDecompressor
+	metadata map[string]string
+}
+
+func (decompressor objectDecompressor) Decompress(src io.Reader) (io.ReadCloser, error) {
+	return decompressor.DecompressWithMetadata(src, decompressor.metadata)
+}
+
+// WithMetadata returns the Decompressor of the object stored with the metadata
+func WithMetadata(decompressor Decompressor, metadata map[string]string) Decompressor {
+	metadataDecompressor, ok := decompressor.(MetadataDecompressor)
+	if !ok || len(metadata) == 0 {
+		return decompressor
+	}
+	return objectDecompressor{MetadataDecompressor: metadataDecompressor, metadata: metadata}
+}
+
+// KnownFileExtensions are the extensions of all the compression methods the files may be stored with,
+// including the ones which are not built in, so such a file is reported as unsupported instead of missing
+var KnownFileExtensions = []string{"lz4", "lzma", "zst", "br", "lzo", "gz"}
+
 func GetDecompressorByCompressor(compressor Compressor) Decompressor {
 	return FindDecompressor(compressor.FileExtension())
 }
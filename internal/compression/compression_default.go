@@ -10,11 +10,12 @@ import (
 	"github.com/wal-g/wal-g/internal/compression/zstd"
 )
 
-var CompressingAlgorithms = []string{lz4.AlgorithmName, lzma.AlgorithmName}
+var CompressingAlgorithms = []string{lz4.AlgorithmName, lzma.AlgorithmName, zstd.AlgorithmName}
 
 var Compressors = map[string]Compressor{
 	lz4.AlgorithmName:  lz4.Compressor{},
 	lzma.AlgorithmName: lzma.Compressor{},
+	zstd.AlgorithmName: zstd.Compressor{Level: zstd.DefaultLevel},
 }
 
 var Decompressors = []Decompressor{
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
 	"github.com/wal-g/wal-g/utility"
 )
 
@@ -62,3 +63,43 @@ func TestBigDataCompression(t *testing.T) {
 		testCompressor(compressor, testData, t)
 	}
 }
+
+func TestLz4BlockSizes(t *testing.T) {
+	const DataSize = 5 << 20
+	randomReader := io.LimitReader(NewBiasedRandomReader(), DataSize)
+	var testData bytes.Buffer
+	io.Copy(&testData, randomReader)
+	for _, blockSize := range lz4.BlockSizes {
+		testCompressor(lz4.Compressor{BlockSize: blockSize}, testData, t)
+	}
+}
+
+func TestLz4InvalidBlockSize(t *testing.T) {
+	var compressed bytes.Buffer
+	compressingWriter := lz4.Compressor{BlockSize: 1000}.NewWriter(&compressed)
+	_, err := compressingWriter.Write([]byte("data"))
+	assert.Error(t, err)
+}
+
+// BenchmarkCompressors reports the compression time and the compressed size ratio of every algorithm,
+// run with -tags brotli to include brotli
+func BenchmarkCompressors(b *testing.B) {
+	const DataSize = 8 << 20
+	var testData bytes.Buffer
+	_, _ = io.Copy(&testData, io.LimitReader(NewBiasedRandomReader(), DataSize))
+	for _, compressingAlgorithm := range CompressingAlgorithms {
+		compressor := Compressors[compressingAlgorithm]
+		b.Run(compressingAlgorithm, func(b *testing.B) {
+			var compressed bytes.Buffer
+			b.SetBytes(DataSize)
+			for i := 0; i < b.N; i++ {
+				compressed.Reset()
+				compressingWriter := compressor.NewWriter(&compressed)
+				_, err := compressingWriter.Write(testData.Bytes())
+				assert.NoError(b, err)
+				assert.NoError(b, compressingWriter.Close())
+			}
+			b.ReportMetric(float64(compressed.Len())/DataSize, "ratio")
+		})
+	}
+}
@@ -9,12 +9,26 @@ import (
 const (
 	AlgorithmName = "lz4"
 	FileExtension = "lz4"
+
+	DefaultBlockSize = int(lz4.Block4Mb)
 )
 
-type Compressor struct{}
+// BlockSizes are the block sizes allowed by the LZ4 frame format
+var BlockSizes = []int{int(lz4.Block64Kb), int(lz4.Block256Kb), int(lz4.Block1Mb), int(lz4.Block4Mb)}
+
+type Compressor struct {
+	// BlockSize is the maximum size of the compressed blocks, DefaultBlockSize is used if it's zero.
+	// The block size is written to the frame header, so the decompressor doesn't need it.
+	BlockSize int
+}
 
 func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
-	return lz4.NewWriter(writer)
+	lz4Writer := lz4.NewWriter(writer)
+	if compressor.BlockSize != 0 {
+		// the invalid block size puts the writer into the error state, so it fails the first write
+		_ = lz4Writer.Apply(lz4.BlockSizeOption(lz4.BlockSize(compressor.BlockSize)))
+	}
+	return lz4Writer
 }
 
 func (compressor Compressor) FileExtension() string {
@@ -9,12 +9,22 @@ import (
 const (
 	AlgorithmName = "zstd"
 	FileExtension = "zst"
+
+	DefaultLevel = 3
+	MinLevel     = zstd.BestSpeed
+	MaxLevel     = 22
 )
 
-type Compressor struct{}
+type Compressor struct {
+	Level int
+}
 
 func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
-	return zstd.NewWriterLevel(writer, 3)
+	level := compressor.Level
+	if level == 0 {
+		level = DefaultLevel
+	}
+	return zstd.NewWriterLevel(writer, level)
 }
 
 func (compressor Compressor) FileExtension() string {
@@ -4,6 +4,7 @@ import (
 	"io"
 
 	"github.com/DataDog/zstd"
+	"github.com/pkg/errors"
 	"github.com/wal-g/wal-g/internal/compression/computils"
 )
 
@@ -13,6 +14,27 @@ func (decompressor Decompressor) Decompress(src io.Reader) (io.ReadCloser, error
 	return zstd.NewReader(computils.NewUntilEOFReader(src)), nil
 }
 
+// DecompressWithMetadata decompresses the object with the dictionary whose id it is stored with
+func (decompressor Decompressor) DecompressWithMetadata(src io.Reader, metadata map[string]string) (io.ReadCloser, error) {
+	id, ok, err := parseDictionaryMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return decompressor.Decompress(src)
+	}
+
+	if dictionaryLoader == nil {
+		return nil, errors.Errorf("the object is compressed with the zstd dictionary %08x, "+
+			"but the dictionaries are not supported here", id)
+	}
+	dictionary, err := dictionaryLoader(id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load the zstd dictionary %08x", id)
+	}
+	return zstd.NewReaderDict(computils.NewUntilEOFReader(src), dictionary), nil
+}
+
 func (decompressor Decompressor) FileExtension() string {
 	return FileExtension
 }
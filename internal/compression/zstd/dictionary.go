@@ -0,0 +1,118 @@
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/DataDog/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DictionaryMetadataKey is the key of the object metadata which keeps the id of the dictionary
+	// the object is compressed with
+	DictionaryMetadataKey = "walg-zstd-dictionary"
+
+	// dictionaryBlockSize is the size of the sample blocks the dictionary is built of
+	dictionaryBlockSize = 64
+	// DefaultDictionarySize is the zstd default dictionary size
+	DefaultDictionarySize = 110 << 10
+)
+
+// DictionaryLoader returns the dictionary by the id from the object metadata
+type DictionaryLoader func(id uint32) ([]byte, error)
+
+var dictionaryLoader DictionaryLoader
+
+// SetDictionaryLoader makes the Decompressor load the dictionaries of the objects compressed with them
+func SetDictionaryLoader(loader DictionaryLoader) {
+	dictionaryLoader = loader
+}
+
+// DictionaryID identifies the dictionary by its content
+func DictionaryID(dictionary []byte) uint32 {
+	return crc32.ChecksumIEEE(dictionary)
+}
+
+// DictionaryMetadata is the metadata of the object compressed with the dictionary
+func DictionaryMetadata(dictionary []byte) map[string]string {
+	return map[string]string{DictionaryMetadataKey: fmt.Sprintf("%08x", DictionaryID(dictionary))}
+}
+
+// parseDictionaryMetadata returns the id of the dictionary the object is compressed with, false if it is compressed without it
+func parseDictionaryMetadata(metadata map[string]string) (uint32, bool, error) {
+	value, ok := metadata[DictionaryMetadataKey]
+	if !ok {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "invalid zstd dictionary id '%s' in the object metadata", value)
+	}
+	return uint32(id), true, nil
+}
+
+// TrainDictionary builds the raw content dictionary of up to maxSize bytes from the samples. The samples are split
+// into blocks, the blocks repeated across the samples are put into the dictionary, the most frequent ones
+// at its end, where zstd matches them with the shortest offsets.
+func TrainDictionary(samples [][]byte, maxSize int) ([]byte, error) {
+	blockCounts := make(map[string]int)
+	for _, sample := range samples {
+		for offset := 0; offset+dictionaryBlockSize <= len(sample); offset += dictionaryBlockSize {
+			block := sample[offset : offset+dictionaryBlockSize]
+			// zstd encodes the runs of the same byte without the dictionary
+			if bytes.Count(block, block[:1]) == dictionaryBlockSize {
+				continue
+			}
+			blockCounts[string(block)]++
+		}
+	}
+
+	blocks := make([]string, 0, len(blockCounts))
+	for block, count := range blockCounts {
+		if count > 1 {
+			blocks = append(blocks, block)
+		}
+	}
+	if len(blocks) == 0 {
+		return nil, errors.New("no repeated content found in the dictionary samples")
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		if blockCounts[blocks[i]] != blockCounts[blocks[j]] {
+			return blockCounts[blocks[i]] > blockCounts[blocks[j]]
+		}
+		return blocks[i] < blocks[j]
+	})
+	if len(blocks) > maxSize/dictionaryBlockSize {
+		blocks = blocks[:maxSize/dictionaryBlockSize]
+	}
+
+	dictionary := make([]byte, 0, len(blocks)*dictionaryBlockSize)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		dictionary = append(dictionary, blocks[i]...)
+	}
+	return dictionary, nil
+}
+
+// DictionaryCompressor compresses the data with the dictionary. The objects compressed with it must be stored
+// with the DictionaryMetadata, so the Decompressor loads the dictionary by itself.
+type DictionaryCompressor struct {
+	Level      int
+	Dictionary []byte
+}
+
+func (compressor DictionaryCompressor) NewWriter(writer io.Writer) io.WriteCloser {
+	level := compressor.Level
+	if level == 0 {
+		level = DefaultLevel
+	}
+	return zstd.NewWriterLevelDict(writer, level, compressor.Dictionary)
+}
+
+func (compressor DictionaryCompressor) FileExtension() string {
+	return FileExtension
+}
@@ -0,0 +1,103 @@
+package zstd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
+)
+
+func makeDictionarySample(seed int) []byte {
+	var sample bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sample, "%-64s", fmt.Sprintf("record %d of the shared structure", i%50))
+		fmt.Fprintf(&sample, "%-64s", fmt.Sprintf("unique record %d of sample %d", i, seed))
+	}
+	return sample.Bytes()
+}
+
+func compressWithDictionary(t *testing.T, dictionary, data []byte) []byte {
+	var compressed bytes.Buffer
+	writer := zstd.DictionaryCompressor{Dictionary: dictionary}.NewWriter(&compressed)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return compressed.Bytes()
+}
+
+func TestTrainDictionary(t *testing.T) {
+	dictionary, err := zstd.TrainDictionary([][]byte{makeDictionarySample(1), makeDictionarySample(2)}, 1024)
+	require.NoError(t, err)
+	assert.Len(t, dictionary, 1024)
+	assert.Contains(t, string(dictionary), "of the shared structure")
+	assert.NotContains(t, string(dictionary), "unique record")
+
+	_, err = zstd.TrainDictionary([][]byte{makeDictionarySample(1)[64:128], make([]byte, 1024)}, 1024)
+	assert.Error(t, err)
+}
+
+func TestDictionaryCompressor(t *testing.T) {
+	dictionary, err := zstd.TrainDictionary([][]byte{makeDictionarySample(1), makeDictionarySample(2)}, 4096)
+	require.NoError(t, err)
+	data := makeDictionarySample(3)
+	compressed := compressWithDictionary(t, dictionary, data)
+
+	var loadedID uint32
+	zstd.SetDictionaryLoader(func(id uint32) ([]byte, error) {
+		loadedID = id
+		return dictionary, nil
+	})
+	defer zstd.SetDictionaryLoader(nil)
+	metadata := zstd.DictionaryMetadata(dictionary)
+	reader, err := zstd.Decompressor{}.DecompressWithMetadata(bytes.NewReader(compressed), metadata)
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, data, decompressed)
+	assert.Equal(t, zstd.DictionaryID(dictionary), loadedID)
+
+	zstd.SetDictionaryLoader(func(id uint32) ([]byte, error) {
+		return nil, errors.New("not found")
+	})
+	_, err = zstd.Decompressor{}.DecompressWithMetadata(bytes.NewReader(compressed), metadata)
+	assert.Error(t, err)
+	_, err = zstd.Decompressor{}.DecompressWithMetadata(bytes.NewReader(compressed),
+		map[string]string{zstd.DictionaryMetadataKey: "not an id"})
+	assert.Error(t, err)
+}
+
+func TestDecompressWithMetadata_WithoutDictionary(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := zstd.Compressor{}.NewWriter(&compressed)
+	_, err := writer.Write([]byte("data"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	// the object stored without the dictionary id is decompressed without loading any dictionary
+	reader, err := zstd.Decompressor{}.DecompressWithMetadata(&compressed, map[string]string{"other": "value"})
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(decompressed))
+}
+
+func TestDictionaryCompressor_EmptyData(t *testing.T) {
+	dictionary := makeDictionarySample(1)[:1024]
+	compressed := compressWithDictionary(t, dictionary, nil)
+
+	zstd.SetDictionaryLoader(func(id uint32) ([]byte, error) {
+		return dictionary, nil
+	})
+	defer zstd.SetDictionaryLoader(nil)
+	reader, err := zstd.Decompressor{}.DecompressWithMetadata(bytes.NewReader(compressed),
+		zstd.DictionaryMetadata(dictionary))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Empty(t, decompressed)
+}
@@ -167,6 +167,24 @@ func TestCompressAndEncryptError(t *testing.T) {
 	}
 }
 
+// failingCrypter fails to set up the encryption
+type failingCrypter struct{}
+
+func (crypter failingCrypter) Name() string { return "failing" }
+
+func (crypter failingCrypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	return nil, errors.New("the key is not available")
+}
+
+func (crypter failingCrypter) Decrypt(reader io.Reader) (io.Reader, error) { return reader, nil }
+
+func TestCompressAndEncryptEncryptionError(t *testing.T) {
+	compressed := internal.CompressAndEncrypt(bytes.NewBufferString("content"), GetLz4Compressor(), failingCrypter{})
+
+	_, err := io.ReadAll(compressed)
+	assert.IsType(t, internal.CompressAndEncryptError{}, err)
+}
+
 func TestCompressAndEncryptWithNoCompression(t *testing.T) {
 	for _, testCase := range tests {
 		in := &testtools.BufCloser{Buffer: bytes.NewBufferString(testCase.testString), Err: false}
@@ -29,35 +29,66 @@ const (
 	DownloadConcurrencySetting   = "WALG_DOWNLOAD_CONCURRENCY"
 	UploadConcurrencySetting     = "WALG_UPLOAD_CONCURRENCY"
 	UploadDiskConcurrencySetting = "WALG_UPLOAD_DISK_CONCURRENCY"
+	ConcurrencySetting           = "WALG_CONCURRENCY"
 	UploadQueueSetting           = "WALG_UPLOAD_QUEUE"
+	UploadQueueLogInterval       = "WALG_UPLOAD_QUEUE_LOG_INTERVAL"
 	SentinelUserDataSetting      = "WALG_SENTINEL_USER_DATA"
 	PreventWalOverwriteSetting   = "WALG_PREVENT_WAL_OVERWRITE"
 	UploadWalMetadata            = "WALG_UPLOAD_WAL_METADATA"
+	FailoverStoragesSetting      = "WALG_FAILOVER_STORAGES"
+	StorageMaxRetriesSetting     = "WALG_STORAGE_MAX_RETRIES"
+	StorageRetryWaitSetting      = "WALG_STORAGE_RETRY_WAIT"
 	DeltaMaxStepsSetting         = "WALG_DELTA_MAX_STEPS"
 	DeltaOriginSetting           = "WALG_DELTA_ORIGIN"
 	CompressionMethodSetting     = "WALG_COMPRESSION_METHOD"
+	ZstdLevelSetting             = "WALG_ZSTD_LEVEL"
+	Lz4BlockSizeSetting          = "WALG_LZ4_BLOCK_SIZE"
+	BrotliQualitySetting         = "WALG_BROTLI_QUALITY"
 	StoragePrefixSetting         = "WALG_STORAGE_PREFIX"
+	ObjectPrefixSetting          = "WALG_OBJECT_PREFIX"
 	DiskRateLimitSetting         = "WALG_DISK_RATE_LIMIT"
 	NetworkRateLimitSetting      = "WALG_NETWORK_RATE_LIMIT"
+	UploadRateLimitSetting       = "WALG_UPLOAD_RATE_LIMIT"
 	UseWalDeltaSetting           = "WALG_USE_WAL_DELTA"
 	UseReverseUnpackSetting      = "WALG_USE_REVERSE_UNPACK"
 	SkipRedundantTarsSetting     = "WALG_SKIP_REDUNDANT_TARS"
 	VerifyPageChecksumsSetting   = "WALG_VERIFY_PAGE_CHECKSUMS"
 	StoreAllCorruptBlocksSetting = "WALG_STORE_ALL_CORRUPT_BLOCKS"
+	VerifyConcurrencySetting     = "WALG_VERIFY_CONCURRENCY"
 	UseRatingComposerSetting     = "WALG_USE_RATING_COMPOSER"
 	UseCopyComposerSetting       = "WALG_USE_COPY_COMPOSER"
+	UseTablespaceComposerSetting = "WALG_USE_TABLESPACE_COMPOSER"
+	ReuseRatingStatsSetting      = "WALG_REUSE_RATING_STATS"
+	DumpRatingSetting            = "WALG_DUMP_RATING"
 	WithoutFilesMetadataSetting  = "WALG_WITHOUT_FILES_METADATA"
+	MinimalFilesMetadataSetting  = "WALG_MINIMAL_FILES_METADATA"
+	DedupSmallFilesSetting       = "WALG_DEDUP_SMALL_FILES"
+	DedupMaxFileSizeSetting      = "WALG_DEDUP_MAX_FILE_SIZE"
+	SplitLargeFilesSetting       = "WALG_SPLIT_LARGE_FILES"
+	SplitFileSizeSetting         = "WALG_SPLIT_FILE_SIZE"
+	SkipUnloggedSetting          = "WALG_SKIP_UNLOGGED"
+	WhileStandbySetting          = "WALG_BACKUP_WHILE_STANDBY"
+	UploadLabelFilesSetting      = "WALG_UPLOAD_LABEL_FILES"
 	DeltaFromNameSetting         = "WALG_DELTA_FROM_NAME"
 	DeltaFromUserDataSetting     = "WALG_DELTA_FROM_USER_DATA"
+	FullIfOlderThanSetting       = "WALG_FULL_IF_OLDER_THAN"
+	DeltaDetectionSetting        = "WALG_DELTA_DETECTION"
 	FetchTargetUserDataSetting   = "WALG_FETCH_TARGET_USER_DATA"
 	LogLevelSetting              = "WALG_LOG_LEVEL"
+	LogFormatSetting             = "WALG_LOG_FORMAT"
 	TarSizeThresholdSetting      = "WALG_TAR_SIZE_THRESHOLD"
 	TarDisableFsyncSetting       = "WALG_TAR_DISABLE_FSYNC"
 	CseKmsIDSetting              = "WALG_CSE_KMS_ID"
 	CseKmsRegionSetting          = "WALG_CSE_KMS_REGION"
+	KmsProviderSetting           = "WALG_KMS_PROVIDER"
+	GcpKmsKeyNameSetting         = "WALG_GCP_KMS_KEY_NAME"
 	LibsodiumKeySetting          = "WALG_LIBSODIUM_KEY"
 	LibsodiumKeyPathSetting      = "WALG_LIBSODIUM_KEY_PATH"
 	LibsodiumKeyTransform        = "WALG_LIBSODIUM_KEY_TRANSFORM"
+	EnvelopeKeySetting           = "WALG_ENVELOPE_MASTER_KEY"
+	EnvelopeKeyPathSetting       = "WALG_ENVELOPE_MASTER_KEY_PATH"
+	EnvelopeOldKeySetting        = "WALG_ENVELOPE_OLD_MASTER_KEY"
+	EnvelopeOldKeyPathSetting    = "WALG_ENVELOPE_OLD_MASTER_KEY_PATH"
 	GpgKeyIDSetting              = "GPG_KEY_ID"
 	PgpKeySetting                = "WALG_PGP_KEY"
 	PgpKeyPathSetting            = "WALG_PGP_KEY_PATH"
@@ -78,12 +109,36 @@ const (
 	MaxDelayedSegmentsCount      = "WALG_INTEGRITY_MAX_DELAYED_WALS"
 	PrefetchDir                  = "WALG_PREFETCH_DIR"
 	PgReadyRename                = "PG_READY_RENAME"
+	WalPushBatchSizeSetting      = "WALG_WAL_PUSH_BATCH_SIZE"
+	WalPushOverwriteSetting      = "WALG_WAL_PUSH_OVERWRITE"
+	WalZstdDictSetting           = "WALG_WAL_ZSTD_DICT"
+	FetchMaxDeltaSteps           = "WALG_FETCH_MAX_DELTA_STEPS"
+	FetchDeltaStepsAction        = "WALG_FETCH_DELTA_STEPS_ACTION"
 	SerializerTypeSetting        = "WALG_SERIALIZER_TYPE"
 	StreamSplitterPartitions     = "WALG_STREAM_SPLITTER_PARTITIONS"
 	StreamSplitterBlockSize      = "WALG_STREAM_SPLITTER_BLOCK_SIZE"
 	StatsdAddressSetting         = "WALG_STATSD_ADDRESS"
+	OtelEndpointSetting          = "WALG_OTEL_ENDPOINT"
 	PgAliveCheckInterval         = "WALG_ALIVE_CHECK_INTERVAL"
 	PgStopBackupTimeout          = "WALG_STOP_BACKUP_TIMEOUT"
+	ExcludePathsSetting          = "WALG_EXCLUDE_PATHS"
+	OverrideDefaultExcludes      = "WALG_OVERRIDE_DEFAULT_EXCLUDES"
+	FollowSymlinksSetting        = "WALG_FOLLOW_SYMLINKS"
+	MetricsAddressSetting        = "WALG_METRICS_ADDRESS"
+	BackupCheckpointInterval     = "WALG_BACKUP_CHECKPOINT_INTERVAL"
+	VerifyOnFetchSetting         = "WALG_VERIFY_ON_FETCH"
+	FetchProgressSetting         = "WALG_FETCH_PROGRESS"
+	FetchKeepGoingSetting        = "WALG_FETCH_KEEP_GOING"
+	FetchCacheDirSetting         = "WALG_FETCH_CACHE_DIR"
+	FetchCacheSizeSetting        = "WALG_FETCH_CACHE_SIZE"
+	StorageClassSetting          = "WALG_STORAGE_CLASS"
+	WalStorageClassSetting       = "WALG_WAL_STORAGE_CLASS"
+	PreBackupScriptSetting       = "WALG_PRE_BACKUP_SCRIPT"
+	PostBackupScriptSetting      = "WALG_POST_BACKUP_SCRIPT"
+	ScratchDirSetting            = "WALG_SCRATCH_DIR"
+	SentinelNamespaceSetting     = "WALG_SENTINEL_NAMESPACE"
+	RestoreUIDSetting            = "WALG_RESTORE_UID"
+	RestoreGIDSetting            = "WALG_RESTORE_GID"
 
 	ProfileSamplingRatio = "PROFILE_SAMPLING_RATIO"
 	ProfileMode          = "PROFILE_MODE"
@@ -146,10 +201,14 @@ const (
 	YcSaKeyFileSetting = "YC_SERVICE_ACCOUNT_KEY_FILE"
 
 	PgBackRestStanza = "PGBACKREST_STANZA"
+
+	// ConfigProfilesKey is the config file key holding the named profiles, selected by --profile
+	ConfigProfilesKey = "profiles"
 )
 
 var (
 	CfgFile             string
+	CfgProfile          string
 	defaultConfigValues map[string]string
 
 	commonDefaultConfigValues = map[string]string{
@@ -157,21 +216,41 @@ var (
 		UploadConcurrencySetting:     "16",
 		UploadDiskConcurrencySetting: "1",
 		UploadQueueSetting:           "2",
+		UploadQueueLogInterval:       "1m",
 		PreventWalOverwriteSetting:   "false",
 		UploadWalMetadata:            "NOMETADATA",
 		DeltaMaxStepsSetting:         "0",
 		CompressionMethodSetting:     "lz4",
+		ZstdLevelSetting:             "3",
+		Lz4BlockSizeSetting:          "4MB",
+		BrotliQualitySetting:         "3",
 		UseWalDeltaSetting:           "false",
 		TarSizeThresholdSetting:      "1073741823", // (1 << 30) - 1
+		StorageMaxRetriesSetting:     "0",
+		StorageRetryWaitSetting:      "1s",
 		TarDisableFsyncSetting:       "false",
 		TotalBgUploadedLimit:         "32",
 		UseReverseUnpackSetting:      "false",
 		SkipRedundantTarsSetting:     "false",
 		VerifyPageChecksumsSetting:   "false",
 		StoreAllCorruptBlocksSetting: "false",
+		VerifyConcurrencySetting:     "1",
+		FetchProgressSetting:         "true",
+		FetchCacheSizeSetting:        "10GB",
 		UseRatingComposerSetting:     "false",
 		UseCopyComposerSetting:       "false",
+		UseTablespaceComposerSetting: "false",
 		WithoutFilesMetadataSetting:  "false",
+		MinimalFilesMetadataSetting:  "false",
+		DedupSmallFilesSetting:       "false",
+		DedupMaxFileSizeSetting:      "1MB",
+		SplitLargeFilesSetting:       "false",
+		SplitFileSizeSetting:         "1GB",
+		SkipUnloggedSetting:          "false",
+		WhileStandbySetting:          "false",
+		UploadLabelFilesSetting:      "false",
+		DeltaDetectionSetting:        "mtime",
+		LogFormatSetting:             LogFormatText,
 		MaxDelayedSegmentsCount:      "0",
 		SerializerTypeSetting:        "json_default",
 		LibsodiumKeyTransform:        "none",
@@ -218,18 +297,32 @@ var (
 		DownloadConcurrencySetting:   true,
 		UploadConcurrencySetting:     true,
 		UploadDiskConcurrencySetting: true,
+		ConcurrencySetting:           true,
 		UploadQueueSetting:           true,
+		UploadQueueLogInterval:       true,
 		SentinelUserDataSetting:      true,
 		PreventWalOverwriteSetting:   true,
 		UploadWalMetadata:            true,
+		FailoverStoragesSetting:      true,
+		StorageClassSetting:          true,
+		StorageMaxRetriesSetting:     true,
+		StorageRetryWaitSetting:      true,
 		DeltaMaxStepsSetting:         true,
 		DeltaOriginSetting:           true,
 		CompressionMethodSetting:     true,
+		ZstdLevelSetting:             true,
+		Lz4BlockSizeSetting:          true,
+		BrotliQualitySetting:         true,
 		StoragePrefixSetting:         true,
+		ObjectPrefixSetting:          true,
+		ScratchDirSetting:            true,
+		SentinelNamespaceSetting:     true,
 		DiskRateLimitSetting:         true,
 		NetworkRateLimitSetting:      true,
+		UploadRateLimitSetting:       true,
 		UseWalDeltaSetting:           true,
 		LogLevelSetting:              true,
+		LogFormatSetting:             true,
 		TarSizeThresholdSetting:      true,
 		TarDisableFsyncSetting:       true,
 		"WALG_" + GpgKeyIDSetting:    true,
@@ -240,6 +333,11 @@ var (
 		LibsodiumKeySetting:          true,
 		LibsodiumKeyPathSetting:      true,
 		LibsodiumKeyTransform:        true,
+		EnvelopeKeySetting:           true,
+		EnvelopeKeyPathSetting:       true,
+		EnvelopeOldKeySetting:        true,
+		EnvelopeOldKeyPathSetting:    true,
+		KmsProviderSetting:           true,
 		TotalBgUploadedLimit:         true,
 		NameStreamCreateCmd:          true,
 		NameStreamRestoreCmd:         true,
@@ -247,15 +345,30 @@ var (
 		SkipRedundantTarsSetting:     true,
 		VerifyPageChecksumsSetting:   true,
 		StoreAllCorruptBlocksSetting: true,
+		VerifyConcurrencySetting:     true,
 		UseRatingComposerSetting:     true,
 		UseCopyComposerSetting:       true,
+		UseTablespaceComposerSetting: true,
+		ReuseRatingStatsSetting:      true,
+		DumpRatingSetting:            true,
 		WithoutFilesMetadataSetting:  true,
+		MinimalFilesMetadataSetting:  true,
+		DedupSmallFilesSetting:       true,
+		DedupMaxFileSizeSetting:      true,
+		SplitLargeFilesSetting:       true,
+		SplitFileSizeSetting:         true,
+		SkipUnloggedSetting:          true,
+		WhileStandbySetting:          true,
+		UploadLabelFilesSetting:      true,
 		MaxDelayedSegmentsCount:      true,
 		DeltaFromNameSetting:         true,
 		DeltaFromUserDataSetting:     true,
+		FullIfOlderThanSetting:       true,
+		DeltaDetectionSetting:        true,
 		FetchTargetUserDataSetting:   true,
 		SerializerTypeSetting:        true,
 		StatsdAddressSetting:         true,
+		OtelEndpointSetting:          true,
 
 		ProfileSamplingRatio: true,
 		ProfileMode:          true,
@@ -293,6 +406,9 @@ var (
 		"WALG_CSE_KMS_ID":             true,
 		"WALG_CSE_KMS_REGION":         true,
 		"WALG_S3_MAX_PART_SIZE":       true,
+		"WALG_UPLOAD_PART_SIZE":       true,
+		"WALG_OBJECT_LOCK_DAYS":       true,
+		"WALG_S3_OBJECT_LOCK_MODE":    true,
 		"S3_ENDPOINT_SOURCE":          true,
 		"S3_ENDPOINT_PORT":            true,
 		"S3_USE_LIST_OBJECTS_V1":      true,
@@ -302,17 +418,22 @@ var (
 		"S3_MAX_RETRIES":              true,
 
 		// Azure
-		"WALG_AZ_PREFIX":           true,
-		"AZURE_STORAGE_ACCOUNT":    true,
-		"AZURE_STORAGE_ACCESS_KEY": true,
-		"AZURE_STORAGE_SAS_TOKEN":  true,
-		"AZURE_ENVIRONMENT_NAME":   true,
-		"WALG_AZURE_BUFFER_SIZE":   true,
-		"WALG_AZURE_MAX_BUFFERS":   true,
+		"WALG_AZ_PREFIX":                true,
+		"AZURE_STORAGE_ACCOUNT":         true,
+		"AZURE_STORAGE_ACCESS_KEY":      true,
+		"AZURE_STORAGE_SAS_TOKEN":       true,
+		"AZURE_ENVIRONMENT_NAME":        true,
+		"WALG_AZURE_BUFFER_SIZE":        true,
+		"WALG_AZURE_MAX_BUFFERS":        true,
+		"WALG_AZURE_REHYDRATE_TIER":     true,
+		"WALG_AZURE_REHYDRATE_PRIORITY": true,
+		"WALG_AZURE_WAIT_REHYDRATE":     true,
+		"WALG_AZURE_REHYDRATE_TIMEOUT":  true,
 
 		// GS
 		"WALG_GS_PREFIX":                 true,
 		"GOOGLE_APPLICATION_CREDENTIALS": true,
+		GcpKmsKeyNameSetting:             true,
 
 		// Yandex Cloud
 		YcSaKeyFileSetting: true,
@@ -339,21 +460,41 @@ var (
 
 	PGAllowedSettings = map[string]bool{
 		// Postgres
-		PgPortSetting:        true,
-		PgUserSetting:        true,
-		PgHostSetting:        true,
-		PgDataSetting:        true,
-		PgPasswordSetting:    true,
-		PgDatabaseSetting:    true,
-		PgSslModeSetting:     true,
-		PgSlotName:           true,
-		PgWalSize:            true,
-		"PGPASSFILE":         true,
-		PrefetchDir:          true,
-		PgReadyRename:        true,
-		PgBackRestStanza:     true,
-		PgAliveCheckInterval: true,
-		PgStopBackupTimeout:  true,
+		PgPortSetting:            true,
+		PgUserSetting:            true,
+		PgHostSetting:            true,
+		PgDataSetting:            true,
+		RestoreUIDSetting:        true,
+		RestoreGIDSetting:        true,
+		PgPasswordSetting:        true,
+		PgDatabaseSetting:        true,
+		PgSslModeSetting:         true,
+		PgSlotName:               true,
+		PgWalSize:                true,
+		"PGPASSFILE":             true,
+		PrefetchDir:              true,
+		PgReadyRename:            true,
+		WalPushBatchSizeSetting:  true,
+		WalPushOverwriteSetting:  true,
+		WalZstdDictSetting:       true,
+		FetchMaxDeltaSteps:       true,
+		FetchDeltaStepsAction:    true,
+		PgBackRestStanza:         true,
+		PgAliveCheckInterval:     true,
+		PgStopBackupTimeout:      true,
+		ExcludePathsSetting:      true,
+		OverrideDefaultExcludes:  true,
+		FollowSymlinksSetting:    true,
+		MetricsAddressSetting:    true,
+		BackupCheckpointInterval: true,
+		VerifyOnFetchSetting:     true,
+		FetchProgressSetting:     true,
+		FetchKeepGoingSetting:    true,
+		FetchCacheDirSetting:     true,
+		FetchCacheSizeSetting:    true,
+		PreBackupScriptSetting:   true,
+		WalStorageClassSetting:   true,
+		PostBackupScriptSetting:  true,
 	}
 
 	MongoAllowedSettings = map[string]bool{
@@ -531,6 +672,12 @@ func Configure() {
 	}
 
 	configureLimiters()
+
+	err = ConfigureScratchDir()
+	FatalOnError(err)
+
+	err = ConfigureSentinelNamespace()
+	FatalOnError(err)
 }
 
 // ConfigureAndRunDefaultWebServer configures and runs web server
@@ -594,7 +741,9 @@ func InitConfig() {
 	SetDefaultValues(globalViper)
 	SetGoMaxProcs(globalViper)
 	ReadConfigFromFile(globalViper, CfgFile)
+	FatalUsageOnError(ApplyConfigProfile(globalViper, CfgProfile))
 	CheckAllowedSettings(globalViper)
+	FatalOnError(ConfigureAutoConcurrency(globalViper))
 
 	bindConfigToEnv(globalViper)
 }
@@ -625,6 +774,27 @@ func ReadConfigFromFile(config *viper.Viper, configFile string) {
 	}
 }
 
+// ApplyConfigProfile merges the settings of the named profile from the config file
+// over its top-level settings. The profiles are kept under the "profiles" key:
+//
+//	WALG_COMPRESSION_METHOD: zstd
+//	profiles:
+//	  prod:
+//	    WALG_S3_PREFIX: s3://prod-bucket/path
+//
+// The environment variables and the flags still override the profile settings.
+func ApplyConfigProfile(config *viper.Viper, profile string) error {
+	if profile == "" {
+		return nil
+	}
+	profileKey := ConfigProfilesKey + "." + profile
+	if !config.IsSet(profileKey) {
+		return errors.Errorf("profile '%s' is not found in the config file '%s'", profile, config.ConfigFileUsed())
+	}
+	tracelog.DebugLogger.Printf("Using config profile %s\n", profile)
+	return config.MergeConfigMap(config.GetStringMap(profileKey))
+}
+
 // SetDefaultValues set default settings to the viper instance
 func SetDefaultValues(config *viper.Viper) {
 	for setting, value := range defaultConfigValues {
@@ -643,6 +813,9 @@ func SetGoMaxProcs(config *viper.Viper) {
 func CheckAllowedSettings(config *viper.Viper) {
 	foundNotAllowed := false
 	for k := range config.AllSettings() {
+		if k == ConfigProfilesKey {
+			continue
+		}
 		k = strings.ToUpper(k)
 		if !isAllowedSetting(k, AllowedSettings) {
 			tracelog.WarningLogger.Println(k + " is unknown")
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/wal-g/wal-g/internal/crypto/yckms"
@@ -18,10 +19,12 @@ import (
 	"github.com/wal-g/wal-g/internal/compression"
 	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/crypto/awskms"
+	"github.com/wal-g/wal-g/internal/crypto/gcpkms"
 	"github.com/wal-g/wal-g/internal/crypto/openpgp"
 	"github.com/wal-g/wal-g/internal/fsutil"
 	"github.com/wal-g/wal-g/internal/limiters"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
 	"golang.org/x/time/rate"
 )
 
@@ -34,6 +37,17 @@ const (
 
 const MinAllowedConcurrency = 1
 
+// The KMS providers supported by WALG_KMS_PROVIDER
+const (
+	AwsKmsProvider = "aws"
+	GcpKmsProvider = "gcp"
+	YcKmsProvider  = "yc"
+)
+
+// MinTarSizeThreshold is the smallest allowed WALG_TAR_SIZE_THRESHOLD value,
+// lower values would produce an enormous number of tiny storage objects
+const MinTarSizeThreshold = 1 << 20
+
 var DeprecatedExternalGpgMessage = fmt.Sprintf(
 	`You are using deprecated functionality that uses an external gpg library.
 It will be removed in next major version.
@@ -54,6 +68,10 @@ func (err UnconfiguredStorageError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+func (err UnconfiguredStorageError) ExitCode() int {
+	return ExitCodeUsage
+}
+
 type UnknownCompressionMethodError struct {
 	error
 }
@@ -80,6 +98,10 @@ func (err UnsetRequiredSettingError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+func (err UnsetRequiredSettingError) ExitCode() int {
+	return ExitCodeUsage
+}
+
 type InvalidConcurrencyValueError struct {
 	error
 }
@@ -94,6 +116,20 @@ func (err InvalidConcurrencyValueError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+type InvalidTarSizeThresholdError struct {
+	error
+}
+
+func newInvalidTarSizeThresholdError(value int64) InvalidTarSizeThresholdError {
+	return InvalidTarSizeThresholdError{
+		errors.Errorf("%s value is expected to be at least %d bytes but is: %d",
+			TarSizeThresholdSetting, MinTarSizeThreshold, value)}
+}
+
+func (err InvalidTarSizeThresholdError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
 type UnmarshallingError struct {
 	error
 }
@@ -122,6 +158,32 @@ func configureLimiters() {
 		limiters.NetworkLimiter = rate.NewLimiter(rate.Limit(netLimit),
 			int(netLimit+DefaultDataBurstRateLimit)) // Add 8 pages to possible bursts
 	}
+
+	if viper.IsSet(UploadRateLimitSetting) {
+		err := ConfigureUploadRateLimiter()
+		tracelog.ErrorLogger.FatalOnError(err)
+	}
+}
+
+// ConfigureUploadRateLimiter sets up the network limiter shared by all the uploading goroutines
+// according to the WALG_UPLOAD_RATE_LIMIT setting. The setting accepts size suffixes (e.g. 50M),
+// zero value disables the limit.
+func ConfigureUploadRateLimiter() error {
+	if Turbo {
+		return nil
+	}
+	uploadLimit, err := utility.ParseSizeInBytes(viper.GetString(UploadRateLimitSetting))
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %s", UploadRateLimitSetting)
+	}
+	if uploadLimit == 0 {
+		limiters.NetworkLimiter = nil
+		return nil
+	}
+	tracelog.InfoLogger.Printf("Upload rate limited to %d bytes/s", uploadLimit)
+	limiters.NetworkLimiter = rate.NewLimiter(rate.Limit(uploadLimit),
+		int(uploadLimit+DefaultDataBurstRateLimit)) // Add 8 pages to possible bursts
+	return nil
 }
 
 // TODO : unit tests
@@ -142,6 +204,40 @@ func ConfigureStoragePrefix(folder storage.Folder) storage.Folder {
 	return folder
 }
 
+// ConfigureFolderFromConfig configures the root folder of the storage described by the config
+// including WALG_STORAGE_PREFIX, as ConfigureFolder does with the global settings
+func ConfigureFolderFromConfig(config *viper.Viper) (storage.Folder, error) {
+	folder, err := ConfigureFolderForSpecificConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if prefix := config.GetString(StoragePrefixSetting); prefix != "" {
+		folder = folder.GetSubFolder(prefix)
+	}
+	return folder, nil
+}
+
+// ConfigureFailoverStorages configures the storages listed in WALG_FAILOVER_STORAGES.
+// The setting is a comma-separated list of config files, each of them describes a single storage.
+func ConfigureFailoverStorages() ([]FailoverStorage, error) {
+	var failoverStorages []FailoverStorage
+	for _, configFile := range strings.Split(viper.GetString(FailoverStoragesSetting), ",") {
+		configFile = strings.TrimSpace(configFile)
+		if configFile == "" {
+			continue
+		}
+		config := viper.New()
+		SetDefaultValues(config)
+		ReadConfigFromFile(config, configFile)
+		folder, err := ConfigureFolderFromConfig(config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to configure the failover storage '%s'", configFile)
+		}
+		failoverStorages = append(failoverStorages, FailoverStorage{Name: configFile, Folder: folder})
+	}
+	return failoverStorages, nil
+}
+
 // TODO: something with that
 // when provided multiple 'keys' in the config,
 // this function will always return only one concrete 'folder'.
@@ -159,11 +255,49 @@ func ConfigureFolderForSpecificConfig(config *viper.Viper) (storage.Folder, erro
 		}
 
 		settings := adapter.loadSettings(config)
-		return adapter.configureFolder(prefix, settings)
+		folder, err := adapter.configureFolder(prefix, settings)
+		if err != nil {
+			return nil, err
+		}
+		return configureObjectPrefix(folder, config), nil
 	}
 	return nil, newUnconfiguredStorageError(skippedPrefixes)
 }
 
+// configureObjectPrefix places all the objects under WALG_OBJECT_PREFIX,
+// so the environments sharing the storage prefix don't collide
+func configureObjectPrefix(folder storage.Folder, config *viper.Viper) storage.Folder {
+	objectPrefix := strings.Trim(config.GetString(ObjectPrefixSetting), "/")
+	if objectPrefix != "" {
+		folder = folder.GetSubFolder(objectPrefix)
+	}
+	return folder
+}
+
+// ConfigFlag is the command line flag of the setting added by AddConfigFlags
+type ConfigFlag struct {
+	Name  string
+	Value string
+}
+
+// GetStorageLocationFlags returns the flags of the settings which locate the folder configured by ConfigureFolder,
+// so another wal-g process finds the same folder whatever its environment is. The credentials are not included.
+func GetStorageLocationFlags() []ConfigFlag {
+	flags := make([]ConfigFlag, 0)
+	for _, adapter := range StorageAdapters {
+		if prefix, ok := getWaleCompatibleSettingFrom(adapter.prefixName, viper.GetViper()); ok {
+			flags = append(flags, ConfigFlag{Name: toFlagName("WALG_" + adapter.prefixName), Value: prefix})
+			break
+		}
+	}
+	for _, setting := range []string{ObjectPrefixSetting, StoragePrefixSetting} {
+		if value := viper.GetString(setting); value != "" {
+			flags = append(flags, ConfigFlag{Name: toFlagName(setting), Value: value})
+		}
+	}
+	return flags
+}
+
 func getWalFolderPath() string {
 	if !viper.IsSet(PgDataSetting) {
 		return DefaultDataFolderPath
@@ -200,14 +334,18 @@ func ConfigureCompressor() (compression.Compressor, error) {
 	if _, ok := compression.Compressors[compressionMethod]; !ok {
 		return nil, newUnknownCompressionMethodError()
 	}
-	return compression.Compressors[compressionMethod], nil
+	return configureTunableCompressor(compressionMethod)
 }
 
 func ConfigureLogging() error {
 	if viper.IsSet(LogLevelSetting) {
-		return tracelog.UpdateLogLevel(viper.GetString(LogLevelSetting))
+		err := tracelog.UpdateLogLevel(viper.GetString(LogLevelSetting))
+		if err != nil {
+			return err
+		}
 	}
-	return nil
+	// the log level recreates the debug logger, so the format is applied after it
+	return ConfigureLogFormat(viper.GetString(LogFormatSetting), os.Stderr)
 }
 
 func getPGArchiveStatusFolderPath() string {
@@ -248,7 +386,11 @@ func ConfigureUploader() (uploader *Uploader, err error) {
 	}
 
 	uploader = NewUploader(compressor, folder)
-	return uploader, err
+	err = uploader.SetStorageClass(GetStorageClass())
+	if err != nil {
+		return nil, err
+	}
+	return uploader, nil
 }
 
 func ConfigureUploaderWithoutCompressMethod() (uploader *Uploader, err error) {
@@ -276,7 +418,11 @@ func ConfigureSplitUploader() (uploader UploaderProvider, err error) {
 	var blockSize = viper.GetSizeInBytes(StreamSplitterBlockSize)
 
 	uploader = NewSplitStreamUploader(compressor, folder, partitions, int(blockSize))
-	return uploader, err
+	err = uploader.SetStorageClass(GetStorageClass())
+	if err != nil {
+		return nil, err
+	}
+	return uploader, nil
 }
 
 // ConfigureCrypter uses environment variables to create and configure a crypter.
@@ -286,6 +432,10 @@ func ConfigureCrypter() crypto.Crypter {
 		return GetSetting(PgpKeyPassphraseSetting)
 	}
 
+	if kmsProvider, ok := GetSetting(KmsProviderSetting); ok {
+		return configureKmsCrypter(kmsProvider)
+	}
+
 	// key can be either private (for download) or public (for upload)
 	if viper.IsSet(PgpKeySetting) {
 		return openpgp.CrypterFromKey(viper.GetString(PgpKeySetting), loadPassphrase)
@@ -309,6 +459,14 @@ func ConfigureCrypter() crypto.Crypter {
 		return yckms.YcCrypterFromKeyIDAndCredential(viper.GetString(YcKmsKeyIDSetting), viper.GetString(YcSaKeyFileSetting))
 	}
 
+	if viper.IsSet(GcpKmsKeyNameSetting) {
+		return gcpkms.CrypterFromKeyName(viper.GetString(GcpKmsKeyNameSetting))
+	}
+
+	if crypter := configureEnvelopeCrypter(); crypter != nil {
+		return crypter
+	}
+
 	if crypter := configureLibsodiumCrypter(); crypter != nil {
 		return crypter
 	}
@@ -316,6 +474,70 @@ func ConfigureCrypter() crypto.Crypter {
 	return nil
 }
 
+// UnsupportedKmsProviderError is returned for the unknown WALG_KMS_PROVIDER
+type UnsupportedKmsProviderError struct {
+	error
+}
+
+func newUnsupportedKmsProviderError(kmsProvider string) UnsupportedKmsProviderError {
+	return UnsupportedKmsProviderError{errors.Errorf("Unsupported %s: '%s', expected one of: %s, %s, %s",
+		KmsProviderSetting, kmsProvider, AwsKmsProvider, GcpKmsProvider, YcKmsProvider)}
+}
+
+func (err UnsupportedKmsProviderError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err UnsupportedKmsProviderError) ExitCode() int {
+	return ExitCodeUsage
+}
+
+// kmsKeySettings are the settings of the keys of the KMS providers
+var kmsKeySettings = map[string]string{
+	AwsKmsProvider: CseKmsIDSetting,
+	GcpKmsProvider: GcpKmsKeyNameSetting,
+	YcKmsProvider:  YcKmsKeyIDSetting,
+}
+
+// CheckCrypterSettings checks the settings which ConfigureCrypter exits the process on,
+// so the callers which must not exit can fail with the error before configuring the crypter
+func CheckCrypterSettings() error {
+	kmsProvider, ok := GetSetting(KmsProviderSetting)
+	if !ok {
+		return nil
+	}
+	_, err := getKmsKeySetting(kmsProvider)
+	return err
+}
+
+func getKmsKeySetting(kmsProvider string) (string, error) {
+	setting, ok := kmsKeySettings[strings.ToLower(kmsProvider)]
+	if !ok {
+		return "", newUnsupportedKmsProviderError(kmsProvider)
+	}
+	key, ok := GetSetting(setting)
+	if !ok {
+		return "", NewUnsetRequiredSettingError(setting)
+	}
+	return key, nil
+}
+
+// configureKmsCrypter creates the crypter of the KMS provider chosen by WALG_KMS_PROVIDER,
+// the key of the provider is configured by its own setting
+func configureKmsCrypter(kmsProvider string) crypto.Crypter {
+	key, err := getKmsKeySetting(kmsProvider)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	switch strings.ToLower(kmsProvider) {
+	case AwsKmsProvider:
+		return awskms.CrypterFromKeyID(key, viper.GetString(CseKmsRegionSetting))
+	case GcpKmsProvider:
+		return gcpkms.CrypterFromKeyName(key)
+	default:
+		return yckms.YcCrypterFromKeyIDAndCredential(key, viper.GetString(YcSaKeyFileSetting))
+	}
+}
+
 func GetMaxDownloadConcurrency() (int, error) {
 	return GetMaxConcurrency(DownloadConcurrencySetting)
 }
@@ -324,12 +546,29 @@ func GetMaxUploadConcurrency() (int, error) {
 	return GetMaxConcurrency(UploadConcurrencySetting)
 }
 
+// GetVerifyConcurrency returns the number of workers verifying the page checksums of one paged file
+func GetVerifyConcurrency() (int, error) {
+	return GetMaxConcurrency(VerifyConcurrencySetting)
+}
+
 // This setting is intentionally undocumented in README. Effectively, this configures how many prepared tar Files there
 // may be in uploading state during backup-push.
 func getMaxUploadQueue() (int, error) {
 	return GetMaxConcurrency(UploadQueueSetting)
 }
 
+// getUploadQueueLogInterval returns how often the upload queue depth is logged, zero disables the logging
+func getUploadQueueLogInterval() (time.Duration, error) {
+	interval, err := GetDurationSetting(UploadQueueLogInterval)
+	if err != nil {
+		return 0, err
+	}
+	if interval < 0 {
+		return 0, errors.Errorf("%s must not be negative, got %s", UploadQueueLogInterval, interval)
+	}
+	return interval, nil
+}
+
 func GetMaxUploadDiskConcurrency() (int, error) {
 	if Turbo {
 		return 4, nil
@@ -346,6 +585,60 @@ func GetMaxConcurrency(concurrencyType string) (int, error) {
 	return concurrency, nil
 }
 
+// GetTarSizeThreshold returns the size of one backup tarball configured
+// by the WALG_TAR_SIZE_THRESHOLD setting. The setting accepts size suffixes (e.g. 512M).
+func GetTarSizeThreshold() (int64, error) {
+	tarSizeThreshold, err := utility.ParseSizeInBytes(viper.GetString(TarSizeThresholdSetting))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse %s", TarSizeThresholdSetting)
+	}
+	if tarSizeThreshold < MinTarSizeThreshold {
+		return 0, newInvalidTarSizeThresholdError(tarSizeThreshold)
+	}
+	return tarSizeThreshold, nil
+}
+
+// GetDedupMaxFileSize returns the size of the largest file deduplicated by the backup-push --dedup-small-files,
+// configured by the WALG_DEDUP_MAX_FILE_SIZE setting (e.g. 64KB)
+func GetDedupMaxFileSize() (int64, error) {
+	maxFileSize, err := utility.ParseSizeInBytes(viper.GetString(DedupMaxFileSizeSetting))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse %s", DedupMaxFileSizeSetting)
+	}
+	if maxFileSize <= 0 {
+		return 0, errors.Errorf("%s must be positive", DedupMaxFileSizeSetting)
+	}
+	return maxFileSize, nil
+}
+
+// GetSplitFileSize returns the size of the parts the larger files are split into by the backup-push --split-large-files,
+// configured by the WALG_SPLIT_FILE_SIZE setting (e.g. 256MB)
+func GetSplitFileSize() (int64, error) {
+	splitFileSize, err := utility.ParseSizeInBytes(viper.GetString(SplitFileSizeSetting))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse %s", SplitFileSizeSetting)
+	}
+	if splitFileSize <= 0 {
+		return 0, errors.Errorf("%s must be positive", SplitFileSizeSetting)
+	}
+	return splitFileSize, nil
+}
+
+// GetFullIfOlderThan returns the maximum age of the full backup which delta backups
+// are based on, configured by the WALG_FULL_IF_OLDER_THAN setting (e.g. 7d or 12h).
+// Zero value means that the age is not limited.
+func GetFullIfOlderThan() (time.Duration, error) {
+	fullIfOlderThan, ok := GetSetting(FullIfOlderThanSetting)
+	if !ok || fullIfOlderThan == "" {
+		return 0, nil
+	}
+	duration, err := utility.ParseDuration(fullIfOlderThan)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse %s", FullIfOlderThanSetting)
+	}
+	return duration, nil
+}
+
 func GetSentinelUserData() (interface{}, error) {
 	dataStr, ok := GetSetting(SentinelUserDataSetting)
 	if !ok {
@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package internal
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
+)
+
+// compressorConfigurators configure the tunable compressors which are built only with the build tags, e.g. brotli
+var compressorConfigurators = map[string]func() (compression.Compressor, error){}
+
+// configureTunableCompressor returns the compressor with the settings-provided options applied
+// if the compression method supports any tuning. Otherwise, the default compressor is returned.
+func configureTunableCompressor(compressionMethod string) (compression.Compressor, error) {
+	switch compressionMethod {
+	case lz4.AlgorithmName:
+		return configureLz4Compressor()
+	case zstd.AlgorithmName:
+		level := viper.GetInt(ZstdLevelSetting)
+		if level < zstd.MinLevel || level > zstd.MaxLevel {
+			return nil, errors.Errorf("%s value is expected to be in range [%d, %d] but is: %d",
+				ZstdLevelSetting, zstd.MinLevel, zstd.MaxLevel, level)
+		}
+		return zstd.Compressor{Level: level}, nil
+	default:
+		if configure, ok := compressorConfigurators[compressionMethod]; ok {
+			return configure()
+		}
+		return compression.Compressors[compressionMethod], nil
+	}
+}
@@ -0,0 +1,25 @@
+//go:build brotli && !windows
+// +build brotli,!windows
+
+package internal
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/brotli"
+)
+
+func init() {
+	compressorConfigurators[brotli.AlgorithmName] = configureBrotliCompressor
+}
+
+// configureBrotliCompressor returns the brotli compressor with the quality set by WALG_BROTLI_QUALITY
+func configureBrotliCompressor() (compression.Compressor, error) {
+	quality := viper.GetInt(BrotliQualitySetting)
+	if quality < brotli.MinQuality || quality > brotli.MaxQuality {
+		return nil, errors.Errorf("%s value is expected to be in range [%d, %d] but is: %d",
+			BrotliQualitySetting, brotli.MinQuality, brotli.MaxQuality, quality)
+	}
+	return brotli.Compressor{Quality: quality}, nil
+}
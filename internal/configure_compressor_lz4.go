@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// configureLz4Compressor returns the lz4 compressor with the block size set by WALG_LZ4_BLOCK_SIZE
+func configureLz4Compressor() (lz4.Compressor, error) {
+	blockSize, err := utility.ParseSizeInBytes(viper.GetString(Lz4BlockSizeSetting))
+	if err != nil {
+		return lz4.Compressor{}, errors.Wrapf(err, "failed to parse %s", Lz4BlockSizeSetting)
+	}
+	for _, allowedSize := range lz4.BlockSizes {
+		if int64(allowedSize) == blockSize {
+			return lz4.Compressor{BlockSize: allowedSize}, nil
+		}
+	}
+	return lz4.Compressor{}, errors.Errorf("%s value is expected to be one of 64KB, 256KB, 1MB, 4MB but is: %s",
+		Lz4BlockSizeSetting, viper.GetString(Lz4BlockSizeSetting))
+}
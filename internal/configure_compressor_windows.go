@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package internal
+
+import (
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+)
+
+// configureTunableCompressor returns the compressor with the settings-provided options applied
+// if the compression method supports any tuning. Otherwise, the default compressor is returned.
+func configureTunableCompressor(compressionMethod string) (compression.Compressor, error) {
+	if compressionMethod == lz4.AlgorithmName {
+		return configureLz4Compressor()
+	}
+	return compression.Compressors[compressionMethod], nil
+}
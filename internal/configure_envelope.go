@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+)
+
+// configureEnvelopeCrypter creates the envelope crypter if WALG_ENVELOPE_MASTER_KEY or its path is set,
+// the data keys stored in the backup sentinels are fetched from the configured storage
+func configureEnvelopeCrypter() crypto.Crypter {
+	masterKey, err := ConfigureEnvelopeMasterKey(EnvelopeKeySetting, EnvelopeKeyPathSetting)
+	tracelog.ErrorLogger.FatalOnError(err)
+	if masterKey == nil {
+		return nil
+	}
+	oldMasterKeys, err := ConfigureEnvelopeOldMasterKeys()
+	tracelog.ErrorLogger.FatalOnError(err)
+	return envelope.NewCrypter(masterKey, oldMasterKeys, fetchSentinelEncryptionKey)
+}
+
+// ConfigureEnvelopeMasterKey loads the envelope master key from the key setting or the key path setting,
+// nil if neither is set
+func ConfigureEnvelopeMasterKey(keySetting, keyPathSetting string) (*envelope.MasterKey, error) {
+	if viper.IsSet(keySetting) {
+		masterKey, err := envelope.MasterKeyFromBase64(viper.GetString(keySetting))
+		return masterKey, errors.Wrap(err, keySetting)
+	}
+	if viper.IsSet(keyPathSetting) {
+		masterKey, err := envelope.MasterKeyFromPath(viper.GetString(keyPathSetting))
+		return masterKey, errors.Wrap(err, keyPathSetting)
+	}
+	return nil, nil
+}
+
+// ConfigureEnvelopeOldMasterKeys loads the previous envelope master keys from the comma-separated lists
+// of WALG_ENVELOPE_OLD_MASTER_KEY and WALG_ENVELOPE_OLD_MASTER_KEY_PATH, so the files encrypted before
+// several rotations are still decrypted
+func ConfigureEnvelopeOldMasterKeys() ([]*envelope.MasterKey, error) {
+	var masterKeys []*envelope.MasterKey
+	for _, encodedKey := range splitEnvelopeKeys(viper.GetString(EnvelopeOldKeySetting)) {
+		masterKey, err := envelope.MasterKeyFromBase64(encodedKey)
+		if err != nil {
+			return nil, errors.Wrap(err, EnvelopeOldKeySetting)
+		}
+		masterKeys = append(masterKeys, masterKey)
+	}
+	for _, keyPath := range splitEnvelopeKeys(viper.GetString(EnvelopeOldKeyPathSetting)) {
+		masterKey, err := envelope.MasterKeyFromPath(keyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, EnvelopeOldKeyPathSetting)
+		}
+		masterKeys = append(masterKeys, masterKey)
+	}
+	return masterKeys, nil
+}
+
+func splitEnvelopeKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// sentinelEncryptionKey is the part of the backup sentinel storing the wrapped data key of the backup
+type sentinelEncryptionKey struct {
+	EncryptionKey *envelope.WrappedKey `json:"EncryptionKey,omitempty"`
+}
+
+// fetchSentinelEncryptionKey fetches the wrapped data key from the sentinel, the reference is the sentinel path
+func fetchSentinelEncryptionKey(sentinelPath string) (*envelope.WrappedKey, error) {
+	folder, err := ConfigureFolder()
+	if err != nil {
+		return nil, err
+	}
+	var sentinel sentinelEncryptionKey
+	err = FetchDto(folder, &sentinel, sentinelPath)
+	if err != nil {
+		return nil, err
+	}
+	if sentinel.EncryptionKey == nil {
+		return nil, errors.Errorf("%s has no encryption key", sentinelPath)
+	}
+	return sentinel.EncryptionKey, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/wal-g/wal-g/testtools"
@@ -12,6 +13,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
+	"github.com/wal-g/wal-g/internal/limiters"
+	"github.com/wal-g/wal-g/utility"
+	"golang.org/x/time/rate"
 )
 
 func TestGetMaxConcurrency_InvalidKey(t *testing.T) {
@@ -152,6 +158,84 @@ func TestConfigureLogging_WhenLogLevelSettingIsSet(t *testing.T) {
 	resetToDefaults()
 }
 
+func TestConfigureCompressor_ZstdLevel(t *testing.T) {
+	viper.Set(internal.CompressionMethodSetting, zstd.AlgorithmName)
+	viper.Set(internal.ZstdLevelSetting, "7")
+
+	compressor, err := internal.ConfigureCompressor()
+	assert.NoError(t, err)
+	assert.Equal(t, zstd.Compressor{Level: 7}, compressor)
+	resetToDefaults()
+}
+
+func TestConfigureCompressor_ZstdInvalidLevel(t *testing.T) {
+	viper.Set(internal.CompressionMethodSetting, zstd.AlgorithmName)
+	viper.Set(internal.ZstdLevelSetting, "100")
+
+	_, err := internal.ConfigureCompressor()
+	assert.Error(t, err)
+	resetToDefaults()
+}
+
+func TestConfigureCompressor_Lz4BlockSize(t *testing.T) {
+	viper.Set(internal.CompressionMethodSetting, lz4.AlgorithmName)
+	viper.Set(internal.Lz4BlockSizeSetting, "256KB")
+
+	compressor, err := internal.ConfigureCompressor()
+	assert.NoError(t, err)
+	assert.Equal(t, lz4.Compressor{BlockSize: 256 << 10}, compressor)
+	resetToDefaults()
+}
+
+func TestConfigureCompressor_Lz4InvalidBlockSize(t *testing.T) {
+	viper.Set(internal.CompressionMethodSetting, lz4.AlgorithmName)
+	viper.Set(internal.Lz4BlockSizeSetting, "512KB")
+
+	_, err := internal.ConfigureCompressor()
+	assert.Error(t, err)
+	resetToDefaults()
+}
+
+func TestConfigureUploadRateLimiter(t *testing.T) {
+	viper.Set(internal.UploadRateLimitSetting, "50M")
+	err := internal.ConfigureUploadRateLimiter()
+
+	assert.NoError(t, err)
+	assert.NotNil(t, limiters.NetworkLimiter)
+	assert.Equal(t, rate.Limit(50<<20), limiters.NetworkLimiter.Limit())
+
+	viper.Set(internal.UploadRateLimitSetting, "0")
+	err = internal.ConfigureUploadRateLimiter()
+
+	assert.NoError(t, err)
+	assert.Nil(t, limiters.NetworkLimiter)
+	resetToDefaults()
+}
+
+func TestGetTarSizeThreshold(t *testing.T) {
+	viper.Set(internal.TarSizeThresholdSetting, "4G")
+	tarSizeThreshold, err := internal.GetTarSizeThreshold()
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4<<30), tarSizeThreshold)
+	resetToDefaults()
+}
+
+func TestGetTarSizeThreshold_Default(t *testing.T) {
+	tarSizeThreshold, err := internal.GetTarSizeThreshold()
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<30-1), tarSizeThreshold)
+}
+
+func TestGetTarSizeThreshold_TooSmall(t *testing.T) {
+	viper.Set(internal.TarSizeThresholdSetting, "1024")
+	_, err := internal.GetTarSizeThreshold()
+
+	assert.IsType(t, internal.InvalidTarSizeThresholdError{}, err)
+	resetToDefaults()
+}
+
 func prepareDataFolder(t *testing.T, name string) string {
 	cwd, err := filepath.Abs("./")
 	if err != nil {
@@ -176,3 +260,94 @@ func resetToDefaults() {
 	internal.InitConfig()
 	internal.Configure()
 }
+
+func TestConfigureFolder_ObjectPrefix(t *testing.T) {
+	dir := t.TempDir()
+	viper.Set("WALG_FILE_PREFIX", dir)
+	viper.Set(internal.ObjectPrefixSetting, "/staging/")
+	defer resetToDefaults()
+
+	folder, err := internal.ConfigureFolder()
+	assert.NoError(t, err)
+	err = folder.GetSubFolder(utility.BaseBackupPath).PutObject("base_000000010000000000000002"+utility.SentinelSuffix,
+		strings.NewReader("{}"))
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "staging", utility.BaseBackupPath, "base_000000010000000000000002"+utility.SentinelSuffix))
+
+	objects, _, err := folder.GetSubFolder(utility.BaseBackupPath).ListFolder()
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+}
+
+func TestGetStorageLocationFlags(t *testing.T) {
+	viper.Set("WALE_S3_PREFIX", "s3://bucket/cluster")
+	viper.Set(internal.StoragePrefixSetting, "shard1")
+	viper.Set(internal.AwsSecretAccessKey, "secret")
+	defer resetToDefaults()
+
+	assert.Equal(t, []internal.ConfigFlag{
+		{Name: "walg-s3-prefix", Value: "s3://bucket/cluster"},
+		{Name: "walg-storage-prefix", Value: "shard1"},
+	}, internal.GetStorageLocationFlags())
+}
+
+func TestApplyConfigProfile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "walg.yaml")
+	err := os.WriteFile(configFile, []byte(`
+WALG_COMPRESSION_METHOD: zstd
+WALG_FILE_PREFIX: /tmp/default
+profiles:
+  prod:
+    WALG_FILE_PREFIX: /tmp/prod
+    WALG_UPLOAD_CONCURRENCY: "32"
+`), 0600)
+	assert.NoError(t, err)
+
+	config := viper.New()
+	internal.ReadConfigFromFile(config, configFile)
+	assert.NoError(t, internal.ApplyConfigProfile(config, "prod"))
+	assert.Equal(t, "zstd", config.GetString(internal.CompressionMethodSetting))
+	assert.Equal(t, "/tmp/prod", config.GetString("WALG_FILE_PREFIX"))
+	assert.Equal(t, 32, config.GetInt(internal.UploadConcurrencySetting))
+
+	config = viper.New()
+	internal.ReadConfigFromFile(config, configFile)
+	assert.NoError(t, internal.ApplyConfigProfile(config, ""))
+	assert.Equal(t, "/tmp/default", config.GetString("WALG_FILE_PREFIX"))
+	assert.Error(t, internal.ApplyConfigProfile(config, "dev"))
+}
+
+func TestApplyConfigProfile_FlagOverrides(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "walg.json")
+	err := os.WriteFile(configFile, []byte(`{"profiles": {"prod": {"WALG_FILE_PREFIX": "/tmp/prod"}}}`), 0600)
+	assert.NoError(t, err)
+
+	config := viper.New()
+	config.Set("WALG_FILE_PREFIX", "/tmp/flag")
+	internal.ReadConfigFromFile(config, configFile)
+	assert.NoError(t, internal.ApplyConfigProfile(config, "prod"))
+	assert.Equal(t, "/tmp/flag", config.GetString("WALG_FILE_PREFIX"))
+}
+
+func TestConfigureEnvelopeOldMasterKeys(t *testing.T) {
+	firstKey := "AQIDBAUGBwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHyA="
+	secondKey := "ICEiIyQlJicoKSorLC0uLzAxMjM0NTY3ODk6Ozw9Pj8="
+	keyPath := filepath.Join(t.TempDir(), "old.key")
+	assert.NoError(t, os.WriteFile(keyPath, []byte(secondKey+"\n"), 0600))
+	viper.Set(internal.EnvelopeOldKeySetting, firstKey+", "+secondKey)
+	viper.Set(internal.EnvelopeOldKeyPathSetting, keyPath)
+	defer func() {
+		viper.Set(internal.EnvelopeOldKeySetting, nil)
+		viper.Set(internal.EnvelopeOldKeyPathSetting, nil)
+	}()
+
+	masterKeys, err := internal.ConfigureEnvelopeOldMasterKeys()
+	assert.NoError(t, err)
+	assert.Len(t, masterKeys, 3)
+	assert.NotEqual(t, masterKeys[0].ID(), masterKeys[1].ID())
+	assert.Equal(t, masterKeys[1].ID(), masterKeys[2].ID())
+
+	viper.Set(internal.EnvelopeOldKeySetting, firstKey+",not a key")
+	_, err = internal.ConfigureEnvelopeOldMasterKeys()
+	assert.Error(t, err)
+}
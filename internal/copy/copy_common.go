@@ -1,10 +1,15 @@
 package copy
 
 import (
+	"hash/crc32"
+	"io"
+	"strings"
 	"sync"
 
+	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
 )
 
 type InfoProvider struct {
@@ -13,6 +18,14 @@ type InfoProvider struct {
 
 	SrcObj     storage.Object
 	targetName string
+	// copied records the source content, so VerifyInfos compares the target object with it
+	copied *copiedObject
+}
+
+// copiedObject is the checksum and the metadata of the source object as it is copied
+type copiedObject struct {
+	crc32    uint32
+	metadata map[string]string
 }
 
 func Infos(chs []InfoProvider) error {
@@ -60,17 +73,22 @@ func Infos(chs []InfoProvider) error {
 }
 
 func (ch *InfoProvider) copyObject() error {
-	readCloser, err := ch.From.ReadObject(ch.SrcObj.GetName())
+	readCloser, metadata, err := storage.ReadObjectWithMetadata(ch.From, ch.SrcObj.GetName())
 	if err != nil {
 		return err
 	}
+	defer utility.LoggedClose(readCloser, "")
 
 	tracelog.DebugLogger.Printf("fetched object %s reader\n", ch.SrcObj.GetName())
 
-	err = ch.To.PutObject(ch.targetName, readCloser)
+	checksum := crc32.NewIEEE()
+	err = putCopiedObject(ch.To, ch.targetName, io.TeeReader(readCloser, checksum), metadata)
 	if err != nil {
 		return err
 	}
+	if ch.copied != nil {
+		*ch.copied = copiedObject{crc32: checksum.Sum32(), metadata: metadata}
+	}
 
 	tracelog.InfoLogger.Printf(
 		"Copied '%s' from folder '%s' to '%s' in fodler '%s'.",
@@ -78,6 +96,94 @@ func (ch *InfoProvider) copyObject() error {
 	return nil
 }
 
+// putCopiedObject puts the copy with the metadata of the source object, since the object may be unreadable
+// without it, e.g. the WAL file compressed with the zstd dictionary
+func putCopiedObject(folder storage.Folder, name string, content io.Reader, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return folder.PutObject(name, content)
+	}
+	metadataFolder, ok := folder.(storage.MetadataFolder)
+	if !ok {
+		return errors.Errorf("'%s' has the object metadata, but the target storage '%s' doesn't keep it",
+			name, folder.GetPath())
+	}
+	return metadataFolder.PutObjectWithMetadata(name, content, "", metadata)
+}
+
+// VerifyInfos checks that every copied object is in the target folder and has the size of the source object,
+// so the number of the objects in the target matches the number of the copied ones. The target objects
+// are read back and compared with the source ones by the checksum of the content and by the metadata,
+// so the copy is known to be readable.
+func VerifyInfos(chs []InfoProvider) error {
+	targetObjects := make(map[string]map[string]storage.Object)
+	var mismatched []string
+	for _, ch := range chs {
+		objects, ok := targetObjects[ch.To.GetPath()]
+		if !ok {
+			folderObjects, err := storage.ListFolderRecursively(ch.To)
+			if err != nil {
+				return errors.Wrapf(err, "failed to list the target folder '%s'", ch.To.GetPath())
+			}
+			objects = make(map[string]storage.Object, len(folderObjects))
+			for _, object := range folderObjects {
+				objects[object.GetName()] = object
+			}
+			targetObjects[ch.To.GetPath()] = objects
+		}
+		target, ok := objects[ch.targetName]
+		if !ok || target.GetSize() != ch.SrcObj.GetSize() {
+			mismatched = append(mismatched, ch.targetName)
+			continue
+		}
+		equal, err := ch.targetEqualsCopied()
+		if err != nil {
+			return err
+		}
+		if !equal {
+			mismatched = append(mismatched, ch.targetName)
+		}
+	}
+	tracelog.InfoLogger.Printf("Verified %d of %d copied objects in the target storage\n",
+		len(chs)-len(mismatched), len(chs))
+	if len(mismatched) > 0 {
+		return errors.Errorf("%d of %d copied objects are missing or differ in the target storage: %s",
+			len(mismatched), len(chs), strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
+// targetEqualsCopied reads the target object back and compares its checksum and metadata with the source ones
+func (ch *InfoProvider) targetEqualsCopied() (bool, error) {
+	if ch.copied == nil {
+		return true, nil
+	}
+	reader, metadata, err := storage.ReadObjectWithMetadata(ch.To, ch.targetName)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read the copied object '%s'", ch.targetName)
+	}
+	defer utility.LoggedClose(reader, "")
+	checksum := crc32.NewIEEE()
+	_, err = io.Copy(checksum, reader)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read the copied object '%s'", ch.targetName)
+	}
+	if checksum.Sum32() != ch.copied.crc32 {
+		tracelog.WarningLogger.Printf("The copied object '%s' differs from the source one\n", ch.targetName)
+		return false, nil
+	}
+	if len(metadata) != len(ch.copied.metadata) {
+		tracelog.WarningLogger.Printf("The copied object '%s' lost the metadata of the source one\n", ch.targetName)
+		return false, nil
+	}
+	for key, value := range ch.copied.metadata {
+		if metadata[key] != value {
+			tracelog.WarningLogger.Printf("The copied object '%s' lost the metadata of the source one\n", ch.targetName)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 var NoopRenameFunc = func(o storage.Object) string {
 	if o == nil {
 		return ""
@@ -96,6 +202,7 @@ func BuildCopyingInfos(from storage.Folder, to storage.Folder, objects []storage
 				To:         to,
 				SrcObj:     object,
 				targetName: renameFunc(object),
+				copied:     &copiedObject{},
 			})
 			tracelog.DebugLogger.Printf("add copy info %s-%s \n", object.GetName(), renameFunc(object))
 		}
@@ -0,0 +1,179 @@
+package envelope
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/minio/sio"
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+const (
+	headerMagic = "WALGENV1"
+	// embeddedKeyKind header carries the wrapped data key itself
+	embeddedKeyKind = 'K'
+	// referencedKeyKind header carries the reference to the object which stores the wrapped data key,
+	// e.g. the backup sentinel, so the key can be rewrapped without rewriting the data
+	referencedKeyKind = 'R'
+)
+
+// KeyResolver fetches the wrapped data key stored in the referenced object
+type KeyResolver func(reference string) (*WrappedKey, error)
+
+// Crypter is the envelope encryption Crypter implementation: the data is encrypted by the random data key
+// and only the data key is encrypted by the master key
+type Crypter struct {
+	masterKeys []*MasterKey
+	resolver   KeyResolver
+
+	reference  string
+	dataKey    []byte
+	wrappedKey *WrappedKey
+
+	resolvedKeys map[string][]byte
+	mutex        sync.Mutex
+}
+
+var _ crypto.Crypter = &Crypter{}
+
+// NewCrypter creates the Crypter wrapping the new data keys by the master key. The old master keys
+// only unwrap the data keys which are not rewrapped yet, the resolver fetches the referenced data keys.
+func NewCrypter(masterKey *MasterKey, oldMasterKeys []*MasterKey, resolver KeyResolver) *Crypter {
+	return &Crypter{
+		masterKeys:   append([]*MasterKey{masterKey}, oldMasterKeys...),
+		resolver:     resolver,
+		resolvedKeys: make(map[string][]byte),
+	}
+}
+
+func (crypter *Crypter) Name() string {
+	return "Envelope/Crypter"
+}
+
+// ForBackup creates the Crypter encrypting by the new data key which is stored in the referenced object
+// instead of the encrypted objects. The caller stores the returned wrapped key there.
+func (crypter *Crypter) ForBackup(reference string) (*Crypter, *WrappedKey, error) {
+	dataKey, err := generateDataKey()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "can't generate data key")
+	}
+	wrappedKey, err := crypter.masterKeys[0].Wrap(dataKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "can't wrap data key")
+	}
+	backupCrypter := NewCrypter(crypter.masterKeys[0], crypter.masterKeys[1:], crypter.resolver)
+	backupCrypter.reference = reference
+	backupCrypter.dataKey = dataKey
+	backupCrypter.wrappedKey = wrappedKey
+	return backupCrypter, wrappedKey, nil
+}
+
+// Encrypt creates encryption writer from ordinary writer
+func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	dataKey, payload, kind, err := crypter.getEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(headerMagic)+3, len(headerMagic)+3+len(payload))
+	copy(header, headerMagic)
+	header[len(headerMagic)] = kind
+	binary.BigEndian.PutUint16(header[len(headerMagic)+1:], uint16(len(payload)))
+	header = append(header, payload...)
+	if _, err = writer.Write(header); err != nil {
+		return nil, errors.Wrap(err, "can't write envelope header")
+	}
+	return sio.EncryptWriter(writer, sio.Config{Key: dataKey})
+}
+
+// getEncryptionKey returns the data key and the header payload describing it,
+// the data key is generated once per Crypter
+func (crypter *Crypter) getEncryptionKey() (dataKey, payload []byte, kind byte, err error) {
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	if crypter.dataKey == nil {
+		dataKey, err := generateDataKey()
+		if err != nil {
+			return nil, nil, 0, errors.Wrap(err, "can't generate data key")
+		}
+		wrappedKey, err := crypter.masterKeys[0].Wrap(dataKey)
+		if err != nil {
+			return nil, nil, 0, errors.Wrap(err, "can't wrap data key")
+		}
+		crypter.dataKey, crypter.wrappedKey = dataKey, wrappedKey
+	}
+	if crypter.reference != "" {
+		return crypter.dataKey, []byte(crypter.reference), referencedKeyKind, nil
+	}
+	payload, err = json.Marshal(crypter.wrappedKey)
+	return crypter.dataKey, payload, embeddedKeyKind, err
+}
+
+// Decrypt creates decrypted reader from ordinary reader
+func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	header := make([]byte, len(headerMagic)+3)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, errors.Wrap(err, "can't read envelope header")
+	}
+	if string(header[:len(headerMagic)]) != headerMagic {
+		return nil, errors.New("object is not encrypted with envelope encryption")
+	}
+	kind := header[len(headerMagic)]
+	payload := make([]byte, binary.BigEndian.Uint16(header[len(headerMagic)+1:]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, errors.Wrap(err, "can't read envelope header")
+	}
+
+	var dataKey []byte
+	var err error
+	switch kind {
+	case embeddedKeyKind:
+		var wrappedKey WrappedKey
+		if err = json.Unmarshal(payload, &wrappedKey); err != nil {
+			return nil, errors.Wrap(err, "can't parse wrapped data key")
+		}
+		dataKey, err = crypter.unwrap(&wrappedKey)
+	case referencedKeyKind:
+		dataKey, err = crypter.resolve(string(payload))
+	default:
+		err = errors.Errorf("unknown envelope header kind '%c'", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sio.DecryptReader(reader, sio.Config{Key: dataKey})
+}
+
+// resolve fetches and unwraps the referenced data key, the keys are cached since all the tarballs
+// of the backup reference the same key
+func (crypter *Crypter) resolve(reference string) ([]byte, error) {
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	if dataKey, ok := crypter.resolvedKeys[reference]; ok {
+		return dataKey, nil
+	}
+	if crypter.resolver == nil {
+		return nil, errors.Errorf("can't resolve data key stored in %s", reference)
+	}
+	wrappedKey, err := crypter.resolver(reference)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't fetch data key stored in %s", reference)
+	}
+	dataKey, err := crypter.unwrap(wrappedKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "data key stored in %s", reference)
+	}
+	crypter.resolvedKeys[reference] = dataKey
+	return dataKey, nil
+}
+
+func (crypter *Crypter) unwrap(wrappedKey *WrappedKey) ([]byte, error) {
+	for _, masterKey := range crypter.masterKeys {
+		if masterKey.ID() == wrappedKey.MasterKeyID {
+			return masterKey.Unwrap(wrappedKey)
+		}
+	}
+	return nil, errors.Errorf("data key is wrapped by unknown master key %s", wrappedKey.MasterKeyID)
+}
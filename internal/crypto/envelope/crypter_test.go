@@ -0,0 +1,100 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const someSecret = "so very secret thingy"
+
+func newTestMasterKey(t *testing.T) *MasterKey {
+	key := make([]byte, masterKeyLen)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	masterKey, err := NewMasterKey(key)
+	require.NoError(t, err)
+	return masterKey
+}
+
+func encryptSecret(t *testing.T, crypter *Crypter) []byte {
+	var encrypted bytes.Buffer
+	writer, err := crypter.Encrypt(&encrypted)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte(someSecret))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.NotContains(t, encrypted.String(), someSecret)
+	return encrypted.Bytes()
+}
+
+func decryptSecret(crypter *Crypter, encrypted []byte) (string, error) {
+	reader, err := crypter.Decrypt(bytes.NewReader(encrypted))
+	if err != nil {
+		return "", err
+	}
+	decrypted, err := io.ReadAll(reader)
+	return string(decrypted), err
+}
+
+func TestEncryptionCycle_EmbeddedKey(t *testing.T) {
+	crypter := NewCrypter(newTestMasterKey(t), nil, nil)
+
+	decrypted, err := decryptSecret(crypter, encryptSecret(t, crypter))
+	assert.NoError(t, err)
+	assert.Equal(t, someSecret, decrypted)
+}
+
+func TestEncryptionCycle_ReferencedKey(t *testing.T) {
+	masterKey := newTestMasterKey(t)
+	storedKeys := make(map[string]*WrappedKey)
+	resolver := func(reference string) (*WrappedKey, error) {
+		return storedKeys[reference], nil
+	}
+	backupCrypter, wrappedKey, err := NewCrypter(masterKey, nil, resolver).ForBackup("sentinel.json")
+	require.NoError(t, err)
+	storedKeys["sentinel.json"] = wrappedKey
+	encrypted := encryptSecret(t, backupCrypter)
+
+	// rotate the master key
+	newMasterKey := newTestMasterKey(t)
+	storedKeys["sentinel.json"], err = masterKey.Rewrap(wrappedKey, newMasterKey)
+	require.NoError(t, err)
+
+	decrypted, err := decryptSecret(NewCrypter(newMasterKey, nil, resolver), encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, someSecret, decrypted)
+
+	_, err = decryptSecret(NewCrypter(masterKey, nil, resolver), encrypted)
+	assert.Error(t, err)
+}
+
+func TestDecrypt_OldMasterKey(t *testing.T) {
+	oldMasterKey := newTestMasterKey(t)
+	encrypted := encryptSecret(t, NewCrypter(oldMasterKey, nil, nil))
+
+	decrypted, err := decryptSecret(NewCrypter(newTestMasterKey(t), []*MasterKey{oldMasterKey}, nil), encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, someSecret, decrypted)
+
+	_, err = decryptSecret(NewCrypter(newTestMasterKey(t), nil, nil), encrypted)
+	assert.Error(t, err)
+}
+
+func TestMasterKey_RewrapByWrongKey(t *testing.T) {
+	masterKey := newTestMasterKey(t)
+	wrappedKey, err := masterKey.Wrap([]byte("data key"))
+	require.NoError(t, err)
+
+	_, err = newTestMasterKey(t).Rewrap(wrappedKey, newTestMasterKey(t))
+	assert.Error(t, err)
+}
+
+func TestMasterKeyFromBase64_WrongLength(t *testing.T) {
+	_, err := MasterKeyFromBase64("c2hvcnQ=")
+	assert.Error(t, err)
+}
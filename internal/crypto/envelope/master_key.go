@@ -0,0 +1,121 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	masterKeyLen   = 32
+	dataKeyLen     = 32
+	masterKeyIDLen = 16
+)
+
+// WrappedKey is the data key encrypted by the master key, it is stored along with the data it encrypts
+type WrappedKey struct {
+	MasterKeyID string `json:"MasterKeyID"`
+	Key         []byte `json:"Key"`
+}
+
+// MasterKey wraps and unwraps the data keys with AES-256-GCM, the data itself is never encrypted by it
+type MasterKey struct {
+	key []byte
+	id  string
+}
+
+// NewMasterKey creates the master key from the 32 raw bytes
+func NewMasterKey(key []byte) (*MasterKey, error) {
+	if len(key) != masterKeyLen {
+		return nil, fmt.Errorf("envelope master key must be exactly %d bytes (got %d bytes)", masterKeyLen, len(key))
+	}
+	hash := sha256.Sum256(key)
+	return &MasterKey{key: key, id: hex.EncodeToString(hash[:])[:masterKeyIDLen]}, nil
+}
+
+// MasterKeyFromBase64 creates the master key from its base64 encoding
+func MasterKeyFromBase64(encodedKey string) (*MasterKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope master key is not valid base64")
+	}
+	return NewMasterKey(key)
+}
+
+// MasterKeyFromPath creates the master key from the file with its base64 encoding
+func MasterKeyFromPath(path string) (*MasterKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read envelope master key from file")
+	}
+	return MasterKeyFromBase64(string(content))
+}
+
+// ID identifies the master key in the wrapped keys without disclosing it
+func (masterKey *MasterKey) ID() string {
+	return masterKey.id
+}
+
+// Wrap encrypts the data key, the random nonce is prepended to the result
+func (masterKey *MasterKey) Wrap(dataKey []byte) (*WrappedKey, error) {
+	aead, err := masterKey.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &WrappedKey{MasterKeyID: masterKey.id, Key: aead.Seal(nonce, nonce, dataKey, nil)}, nil
+}
+
+// Unwrap decrypts the data key wrapped by this master key
+func (masterKey *MasterKey) Unwrap(wrappedKey *WrappedKey) ([]byte, error) {
+	if wrappedKey.MasterKeyID != masterKey.id {
+		return nil, errors.Errorf("data key is wrapped by master key %s, not by %s", wrappedKey.MasterKeyID, masterKey.id)
+	}
+	aead, err := masterKey.newAEAD()
+	if err != nil {
+		return nil, err
+	}
+	if len(wrappedKey.Key) < aead.NonceSize() {
+		return nil, errors.New("wrapped data key is too short")
+	}
+	nonce, sealed := wrappedKey.Key[:aead.NonceSize()], wrappedKey.Key[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unwrap data key with master key %s", masterKey.id)
+	}
+	return dataKey, nil
+}
+
+// Rewrap moves the data key from this master key to the new one
+func (masterKey *MasterKey) Rewrap(wrappedKey *WrappedKey, newMasterKey *MasterKey) (*WrappedKey, error) {
+	dataKey, err := masterKey.Unwrap(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return newMasterKey.Wrap(dataKey)
+}
+
+func (masterKey *MasterKey) newAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(masterKey.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func generateDataKey() ([]byte, error) {
+	dataKey := make([]byte, dataKeyLen)
+	_, err := rand.Read(dataKey)
+	return dataKey, err
+}
@@ -0,0 +1,118 @@
+package gcpkms
+
+import (
+	"bufio"
+	"crypto/rand"
+	"io"
+	"sync"
+
+	"github.com/minio/sio"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+)
+
+const dataKeyLen = 32
+
+// Crypter is GCP KMS Crypter implementation.
+// The data key is generated once per process and is wrapped by KMS,
+// the wrapped key is written to the header of each encrypted file,
+// so each file can be decrypted on its own, even after the crypto key rotation.
+type Crypter struct {
+	kms KeyManagementService
+
+	mutex        sync.Mutex
+	key          []byte
+	encryptedKey []byte
+	// decryptedKeys caches the unwrapped keys, so KMS is asked once per data key, not once per file
+	decryptedKeys map[string][]byte
+}
+
+func NewCrypter(kms KeyManagementService) *Crypter {
+	return &Crypter{kms: kms, decryptedKeys: make(map[string][]byte)}
+}
+
+func (crypter *Crypter) Name() string {
+	return "GCP_KMS/Crypter"
+}
+
+// Encrypt creates encryption writer from ordinary writer
+func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	key, encryptedKey, err := crypter.getDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	bufferedWriter := bufio.NewWriter(writer)
+	_, err = bufferedWriter.Write(serializeEncryptedKey(encryptedKey))
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Can't write encryption key to buffer: %v", err)
+		return nil, err
+	}
+
+	encryptedWriter, err := sio.EncryptWriter(bufferedWriter,
+		sio.Config{Key: key, CipherSuites: []byte{sio.AES_256_GCM}})
+	if err != nil {
+		tracelog.ErrorLogger.Printf("GCP KMS can't create encrypted writer: %v", err)
+		return nil, err
+	}
+
+	return ioextensions.NewOnCloseFlusher(encryptedWriter, bufferedWriter), nil
+}
+
+// Decrypt creates decrypted reader from ordinary reader
+func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	encryptedKey, err := deserializeEncryptedKey(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read encryption key from archive file header")
+	}
+
+	key, err := crypter.decryptDataKey(encryptedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decrypt data encryption key from archive file header")
+	}
+
+	return sio.DecryptReader(reader, sio.Config{Key: key, CipherSuites: []byte{sio.AES_256_GCM}})
+}
+
+func (crypter *Crypter) getDataKey() (key []byte, encryptedKey []byte, err error) {
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+
+	if crypter.key == nil {
+		key := make([]byte, dataKeyLen)
+		if _, err := rand.Read(key); err != nil {
+			return nil, nil, errors.Wrap(err, "can't generate data encryption key")
+		}
+		encryptedKey, err := crypter.kms.Encrypt(key)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "can't encrypt data encryption key")
+		}
+		crypter.key, crypter.encryptedKey = key, encryptedKey
+	}
+	return crypter.key, crypter.encryptedKey, nil
+}
+
+func (crypter *Crypter) decryptDataKey(encryptedKey []byte) ([]byte, error) {
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+
+	if key, ok := crypter.decryptedKeys[string(encryptedKey)]; ok {
+		return key, nil
+	}
+	key, err := crypter.kms.Decrypt(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	crypter.decryptedKeys[string(encryptedKey)] = key
+	return key, nil
+}
+
+// CrypterFromKeyName creates GCP KMS Crypter with given crypto key resource name
+func CrypterFromKeyName(keyName string) crypto.Crypter {
+	kms, err := newCloudKeyManagementService(keyName)
+	tracelog.ErrorLogger.FatalfOnError("Can't initialize GCP KMS client: %v", err)
+
+	return NewCrypter(kms)
+}
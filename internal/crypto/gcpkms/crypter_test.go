@@ -0,0 +1,117 @@
+package gcpkms
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockKeyManagementService imitates KMS crypto key with versions:
+// the ciphertext is prefixed by the version it was wrapped with
+type mockKeyManagementService struct {
+	primaryVersion byte
+	disabled       map[byte]bool
+	decryptCalls   int
+}
+
+func newMockKeyManagementService() *mockKeyManagementService {
+	return &mockKeyManagementService{primaryVersion: 1, disabled: make(map[byte]bool)}
+}
+
+func (kms *mockKeyManagementService) rotate() {
+	kms.primaryVersion++
+}
+
+func (kms *mockKeyManagementService) Encrypt(plaintext []byte) ([]byte, error) {
+	ciphertext := []byte{kms.primaryVersion}
+	for _, b := range plaintext {
+		ciphertext = append(ciphertext, b^kms.primaryVersion)
+	}
+	return ciphertext, nil
+}
+
+func (kms *mockKeyManagementService) Decrypt(ciphertext []byte) ([]byte, error) {
+	kms.decryptCalls++
+	version := ciphertext[0]
+	if version > kms.primaryVersion || kms.disabled[version] {
+		return nil, errors.New("crypto key version is not enabled")
+	}
+	plaintext := make([]byte, 0, len(ciphertext)-1)
+	for _, b := range ciphertext[1:] {
+		plaintext = append(plaintext, b^version)
+	}
+	return plaintext, nil
+}
+
+func encrypt(t *testing.T, crypter *Crypter, secret string) []byte {
+	buf := new(bytes.Buffer)
+	encrypt, err := crypter.Encrypt(buf)
+	assert.NoError(t, err)
+	_, err = encrypt.Write([]byte(secret))
+	assert.NoError(t, err)
+	assert.NoError(t, encrypt.Close())
+	return buf.Bytes()
+}
+
+func decrypt(crypter *Crypter, encrypted []byte) (string, error) {
+	decrypt, err := crypter.Decrypt(bytes.NewReader(encrypted))
+	if err != nil {
+		return "", err
+	}
+	decryptedBytes, err := io.ReadAll(decrypt)
+	return string(decryptedBytes), err
+}
+
+func TestEncryptionCycle(t *testing.T) {
+	const someSecret = "so very secret thingy"
+	crypter := NewCrypter(newMockKeyManagementService())
+
+	encrypted := encrypt(t, crypter, someSecret)
+	assert.NotContains(t, string(encrypted), someSecret)
+
+	decrypted, err := decrypt(crypter, encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, someSecret, decrypted)
+}
+
+func TestDecryptAfterKeyRotation(t *testing.T) {
+	kms := newMockKeyManagementService()
+	oldEncrypted := encrypt(t, NewCrypter(kms), "old backup")
+
+	kms.rotate()
+	newEncrypted := encrypt(t, NewCrypter(kms), "new backup")
+
+	crypter := NewCrypter(kms)
+	decrypted, err := decrypt(crypter, oldEncrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "old backup", decrypted)
+	decrypted, err = decrypt(crypter, newEncrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "new backup", decrypted)
+
+	kms.disabled[1] = true
+	_, err = decrypt(NewCrypter(kms), oldEncrypted)
+	assert.Error(t, err)
+}
+
+func TestDataKeyIsUnwrappedOnce(t *testing.T) {
+	kms := newMockKeyManagementService()
+	encryptCrypter := NewCrypter(kms)
+	first := encrypt(t, encryptCrypter, "first file")
+	second := encrypt(t, encryptCrypter, "second file")
+
+	crypter := NewCrypter(kms)
+	for _, encrypted := range [][]byte{first, second} {
+		_, err := decrypt(crypter, encrypted)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, kms.decryptCalls)
+}
+
+func TestDecryptInvalidHeader(t *testing.T) {
+	_, err := decrypt(NewCrypter(newMockKeyManagementService()), []byte("not encrypted at all"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package gcpkms
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"google.golang.org/api/cloudkms/v1"
+)
+
+const (
+	magic                   = "gcpkms"
+	schemeVersion      byte = 1
+	maxEncryptedKeyLen      = 4096
+)
+
+// KeyManagementService wraps and unwraps the data encryption keys
+type KeyManagementService interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// cloudKeyManagementService is GCP Cloud KMS implementation of KeyManagementService
+type cloudKeyManagementService struct {
+	// keyName is the resource name of the crypto key:
+	// projects/{project}/locations/{location}/keyRings/{keyRing}/cryptoKeys/{cryptoKey}
+	keyName string
+	service *cloudkms.Service
+}
+
+func newCloudKeyManagementService(keyName string) (*cloudKeyManagementService, error) {
+	service, err := cloudkms.NewService(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &cloudKeyManagementService{keyName: keyName, service: service}, nil
+}
+
+// Encrypt wraps the key with the primary version of the crypto key
+func (kms *cloudKeyManagementService) Encrypt(plaintext []byte) ([]byte, error) {
+	response, err := kms.service.Projects.Locations.KeyRings.CryptoKeys.Encrypt(kms.keyName,
+		&cloudkms.EncryptRequest{Plaintext: base64.StdEncoding.EncodeToString(plaintext)}).Do()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Ciphertext)
+}
+
+// Decrypt unwraps the key. The ciphertext identifies the crypto key version it was wrapped with,
+// so the keys wrapped before the rotation are unwrapped while their versions are enabled.
+func (kms *cloudKeyManagementService) Decrypt(ciphertext []byte) ([]byte, error) {
+	response, err := kms.service.Projects.Locations.KeyRings.CryptoKeys.Decrypt(kms.keyName,
+		&cloudkms.DecryptRequest{Ciphertext: base64.StdEncoding.EncodeToString(ciphertext)}).Do()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Plaintext)
+}
+
+func serializeEncryptedKey(encryptedKey []byte) []byte {
+	/*
+		magic value "gcpkms"
+		scheme version (current version is 1)
+		uint32 - encrypted key len
+		encrypted key ...
+	*/
+
+	encryptedKeyLen := make([]byte, 4)
+	binary.LittleEndian.PutUint32(encryptedKeyLen, uint32(len(encryptedKey)))
+	result := append([]byte(magic), schemeVersion)
+	result = append(result, encryptedKeyLen...)
+
+	return append(result, encryptedKey...)
+}
+
+func deserializeEncryptedKey(r io.Reader) ([]byte, error) {
+	magicSchemeBytes := make([]byte, len(magic)+1)
+	_, err := io.ReadFull(r, magicSchemeBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(magicSchemeBytes[0:len(magic)]) != magic {
+		return nil, errors.New("GCP KMS: invalid encrypted header format")
+	}
+
+	if schemeVersion != magicSchemeBytes[len(magic)] {
+		return nil, errors.New("GCP KMS: scheme version is not supported")
+	}
+
+	encryptedKeyLenBytes := make([]byte, 4)
+	_, err = io.ReadFull(r, encryptedKeyLenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKeyLen := binary.LittleEndian.Uint32(encryptedKeyLenBytes)
+	if encryptedKeyLen > maxEncryptedKeyLen {
+		return nil, errors.New("GCP KMS: invalid size of the encrypted key")
+	}
+
+	encryptedKey := make([]byte, encryptedKeyLen)
+	_, err = io.ReadFull(r, encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return encryptedKey, nil
+}
@@ -43,6 +43,9 @@ func (r *SegBackupRunner) Run() {
 	if internal.CfgFile != "" {
 		cmdArgs = append(cmdArgs, "--config", internal.CfgFile)
 	}
+	if internal.CfgProfile != "" {
+		cmdArgs = append(cmdArgs, "--profile", internal.CfgProfile)
+	}
 
 	segBackupStatesPath := FormatSegmentStateFolderPath(r.contentID)
 	tracelog.ErrorLogger.FatalOnError(os.RemoveAll(segBackupStatesPath))
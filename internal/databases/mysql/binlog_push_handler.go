@@ -179,7 +179,7 @@ func archiveBinLog(uploader internal.UploaderProvider, dataDir string, binlog st
 		return errors.Wrapf(err, "upload: could not open '%s'\n", filename)
 	}
 	defer utility.LoggedClose(walFile, "")
-	err = uploader.UploadFile(walFile)
+	_, err = uploader.UploadFile(walFile)
 	if err != nil {
 		return errors.Wrapf(err, "upload: could not upload '%s'\n", filename)
 	}
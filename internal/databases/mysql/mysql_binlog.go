@@ -104,10 +104,11 @@ func GetBinlogPreviousGTIDsRemote(folder storage.Folder, filename string, flavor
 		return nil, fmt.Errorf("failed to read binlog %s: %w", binlogName, err)
 	}
 	defer utility.LoggedClose(fh, "failed to close binlog")
-	tmp, err := os.CreateTemp("", binlogName)
+	tmp, err := internal.CreateScratchFile(binlogName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
+	defer func() { tracelog.WarningLogger.PrintOnError(os.Remove(tmp.Name())) }()
 	defer utility.LoggedClose(tmp, "failed to close temp file")
 	_, err = io.CopyN(tmp, fh, BinlogReadHeaderSize)
 	if err != nil && err != io.EOF {
@@ -2,12 +2,15 @@ package postgres
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/pkg/storages/fs"
@@ -59,10 +62,9 @@ func (backup *Backup) getTarPartitionFolder() storage.Folder {
 }
 
 func (backup *Backup) GetTarNames() ([]string, error) {
-	tarPartitionFolder := backup.getTarPartitionFolder()
-	objects, _, err := tarPartitionFolder.ListFolder()
+	objects, err := backup.listTars()
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to list backup '%s' for deletion", backup.Name)
+		return nil, err
 	}
 	result := make([]string, len(objects))
 	for id, object := range objects {
@@ -71,6 +73,25 @@ func (backup *Backup) GetTarNames() ([]string, error) {
 	return result, nil
 }
 
+func (backup *Backup) listTars() ([]storage.Object, error) {
+	tarPartitionFolder := backup.getTarPartitionFolder()
+	objects, _, err := tarPartitionFolder.ListFolder()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list backup '%s' for deletion", backup.Name)
+	}
+	return objects, nil
+}
+
+// newTarReaderMaker returns the reader maker of the tar member,
+// which validates the downloaded bytes if the checksum of the tar member is recorded
+func (backup *Backup) newTarReaderMaker(tarName string, filesMeta FilesMetadataDto) *internal.StorageReaderMaker {
+	readerMaker := internal.NewStorageReaderMaker(backup.getTarPartitionFolder(), tarName)
+	if checksum, ok := filesMeta.TarChecksums[tarName]; ok {
+		readerMaker.Checksum = &checksum
+	}
+	return readerMaker
+}
+
 func (backup *Backup) GetSentinel() (BackupSentinelDto, error) {
 	if backup.SentinelDto != nil {
 		return *backup.SentinelDto, nil
@@ -133,6 +154,7 @@ func (backup *Backup) GetSentinelAndFilesMetadata() (BackupSentinelDto, FilesMet
 	// skip the files metadata fetch if backup was taken without it
 	if sentinel.FilesMetadataDisabled {
 		tracelog.InfoLogger.Printf("Files metadata tracking was disabled, skipping the download of %s", FilesMetadataName)
+		filesMetadata.TarChecksums = sentinel.TarChecksums
 		backup.FilesMetadataDto = &filesMetadata
 		return sentinel, filesMetadata, nil
 	}
@@ -178,13 +200,17 @@ func (backup *Backup) FetchMeta() (ExtendedMetadataDto, error) {
 	if err != nil {
 		return ExtendedMetadataDto{}, err
 	}
+	// the backup is no longer permanent once the hold of backup-retain expires
+	if extendedMetadataDto.Retention.isExpired(utility.TimeNowCrossPlatformUTC()) {
+		extendedMetadataDto.IsPermanent = false
+	}
 
 	return extendedMetadataDto, nil
 }
 
 // getFilesMetadataPath returns files metadata storage path.
 func getFilesMetadataPath(backupName string) string {
-	return backupName + "/" + FilesMetadataName
+	return backupName + "/" + internal.NamespacedName(FilesMetadataName)
 }
 
 func checkDBDirectoryForUnwrap(dbDataDirectory string, sentinelDto BackupSentinelDto, filesMeta FilesMetadataDto) error {
@@ -248,23 +274,35 @@ func setTablespacePaths(spec TablespaceSpec) error {
 // check that directory is empty before unwrap
 func (backup *Backup) unwrapToEmptyDirectory(
 	dbDataDirectory string, sentinelDto BackupSentinelDto,
-	filesMeta FilesMetadataDto, filesToUnwrap map[string]bool, createIncrementalFiles bool,
+	filesMeta FilesMetadataDto, filesToUnwrap map[string]bool, createIncrementalFiles bool, restoreFilter *RestoreFilter,
 ) error {
 	err := checkDBDirectoryForUnwrap(dbDataDirectory, sentinelDto, filesMeta)
 	if err != nil {
 		return err
 	}
 
-	return backup.unwrapOld(dbDataDirectory, sentinelDto, filesMeta, filesToUnwrap, createIncrementalFiles)
+	return backup.unwrapOld(dbDataDirectory, sentinelDto, filesMeta, filesToUnwrap, createIncrementalFiles, restoreFilter)
 }
 
 // TODO : unit tests
 // Do the job of unpacking Backup object
 func (backup *Backup) unwrapOld(
 	dbDataDirectory string, sentinelDto BackupSentinelDto,
-	filesMeta FilesMetadataDto, filesToUnwrap map[string]bool, createIncrementalFiles bool,
+	filesMeta FilesMetadataDto, filesToUnwrap map[string]bool, createIncrementalFiles bool, restoreFilter *RestoreFilter,
 ) error {
 	tarInterpreter := NewFileTarInterpreter(dbDataDirectory, sentinelDto, filesMeta, filesToUnwrap, createIncrementalFiles)
+	tarInterpreter.RestoreFilter = restoreFilter
+	var err error
+	tarInterpreter.Ownership, err = ConfigureRestoreOwnership()
+	if err != nil {
+		return err
+	}
+	return backup.extractTars(tarInterpreter, sentinelDto, filesMeta, filesToUnwrap)
+}
+
+// extractTars extracts the tar members with the files to unwrap using the interpreter, pg_control is extracted last
+func (backup *Backup) extractTars(tarInterpreter *FileTarInterpreter, sentinelDto BackupSentinelDto,
+	filesMeta FilesMetadataDto, filesToUnwrap map[string]bool) error {
 	tarsToExtract, pgControlKey, err := backup.getTarsToExtract(filesMeta, filesToUnwrap, false)
 	if err != nil {
 		return err
@@ -277,23 +315,68 @@ func (backup *Backup) unwrapOld(
 		return newPgControlNotFoundError()
 	}
 
-	err = internal.ExtractAll(tarInterpreter, tarsToExtract)
+	progress := backup.newExtractProgress(sentinelDto)
+	progress.Start()
+	err = backup.extractBackupTars(tarInterpreter, tarsToExtract, filesMeta, filesToUnwrap, progress)
+	progress.Stop()
 	if err != nil {
 		return err
 	}
 
 	if needPgControl {
-		err = internal.ExtractAll(tarInterpreter, []internal.ReaderMaker{
-			internal.NewStorageReaderMaker(backup.getTarPartitionFolder(), pgControlKey)})
+		err = internal.ExtractAll(tarInterpreter, []internal.ReaderMaker{backup.newTarReaderMaker(pgControlKey, filesMeta)})
 		if err != nil {
 			return errors.Wrap(err, "failed to extract pg_control")
 		}
 	}
+	err = tarInterpreter.SyncDirectories()
+	if err != nil {
+		return err
+	}
 
 	tracelog.InfoLogger.Print("\nBackup extraction complete.\n")
 	return nil
 }
 
+// newExtractProgress returns the progress of the backup extraction,
+// or nil if it is disabled by the WALG_FETCH_PROGRESS setting
+func (backup *Backup) newExtractProgress(sentinelDto BackupSentinelDto) *internal.ExtractProgress {
+	if !viper.GetBool(internal.FetchProgressSetting) {
+		return nil
+	}
+	return internal.NewExtractProgress(backup.Name, sentinelDto.UncompressedSize)
+}
+
+// unwrapToStream writes the files of the full backup into the output as a single tar stream.
+// The tarballs are extracted one by one without retries, since a partially written member
+// can't be taken back from the stream. The pg_control is written last.
+func (backup *Backup) unwrapToStream(output io.Writer, filesMeta FilesMetadataDto, filesToUnwrap map[string]bool) error {
+	tarInterpreter := NewStreamTarInterpreter(output, filesToUnwrap)
+	tarInterpreter.dedupCopies = filesMeta.getDedupCopies()
+	// the parts of the split file are extracted from the different tarballs, they can't form a single tar member
+	if fileName, ok := filesMeta.findSplitFile(filesToUnwrap); ok {
+		return newSplitFileError(fileName, "streamed, restore the backup into the data directory")
+	}
+	tarsToExtract, pgControlKey, err := backup.getTarsToExtract(filesMeta, filesToUnwrap, false)
+	if err != nil {
+		return err
+	}
+	if pgControlKey != "" {
+		tarsToExtract = append(tarsToExtract, backup.newTarReaderMaker(pgControlKey, filesMeta))
+	}
+
+	for _, tarToExtract := range tarsToExtract {
+		failed, err := internal.ExtractAllOnce(tarInterpreter, []internal.ReaderMaker{tarToExtract})
+		if err != nil {
+			return err
+		}
+		if err, ok := failed[tarToExtract.StoragePath()]; ok {
+			return errors.Wrapf(err, "failed to stream %s", tarToExtract.StoragePath())
+		}
+	}
+	return tarInterpreter.Close()
+}
+
 func IsPgControlRequired(backup Backup, sentinelDto BackupSentinelDto) bool {
 	re := regexp.MustCompile(`^([^_]+._{1}[^_]+._{1})`)
 	walgBasebackupName := re.FindString(backup.Name) == ""
@@ -318,14 +401,27 @@ func isDirectoryEmpty(directoryPath string) (bool, error) {
 // TODO : init tests
 func (backup *Backup) getTarsToExtract(filesMeta FilesMetadataDto, filesToUnwrap map[string]bool,
 	skipRedundantTars bool) (tarsToExtract []internal.ReaderMaker, pgControlKey string, err error) {
-	tarNames, err := backup.GetTarNames()
+	tarObjects, err := backup.listTars()
 	if err != nil {
 		return nil, "", err
 	}
+	tarNames := make([]string, 0, len(tarObjects))
+	for _, tarObject := range tarObjects {
+		// the truncated tar members are reported before anything is extracted
+		if checksum, ok := filesMeta.TarChecksums[tarObject.GetName()]; ok {
+			err = internal.CheckObjectSize(tarObject.GetName(), tarObject.GetSize(), checksum)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		tarNames = append(tarNames, tarObject.GetName())
+	}
+	sortTarNamesBySize(tarNames, filesMeta)
 	tracelog.DebugLogger.Printf("Tars to extract: '%+v'\n", tarNames)
 	tarsToExtract = make([]internal.ReaderMaker, 0, len(tarNames))
 
 	pgControlRe := regexp.MustCompile(`^.*?pg_control\.tar(\..+$|$)`)
+	dedupCopies := filesMeta.getDedupCopies()
 	for _, tarName := range tarNames {
 		// Separate the pg_control tarName from the others to
 		// extract it at the end, as to prevent server startup
@@ -340,11 +436,11 @@ func (backup *Backup) getTarsToExtract(filesMeta FilesMetadataDto, filesToUnwrap
 			continue
 		}
 
-		if skipRedundantTars && !shouldUnwrapTar(tarName, filesMeta, filesToUnwrap) {
+		if skipRedundantTars && !shouldUnwrapTar(tarName, filesMeta, filesToUnwrap, dedupCopies) {
 			continue
 		}
 
-		tarToExtract := internal.NewStorageReaderMaker(backup.getTarPartitionFolder(), tarName)
+		tarToExtract := backup.newTarReaderMaker(tarName, filesMeta)
 		tarsToExtract = append(tarsToExtract, tarToExtract)
 	}
 
@@ -374,13 +470,17 @@ func (backup *Backup) GetFilesToUnwrap(fileMask string) (map[string]bool, error)
 	}
 	// in case of WAL-E of old WAL-G backup -or-
 	// base backup created with WALG_WITHOUT_FILES_METADATA
-	if len(filesMeta.Files) == 0 {
+	if len(filesMeta.Files) == 0 && len(filesMeta.TarMemberSizes) == 0 {
 		return UnwrapAll, nil
 	}
 	filesToUnwrap := make(map[string]bool)
 	for file := range filesMeta.Files {
 		filesToUnwrap[file] = true
 	}
+	// the backup created with the minimal files metadata lists only the tar members
+	for file := range filesMeta.TarMemberSizes {
+		filesToUnwrap[file] = true
+	}
 	for utilityFilePath := range UtilityFilePaths {
 		filesToUnwrap[utilityFilePath] = true
 	}
@@ -402,7 +502,27 @@ func (backup *Backup) LoadAoFilesMetadata() (*AOFilesMetadataDTO, error) {
 	return backup.AoFilesMetadataDto, nil
 }
 
-func shouldUnwrapTar(tarName string, filesMeta FilesMetadataDto, filesToUnwrap map[string]bool) bool {
+// sortTarNamesBySize puts the largest tars first if the sizes of their members are recorded,
+// so the extraction doesn't end up waiting for a single large tar started last
+func sortTarNamesBySize(tarNames []string, filesMeta FilesMetadataDto) {
+	fileSizes := filesMeta.getFileSizes()
+	tarSizes := make(map[string]int64, len(tarNames))
+	for _, tarName := range tarNames {
+		for _, file := range filesMeta.TarFileSets[tarName] {
+			if part, ok := filesMeta.getSplitFilePart(file); ok {
+				tarSizes[tarName] += part.region().Length
+				continue
+			}
+			tarSizes[tarName] += fileSizes[file]
+		}
+	}
+	sort.SliceStable(tarNames, func(i, j int) bool {
+		return tarSizes[tarNames[i]] > tarSizes[tarNames[j]]
+	})
+}
+
+func shouldUnwrapTar(tarName string, filesMeta FilesMetadataDto, filesToUnwrap map[string]bool,
+	dedupCopies map[string][]string) bool {
 	// in case of base backup created with WALG_WITHOUT_FILES_METADATA
 	if len(filesMeta.TarFileSets) == 0 {
 		return true
@@ -411,7 +531,8 @@ func shouldUnwrapTar(tarName string, filesMeta FilesMetadataDto, filesToUnwrap m
 	tarFiles := filesMeta.TarFileSets[tarName]
 
 	for _, file := range tarFiles {
-		if filesToUnwrap[file] {
+		file = filesMeta.getEntryFileName(file)
+		if filesToUnwrap[file] || hasDedupCopyToUnwrap(dedupCopies[file], filesToUnwrap) {
 			return true
 		}
 	}
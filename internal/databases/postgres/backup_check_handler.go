@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// BackupCheckStatus is the Nagios plugin status of backup-check, its value is the exit code
+type BackupCheckStatus int
+
+const (
+	BackupCheckOk BackupCheckStatus = iota
+	BackupCheckWarning
+	BackupCheckCritical
+	// BackupCheckUnknown is reported if the check can't be made, e.g. on the invalid flags or configuration
+	BackupCheckUnknown
+)
+
+func (status BackupCheckStatus) String() string {
+	return [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}[status]
+}
+
+// BackupCheckThresholds are the ages of the latest backup which make backup-check warn or fail,
+// the zero threshold is not checked
+type BackupCheckThresholds struct {
+	WarnAge time.Duration
+	CritAge time.Duration
+}
+
+// BackupCheckResult is the worst status of the checks with the one-line summary of all of them
+type BackupCheckResult struct {
+	Status  BackupCheckStatus
+	Summary string
+}
+
+// backupCheck collects the findings of the checks, the worst one sets the status
+type backupCheck struct {
+	status   BackupCheckStatus
+	findings []string
+}
+
+func (check *backupCheck) report(status BackupCheckStatus, format string, args ...interface{}) {
+	if status > check.status {
+		check.status = status
+	}
+	check.findings = append(check.findings, fmt.Sprintf(format, args...))
+}
+
+func (check *backupCheck) result() BackupCheckResult {
+	return BackupCheckResult{Status: check.status, Summary: strings.Join(check.findings, ", ")}
+}
+
+// HandleBackupCheck checks the latest backup, prints the one-line summary
+// and exits with 0, 1, 2 or 3 for OK, WARNING, CRITICAL or UNKNOWN like a Nagios plugin
+func HandleBackupCheck(rootFolder storage.Folder, thresholds BackupCheckThresholds) {
+	ReportBackupCheck(CheckLatestBackup(rootFolder, thresholds, time.Now()))
+}
+
+// ReportBackupCheck prints the summary and exits with the status. The summary is joined into one line
+// as the monitoring systems expect, the errors in it may be multiline.
+func ReportBackupCheck(result BackupCheckResult) {
+	fmt.Printf("BACKUP %s - %s\n", result.Status, strings.Join(strings.Fields(result.Summary), " "))
+	internal.ExitWithCode(int(result.Status))
+}
+
+// CheckLatestBackup checks that the latest backup is not older than the thresholds, that its sentinel
+// is parseable and that the WAL segments from its start to its finish are archived
+func CheckLatestBackup(rootFolder storage.Folder, thresholds BackupCheckThresholds, now time.Time) BackupCheckResult {
+	check := &backupCheck{}
+	// the latest backup is the one backup-fetch LATEST restores
+	latestName, err := internal.NewLatestBackupSelector().Select(rootFolder)
+	if _, ok := err.(internal.NoBackupsFoundError); ok {
+		check.report(BackupCheckCritical, "no backups found")
+		return check.result()
+	}
+	if err != nil {
+		check.report(BackupCheckCritical, "failed to find the latest backup: %v", err)
+		return check.result()
+	}
+
+	backup := NewBackup(rootFolder.GetSubFolder(utility.BaseBackupPath), latestName)
+	finishTime, err := getBackupFinishTime(backup)
+	if err != nil {
+		check.report(BackupCheckCritical, "failed to get the finish time of latest backup %s: %v", latestName, err)
+		return check.result()
+	}
+	age := now.Sub(finishTime).Truncate(time.Second)
+	switch {
+	case thresholds.CritAge > 0 && age > thresholds.CritAge:
+		check.report(BackupCheckCritical, "latest backup %s is %s old (critical after %s)",
+			latestName, age, thresholds.CritAge)
+	case thresholds.WarnAge > 0 && age > thresholds.WarnAge:
+		check.report(BackupCheckWarning, "latest backup %s is %s old (warning after %s)",
+			latestName, age, thresholds.WarnAge)
+	default:
+		check.report(BackupCheckOk, "latest backup %s is %s old", latestName, age)
+	}
+
+	sentinel, err := backup.GetSentinel()
+	if err != nil {
+		check.report(BackupCheckCritical, "sentinel is not parseable: %v", err)
+		return check.result()
+	}
+	checkBackupWalArchived(check, rootFolder, latestName, sentinel)
+	return check.result()
+}
+
+// getBackupFinishTime returns the finish time of the backup as backup-list --detail prints it. The modification
+// time of the sentinel is used only for the backups without the metadata, since the sentinel is rewritten
+// e.g. by backup-mark.
+func getBackupFinishTime(backup Backup) (time.Time, error) {
+	meta, err := backup.FetchMeta()
+	if err == nil && !meta.FinishTime.IsZero() {
+		return meta.FinishTime, nil
+	}
+	backupTimes, err := internal.GetBackups(backup.Folder)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, backupTime := range backupTimes {
+		if backupTime.BackupName == backup.Name {
+			return backupTime.Time, nil
+		}
+	}
+	return time.Time{}, internal.NewBackupNonExistenceError(backup.Name)
+}
+
+// checkBackupWalArchived checks the WAL segments the backup needs to be consistent, their absence is only
+// a warning for the backups taken without waiting for the WAL archiving
+func checkBackupWalArchived(check *backupCheck, rootFolder storage.Folder, backupName string,
+	sentinel BackupSentinelDto) {
+	if sentinel.BackupStartLSN == nil || sentinel.BackupFinishLSN == nil {
+		check.report(BackupCheckWarning, "WAL range is unknown, the sentinel has no start or finish LSN")
+		return
+	}
+	timelineID, err := ParseTimelineFromBackupName(backupName)
+	if err != nil {
+		check.report(BackupCheckCritical, "WAL range is unknown: %v", err)
+		return
+	}
+	walFolderFilenames, err := getFolderFilenames(rootFolder.GetSubFolder(utility.WalPath))
+	if err != nil {
+		check.report(BackupCheckCritical, "failed to list WAL segments: %v", err)
+		return
+	}
+	storageSegments := getSegmentsFromFiles(walFolderFilenames)
+
+	startSegmentNo := newWalSegmentNo(*sentinel.BackupStartLSN)
+	endSegmentNo := newWalSegmentNo(*sentinel.BackupFinishLSN - 1)
+	var missingSegments []string
+	for segmentNo := startSegmentNo; segmentNo <= endSegmentNo; segmentNo = segmentNo.next() {
+		if !storageSegments[WalSegmentDescription{Timeline: timelineID, Number: segmentNo}] {
+			missingSegments = append(missingSegments, segmentNo.getFilename(timelineID))
+		}
+	}
+	walRange := fmt.Sprintf("WAL %s-%s", startSegmentNo.getFilename(timelineID), endSegmentNo.getFilename(timelineID))
+	if len(missingSegments) == 0 {
+		check.report(BackupCheckOk, "%s archived", walRange)
+		return
+	}
+	status := BackupCheckCritical
+	if sentinel.WalUnverified {
+		status = BackupCheckWarning
+	}
+	check.report(status, "%s has %d missing segments, first missing %s",
+		walRange, len(missingSegments), missingSegments[0])
+}
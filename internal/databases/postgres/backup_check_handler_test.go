@@ -0,0 +1,101 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const checkedBackupName = "base_000000010000000000000002"
+
+var checkThresholds = postgres.BackupCheckThresholds{WarnAge: 26 * time.Hour, CritAge: 50 * time.Hour}
+
+func putCheckedBackup(t *testing.T, folder storage.Folder, walUnverified bool, walSegments ...string) {
+	startLSN, finishLSN := postgres.LSN(0x2000028), postgres.LSN(0x3000100)
+	sentinel := postgres.BackupSentinelDto{BackupStartLSN: &startLSN, BackupFinishLSN: &finishLSN,
+		WalUnverified: walUnverified}
+	content, err := json.Marshal(sentinel)
+	require.NoError(t, err)
+	require.NoError(t, folder.GetSubFolder(utility.BaseBackupPath).PutObject(
+		internal.SentinelNameFromBackup(checkedBackupName), bytes.NewReader(content)))
+	for _, walSegment := range walSegments {
+		require.NoError(t, folder.GetSubFolder(utility.WalPath).PutObject(walSegment+".lz4", &bytes.Buffer{}))
+	}
+}
+
+func TestCheckLatestBackup_Ok(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putCheckedBackup(t, folder, false, "000000010000000000000002", "000000010000000000000003")
+
+	result := postgres.CheckLatestBackup(folder, checkThresholds, time.Now().Add(time.Hour))
+	assert.Equal(t, postgres.BackupCheckOk, result.Status)
+	assert.Contains(t, result.Summary, checkedBackupName)
+	assert.Contains(t, result.Summary, "WAL 000000010000000000000002-000000010000000000000003 archived")
+}
+
+func TestCheckLatestBackup_Age(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putCheckedBackup(t, folder, false, "000000010000000000000002", "000000010000000000000003")
+
+	result := postgres.CheckLatestBackup(folder, checkThresholds, time.Now().Add(30*time.Hour))
+	assert.Equal(t, postgres.BackupCheckWarning, result.Status)
+
+	result = postgres.CheckLatestBackup(folder, checkThresholds, time.Now().Add(60*time.Hour))
+	assert.Equal(t, postgres.BackupCheckCritical, result.Status)
+}
+
+func TestCheckLatestBackup_AgeByFinishTime(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putCheckedBackup(t, folder, false, "000000010000000000000002", "000000010000000000000003")
+	// the sentinel is modified now, but the backup is finished 30 hours ago
+	now := time.Now()
+	meta := postgres.ExtendedMetadataDto{FinishTime: now.Add(-30 * time.Hour)}
+	require.NoError(t, internal.UploadDto(folder.GetSubFolder(utility.BaseBackupPath), meta,
+		checkedBackupName+"/"+utility.MetadataFileName))
+
+	result := postgres.CheckLatestBackup(folder, checkThresholds, now)
+	assert.Equal(t, postgres.BackupCheckWarning, result.Status)
+	assert.Contains(t, result.Summary, "is 30h0m0s old")
+}
+
+func TestCheckLatestBackup_MissingWal(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putCheckedBackup(t, folder, false, "000000010000000000000002")
+
+	result := postgres.CheckLatestBackup(folder, checkThresholds, time.Now())
+	assert.Equal(t, postgres.BackupCheckCritical, result.Status)
+	assert.Contains(t, result.Summary, "first missing 000000010000000000000003")
+}
+
+func TestCheckLatestBackup_MissingWalOfUnverifiedBackup(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putCheckedBackup(t, folder, true, "000000010000000000000002")
+
+	result := postgres.CheckLatestBackup(folder, checkThresholds, time.Now())
+	assert.Equal(t, postgres.BackupCheckWarning, result.Status)
+}
+
+func TestCheckLatestBackup_BrokenSentinel(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	require.NoError(t, folder.GetSubFolder(utility.BaseBackupPath).PutObject(
+		internal.SentinelNameFromBackup(checkedBackupName), bytes.NewBufferString("{broken")))
+
+	result := postgres.CheckLatestBackup(folder, checkThresholds, time.Now())
+	assert.Equal(t, postgres.BackupCheckCritical, result.Status)
+	assert.Contains(t, result.Summary, "sentinel is not parseable")
+}
+
+func TestCheckLatestBackup_NoBackups(t *testing.T) {
+	result := postgres.CheckLatestBackup(memory.NewFolder("", memory.NewStorage()), checkThresholds, time.Now())
+	assert.Equal(t, postgres.BackupCheckCritical, result.Status)
+	assert.Equal(t, "no backups found", result.Summary)
+}
@@ -0,0 +1,268 @@
+package postgres
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type BackupDiffError struct {
+	error
+}
+
+func newBackupDiffError(backupName string, differentFilesCount int) BackupDiffError {
+	return BackupDiffError{errors.Errorf(
+		"%d files of the data directory differ from the backup %s", differentFilesCount, backupName)}
+}
+
+func (err BackupDiffError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err BackupDiffError) ExitCode() int {
+	return internal.ExitCodeDataCorruption
+}
+
+// BackupDiffReport describes the files of the backup which differ from the data directory of the running cluster
+type BackupDiffReport struct {
+	BackupName    string
+	DataDirectory string
+	// VerifiedCount is the number of the backup files which checksums are compared to the data directory
+	VerifiedCount int
+	// UnverifiedCount is the number of the backup files without the recorded checksums:
+	// the increments, the skipped files of the delta backups and the files of the older backups
+	UnverifiedCount int
+	// ChangedFiles differ from the backup because they are written after the backup start, by the reasons
+	ChangedFiles map[string]string
+	// DifferentFiles differ from the backup while nothing explains it, by the reasons
+	DifferentFiles map[string]string
+}
+
+func (report *BackupDiffReport) IsOk() bool {
+	return len(report.DifferentFiles) == 0
+}
+
+// HandleBackupDiff compares the checksums of the backup files to the data directory of the running cluster
+func HandleBackupDiff(folder storage.Folder, backupSelector internal.BackupSelector, dataDirectory string,
+	outputJSON, pretty bool) {
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	backupName, err := backupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	report, err := DiffBackup(NewBackup(baseBackupFolder, backupName), utility.ResolveSymlink(dataDirectory))
+	tracelog.ErrorLogger.FatalfOnError("Failed to diff backup: %v", err)
+
+	if outputJSON {
+		err = internal.WriteAsJSON(report, os.Stdout, pretty)
+	} else {
+		err = WriteBackupDiffReport(report, os.Stdout)
+	}
+	tracelog.ErrorLogger.FatalOnError(err)
+	if !report.IsOk() {
+		internal.FatalOnError(newBackupDiffError(backupName, len(report.DifferentFiles)))
+	}
+	tracelog.InfoLogger.Printf("Data directory matches backup %s", backupName)
+}
+
+// DiffBackup compares the checksums recorded in the files metadata of the backup to the checksums
+// of the files in the data directory. A different file is explained by the concurrent writes
+// if it is deleted, its modification time is not the recorded one or it has pages newer than the backup start LSN.
+// The files created after the backup are not compared.
+func DiffBackup(backup Backup, dataDirectory string) (*BackupDiffReport, error) {
+	sentinelDto, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if sentinelDto.FilesMetadataDisabled || sentinelDto.FilesMetadataMinimal {
+		return nil, errors.Errorf("backup %s has no files metadata to compare with", backup.Name)
+	}
+	err = verifyBackupDiffSystemIdentifier(dataDirectory, sentinelDto)
+	if err != nil {
+		return nil, err
+	}
+	var startLSN LSN
+	if sentinelDto.BackupStartLSN != nil {
+		startLSN = *sentinelDto.BackupStartLSN
+	}
+
+	report := &BackupDiffReport{
+		BackupName:     backup.Name,
+		DataDirectory:  dataDirectory,
+		ChangedFiles:   make(map[string]string),
+		DifferentFiles: make(map[string]string),
+	}
+	fileNames := make([]string, 0, len(filesMeta.Files))
+	for fileName := range filesMeta.Files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	tracelog.InfoLogger.Printf("Comparing %d files of backup %s with %s", len(fileNames), backup.Name, dataDirectory)
+
+	for _, fileName := range fileNames {
+		fileDescription := filesMeta.Files[fileName]
+		localPath := filepath.Join(dataDirectory, fileName)
+		localInfo, err := os.Stat(localPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "failed to stat file: '%s'", localPath)
+		}
+		if err == nil && localInfo.IsDir() {
+			continue
+		}
+		if fileDescription.Crc32c == nil || fileDescription.IsIncremented || fileDescription.IsSkipped {
+			report.UnverifiedCount++
+			continue
+		}
+		report.VerifiedCount++
+		if err != nil {
+			report.ChangedFiles[fileName] = "deleted after the backup"
+			continue
+		}
+		changeReason, differenceReason, err := diffLocalFile(localPath, localInfo, fileDescription, startLSN)
+		if err != nil {
+			return nil, err
+		}
+		if changeReason != "" {
+			tracelog.DebugLogger.Printf("%s: %s", fileName, changeReason)
+			report.ChangedFiles[fileName] = changeReason
+		}
+		if differenceReason != "" {
+			report.DifferentFiles[fileName] = differenceReason
+		}
+	}
+	return report, nil
+}
+
+// diffLocalFile returns the reason of the local file difference which is explained by the writes made
+// after the backup start, or the reason of the difference which is not explained; both are empty for the same file
+func diffLocalFile(localPath string, localInfo os.FileInfo, fileDescription internal.BackupFileDescription,
+	startLSN LSN) (changeReason, differenceReason string, err error) {
+	if !localInfo.Mode().IsRegular() {
+		return "", "not a regular file", nil
+	}
+	isPaged := isPagedFile(localInfo, localPath)
+	checksum, maxPageLSN, err := readLocalFileChecksum(localPath, isPaged)
+	if os.IsNotExist(errors.Cause(err)) {
+		return "deleted after the backup", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	sizeChanged := fileDescription.Size != 0 && fileDescription.Size != localInfo.Size()
+	if checksum == *fileDescription.Crc32c && !sizeChanged {
+		return "", "", nil
+	}
+	if isPaged && maxPageLSN >= startLSN {
+		return fmt.Sprintf("has pages changed at %s after the backup start LSN %s", maxPageLSN, startLSN), "", nil
+	}
+	if !localInfo.ModTime().Equal(fileDescription.MTime) {
+		return fmt.Sprintf("modified at %s after the backup read it", localInfo.ModTime().Format(time.RFC3339)), "", nil
+	}
+	if sizeChanged {
+		return "", fmt.Sprintf("size mismatch: expected %d, got %d", fileDescription.Size, localInfo.Size()), nil
+	}
+	return "", fmt.Sprintf("checksum mismatch: expected %08x, got %08x", *fileDescription.Crc32c, checksum), nil
+}
+
+// readLocalFileChecksum calculates the CRC32C of the file and, for the paged files, the largest LSN of its pages
+func readLocalFileChecksum(localPath string, isPaged bool) (checksum uint32, maxPageLSN LSN, err error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to open file: '%s'", localPath)
+	}
+	defer utility.LoggedClose(file, "")
+	checksumReader := newChecksumReader(file)
+	if !isPaged {
+		_, err = io.Copy(io.Discard, checksumReader)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "failed to read file: '%s'", localPath)
+		}
+		return checksumReader.checksum(), 0, nil
+	}
+
+	page := make([]byte, DatabasePageSize)
+	for {
+		_, err = io.ReadFull(checksumReader, page)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			// the file is being extended, the partially written page is compared as is
+			break
+		}
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "failed to read file: '%s'", localPath)
+		}
+		pageHeader, err := parsePostgresPageHeader(bytes.NewReader(page))
+		if err != nil {
+			return 0, 0, err
+		}
+		if pageHeader.lsn() > maxPageLSN {
+			maxPageLSN = pageHeader.lsn()
+		}
+	}
+	return checksumReader.checksum(), maxPageLSN, nil
+}
+
+// verifyBackupDiffSystemIdentifier checks that the backup is taken from the cluster it is compared with
+func verifyBackupDiffSystemIdentifier(dataDirectory string, sentinelDto BackupSentinelDto) error {
+	if sentinelDto.SystemIdentifier == nil {
+		tracelog.WarningLogger.Println("The backup has no system identifier, " +
+			"unable to verify that it is taken from the compared cluster")
+		return nil
+	}
+	pgControlData, err := ExtractPgControl(dataDirectory)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read pg_control of the compared cluster")
+	}
+	if pgControlData.GetSystemIdentifier() != *sentinelDto.SystemIdentifier {
+		return errors.Errorf("backup system identifier %d does not match the compared cluster system identifier %d",
+			*sentinelDto.SystemIdentifier, pgControlData.GetSystemIdentifier())
+	}
+	return nil
+}
+
+func WriteBackupDiffReport(report *BackupDiffReport, output io.Writer) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
+	defer writer.Flush()
+	_, err := fmt.Fprintf(writer, "backup: %s, data directory: %s\n", report.BackupName, report.DataDirectory)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "verified files: %d, unverified files: %d, changed after the backup: %d\n",
+		report.VerifiedCount, report.UnverifiedCount, len(report.ChangedFiles))
+	if err != nil {
+		return err
+	}
+	if report.IsOk() {
+		_, err = fmt.Fprintln(writer, "status: OK")
+		return err
+	}
+
+	_, err = fmt.Fprintln(writer, "status: FAILED\n\nfile\tproblem")
+	if err != nil {
+		return err
+	}
+	differentFiles := make([]string, 0, len(report.DifferentFiles))
+	for fileName := range report.DifferentFiles {
+		differentFiles = append(differentFiles, fileName)
+	}
+	sort.Strings(differentFiles)
+	for _, fileName := range differentFiles {
+		_, err = fmt.Fprintf(writer, "%s\t%s\n", fileName, report.DifferentFiles[fileName])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+var diffedBackupMTime = time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func makeDiffedPage(lsn uint64) []byte {
+	page := make([]byte, postgres.DatabasePageSize)
+	binary.LittleEndian.PutUint32(page[0:4], uint32(lsn>>32))
+	binary.LittleEndian.PutUint32(page[4:8], uint32(lsn))
+	return page
+}
+
+// writeDiffedFile writes the local file and returns its description with the checksum of the backed up content
+func writeDiffedFile(t *testing.T, dataDirectory, fileName string, backedUp, local []byte,
+	localMTime time.Time) internal.BackupFileDescription {
+	localPath := filepath.Join(dataDirectory, fileName)
+	require.NoError(t, os.MkdirAll(filepath.Dir(localPath), 0700))
+	require.NoError(t, os.WriteFile(localPath, local, 0600))
+	require.NoError(t, os.Chtimes(localPath, localMTime, localMTime))
+	checksum := crc32.Checksum(backedUp, crc32.MakeTable(crc32.Castagnoli))
+	return internal.BackupFileDescription{MTime: diffedBackupMTime, Crc32c: &checksum, Size: int64(len(backedUp))}
+}
+
+func TestDiffBackup(t *testing.T) {
+	dataDirectory := t.TempDir()
+	changedMTime := diffedBackupMTime.Add(time.Hour)
+	startLSN := postgres.LSN(0x2000000)
+	oldPage, newPage := makeDiffedPage(0x1000000), makeDiffedPage(0x3000000)
+	files := internal.BackupFileList{
+		"/global/pg_filenode.map": writeDiffedFile(t, dataDirectory, "/global/pg_filenode.map",
+			[]byte("map"), []byte("map"), diffedBackupMTime),
+		"/postgresql.auto.conf": writeDiffedFile(t, dataDirectory, "/postgresql.auto.conf",
+			[]byte("a = 1"), []byte("a = 2"), changedMTime),
+		"/pg_hba.conf": writeDiffedFile(t, dataDirectory, "/pg_hba.conf",
+			[]byte("local all"), []byte("local bad"), diffedBackupMTime),
+		"/base/5/16384": writeDiffedFile(t, dataDirectory, "/base/5/16384",
+			oldPage, newPage, diffedBackupMTime),
+		"/base/5/16385": writeDiffedFile(t, dataDirectory, "/base/5/16385",
+			oldPage, makeDiffedPage(0x1000001), diffedBackupMTime),
+		"/base/5/16386": writeDiffedFile(t, dataDirectory, "/base/5/16386",
+			oldPage, append(oldPage, oldPage...), diffedBackupMTime),
+		"/base/5/16387": {IsIncremented: true},
+		"/base/5":       {},
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDirectory, "base", "5"), 0700))
+	deletedChecksum := uint32(42)
+	files["/base/5/16388"] = internal.BackupFileDescription{Crc32c: &deletedChecksum}
+
+	backup := postgres.NewBackup(memory.NewFolder("", memory.NewStorage()), verifiedBackupName)
+	backup.SentinelDto = &postgres.BackupSentinelDto{BackupStartLSN: &startLSN}
+	backup.FilesMetadataDto = &postgres.FilesMetadataDto{Files: files}
+
+	report, err := postgres.DiffBackup(backup, dataDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, 7, report.VerifiedCount)
+	assert.Equal(t, 1, report.UnverifiedCount)
+	assert.ElementsMatch(t, []string{"/postgresql.auto.conf", "/base/5/16384", "/base/5/16388"},
+		diffedFileNames(report.ChangedFiles))
+	assert.ElementsMatch(t, []string{"/pg_hba.conf", "/base/5/16385", "/base/5/16386"}, diffedFileNames(report.DifferentFiles))
+	assert.Contains(t, report.DifferentFiles["/base/5/16386"], "size mismatch")
+	assert.False(t, report.IsOk())
+
+	var output bytes.Buffer
+	require.NoError(t, postgres.WriteBackupDiffReport(report, &output))
+	assert.Contains(t, output.String(), "status: FAILED")
+	assert.Contains(t, output.String(), "/pg_hba.conf")
+}
+
+func TestDiffBackup_WithoutFilesMetadata(t *testing.T) {
+	backup := postgres.NewBackup(memory.NewFolder("", memory.NewStorage()), verifiedBackupName)
+	backup.SentinelDto = &postgres.BackupSentinelDto{FilesMetadataDisabled: true}
+	backup.FilesMetadataDto = &postgres.FilesMetadataDto{}
+
+	_, err := postgres.DiffBackup(backup, t.TempDir())
+	assert.Error(t, err)
+}
+
+func diffedFileNames(values map[string]string) []string {
+	result := make([]string, 0, len(values))
+	for key := range values {
+		result = append(result, key)
+	}
+	return result
+}
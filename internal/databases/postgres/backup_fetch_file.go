@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"archive/tar"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// GetPgFileFetcher returns the fetcher restoring the single file of the backup into the destination directory
+func GetPgFileFetcher(dbDataDirectory, fileName string) func(folder storage.Folder, backup internal.Backup) {
+	return func(folder storage.Folder, backup internal.Backup) {
+		err := FetchBackupFile(ToPgBackup(backup), fileName, dbDataDirectory)
+		internal.FatalfOnError("Failed to fetch file: %v\n", err)
+	}
+}
+
+// FetchBackupFile restores the single file of the backup at its path under the destination directory.
+// The tar member of the file is found by the files metadata and extracted whole keeping only the file,
+// as the entries of the compressed members can't be reached without decompressing the ones before them.
+// The unchanged files of the delta backup are fetched from its base backups.
+func FetchBackupFile(backup Backup, fileName, dbDataDirectory string) error {
+	sentinelDto, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		return err
+	}
+	fileName = path.Clean("/" + fileName)
+	if description, ok := filesMeta.Files[fileName]; ok && sentinelDto.IsIncremental() {
+		if description.IsIncremented {
+			return errors.Errorf("file '%s' is stored as an increment in delta backup %s, "+
+				"restore it with the delta chain using --mask", fileName, backup.Name)
+		}
+		if description.IsSkipped {
+			tracelog.InfoLogger.Printf("File '%s' is unchanged since delta base %s\n", fileName, *sentinelDto.IncrementFrom)
+			return FetchBackupFile(NewBackup(backup.Folder, *sentinelDto.IncrementFrom), fileName, dbDataDirectory)
+		}
+	}
+
+	tarName, memberName, err := backup.findFileTar(filesMeta, fileName)
+	if err != nil {
+		return err
+	}
+	tarInterpreter := NewFileTarInterpreter(dbDataDirectory, sentinelDto, filesMeta, map[string]bool{fileName: true}, false)
+	tarInterpreter.Ownership, err = ConfigureRestoreOwnership()
+	if err != nil {
+		return err
+	}
+	fileInterpreter := &singleFileTarInterpreter{FileTarInterpreter: tarInterpreter, memberName: memberName}
+
+	tracelog.InfoLogger.Printf("Extracting '%s' from tar member %s\n", fileName, tarName)
+	err = internal.ExtractAll(fileInterpreter, []internal.ReaderMaker{backup.newTarReaderMaker(tarName, filesMeta)})
+	if err != nil {
+		return err
+	}
+	if !fileInterpreter.found {
+		return errors.Errorf("file '%s' is not found in tar member %s", memberName, tarName)
+	}
+	return tarInterpreter.SyncDirectories()
+}
+
+// findFileTar returns the tar member storing the file and the name of the file in it,
+// which differs from the file name for the deduplicated copies
+func (backup *Backup) findFileTar(filesMeta FilesMetadataDto, fileName string) (tarName, memberName string, err error) {
+	memberName = fileName
+	if description, ok := filesMeta.Files[fileName]; ok && description.DedupOf != "" {
+		memberName = description.DedupOf
+	}
+	if description, ok := filesMeta.Files[fileName]; ok && len(description.Parts) > 0 {
+		return "", "", newSplitFileError(fileName, "fetched alone, restore it using --mask")
+	}
+	// pg_control is uploaded in its own tar member, which is not recorded in the files metadata
+	if memberName == PgControlPath {
+		tarNames, err := backup.GetTarNames()
+		if err != nil {
+			return "", "", err
+		}
+		for _, tarName := range tarNames {
+			if strings.HasPrefix(tarName, "pg_control.tar") {
+				return tarName, memberName, nil
+			}
+		}
+		return "", "", newPgControlNotFoundError()
+	}
+	if len(filesMeta.TarFileSets) == 0 {
+		return "", "", errors.Errorf("backup %s has no files metadata to find the tar member of '%s', "+
+			"restore it using --mask", backup.Name, fileName)
+	}
+	for tarName, fileNames := range filesMeta.TarFileSets {
+		for _, name := range fileNames {
+			if name == memberName {
+				return tarName, memberName, nil
+			}
+		}
+	}
+	return "", "", errors.Errorf("file '%s' is not found in backup %s", fileName, backup.Name)
+}
+
+// singleFileTarInterpreter extracts only the tar entry of the file and records that it is found
+type singleFileTarInterpreter struct {
+	*FileTarInterpreter
+	memberName string
+	found      bool
+}
+
+func (interpreter *singleFileTarInterpreter) Interpret(fileReader io.Reader, fileInfo *tar.Header) error {
+	if fileInfo.Name != interpreter.memberName {
+		return nil
+	}
+	interpreter.found = true
+	return interpreter.FileTarInterpreter.Interpret(fileReader, fileInfo)
+}
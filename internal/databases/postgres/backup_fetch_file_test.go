@@ -0,0 +1,135 @@
+package postgres_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// putFetchedTar puts the tar member with the files of the backup, compressed if the tar name ends with .lz4
+func putFetchedTar(t *testing.T, folder storage.Folder, backupName, tarName string, files map[string][]byte) []string {
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	var buffer bytes.Buffer
+	var writer io.WriteCloser = &nopWriteCloser{&buffer}
+	if filepath.Ext(tarName) == "."+lz4.FileExtension {
+		writer = lz4.Compressor{}.NewWriter(&buffer)
+	}
+	tarWriter := tar.NewWriter(writer)
+	for _, fileName := range fileNames {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: fileName, Mode: 0600, Size: int64(len(files[fileName]))}))
+		_, err := tarWriter.Write(files[fileName])
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, writer.Close())
+	require.NoError(t, folder.PutObject(backupName+internal.TarPartitionFolderName+tarName, &buffer))
+	return fileNames
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (writer *nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestFetchBackupFile_ExtractsCompressedTar(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	files := map[string][]byte{
+		"/base/1/1259": []byte("other file"),
+		"/base/1/1261": []byte("fetched file"),
+	}
+	backup := newVerifiedBackup(folder, map[string][]string{
+		"part_001.tar.lz4": putFetchedTar(t, folder, verifiedBackupName, "part_001.tar.lz4", files),
+	})
+	dbDataDirectory := t.TempDir()
+
+	err := postgres.FetchBackupFile(backup, "/base/1/1261", dbDataDirectory)
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dbDataDirectory, "base", "1", "1261"))
+	require.NoError(t, err)
+	assert.Equal(t, "fetched file", string(content))
+	assert.NoFileExists(t, filepath.Join(dbDataDirectory, "base", "1", "1259"))
+}
+
+func TestFetchBackupFile_DedupCopy(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	backup := newVerifiedBackup(folder, map[string][]string{
+		"part_001.tar": putFetchedTar(t, folder, verifiedBackupName, "part_001.tar",
+			map[string][]byte{"/base/1/1259": []byte("same content")}),
+	})
+	backup.FilesMetadataDto.Files = internal.BackupFileList{
+		"/base/1/1259": {},
+		"/base/1/1260": {DedupOf: "/base/1/1259"},
+	}
+	dbDataDirectory := t.TempDir()
+
+	err := postgres.FetchBackupFile(backup, "base/1/1260", dbDataDirectory)
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dbDataDirectory, "base", "1", "1260"))
+	require.NoError(t, err)
+	assert.Equal(t, "same content", string(content))
+	assert.NoFileExists(t, filepath.Join(dbDataDirectory, "base", "1", "1259"))
+}
+
+func TestFetchBackupFile_DeltaSkippedFile(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	baseName := "base_000000010000000000000001"
+	baseFiles := putFetchedTar(t, folder, baseName, "part_001.tar",
+		map[string][]byte{"/base/1/1259": []byte("unchanged file")})
+	require.NoError(t, internal.UploadDto(folder, postgres.BackupSentinelDto{}, internal.SentinelNameFromBackup(baseName)))
+	require.NoError(t, internal.UploadDto(folder, postgres.FilesMetadataDto{
+		TarFileSets: map[string][]string{"part_001.tar": baseFiles},
+	}, baseName+"/"+postgres.FilesMetadataName))
+
+	baseLSN := postgres.LSN(0x1000000)
+	count := 1
+	backup := newVerifiedBackup(folder, map[string][]string{})
+	backup.SentinelDto.IncrementFrom = &baseName
+	backup.SentinelDto.IncrementFullName = &baseName
+	backup.SentinelDto.IncrementFromLSN = &baseLSN
+	backup.SentinelDto.IncrementCount = &count
+	backup.FilesMetadataDto.Files = internal.BackupFileList{
+		"/base/1/1259": {IsSkipped: true},
+		"/base/1/1260": {IsIncremented: true},
+	}
+	dbDataDirectory := t.TempDir()
+
+	err := postgres.FetchBackupFile(backup, "base/1/1259", dbDataDirectory)
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dbDataDirectory, "base", "1", "1259"))
+	require.NoError(t, err)
+	assert.Equal(t, "unchanged file", string(content))
+
+	err = postgres.FetchBackupFile(backup, "base/1/1260", dbDataDirectory)
+	assert.Error(t, err)
+}
+
+func TestFetchBackupFile_NotFound(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	backup := newVerifiedBackup(folder, map[string][]string{
+		"part_001.tar": putFetchedTar(t, folder, verifiedBackupName, "part_001.tar",
+			map[string][]byte{"/base/1/1259": []byte("other file")}),
+	})
+
+	err := postgres.FetchBackupFile(backup, "base/1/1261", t.TempDir())
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/pkg/errors"
@@ -63,7 +64,7 @@ func chooseTablespaceSpecification(sentinelDtoSpec, spec *TablespaceSpec) *Table
 // TODO : unit tests
 // deltaFetchRecursion function composes Backup object and recursively searches for necessary base backup
 func deltaFetchRecursionOld(backup Backup, folder storage.Folder, dbDataDirectory string,
-	tablespaceSpec *TablespaceSpec, filesToUnwrap map[string]bool) error {
+	tablespaceSpec *TablespaceSpec, filesToUnwrap map[string]bool, restoreFilter *RestoreFilter) error {
 	sentinelDto, filesMetaDto, err := backup.GetSentinelAndFilesMetadata()
 	if err != nil {
 		return err
@@ -79,7 +80,7 @@ func deltaFetchRecursionOld(backup Backup, folder storage.Folder, dbDataDirector
 			return err
 		}
 		incrementFrom := NewBackup(folder.GetSubFolder(utility.BaseBackupPath), *sentinelDto.IncrementFrom)
-		err = deltaFetchRecursionOld(incrementFrom, folder, dbDataDirectory, tablespaceSpec, baseFilesToUnwrap)
+		err = deltaFetchRecursionOld(incrementFrom, folder, dbDataDirectory, tablespaceSpec, baseFilesToUnwrap, restoreFilter)
 		if err != nil {
 			return err
 		}
@@ -89,25 +90,71 @@ func deltaFetchRecursionOld(backup Backup, folder storage.Folder, dbDataDirector
 			*(sentinelDto.BackupStartLSN))
 	}
 
-	return backup.unwrapToEmptyDirectory(dbDataDirectory, sentinelDto, filesMetaDto, filesToUnwrap, false)
+	return backup.unwrapToEmptyDirectory(dbDataDirectory, sentinelDto, filesMetaDto, filesToUnwrap, false, restoreFilter)
 }
 
-func GetPgFetcherOld(dbDataDirectory, fileMask, restoreSpecPath string) func(rootFolder storage.Folder, backup internal.Backup) {
+func GetPgFetcherOld(dbDataDirectory, fileMask, restoreSpecPath string, tablespaceMapping TablespaceMapping,
+	flattenTablespaces bool, restoreOnly []string, skipRelFileNodes []uint32,
+	catalogsOnly bool) func(rootFolder storage.Folder, backup internal.Backup) {
 	return func(rootFolder storage.Folder, backup internal.Backup) {
 		pgBackup := ToPgBackup(backup)
 		filesToUnwrap, err := pgBackup.GetFilesToUnwrap(fileMask)
-		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
-
-		var spec *TablespaceSpec
-		if restoreSpecPath != "" {
-			spec = &TablespaceSpec{}
-			err := readRestoreSpec(restoreSpecPath, spec)
-			errMessege := fmt.Sprintf("Invalid restore specification path %s\n", restoreSpecPath)
-			tracelog.ErrorLogger.FatalfOnError(errMessege, err)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		restoreFilter, err := newBackupRestoreFilter(pgBackup, restoreOnly, skipRelFileNodes, catalogsOnly)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+
+		spec, err := getRestoreTablespaceSpec(pgBackup, restoreSpecPath, tablespaceMapping, flattenTablespaces)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		precheckRestoreDiskSpace(pgBackup, utility.ResolveSymlink(dbDataDirectory), spec, filesToUnwrap, restoreFilter)
+		err = deltaFetchRecursionOld(pgBackup, rootFolder, utility.ResolveSymlink(dbDataDirectory), spec, filesToUnwrap, restoreFilter)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		if flattenTablespaces {
+			err = removeTablespaceMap(utility.ResolveSymlink(dbDataDirectory))
+			internal.FatalfOnError("Failed to fetch backup: %v\n", err)
 		}
-		err = deltaFetchRecursionOld(pgBackup, rootFolder, utility.ResolveSymlink(dbDataDirectory), spec, filesToUnwrap)
-		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
+		if catalogsOnly {
+			err = writeCatalogsOnlyConfig(utility.ResolveSymlink(dbDataDirectory))
+			internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		}
+	}
+}
+
+// GetPgStreamFetcher returns the fetcher which writes the full backup as a tar stream into the output
+func GetPgStreamFetcher(output io.Writer, fileMask string) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		pgBackup := ToPgBackup(backup)
+		sentinelDto, filesMetaDto, err := pgBackup.GetSentinelAndFilesMetadata()
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		if sentinelDto.IsIncremental() {
+			internal.FatalfWithExitCode(internal.ExitCodeUsage, "Failed to fetch backup: %s is a delta backup, "+
+				"only the full backups can be streamed\n", pgBackup.Name)
+		}
+		filesToUnwrap, err := pgBackup.GetFilesToUnwrap(fileMask)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+
+		err = pgBackup.unwrapToStream(output, filesMetaDto, filesToUnwrap)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+	}
+}
+
+// newBackupRestoreFilter resolves the requested database names using the files metadata of the target backup
+func newBackupRestoreFilter(backup Backup, restoreOnly []string, skipRelFileNodes []uint32,
+	catalogsOnly bool) (*RestoreFilter, error) {
+	if !catalogsOnly && len(restoreOnly) == 0 {
+		return NewRestoreFilter(nil, nil, skipRelFileNodes)
+	}
+	_, filesMetaDto, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if catalogsOnly {
+		if len(filesMetaDto.CatalogRelFileNodes) == 0 {
+			tracelog.WarningLogger.Printf("Backup %s has no recorded catalog relfilenodes, "+
+				"the catalogs rewritten by VACUUM FULL or CLUSTER will be restored empty", backup.Name)
+		}
+		return NewCatalogsOnlyRestoreFilter(filesMetaDto.CatalogRelFileNodes), nil
 	}
+	return NewRestoreFilter(filesMetaDto.DatabasesByNames, restoreOnly, skipRelFileNodes)
 }
 
 func GetBaseFilesToUnwrap(backupFileStates internal.BackupFileList, currentFilesToUnwrap map[string]bool) (map[string]bool, error) {
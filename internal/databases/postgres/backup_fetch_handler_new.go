@@ -1,41 +1,46 @@
 package postgres
 
 import (
-	"fmt"
-
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
 
-func GetPgFetcherNew(dbDataDirectory, fileMask, restoreSpecPath string, skipRedundantTars bool,
-) func(folder storage.Folder, backup internal.Backup) {
+func GetPgFetcherNew(dbDataDirectory, fileMask, restoreSpecPath string, tablespaceMapping TablespaceMapping,
+	flattenTablespaces bool, skipRedundantTars bool, restoreOnly []string,
+	skipRelFileNodes []uint32, catalogsOnly bool) func(folder storage.Folder, backup internal.Backup) {
 	return func(folder storage.Folder, backup internal.Backup) {
 		pgBackup := ToPgBackup(backup)
 		filesToUnwrap, err := pgBackup.GetFilesToUnwrap(fileMask)
-		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		restoreFilter, err := newBackupRestoreFilter(pgBackup, restoreOnly, skipRelFileNodes, catalogsOnly)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
 
-		var spec *TablespaceSpec
-		if restoreSpecPath != "" {
-			spec = &TablespaceSpec{}
-			err := readRestoreSpec(restoreSpecPath, spec)
-			errMessage := fmt.Sprintf("Invalid restore specification path %s\n", restoreSpecPath)
-			tracelog.ErrorLogger.FatalfOnError(errMessage, err)
-		}
+		spec, err := getRestoreTablespaceSpec(pgBackup, restoreSpecPath, tablespaceMapping, flattenTablespaces)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
 
 		// directory must be empty before starting a deltaFetch
 		isEmpty, err := isDirectoryEmpty(dbDataDirectory)
-		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
 
 		if !isEmpty {
-			tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n",
+			internal.FatalfOnError("Failed to fetch backup: %v\n",
 				NewNonEmptyDBDataDirectoryError(dbDataDirectory))
 		}
+		precheckRestoreDiskSpace(pgBackup, utility.ResolveSymlink(dbDataDirectory), spec, filesToUnwrap, restoreFilter)
 		config := NewFetchConfig(pgBackup.Name,
-			utility.ResolveSymlink(dbDataDirectory), folder, spec, filesToUnwrap, skipRedundantTars)
+			utility.ResolveSymlink(dbDataDirectory), folder, spec, filesToUnwrap, skipRedundantTars, restoreFilter)
 		err = deltaFetchRecursionNew(config)
-		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		if flattenTablespaces {
+			err = removeTablespaceMap(utility.ResolveSymlink(dbDataDirectory))
+			internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		}
+		if catalogsOnly {
+			err = writeCatalogsOnlyConfig(utility.ResolveSymlink(dbDataDirectory))
+			internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		}
 	}
 }
 
@@ -59,7 +64,7 @@ func deltaFetchRecursionNew(cfg *FetchConfig) error {
 			return err
 		}
 		unwrapResult, err := backup.unwrapNew(cfg.dbDataDirectory, sentinelDto, filesMetaDto, cfg.filesToUnwrap,
-			false, cfg.skipRedundantTars)
+			false, cfg.skipRedundantTars, cfg.restoreFilter)
 		if err != nil {
 			return err
 		}
@@ -85,6 +90,6 @@ func deltaFetchRecursionNew(cfg *FetchConfig) error {
 	tracelog.InfoLogger.Printf("%s reached. Applying base backup... \n",
 		*(sentinelDto.BackupStartLSN))
 	_, err = backup.unwrapNew(cfg.dbDataDirectory, sentinelDto, filesMetaDto, cfg.filesToUnwrap,
-		false, cfg.skipRedundantTars)
+		false, cfg.skipRedundantTars, cfg.restoreFilter)
 	return err
 }
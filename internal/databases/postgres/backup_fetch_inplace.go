@@ -0,0 +1,274 @@
+package postgres
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const postmasterPidFilename = "postmaster.pid"
+
+type InplaceSystemIdentifierMismatchError struct {
+	error
+}
+
+func newInplaceSystemIdentifierMismatchError(backupSystemIdentifier, localSystemIdentifier uint64) error {
+	return InplaceSystemIdentifierMismatchError{errors.Errorf(
+		"backup system identifier %d does not match the system identifier %d of the data directory, "+
+			"in-place restore is possible only into the data directory of the same cluster",
+		backupSystemIdentifier, localSystemIdentifier)}
+}
+
+func (err InplaceSystemIdentifierMismatchError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err InplaceSystemIdentifierMismatchError) ExitCode() int {
+	return internal.ExitCodeUsage
+}
+
+// inplaceRestore collects the files of the backup met during the in-place restore
+type inplaceRestore struct {
+	mutex          sync.Mutex
+	restoredFiles  map[string]bool
+	unchangedCount int
+}
+
+func newInplaceRestore() *inplaceRestore {
+	return &inplaceRestore{restoredFiles: make(map[string]bool)}
+}
+
+func (inplace *inplaceRestore) addRestoredFile(fileName string) {
+	inplace.mutex.Lock()
+	defer inplace.mutex.Unlock()
+	inplace.restoredFiles[fileName] = true
+}
+
+func (inplace *inplaceRestore) addUnchangedFile() {
+	inplace.mutex.Lock()
+	defer inplace.mutex.Unlock()
+	inplace.unchangedCount++
+}
+
+// GetPgInplaceFetcher returns the fetcher which restores the full backup into the existing data directory
+// of the same cluster: the files identical to the backup ones are not rewritten
+// and the files which are not in the backup are removed
+func GetPgInplaceFetcher(dbDataDirectory string) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		pgBackup := ToPgBackup(backup)
+		dbDataDirectory = utility.ResolveSymlink(dbDataDirectory)
+		sentinelDto, filesMetaDto, err := pgBackup.GetSentinelAndFilesMetadata()
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		if sentinelDto.IsIncremental() {
+			internal.FatalfWithExitCode(internal.ExitCodeUsage, "Failed to fetch backup: %s is a delta backup, "+
+				"only the full backups can be restored in place\n", pgBackup.Name)
+		}
+		err = checkDBDirectoryForInplaceUnwrap(dbDataDirectory, sentinelDto)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		filesToUnwrap, err := pgBackup.GetFilesToUnwrap("")
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+
+		err = pgBackup.unwrapInplace(dbDataDirectory, sentinelDto, filesMetaDto, filesToUnwrap)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+	}
+}
+
+// checkDBDirectoryForInplaceUnwrap checks that the data directory belongs to the stopped cluster the backup is taken from
+func checkDBDirectoryForInplaceUnwrap(dbDataDirectory string, sentinelDto BackupSentinelDto) error {
+	if _, err := os.Stat(filepath.Join(dbDataDirectory, postmasterPidFilename)); err == nil {
+		return errors.Errorf("%s exists in %s, the cluster must be stopped before the in-place restore",
+			postmasterPidFilename, dbDataDirectory)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if sentinelDto.SystemIdentifier == nil {
+		return errors.New("the backup has no system identifier, " +
+			"unable to verify that it matches the cluster of the data directory")
+	}
+	pgControlData, err := ExtractPgControl(dbDataDirectory)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read pg_control of the data directory")
+	}
+	if pgControlData.GetSystemIdentifier() != *sentinelDto.SystemIdentifier {
+		return newInplaceSystemIdentifierMismatchError(*sentinelDto.SystemIdentifier, pgControlData.GetSystemIdentifier())
+	}
+	return nil
+}
+
+// unwrapInplace extracts the backup over the existing data directory and removes the local files
+// which are not in the backup
+func (backup *Backup) unwrapInplace(dbDataDirectory string, sentinelDto BackupSentinelDto,
+	filesMeta FilesMetadataDto, filesToUnwrap map[string]bool) error {
+	if sentinelDto.TablespaceSpec != nil && !sentinelDto.TablespaceSpec.empty() {
+		err := ensureTablespacePaths(*sentinelDto.TablespaceSpec)
+		if err != nil {
+			return err
+		}
+	}
+
+	tarInterpreter := NewFileTarInterpreter(dbDataDirectory, sentinelDto, filesMeta, filesToUnwrap, false)
+	tarInterpreter.inplace = newInplaceRestore()
+	var err error
+	tarInterpreter.Ownership, err = ConfigureRestoreOwnership()
+	if err != nil {
+		return err
+	}
+	err = backup.extractTars(tarInterpreter, sentinelDto, filesMeta, filesToUnwrap)
+	if err != nil {
+		return err
+	}
+
+	removedCount, err := removeFilesNotInBackup(dbDataDirectory, tarInterpreter.inplace.restoredFiles, filesMeta)
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("In-place restore: %d files of %d were unchanged, %d local files not in the backup removed\n",
+		tarInterpreter.inplace.unchangedCount, len(tarInterpreter.inplace.restoredFiles), removedCount)
+	return nil
+}
+
+// ensureTablespacePaths creates the tablespace symlinks as setTablespacePaths does,
+// the existing symlinks must point to the locations of the backup
+func ensureTablespacePaths(spec TablespaceSpec) error {
+	basePrefix, ok := spec.BasePrefix()
+	if !ok {
+		return fmt.Errorf("tablespace specification base path is not set")
+	}
+	err := os.MkdirAll(filepath.Join(basePrefix, TablespaceFolder), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating pg_tblspc folder %v", err)
+	}
+	for _, location := range spec.tablespaceLocations() {
+		err = os.MkdirAll(location.Location, 0755)
+		if err != nil {
+			return fmt.Errorf("error creating folder for tablespace %v", err)
+		}
+		symlinkPath := filepath.Join(basePrefix, location.Symlink)
+		target, err := os.Readlink(symlinkPath)
+		if err == nil {
+			if filepath.Clean(target) != filepath.Clean(location.Location) {
+				return errors.Errorf("tablespace symlink %s points to %s instead of %s",
+					symlinkPath, target, location.Location)
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return errors.Wrapf(err, "tablespace %s is not a symlink", symlinkPath)
+		}
+		err = os.Symlink(location.Location, symlinkPath)
+		if err != nil {
+			return fmt.Errorf("error creating tablespace symkink %v", err)
+		}
+	}
+	return nil
+}
+
+// isLocalFileUnchanged checks that the local file has the size and the checksum of the backup one,
+// the files without the recorded checksum are considered changed
+func (tarInterpreter *FileTarInterpreter) isLocalFileUnchanged(fileInfo *tar.Header, targetPath string) (bool, error) {
+	fileDescription, ok := tarInterpreter.FilesMetadata.Files[fileInfo.Name]
+	if !ok || fileDescription.Crc32c == nil || fileDescription.IsIncremented {
+		return false, nil
+	}
+	localFileInfo, err := os.Lstat(targetPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to stat file: '%s'", targetPath)
+	}
+	if !localFileInfo.Mode().IsRegular() || localFileInfo.Size() != fileInfo.Size {
+		return false, nil
+	}
+
+	file, err := os.Open(targetPath)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to open file: '%s'", targetPath)
+	}
+	defer utility.LoggedClose(file, "")
+	checksumReader := newChecksumReader(file)
+	if _, err = io.Copy(io.Discard, checksumReader); err != nil {
+		return false, errors.Wrapf(err, "failed to read file: '%s'", targetPath)
+	}
+	return checksumReader.checksum() == *fileDescription.Crc32c, nil
+}
+
+// skipUnchangedFile leaves the local file untouched if it is identical to the backup one,
+// otherwise the local symlink is removed since the file is opened for writing with O_TRUNC, which follows it
+func (tarInterpreter *FileTarInterpreter) skipUnchangedFile(fileInfo *tar.Header, targetPath string) (bool, error) {
+	unchanged, err := tarInterpreter.isLocalFileUnchanged(fileInfo, targetPath)
+	if err != nil {
+		return false, err
+	}
+	if !unchanged {
+		return false, removeLocalLink(targetPath)
+	}
+	tracelog.DebugLogger.Printf("'%s' is unchanged\n", fileInfo.Name)
+	tarInterpreter.inplace.addUnchangedFile()
+	if err = os.Chmod(targetPath, os.FileMode(fileInfo.Mode)); err != nil {
+		return false, errors.Wrap(err, "Interpret: chmod failed")
+	}
+	return true, nil
+}
+
+// removeLocalLink removes the existing symlink to replace it with the file or the link of the backup
+func removeLocalLink(targetPath string) error {
+	localFileInfo, err := os.Lstat(targetPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat file: '%s'", targetPath)
+	}
+	if localFileInfo.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	return errors.Wrapf(os.Remove(targetPath), "failed to remove symlink: '%s'", targetPath)
+}
+
+// removeFilesNotInBackup removes the regular files and the symlinks of the data directory
+// and the tablespaces which are neither restored nor listed in the files metadata
+func removeFilesNotInBackup(dbDataDirectory string, restoredFiles map[string]bool,
+	filesMeta FilesMetadataDto) (int, error) {
+	removedCount := 0
+	var removeFromDirectory func(directory, namePrefix string) error
+	removeFromDirectory = func(directory, namePrefix string) error {
+		return filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			relativePath, err := filepath.Rel(directory, path)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(filepath.Join(namePrefix, relativePath))
+			if info.Mode()&os.ModeSymlink != 0 && filepath.Dir(name) == TablespaceFolder {
+				// the tablespace files are checked in its location
+				return removeFromDirectory(path+"/", name)
+			}
+			if _, ok := filesMeta.Files[name]; ok || restoredFiles[name] {
+				return nil
+			}
+			tracelog.DebugLogger.Printf("Removing '%s' which is not in the backup\n", name)
+			if err = os.Remove(path); err != nil {
+				return errors.Wrapf(err, "failed to remove '%s'", path)
+			}
+			removedCount++
+			return nil
+		})
+	}
+	err := removeFromDirectory(dbDataDirectory, "")
+	return removedCount, err
+}
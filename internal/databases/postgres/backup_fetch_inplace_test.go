@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+)
+
+func newInplaceTestInterpreter(dbDirectory string, files map[string]string) *FileTarInterpreter {
+	filesMeta := FilesMetadataDto{Files: make(internal.BackupFileList)}
+	for name, content := range files {
+		checksum := crc32.Checksum([]byte(content), crc32cTable)
+		filesMeta.Files[name] = internal.BackupFileDescription{Size: int64(len(content)), Crc32c: &checksum, MTime: time.Now()}
+	}
+	tarInterpreter := NewFileTarInterpreter(dbDirectory, BackupSentinelDto{}, filesMeta, nil, false)
+	tarInterpreter.inplace = newInplaceRestore()
+	return tarInterpreter
+}
+
+func interpretRegularFile(t *testing.T, tarInterpreter *FileTarInterpreter, name, content string) {
+	err := tarInterpreter.Interpret(bytes.NewBufferString(content),
+		&tar.Header{Name: name, Typeflag: tar.TypeReg, Size: int64(len(content)), Mode: 0600})
+	require.NoError(t, err)
+}
+
+func TestInplaceInterpret_SkipsUnchangedFiles(t *testing.T) {
+	dbDirectory := t.TempDir()
+	files := map[string]string{"base/1/1": "unchanged", "base/1/2": "new contents", "base/1/3": "created"}
+	require.NoError(t, os.MkdirAll(filepath.Join(dbDirectory, "base/1"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dbDirectory, "base/1/1"), []byte("unchanged"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dbDirectory, "base/1/2"), []byte("old contents"), 0600))
+
+	tarInterpreter := newInplaceTestInterpreter(dbDirectory, files)
+	for name, content := range files {
+		interpretRegularFile(t, tarInterpreter, name, content)
+	}
+
+	for name, content := range files {
+		restored, err := os.ReadFile(filepath.Join(dbDirectory, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(restored))
+	}
+	assert.Equal(t, 1, tarInterpreter.inplace.unchangedCount)
+	assert.Len(t, tarInterpreter.inplace.restoredFiles, 3)
+}
+
+func TestInplaceInterpret_ReplacesLocalSymlink(t *testing.T) {
+	dbDirectory := t.TempDir()
+	outsideFile := filepath.Join(t.TempDir(), "outside")
+	require.NoError(t, os.WriteFile(outsideFile, []byte("outside"), 0600))
+	require.NoError(t, os.Symlink(outsideFile, filepath.Join(dbDirectory, "postgresql.conf")))
+
+	tarInterpreter := newInplaceTestInterpreter(dbDirectory, map[string]string{"postgresql.conf": "restored"})
+	interpretRegularFile(t, tarInterpreter, "postgresql.conf", "restored")
+
+	restored, err := os.ReadFile(filepath.Join(dbDirectory, "postgresql.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "restored", string(restored))
+	outside, err := os.ReadFile(outsideFile)
+	require.NoError(t, err)
+	assert.Equal(t, "outside", string(outside), "the symlink target must not be overwritten")
+}
+
+func TestRemoveFilesNotInBackup(t *testing.T) {
+	dbDirectory := t.TempDir()
+	tablespaceLocation := t.TempDir()
+	for _, name := range []string{"base/1/1", "base/1/2", "pg_wal/000000010000000000000001", "global/pg_control"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dbDirectory, name)), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dbDirectory, name), []byte("data"), 0600))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(dbDirectory, TablespaceFolder), 0700))
+	require.NoError(t, os.Symlink(tablespaceLocation, filepath.Join(dbDirectory, TablespaceFolder, "16384")))
+	for _, name := range []string{"PG_14/1/16385", "PG_14/1/16386"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(tablespaceLocation, name)), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(tablespaceLocation, name), []byte("data"), 0600))
+	}
+
+	filesMeta := FilesMetadataDto{Files: make(internal.BackupFileList)}
+	filesMeta.Files["base/1/1"] = internal.BackupFileDescription{}
+	restoredFiles := map[string]bool{"global/pg_control": true, "pg_tblspc/16384/PG_14/1/16385": true}
+	removedCount, err := removeFilesNotInBackup(dbDirectory, restoredFiles, filesMeta)
+	require.NoError(t, err)
+	assert.Equal(t, 3, removedCount)
+
+	for path, expected := range map[string]bool{
+		filepath.Join(dbDirectory, "base/1/1"):                        true,
+		filepath.Join(dbDirectory, "global/pg_control"):               true,
+		filepath.Join(dbDirectory, TablespaceFolder, "16384"):         true,
+		filepath.Join(tablespaceLocation, "PG_14/1/16385"):            true,
+		filepath.Join(dbDirectory, "base/1/2"):                        false,
+		filepath.Join(dbDirectory, "pg_wal/000000010000000000000001"): false,
+		filepath.Join(tablespaceLocation, "PG_14/1/16386"):            false,
+	} {
+		_, err := os.Lstat(path)
+		assert.Equal(t, expected, err == nil, path)
+	}
+}
+
+func TestCheckDBDirectoryForInplaceUnwrap(t *testing.T) {
+	dbDirectory := t.TempDir()
+	writeTestPgControl(t, dbDirectory, 9876)
+	systemIdentifier, otherSystemIdentifier := uint64(9876), uint64(1234)
+
+	assert.NoError(t, checkDBDirectoryForInplaceUnwrap(dbDirectory, BackupSentinelDto{SystemIdentifier: &systemIdentifier}))
+	err := checkDBDirectoryForInplaceUnwrap(dbDirectory, BackupSentinelDto{SystemIdentifier: &otherSystemIdentifier})
+	assert.IsType(t, InplaceSystemIdentifierMismatchError{}, err)
+	assert.Error(t, checkDBDirectoryForInplaceUnwrap(dbDirectory, BackupSentinelDto{}))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dbDirectory, postmasterPidFilename), []byte("1"), 0600))
+	assert.Error(t, checkDBDirectoryForInplaceUnwrap(dbDirectory, BackupSentinelDto{SystemIdentifier: &systemIdentifier}))
+}
@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"archive/tar"
+	"io"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// ExtractFailure is the tar member which backup-fetch --keep-going failed to extract after all the retries
+type ExtractFailure struct {
+	BackupName string
+	TarName    string
+	Err        error
+	// LostFiles are the files of the tar member which are not restored, they are unknown
+	// for the backups without the files metadata
+	LostFiles []string
+}
+
+// extractFailures collects the failures of the keep-going restore across the backups of the delta chain
+type extractFailures struct {
+	mutex    sync.Mutex
+	failures []ExtractFailure
+}
+
+func (failures *extractFailures) add(failure ExtractFailure) {
+	failures.mutex.Lock()
+	defer failures.mutex.Unlock()
+	failures.failures = append(failures.failures, failure)
+}
+
+func (failures *extractFailures) take() []ExtractFailure {
+	failures.mutex.Lock()
+	defer failures.mutex.Unlock()
+	taken := failures.failures
+	failures.failures = nil
+	return taken
+}
+
+var keepGoingFailures = &extractFailures{}
+
+// GetPgKeepGoingFetcher wraps the fetcher to report the tar members and the files the keep-going restore
+// failed to extract. The restore fails with the data corruption exit code after the summary if there are any.
+func GetPgKeepGoingFetcher(fetcher func(rootFolder storage.Folder, backup internal.Backup),
+) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		fetcher(rootFolder, backup)
+		failures := keepGoingFailures.take()
+		if len(failures) == 0 {
+			return
+		}
+		lostFilesCount := reportExtractFailures(failures)
+		internal.FatalfWithExitCode(internal.ExitCodeDataCorruption, "Backup %s is restored partially: "+
+			"%d tar members failed to extract, %d files are not restored\n", backup.Name, len(failures), lostFilesCount)
+	}
+}
+
+// reportExtractFailures logs the failed tar members with the files which are not restored
+// and returns the number of the files
+func reportExtractFailures(failures []ExtractFailure) (lostFilesCount int) {
+	for _, failure := range failures {
+		tracelog.ErrorLogger.Printf("Failed to extract tar member %s of backup %s: %v\n",
+			failure.TarName, failure.BackupName, failure.Err)
+		if failure.LostFiles == nil {
+			tracelog.ErrorLogger.Printf("The files of %s are unknown, the backup has no files metadata\n", failure.TarName)
+			continue
+		}
+		for _, fileName := range failure.LostFiles {
+			tracelog.ErrorLogger.Printf("Not restored: %s\n", fileName)
+		}
+		lostFilesCount += len(failure.LostFiles)
+	}
+	return lostFilesCount
+}
+
+// extractBackupTars extracts the tar members of the backup, with WALG_FETCH_KEEP_GOING the members which fail
+// after all the retries are recorded for the summary instead of failing the restore
+func (backup *Backup) extractBackupTars(tarInterpreter internal.TarInterpreter, tarsToExtract []internal.ReaderMaker,
+	filesMeta FilesMetadataDto, filesToUnwrap map[string]bool, progress *internal.ExtractProgress) error {
+	if !viper.GetBool(internal.FetchKeepGoingSetting) {
+		return internal.ExtractAllWithProgress(tarInterpreter, tarsToExtract, progress)
+	}
+	recorder := &restoredFilesRecorder{TarInterpreter: tarInterpreter, restoredFiles: make(map[string]bool)}
+	failed, err := internal.ExtractAllKeepGoing(recorder, tarsToExtract, progress)
+	if err != nil {
+		return err
+	}
+	for storagePath, err := range failed {
+		tarName := path.Base(storagePath)
+		failure := ExtractFailure{BackupName: backup.Name, TarName: tarName, Err: err}
+		if len(filesMeta.TarFileSets) > 0 {
+			failure.LostFiles = recorder.lostFiles(filesMeta, filesToUnwrap, tarName)
+		}
+		tracelog.WarningLogger.Printf("Continuing the restore without tar member %s: %v\n", tarName, err)
+		keepGoingFailures.add(failure)
+	}
+	return nil
+}
+
+// restoredFilesRecorder records the files of the tar members which are interpreted successfully,
+// the last attempt of the retried member decides whether its file is restored
+type restoredFilesRecorder struct {
+	internal.TarInterpreter
+	mutex         sync.Mutex
+	restoredFiles map[string]bool
+}
+
+func (recorder *restoredFilesRecorder) Interpret(reader io.Reader, header *tar.Header) error {
+	err := recorder.TarInterpreter.Interpret(reader, header)
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.restoredFiles[header.Name] = err == nil
+	return err
+}
+
+// lostFiles returns the files to unwrap of the tar member and their deduplicated copies which are not restored
+func (recorder *restoredFilesRecorder) lostFiles(filesMeta FilesMetadataDto, filesToUnwrap map[string]bool,
+	tarName string) []string {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	dedupCopies := filesMeta.getDedupCopies()
+	isToUnwrap := func(fileName string) bool {
+		return filesToUnwrap == nil || filesToUnwrap[fileName]
+	}
+	lostFiles := make([]string, 0)
+	for _, entryName := range filesMeta.TarFileSets[tarName] {
+		if recorder.restoredFiles[entryName] {
+			continue
+		}
+		// the split file is lost if any of its parts is not restored
+		fileName := filesMeta.getEntryFileName(entryName)
+		if isToUnwrap(fileName) {
+			lostFiles = append(lostFiles, fileName)
+		}
+		for _, dedupCopy := range dedupCopies[fileName] {
+			if isToUnwrap(dedupCopy) {
+				lostFiles = append(lostFiles, dedupCopy)
+			}
+		}
+	}
+	sort.Strings(lostFiles)
+	// the split file is reported once if several of its parts are in the tar member
+	uniqueLostFiles := lostFiles[:0]
+	for i, fileName := range lostFiles {
+		if i == 0 || fileName != lostFiles[i-1] {
+			uniqueLostFiles = append(uniqueLostFiles, fileName)
+		}
+	}
+	return uniqueLostFiles
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const keepGoingBackupName = "base_000000010000000000000002"
+
+// putKeepGoingTar puts the tar member with the files, cutting it after the given number of bytes if it is positive
+func putKeepGoingTar(t *testing.T, folder storage.Folder, tarName string, cutAfter int, files ...string) {
+	var buffer bytes.Buffer
+	tarWriter := tar.NewWriter(&buffer)
+	for _, fileName := range files {
+		content := bytes.Repeat([]byte(fileName), 100)
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: fileName, Mode: 0600, Size: int64(len(content))}))
+		_, err := tarWriter.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	content := buffer.Bytes()
+	if cutAfter > 0 {
+		content = content[:cutAfter]
+	}
+	require.NoError(t, folder.PutObject(keepGoingBackupName+internal.TarPartitionFolderName+tarName,
+		bytes.NewReader(content)))
+}
+
+func setKeepGoingSettings(t *testing.T) {
+	minWait, maxWait := internal.MinExtractRetryWait, internal.MaxExtractRetryWait
+	internal.MinExtractRetryWait, internal.MaxExtractRetryWait = time.Millisecond, time.Millisecond
+	viper.Set(internal.FetchKeepGoingSetting, true)
+	viper.Set(internal.DownloadConcurrencySetting, 1)
+	t.Cleanup(func() {
+		internal.MinExtractRetryWait, internal.MaxExtractRetryWait = minWait, maxWait
+		viper.Set(internal.FetchKeepGoingSetting, false)
+		viper.Set(internal.DownloadConcurrencySetting, 10)
+		keepGoingFailures.take()
+	})
+}
+
+func TestUnwrapKeepGoing(t *testing.T) {
+	setKeepGoingSettings(t)
+	folder := memory.NewFolder("", memory.NewStorage())
+	putKeepGoingTar(t, folder, "pg_control.tar", 0, PgControlPath)
+	putKeepGoingTar(t, folder, "part_001.tar", 0, "/base/1/1259")
+	// the member breaks in the middle of the second file
+	putKeepGoingTar(t, folder, "part_002.tar", 2200, "/base/1/1260", "/base/1/1261", "/base/1/1262")
+	backup := NewBackup(folder, keepGoingBackupName)
+	sentinel := BackupSentinelDto{}
+	filesMeta := FilesMetadataDto{
+		Files: internal.BackupFileList{"/base/1/1263": {DedupOf: "/base/1/1262"}},
+		TarFileSets: map[string][]string{
+			"part_001.tar": {"/base/1/1259"},
+			"part_002.tar": {"/base/1/1260", "/base/1/1261", "/base/1/1262"},
+		},
+	}
+	dbDataDirectory := t.TempDir()
+
+	err := backup.unwrapOld(dbDataDirectory, sentinel, filesMeta, nil, false, nil)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dbDataDirectory, "global", "pg_control"))
+	assert.FileExists(t, filepath.Join(dbDataDirectory, "base", "1", "1259"))
+	assert.FileExists(t, filepath.Join(dbDataDirectory, "base", "1", "1260"))
+	_, err = os.Stat(filepath.Join(dbDataDirectory, "base", "1", "1261"))
+	assert.True(t, os.IsNotExist(err))
+
+	failures := keepGoingFailures.take()
+	require.Len(t, failures, 1)
+	assert.Equal(t, keepGoingBackupName, failures[0].BackupName)
+	assert.Equal(t, "part_002.tar", failures[0].TarName)
+	assert.Error(t, failures[0].Err)
+	assert.Equal(t, []string{"/base/1/1261", "/base/1/1262", "/base/1/1263"}, failures[0].LostFiles)
+	assert.Equal(t, 3, reportExtractFailures(failures))
+}
+
+func TestUnwrapKeepGoing_Disabled(t *testing.T) {
+	setKeepGoingSettings(t)
+	viper.Set(internal.FetchKeepGoingSetting, false)
+	folder := memory.NewFolder("", memory.NewStorage())
+	putKeepGoingTar(t, folder, "pg_control.tar", 0, PgControlPath)
+	putKeepGoingTar(t, folder, "part_001.tar", 2200, "/base/1/1260", "/base/1/1261")
+	backup := NewBackup(folder, keepGoingBackupName)
+
+	err := backup.unwrapOld(t.TempDir(), BackupSentinelDto{}, FilesMetadataDto{}, nil, false, nil)
+	assert.Error(t, err)
+	assert.Empty(t, keepGoingFailures.take())
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// BackupListFilter selects the backups printed by backup-list by their finish time
+// and permanence recorded in the backup metadata. The zero Since and Until don't limit the finish time.
+type BackupListFilter struct {
+	Since         time.Time
+	Until         time.Time
+	PermanentOnly bool
+}
+
+// NewBackupListFilter builds the filter from the --since and --until values,
+// each of them is either "now", a RFC 3339 timestamp or a duration back from now like 7d or 12h
+func NewBackupListFilter(since, until string, permanentOnly bool, now time.Time) (BackupListFilter, error) {
+	filter := BackupListFilter{PermanentOnly: permanentOnly}
+	var err error
+	if since != "" {
+		filter.Since, err = parseBackupListTime(since, now)
+		if err != nil {
+			return BackupListFilter{}, err
+		}
+	}
+	if until != "" {
+		filter.Until, err = parseBackupListTime(until, now)
+		if err != nil {
+			return BackupListFilter{}, err
+		}
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Since.After(filter.Until) {
+		return BackupListFilter{}, errors.Errorf("since %s is after until %s",
+			filter.Since.Format(time.RFC3339), filter.Until.Format(time.RFC3339))
+	}
+	return filter, nil
+}
+
+func parseBackupListTime(value string, now time.Time) (time.Time, error) {
+	if value == "now" {
+		return now, nil
+	}
+	if parsedTime, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsedTime, nil
+	}
+	duration, err := utility.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time '%s', expected now, a RFC 3339 timestamp or a duration like 7d", value)
+	}
+	return now.Add(-duration), nil
+}
+
+func (filter BackupListFilter) IsEmpty() bool {
+	return filter == BackupListFilter{}
+}
+
+// Matches checks the finish time and the permanence of the backup, the bounds are inclusive
+func (filter BackupListFilter) Matches(finishTime time.Time, isPermanent bool) bool {
+	if filter.PermanentOnly && !isPermanent {
+		return false
+	}
+	if !filter.Since.IsZero() && finishTime.Before(filter.Since) {
+		return false
+	}
+	return filter.Until.IsZero() || !finishTime.After(filter.Until)
+}
+
+// filterBackupTimes keeps the backups matching the filter, their metadata is fetched only if the filter is set
+func filterBackupTimes(folder storage.Folder, backups []internal.BackupTime,
+	filter BackupListFilter) ([]internal.BackupTime, error) {
+	if filter.IsEmpty() {
+		return backups, nil
+	}
+	metaFetcher := NewGenericMetaFetcher()
+	filtered := make([]internal.BackupTime, 0, len(backups))
+	for _, backupTime := range backups {
+		meta, err := metaFetcher.Fetch(backupTime.BackupName, folder)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Matches(meta.FinishTime, meta.IsPermanent) {
+			filtered = append(filtered, backupTime)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,60 @@
+package postgres_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+var backupListFilterNow = time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func TestNewBackupListFilter(t *testing.T) {
+	filter, err := postgres.NewBackupListFilter("", "", false, backupListFilterNow)
+	require.NoError(t, err)
+	assert.True(t, filter.IsEmpty())
+
+	filter, err = postgres.NewBackupListFilter("7d", "now", true, backupListFilterNow)
+	require.NoError(t, err)
+	assert.Equal(t, postgres.BackupListFilter{
+		Since:         time.Date(2023, 5, 3, 12, 0, 0, 0, time.UTC),
+		Until:         backupListFilterNow,
+		PermanentOnly: true,
+	}, filter)
+
+	filter, err = postgres.NewBackupListFilter("2023-01-01T00:00:00Z", "12h", false, backupListFilterNow)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), filter.Since)
+	assert.Equal(t, time.Date(2023, 5, 10, 0, 0, 0, 0, time.UTC), filter.Until)
+}
+
+func TestNewBackupListFilter_Invalid(t *testing.T) {
+	for _, test := range []struct{ since, until string }{
+		{"yesterday", ""},
+		{"", "2023-01-01"},
+		{"-7d", ""},
+		{"1d", "2d"},
+	} {
+		_, err := postgres.NewBackupListFilter(test.since, test.until, false, backupListFilterNow)
+		assert.Error(t, err, test)
+	}
+}
+
+func TestBackupListFilter_Matches(t *testing.T) {
+	filter := postgres.BackupListFilter{
+		Since: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC),
+	}
+	assert.True(t, filter.Matches(filter.Since, false))
+	assert.True(t, filter.Matches(filter.Until, false))
+	assert.True(t, filter.Matches(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC), true))
+	assert.False(t, filter.Matches(filter.Since.Add(-time.Second), true))
+	assert.False(t, filter.Matches(filter.Until.Add(time.Second), true))
+	assert.False(t, filter.Matches(time.Time{}, true))
+
+	filter = postgres.BackupListFilter{PermanentOnly: true}
+	assert.True(t, filter.Matches(time.Time{}, true))
+	assert.False(t, filter.Matches(filter.Since, false))
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"text/tabwriter"
+	"time"
 
 	"github.com/jedib0t/go-pretty/table"
 	"github.com/wal-g/tracelog"
@@ -13,7 +15,7 @@ import (
 )
 
 // TODO : unit tests
-func HandleDetailedBackupList(folder storage.Folder, pretty bool, json bool) {
+func HandleDetailedBackupList(folder storage.Folder, pretty bool, json bool, filter BackupListFilter) {
 	backups, err := internal.GetBackups(folder)
 
 	if len(backups) == 0 {
@@ -26,6 +28,7 @@ func HandleDetailedBackupList(folder storage.Folder, pretty bool, json bool) {
 
 	backupDetails, err := GetBackupsDetails(folder, backups)
 	tracelog.ErrorLogger.FatalOnError(err)
+	backupDetails = filterBackupDetails(backupDetails, filter)
 	SortBackupDetails(backupDetails)
 
 	switch {
@@ -39,6 +42,162 @@ func HandleDetailedBackupList(folder storage.Folder, pretty bool, json bool) {
 	tracelog.ErrorLogger.FatalOnError(err)
 }
 
+// BackupListItem is the machine-readable description of a backup printed by backup-list --json.
+// The embedded BackupTime keeps the fields of the plain JSON backup list.
+type BackupListItem struct {
+	internal.BackupTime
+	WalSegmentBackupStop string      `json:"wal_segment_backup_stop,omitempty"`
+	StartTime            time.Time   `json:"start_time"`
+	FinishTime           time.Time   `json:"finish_time"`
+	CompressedSize       int64       `json:"compressed_size"`
+	UncompressedSize     int64       `json:"uncompressed_size"`
+	IsPermanent          bool        `json:"is_permanent"`
+	Hostname             string      `json:"hostname,omitempty"`
+	PgVersion            int         `json:"pg_version,omitempty"`
+	DeltaBaseName        string      `json:"delta_base_name,omitempty"`
+	UserData             interface{} `json:"user_data,omitempty"`
+	// PageChecksums is set only for the backups made with the page checksums verification
+	PageChecksums *PageChecksumsSummary `json:"page_checksums,omitempty"`
+	// Stats is set only for the backups made by the versions recording the duration and the throughput
+	Stats *BackupStats `json:"stats,omitempty"`
+	// Tars is set only for the backups made with the minimal files metadata
+	Tars []BackupListTar `json:"tars,omitempty"`
+}
+
+// BackupListTar describes the composition of the backup tar recorded by the minimal files metadata
+type BackupListTar struct {
+	Name         string `json:"name"`
+	MembersCount int    `json:"members_count"`
+	Size         int64  `json:"size"`
+}
+
+func newBackupListTars(filesMeta FilesMetadataDto) []BackupListTar {
+	tars := make([]BackupListTar, 0, len(filesMeta.TarFileSets))
+	for tarName, members := range filesMeta.TarFileSets {
+		tar := BackupListTar{Name: tarName, MembersCount: len(members)}
+		for _, member := range members {
+			tar.Size += filesMeta.TarMemberSizes[member]
+		}
+		tars = append(tars, tar)
+	}
+	sort.Slice(tars, func(i, j int) bool {
+		return tars[i].Name < tars[j].Name
+	})
+	return tars
+}
+
+func filterBackupDetails(backupDetails []BackupDetail, filter BackupListFilter) []BackupDetail {
+	if filter.IsEmpty() {
+		return backupDetails
+	}
+	filtered := make([]BackupDetail, 0, len(backupDetails))
+	for _, details := range backupDetails {
+		if filter.Matches(details.FinishTime, details.IsPermanent) {
+			filtered = append(filtered, details)
+		}
+	}
+	return filtered
+}
+
+// HandleFilteredBackupList prints the backups matching the filter in the format of the plain backup list
+func HandleFilteredBackupList(folder storage.Folder, pretty bool, filter BackupListFilter) {
+	backups, err := internal.GetBackups(folder)
+	if _, ok := err.(internal.NoBackupsFoundError); ok {
+		tracelog.InfoLogger.Println("No backups found")
+		return
+	}
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	backups, err = filterBackupTimes(folder, backups, filter)
+	tracelog.ErrorLogger.FatalOnError(err)
+	if len(backups) == 0 {
+		tracelog.InfoLogger.Println("No backups match the filter")
+		return
+	}
+	internal.SortBackupTimeSlices(backups)
+	if pretty {
+		internal.WritePrettyBackupList(backups, os.Stdout)
+	} else {
+		internal.WriteBackupList(backups, os.Stdout)
+	}
+}
+
+// HandleBackupListJSON prints the backups with their sentinel and metadata fields as a JSON array
+func HandleBackupListJSON(folder storage.Folder, pretty bool, filter BackupListFilter) {
+	backups, err := internal.GetBackups(folder)
+	if _, ok := err.(internal.NoBackupsFoundError); ok {
+		tracelog.InfoLogger.Println("No backups found")
+		return
+	}
+	tracelog.ErrorLogger.FatalOnError(err)
+	internal.SortBackupTimeSlices(backups)
+
+	items, err := GetBackupListItems(folder, backups)
+	tracelog.ErrorLogger.FatalOnError(err)
+	err = internal.WriteAsJSON(filterBackupListItems(items, filter), os.Stdout, pretty)
+	tracelog.ErrorLogger.FatalOnError(err)
+}
+
+func filterBackupListItems(items []BackupListItem, filter BackupListFilter) []BackupListItem {
+	filtered := make([]BackupListItem, 0, len(items))
+	for _, item := range items {
+		if filter.Matches(item.FinishTime, item.IsPermanent) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+func GetBackupListItems(folder storage.Folder, backups []internal.BackupTime) ([]BackupListItem, error) {
+	metaFetcher := NewGenericMetaFetcher()
+	items := make([]BackupListItem, 0, len(backups))
+	for _, backupTime := range backups {
+		meta, err := metaFetcher.Fetch(backupTime.BackupName, folder)
+		if err != nil {
+			return nil, err
+		}
+		backup := NewBackup(folder, backupTime.BackupName)
+		sentinel, err := backup.GetSentinel()
+		if err != nil {
+			return nil, err
+		}
+		item := BackupListItem{
+			BackupTime:       backupTime,
+			StartTime:        meta.StartTime,
+			FinishTime:       meta.FinishTime,
+			CompressedSize:   meta.CompressedSize,
+			UncompressedSize: meta.UncompressedSize,
+			IsPermanent:      meta.IsPermanent,
+			Hostname:         sentinel.Hostname,
+			PgVersion:        sentinel.PgVersion,
+			UserData:         sentinel.UserData,
+			PageChecksums:    sentinel.PageChecksums,
+			Stats:            sentinel.Stats,
+		}
+		// the older versions record the hostname only in the metadata
+		if item.Hostname == "" {
+			item.Hostname = meta.Hostname
+		}
+		if sentinel.IncrementFrom != nil {
+			item.DeltaBaseName = *sentinel.IncrementFrom
+		}
+		if sentinel.FilesMetadataMinimal {
+			_, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+			if err != nil {
+				return nil, err
+			}
+			item.Tars = newBackupListTars(filesMeta)
+		}
+		// the backups made by older WAL-G versions may lack the finish LSN
+		if timeline, _, err := ParseWALFilename(backupTime.WalFileName); err == nil &&
+			sentinel.BackupFinishLSN != nil {
+			item.WalSegmentBackupStop = newWalSegmentNo(*sentinel.BackupFinishLSN).getFilename(timeline)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
 // TODO : unit tests
 func WriteBackupListDetails(backupDetails []BackupDetail, output io.Writer) error {
 	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
@@ -4,17 +4,21 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/testtools"
+	"github.com/wal-g/wal-g/utility"
 )
 
 func TestBackupListFlagsFindsBackups(t *testing.T) {
 	folder := testtools.CreateMockStorageFolder()
-	postgres.HandleDetailedBackupList(folder, true, false)
+	postgres.HandleDetailedBackupList(folder, true, false, postgres.BackupListFilter{})
 }
 
 func TestBackupListCorrectOutput(t *testing.T) {
@@ -217,3 +221,106 @@ func TestBackupListCorrectPrettyJsonOutput(t *testing.T) {
 	assert.Equal(t, unmarshaledDetails, details)
 	assert.Equal(t, buf.String(), expectedString)
 }
+
+func TestGetBackupListItems(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	const backupName = "base_000000010000000000000002_D_000000010000000000000001"
+	deltaBaseName := "base_000000010000000000000001"
+	startLsn := postgres.LSN(0x2000028)
+	finishLsn := postgres.LSN(0x4000100)
+	sentinel := postgres.BackupSentinelDto{
+		BackupStartLSN:   &startLsn,
+		BackupFinishLSN:  &finishLsn,
+		IncrementFrom:    &deltaBaseName,
+		PgVersion:        140005,
+		Hostname:         "db1.example.com",
+		UncompressedSize: 200,
+		CompressedSize:   100,
+		UserData:         "data",
+		PageChecksums: &postgres.PageChecksumsSummary{
+			CheckedPagesCount:  1000,
+			CorruptBlocksCount: 2,
+			CorruptRelations:   map[string]uint64{"1663/16384/16385": 2},
+		},
+		Stats: &postgres.BackupStats{DurationSeconds: 3600, CompressionRatio: 2, UploadBytesPerSecond: 0.25},
+	}
+	meta := postgres.ExtendedMetadataDto{
+		StartTime:        time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		FinishTime:       time.Date(2022, 1, 1, 1, 0, 0, 0, time.UTC),
+		IsPermanent:      true,
+		UncompressedSize: 200,
+		CompressedSize:   100,
+	}
+	for objectName, dto := range map[string]interface{}{
+		backupName + utility.SentinelSuffix:           sentinel,
+		backupName + "/" + utility.MetadataFileName:   meta,
+		backupName + "/" + postgres.FilesMetadataName: postgres.FilesMetadataDto{},
+	} {
+		bytes, err := json.Marshal(dto)
+		assert.NoError(t, err)
+		assert.NoError(t, folder.PutObject(objectName, strings.NewReader(string(bytes))))
+	}
+
+	backups, err := internal.GetBackups(folder)
+	assert.NoError(t, err)
+	items, err := postgres.GetBackupListItems(folder, backups)
+	assert.NoError(t, err)
+
+	assert.Len(t, items, 1)
+	item := items[0]
+	assert.Equal(t, backupName, item.BackupName)
+	assert.Equal(t, "000000010000000000000002", item.WalFileName)
+	assert.Equal(t, "000000010000000000000004", item.WalSegmentBackupStop)
+	assert.Equal(t, meta.StartTime, item.StartTime)
+	assert.Equal(t, meta.FinishTime, item.FinishTime)
+	assert.Equal(t, int64(100), item.CompressedSize)
+	assert.Equal(t, int64(200), item.UncompressedSize)
+	assert.True(t, item.IsPermanent)
+	assert.Equal(t, "db1.example.com", item.Hostname)
+	assert.Equal(t, 140005, item.PgVersion)
+	assert.Equal(t, deltaBaseName, item.DeltaBaseName)
+	assert.Equal(t, "data", item.UserData)
+	assert.Equal(t, sentinel.PageChecksums, item.PageChecksums)
+	assert.Equal(t, sentinel.Stats, item.Stats)
+}
+
+func TestGetBackupListItems_MinimalFilesMetadata(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	const backupName = "base_000000010000000000000002"
+	startLsn := postgres.LSN(0x2000028)
+	finishLsn := postgres.LSN(0x4000100)
+	sentinel := postgres.BackupSentinelDto{
+		BackupStartLSN:       &startLsn,
+		BackupFinishLSN:      &finishLsn,
+		FilesMetadataMinimal: true,
+	}
+	filesMeta := postgres.FilesMetadataDto{
+		TarFileSets: map[string][]string{
+			"part_1.tar.lz4": {"/base/1/1259", "/base/1/1249"},
+			"part_2.tar.lz4": {"/postgresql.conf"},
+		},
+		TarMemberSizes: map[string]int64{"/base/1/1259": 8192, "/base/1/1249": 16384, "/postgresql.conf": 100},
+	}
+	for objectName, dto := range map[string]interface{}{
+		backupName + utility.SentinelSuffix:           sentinel,
+		backupName + "/" + utility.MetadataFileName:   postgres.ExtendedMetadataDto{},
+		backupName + "/" + postgres.FilesMetadataName: filesMeta,
+	} {
+		bytes, err := json.Marshal(dto)
+		assert.NoError(t, err)
+		assert.NoError(t, folder.PutObject(objectName, strings.NewReader(string(bytes))))
+	}
+
+	backups, err := internal.GetBackups(folder)
+	assert.NoError(t, err)
+	items, err := postgres.GetBackupListItems(folder, backups)
+	assert.NoError(t, err)
+
+	assert.Len(t, items, 1)
+	// the older sentinels have no stats
+	assert.Nil(t, items[0].Stats)
+	assert.Equal(t, []postgres.BackupListTar{
+		{Name: "part_1.tar.lz4", MembersCount: 2, Size: 8192 + 16384},
+		{Name: "part_2.tar.lz4", MembersCount: 1, Size: 100},
+	}, items[0].Tars)
+}
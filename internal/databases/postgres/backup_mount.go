@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const mountCachePartialSuffix = ".partial"
+
+// MountedFile is the file of the backup exposed by backup-mount
+type MountedFile struct {
+	Name  string
+	Size  int64
+	MTime time.Time
+	IsDir bool
+	// SizeUnknown is set for the files of the older backups, which don't record the file sizes
+	SizeUnknown bool
+}
+
+// MountedBackup exposes the files of the full backup read-only, fetching the tar member of the file
+// on its first open. The fetched tar members are extracted into the cache directory and are reused
+// by the next mounts of the backup.
+type MountedBackup struct {
+	backup         Backup
+	filesMeta      FilesMetadataDto
+	cacheDirectory string
+	files          []MountedFile
+	fileTars       map[string]string
+
+	mutex      sync.Mutex
+	tarMutexes map[string]*sync.Mutex
+}
+
+func NewMountedBackup(backup Backup, cacheDirectory string) (*MountedBackup, error) {
+	sentinelDto, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if sentinelDto.IsIncremental() {
+		return nil, errors.Errorf("backup %s is a delta backup, only the full backups can be mounted", backup.Name)
+	}
+	if sentinelDto.FilesMetadataDisabled || sentinelDto.FilesMetadataMinimal || len(filesMeta.TarFileSets) == 0 {
+		return nil, errors.Errorf("backup %s has no files metadata to list its files", backup.Name)
+	}
+	if fileName, ok := filesMeta.findSplitFile(nil); ok {
+		return nil, newSplitFileError(fileName, "mounted")
+	}
+	fileTars := make(map[string]string)
+	for tarName, fileNames := range filesMeta.TarFileSets {
+		for _, fileName := range fileNames {
+			fileTars[fileName] = tarName
+		}
+	}
+	// pg_control is uploaded in its own tar member, which is not recorded in the files metadata
+	tarNames, err := backup.GetTarNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, tarName := range tarNames {
+		if strings.HasPrefix(tarName, "pg_control.tar") {
+			fileTars[PgControlPath] = tarName
+		}
+	}
+	return &MountedBackup{
+		backup:         backup,
+		filesMeta:      filesMeta,
+		cacheDirectory: filepath.Join(cacheDirectory, backup.Name),
+		files:          listMountedFiles(filesMeta.Files, fileTars),
+		fileTars:       fileTars,
+		tarMutexes:     make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// listMountedFiles lists the files and the directories of the backup. The files metadata has no file types,
+// so the directories are told by the files inside them, or by the missing checksum which every regular file has
+// in the backups recording the checksums. The files of the tar members missing in the files metadata, such as
+// backup_label, are regular files of unknown size.
+func listMountedFiles(files internal.BackupFileList, fileTars map[string]string) []MountedFile {
+	hasChecksums := false
+	for _, description := range files {
+		hasChecksums = hasChecksums || description.Crc32c != nil
+	}
+	descriptions := make(map[string]*internal.BackupFileDescription, len(files))
+	for fileName := range fileTars {
+		descriptions[fileName] = nil
+	}
+	for fileName, description := range files {
+		description := description
+		descriptions[fileName] = &description
+	}
+	parents := make(map[string]bool)
+	for fileName := range descriptions {
+		for dir := path.Dir(fileName); dir != "/" && dir != "."; dir = path.Dir(dir) {
+			parents[dir] = true
+		}
+	}
+
+	mountedFiles := make([]MountedFile, 0, len(descriptions)+len(parents))
+	for fileName, description := range descriptions {
+		if description == nil {
+			mountedFiles = append(mountedFiles, MountedFile{Name: fileName, IsDir: parents[fileName], SizeUnknown: true})
+			delete(parents, fileName)
+			continue
+		}
+		if description.IsSkipped {
+			continue
+		}
+		isDir := parents[fileName] || hasChecksums && description.Crc32c == nil
+		delete(parents, fileName)
+		mountedFiles = append(mountedFiles, MountedFile{
+			Name:        fileName,
+			Size:        description.Size,
+			MTime:       description.MTime,
+			IsDir:       isDir,
+			SizeUnknown: !isDir && description.Size == 0 && description.Crc32c == nil,
+		})
+	}
+	for dir := range parents {
+		mountedFiles = append(mountedFiles, MountedFile{Name: dir, IsDir: true})
+	}
+	sort.Slice(mountedFiles, func(i, j int) bool {
+		return mountedFiles[i].Name < mountedFiles[j].Name
+	})
+	return mountedFiles
+}
+
+// Files returns the files and the directories of the backup ordered by name
+func (mountedBackup *MountedBackup) Files() []MountedFile {
+	return mountedBackup.files
+}
+
+// OpenFile fetches the tar member of the file into the cache directory if it is not cached yet,
+// and opens the extracted file
+func (mountedBackup *MountedBackup) OpenFile(fileName string) (*os.File, error) {
+	if description, ok := mountedBackup.filesMeta.Files[fileName]; ok && description.DedupOf != "" {
+		fileName = description.DedupOf
+	}
+	tarName, ok := mountedBackup.fileTars[fileName]
+	if !ok {
+		return nil, errors.Errorf("file '%s' is not found in the tar members of backup %s",
+			fileName, mountedBackup.backup.Name)
+	}
+	tarDirectory, err := mountedBackup.fetchTar(tarName)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(tarDirectory, fileName))
+}
+
+// fetchTar extracts the tar member into its cache directory once. The tar member is extracted
+// into the partial directory first, so the interrupted extraction is not taken for the cached one.
+func (mountedBackup *MountedBackup) fetchTar(tarName string) (string, error) {
+	tarMutex := mountedBackup.getTarMutex(tarName)
+	tarMutex.Lock()
+	defer tarMutex.Unlock()
+
+	tarDirectory := filepath.Join(mountedBackup.cacheDirectory, tarName)
+	if _, err := os.Stat(tarDirectory); err == nil {
+		return tarDirectory, nil
+	}
+	partialDirectory := tarDirectory + mountCachePartialSuffix
+	err := os.RemoveAll(partialDirectory)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to remove the partially fetched tar member '%s'", partialDirectory)
+	}
+
+	tracelog.InfoLogger.Printf("Fetching tar member %s of backup %s", tarName, mountedBackup.backup.Name)
+	readerMaker := mountedBackup.backup.newTarReaderMaker(tarName, mountedBackup.filesMeta)
+	// the failed open is retried by the reader of the mount point, so the fetch is not retried here
+	failed, err := internal.ExtractAllOnce(&mountCacheTarInterpreter{directory: partialDirectory},
+		[]internal.ReaderMaker{readerMaker})
+	for _, extractErr := range failed {
+		err = extractErr
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to fetch tar member %s of backup %s", tarName, mountedBackup.backup.Name)
+	}
+	err = os.MkdirAll(partialDirectory, 0700)
+	if err != nil {
+		return "", err
+	}
+	return tarDirectory, os.Rename(partialDirectory, tarDirectory)
+}
+
+func (mountedBackup *MountedBackup) getTarMutex(tarName string) *sync.Mutex {
+	mountedBackup.mutex.Lock()
+	defer mountedBackup.mutex.Unlock()
+	tarMutex, ok := mountedBackup.tarMutexes[tarName]
+	if !ok {
+		tarMutex = new(sync.Mutex)
+		mountedBackup.tarMutexes[tarName] = tarMutex
+	}
+	return tarMutex
+}
+
+// mountCacheTarInterpreter writes the regular files of the tar member into the cache directory,
+// the directories are listed from the files metadata
+type mountCacheTarInterpreter struct {
+	directory string
+}
+
+func (interpreter *mountCacheTarInterpreter) Interpret(reader io.Reader, header *tar.Header) error {
+	if !header.FileInfo().Mode().IsRegular() {
+		return nil
+	}
+	cachedPath := filepath.Join(interpreter.directory, header.Name)
+	if !strings.HasPrefix(cachedPath, filepath.Clean(interpreter.directory)+string(filepath.Separator)) {
+		return errors.Errorf("tar member file '%s' is outside of the cache directory", header.Name)
+	}
+	err := os.MkdirAll(filepath.Dir(cachedPath), 0700)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the cache directory of file '%s'", header.Name)
+	}
+	file, err := os.OpenFile(cachedPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the cached file '%s'", cachedPath)
+	}
+	defer utility.LoggedClose(file, "")
+	_, err = io.Copy(file, reader)
+	return errors.Wrapf(err, "failed to write the cached file '%s'", cachedPath)
+}
@@ -0,0 +1,142 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package postgres
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// HandleBackupMount mounts the backup read-only at the mount point until the process is interrupted
+func HandleBackupMount(folder storage.Folder, backupSelector internal.BackupSelector, mountPoint, cacheDirectory string) {
+	backupName, err := backupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	mountedBackup, err := NewMountedBackup(NewBackup(folder.GetSubFolder(utility.BaseBackupPath), backupName),
+		cacheDirectory)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	server, err := fs.Mount(mountPoint, &mountedBackupRoot{mountedBackup: mountedBackup}, &fs.Options{
+		MountOptions: fuse.MountOptions{FsName: "wal-g:" + backupName, Name: "wal-g", Options: []string{"ro"},
+			// the direct mount works without fusermount when run by root, fusermount is used otherwise
+			DirectMount: true},
+	})
+	tracelog.ErrorLogger.FatalfOnError("Failed to mount the backup: %v", err)
+	tracelog.InfoLogger.Printf("Backup %s is mounted at %s, the tar members are cached in %s",
+		backupName, mountPoint, cacheDirectory)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		tracelog.InfoLogger.Printf("Unmounting %s", mountPoint)
+		tracelog.ErrorLogger.PrintOnError(server.Unmount())
+	}()
+	server.Wait()
+}
+
+// mountedBackupRoot builds the whole tree of the backup files when it is mounted
+type mountedBackupRoot struct {
+	fs.Inode
+	mountedBackup *MountedBackup
+}
+
+var _ = (fs.NodeOnAdder)((*mountedBackupRoot)(nil))
+
+func (root *mountedBackupRoot) OnAdd(ctx context.Context) {
+	for _, file := range root.mountedBackup.Files() {
+		parent := &root.Inode
+		components := strings.Split(strings.Trim(file.Name, "/"), "/")
+		for _, component := range components[:len(components)-1] {
+			child := parent.GetChild(component)
+			if child == nil {
+				child = parent.NewPersistentInode(ctx, &mountedBackupNode{}, fs.StableAttr{Mode: fuse.S_IFDIR})
+				parent.AddChild(component, child, true)
+			}
+			parent = child
+		}
+		name := components[len(components)-1]
+		if existing := parent.GetChild(name); existing != nil {
+			// the directory is already created for the files inside it
+			existing.Operations().(*mountedBackupNode).file = file
+			continue
+		}
+		node := &mountedBackupNode{mountedBackup: root.mountedBackup, file: file}
+		mode := uint32(fuse.S_IFREG)
+		if file.IsDir {
+			mode = fuse.S_IFDIR
+		}
+		parent.AddChild(name, parent.NewPersistentInode(ctx, node, fs.StableAttr{Mode: mode}), true)
+	}
+}
+
+// mountedBackupNode is the file or the directory of the backup
+type mountedBackupNode struct {
+	fs.Inode
+	mountedBackup *MountedBackup
+	file          MountedFile
+}
+
+var _ = (fs.NodeGetattrer)((*mountedBackupNode)(nil))
+var _ = (fs.NodeOpener)((*mountedBackupNode)(nil))
+
+func (node *mountedBackupNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0444
+	if node.IsDir() {
+		out.Mode = 0555
+	}
+	out.Size = uint64(node.file.Size)
+	if !node.file.MTime.IsZero() {
+		out.SetTimes(nil, &node.file.MTime, nil)
+	}
+	return fs.OK
+}
+
+func (node *mountedBackupNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	file, err := node.mountedBackup.OpenFile(node.file.Name)
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to open %s: %v", node.file.Name, err)
+		return nil, 0, syscall.EIO
+	}
+	// the file size is not known until the file is fetched, so its reads bypass the page cache
+	fuseFlags := uint32(fuse.FOPEN_KEEP_CACHE)
+	if node.file.SizeUnknown {
+		fuseFlags = fuse.FOPEN_DIRECT_IO
+	}
+	return &mountedFileHandle{file: file}, fuseFlags, fs.OK
+}
+
+// mountedFileHandle reads the file extracted into the cache directory
+type mountedFileHandle struct {
+	file *os.File
+}
+
+var _ = (fs.FileReader)((*mountedFileHandle)(nil))
+var _ = (fs.FileReleaser)((*mountedFileHandle)(nil))
+
+func (handle *mountedFileHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := handle.file.ReadAt(dest, off)
+	if err != nil && err != io.EOF {
+		return nil, fs.ToErrno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), fs.OK
+}
+
+func (handle *mountedFileHandle) Release(ctx context.Context) syscall.Errno {
+	return fs.ToErrno(handle.file.Close())
+}
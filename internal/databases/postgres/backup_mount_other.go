@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package postgres
+
+import (
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// HandleBackupMount is not supported without FUSE
+func HandleBackupMount(folder storage.Folder, backupSelector internal.BackupSelector, mountPoint, cacheDirectory string) {
+	tracelog.ErrorLogger.Fatal("backup-mount is supported only on Linux and macOS")
+}
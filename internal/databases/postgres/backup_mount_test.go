@@ -0,0 +1,96 @@
+package postgres_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func TestMountedBackup(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putTarMember(t, folder, "part_001.tar", "/base/1/1259", "/global/1262")
+	putTarMember(t, folder, "part_003.tar", postgres.BackupLabelFilename)
+	putTarMember(t, folder, "pg_control.tar", postgres.PgControlPath)
+	checksum := uint32(42)
+	backup := newVerifiedBackup(folder, map[string][]string{
+		"part_001.tar": {"/base", "/base/1", "/base/1/1259", "/global/1262"},
+		"part_003.tar": {postgres.BackupLabelFilename},
+	})
+	backup.FilesMetadataDto.Files = internal.BackupFileList{
+		"/base":         {},
+		"/base/1":       {},
+		"/base/1/1259":  {Crc32c: &checksum, Size: int64(len("/base/1/1259"))},
+		"/base/1/1260":  {Crc32c: &checksum, DedupOf: "/base/1/1259"},
+		"/global/1262":  {Crc32c: &checksum, Size: int64(len("/global/1262"))},
+		"/pg_stat_tmp":  {},
+		"/base/1/16384": {IsSkipped: true},
+	}
+	cacheDirectory := t.TempDir()
+	mountedBackup, err := postgres.NewMountedBackup(backup, cacheDirectory)
+	require.NoError(t, err)
+
+	dirs := make(map[string]bool)
+	for _, file := range mountedBackup.Files() {
+		dirs[file.Name] = file.IsDir
+	}
+	assert.Equal(t, map[string]bool{
+		"/base": true, "/base/1": true, "/base/1/1259": false, "/base/1/1260": false, "/global": true,
+		"/global/1262": false, "/pg_stat_tmp": true, postgres.BackupLabelFilename: false, postgres.PgControlPath: false,
+	}, dirs)
+
+	for _, fileName := range []string{"/base/1/1259", postgres.PgControlPath, postgres.BackupLabelFilename} {
+		file, err := mountedBackup.OpenFile(fileName)
+		require.NoError(t, err)
+		content, err := io.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, fileName, string(content))
+		require.NoError(t, file.Close())
+	}
+	file, err := mountedBackup.OpenFile("/base/1/1260")
+	require.NoError(t, err)
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, "/base/1/1259", string(content))
+	require.NoError(t, file.Close())
+	assert.DirExists(t, filepath.Join(cacheDirectory, verifiedBackupName, "part_001.tar"))
+
+	// the cached tar members are reused without the storage
+	require.NoError(t, folder.DeleteObjects([]string{verifiedBackupName + internal.TarPartitionFolderName + "part_001.tar"}))
+	file, err = mountedBackup.OpenFile("/global/1262")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func TestMountedBackup_FailedFetch(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	backup := newVerifiedBackup(folder, map[string][]string{"part_001.tar": {"/base/1/1259"}})
+	cacheDirectory := t.TempDir()
+	mountedBackup, err := postgres.NewMountedBackup(backup, cacheDirectory)
+	require.NoError(t, err)
+
+	_, err = mountedBackup.OpenFile("/base/1/1259")
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(cacheDirectory, verifiedBackupName, "part_001.tar"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMountedBackup_Delta(t *testing.T) {
+	baseName := "base_000000010000000000000001"
+	baseLSN := postgres.LSN(0x1000000)
+	count := 1
+	backup := newVerifiedBackup(memory.NewFolder("", memory.NewStorage()), map[string][]string{})
+	backup.SentinelDto.IncrementFrom = &baseName
+	backup.SentinelDto.IncrementFullName = &baseName
+	backup.SentinelDto.IncrementFromLSN = &baseLSN
+	backup.SentinelDto.IncrementCount = &count
+
+	_, err := postgres.NewMountedBackup(backup, t.TempDir())
+	assert.Error(t, err)
+}
@@ -69,7 +69,7 @@ func checkDBDirectoryForUnwrapNew(dbDataDirectory string, sentinelDto BackupSent
 // Do the job of unpacking Backup object
 func (backup *Backup) unwrapNew(
 	dbDataDirectory string, sentinelDto BackupSentinelDto, filesMetaDto FilesMetadataDto, filesToUnwrap map[string]bool,
-	createIncrementalFiles, skipRedundantTars bool) (*UnwrapResult, error) {
+	createIncrementalFiles, skipRedundantTars bool, restoreFilter *RestoreFilter) (*UnwrapResult, error) {
 	useNewUnwrapImplementation = true
 	err := checkDBDirectoryForUnwrapNew(dbDataDirectory, sentinelDto, filesMetaDto)
 	if err != nil {
@@ -77,6 +77,11 @@ func (backup *Backup) unwrapNew(
 	}
 
 	tarInterpreter := NewFileTarInterpreter(dbDataDirectory, sentinelDto, filesMetaDto, filesToUnwrap, createIncrementalFiles)
+	tarInterpreter.RestoreFilter = restoreFilter
+	tarInterpreter.Ownership, err = ConfigureRestoreOwnership()
+	if err != nil {
+		return nil, err
+	}
 	tarsToExtract, pgControlKey, err := backup.getTarsToExtract(filesMetaDto, filesToUnwrap, skipRedundantTars)
 	if err != nil {
 		return nil, err
@@ -89,7 +94,10 @@ func (backup *Backup) unwrapNew(
 		return nil, newPgControlNotFoundError()
 	}
 
-	err = internal.ExtractAll(tarInterpreter, tarsToExtract)
+	progress := backup.newExtractProgress(sentinelDto)
+	progress.Start()
+	err = internal.ExtractAllWithProgress(tarInterpreter, tarsToExtract, progress)
+	progress.Stop()
 	if _, ok := err.(internal.NoFilesToExtractError); ok {
 		// in case of no tars to extract, just ignore this backup and proceed to the next
 		tracelog.InfoLogger.Println("Skipping backup: no useful files found.")
@@ -100,12 +108,16 @@ func (backup *Backup) unwrapNew(
 	}
 
 	if needPgControl {
-		readerMakers := []internal.ReaderMaker{internal.NewStorageReaderMaker(backup.getTarPartitionFolder(), pgControlKey)}
+		readerMakers := []internal.ReaderMaker{backup.newTarReaderMaker(pgControlKey, filesMetaDto)}
 		err = internal.ExtractAll(tarInterpreter, readerMakers)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to extract pg_control")
 		}
 	}
+	err = tarInterpreter.SyncDirectories()
+	if err != nil {
+		return nil, err
+	}
 
 	tracelog.InfoLogger.Print("\nBackup extraction complete.\n")
 	return tarInterpreter.UnwrapResult, nil
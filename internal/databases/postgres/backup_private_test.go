@@ -3,9 +3,12 @@ package postgres
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
 )
 
 func createTempDir(prefix string) (name string, err error) {
@@ -87,3 +90,45 @@ func TestIsDirectoryEmpty_ReturnsTrue_WhenDirectoryDoesntExist(t *testing.T) {
 
 	assert.True(t, actual)
 }
+
+func TestSortTarNamesBySize(t *testing.T) {
+	filesMeta := FilesMetadataDto{
+		TarFileSets: map[string][]string{
+			"part_1.tar.lz4": {"/base/1/1259"},
+			"part_2.tar.lz4": {"/base/16384/16385", "/base/16384/16386"},
+			"part_3.tar.lz4": {"/postgresql.conf"},
+		},
+		TarMemberSizes: map[string]int64{
+			"/base/1/1259":      8192,
+			"/base/16384/16385": 8192,
+			"/base/16384/16386": 16384,
+			"/postgresql.conf":  100,
+		},
+	}
+	tarNames := []string{"part_1.tar.lz4", "part_3.tar.lz4", "part_2.tar.lz4", "part_4.tar.lz4"}
+
+	sortTarNamesBySize(tarNames, filesMeta)
+	assert.Equal(t, []string{"part_2.tar.lz4", "part_1.tar.lz4", "part_3.tar.lz4", "part_4.tar.lz4"}, tarNames)
+}
+
+func TestGetTarsToExtract_ChecksTarSizes(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	backup := NewBackup(folder, "base_000000010000000000000002")
+	tarPartitionFolder := backup.getTarPartitionFolder()
+	assert.NoError(t, tarPartitionFolder.PutObject("part_1.tar", strings.NewReader("complete")))
+	assert.NoError(t, tarPartitionFolder.PutObject("part_2.tar", strings.NewReader("trunc")))
+	filesMeta := FilesMetadataDto{TarChecksums: map[string]internal.ObjectChecksum{
+		"part_1.tar": {Size: 8, CRC32: 1},
+		"part_2.tar": {Size: 9, CRC32: 2},
+	}}
+
+	_, _, err := backup.getTarsToExtract(filesMeta, nil, false)
+	assert.IsType(t, internal.ObjectTruncatedError{}, err)
+	assert.Contains(t, err.Error(), "object part_2.tar truncated: expected 9 got 5")
+
+	filesMeta.TarChecksums["part_2.tar"] = internal.ObjectChecksum{Size: 5, CRC32: 2}
+	tarsToExtract, _, err := backup.getTarsToExtract(filesMeta, nil, false)
+	assert.NoError(t, err)
+	assert.Len(t, tarsToExtract, 2)
+	assert.NotNil(t, tarsToExtract[0].(*internal.StorageReaderMaker).Checksum)
+}
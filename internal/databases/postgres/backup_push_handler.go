@@ -7,13 +7,18 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path"
 	"path/filepath"
+	"sort"
 	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+	"github.com/wal-g/wal-g/internal/tracing"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
@@ -46,6 +51,40 @@ func (err backupFromOtherBD) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+// BackupPushUsageError is returned for the backup-push options which cannot be used in the current setup
+type BackupPushUsageError struct {
+	error
+}
+
+func newBackupPushUsageError(format string, args ...interface{}) BackupPushUsageError {
+	return BackupPushUsageError{errors.Errorf(format, args...)}
+}
+
+func (err BackupPushUsageError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err BackupPushUsageError) ExitCode() int {
+	return internal.ExitCodeUsage
+}
+
+// BackupUploadError is returned when some files of the backup failed to upload
+type BackupUploadError struct {
+	error
+}
+
+func newBackupUploadError(backupName string) BackupUploadError {
+	return BackupUploadError{errors.Errorf("Uploading failed during '%s' backup.", backupName)}
+}
+
+func (err BackupUploadError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err BackupUploadError) ExitCode() int {
+	return internal.ExitCodeStorageIO
+}
+
 // BackupArguments holds all arguments parsed from cmd to this handler class
 type BackupArguments struct {
 	isPermanent           bool
@@ -59,6 +98,20 @@ type BackupArguments struct {
 	isFullBackup          bool
 	deltaBaseSelector     internal.BackupSelector
 	withoutFilesMetadata  bool
+	minimalFilesMetadata  bool
+	dryRun                bool
+	resumeBackupName      string
+	fullIfOlderThan       time.Duration
+	timeout               time.Duration
+	skipWalValidation     bool
+	dedupSmallFiles       bool
+	parallelTablespaces   bool
+	skipUnlogged          bool
+	whileStandby          bool
+	fromStdin             bool
+	splitLargeFiles       bool
+	fromSnapshot          bool
+	verifyChecksumsOnly   bool
 }
 
 // CurBackupInfo holds all information that is harvest during the backup process
@@ -70,6 +123,9 @@ type CurBackupInfo struct {
 	uncompressedSize int64
 	compressedSize   int64
 	incrementCount   int
+	encryptionKey    *envelope.WrappedKey
+	// labelFiles are uploaded as the separate objects if WALG_UPLOAD_LABEL_FILES is set
+	labelFiles BackupLabelFiles
 }
 
 // PrevBackupInfo holds all information that is harvest during the backup process
@@ -84,6 +140,12 @@ type BackupWorkers struct {
 	uploader    *WalUploader
 	bundle      *Bundle
 	queryRunner *PgQueryRunner
+	// progressCollector is set only when the metrics server is enabled
+	progressCollector *backupProgressCollector
+	// relFileStats is set only when the rating composer reuses the stored statistics
+	relFileStats RelFileStatistics
+	// pageChecksums is set only when the page checksums are verified
+	pageChecksums *pageChecksumsCollector
 }
 
 // BackupPgInfo holds the PostgreSQL info that the handler queries before running the backup
@@ -100,28 +162,89 @@ type BackupHandler struct {
 	arguments      BackupArguments
 	workers        BackupWorkers
 	pgInfo         BackupPgInfo
+	// resumedBackup is the unfinished backup which tarballs are reused, set only by the backup-push --resume
+	resumedBackup *Backup
+	// ctx is done once the backup-push --timeout is exceeded or the backup is terminated
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout backupTimeout
+	// terminateErr is the cause of the backup termination by the PG alive checker
+	terminateErr atomic.Value
+	// terminated is closed once the backup terminator is done, set only after the backup is started
+	terminated chan struct{}
+}
+
+// BackupPushOptions are the options of backup-push. The zero values of TarBallComposerType, BackupsFolder
+// and DeltaBaseSelector stand for the regular composer, the base backups folder and the latest backup.
+type BackupPushOptions struct {
+	DataDirectory         string
+	BackupsFolder         string
+	Permanent             bool
+	VerifyPageChecksums   bool
+	FullBackup            bool
+	StoreAllCorruptBlocks bool
+	TarBallComposerType   TarBallComposerType
+	DeltaBaseSelector     internal.BackupSelector
+	UserData              interface{}
+	WithoutFilesMetadata  bool
+	MinimalFilesMetadata  bool
+	DryRun                bool
+	ResumeBackupName      string
+	FullIfOlderThan       time.Duration
+	Timeout               time.Duration
+	SkipWalValidation     bool
+	DedupSmallFiles       bool
+	ParallelTablespaces   bool
+	SkipUnlogged          bool
+	WhileStandby          bool
+	FromStdin             bool
+	SplitLargeFiles       bool
+	FromSnapshot          bool
+	VerifyChecksumsOnly   bool
 }
 
 // NewBackupArguments creates a BackupArgument object to hold the arguments from the cmd
-func NewBackupArguments(pgDataDirectory string, backupsFolder string, isPermanent bool, verifyPageChecksums bool,
-	isFullBackup bool, storeAllCorruptBlocks bool, tarBallComposerType TarBallComposerType,
-	deltaBaseSelector internal.BackupSelector, userData interface{}, withoutFilesMetadata bool) BackupArguments {
-	return BackupArguments{
-		pgDataDirectory:       pgDataDirectory,
-		backupsFolder:         backupsFolder,
-		isPermanent:           isPermanent,
-		verifyPageChecksums:   verifyPageChecksums,
-		isFullBackup:          isFullBackup,
-		storeAllCorruptBlocks: storeAllCorruptBlocks,
-		tarBallComposerType:   tarBallComposerType,
-		deltaBaseSelector:     deltaBaseSelector,
-		userData:              userData,
-		withoutFilesMetadata:  withoutFilesMetadata,
+func NewBackupArguments(options BackupPushOptions) BackupArguments {
+	arguments := BackupArguments{
+		pgDataDirectory:       options.DataDirectory,
+		backupsFolder:         options.BackupsFolder,
+		isPermanent:           options.Permanent,
+		verifyPageChecksums:   options.VerifyPageChecksums,
+		isFullBackup:          options.FullBackup,
+		storeAllCorruptBlocks: options.StoreAllCorruptBlocks,
+		tarBallComposerType:   options.TarBallComposerType,
+		deltaBaseSelector:     options.DeltaBaseSelector,
+		userData:              options.UserData,
+		withoutFilesMetadata:  options.WithoutFilesMetadata,
+		minimalFilesMetadata:  options.MinimalFilesMetadata,
+		dryRun:                options.DryRun,
+		resumeBackupName:      options.ResumeBackupName,
+		fullIfOlderThan:       options.FullIfOlderThan,
+		timeout:               options.Timeout,
+		skipWalValidation:     options.SkipWalValidation,
+		dedupSmallFiles:       options.DedupSmallFiles,
+		parallelTablespaces:   options.ParallelTablespaces,
+		skipUnlogged:          options.SkipUnlogged,
+		whileStandby:          options.WhileStandby,
+		fromStdin:             options.FromStdin,
+		splitLargeFiles:       options.SplitLargeFiles,
+		fromSnapshot:          options.FromSnapshot,
+		verifyChecksumsOnly:   options.VerifyChecksumsOnly,
+	}
+	if arguments.backupsFolder == "" {
+		arguments.backupsFolder = utility.BaseBackupPath
+	}
+	if arguments.tarBallComposerType == 0 {
+		arguments.tarBallComposerType = RegularComposer
+	}
+	if arguments.deltaBaseSelector == nil {
+		arguments.deltaBaseSelector = internal.NewLatestBackupSelector()
 	}
+	return arguments
 }
 
 // TODO : unit tests
-func getDeltaConfig() (maxDeltas int, fromFull bool) {
+func getDeltaConfig() (maxDeltas int, fromFull bool, err error) {
 	maxDeltas = viper.GetInt(internal.DeltaMaxStepsSetting)
 	if origin, hasOrigin := internal.GetSetting(internal.DeltaOriginSetting); hasOrigin {
 		switch origin {
@@ -129,14 +252,13 @@ func getDeltaConfig() (maxDeltas int, fromFull bool) {
 		case "LATEST_FULL":
 			fromFull = true
 		default:
-			tracelog.ErrorLogger.Fatalf("Unknown %s: %s\n", internal.DeltaOriginSetting, origin)
+			return 0, false, newBackupPushUsageError("Unknown %s: %s", internal.DeltaOriginSetting, origin)
 		}
 	}
 	return
 }
 
-func (bh *BackupHandler) createAndPushBackup() {
-	var err error
+func (bh *BackupHandler) createAndPushBackup() (BackupSentinelDto, error) {
 	folder := bh.workers.uploader.UploadingFolder
 	// TODO: AB: this subfolder switch look ugly.
 	// I think typed storage folders could be better (i.e. interface BasebackupStorageFolder, WalStorageFolder etc)
@@ -145,20 +267,74 @@ func (bh *BackupHandler) createAndPushBackup() {
 
 	arguments := bh.arguments
 	crypter := internal.ConfigureCrypter()
+	tarSizeThreshold, err := internal.GetTarSizeThreshold()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
 	bh.workers.bundle = NewBundle(bh.pgInfo.pgDataDirectory, crypter, bh.prevBackupInfo.sentinelDto.BackupStartLSN,
-		bh.prevBackupInfo.filesMetadataDto.Files, arguments.forceIncremental,
-		viper.GetInt64(internal.TarSizeThresholdSetting))
+		bh.prevBackupInfo.filesMetadataDto.Files, arguments.forceIncremental, tarSizeThreshold)
+	bh.workers.bundle.ctx = bh.ctx
+	bh.workers.bundle.SkipUnlogged = arguments.skipUnlogged
+	bh.workers.bundle.DeltaDetection, err = GetDeltaDetection()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = bh.workers.bundle.configureExcludedPaths()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = bh.workers.bundle.configureFollowedSymlinks()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
 
-	err = bh.startBackup()
-	tracelog.ErrorLogger.FatalOnError(err)
-	bh.handleDeltaBackup(folder)
-	tarFileSets := bh.uploadBackup()
+	if arguments.fromSnapshot {
+		err = bh.startSnapshotBackup(folder)
+	} else {
+		err = bh.startBackup()
+	}
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = bh.handleDeltaBackup(folder)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = bh.configureBackupEncryptionKey()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	tarFileSets, err := bh.uploadBackup()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
 	sentinelDto, filesMetaDto := bh.setupDTO(tarFileSets)
-	bh.markBackups(folder, sentinelDto)
-	bh.uploadMetadata(sentinelDto, filesMetaDto)
+	err = checkTarsUploaded(NewBackup(bh.workers.uploader.UploadingFolder, bh.curBackupInfo.name), tarFileSets)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = bh.finishBeforeTimeout()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = bh.markBackups(folder, sentinelDto)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = bh.uploadMetadata(sentinelDto, filesMetaDto)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	bh.cleanupResumeState()
+	bh.storeRatingStatistics()
 
 	// logging backup set name
-	tracelog.InfoLogger.Printf("Wrote backup with name %s", bh.curBackupInfo.name)
+	internal.AddLogFields(internal.LogFields{"backup_name": bh.curBackupInfo.name})
+	internal.InfoLogWithFields(internal.LogFields{
+		"uncompressed_size": bh.curBackupInfo.uncompressedSize,
+		"compressed_size":   bh.curBackupInfo.compressedSize,
+	}, "Wrote backup with name %s", bh.curBackupInfo.name)
+	return sentinelDto, nil
 }
 
 func (bh *BackupHandler) startBackup() (err error) {
@@ -172,6 +348,20 @@ func (bh *BackupHandler) startBackup() (err error) {
 	if err != nil {
 		return fmt.Errorf("failed to build query runner: %v", err)
 	}
+	if bh.arguments.skipWalValidation {
+		tracelog.WarningLogger.Println("The archiving of the backup WAL is not verified, " +
+			"the backup may be impossible to recover from, use it only for non-production clusters")
+		if bh.workers.queryRunner.Version < 100000 {
+			tracelog.WarningLogger.Println("pg_stop_backup() of PostgreSQL before 10 always waits for the WAL archiving")
+		}
+		bh.workers.queryRunner.SkipWaitForArchive = true
+	}
+	if bh.arguments.whileStandby {
+		err = bh.checkStandby()
+		if err != nil {
+			return err
+		}
+	}
 
 	tracelog.DebugLogger.Println("Running StartBackup.")
 	backupName, backupStartLSN, err := bh.workers.bundle.StartBackup(
@@ -182,22 +372,54 @@ func (bh *BackupHandler) startBackup() (err error) {
 	bh.curBackupInfo.startLSN = backupStartLSN
 	bh.curBackupInfo.name = backupName
 	tracelog.DebugLogger.Printf("Backup name: %s\nBackup start LSN: %s", backupName, backupStartLSN)
-	bh.initBackupTerminator()
-	return
+	return bh.initBackupTerminator()
+}
+
+// checkEnvelopeEncryptionArguments fails on the options which reuse the tarballs of the other backups
+// with the envelope encryption. The copied tarballs keep referencing the sentinels of their own backups
+// for the data keys, so they can't be decrypted once these backups are deleted, and the unfinished backup
+// resumed by --resume has no sentinel at all.
+func checkEnvelopeEncryptionArguments(arguments BackupArguments) error {
+	if arguments.tarBallComposerType != CopyComposer && arguments.resumeBackupName == "" {
+		return nil
+	}
+	if _, ok := internal.ConfigureCrypter().(*envelope.Crypter); !ok {
+		return nil
+	}
+	return newBackupPushUsageError("Copy composer and resume are not available with the envelope encryption (%s).",
+		internal.EnvelopeKeySetting)
 }
 
-func (bh *BackupHandler) handleDeltaBackup(folder storage.Folder) {
+// configureBackupEncryptionKey switches the envelope encryption to the new data key of the backup.
+// The key is stored in the backup sentinel instead of the tarballs, so rewrap-keys rewraps it
+// by the new master key without moving the tarballs.
+func (bh *BackupHandler) configureBackupEncryptionKey() (err error) {
+	envelopeCrypter, ok := bh.workers.bundle.Crypter.(*envelope.Crypter)
+	if !ok {
+		return nil
+	}
+	sentinelPath := path.Join(bh.arguments.backupsFolder, internal.SentinelNameFromBackup(bh.curBackupInfo.name))
+	backupCrypter, encryptionKey, err := envelopeCrypter.ForBackup(sentinelPath)
+	if err != nil {
+		return err
+	}
+	bh.workers.bundle.Crypter = backupCrypter
+	bh.curBackupInfo.encryptionKey = encryptionKey
+	return nil
+}
+
+func (bh *BackupHandler) handleDeltaBackup(folder storage.Folder) error {
 	if len(bh.prevBackupInfo.name) > 0 && bh.prevBackupInfo.sentinelDto.BackupStartLSN != nil {
 		tracelog.InfoLogger.Println("Delta backup enabled")
 		tracelog.DebugLogger.Printf("Previous backup: %s\nBackup start LSN: %d", bh.prevBackupInfo.name,
 			bh.prevBackupInfo.sentinelDto.BackupStartLSN)
 		if *bh.prevBackupInfo.sentinelDto.BackupFinishLSN > bh.curBackupInfo.startLSN {
-			tracelog.ErrorLogger.FatalOnError(newBackupFromFuture(bh.prevBackupInfo.name))
+			return newBackupFromFuture(bh.prevBackupInfo.name)
 		}
 		if bh.prevBackupInfo.sentinelDto.SystemIdentifier != nil &&
 			bh.pgInfo.systemIdentifier != nil &&
 			*bh.pgInfo.systemIdentifier != *bh.prevBackupInfo.sentinelDto.SystemIdentifier {
-			tracelog.ErrorLogger.FatalOnError(newBackupFromOtherBD())
+			return newBackupFromOtherBD()
 		}
 		if bh.workers.uploader.getUseWalDelta() {
 			err := bh.workers.bundle.DownloadDeltaMap(folder.GetSubFolder(utility.WalPath), bh.curBackupInfo.startLSN)
@@ -212,6 +434,7 @@ func (bh *BackupHandler) handleDeltaBackup(folder storage.Folder) {
 		bh.curBackupInfo.name = bh.curBackupInfo.name + "_D_" + utility.StripWalFileName(bh.prevBackupInfo.name)
 		tracelog.DebugLogger.Printf("Suffixing Backup name with Delta info: %s", bh.curBackupInfo.name)
 	}
+	return nil
 }
 
 func (bh *BackupHandler) setupDTO(tarFileSets internal.TarFileSets) (sentinelDto BackupSentinelDto, filesMeta FilesMetadataDto) {
@@ -220,64 +443,232 @@ func (bh *BackupHandler) setupDTO(tarFileSets internal.TarFileSets) (sentinelDto
 		tablespaceSpec = &bh.workers.bundle.TablespaceSpec
 	}
 	sentinelDto = NewBackupSentinelDto(bh, tablespaceSpec)
-	filesMeta.setFiles(bh.workers.bundle.GetFiles())
+	if bh.arguments.minimalFilesMetadata {
+		filesMeta.setTarMemberSizes(bh.workers.bundle.GetFiles())
+	} else {
+		filesMeta.setFiles(bh.workers.bundle.GetFiles())
+	}
 	filesMeta.TarFileSets = tarFileSets.Get()
+	filesMeta.TarChecksums = bh.workers.uploader.TarChecksums()
+	for tarName, checksum := range bh.workers.bundle.getCopiedTarChecksums() {
+		filesMeta.TarChecksums[tarName] = checksum
+	}
+	if bh.arguments.withoutFilesMetadata {
+		// the files metadata is not uploaded, the sentinel keeps the checksums to validate the tar members
+		sentinelDto.TarChecksums = filesMeta.TarChecksums
+	}
+	if bh.workers.queryRunner != nil {
+		filesMeta.DatabasesByNames = bh.collectDatabasesByNames()
+		filesMeta.CatalogRelFileNodes = bh.collectCatalogRelFileNodes()
+	}
+	if len(bh.workers.bundle.SkippedUnloggedFiles) > 0 {
+		filesMeta.SkippedUnloggedFiles = bh.workers.bundle.SkippedUnloggedFiles
+		sort.Strings(filesMeta.SkippedUnloggedFiles)
+		tracelog.InfoLogger.Printf("Skipped %d forks of the unlogged relations", len(filesMeta.SkippedUnloggedFiles))
+	}
 	return sentinelDto, filesMeta
 }
 
-func (bh *BackupHandler) markBackups(folder storage.Folder, sentinelDto BackupSentinelDto) {
+// collectDatabasesByNames fetches the database names and OIDs so that a single database can be restored later.
+// Failure is not fatal: the backup itself is consistent, only the partial restore becomes unavailable.
+func (bh *BackupHandler) collectDatabasesByNames() DatabasesByNames {
+	databases, err := bh.workers.queryRunner.getDatabaseInfos()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to collect database names, partial restore will be unavailable: %v", err)
+		return nil
+	}
+	return NewDatabasesByNames(databases)
+}
+
+// collectCatalogRelFileNodes fetches the current relfilenodes of the system catalogs of every database,
+// so that the catalogs-only restore finds the catalogs rewritten by VACUUM FULL or CLUSTER.
+// Failure is not fatal: the catalogs-only restore falls back to the relfilenodes below the first user OID.
+func (bh *BackupHandler) collectCatalogRelFileNodes() CatalogRelFileNodes {
+	databases, err := bh.workers.queryRunner.getDatabaseInfos()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to collect the catalog relfilenodes: %v", err)
+		return nil
+	}
+	catalogRelFileNodes := make(CatalogRelFileNodes, len(databases))
+	for _, db := range databases {
+		relFileNodes, err := fetchCatalogRelFileNodes(db.name)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to collect the catalog relfilenodes of database %s: %v", db.name, err)
+			continue
+		}
+		catalogRelFileNodes[uint32(db.oid)] = relFileNodes
+	}
+	return catalogRelFileNodes
+}
+
+func fetchCatalogRelFileNodes(dbName string) ([]uint32, error) {
+	dbConn, err := Connect(func(c *pgx.ConnConfig) error {
+		c.Database = dbName
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(dbConn, "")
+	queryRunner, err := NewPgQueryRunner(dbConn)
+	if err != nil {
+		return nil, err
+	}
+	return queryRunner.getCatalogRelFileNodes()
+}
+
+func (bh *BackupHandler) markBackups(folder storage.Folder, sentinelDto BackupSentinelDto) error {
 	// If pushing permanent delta backup, mark all previous backups permanent
 	// Do this before uploading current meta to ensure that backups are marked in increasing order
 	if bh.arguments.isPermanent && sentinelDto.IsIncremental() {
 		markBackupHandler := internal.NewBackupMarkHandler(NewGenericMetaInteractor(), folder)
-		markBackupHandler.MarkBackup(bh.prevBackupInfo.name, true)
+		return markBackupHandler.MarkBackup(bh.prevBackupInfo.name, true)
 	}
+	return nil
 }
 
-func (bh *BackupHandler) uploadBackup() internal.TarFileSets {
+func (bh *BackupHandler) uploadBackup() (internal.TarFileSets, error) {
 	bundle := bh.workers.bundle
+	// the files are packed while they are walked, so the pack span covers the walk too
+	packCtx, packSpan := tracing.StartSpan(bh.ctx, "pack", tracing.String("backup.name", bh.curBackupInfo.name))
+	tarFileSets, checkpointer, err := bh.packBackup(packCtx, bundle)
+	if bundle.TarBallQueue != nil {
+		packSpan.SetAttributes(tracing.Int64("backup.uncompressed_bytes",
+			atomic.LoadInt64(bundle.TarBallQueue.AllTarballsSize)))
+	}
+	packSpan.EndWithError(err)
+	if err != nil {
+		return nil, err
+	}
+
+	// the tarballs are uploaded while they are packed, the upload span waits for the rest of them
+	_, uploadSpan := tracing.StartSpan(bh.ctx, "upload", tracing.String("backup.name", bh.curBackupInfo.name))
+	tarFileSets, err = bh.finishUploads(bundle, tarFileSets, checkpointer)
+	uploadSpan.SetAttributes(tracing.Int64("backup.compressed_bytes", bh.curBackupInfo.compressedSize))
+	uploadSpan.EndWithError(err)
+	return tarFileSets, err
+}
+
+// packBackup walks the pgDataDirectory and packs everything there into the tarballs, which are uploaded
+// in the background. The checkpointer is not nil only if the backup checkpoints are enabled.
+func (bh *BackupHandler) packBackup(ctx context.Context, bundle *Bundle) (internal.TarFileSets, *backupCheckpointer, error) {
 	// Start a new tar bundle, walk the pgDataDirectory and upload everything there.
 	tracelog.InfoLogger.Println("Starting a new tar bundle")
 	err := bundle.StartQueue(internal.NewStorageTarBallMaker(bh.curBackupInfo.name, bh.workers.uploader.Uploader))
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bh.workers.progressCollector != nil {
+		bh.workers.progressCollector.setTarBallQueue(bundle.TarBallQueue)
+	}
 
-	tarBallComposerMaker, err := NewTarBallComposerMaker(bh.arguments.tarBallComposerType, bh.workers.queryRunner,
-		bh.workers.uploader.Uploader, bh.curBackupInfo.name,
-		NewTarBallFilePackerOptions(bh.arguments.verifyPageChecksums, bh.arguments.storeAllCorruptBlocks),
-		bh.arguments.withoutFilesMetadata)
-	tracelog.ErrorLogger.FatalOnError(err)
+	tarBallComposerMaker, checkpointTarFileSets, err := bh.chooseTarBallComposerMaker()
+	if err != nil {
+		return nil, nil, err
+	}
+	if bh.arguments.dedupSmallFiles {
+		err = enableSmallFilesDedup(tarBallComposerMaker)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if bh.arguments.splitLargeFiles {
+		err = enableLargeFilesSplit(tarBallComposerMaker)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if bh.arguments.parallelTablespaces {
+		err = enableParallelTablespaces(tarBallComposerMaker)
+		if err != nil {
+			return nil, nil, err
+		}
+		bundle.ParallelTablespaces = true
+	}
 
 	err = bundle.SetupComposer(tarBallComposerMaker)
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var checkpointer *backupCheckpointer
+	if checkpointTarFileSets != nil {
+		checkpointer = newBackupCheckpointer(bh.workers.uploader.UploadingFolder, bh.curBackupInfo.name,
+			bundle.TarBallQueue, checkpointTarFileSets, bundle.TarBallComposer.GetFiles(),
+			viper.GetDuration(internal.BackupCheckpointInterval))
+		checkpointer.start()
+	}
+
+	tarFileSets, err := bh.walkAndPack(ctx, bundle)
+	return tarFileSets, checkpointer, err
+}
+
+// walkAndPack walks the data directory and packs the files into the tarballs,
+// the WAL replay of the standby is paused until all the files are read
+func (bh *BackupHandler) walkAndPack(ctx context.Context, bundle *Bundle) (tarFileSets internal.TarFileSets, err error) {
+	if bh.arguments.whileStandby {
+		// the pause may be requested even if waiting for it fails, so the replay is always resumed,
+		// on the interruption signal or the backup termination it is resumed without waiting for the walk
+		resumer, pauseErr := bh.pauseWalReplay()
+		defer func() {
+			err = resumeWalReplay(resumer, err)
+		}()
+		if err = pauseErr; err != nil {
+			return nil, err
+		}
+	}
 
 	tracelog.InfoLogger.Println("Walking ...")
-	err = filepath.Walk(bh.pgInfo.pgDataDirectory, bundle.HandleWalkedFSObject)
-	tracelog.ErrorLogger.FatalOnError(err)
+	_, walkSpan := tracing.StartSpan(ctx, "walk", tracing.String("backup.name", bh.curBackupInfo.name))
+	err = bundle.Walk()
+	walkSpan.EndWithError(err)
+	if err != nil {
+		return nil, err
+	}
 
 	tracelog.InfoLogger.Println("Packing ...")
-	tarFileSets, err := bundle.FinishTarComposer()
-	tracelog.ErrorLogger.FatalOnError(err)
+	return bundle.FinishTarComposer()
+}
 
+// finishUploads waits for the tarballs to be uploaded, then uploads pg_control and the label files
+func (bh *BackupHandler) finishUploads(bundle *Bundle, tarFileSets internal.TarFileSets,
+	checkpointer *backupCheckpointer) (internal.TarFileSets, error) {
 	tracelog.DebugLogger.Println("Finishing queue ...")
-	err = bundle.FinishQueue()
-	tracelog.ErrorLogger.FatalOnError(err)
+	err := bundle.FinishQueue()
+	if err != nil {
+		return nil, err
+	}
+	if checkpointer != nil {
+		checkpointer.stop()
+	}
 
 	tracelog.DebugLogger.Println("Uploading pg_control ...")
 	err = bundle.UploadPgControl(bh.workers.uploader.Compressor.FileExtension())
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return nil, err
+	}
 
-	// Stops backup and write/upload postgres `backup_label` and `tablespace_map` Files
-	tracelog.DebugLogger.Println("Stop backup and upload backup_label and tablespace_map")
-	labelFilesTarBallName, labelFilesList, finishLsn, err := bundle.uploadLabelFiles(bh.workers.queryRunner)
-	tracelog.ErrorLogger.FatalOnError(err)
-	bh.curBackupInfo.endLSN = finishLsn
+	// the label files of the snapshot are packed by the walk and the backup is already stopped
+	timelineChanged := false
+	if !bh.arguments.fromSnapshot {
+		// Stops backup and write/upload postgres `backup_label` and `tablespace_map` Files
+		tracelog.DebugLogger.Println("Stop backup and upload backup_label and tablespace_map")
+		labelFilesTarBallName, labelFilesList, finishLsn, err := bundle.uploadLabelFiles(bh.workers.queryRunner)
+		if err != nil {
+			return nil, err
+		}
+		bh.curBackupInfo.endLSN = finishLsn
+		bh.curBackupInfo.labelFiles = bundle.labelFiles
+		tarFileSets.AddFiles(labelFilesTarBallName, labelFilesList)
+		timelineChanged = bundle.checkTimelineChanged(bh.workers.queryRunner)
+		tracelog.DebugLogger.Printf("Labelfiles tarball name: %s", labelFilesTarBallName)
+		tracelog.DebugLogger.Printf("Number of label files: %d", len(labelFilesList))
+	}
 	bh.curBackupInfo.uncompressedSize = atomic.LoadInt64(bundle.TarBallQueue.AllTarballsSize)
 	bh.curBackupInfo.compressedSize, err = bh.workers.uploader.UploadedDataSize()
-	tracelog.ErrorLogger.FatalOnError(err)
-	tarFileSets.AddFiles(labelFilesTarBallName, labelFilesList)
-	timelineChanged := bundle.checkTimelineChanged(bh.workers.queryRunner)
-	tracelog.DebugLogger.Printf("Labelfiles tarball name: %s", labelFilesTarBallName)
-	tracelog.DebugLogger.Printf("Number of label files: %d", len(labelFilesList))
+	if err != nil {
+		return nil, err
+	}
 	tracelog.DebugLogger.Printf("Finish LSN: %s", bh.curBackupInfo.endLSN)
 	tracelog.DebugLogger.Printf("Uncompressed size: %d", bh.curBackupInfo.uncompressedSize)
 	tracelog.DebugLogger.Printf("Compressed size: %d", bh.curBackupInfo.compressedSize)
@@ -286,27 +677,200 @@ func (bh *BackupHandler) uploadBackup() internal.TarFileSets {
 	tracelog.DebugLogger.Println("Waiting for all uploads to finish")
 	bh.workers.uploader.Finish()
 	if bh.workers.uploader.Failed.Load().(bool) {
-		tracelog.ErrorLogger.Fatalf("Uploading failed during '%s' backup.\n", bh.curBackupInfo.name)
+		return nil, newBackupUploadError(bh.curBackupInfo.name)
 	}
 	if timelineChanged {
-		tracelog.ErrorLogger.Fatalf("Cannot finish backup because of changed timeline.")
+		return nil, errors.New("Cannot finish backup because of changed timeline.")
 	}
-	return tarFileSets
+	return tarFileSets, nil
 }
 
-// HandleBackupPush handles the backup being read from Postgres or filesystem and being pushed to the repository
-// TODO : unit tests
-func (bh *BackupHandler) HandleBackupPush() {
+// chooseTarBallComposerMaker returns the composer maker for the local backup.
+// The returned tar file sets are not nil only if the backup checkpoints are enabled.
+func (bh *BackupHandler) chooseTarBallComposerMaker() (TarBallComposerMaker, *SynchronizedTarFileSets, error) {
+	verifyConcurrency, err := internal.GetVerifyConcurrency()
+	if err != nil {
+		return nil, nil, err
+	}
+	filePackOptions := NewTarBallFilePackerOptions(bh.arguments.verifyPageChecksums, bh.arguments.storeAllCorruptBlocks,
+		verifyConcurrency)
+	bh.workers.pageChecksums = filePackOptions.pageChecksums
+	if bh.resumedBackup != nil {
+		return NewCopyTarBallComposerMaker(*bh.resumedBackup, bh.curBackupInfo.name, filePackOptions), nil, nil
+	}
+
+	if bh.arguments.minimalFilesMetadata {
+		// the tar members and their sizes are tracked, but the rest of the files metadata is not
+		return NewRegularTarBallComposerMaker(filePackOptions, &internal.MinimalBundleFiles{},
+			internal.NewRegularTarFileSets()), nil, nil
+	}
+
+	checkpointInterval := viper.GetDuration(internal.BackupCheckpointInterval)
+	if checkpointInterval > 0 {
+		if bh.arguments.tarBallComposerType == RegularComposer && !bh.arguments.withoutFilesMetadata &&
+			bh.prevBackupInfo.name == "" {
+			tarFileSets := NewSynchronizedTarFileSets()
+			return NewRegularTarBallComposerMaker(filePackOptions, &internal.RegularBundleFiles{}, tarFileSets),
+				tarFileSets, nil
+		}
+		tracelog.WarningLogger.Printf("%s is supported only for the full backups made by the regular composer "+
+			"with files metadata, checkpoints are disabled", internal.BackupCheckpointInterval)
+	}
+
+	if bh.arguments.tarBallComposerType == RatingComposer && viper.GetBool(internal.ReuseRatingStatsSetting) {
+		if bh.pgInfo.systemIdentifier != nil {
+			tarBallComposerMaker, err := bh.newRatingComposerMakerWithStoredStats(filePackOptions)
+			return tarBallComposerMaker, nil, err
+		}
+		tracelog.WarningLogger.Printf("%s requires the cluster system identifier, the stored statistics are not used",
+			internal.ReuseRatingStatsSetting)
+	}
+
+	tarBallComposerMaker, err := NewTarBallComposerMaker(bh.arguments.tarBallComposerType, bh.workers.queryRunner,
+		bh.workers.uploader.Uploader, bh.curBackupInfo.name, bh.prevBackupInfo.name, filePackOptions,
+		bh.arguments.withoutFilesMetadata)
+	return tarBallComposerMaker, nil, err
+}
+
+// newRatingComposerMakerWithStoredStats makes the rating composer maker which combines
+// the current statistics with the ones stored by the previous backup of the cluster
+func (bh *BackupHandler) newRatingComposerMakerWithStoredStats(
+	filePackOptions TarBallFilePackerOptions) (TarBallComposerMaker, error) {
+	storedStats := loadRelFileStatistics(bh.workers.uploader.UploadingFolder, *bh.pgInfo.systemIdentifier)
+	currentStats, err := newRelFileStatistics(bh.workers.queryRunner)
+	if err != nil {
+		if storedStats == nil {
+			return nil, err
+		}
+		tracelog.WarningLogger.Printf("Failed to collect the relations statistics, using the stored ones: %v", err)
+	}
+	bh.workers.relFileStats = mergeRelFileStatistics(storedStats, currentStats)
+	return NewRatingTarBallComposerMaker(bh.workers.relFileStats, filePackOptions)
+}
+
+// storeRatingStatistics saves the statistics used by the rating composer for the next backups,
+// the failure doesn't affect the finished backup
+func (bh *BackupHandler) storeRatingStatistics() {
+	if bh.workers.relFileStats == nil {
+		return
+	}
+	err := storeRelFileStatistics(bh.workers.uploader.UploadingFolder, *bh.pgInfo.systemIdentifier, bh.workers.relFileStats)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to store the rating statistics: %v", err)
+	}
+}
+
+// cleanupResumeState removes the checkpoint of the finished backup
+// and the unfinished backup which was resumed
+func (bh *BackupHandler) cleanupResumeState() {
+	baseBackupFolder := bh.workers.uploader.UploadingFolder
+	if viper.GetDuration(internal.BackupCheckpointInterval) > 0 {
+		err := baseBackupFolder.DeleteObjects([]string{getResumeStatePath(bh.curBackupInfo.name)})
+		tracelog.WarningLogger.PrintOnError(err)
+	}
+	if bh.resumedBackup != nil {
+		tracelog.InfoLogger.Printf("Deleting the resumed backup %s", bh.resumedBackup.Name)
+		err := deleteBackupObjects(baseBackupFolder, bh.resumedBackup.Name)
+		tracelog.WarningLogger.PrintOnError(err)
+	}
+}
+
+// RunBackupPush makes the backup of the PostgreSQL cluster, read from Postgres or filesystem,
+// and pushes it to the repository with the uploader, as the backup-push command does.
+// The errors are returned instead of exiting the process, internal.ExitCodeOf tells the exit code of backup-push.
+// The running backup is stopped once the context is canceled. The dry run and the verification of the page
+// checksums only return the empty sentinel, the pre-backup and post-backup scripts, if configured, run around
+// the backup except for them.
+func RunBackupPush(ctx context.Context, arguments BackupArguments, uploader *WalUploader) (BackupSentinelDto, error) {
+	err := internal.CheckCrypterSettings()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	bh, err := newBackupHandler(ctx, arguments, uploader)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	defer bh.cancel()
+	var span *tracing.Span
+	bh.ctx, span = tracing.StartSpan(bh.ctx, "backup-push")
+	defer func() {
+		span.SetAttributes(tracing.String("backup.name", bh.curBackupInfo.name))
+		span.End()
+	}()
+	if arguments.dryRun || arguments.verifyChecksumsOnly {
+		sentinelDto, err := bh.handleBackupPush()
+		return sentinelDto, bh.backupError(err)
+	}
+
+	var sentinelDto BackupSentinelDto
+	err = runBackupScript(bh.ctx, internal.PreBackupScriptSetting, nil)
+	if err == nil {
+		sentinelDto, err = bh.handleBackupPush()
+	}
+	err = bh.backupError(err)
+	span.SetError(err)
+	runPostBackupScript(bh.curBackupInfo.name, err)
+	return sentinelDto, err
+}
+
+func (bh *BackupHandler) handleBackupPush() (BackupSentinelDto, error) {
 	folder := bh.workers.uploader.UploadingFolder
 	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
 	tracelog.DebugLogger.Printf("Base backup folder: %s", baseBackupFolder)
 
 	bh.curBackupInfo.startTime = utility.TimeNowCrossPlatformUTC()
+	internal.AddLogFields(internal.LogFields{"operation": "backup-push"})
+	stopMetricsServer := bh.startMetricsServer()
+	defer stopMetricsServer()
 
+	if bh.arguments.fromStdin {
+		tracelog.InfoLogger.Println("Doing full backup of the pg_basebackup tar stream.")
+		return bh.createAndPushStdinBackup(os.Stdin)
+	}
+	if bh.arguments.fromSnapshot {
+		err := checkSnapshotArguments(bh.arguments)
+		if err != nil {
+			return BackupSentinelDto{}, err
+		}
+		tracelog.InfoLogger.Printf("Backing up the snapshot %s without connecting to Postgres.", bh.pgInfo.pgDataDirectory)
+	}
 	if bh.arguments.pgDataDirectory == "" {
+		if bh.arguments.dryRun {
+			return BackupSentinelDto{}, newBackupPushUsageError("Dry run is not available for remote backup, supply [db_directory].")
+		}
+		if bh.arguments.verifyChecksumsOnly {
+			return BackupSentinelDto{}, newBackupPushUsageError(
+				"Verifying the page checksums only is not available for remote backup, supply [db_directory].")
+		}
+		if bh.arguments.resumeBackupName != "" {
+			return BackupSentinelDto{}, newBackupPushUsageError("Resume is not available for remote backup, supply [db_directory].")
+		}
+		if bh.arguments.minimalFilesMetadata {
+			return BackupSentinelDto{}, newBackupPushUsageError(
+				"Minimal files metadata is not available for remote backup, supply [db_directory].")
+		}
+		if bh.arguments.dedupSmallFiles {
+			return BackupSentinelDto{}, newBackupPushUsageError(
+				"Small files deduplication is not available for remote backup, supply [db_directory].")
+		}
+		if bh.arguments.splitLargeFiles {
+			return BackupSentinelDto{}, newBackupPushUsageError(
+				"Splitting the large files is not available for remote backup, supply [db_directory].")
+		}
+		if bh.arguments.skipUnlogged {
+			return BackupSentinelDto{}, newBackupPushUsageError(
+				"Skipping the unlogged relations is not available for remote backup, supply [db_directory].")
+		}
+		if bh.arguments.whileStandby {
+			return BackupSentinelDto{}, newBackupPushUsageError(
+				"Pausing the WAL replay is not available for remote backup, supply [db_directory].")
+		}
+		if bh.arguments.timeout > 0 {
+			return BackupSentinelDto{}, newBackupPushUsageError("Timeout is not available for remote backup, supply [db_directory].")
+		}
 		if bh.arguments.forceIncremental {
 			tracelog.ErrorLogger.Println("Delta backup not available for remote backup.")
-			tracelog.ErrorLogger.Fatal("To run delta backup, supply [db_directory].")
+			return BackupSentinelDto{}, newBackupPushUsageError("To run delta backup, supply [db_directory].")
 		}
 		// If no arg is parsed, try to run remote backup using pglogrepl's BASE_BACKUP functionality
 		tracelog.InfoLogger.Println("Running remote backup through Postgres connection.")
@@ -316,28 +880,98 @@ func (bh *BackupHandler) HandleBackupPush() {
 			tracelog.InfoLogger.Println("VerifyPageChecksums=false is only supported for streaming backup since PG11")
 			bh.arguments.verifyPageChecksums = true
 		}
-		bh.createAndPushRemoteBackup()
-		return
+		return bh.createAndPushRemoteBackup()
 	}
 
+	stopTimeout := bh.startTimeout()
+	defer stopTimeout()
+
 	if utility.ResolveSymlink(bh.arguments.pgDataDirectory) != bh.pgInfo.pgDataDirectory {
-		tracelog.ErrorLogger.Panicf("Data directory read from Postgres (%s) is different than as parsed (%s).",
+		return BackupSentinelDto{}, errors.Errorf("Data directory read from Postgres (%s) is different than as parsed (%s).",
 			bh.arguments.pgDataDirectory, bh.pgInfo.pgDataDirectory)
 	}
-	bh.checkPgVersionAndPgControl()
+	err := bh.checkPgVersionAndPgControl()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	if bh.arguments.verifyChecksumsOnly {
+		return BackupSentinelDto{}, bh.runVerifyChecksumsOnly()
+	}
+	err = checkEnvelopeEncryptionArguments(bh.arguments)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
 
-	if bh.arguments.isFullBackup {
+	if bh.arguments.resumeBackupName != "" {
+		tracelog.InfoLogger.Printf("Resuming backup %s as a new full backup.", bh.arguments.resumeBackupName)
+		resumedBackup, err := loadResumedBackup(baseBackupFolder, bh.arguments.resumeBackupName)
+		if err != nil {
+			return BackupSentinelDto{}, err
+		}
+		bh.resumedBackup = &resumedBackup
+	} else if bh.arguments.isFullBackup {
 		tracelog.InfoLogger.Println("Doing full backup.")
 	} else {
-		err := bh.configureDeltaBackup()
-		tracelog.ErrorLogger.FatalOnError(err)
+		err = bh.configureDeltaBackup()
+		if err != nil {
+			return BackupSentinelDto{}, err
+		}
 	}
 
-	bh.createAndPushBackup()
+	if bh.arguments.dryRun {
+		return BackupSentinelDto{}, bh.runDryRunBackup()
+	}
+	sentinelDto, err := bh.createAndPushBackup()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	if bh.workers.pageChecksums != nil {
+		bh.workers.pageChecksums.getSummary().log()
+	}
+	return sentinelDto, nil
 }
 
-func (bh *BackupHandler) createAndPushRemoteBackup() {
-	var err error
+// runDryRunBackup walks the data directory as the regular backup does,
+// but only reports the files which would be uploaded
+func (bh *BackupHandler) runDryRunBackup() error {
+	tarSizeThreshold, err := internal.GetTarSizeThreshold()
+	if err != nil {
+		return err
+	}
+	bundle := NewBundle(bh.pgInfo.pgDataDirectory, nil, bh.prevBackupInfo.sentinelDto.BackupStartLSN,
+		bh.prevBackupInfo.filesMetadataDto.Files, bh.arguments.forceIncremental, tarSizeThreshold)
+	bundle.SkipUnlogged = bh.arguments.skipUnlogged
+	bundle.DeltaDetection, err = GetDeltaDetection()
+	if err != nil {
+		return err
+	}
+	err = bundle.configureExcludedPaths()
+	if err != nil {
+		return err
+	}
+	err = bundle.configureFollowedSymlinks()
+	if err != nil {
+		return err
+	}
+	report := newDryRunReport()
+	bundle.TarBallComposer = NewDryRunTarBallComposer(report)
+
+	tracelog.InfoLogger.Println("Walking (dry run) ...")
+	err = filepath.Walk(bh.pgInfo.pgDataDirectory, bundle.HandleWalkedFSObject)
+	if err != nil {
+		return err
+	}
+
+	report.ExcludedPaths = bundle.ExcludedPaths
+	err = WriteDryRunReport(report, os.Stdout)
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Println("Dry run finished, nothing was uploaded")
+	return nil
+}
+
+func (bh *BackupHandler) createAndPushRemoteBackup() (BackupSentinelDto, error) {
 	uploader := *bh.workers.uploader
 	uploader.UploadingFolder = uploader.UploadingFolder.GetSubFolder(utility.BaseBackupPath)
 	tracelog.DebugLogger.Printf("Uploading folder: %s", uploader.UploadingFolder)
@@ -349,55 +983,79 @@ func (bh *BackupHandler) createAndPushRemoteBackup() {
 		tarFileSets = internal.NewRegularTarFileSets()
 	}
 
-	baseBackup := bh.runRemoteBackup()
+	baseBackup, err := bh.runRemoteBackup()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
 	tracelog.InfoLogger.Println("Updating metadata")
 	bh.curBackupInfo.startLSN = LSN(baseBackup.StartLSN)
 	bh.curBackupInfo.endLSN = LSN(baseBackup.EndLSN)
 
 	bh.curBackupInfo.uncompressedSize = baseBackup.UncompressedSize
 	bh.curBackupInfo.compressedSize, err = bh.workers.uploader.UploadedDataSize()
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
 	sentinelDto := NewBackupSentinelDto(bh, baseBackup.GetTablespaceSpec())
 	filesMetadataDto := NewFilesMetadataDto(baseBackup.Files, tarFileSets)
 	bh.curBackupInfo.name = baseBackup.BackupName()
 	tracelog.InfoLogger.Println("Uploading metadata")
-	bh.uploadMetadata(sentinelDto, filesMetadataDto)
+	err = bh.uploadMetadata(sentinelDto, filesMetadataDto)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
 	// logging backup set name
-	tracelog.InfoLogger.Printf("Wrote backup with name %s", bh.curBackupInfo.name)
+	internal.AddLogFields(internal.LogFields{"backup_name": bh.curBackupInfo.name})
+	internal.InfoLogWithFields(internal.LogFields{
+		"uncompressed_size": bh.curBackupInfo.uncompressedSize,
+		"compressed_size":   bh.curBackupInfo.compressedSize,
+	}, "Wrote backup with name %s", bh.curBackupInfo.name)
+	return sentinelDto, nil
 }
 
-func (bh *BackupHandler) uploadMetadata(sentinelDto BackupSentinelDto, filesMetaDto FilesMetadataDto) {
+func (bh *BackupHandler) uploadMetadata(sentinelDto BackupSentinelDto, filesMetaDto FilesMetadataDto) error {
 	curBackupName := bh.curBackupInfo.name
 	meta := NewExtendedMetadataDto(bh.arguments.isPermanent, bh.pgInfo.pgDataDirectory,
 		bh.curBackupInfo.startTime, sentinelDto)
 
 	err := bh.uploadExtendedMetadata(meta)
 	if err != nil {
-		tracelog.ErrorLogger.Fatalf("Failed to upload metadata file for backup %s: %v", curBackupName, err)
+		return errors.Wrapf(err, "Failed to upload metadata file for backup %s", curBackupName)
 	}
 	err = bh.uploadFilesMetadata(filesMetaDto)
 	if err != nil {
-		tracelog.ErrorLogger.Fatalf("Failed to upload files metadata for backup %s: %v", curBackupName, err)
+		return errors.Wrapf(err, "Failed to upload files metadata for backup %s", curBackupName)
 	}
-	err = internal.UploadSentinel(bh.workers.uploader, NewBackupSentinelDtoV2(sentinelDto, meta), bh.curBackupInfo.name)
+	err = bh.uploadLabelFileObjects()
 	if err != nil {
-		tracelog.ErrorLogger.Fatalf("Failed to upload sentinel file for backup %s: %v", curBackupName, err)
+		return errors.Wrapf(err, "Failed to upload label files for backup %s", curBackupName)
 	}
+	err = internal.UploadSentinel(bh.workers.uploader, NewBackupSentinelDtoV2(sentinelDto, meta), bh.curBackupInfo.name)
+	return errors.Wrapf(err, "Failed to upload sentinel file for backup %s", curBackupName)
 }
 
 // NewBackupHandler returns a backup handler object, which can handle the backup
 func NewBackupHandler(arguments BackupArguments) (bh *BackupHandler, err error) {
-	// RemoteBackup is triggered by not passing PGDATA to wal-g,
-	// and version cannot be read easily using replication connection.
-	// Retrieve both with this helper function which uses a temp connection to postgres.
-
 	uploader, err := ConfigureWalUploader()
 	if err != nil {
 		return bh, err
 	}
-	pgInfo, err := getPgServerInfo()
+	return newBackupHandler(context.Background(), arguments, uploader)
+}
+
+func newBackupHandler(ctx context.Context, arguments BackupArguments, uploader *WalUploader) (*BackupHandler, error) {
+	// RemoteBackup is triggered by not passing PGDATA to wal-g,
+	// and version cannot be read easily using replication connection.
+	// Retrieve both with this helper function which uses a temp connection to postgres.
+	var pgInfo BackupPgInfo
+	var err error
+	if arguments.fromSnapshot {
+		pgInfo, err = getSnapshotPgInfo(arguments.pgDataDirectory)
+	} else {
+		pgInfo, err = getPgServerInfo()
+	}
 	if err != nil {
-		return bh, err
+		return nil, err
 	}
 
 	if arguments.pgDataDirectory != "" && arguments.pgDataDirectory != pgInfo.pgDataDirectory {
@@ -406,18 +1064,18 @@ func NewBackupHandler(arguments BackupArguments) (bh *BackupHandler, err error)
 		tracelog.WarningLogger.Println(warning)
 	}
 
-	bh = &BackupHandler{
+	bh := &BackupHandler{
 		arguments: arguments,
 		workers: BackupWorkers{
 			uploader: uploader,
 		},
 		pgInfo: pgInfo,
 	}
-
-	return bh, err
+	bh.ctx, bh.cancel = context.WithCancel(ctx)
+	return bh, nil
 }
 
-func (bh *BackupHandler) runRemoteBackup() *StreamingBaseBackup {
+func (bh *BackupHandler) runRemoteBackup() (*StreamingBaseBackup, error) {
 	var diskLimit int32
 	if viper.IsSet(internal.DiskRateLimitSetting) {
 		// Note that BASE_BACKUP (pg protocol) allows to limit in kb/sec
@@ -429,10 +1087,16 @@ func (bh *BackupHandler) runRemoteBackup() *StreamingBaseBackup {
 	}
 	// Connect to postgres and start/finish a nonexclusive backup.
 	tracelog.DebugLogger.Println("Connecting to Postgres (replication connection)")
-	conn, err := pgconn.Connect(context.Background(), "replication=yes")
-	tracelog.ErrorLogger.FatalOnError(err)
+	conn, err := pgconn.Connect(bh.ctx, "replication=yes")
+	if err != nil {
+		return nil, err
+	}
 
-	baseBackup := NewStreamingBaseBackup(bh.pgInfo.pgDataDirectory, viper.GetInt64(internal.TarSizeThresholdSetting), conn)
+	tarSizeThreshold, err := internal.GetTarSizeThreshold()
+	if err != nil {
+		return nil, err
+	}
+	baseBackup := NewStreamingBaseBackup(bh.pgInfo.pgDataDirectory, tarSizeThreshold, conn)
 	var bundleFiles internal.BundleFiles
 	if bh.arguments.withoutFilesMetadata {
 		bundleFiles = &internal.NopBundleFiles{}
@@ -441,21 +1105,26 @@ func (bh *BackupHandler) runRemoteBackup() *StreamingBaseBackup {
 	}
 	tracelog.InfoLogger.Println("Starting remote backup")
 	err = baseBackup.Start(bh.arguments.verifyPageChecksums, diskLimit)
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return nil, err
+	}
 
 	tracelog.InfoLogger.Println("Streaming remote backup")
 	err = baseBackup.Upload(bh.workers.uploader, bundleFiles)
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return nil, err
+	}
 
 	tracelog.InfoLogger.Println("Finishing backup")
 	tracelog.InfoLogger.Println("If wal-g hangs during this step, please Postgres log file for details.")
 	err = baseBackup.Finish()
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return nil, err
+	}
 
 	tracelog.DebugLogger.Println("Closing Postgres connection (replication connection)")
 	err = conn.Close(context.Background())
-	tracelog.ErrorLogger.FatalOnError(err)
-	return baseBackup
+	return baseBackup, err
 }
 
 func getPgServerInfo() (pgInfo BackupPgInfo, err error) {
@@ -501,9 +1170,9 @@ func getPgServerInfo() (pgInfo BackupPgInfo, err error) {
 }
 
 func (bh *BackupHandler) configureDeltaBackup() (err error) {
-	maxDeltas, fromFull := getDeltaConfig()
-	if maxDeltas == 0 {
-		return nil
+	maxDeltas, fromFull, err := getDeltaConfig()
+	if err != nil || maxDeltas == 0 {
+		return err
 	}
 
 	folder := bh.workers.uploader.UploadingFolder
@@ -519,7 +1188,9 @@ func (bh *BackupHandler) configureDeltaBackup() (err error) {
 
 	previousBackup := NewBackup(baseBackupFolder, previousBackupName)
 	prevBackupSentinelDto, err := previousBackup.GetSentinel()
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return err
+	}
 
 	if prevBackupSentinelDto.IncrementCount != nil {
 		bh.curBackupInfo.incrementCount = *prevBackupSentinelDto.IncrementCount + 1
@@ -538,6 +1209,11 @@ func (bh *BackupHandler) configureDeltaBackup() (err error) {
 		return nil
 	}
 
+	if bh.arguments.fullIfOlderThan > 0 && bh.isFullBackupTooOld(baseBackupFolder, previousBackupName,
+		prevBackupSentinelDto) {
+		return nil
+	}
+
 	previousBackupMeta, err := previousBackup.FetchMeta()
 	if err != nil {
 		tracelog.InfoLogger.Printf(
@@ -571,9 +1247,36 @@ func (bh *BackupHandler) configureDeltaBackup() (err error) {
 	return err
 }
 
+// isFullBackupTooOld checks whether the full backup of the delta chain ending
+// with the delta base is older than the WALG_FULL_IF_OLDER_THAN threshold
+func (bh *BackupHandler) isFullBackupTooOld(baseBackupFolder storage.Folder, previousBackupName string,
+	prevBackupSentinelDto BackupSentinelDto) bool {
+	fullBackupName := previousBackupName
+	if prevBackupSentinelDto.IncrementFullName != nil {
+		fullBackupName = *prevBackupSentinelDto.IncrementFullName
+	}
+	fullBackup := NewBackup(baseBackupFolder, fullBackupName)
+	fullBackupMeta, err := fullBackup.FetchMeta()
+	if err != nil {
+		tracelog.InfoLogger.Printf(
+			"Failed to get the metadata of the full backup %s: %s. Doing full backup.\n", fullBackupName, err.Error())
+		return true
+	}
+
+	age := utility.TimeNowCrossPlatformUTC().Sub(fullBackupMeta.StartTime).Round(time.Second)
+	if age > bh.arguments.fullIfOlderThan {
+		tracelog.InfoLogger.Printf("The full backup %s of the delta base %s is %s old, "+
+			"older than %s. Doing full backup.\n", fullBackupName, previousBackupName, age, bh.arguments.fullIfOlderThan)
+		return true
+	}
+	tracelog.InfoLogger.Printf("The full backup %s of the delta base %s is %s old, not older than %s.\n",
+		fullBackupName, previousBackupName, age, bh.arguments.fullIfOlderThan)
+	return false
+}
+
 // TODO : unit tests
 func (bh *BackupHandler) uploadExtendedMetadata(meta ExtendedMetadataDto) (err error) {
-	metaFile := storage.JoinPath(bh.curBackupInfo.name, utility.MetadataFileName)
+	metaFile := internal.MetadataNameFromBackup(bh.curBackupInfo.name)
 	dtoBody, err := json.Marshal(meta)
 	if err != nil {
 		return internal.NewSentinelMarshallingError(metaFile, err)
@@ -595,47 +1298,81 @@ func (bh *BackupHandler) uploadFilesMetadata(filesMetaDto FilesMetadataDto) (err
 	return bh.workers.uploader.Upload(getFilesMetadataPath(bh.curBackupInfo.name), bytes.NewReader(dtoBody))
 }
 
-func (bh *BackupHandler) checkPgVersionAndPgControl() {
+func (bh *BackupHandler) checkPgVersionAndPgControl() error {
 	_, err := os.ReadFile(filepath.Join(bh.pgInfo.pgDataDirectory, PgControlPath))
-	tracelog.ErrorLogger.FatalfOnError(
-		"It looks like you are trying to backup not pg_data. PgControl file not found: %v\n", err)
+	if err != nil {
+		return errors.Errorf("It looks like you are trying to backup not pg_data. PgControl file not found: %v", err)
+	}
 	_, err = os.ReadFile(filepath.Join(bh.pgInfo.pgDataDirectory, "PG_VERSION"))
-	tracelog.ErrorLogger.FatalfOnError(
-		"It looks like you are trying to backup not pg_data. PG_VERSION file not found: %v\n", err)
+	if err != nil {
+		return errors.Errorf("It looks like you are trying to backup not pg_data. PG_VERSION file not found: %v", err)
+	}
+	return nil
 }
 
-func (bh *BackupHandler) initBackupTerminator() {
+// initBackupTerminator stops the running backup once the handler context is canceled or the PG alive check fails,
+// the failed check cancels the context, so the backup returns the error of the check
+func (bh *BackupHandler) initBackupTerminator() error {
 	errCh := make(chan error, 1)
-
-	addSignalListener(errCh)
-	addPgIsAliveChecker(bh.workers.queryRunner, errCh)
+	err := addPgIsAliveChecker(bh.workers.queryRunner, errCh)
+	if err != nil {
+		return err
+	}
 
 	terminator := NewBackupTerminator(bh.workers.queryRunner, bh.pgInfo.pgVersion, bh.pgInfo.pgDataDirectory)
 
+	bh.terminated = make(chan struct{})
 	go func() {
-		err := <-errCh
-		tracelog.ErrorLogger.Printf("Error: %v, gracefully stopping the running backup...", err)
+		defer close(bh.terminated)
+		select {
+		case err := <-errCh:
+			bh.terminateErr.Store(err)
+		case <-bh.ctx.Done():
+			// the backup which exceeded the timeout is aborted by abortTimedOutBackup
+			if bh.timedOut() {
+				return
+			}
+		}
+		bh.timeout.mutex.Lock()
+		finishing := bh.timeout.finishing
+		bh.timeout.mutex.Unlock()
+		if finishing {
+			return
+		}
+		tracelog.ErrorLogger.Printf("Error: %v, gracefully stopping the running backup...", bh.terminationCause())
 		terminator.TerminateBackup()
-		tracelog.ErrorLogger.Fatal("Finished backup termination, will now exit")
+		bh.cancel()
 	}()
+	return nil
 }
 
-func addSignalListener(errCh chan error) {
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+// terminationCause returns the error which made the backup stop before it is finished
+func (bh *BackupHandler) terminationCause() error {
+	if err, ok := bh.terminateErr.Load().(error); ok {
+		return err
+	}
+	return bh.ctx.Err()
+}
 
+// NewInterruptContext returns the context which is canceled by the first interruption signal,
+// the next signal terminates the process as usual
+func NewInterruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	go func() {
-		sig := <-sigCh
-		errCh <- fmt.Errorf("received interruption signal: %s", sig)
+		<-ctx.Done()
+		stop()
 	}()
+	return ctx, stop
 }
 
-func addPgIsAliveChecker(queryRunner *PgQueryRunner, errCh chan error) {
+func addPgIsAliveChecker(queryRunner *PgQueryRunner, errCh chan error) error {
 	if !viper.IsSet(internal.PgAliveCheckInterval) {
-		return
+		return nil
 	}
 	stateUpdateInterval, err := internal.GetDurationSetting(internal.PgAliveCheckInterval)
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return err
+	}
 	tracelog.InfoLogger.Printf("Initializing the PG alive checker (interval=%s)...", stateUpdateInterval)
 	pgWatcher := NewPgWatcher(queryRunner, stateUpdateInterval)
 
@@ -643,4 +1380,5 @@ func addPgIsAliveChecker(queryRunner *PgQueryRunner, errCh chan error) {
 		err := <-pgWatcher.Err
 		errCh <- fmt.Errorf("PG alive check failed: %v", err)
 	}()
+	return nil
 }
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/webserver"
+)
+
+const metricsServerShutdownTimeout = 5 * time.Second
+
+type backupPushMetrics struct {
+	readBytesTotal   prometheus.Counter
+	packedFilesTotal prometheus.Counter
+}
+
+var BackupPushMetrics = backupPushMetrics{
+	readBytesTotal: prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: internal.WalgMetricsPrefix + "backup_read_bytes_total",
+			Help: "Number of bytes read from the data directory and packed into tar members.",
+		},
+	),
+
+	packedFilesTotal: prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: internal.WalgMetricsPrefix + "backup_packed_files_total",
+			Help: "Number of files packed into tar members.",
+		},
+	),
+}
+
+func init() {
+	prometheus.MustRegister(BackupPushMetrics.readBytesTotal)
+	prometheus.MustRegister(BackupPushMetrics.packedFilesTotal)
+}
+
+// backupProgressCollector reports the state of the running backup at the scrape time
+type backupProgressCollector struct {
+	uploader *internal.Uploader
+	// tarBallQueue is set once the bundle starts its queue
+	tarBallQueue atomic.Value
+
+	uploadedBytesDesc *prometheus.Desc
+	queueDepthDesc    *prometheus.Desc
+}
+
+func newBackupProgressCollector(uploader *internal.Uploader) *backupProgressCollector {
+	return &backupProgressCollector{
+		uploader: uploader,
+		uploadedBytesDesc: prometheus.NewDesc(internal.WalgMetricsPrefix+"backup_uploaded_bytes_total",
+			"Number of compressed bytes uploaded to storage.", nil, nil),
+		queueDepthDesc: prometheus.NewDesc(internal.WalgMetricsPrefix+"backup_upload_queue_depth",
+			"Number of finished tar members waiting for their upload to complete.", nil, nil),
+	}
+}
+
+func (c *backupProgressCollector) setTarBallQueue(queue *internal.TarBallQueue) {
+	c.tarBallQueue.Store(queue)
+}
+
+func (c *backupProgressCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.uploadedBytesDesc
+	ch <- c.queueDepthDesc
+}
+
+func (c *backupProgressCollector) Collect(ch chan<- prometheus.Metric) {
+	if uploadedBytes, err := c.uploader.UploadedDataSize(); err == nil {
+		ch <- prometheus.MustNewConstMetric(c.uploadedBytesDesc, prometheus.CounterValue, float64(uploadedBytes))
+	}
+	queueDepth := 0
+	if queue, ok := c.tarBallQueue.Load().(*internal.TarBallQueue); ok {
+		queueDepth = queue.UploadQueueLen()
+	}
+	ch <- prometheus.MustNewConstMetric(c.queueDepthDesc, prometheus.GaugeValue, float64(queueDepth))
+}
+
+// startMetricsServer exposes the backup-push metrics on the WALG_METRICS_ADDRESS if it is set.
+// Returns the function which stops the server.
+func (bh *BackupHandler) startMetricsServer() (stop func()) {
+	address := viper.GetString(internal.MetricsAddressSetting)
+	if address == "" {
+		return func() {}
+	}
+
+	bh.workers.progressCollector = newBackupProgressCollector(bh.workers.uploader.Uploader)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(bh.workers.progressCollector)
+	handler := promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, registry}, promhttp.HandlerOpts{})
+
+	ws := webserver.NewSimpleWebServer(address)
+	ws.HandleFunc("/metrics", handler.ServeHTTP)
+	if err := ws.Serve(); err != nil {
+		tracelog.WarningLogger.Printf("Failed to start the metrics server: %v", err)
+		return func() {}
+	}
+	tracelog.InfoLogger.Printf("Serving backup metrics on %s/metrics", address)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsServerShutdownTimeout)
+		defer cancel()
+		err := ws.Shutdown(ctx)
+		tracelog.WarningLogger.PrintOnError(err)
+	}
+}
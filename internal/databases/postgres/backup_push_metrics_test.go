@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func TestBackupProgressCollector(t *testing.T) {
+	uploader := internal.NewUploader(compression.Compressors[lz4.AlgorithmName],
+		memory.NewFolder("", memory.NewStorage()))
+	collector := newBackupProgressCollector(uploader)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	err := uploader.Upload("part_001.tar.lz4", strings.NewReader("0123456789"))
+	assert.NoError(t, err)
+
+	expected := `
+# HELP walg_backup_upload_queue_depth Number of finished tar members waiting for their upload to complete.
+# TYPE walg_backup_upload_queue_depth gauge
+walg_backup_upload_queue_depth 0
+# HELP walg_backup_uploaded_bytes_total Number of compressed bytes uploaded to storage.
+# TYPE walg_backup_uploaded_bytes_total counter
+walg_backup_uploaded_bytes_total 10
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// tarFailingFolder fails the uploads of the tar members
+type tarFailingFolder struct {
+	storage.Folder
+}
+
+func (folder tarFailingFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return tarFailingFolder{folder.Folder.GetSubFolder(subFolderRelativePath)}
+}
+
+func (folder tarFailingFolder) PutObject(name string, content io.Reader) error {
+	if strings.Contains(name, internal.TarPartitionFolderName) {
+		return errors.New("storage is unavailable")
+	}
+	return folder.Folder.PutObject(name, content)
+}
+
+func TestRunBackupPush_ReturnsUploadError(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putTestBackupHistoryFile(t, folder, "000000010000000000000004.00000028.backup",
+		"START WAL LOCATION: 0/4000028 (file 000000010000000000000004)\n"+
+			"STOP WAL LOCATION: 0/4000138 (file 000000010000000000000004)\n"+
+			"CHECKPOINT LOCATION: 0/4000060\nBACKUP METHOD: streamed\n")
+	arguments := BackupArguments{
+		pgDataDirectory:     writeTestSnapshot(t, snapshotBackupLabel),
+		backupsFolder:       utility.BaseBackupPath,
+		isFullBackup:        true,
+		tarBallComposerType: RegularComposer,
+		deltaBaseSelector:   internal.NewLatestBackupSelector(),
+		fromSnapshot:        true,
+	}
+	uploader := &WalUploader{Uploader: internal.NewUploader(&lz4.Compressor{}, tarFailingFolder{folder})}
+
+	_, err := RunBackupPush(context.Background(), arguments, uploader)
+	require.Error(t, err)
+	assert.Equal(t, internal.ExitCodeStorageIO, internal.ExitCodeOf(err))
+	exists, err := folder.GetSubFolder(utility.BaseBackupPath).
+		Exists(internal.SentinelNameFromBackup("base_000000010000000000000004"))
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestCheckEnvelopeEncryptionArguments(t *testing.T) {
+	assert.NoError(t, checkEnvelopeEncryptionArguments(BackupArguments{tarBallComposerType: CopyComposer}))
+
+	viper.Set(internal.EnvelopeKeySetting, "AQIDBAUGBwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHyA=")
+	defer viper.Set(internal.EnvelopeKeySetting, nil)
+	assert.NoError(t, checkEnvelopeEncryptionArguments(BackupArguments{tarBallComposerType: RegularComposer}))
+	assert.IsType(t, BackupPushUsageError{},
+		checkEnvelopeEncryptionArguments(BackupArguments{tarBallComposerType: CopyComposer}))
+	assert.IsType(t, BackupPushUsageError{}, checkEnvelopeEncryptionArguments(
+		BackupArguments{tarBallComposerType: RegularComposer, resumeBackupName: "base_000000010000000000000002"}))
+}
@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// GetPgRehydrateFetcher wraps the fetcher to rehydrate the archived objects of the backup and its delta bases
+// before anything is extracted, so the rehydrations of all the objects run at once instead of one by one
+// as the objects are read. Only the storages which archive the objects, e.g. Azure, do anything here.
+func GetPgRehydrateFetcher(fetcher func(rootFolder storage.Folder, backup internal.Backup),
+) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		err := rehydrateBackup(ToPgBackup(backup))
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+
+		fetcher(rootFolder, backup)
+	}
+}
+
+func rehydrateBackup(backup Backup) error {
+	chain, err := GetDeltaChain(backup)
+	if err != nil {
+		return err
+	}
+	return storage.RehydrateSubFolders(backup.Folder, chain)
+}
@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// rehydrateFolder records the subfolders it is asked to rehydrate
+type rehydrateFolder struct {
+	storage.Folder
+	rehydrated *[]string
+}
+
+func (folder rehydrateFolder) RehydrateSubFolders(subFolderRelativePaths []string) error {
+	*folder.rehydrated = append(*folder.rehydrated, subFolderRelativePaths...)
+	return nil
+}
+
+func TestRehydrateBackup_RehydratesDeltaChainAtOnce(t *testing.T) {
+	var rehydrated []string
+	folder := rehydrateFolder{memory.NewFolder("", memory.NewStorage()).GetSubFolder(utility.BaseBackupPath), &rehydrated}
+	putDeltaChain(t, folder, "base_000000010000000000000002", "base_000000010000000000000004_D_000000010000000000000002")
+
+	err := rehydrateBackup(NewBackup(folder, "base_000000010000000000000004_D_000000010000000000000002"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"base_000000010000000000000002", "base_000000010000000000000004_D_000000010000000000000002"},
+		rehydrated)
+}
@@ -0,0 +1,285 @@
+package postgres
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type BackupRepairRefusedError struct {
+	error
+}
+
+func newBackupRepairRefusedError(backupName string, reasons map[string]string) BackupRepairRefusedError {
+	fileNames := make([]string, 0, len(reasons))
+	for fileName := range reasons {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	problems := make([]string, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		problems = append(problems, fmt.Sprintf("%s: %s", fileName, reasons[fileName]))
+	}
+	return BackupRepairRefusedError{errors.Errorf(
+		"unable to repair backup %s from the data directory, %d files can't be repacked as they are backed up:\n%s",
+		backupName, len(fileNames), strings.Join(problems, "\n"))}
+}
+
+func (err BackupRepairRefusedError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err BackupRepairRefusedError) ExitCode() int {
+	return internal.ExitCodeDataCorruption
+}
+
+// HandleBackupRepair re-uploads the missing and corrupt tar members of the backup from the data directory
+// of the running cluster it is taken from
+func HandleBackupRepair(uploader *internal.Uploader, backupSelector internal.BackupSelector, dataDirectory string) {
+	folder := uploader.UploadingFolder
+	backupName, err := backupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+	uploader.ChangeDirectory(utility.BaseBackupPath)
+
+	repairedTars, err := RepairBackup(NewBackup(uploader.UploadingFolder, backupName),
+		utility.ResolveSymlink(dataDirectory), uploader, internal.ConfigureCrypter())
+	internal.FatalOnError(err)
+	if len(repairedTars) == 0 {
+		tracelog.InfoLogger.Printf("Backup %s is verified successfully, nothing to repair", backupName)
+		return
+	}
+	tracelog.InfoLogger.Printf("Backup %s is repaired, re-uploaded tar members: %s",
+		backupName, strings.Join(repairedTars, ", "))
+}
+
+// RepairBackup verifies the backup and re-packs its missing and corrupt tar members from the data directory.
+// Every file of these tar members must still be in the data directory as it was backed up: the regular files
+// must have the checksums recorded in the files metadata and no pages newer than the backup start LSN,
+// otherwise nothing is uploaded. The repacked tar members and their checksums are recorded in the files metadata.
+// Returns the names of the re-uploaded tar members.
+func RepairBackup(backup Backup, dataDirectory string, uploader *internal.Uploader,
+	crypter crypto.Crypter) ([]string, error) {
+	sentinelDto, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if sentinelDto.FilesMetadataDisabled || sentinelDto.FilesMetadataMinimal {
+		return nil, errors.Errorf("backup %s has no files metadata to repair it by", backup.Name)
+	}
+	if sentinelDto.SystemIdentifier == nil {
+		return nil, errors.Errorf("backup %s has no system identifier, "+
+			"unable to verify that it is taken from the cluster being repaired from", backup.Name)
+	}
+	err = verifyBackupDiffSystemIdentifier(dataDirectory, sentinelDto)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := VerifyBackup(backup)
+	if err != nil {
+		return nil, err
+	}
+	if report.IsOk() {
+		return nil, nil
+	}
+	refusals := make(map[string]string)
+	if !report.HasPgControl {
+		refusals[PgControlPath] = "copied by the backup at its end, not read from the data directory"
+	}
+	if !report.HasBackupLabel {
+		refusals[BackupLabelFilename] = "made by the backup, not read from the data directory"
+	}
+	tarNames := getTarsToRepair(report)
+	var startLSN LSN
+	if sentinelDto.BackupStartLSN != nil {
+		startLSN = *sentinelDto.BackupStartLSN
+	}
+	for _, tarName := range tarNames {
+		for _, fileName := range filesMeta.TarFileSets[tarName] {
+			reason, err := checkRepairableFile(dataDirectory, fileName, filesMeta.Files, startLSN)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				refusals[fileName] = reason
+			}
+		}
+	}
+	if len(refusals) > 0 {
+		return nil, newBackupRepairRefusedError(backup.Name, refusals)
+	}
+
+	tarBallMaker := internal.NewStorageTarBallMaker(backup.Name, uploader)
+	repairedTars := make([]string, 0, len(tarNames))
+	// the replaced tar members are deleted only once the files metadata refers to the repaired ones,
+	// so the backup stays restorable if the repair fails in between
+	replacedTars := make([]string, 0)
+	for _, tarName := range tarNames {
+		newTarName := getRepairedTarName(tarName, uploader.Compressor.FileExtension())
+		tracelog.InfoLogger.Printf("Repacking tar member %s of backup %s as %s", tarName, backup.Name, newTarName)
+		err = repackTar(tarBallMaker.Make(false), crypter, newTarName, dataDirectory,
+			filesMeta.TarFileSets[tarName], filesMeta.Files)
+		if err != nil {
+			// the partially repacked tar member is a valid tar, so it must not be left in place of the broken one
+			deleteErr := backup.getTarPartitionFolder().DeleteObjects([]string{newTarName})
+			tracelog.ErrorLogger.PrintOnError(deleteErr)
+			return nil, err
+		}
+		if newTarName != tarName {
+			replacedTars = append(replacedTars, tarName)
+			filesMeta.TarFileSets[newTarName] = filesMeta.TarFileSets[tarName]
+			delete(filesMeta.TarFileSets, tarName)
+			delete(filesMeta.TarChecksums, tarName)
+		}
+		repairedTars = append(repairedTars, newTarName)
+	}
+
+	if filesMeta.TarChecksums == nil {
+		filesMeta.TarChecksums = make(map[string]internal.ObjectChecksum)
+	}
+	for tarName, checksum := range uploader.TarChecksums() {
+		filesMeta.TarChecksums[tarName] = checksum
+	}
+	err = internal.UploadDto(backup.Folder, filesMeta, getFilesMetadataPath(backup.Name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to upload the files metadata of backup %s", backup.Name)
+	}
+	backup.FilesMetadataDto = &filesMeta
+	if len(replacedTars) > 0 {
+		err = backup.getTarPartitionFolder().DeleteObjects(replacedTars)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to delete the replaced tar members of backup %s", backup.Name)
+		}
+	}
+	return repairedTars, nil
+}
+
+// getTarsToRepair returns the tar members which are missing in storage, corrupt, or lack some files
+func getTarsToRepair(report *BackupVerifyReport) []string {
+	tarNames := append([]string{}, report.MissingTars...)
+	for tarName := range report.CorruptTars {
+		tarNames = append(tarNames, tarName)
+	}
+	for tarName := range report.MissingFiles {
+		tarNames = append(tarNames, tarName)
+	}
+	sort.Strings(tarNames)
+	return tarNames
+}
+
+// getRepairedTarName replaces the compression extension of the tar member with the one it is repacked with,
+// since the tar members are decompressed by their extensions
+func getRepairedTarName(tarName, fileExtension string) string {
+	extensionStart := strings.LastIndex(tarName, ".tar.")
+	if extensionStart == -1 {
+		return tarName
+	}
+	return tarName[:extensionStart] + ".tar." + fileExtension
+}
+
+// checkRepairableFile returns the reason why the file of the data directory can't be packed instead
+// of the backed up one, or an empty string if it is the same file
+func checkRepairableFile(dataDirectory, fileName string, files internal.BackupFileList, startLSN LSN) (string, error) {
+	fileDescription, ok := files[fileName]
+	if !ok {
+		return "not read from the data directory by the backup", nil
+	}
+	localPath := filepath.Join(dataDirectory, fileName)
+	localInfo, err := os.Lstat(localPath)
+	if os.IsNotExist(err) {
+		return "deleted after the backup", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to stat file: '%s'", localPath)
+	}
+	if !localInfo.Mode().IsRegular() {
+		// the directories and the links are packed as the headers only
+		return "", nil
+	}
+	if fileDescription.IsIncremented {
+		return "stored as an increment", nil
+	}
+	if fileDescription.Crc32c == nil {
+		return "no checksum recorded", nil
+	}
+	changeReason, differenceReason, err := diffLocalFile(localPath, localInfo, fileDescription, startLSN)
+	if err != nil {
+		return "", err
+	}
+	if changeReason != "" {
+		return changeReason, nil
+	}
+	return differenceReason, nil
+}
+
+// repackTar packs the files of the data directory into the tar member, the checksums of the regular files
+// are verified again while packing, since they may be changed after the check
+func repackTar(tarBall internal.TarBall, crypter crypto.Crypter, tarName, dataDirectory string,
+	fileNames []string, files internal.BackupFileList) error {
+	tarBall.SetUp(crypter, tarName)
+	for _, fileName := range fileNames {
+		err := repackFile(tarBall, dataDirectory, fileName, files[fileName])
+		if err != nil {
+			_ = tarBall.CloseTar()
+			_ = tarBall.AwaitUploads()
+			return err
+		}
+	}
+	err := tarBall.CloseTar()
+	if err != nil {
+		return err
+	}
+	return tarBall.AwaitUploads()
+}
+
+func repackFile(tarBall internal.TarBall, dataDirectory, fileName string,
+	fileDescription internal.BackupFileDescription) error {
+	localPath := filepath.Join(dataDirectory, fileName)
+	localInfo, err := os.Lstat(localPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat file: '%s'", localPath)
+	}
+	var link string
+	if localInfo.Mode()&os.ModeSymlink != 0 {
+		link, err = os.Readlink(localPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read link: '%s'", localPath)
+		}
+	}
+	header, err := tar.FileInfoHeader(localInfo, link)
+	if err != nil {
+		return errors.Wrapf(err, "failed to make the tar header of file: '%s'", localPath)
+	}
+	header.Name = fileName
+	if !localInfo.Mode().IsRegular() {
+		return tarBall.TarWriter().WriteHeader(header)
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file: '%s'", localPath)
+	}
+	defer utility.LoggedClose(file, "")
+	fileChecksumReader := newChecksumReader(io.LimitReader(file, header.Size))
+	packedSize, err := internal.PackFileTo(tarBall, header, fileChecksumReader)
+	if err != nil {
+		return err
+	}
+	if packedSize != header.Size {
+		return errors.Errorf("file '%s' is truncated while repacking", localPath)
+	}
+	if actual := fileChecksumReader.checksum(); actual != *fileDescription.Crc32c {
+		return newFileChecksumMismatchError(fileName, *fileDescription.Crc32c, actual)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package postgres_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/testtools"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const repairedSystemIdentifier = uint64(7123456789)
+
+// newRepairedDataDirectory makes the data directory with the pg_control of the backed up cluster
+// and the files of the backup, their contents are their names as in the putTarMember tar members
+func newRepairedDataDirectory(t *testing.T, fileNames ...string) (string, internal.BackupFileList) {
+	dataDirectory := t.TempDir()
+	pgControl := make([]byte, 8192)
+	binary.LittleEndian.PutUint64(pgControl, repairedSystemIdentifier)
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDirectory, "global"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, postgres.PgControlPath), pgControl, 0600))
+	files := internal.BackupFileList{}
+	for _, fileName := range fileNames {
+		files[fileName] = writeDiffedFile(t, dataDirectory, fileName, []byte(fileName), []byte(fileName), diffedBackupMTime)
+	}
+	return dataDirectory, files
+}
+
+func newRepairedBackup(t *testing.T, files internal.BackupFileList) (postgres.Backup, *internal.Uploader) {
+	uploader := testtools.NewStoringMockUploader(memory.NewStorage(), nil)
+	uploader.Compressor = lz4.Compressor{}
+	uploader.ChangeDirectory(utility.BaseBackupPath)
+	folder := uploader.UploadingFolder
+	putTarMember(t, folder, "part_001.tar", "/base/1/1259")
+	putTarMember(t, folder, "part_003.tar", postgres.BackupLabelFilename)
+	putTarMember(t, folder, "pg_control.tar", postgres.PgControlPath)
+
+	systemIdentifier := repairedSystemIdentifier
+	startLSN := postgres.LSN(0x2000000)
+	backup := newVerifiedBackup(folder, map[string][]string{
+		"part_001.tar":     {"/base/1/1259"},
+		"part_002.tar.lz4": {"/base/1/2608", "/global/1262"},
+		"part_003.tar":     {postgres.BackupLabelFilename},
+	})
+	backup.SentinelDto.SystemIdentifier = &systemIdentifier
+	backup.SentinelDto.BackupStartLSN = &startLSN
+	backup.FilesMetadataDto.Files = files
+	return backup, uploader
+}
+
+func TestRepairBackup(t *testing.T) {
+	dataDirectory, files := newRepairedDataDirectory(t, "/base/1/1259", "/base/1/2608", "/global/1262")
+	backup, uploader := newRepairedBackup(t, files)
+
+	repairedTars, err := postgres.RepairBackup(backup, dataDirectory, uploader, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"part_002.tar.lz4"}, repairedTars)
+
+	repairedBackup := postgres.NewBackup(backup.Folder, backup.Name)
+	repairedBackup.SentinelDto = backup.SentinelDto
+	report, err := postgres.VerifyBackup(repairedBackup)
+	require.NoError(t, err)
+	assert.True(t, report.IsOk())
+	_, filesMeta, err := repairedBackup.GetSentinelAndFilesMetadata()
+	require.NoError(t, err)
+	assert.Contains(t, filesMeta.TarChecksums, "part_002.tar.lz4")
+
+	repairedTars, err = postgres.RepairBackup(repairedBackup, dataDirectory, uploader, nil)
+	require.NoError(t, err)
+	assert.Empty(t, repairedTars)
+}
+
+func TestRepairBackup_ReplacesTarWithOtherCompression(t *testing.T) {
+	dataDirectory, files := newRepairedDataDirectory(t, "/base/1/1259", "/base/1/2608", "/global/1262")
+	backup, uploader := newRepairedBackup(t, files)
+	// the tar member compressed by the other method is corrupt, it is repacked by the current compression
+	backup.FilesMetadataDto.TarFileSets["part_001.tar.zst"] = backup.FilesMetadataDto.TarFileSets["part_001.tar"]
+	delete(backup.FilesMetadataDto.TarFileSets, "part_001.tar")
+	tarPartitionFolder := backup.Folder.GetSubFolder(backup.Name + internal.TarPartitionFolderName)
+	require.NoError(t, tarPartitionFolder.PutObject("part_001.tar.zst", strings.NewReader("corrupt")))
+
+	repairedTars, err := postgres.RepairBackup(backup, dataDirectory, uploader, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"part_001.tar.lz4", "part_002.tar.lz4"}, repairedTars)
+
+	// the replaced tar member is deleted after the files metadata is updated
+	exists, err := tarPartitionFolder.Exists("part_001.tar.zst")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	repairedBackup := postgres.NewBackup(backup.Folder, backup.Name)
+	repairedBackup.SentinelDto = backup.SentinelDto
+	_, filesMeta, err := repairedBackup.GetSentinelAndFilesMetadata()
+	require.NoError(t, err)
+	assert.NotContains(t, filesMeta.TarFileSets, "part_001.tar.zst")
+	assert.Equal(t, []string{"/base/1/1259"}, filesMeta.TarFileSets["part_001.tar.lz4"])
+}
+
+func TestRepairBackup_ChangedFile(t *testing.T) {
+	dataDirectory, files := newRepairedDataDirectory(t, "/base/1/1259", "/base/1/2608", "/global/1262")
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, "global", "1262"), []byte("changed"), 0600))
+	backup, uploader := newRepairedBackup(t, files)
+
+	_, err := postgres.RepairBackup(backup, dataDirectory, uploader, nil)
+	assert.IsType(t, postgres.BackupRepairRefusedError{}, err)
+	assert.Contains(t, err.Error(), "/global/1262")
+	exists, err := backup.Folder.Exists(backup.Name + internal.TarPartitionFolderName + "part_002.tar.lz4")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRepairBackup_OtherCluster(t *testing.T) {
+	dataDirectory, files := newRepairedDataDirectory(t, "/base/1/1259", "/base/1/2608", "/global/1262")
+	backup, uploader := newRepairedBackup(t, files)
+	otherSystemIdentifier := repairedSystemIdentifier + 1
+	backup.SentinelDto.SystemIdentifier = &otherSystemIdentifier
+
+	_, err := postgres.RepairBackup(backup, dataDirectory, uploader, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const ResumeStateName = "resume_state.json"
+
+type NoResumeStateError struct {
+	error
+}
+
+func newNoResumeStateError(backupName string, err error) NoResumeStateError {
+	return NoResumeStateError{errors.Wrapf(err, "can't resume backup %s: failed to fetch %s", backupName, ResumeStateName)}
+}
+
+func (err NoResumeStateError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+type MissingTarsError struct {
+	error
+}
+
+func newMissingTarsError(backupName string, missingTars []string) MissingTarsError {
+	return MissingTarsError{errors.Errorf("backup %s is incomplete, tar members not found in storage: %v",
+		backupName, missingTars)}
+}
+
+func (err MissingTarsError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// ResumeStateDto is the checkpoint of the unfinished backup.
+// It describes the tarballs which were finished during the backup,
+// so they can be reused by the backup-push --resume.
+type ResumeStateDto struct {
+	Files       internal.BackupFileList `json:"Files"`
+	TarFileSets map[string][]string     `json:"TarFileSets"`
+}
+
+func getResumeStatePath(backupName string) string {
+	return backupName + "/" + ResumeStateName
+}
+
+// SynchronizedTarFileSets is the TarFileSets which can be read while the composer is still adding files
+type SynchronizedTarFileSets struct {
+	internal.RegularTarFileSets
+	mutex sync.Mutex
+}
+
+func NewSynchronizedTarFileSets() *SynchronizedTarFileSets {
+	return &SynchronizedTarFileSets{RegularTarFileSets: make(internal.RegularTarFileSets)}
+}
+
+func (tarFileSets *SynchronizedTarFileSets) AddFile(name string, file string) {
+	tarFileSets.mutex.Lock()
+	defer tarFileSets.mutex.Unlock()
+	tarFileSets.RegularTarFileSets.AddFile(name, file)
+}
+
+func (tarFileSets *SynchronizedTarFileSets) AddFiles(name string, files []string) {
+	tarFileSets.mutex.Lock()
+	defer tarFileSets.mutex.Unlock()
+	tarFileSets.RegularTarFileSets.AddFiles(name, files)
+}
+
+// GetFiles returns the copy of the file set of the specified tarball
+func (tarFileSets *SynchronizedTarFileSets) GetFiles(name string) []string {
+	tarFileSets.mutex.Lock()
+	defer tarFileSets.mutex.Unlock()
+	files := tarFileSets.RegularTarFileSets[name]
+	result := make([]string, len(files))
+	copy(result, files)
+	return result
+}
+
+// backupCheckpointer periodically uploads the ResumeStateDto of the running backup
+type backupCheckpointer struct {
+	folder      storage.Folder
+	backupName  string
+	queue       *internal.TarBallQueue
+	tarFileSets *SynchronizedTarFileSets
+	files       internal.BundleFiles
+	interval    time.Duration
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func newBackupCheckpointer(folder storage.Folder, backupName string, queue *internal.TarBallQueue,
+	tarFileSets *SynchronizedTarFileSets, files internal.BundleFiles, interval time.Duration) *backupCheckpointer {
+	return &backupCheckpointer{
+		folder:      folder,
+		backupName:  backupName,
+		queue:       queue,
+		tarFileSets: tarFileSets,
+		files:       files,
+		interval:    interval,
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+}
+
+func (c *backupCheckpointer) start() {
+	go func() {
+		defer close(c.doneCh)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stopCh:
+				return
+			case <-ticker.C:
+				err := c.checkpoint()
+				if err != nil {
+					tracelog.WarningLogger.Printf("Failed to upload the backup checkpoint: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// stop waits for the running checkpoint to finish
+func (c *backupCheckpointer) stop() {
+	close(c.stopCh)
+	<-c.doneCh
+}
+
+func (c *backupCheckpointer) checkpoint() error {
+	state := ResumeStateDto{
+		Files:       make(internal.BackupFileList),
+		TarFileSets: make(map[string][]string),
+	}
+	filesMap := c.files.GetUnderlyingMap()
+	for _, tarName := range c.queue.FinishedTarBallNames() {
+		tarFiles := c.tarFileSets.GetFiles(tarName)
+		for _, fileName := range tarFiles {
+			description, ok := filesMap.Load(fileName)
+			if !ok {
+				return errors.Errorf("no description found for the file %s of %s", fileName, tarName)
+			}
+			state.Files[fileName] = description.(internal.BackupFileDescription)
+		}
+		state.TarFileSets[tarName] = tarFiles
+	}
+	tracelog.DebugLogger.Printf("Uploading the backup checkpoint with %d tarballs", len(state.TarFileSets))
+	return internal.UploadDto(c.folder, state, getResumeStatePath(c.backupName))
+}
+
+// loadResumedBackup builds the Backup from the checkpoint of the unfinished backup.
+// Only the tarballs which exist in storage are kept.
+func loadResumedBackup(baseBackupFolder storage.Folder, backupName string) (Backup, error) {
+	var state ResumeStateDto
+	err := internal.FetchDto(baseBackupFolder, &state, getResumeStatePath(backupName))
+	if err != nil {
+		return Backup{}, newNoResumeStateError(backupName, err)
+	}
+
+	backup := NewBackup(baseBackupFolder, backupName)
+	uploadedTars, err := backup.GetTarNames()
+	if err != nil {
+		return Backup{}, err
+	}
+
+	filesMeta := FilesMetadataDto{Files: make(internal.BackupFileList), TarFileSets: make(map[string][]string)}
+	for _, tarName := range uploadedTars {
+		tarFiles, ok := state.TarFileSets[tarName]
+		if !ok {
+			continue
+		}
+		filesMeta.TarFileSets[tarName] = tarFiles
+		for _, fileName := range tarFiles {
+			filesMeta.Files[fileName] = state.Files[fileName]
+		}
+	}
+	tracelog.InfoLogger.Printf("Resuming backup %s: %d of %d checkpointed tarballs found in storage",
+		backupName, len(filesMeta.TarFileSets), len(state.TarFileSets))
+
+	backup.SentinelDto = &BackupSentinelDto{}
+	backup.FilesMetadataDto = &filesMeta
+	return backup, nil
+}
+
+// checkTarsUploaded makes sure that every tarball of the backup exists in storage
+func checkTarsUploaded(backup Backup, tarFileSets internal.TarFileSets) error {
+	uploadedTars, err := backup.GetTarNames()
+	if err != nil {
+		return err
+	}
+	uploaded := make(map[string]bool, len(uploadedTars))
+	for _, tarName := range uploadedTars {
+		uploaded[tarName] = true
+	}
+	var missingTars []string
+	for tarName := range tarFileSets.Get() {
+		if !uploaded[tarName] {
+			missingTars = append(missingTars, tarName)
+		}
+	}
+	if len(missingTars) > 0 {
+		return newMissingTarsError(backup.Name, missingTars)
+	}
+	return nil
+}
+
+// deleteBackupObjects removes all the objects of the unfinished backup
+func deleteBackupObjects(baseBackupFolder storage.Folder, backupName string) error {
+	objects, err := storage.ListFolderRecursively(baseBackupFolder.GetSubFolder(backupName))
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, path.Join(backupName, object.GetName()))
+	}
+	return baseBackupFolder.DeleteObjects(keys)
+}
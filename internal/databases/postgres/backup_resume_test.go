@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const resumedBackupName = "base_000000010000000000000002"
+
+func putResumedBackup(t *testing.T, folder storage.Folder) {
+	state := ResumeStateDto{
+		Files: internal.BackupFileList{
+			"/base/1/1259": {MTime: time.Unix(100, 0)},
+			"/global/1262": {MTime: time.Unix(200, 0)},
+		},
+		TarFileSets: map[string][]string{
+			"part_001.tar.lz4": {"/base/1/1259"},
+			"part_002.tar.lz4": {"/global/1262"},
+		},
+	}
+	assert.NoError(t, internal.UploadDto(folder, state, getResumeStatePath(resumedBackupName)))
+	assert.NoError(t, folder.PutObject(resumedBackupName+internal.TarPartitionFolderName+"part_001.tar.lz4",
+		strings.NewReader("part_001")))
+}
+
+func TestLoadResumedBackup_KeepsOnlyUploadedTars(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putResumedBackup(t, folder)
+
+	backup, err := loadResumedBackup(folder, resumedBackupName)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"part_001.tar.lz4": {"/base/1/1259"}}, backup.FilesMetadataDto.TarFileSets)
+	assert.Equal(t, 1, len(backup.FilesMetadataDto.Files))
+	assert.True(t, backup.FilesMetadataDto.Files["/base/1/1259"].MTime.Equal(time.Unix(100, 0)))
+
+	_, _, err = backup.GetSentinelAndFilesMetadata()
+	assert.NoError(t, err)
+}
+
+func TestLoadResumedBackup_NoResumeState(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+
+	_, err := loadResumedBackup(folder, resumedBackupName)
+	assert.IsType(t, NoResumeStateError{}, err)
+}
+
+func TestCheckTarsUploaded(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putResumedBackup(t, folder)
+	backup := NewBackup(folder, resumedBackupName)
+
+	tarFileSets := internal.NewRegularTarFileSets()
+	tarFileSets.AddFile("part_001.tar.lz4", "/base/1/1259")
+	assert.NoError(t, checkTarsUploaded(backup, tarFileSets))
+
+	tarFileSets.AddFile("part_002.tar.lz4", "/global/1262")
+	assert.IsType(t, MissingTarsError{}, checkTarsUploaded(backup, tarFileSets))
+}
+
+func TestDeleteBackupObjects(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putResumedBackup(t, folder)
+	assert.NoError(t, folder.PutObject("base_000000010000000000000004/"+ResumeStateName, strings.NewReader("{}")))
+
+	assert.NoError(t, deleteBackupObjects(folder, resumedBackupName))
+
+	objects, err := storage.ListFolderRecursively(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(objects))
+	assert.Equal(t, "base_000000010000000000000004/"+ResumeStateName, objects[0].GetName())
+}
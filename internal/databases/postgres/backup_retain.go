@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// BackupRetention is the hold placed by backup-retain, which keeps the backup permanent along with
+// the WAL segments its restore needs
+type BackupRetention struct {
+	// Until is the time the hold expires at, the hold never expires if it is not set
+	Until *time.Time `json:"until,omitempty"`
+	// WalStart and WalEnd are the first and the last WAL segments pinned by the hold
+	WalStart string `json:"wal_start"`
+	WalEnd   string `json:"wal_end"`
+}
+
+func newBackupRetention(backupName string, meta ExtendedMetadataDto, until *time.Time) (*BackupRetention, error) {
+	timelineID, err := ParseTimelineFromBackupName(backupName)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupRetention{
+		Until:    until,
+		WalStart: newWalSegmentNo(meta.StartLsn - 1).getFilename(timelineID),
+		WalEnd:   newWalSegmentNo(meta.FinishLsn - 1).getFilename(timelineID),
+	}, nil
+}
+
+// isExpired tells if the hold is over, so the backup is no longer kept permanent by it
+func (retention *BackupRetention) isExpired(now time.Time) bool {
+	return retention != nil && retention.Until != nil && !now.Before(*retention.Until)
+}
+
+// getWalSegments returns the names of the WAL segments pinned by the hold
+func (retention *BackupRetention) getWalSegments() ([]string, error) {
+	timelineID, startSegmentNo, err := ParseWALFilename(retention.WalStart)
+	if err != nil {
+		return nil, err
+	}
+	_, endSegmentNo, err := ParseWALFilename(retention.WalEnd)
+	if err != nil {
+		return nil, err
+	}
+	var walSegments []string
+	endWalSegmentNo := WalSegmentNo(endSegmentNo)
+	for walSegmentNo := WalSegmentNo(startSegmentNo); walSegmentNo <= endWalSegmentNo; walSegmentNo = walSegmentNo.next() {
+		walSegments = append(walSegments, walSegmentNo.getFilename(timelineID))
+	}
+	return walSegments, nil
+}
+
+// getLaterExpiry returns the later of the hold expiry times, the unset expiry is the latest one
+func getLaterExpiry(expiry1, expiry2 *time.Time) *time.Time {
+	if expiry1 == nil || expiry2 == nil {
+		return nil
+	}
+	if expiry1.After(*expiry2) {
+		return expiry1
+	}
+	return expiry2
+}
+
+// HandleBackupRetain places the hold on the backup until the expiry time, or indefinitely if it is nil
+func HandleBackupRetain(folder storage.Folder, backupSelector internal.BackupSelector, until *time.Time) {
+	backupName, err := backupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	retentions, err := RetainBackup(folder.GetSubFolder(utility.BaseBackupPath), backupName, until)
+	tracelog.ErrorLogger.FatalOnError(err)
+	names := make([]string, 0, len(retentions))
+	for name := range retentions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		retention := retentions[name]
+		expiry := "indefinitely"
+		if retention.Until != nil {
+			expiry = "until " + internal.FormatTime(*retention.Until)
+		}
+		tracelog.InfoLogger.Printf("Backup %s is retained %s with WAL segments %s - %s",
+			name, expiry, retention.WalStart, retention.WalEnd)
+	}
+}
+
+// RetainBackup marks the backup and the backups of its delta chain permanent and records the holds with the WAL
+// segments they pin in their metadata. The existing hold is extended, never shortened, and the backups marked
+// permanent by backup-mark stay permanent indefinitely. Returns the holds by the backup names.
+func RetainBackup(baseBackupFolder storage.Folder, backupName string,
+	until *time.Time) (map[string]BackupRetention, error) {
+	chain, err := GetDeltaChain(NewBackup(baseBackupFolder, backupName))
+	if err != nil {
+		return nil, err
+	}
+	now := utility.TimeNowCrossPlatformUTC()
+	retentions := make(map[string]BackupRetention, len(chain))
+	for _, name := range chain {
+		backup := NewBackup(baseBackupFolder, name)
+		var meta ExtendedMetadataDto
+		err = backup.FetchMetadata(&meta)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch the metadata of backup %s", name)
+		}
+		expiry := until
+		switch {
+		case meta.IsPermanent && meta.Retention == nil:
+			if until != nil {
+				tracelog.WarningLogger.Printf("Backup %s is marked permanent by backup-mark, "+
+					"it is retained indefinitely\n", name)
+			}
+			expiry = nil
+		case meta.Retention != nil && !meta.Retention.isExpired(now):
+			expiry = getLaterExpiry(meta.Retention.Until, until)
+		}
+		retention, err := newBackupRetention(name, meta, expiry)
+		if err != nil {
+			return nil, err
+		}
+		meta.IsPermanent = true
+		meta.Retention = retention
+		err = backup.UploadMetadata(meta)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to retain backup %s", name)
+		}
+		retentions[name] = *retention
+	}
+	return retentions, nil
+}
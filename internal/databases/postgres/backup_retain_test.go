@@ -0,0 +1,153 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/testtools"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	retainFullBackup  = "base_000000010000000000000002"
+	retainDeltaBackup = "base_000000010000000000000004_D_000000010000000000000002"
+)
+
+func putRetainTestBackups(t *testing.T, folder storage.Folder, backups backupInfo) {
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	for backupName, backupData := range backups {
+		sentinelBytes, err := json.Marshal(backupData.sentinel)
+		require.NoError(t, err)
+		require.NoError(t, baseBackupFolder.PutObject(backupName+utility.SentinelSuffix, bytes.NewReader(sentinelBytes)))
+		metaBytes, err := json.Marshal(backupData.meta)
+		require.NoError(t, err)
+		require.NoError(t, baseBackupFolder.PutObject(backupName+"/"+utility.MetadataFileName,
+			bytes.NewReader(metaBytes)))
+	}
+}
+
+func newRetainTestBackups(fullMeta, deltaMeta postgres.ExtendedMetadataDto) backupInfo {
+	fullMeta.StartLsn, fullMeta.FinishLsn = 0x2000028, 0x2000200
+	deltaMeta.StartLsn, deltaMeta.FinishLsn = 0x4000028, 0x5000100
+	return backupInfo{
+		retainFullBackup: {meta: fullMeta},
+		retainDeltaBackup: {
+			meta: deltaMeta,
+			sentinel: postgres.BackupSentinelDto{
+				IncrementFrom:     func(s string) *string { return &s }(retainFullBackup),
+				IncrementFromLSN:  func(i postgres.LSN) *postgres.LSN { return &i }(0x2000028),
+				IncrementFullName: func(s string) *string { return &s }(retainFullBackup),
+				IncrementCount:    func(i int) *int { return &i }(1),
+			},
+		},
+	}
+}
+
+func fetchRetainTestMeta(t *testing.T, folder storage.Folder, backupName string) postgres.ExtendedMetadataDto {
+	backup := postgres.NewBackup(folder.GetSubFolder(utility.BaseBackupPath), backupName)
+	meta, err := backup.FetchMeta()
+	require.NoError(t, err)
+	return meta
+}
+
+func TestRetainBackup_PinsDeltaChain(t *testing.T) {
+	folder := testtools.MakeDefaultInMemoryStorageFolder()
+	putRetainTestBackups(t, folder, newRetainTestBackups(postgres.ExtendedMetadataDto{}, postgres.ExtendedMetadataDto{}))
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	retentions, err := postgres.RetainBackup(folder.GetSubFolder(utility.BaseBackupPath), retainDeltaBackup, &until)
+	require.NoError(t, err)
+	require.Len(t, retentions, 2)
+	assert.Equal(t, "000000010000000000000004", retentions[retainDeltaBackup].WalStart)
+	assert.Equal(t, "000000010000000000000005", retentions[retainDeltaBackup].WalEnd)
+	assert.Equal(t, "000000010000000000000002", retentions[retainFullBackup].WalStart)
+
+	for _, backupName := range []string{retainFullBackup, retainDeltaBackup} {
+		meta := fetchRetainTestMeta(t, folder, backupName)
+		assert.True(t, meta.IsPermanent, backupName)
+		require.NotNil(t, meta.Retention, backupName)
+		assert.True(t, until.Equal(*meta.Retention.Until), backupName)
+	}
+
+	permanentBackups, permanentWals := postgres.GetPermanentBackupsAndWals(folder)
+	assert.Equal(t, map[string]bool{retainFullBackup: true, retainDeltaBackup: true}, permanentBackups)
+	assert.Equal(t, map[string]bool{
+		"000000010000000000000002": true,
+		"000000010000000000000004": true,
+		"000000010000000000000005": true,
+	}, permanentWals)
+}
+
+func TestRetainBackup_ExpiredHold(t *testing.T) {
+	folder := testtools.MakeDefaultInMemoryStorageFolder()
+	expired := time.Now().Add(-time.Hour)
+	retention := &postgres.BackupRetention{Until: &expired,
+		WalStart: "000000010000000000000002", WalEnd: "000000010000000000000002"}
+	putRetainTestBackups(t, folder, newRetainTestBackups(
+		postgres.ExtendedMetadataDto{IsPermanent: true, Retention: retention}, postgres.ExtendedMetadataDto{}))
+
+	assert.False(t, fetchRetainTestMeta(t, folder, retainFullBackup).IsPermanent)
+	permanentBackups, permanentWals := postgres.GetPermanentBackupsAndWals(folder)
+	assert.Empty(t, permanentBackups)
+	assert.Empty(t, permanentWals)
+
+	// the new hold replaces the expired one
+	until := time.Now().Add(time.Hour)
+	retentions, err := postgres.RetainBackup(folder.GetSubFolder(utility.BaseBackupPath), retainFullBackup, &until)
+	require.NoError(t, err)
+	assert.True(t, until.Equal(*retentions[retainFullBackup].Until))
+	assert.True(t, fetchRetainTestMeta(t, folder, retainFullBackup).IsPermanent)
+}
+
+func TestRetainBackup_KeepsLongerHolds(t *testing.T) {
+	folder := testtools.MakeDefaultInMemoryStorageFolder()
+	later := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	retention := &postgres.BackupRetention{Until: &later,
+		WalStart: "000000010000000000000002", WalEnd: "000000010000000000000002"}
+	// the full backup is held longer, the delta backup is marked permanent by backup-mark
+	putRetainTestBackups(t, folder, newRetainTestBackups(
+		postgres.ExtendedMetadataDto{IsPermanent: true, Retention: retention},
+		postgres.ExtendedMetadataDto{IsPermanent: true}))
+
+	until := time.Now().Add(time.Hour)
+	retentions, err := postgres.RetainBackup(folder.GetSubFolder(utility.BaseBackupPath), retainDeltaBackup, &until)
+	require.NoError(t, err)
+	assert.True(t, later.Equal(*retentions[retainFullBackup].Until))
+	assert.Nil(t, retentions[retainDeltaBackup].Until)
+}
+
+func TestRetainedWalCheck(t *testing.T) {
+	folder := testtools.MakeDefaultInMemoryStorageFolder()
+	putRetainTestBackups(t, folder, newRetainTestBackups(postgres.ExtendedMetadataDto{}, postgres.ExtendedMetadataDto{}))
+	_, err := postgres.RetainBackup(folder.GetSubFolder(utility.BaseBackupPath), retainDeltaBackup, nil)
+	require.NoError(t, err)
+
+	walFilenames := []string{"000000010000000000000002.lz4", "000000010000000000000004.lz4"}
+	runner, err := postgres.BuildWalVerifyCheckRunner(postgres.WalVerifyRetainedCheck, folder, walFilenames,
+		postgres.WalSegmentDescription{}, "", false)
+	require.NoError(t, err)
+	result, err := runner.Run()
+	require.NoError(t, err)
+	assert.Equal(t, postgres.StatusFailure, result.Status)
+
+	details := result.Details.(postgres.RetainedWalCheckDetails)
+	require.Len(t, details, 2)
+	assert.Equal(t, retainFullBackup, details[0].BackupName)
+	assert.Empty(t, details[0].MissingSegments)
+	assert.Equal(t, retainDeltaBackup, details[1].BackupName)
+	assert.Equal(t, []string{"000000010000000000000005"}, details[1].MissingSegments)
+
+	walFilenames = append(walFilenames, "000000010000000000000005.lz4")
+	runner, err = postgres.BuildWalVerifyCheckRunner(postgres.WalVerifyRetainedCheck, folder, walFilenames,
+		postgres.WalSegmentDescription{}, "", false)
+	require.NoError(t, err)
+	result, err = runner.Run()
+	require.NoError(t, err)
+	assert.Equal(t, postgres.StatusOk, result.Status)
+}
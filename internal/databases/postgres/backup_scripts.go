@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+)
+
+const (
+	BackupStatusEnv = "WALG_BACKUP_STATUS"
+	BackupErrorEnv  = "WALG_BACKUP_ERROR"
+	BackupNameEnv   = "WALG_BACKUP_NAME"
+
+	backupStatusSucceeded = "succeeded"
+	backupStatusFailed    = "failed"
+)
+
+type BackupScriptError struct {
+	error
+}
+
+func newBackupScriptError(setting string, err error) BackupScriptError {
+	return BackupScriptError{errors.Wrapf(err, "%s failed", setting)}
+}
+
+func (err BackupScriptError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// scriptOutputLogger writes the output of the backup script into the WAL-G log line by line
+type scriptOutputLogger struct {
+	setting string
+	buffer  []byte
+}
+
+func (logger *scriptOutputLogger) Write(p []byte) (int, error) {
+	logger.buffer = append(logger.buffer, p...)
+	for {
+		lineEnd := bytes.IndexByte(logger.buffer, '\n')
+		if lineEnd < 0 {
+			return len(p), nil
+		}
+		tracelog.InfoLogger.Printf("%s: %s\n", logger.setting, logger.buffer[:lineEnd])
+		logger.buffer = logger.buffer[lineEnd+1:]
+	}
+}
+
+// flush logs the last line which is not terminated by the newline
+func (logger *scriptOutputLogger) flush() {
+	if len(logger.buffer) > 0 {
+		tracelog.InfoLogger.Printf("%s: %s\n", logger.setting, logger.buffer)
+		logger.buffer = nil
+	}
+}
+
+// runBackupScript runs the script configured by the setting with the additional environment variables,
+// both stdout and stderr of the script are written into the WAL-G log. The script which is not configured is skipped.
+func runBackupScript(ctx context.Context, setting string, env []string) error {
+	if script, ok := internal.GetSetting(setting); !ok || script == "" {
+		return nil
+	}
+	cmd, err := internal.GetCommandSettingContext(ctx, setting)
+	if err != nil {
+		return newBackupScriptError(setting, err)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	output := &scriptOutputLogger{setting: setting}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	tracelog.InfoLogger.Printf("Running %s\n", setting)
+	err = cmd.Run()
+	output.flush()
+	if err != nil {
+		return newBackupScriptError(setting, err)
+	}
+	return nil
+}
+
+// runPostBackupScript reports the result of the backup to the post-backup script,
+// its failure is only logged since the backup itself is already finished
+func runPostBackupScript(backupName string, backupErr error) {
+	env := []string{BackupStatusEnv + "=" + backupStatusSucceeded, BackupNameEnv + "=" + backupName}
+	if backupErr != nil {
+		env = []string{BackupStatusEnv + "=" + backupStatusFailed, BackupErrorEnv + "=" + backupErr.Error(),
+			BackupNameEnv + "=" + backupName}
+	}
+	// the post-backup script runs even if the backup is interrupted
+	err := runBackupScript(context.Background(), internal.PostBackupScriptSetting, env)
+	if err != nil {
+		tracelog.ErrorLogger.Printf("%v\n", err)
+	}
+}
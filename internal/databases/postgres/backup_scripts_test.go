@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+)
+
+func TestRunBackupScript(t *testing.T) {
+	defer viper.Set(internal.PreBackupScriptSetting, "")
+
+	viper.Set(internal.PreBackupScriptSetting, "")
+	assert.NoError(t, runBackupScript(context.Background(), internal.PreBackupScriptSetting, nil))
+
+	outputFile := filepath.Join(t.TempDir(), "output")
+	viper.Set(internal.PreBackupScriptSetting, "echo flushed; echo \"$EXTRA\" > "+outputFile)
+	assert.NoError(t, runBackupScript(context.Background(), internal.PreBackupScriptSetting, []string{"EXTRA=value"}))
+	content, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "value\n", string(content))
+
+	viper.Set(internal.PreBackupScriptSetting, "echo quiesce failed >&2; exit 3")
+	err = runBackupScript(context.Background(), internal.PreBackupScriptSetting, nil)
+	assert.IsType(t, BackupScriptError{}, err)
+}
+
+func TestRunPostBackupScript(t *testing.T) {
+	defer viper.Set(internal.PostBackupScriptSetting, "")
+	outputFile := filepath.Join(t.TempDir(), "output")
+	viper.Set(internal.PostBackupScriptSetting,
+		"echo \"$WALG_BACKUP_STATUS $WALG_BACKUP_NAME $WALG_BACKUP_ERROR\" > "+outputFile)
+
+	runPostBackupScript("base_000000010000000000000002", nil)
+	content, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "succeeded base_000000010000000000000002 \n", string(content))
+
+	runPostBackupScript("", errors.New("connection lost"))
+	content, err = os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "failed  connection lost\n", string(content))
+}
+
+func TestScriptOutputLogger(t *testing.T) {
+	output := &scriptOutputLogger{setting: internal.PreBackupScriptSetting}
+	_, err := output.Write([]byte("first line\nsecond "))
+	assert.NoError(t, err)
+	assert.Equal(t, "second ", string(output.buffer))
+	_, err = output.Write([]byte("line\nlast"))
+	assert.NoError(t, err)
+	assert.Equal(t, "last", string(output.buffer))
+	output.flush()
+	assert.Empty(t, output.buffer)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/wal-g/wal-g/utility"
 
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
 )
 
 const MetadataDatetimeFormat = "%Y-%m-%dT%H:%M:%S.%fZ"
@@ -24,6 +25,8 @@ type BackupSentinelDto struct {
 	PgVersion        int     `json:"PgVersion"`
 	BackupFinishLSN  *LSN    `json:"FinishLSN"`
 	SystemIdentifier *uint64 `json:"SystemIdentifier,omitempty"`
+	// Hostname is the host the backup is taken on, it is not recorded by the older versions
+	Hostname string `json:"Hostname,omitempty"`
 
 	UncompressedSize int64           `json:"UncompressedSize"`
 	CompressedSize   int64           `json:"CompressedSize"`
@@ -32,6 +35,21 @@ type BackupSentinelDto struct {
 	UserData interface{} `json:"UserData,omitempty"`
 
 	FilesMetadataDisabled bool `json:"FilesMetadataDisabled,omitempty"`
+	// TarChecksums are recorded here instead of the files metadata if FilesMetadataDisabled is set
+	TarChecksums map[string]internal.ObjectChecksum `json:"TarChecksums,omitempty"`
+	// FilesMetadataMinimal is set if only the tar members and their sizes are recorded in the files metadata
+	FilesMetadataMinimal bool `json:"FilesMetadataMinimal,omitempty"`
+
+	// WalUnverified is set if the backup is taken without waiting for its WAL to be archived
+	WalUnverified bool `json:"wal_unverified,omitempty"`
+
+	// PageChecksums is set only if the page checksums were verified during the backup
+	PageChecksums *PageChecksumsSummary `json:"PageChecksums,omitempty"`
+
+	// EncryptionKey is the data key of the tarballs wrapped by the envelope master key
+	EncryptionKey *envelope.WrappedKey `json:"EncryptionKey,omitempty"`
+
+	Stats *BackupStats `json:"Stats,omitempty"`
 }
 
 func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSentinelDto {
@@ -54,9 +72,19 @@ func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSent
 	sentinel.BackupFinishLSN = &bh.curBackupInfo.endLSN
 	sentinel.UserData = bh.arguments.userData
 	sentinel.SystemIdentifier = bh.pgInfo.systemIdentifier
+	sentinel.Hostname = getHostname()
+	sentinel.EncryptionKey = bh.curBackupInfo.encryptionKey
 	sentinel.UncompressedSize = bh.curBackupInfo.uncompressedSize
 	sentinel.CompressedSize = bh.curBackupInfo.compressedSize
+	sentinel.Stats = newBackupStats(bh.curBackupInfo.startTime, utility.TimeNowCrossPlatformUTC(),
+		bh.curBackupInfo.uncompressedSize, bh.curBackupInfo.compressedSize)
 	sentinel.FilesMetadataDisabled = bh.arguments.withoutFilesMetadata
+	sentinel.FilesMetadataMinimal = bh.arguments.minimalFilesMetadata
+	sentinel.WalUnverified = bh.arguments.skipWalValidation
+	if bh.workers.pageChecksums != nil {
+		pageChecksums := bh.workers.pageChecksums.getSummary()
+		sentinel.PageChecksums = &pageChecksums
+	}
 	return sentinel
 }
 
@@ -77,18 +105,16 @@ type ExtendedMetadataDto struct {
 	CompressedSize   int64 `json:"compressed_size"`
 
 	UserData interface{} `json:"user_data,omitempty"`
+
+	// Retention is the hold placed on the backup by backup-retain
+	Retention *BackupRetention `json:"retention,omitempty"`
 }
 
 func NewExtendedMetadataDto(isPermanent bool, dataDir string, startTime time.Time,
 	sentinelDto BackupSentinelDto) (meta ExtendedMetadataDto) {
-	hostname, err := os.Hostname()
-	if err != nil {
-		tracelog.WarningLogger.Printf("Failed to fetch the hostname for metadata, leaving empty: %v", err)
-	}
 	meta.DatetimeFormat = MetadataDatetimeFormat
 	meta.StartTime = startTime
 	meta.FinishTime = utility.TimeNowCrossPlatformUTC()
-	meta.Hostname = hostname
 	meta.IsPermanent = isPermanent
 	meta.DataDir = dataDir
 
@@ -96,6 +122,7 @@ func NewExtendedMetadataDto(isPermanent bool, dataDir string, startTime time.Tim
 	meta.StartLsn = *sentinelDto.BackupStartLSN
 	meta.FinishLsn = *sentinelDto.BackupFinishLSN
 	meta.PgVersion = sentinelDto.PgVersion
+	meta.Hostname = sentinelDto.Hostname
 	meta.SystemIdentifier = sentinelDto.SystemIdentifier
 	meta.UserData = sentinelDto.UserData
 	meta.UncompressedSize = sentinelDto.UncompressedSize
@@ -103,6 +130,14 @@ func NewExtendedMetadataDto(isPermanent bool, dataDir string, startTime time.Tim
 	return meta
 }
 
+func getHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to fetch the hostname for metadata, leaving empty: %v", err)
+	}
+	return hostname
+}
+
 // TODO : unit tests
 // TODO : get rid of panic here
 // IsIncremental checks that sentinel represents delta backup
@@ -121,6 +156,18 @@ func (dto *BackupSentinelDto) IsIncremental() (isIncremental bool) {
 type FilesMetadataDto struct {
 	Files       internal.BackupFileList `json:"Files,omitempty"`
 	TarFileSets map[string][]string     `json:"TarFileSets,omitempty"`
+	// DatabasesByNames maps the names of the backed up databases to their OIDs
+	DatabasesByNames DatabasesByNames `json:"DatabasesByNames,omitempty"`
+	// CatalogRelFileNodes maps the database OIDs to the relfilenodes of their system catalogs
+	CatalogRelFileNodes CatalogRelFileNodes `json:"CatalogRelFileNodes,omitempty"`
+	// TarMemberSizes maps the tar members to their sizes, it is recorded instead of the Files
+	// by the minimal files metadata
+	TarMemberSizes map[string]int64 `json:"TarMemberSizes,omitempty"`
+	// TarChecksums maps the tar members to the size and the CRC-32 of their stored objects
+	TarChecksums map[string]internal.ObjectChecksum `json:"TarChecksums,omitempty"`
+	// SkippedUnloggedFiles lists the forks of the unlogged relations skipped by backup-push --skip-unlogged,
+	// they are recreated from the init forks by the recovery
+	SkippedUnloggedFiles []string `json:"SkippedUnloggedFiles,omitempty"`
 }
 
 func NewFilesMetadataDto(files internal.BackupFileList, tarFileSets internal.TarFileSets) FilesMetadataDto {
@@ -137,6 +184,28 @@ func (dto *FilesMetadataDto) setFiles(p *sync.Map) {
 	})
 }
 
+// setTarMemberSizes records only the sizes of the files instead of their metadata
+func (dto *FilesMetadataDto) setTarMemberSizes(p *sync.Map) {
+	dto.TarMemberSizes = make(map[string]int64)
+	p.Range(func(k, v interface{}) bool {
+		dto.TarMemberSizes[k.(string)] = v.(internal.BackupFileDescription).Size
+		return true
+	})
+}
+
+// getFileSizes returns the sizes of the backup files recorded either in the tar manifest or in the files metadata,
+// the sizes are zero for the backups made by the older versions
+func (dto *FilesMetadataDto) getFileSizes() map[string]int64 {
+	if len(dto.TarMemberSizes) > 0 {
+		return dto.TarMemberSizes
+	}
+	fileSizes := make(map[string]int64, len(dto.Files))
+	for fileName, description := range dto.Files {
+		fileSizes[fileName] = description.Size
+	}
+	return fileSizes
+}
+
 // BackupSentinelDtoV2 is the future version of the backup sentinel.
 // Basically, it is a union of BackupSentinelDto and ExtendedMetadataDto.
 // Currently, WAL-G only uploads it, but use as the regular BackupSentinelDto.
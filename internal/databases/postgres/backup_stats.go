@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"time"
+)
+
+// BackupStats are the duration and the throughput of backup-push recorded in the sentinel
+// to chart the backups over time, they are not recorded by the older versions
+type BackupStats struct {
+	// DurationSeconds is the wall-clock time from the start of backup-push to the finish of the uploads
+	DurationSeconds float64 `json:"DurationSeconds"`
+	// CompressionRatio is the uncompressed size divided by the compressed size
+	CompressionRatio float64 `json:"CompressionRatio,omitempty"`
+	// UploadBytesPerSecond is the compressed size divided by the duration
+	UploadBytesPerSecond float64 `json:"UploadBytesPerSecond,omitempty"`
+}
+
+func newBackupStats(startTime, finishTime time.Time, uncompressedSize, compressedSize int64) *BackupStats {
+	duration := finishTime.Sub(startTime)
+	if startTime.IsZero() || duration <= 0 {
+		return nil
+	}
+	stats := &BackupStats{DurationSeconds: duration.Seconds()}
+	if compressedSize > 0 {
+		stats.CompressionRatio = float64(uncompressedSize) / float64(compressedSize)
+		stats.UploadBytesPerSecond = float64(compressedSize) / duration.Seconds()
+	}
+	return stats
+}
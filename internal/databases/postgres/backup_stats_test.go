@@ -0,0 +1,20 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBackupStats(t *testing.T) {
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := newBackupStats(startTime, startTime.Add(100*time.Second), 4000, 1000)
+	assert.Equal(t, &BackupStats{DurationSeconds: 100, CompressionRatio: 4, UploadBytesPerSecond: 10}, stats)
+
+	// nothing is uploaded, e.g. the empty stdin backup
+	stats = newBackupStats(startTime, startTime.Add(time.Second), 0, 0)
+	assert.Equal(t, &BackupStats{DurationSeconds: 1}, stats)
+
+	assert.Nil(t, newBackupStats(time.Time{}, startTime, 4000, 1000))
+}
@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const bytesPerGiB = 1 << 30
+
+// BackupStorageUsage is the storage footprint of a backup printed by backup-list --storage-usage
+type BackupStorageUsage struct {
+	BackupName    string `json:"backup_name"`
+	DeltaBaseName string `json:"delta_base_name,omitempty"`
+	// Size is the compressed size of the objects of the backup itself
+	Size int64 `json:"size"`
+	// ChainSize is the size of the objects needed to restore the backup, including its delta bases
+	ChainSize int64 `json:"chain_size"`
+}
+
+// StorageUsage is the storage footprint of the live backups and the WAL archive
+type StorageUsage struct {
+	Backups []BackupStorageUsage `json:"backups"`
+	// BackupsSize counts the objects shared by the delta chains of several backups once
+	BackupsSize int64 `json:"backups_size"`
+	WalSize     int64 `json:"wal_size"`
+	TotalSize   int64 `json:"total_size"`
+	// EstimatedMonthlyCost is set only if the price per GiB is provided
+	EstimatedMonthlyCost *float64 `json:"estimated_monthly_cost,omitempty"`
+}
+
+// HandleBackupStorageUsage prints the compressed size of each live backup, of the WAL archive and their total
+func HandleBackupStorageUsage(rootFolder storage.Folder, pretty, json bool, pricePerGiB float64) {
+	usage, err := GetStorageUsage(rootFolder)
+	if _, ok := err.(internal.NoBackupsFoundError); ok {
+		tracelog.InfoLogger.Println("No backups found")
+		return
+	}
+	tracelog.ErrorLogger.FatalOnError(err)
+	if pricePerGiB > 0 {
+		usage.setEstimatedMonthlyCost(pricePerGiB)
+	}
+
+	switch {
+	case json:
+		err = internal.WriteAsJSON(usage, os.Stdout, pretty)
+	case pretty:
+		WritePrettyStorageUsage(usage, os.Stdout)
+	default:
+		err = WriteStorageUsage(usage, os.Stdout)
+	}
+	tracelog.ErrorLogger.FatalOnError(err)
+}
+
+// GetStorageUsage sums the sizes of the storage objects of the live backups and the WAL archive.
+// The objects without a sentinel, e.g. of the unfinished backups, are not counted.
+func GetStorageUsage(rootFolder storage.Folder) (StorageUsage, error) {
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	backups, err := internal.GetBackups(baseBackupFolder)
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	internal.SortBackupTimeSlices(backups)
+
+	objects, err := storage.ListFolderRecursively(baseBackupFolder)
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	objectSizes := groupObjectSizesByBackup(objects)
+
+	deltaBaseNames := make(map[string]string, len(backups))
+	for _, backupTime := range backups {
+		backup := NewBackup(baseBackupFolder, backupTime.BackupName)
+		sentinel, err := backup.GetSentinel()
+		if err != nil {
+			return StorageUsage{}, err
+		}
+		if sentinel.IncrementFrom != nil {
+			deltaBaseNames[backupTime.BackupName] = *sentinel.IncrementFrom
+		}
+	}
+
+	var usage StorageUsage
+	countedBackups := make(map[string]bool)
+	for _, backupTime := range backups {
+		backupUsage := BackupStorageUsage{
+			BackupName:    backupTime.BackupName,
+			DeltaBaseName: deltaBaseNames[backupTime.BackupName],
+			Size:          objectSizes[backupTime.BackupName],
+		}
+		for _, chainBackupName := range getStorageUsageChain(backupTime.BackupName, deltaBaseNames) {
+			backupUsage.ChainSize += objectSizes[chainBackupName]
+			if !countedBackups[chainBackupName] {
+				countedBackups[chainBackupName] = true
+				usage.BackupsSize += objectSizes[chainBackupName]
+			}
+		}
+		usage.Backups = append(usage.Backups, backupUsage)
+	}
+
+	walObjects, err := storage.ListFolderRecursively(rootFolder.GetSubFolder(utility.WalPath))
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	for _, object := range walObjects {
+		usage.WalSize += object.GetSize()
+	}
+	usage.TotalSize = usage.BackupsSize + usage.WalSize
+	return usage, nil
+}
+
+// groupObjectSizesByBackup sums the sizes of the objects in the backup folders and the sentinels by the backup names
+func groupObjectSizesByBackup(objects []storage.Object) map[string]int64 {
+	sizes := make(map[string]int64)
+	sentinelSuffix := internal.SentinelSuffix()
+	for _, object := range objects {
+		name := object.GetName()
+		if backupName, _, found := strings.Cut(name, "/"); found {
+			sizes[backupName] += object.GetSize()
+		} else if strings.HasSuffix(name, sentinelSuffix) {
+			sizes[strings.TrimSuffix(name, sentinelSuffix)] += object.GetSize()
+		}
+	}
+	return sizes
+}
+
+// getStorageUsageChain lists the backup and its delta bases up to the full backup.
+// The chain stops at the delta base which is not found among the live backups.
+func getStorageUsageChain(backupName string, deltaBaseNames map[string]string) []string {
+	chain := []string{backupName}
+	visited := map[string]bool{backupName: true}
+	for {
+		baseName, ok := deltaBaseNames[backupName]
+		if !ok {
+			return chain
+		}
+		if visited[baseName] {
+			tracelog.WarningLogger.Printf("The delta chain of the backup %s is cyclic", chain[0])
+			return chain
+		}
+		chain = append(chain, baseName)
+		visited[baseName] = true
+		backupName = baseName
+	}
+}
+
+func (usage *StorageUsage) setEstimatedMonthlyCost(pricePerGiB float64) {
+	cost := float64(usage.TotalSize) / bytesPerGiB * pricePerGiB
+	usage.EstimatedMonthlyCost = &cost
+}
+
+func WriteStorageUsage(usage StorageUsage, output io.Writer) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
+	_, err := fmt.Fprintln(writer, "name\tdelta_base_name\tsize\tchain_size")
+	if err != nil {
+		return err
+	}
+	for _, backup := range usage.Backups {
+		_, err = fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", backup.BackupName, backup.DeltaBaseName, backup.Size,
+			backup.ChainSize)
+		if err != nil {
+			return err
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(output, "backups_size: %d\nwal_size: %d\ntotal_size: %d\n",
+		usage.BackupsSize, usage.WalSize, usage.TotalSize)
+	if err != nil {
+		return err
+	}
+	if usage.EstimatedMonthlyCost != nil {
+		_, err = fmt.Fprintf(output, "estimated_monthly_cost: %.2f\n", *usage.EstimatedMonthlyCost)
+	}
+	return err
+}
+
+func WritePrettyStorageUsage(usage StorageUsage, output io.Writer) {
+	writer := table.NewWriter()
+	writer.SetOutputMirror(output)
+	writer.AppendHeader(table.Row{"#", "Name", "Delta base", "Size", "Chain size"})
+	for idx, backup := range usage.Backups {
+		writer.AppendRow(table.Row{idx, backup.BackupName, backup.DeltaBaseName, backup.Size,
+			backup.ChainSize})
+	}
+	writer.AppendFooter(table.Row{"", "Backups", "", usage.BackupsSize})
+	writer.AppendFooter(table.Row{"", "WAL", "", usage.WalSize})
+	writer.AppendFooter(table.Row{"", "Total", "", usage.TotalSize})
+	if usage.EstimatedMonthlyCost != nil {
+		writer.AppendFooter(table.Row{"", "Estimated monthly cost", "", fmt.Sprintf("%.2f", *usage.EstimatedMonthlyCost)})
+	}
+	writer.Render()
+}
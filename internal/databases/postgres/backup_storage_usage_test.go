@@ -0,0 +1,89 @@
+package postgres_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func putStorageUsageObject(t *testing.T, folder storage.Folder, name string, size int) {
+	require.NoError(t, folder.PutObject(name, strings.NewReader(strings.Repeat("x", size))))
+}
+
+func TestGetStorageUsage(t *testing.T) {
+	rootFolder := memory.NewFolder("", memory.NewStorage())
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	require.NoError(t, baseBackupFolder.PutObject("base_full"+utility.SentinelSuffix, strings.NewReader(`{}`)))
+	putStorageUsageObject(t, baseBackupFolder, "base_full/tar_partitions/part_1.tar.lz4", 100)
+	for _, deltaName := range []string{"base_delta1", "base_delta2"} {
+		require.NoError(t, baseBackupFolder.PutObject(deltaName+utility.SentinelSuffix,
+			strings.NewReader(`{"DeltaFrom":"base_full"}`)))
+		putStorageUsageObject(t, baseBackupFolder, deltaName+"/tar_partitions/part_1.tar.lz4", 10)
+	}
+	// the unfinished backup without a sentinel is not counted
+	putStorageUsageObject(t, baseBackupFolder, "base_garbage/tar_partitions/part_1.tar.lz4", 1000)
+	putStorageUsageObject(t, rootFolder.GetSubFolder(utility.WalPath), "000000010000000000000001.lz4", 50)
+
+	usage, err := postgres.GetStorageUsage(rootFolder)
+	require.NoError(t, err)
+
+	sizes := make(map[string]postgres.BackupStorageUsage)
+	for _, backup := range usage.Backups {
+		sizes[backup.BackupName] = backup
+	}
+	require.Len(t, sizes, 3)
+	assert.Equal(t, int64(102), sizes["base_full"].Size)
+	assert.Equal(t, int64(102), sizes["base_full"].ChainSize)
+	assert.Equal(t, "base_full", sizes["base_delta1"].DeltaBaseName)
+	assert.Equal(t, int64(35), sizes["base_delta1"].Size)
+	assert.Equal(t, int64(137), sizes["base_delta1"].ChainSize)
+	// the full backup shared by both delta chains is counted once
+	assert.Equal(t, int64(102+35+35), usage.BackupsSize)
+	assert.Equal(t, int64(50), usage.WalSize)
+	assert.Equal(t, int64(102+35+35+50), usage.TotalSize)
+	assert.Nil(t, usage.EstimatedMonthlyCost)
+}
+
+func TestGetStorageUsage_SentinelNamespace(t *testing.T) {
+	viper.Set(internal.SentinelNamespaceSetting, "v2")
+	defer viper.Set(internal.SentinelNamespaceSetting, "")
+	rootFolder := memory.NewFolder("", memory.NewStorage())
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	require.NoError(t, baseBackupFolder.PutObject(internal.SentinelNameFromBackup("base_full"), strings.NewReader(`{}`)))
+	putStorageUsageObject(t, baseBackupFolder, "base_full/tar_partitions/part_1.tar.lz4", 100)
+
+	usage, err := postgres.GetStorageUsage(rootFolder)
+	require.NoError(t, err)
+	require.Len(t, usage.Backups, 1)
+	// the namespaced sentinel is counted with the backup
+	assert.Equal(t, int64(102), usage.Backups[0].Size)
+}
+
+func TestWriteStorageUsage(t *testing.T) {
+	cost := 1.5
+	usage := postgres.StorageUsage{
+		Backups: []postgres.BackupStorageUsage{
+			{BackupName: "base_full", Size: 100, ChainSize: 100},
+			{BackupName: "base_delta", DeltaBaseName: "base_full", Size: 10, ChainSize: 110},
+		},
+		BackupsSize:          110,
+		WalSize:              50,
+		TotalSize:            160,
+		EstimatedMonthlyCost: &cost,
+	}
+	var output bytes.Buffer
+	require.NoError(t, postgres.WriteStorageUsage(usage, &output))
+	assert.Equal(t, "name       delta_base_name size chain_size\n"+
+		"base_full                  100  100\n"+
+		"base_delta base_full       10   110\n"+
+		"backups_size: 110\nwal_size: 50\ntotal_size: 160\nestimated_monthly_cost: 1.50\n", output.String())
+}
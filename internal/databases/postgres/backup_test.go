@@ -49,6 +49,18 @@ func TestGetFilesToUnwrap_UnwrapAll(t *testing.T) {
 	assert.True(t, files == nil)
 }
 
+func TestGetFilesToUnwrap_TarMembers(t *testing.T) {
+	backup := postgres.Backup{
+		SentinelDto: &postgres.BackupSentinelDto{FilesMetadataMinimal: true},
+		FilesMetadataDto: &postgres.FilesMetadataDto{
+			TarMemberSizes: map[string]int64{testtools.SimplePath: 100},
+		},
+	}
+
+	files, _ := backup.GetFilesToUnwrap("")
+	assert.Contains(t, files, testtools.SimplePath)
+}
+
 func TestGetFilesToUnwrap_NoMoreFiles(t *testing.T) {
 	backup := getMockBackupFromFiles(testtools.NewBackupFileListBuilder().
 		WithSimple().
@@ -140,6 +152,20 @@ func TestFetchSentinelReturnErrorWhenSentinelUnmarshallable(t *testing.T) {
 	assert.Equal(t, errorMessage, err.Error()[:len(errorMessage)])
 }
 
+func TestGetSentinelAndFilesMetadata_TarChecksumsFromSentinel(t *testing.T) {
+	folder := testtools.CreateMockStorageFolder()
+	checksums := map[string]internal.ObjectChecksum{"part_1.tar.lz4": {Size: 10, CRC32: 42}}
+	sentinel := postgres.BackupSentinelDto{FilesMetadataDisabled: true, TarChecksums: checksums}
+	sentinelJSON, _ := json.Marshal(sentinel)
+	_ = folder.PutObject("base_789454598"+utility.SentinelSuffix, bytes.NewReader(sentinelJSON))
+	backup := postgres.NewBackup(folder, "base_789454598")
+
+	_, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+
+	assert.NoError(t, err)
+	assert.Equal(t, checksums, filesMeta.TarChecksums)
+}
+
 func TestGetLatestBackupName(t *testing.T) {
 	var folder = testtools.MakeDefaultInMemoryStorageFolder()
 	backupNames := []string{"base_123", "base_456", "base000"}
@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// ExitCodeBackupTimeout is the exit code of the backup-push aborted by the --timeout,
+// it is EX_TEMPFAIL of sysexits.h since the backup is expected to be retried later
+const ExitCodeBackupTimeout = internal.ExitCodeTimeout
+
+// BackupTimeoutError is returned for the backup aborted by the backup-push --timeout
+type BackupTimeoutError struct {
+	error
+}
+
+func newBackupTimeoutError(timeout time.Duration) BackupTimeoutError {
+	return BackupTimeoutError{errors.Errorf("The backup exceeded the timeout of %s and was aborted", timeout)}
+}
+
+func (err BackupTimeoutError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err BackupTimeoutError) ExitCode() int {
+	return ExitCodeBackupTimeout
+}
+
+// backupTimeout aborts the backup which runs longer than the backup-push --timeout
+type backupTimeout struct {
+	mutex sync.Mutex
+	// finishing is set once the sentinel is about to be uploaded, the finished backup is never aborted
+	finishing bool
+	abortOnce sync.Once
+}
+
+// startTimeout sets the deadline of the backup: once it is exceeded, the walk of the data directory
+// and the uploads in progress fail, then the backup is stopped and its uploaded files are deleted.
+// The uploads in progress fail on the canceled handler context as well.
+func (bh *BackupHandler) startTimeout() (stop func()) {
+	if bh.arguments.timeout <= 0 {
+		bh.workers.uploader.SetContext(bh.ctx)
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(bh.ctx, bh.arguments.timeout)
+	bh.ctx = ctx
+	bh.workers.uploader.SetContext(ctx)
+	go func() {
+		<-ctx.Done()
+		if ctx.Err() == context.DeadlineExceeded {
+			bh.abortTimedOutBackup()
+		}
+	}()
+	return cancel
+}
+
+func (bh *BackupHandler) timedOut() bool {
+	return bh.ctx != nil && bh.ctx.Err() == context.DeadlineExceeded
+}
+
+// backupError returns the error of the failed backup: the errors caused by the exceeded timeout
+// wait for the backup to be aborted and become BackupTimeoutError, the other ones wait for the running backup
+// to be stopped and become the cause of its termination if there is one
+func (bh *BackupHandler) backupError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if bh.timedOut() {
+		bh.abortTimedOutBackup()
+		return newBackupTimeoutError(bh.arguments.timeout)
+	}
+	bh.cancel()
+	if bh.terminated != nil {
+		<-bh.terminated
+	}
+	if cause, ok := bh.terminateErr.Load().(error); ok {
+		return cause
+	}
+	return err
+}
+
+// finishBeforeTimeout stops the timeout before the sentinel is uploaded, so the finished backup
+// can't be deleted by the abort, the backup which has already exceeded the timeout is aborted instead
+func (bh *BackupHandler) finishBeforeTimeout() error {
+	bh.timeout.mutex.Lock()
+	if !bh.timedOut() {
+		bh.timeout.finishing = true
+		bh.workers.uploader.SetContext(nil)
+	}
+	bh.timeout.mutex.Unlock()
+	if !bh.timeout.finishing {
+		bh.abortTimedOutBackup()
+		return newBackupTimeoutError(bh.arguments.timeout)
+	}
+	return nil
+}
+
+// abortTimedOutBackup stops the running backup and deletes its uploaded files.
+// The concurrent callers wait for the first one to finish the abort.
+func (bh *BackupHandler) abortTimedOutBackup() {
+	bh.timeout.mutex.Lock()
+	finishing := bh.timeout.finishing
+	bh.timeout.mutex.Unlock()
+	if finishing {
+		return
+	}
+	bh.timeout.abortOnce.Do(func() {
+		tracelog.ErrorLogger.Printf("The backup exceeded the timeout of %s, aborting...", bh.arguments.timeout)
+		if bh.workers.queryRunner != nil {
+			bh.terminateTimedOutBackup()
+		}
+		bh.cleanupTimedOutBackup()
+	})
+}
+
+// terminateTimedOutBackup stops the backup from the new connection, as the connection of the backup
+// may be busy with its query. The non-exclusive backup is aborted by terminating the session which runs it,
+// the exclusive backup before 9.6 is stopped by the new session.
+func (bh *BackupHandler) terminateTimedOutBackup() {
+	conn, err := Connect()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to connect to stop the timed out backup: %v", err)
+		return
+	}
+	defer utility.LoggedClose(conn, "")
+	queryRunner, err := NewPgQueryRunner(conn)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to stop the timed out backup: %v", err)
+		return
+	}
+	if bh.pgInfo.pgVersion < 90600 {
+		NewBackupTerminator(queryRunner, bh.pgInfo.pgVersion, bh.pgInfo.pgDataDirectory).TerminateBackup()
+		return
+	}
+	err = queryRunner.terminateBackend(bh.workers.queryRunner.Connection.PID())
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to stop the timed out backup: %v", err)
+		return
+	}
+	tracelog.InfoLogger.Printf("Terminated the session of the timed out backup")
+}
+
+// cleanupTimedOutBackup deletes the files uploaded by the aborted backup, including the sentinel
+// if it was partially written. With the backup checkpoints enabled the files are kept for the backup-push --resume.
+func (bh *BackupHandler) cleanupTimedOutBackup() {
+	backupName := bh.curBackupInfo.name
+	if backupName == "" {
+		return
+	}
+	if viper.GetDuration(internal.BackupCheckpointInterval) > 0 {
+		tracelog.InfoLogger.Printf("Keeping the files of backup %s to resume it later", backupName)
+		return
+	}
+	tracelog.InfoLogger.Printf("Deleting the files of the aborted backup %s", backupName)
+	baseBackupFolder := bh.workers.uploader.UploadingFolder
+	err := deleteBackupObjects(baseBackupFolder, backupName)
+	tracelog.WarningLogger.PrintOnError(err)
+	err = baseBackupFolder.DeleteObjects([]string{internal.SentinelNameFromBackup(backupName)})
+	tracelog.WarningLogger.PrintOnError(err)
+}
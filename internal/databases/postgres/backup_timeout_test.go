@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func TestBundle_WalkStopsOnContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	bundle := &Bundle{ctx: ctx}
+	cancel()
+
+	info, err := os.Stat(t.TempDir())
+	assert.NoError(t, err)
+	err = bundle.HandleWalkedFSObject("base", info, nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestBackupHandler_FinishBeforeTimeout(t *testing.T) {
+	uploader := internal.NewUploader(&lz4.Compressor{}, memory.NewFolder("in_memory/", memory.NewStorage()))
+	bh := &BackupHandler{
+		arguments: BackupArguments{timeout: time.Hour},
+		workers:   BackupWorkers{uploader: &WalUploader{Uploader: uploader}},
+		ctx:       context.Background(),
+	}
+	stop := bh.startTimeout()
+	defer stop()
+	assert.False(t, bh.timedOut())
+
+	assert.NoError(t, bh.finishBeforeTimeout())
+	assert.True(t, bh.timeout.finishing)
+	// the metadata of the finished backup is uploaded regardless of the timeout
+	stop()
+	assert.NoError(t, uploader.Upload("sentinel", strings.NewReader("{}")))
+}
+
+func TestBackupHandler_BackupError(t *testing.T) {
+	uploader := internal.NewUploader(&lz4.Compressor{}, memory.NewFolder("in_memory/", memory.NewStorage()))
+	bh := &BackupHandler{
+		arguments: BackupArguments{timeout: time.Nanosecond},
+		workers:   BackupWorkers{uploader: &WalUploader{Uploader: uploader}},
+	}
+	bh.ctx, bh.cancel = context.WithCancel(context.Background())
+	walkErr := errors.New("walk failed")
+	assert.NoError(t, bh.backupError(nil))
+	assert.Equal(t, walkErr, bh.backupError(walkErr))
+
+	bh.terminateErr.Store(errors.New("PG alive check failed"))
+	assert.EqualError(t, bh.backupError(walkErr), "PG alive check failed")
+
+	bh.ctx = context.Background()
+	stop := bh.startTimeout()
+	defer stop()
+	<-bh.ctx.Done()
+	err := bh.backupError(walkErr)
+	assert.IsType(t, BackupTimeoutError{}, err)
+	assert.Equal(t, ExitCodeBackupTimeout, internal.ExitCodeOf(err))
+}
@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// VerifyingTarInterpreter reads the tar members without writing anything,
+// checks the recorded file checksums and remembers the names of the files found
+type VerifyingTarInterpreter struct {
+	files      internal.BackupFileList
+	foundFiles sync.Map
+}
+
+func NewVerifyingTarInterpreter(files internal.BackupFileList) *VerifyingTarInterpreter {
+	return &VerifyingTarInterpreter{files: files}
+}
+
+func (interpreter *VerifyingTarInterpreter) Interpret(reader io.Reader, header *tar.Header) error {
+	fileChecksumReader := newChecksumReader(reader)
+	// read the whole file to make sure that it is decompressed and decrypted successfully
+	_, err := io.Copy(io.Discard, fileChecksumReader)
+	if err != nil {
+		return err
+	}
+	fileDescription, ok := interpreter.files[header.Name]
+	if ok && fileDescription.Crc32c != nil && !fileDescription.IsIncremented {
+		if actual := fileChecksumReader.checksum(); actual != *fileDescription.Crc32c {
+			return newFileChecksumMismatchError(header.Name, *fileDescription.Crc32c, actual)
+		}
+	}
+	interpreter.foundFiles.Store(header.Name, true)
+	return nil
+}
+
+func (interpreter *VerifyingTarInterpreter) isFound(fileName string) bool {
+	_, ok := interpreter.foundFiles.Load(fileName)
+	return ok
+}
+
+// BackupVerifyReport describes the problems found in the backup tar members
+type BackupVerifyReport struct {
+	BackupName string
+	TarsCount  int
+	// MissingTars are recorded in the files metadata, but not found in storage
+	MissingTars []string
+	// CorruptTars are the tar members which failed to download, decrypt, decompress
+	// or contain files not matching the recorded checksums
+	CorruptTars map[string]error
+	// MissingFiles are recorded in the files metadata, but not found in their tar members
+	MissingFiles   map[string][]string
+	HasPgControl   bool
+	HasBackupLabel bool
+}
+
+func (report *BackupVerifyReport) IsOk() bool {
+	return len(report.MissingTars) == 0 && len(report.CorruptTars) == 0 && len(report.MissingFiles) == 0 &&
+		report.HasPgControl && report.HasBackupLabel
+}
+
+// HandleBackupVerify downloads all the tar members of the backup and checks that they can be restored
+func HandleBackupVerify(folder storage.Folder, backupSelector internal.BackupSelector) {
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	backupName, err := backupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	report, err := VerifyBackup(NewBackup(baseBackupFolder, backupName))
+	tracelog.ErrorLogger.FatalfOnError("Failed to verify backup: %v", err)
+
+	err = WriteBackupVerifyReport(report, os.Stdout)
+	tracelog.ErrorLogger.FatalOnError(err)
+	if !report.IsOk() {
+		tracelog.ErrorLogger.Fatalf("Backup %s verification failed", backupName)
+	}
+	tracelog.InfoLogger.Printf("Backup %s is verified successfully", backupName)
+}
+
+func VerifyBackup(backup Backup) (*BackupVerifyReport, error) {
+	_, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		return nil, err
+	}
+	tarNames, err := backup.GetTarNames()
+	if err != nil {
+		return nil, err
+	}
+	report := &BackupVerifyReport{
+		BackupName:   backup.Name,
+		TarsCount:    len(tarNames),
+		MissingFiles: make(map[string][]string),
+	}
+
+	uploadedTars := make(map[string]bool, len(tarNames))
+	tarsToVerify := make([]internal.ReaderMaker, 0, len(tarNames))
+	for _, tarName := range tarNames {
+		uploadedTars[tarName] = true
+		tarsToVerify = append(tarsToVerify, backup.newTarReaderMaker(tarName, filesMeta))
+	}
+
+	interpreter := NewVerifyingTarInterpreter(filesMeta.Files)
+	if len(tarsToVerify) > 0 {
+		tracelog.InfoLogger.Printf("Verifying %d tar members of backup %s", len(tarsToVerify), backup.Name)
+		report.CorruptTars, err = internal.ExtractAllOnce(interpreter, tarsToVerify)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for tarName, fileNames := range filesMeta.TarFileSets {
+		if !uploadedTars[tarName] {
+			report.MissingTars = append(report.MissingTars, tarName)
+			continue
+		}
+		if _, isCorrupt := report.CorruptTars[tarName]; isCorrupt {
+			continue
+		}
+		for _, fileName := range fileNames {
+			if !interpreter.isFound(fileName) {
+				report.MissingFiles[tarName] = append(report.MissingFiles[tarName], fileName)
+			}
+		}
+	}
+	sort.Strings(report.MissingTars)
+	report.HasPgControl = interpreter.isFound(PgControlPath)
+	report.HasBackupLabel = interpreter.isFound(BackupLabelFilename)
+	return report, nil
+}
+
+func WriteBackupVerifyReport(report *BackupVerifyReport, output io.Writer) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
+	defer writer.Flush()
+	_, err := fmt.Fprintf(writer, "backup: %s, tar members: %d\n", report.BackupName, report.TarsCount)
+	if err != nil {
+		return err
+	}
+	if report.IsOk() {
+		_, err = fmt.Fprintln(writer, "status: OK")
+		return err
+	}
+
+	_, err = fmt.Fprintln(writer, "status: FAILED\n\ntar member\tproblem")
+	if err != nil {
+		return err
+	}
+	for _, tarName := range report.MissingTars {
+		_, err = fmt.Fprintf(writer, "%s\tmissing in storage\n", tarName)
+		if err != nil {
+			return err
+		}
+	}
+	corruptTars := make([]string, 0, len(report.CorruptTars))
+	for tarName := range report.CorruptTars {
+		corruptTars = append(corruptTars, tarName)
+	}
+	sort.Strings(corruptTars)
+	for _, tarName := range corruptTars {
+		_, err = fmt.Fprintf(writer, "%s\tcorrupt: %v\n", tarName, report.CorruptTars[tarName])
+		if err != nil {
+			return err
+		}
+	}
+	tarsWithMissingFiles := make([]string, 0, len(report.MissingFiles))
+	for tarName := range report.MissingFiles {
+		tarsWithMissingFiles = append(tarsWithMissingFiles, tarName)
+	}
+	sort.Strings(tarsWithMissingFiles)
+	for _, tarName := range tarsWithMissingFiles {
+		_, err = fmt.Fprintf(writer, "%s\tmissing files: %v\n", tarName, report.MissingFiles[tarName])
+		if err != nil {
+			return err
+		}
+	}
+	if !report.HasPgControl {
+		_, err = fmt.Fprintf(writer, "-\t%s not found\n", PgControlPath)
+		if err != nil {
+			return err
+		}
+	}
+	if !report.HasBackupLabel {
+		_, err = fmt.Fprintf(writer, "-\t%s not found\n", BackupLabelFilename)
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package postgres_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const verifiedBackupName = "base_000000010000000000000002"
+
+func putTarMember(t *testing.T, folder storage.Folder, tarName string, fileNames ...string) {
+	var buffer bytes.Buffer
+	tarWriter := tar.NewWriter(&buffer)
+	for _, fileName := range fileNames {
+		content := []byte(fileName)
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: fileName, Mode: 0600, Size: int64(len(content))}))
+		_, err := tarWriter.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, folder.PutObject(verifiedBackupName+internal.TarPartitionFolderName+tarName, &buffer))
+}
+
+func newVerifiedBackup(folder storage.Folder, tarFileSets map[string][]string) postgres.Backup {
+	backup := postgres.NewBackup(folder, verifiedBackupName)
+	backup.SentinelDto = &postgres.BackupSentinelDto{}
+	backup.FilesMetadataDto = &postgres.FilesMetadataDto{TarFileSets: tarFileSets}
+	return backup
+}
+
+func TestVerifyBackup_Ok(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putTarMember(t, folder, "part_001.tar", "/base/1/1259", "/global/1262")
+	putTarMember(t, folder, "part_003.tar", postgres.BackupLabelFilename)
+	putTarMember(t, folder, "pg_control.tar", postgres.PgControlPath)
+	backup := newVerifiedBackup(folder, map[string][]string{
+		"part_001.tar": {"/base/1/1259", "/global/1262"},
+		"part_003.tar": {postgres.BackupLabelFilename},
+	})
+
+	report, err := postgres.VerifyBackup(backup)
+	assert.NoError(t, err)
+	assert.True(t, report.IsOk())
+	assert.Equal(t, 3, report.TarsCount)
+}
+
+func TestVerifyBackup_ChecksumMismatch(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putTarMember(t, folder, "part_001.tar", "/base/1/1259")
+	invalidChecksum := uint32(42)
+	backup := newVerifiedBackup(folder, map[string][]string{"part_001.tar": {"/base/1/1259"}})
+	backup.FilesMetadataDto.Files = internal.BackupFileList{"/base/1/1259": {Crc32c: &invalidChecksum}}
+
+	report, err := postgres.VerifyBackup(backup)
+	assert.NoError(t, err)
+	assert.Contains(t, report.CorruptTars, "part_001.tar")
+}
+
+func TestVerifyBackup_ReportsProblems(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putTarMember(t, folder, "part_001.tar", "/base/1/1259")
+	require.NoError(t, folder.PutObject(verifiedBackupName+internal.TarPartitionFolderName+"part_002.tar.lz4",
+		bytes.NewReader([]byte("not an lz4 stream"))))
+	backup := newVerifiedBackup(folder, map[string][]string{
+		"part_001.tar":     {"/base/1/1259", "/global/1262"},
+		"part_002.tar.lz4": {"/base/1/2608"},
+		"part_003.tar":     {postgres.BackupLabelFilename},
+	})
+
+	report, err := postgres.VerifyBackup(backup)
+	assert.NoError(t, err)
+	assert.False(t, report.IsOk())
+	assert.Equal(t, []string{"part_003.tar"}, report.MissingTars)
+	assert.Contains(t, report.CorruptTars, "part_002.tar.lz4")
+	assert.Equal(t, map[string][]string{"part_001.tar": {"/global/1262"}}, report.MissingFiles)
+	assert.False(t, report.HasPgControl)
+	assert.False(t, report.HasBackupLabel)
+
+	var output bytes.Buffer
+	assert.NoError(t, postgres.WriteBackupVerifyReport(report, &output))
+	assert.Contains(t, output.String(), "part_003.tar")
+	assert.Contains(t, output.String(), "status: FAILED")
+}
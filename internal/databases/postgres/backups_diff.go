@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	defaultTablespaceName = "pg_default"
+	globalTablespaceName  = "pg_global"
+)
+
+// BackupFileChange is the file added, removed or changed between the two backups, the size of the missing file is 0
+type BackupFileChange struct {
+	Name      string `json:"name"`
+	OldSize   int64  `json:"old_size"`
+	NewSize   int64  `json:"new_size"`
+	SizeDelta int64  `json:"size_delta"`
+}
+
+// TablespaceDiffSummary counts the changes of the files of one tablespace
+type TablespaceDiffSummary struct {
+	Tablespace   string `json:"tablespace"`
+	AddedCount   int    `json:"added_count"`
+	RemovedCount int    `json:"removed_count"`
+	ChangedCount int    `json:"changed_count"`
+	SizeDelta    int64  `json:"size_delta"`
+}
+
+// BackupsDiffReport describes how the files of the new backup differ from the files of the old one
+type BackupsDiffReport struct {
+	OldBackupName  string                  `json:"old_backup_name"`
+	NewBackupName  string                  `json:"new_backup_name"`
+	AddedFiles     []BackupFileChange      `json:"added_files"`
+	RemovedFiles   []BackupFileChange      `json:"removed_files"`
+	ChangedFiles   []BackupFileChange      `json:"changed_files"`
+	UnchangedCount int                     `json:"unchanged_count"`
+	SizeDelta      int64                   `json:"size_delta"`
+	Tablespaces    []TablespaceDiffSummary `json:"tablespaces"`
+}
+
+// HandleBackupsDiff compares the files metadata of the two backups and writes the report as text or JSON
+func HandleBackupsDiff(folder storage.Folder, oldBackupSelector, newBackupSelector internal.BackupSelector,
+	outputJSON, pretty bool) {
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	oldBackupName, err := oldBackupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+	newBackupName, err := newBackupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	report, err := DiffBackups(NewBackup(baseBackupFolder, oldBackupName), NewBackup(baseBackupFolder, newBackupName))
+	tracelog.ErrorLogger.FatalfOnError("Failed to diff backups: %v", err)
+
+	if outputJSON {
+		err = internal.WriteAsJSON(report, os.Stdout, pretty)
+	} else {
+		err = WriteBackupsDiffReport(report, os.Stdout)
+	}
+	tracelog.ErrorLogger.FatalOnError(err)
+}
+
+// DiffBackups compares the files metadata of the two backups. The file is changed if its sizes differ
+// or its checksums differ, the checksums are compared only if both backups record them: the increments
+// and the skipped files of the delta backups and the files of the older backups have no checksums.
+func DiffBackups(oldBackup, newBackup Backup) (*BackupsDiffReport, error) {
+	oldFiles, err := getBackupFilesToDiff(oldBackup)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := getBackupFilesToDiff(newBackup)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BackupsDiffReport{OldBackupName: oldBackup.Name, NewBackupName: newBackup.Name}
+	tablespaces := make(map[string]*TablespaceDiffSummary)
+	summaryOf := func(fileName string) *TablespaceDiffSummary {
+		name := fileTablespaceName(fileName)
+		if _, ok := tablespaces[name]; !ok {
+			tablespaces[name] = &TablespaceDiffSummary{Tablespace: name}
+		}
+		return tablespaces[name]
+	}
+
+	for fileName, newDescription := range newFiles {
+		oldDescription, ok := oldFiles[fileName]
+		change := BackupFileChange{Name: fileName, OldSize: oldDescription.Size, NewSize: newDescription.Size,
+			SizeDelta: newDescription.Size - oldDescription.Size}
+		switch {
+		case !ok:
+			report.AddedFiles = append(report.AddedFiles, change)
+			summaryOf(fileName).AddedCount++
+		case isBackupFileChanged(oldDescription, newDescription):
+			report.ChangedFiles = append(report.ChangedFiles, change)
+			summaryOf(fileName).ChangedCount++
+		default:
+			report.UnchangedCount++
+			continue
+		}
+		report.SizeDelta += change.SizeDelta
+		summaryOf(fileName).SizeDelta += change.SizeDelta
+	}
+	for fileName, oldDescription := range oldFiles {
+		if _, ok := newFiles[fileName]; ok {
+			continue
+		}
+		change := BackupFileChange{Name: fileName, OldSize: oldDescription.Size, SizeDelta: -oldDescription.Size}
+		report.RemovedFiles = append(report.RemovedFiles, change)
+		report.SizeDelta += change.SizeDelta
+		summaryOf(fileName).RemovedCount++
+		summaryOf(fileName).SizeDelta += change.SizeDelta
+	}
+
+	for _, changes := range [][]BackupFileChange{report.AddedFiles, report.RemovedFiles, report.ChangedFiles} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	}
+	for _, summary := range tablespaces {
+		report.Tablespaces = append(report.Tablespaces, *summary)
+	}
+	sort.Slice(report.Tablespaces, func(i, j int) bool {
+		return report.Tablespaces[i].Tablespace < report.Tablespaces[j].Tablespace
+	})
+	return report, nil
+}
+
+func getBackupFilesToDiff(backup Backup) (internal.BackupFileList, error) {
+	sentinelDto, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if sentinelDto.FilesMetadataDisabled || sentinelDto.FilesMetadataMinimal {
+		return nil, errors.Errorf("backup %s has no files metadata to compare with", backup.Name)
+	}
+	return filesMeta.Files, nil
+}
+
+func isBackupFileChanged(oldDescription, newDescription internal.BackupFileDescription) bool {
+	if oldDescription.Crc32c != nil && newDescription.Crc32c != nil && *oldDescription.Crc32c != *newDescription.Crc32c {
+		return true
+	}
+	return oldDescription.Size != newDescription.Size
+}
+
+// fileTablespaceName returns the OID of the tablespace the file belongs to, or the name of the built-in tablespace
+func fileTablespaceName(fileName string) string {
+	fileName = strings.TrimPrefix(fileName, "/")
+	if strings.HasPrefix(fileName, TablespaceFolder+"/") {
+		return strings.SplitN(fileName, "/", 3)[1]
+	}
+	if strings.HasPrefix(fileName, "global/") {
+		return globalTablespaceName
+	}
+	return defaultTablespaceName
+}
+
+func WriteBackupsDiffReport(report *BackupsDiffReport, output io.Writer) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
+	defer writer.Flush()
+	_, err := fmt.Fprintf(writer, "old backup: %s, new backup: %s\n", report.OldBackupName, report.NewBackupName)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "added files: %d, removed files: %d, changed files: %d, unchanged files: %d, "+
+		"size delta: %+d\n", len(report.AddedFiles), len(report.RemovedFiles), len(report.ChangedFiles),
+		report.UnchangedCount, report.SizeDelta)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(writer, "\ntablespace\tadded\tremoved\tchanged\tsize delta")
+	if err != nil {
+		return err
+	}
+	for _, summary := range report.Tablespaces {
+		_, err = fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%+d\n", summary.Tablespace, summary.AddedCount,
+			summary.RemovedCount, summary.ChangedCount, summary.SizeDelta)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(report.AddedFiles)+len(report.RemovedFiles)+len(report.ChangedFiles) == 0 {
+		return nil
+	}
+	_, err = fmt.Fprintln(writer, "\nfile\tchange\told size\tnew size\tsize delta")
+	if err != nil {
+		return err
+	}
+	for _, changes := range []struct {
+		kind    string
+		changes []BackupFileChange
+	}{{"added", report.AddedFiles}, {"removed", report.RemovedFiles}, {"changed", report.ChangedFiles}} {
+		for _, change := range changes.changes {
+			_, err = fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%+d\n", change.Name, changes.kind,
+				change.OldSize, change.NewSize, change.SizeDelta)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package postgres_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func newDiffedBackup(name string, files internal.BackupFileList) postgres.Backup {
+	backup := postgres.NewBackup(memory.NewFolder("", memory.NewStorage()), name)
+	backup.SentinelDto = &postgres.BackupSentinelDto{}
+	backup.FilesMetadataDto = &postgres.FilesMetadataDto{Files: files}
+	return backup
+}
+
+func TestDiffBackups(t *testing.T) {
+	checksum := func(value uint32) *uint32 { return &value }
+	oldBackup := newDiffedBackup("base_000000010000000000000002", internal.BackupFileList{
+		"/base/5/16384":                      {Crc32c: checksum(1), Size: 8192},
+		"/base/5/16385":                      {Crc32c: checksum(2), Size: 8192},
+		"/base/5/16386":                      {Size: 8192},
+		"/base/5/16387":                      {Crc32c: checksum(4), Size: 16384},
+		"/global/1262":                       {Crc32c: checksum(5), Size: 8192},
+		"/pg_tblspc/16400/PG_15/5/16401":     {Crc32c: checksum(6), Size: 8192},
+		"/pg_tblspc/16400/PG_15/5/16401_fsm": {Crc32c: checksum(7), Size: 24576},
+	})
+	newBackup := newDiffedBackup("base_000000010000000000000004", internal.BackupFileList{
+		"/base/5/16384":                  {Crc32c: checksum(1), Size: 8192},
+		"/base/5/16385":                  {Crc32c: checksum(3), Size: 8192},
+		"/base/5/16386":                  {IsIncremented: true, Size: 81920},
+		"/global/1262":                   {Crc32c: checksum(5), Size: 8192},
+		"/pg_tblspc/16400/PG_15/5/16401": {Crc32c: checksum(6), Size: 8192},
+		"/pg_tblspc/16400/PG_15/5/16402": {Crc32c: checksum(8), Size: 8192},
+	})
+
+	report, err := postgres.DiffBackups(oldBackup, newBackup)
+	require.NoError(t, err)
+	assert.Equal(t, []postgres.BackupFileChange{
+		{Name: "/pg_tblspc/16400/PG_15/5/16402", NewSize: 8192, SizeDelta: 8192},
+	}, report.AddedFiles)
+	assert.Equal(t, []postgres.BackupFileChange{
+		{Name: "/base/5/16387", OldSize: 16384, SizeDelta: -16384},
+		{Name: "/pg_tblspc/16400/PG_15/5/16401_fsm", OldSize: 24576, SizeDelta: -24576},
+	}, report.RemovedFiles)
+	// the checksums differ for 16385, the sizes differ for the increment 16386 which has no checksum
+	assert.Equal(t, []postgres.BackupFileChange{
+		{Name: "/base/5/16385", OldSize: 8192, NewSize: 8192},
+		{Name: "/base/5/16386", OldSize: 8192, NewSize: 81920, SizeDelta: 73728},
+	}, report.ChangedFiles)
+	assert.Equal(t, 3, report.UnchangedCount)
+	assert.Equal(t, int64(8192-16384-24576+73728), report.SizeDelta)
+	assert.Equal(t, []postgres.TablespaceDiffSummary{
+		{Tablespace: "16400", AddedCount: 1, RemovedCount: 1, SizeDelta: 8192 - 24576},
+		{Tablespace: "pg_default", RemovedCount: 1, ChangedCount: 2, SizeDelta: 73728 - 16384},
+	}, report.Tablespaces)
+
+	var output bytes.Buffer
+	require.NoError(t, postgres.WriteBackupsDiffReport(report, &output))
+	assert.Contains(t, output.String(), "added files: 1, removed files: 2, changed files: 2, unchanged files: 3")
+	assert.Regexp(t, `/base/5/16386 +changed +8192 +81920 +\+73728`, output.String())
+}
+
+func TestDiffBackups_WithoutFilesMetadata(t *testing.T) {
+	backup := newDiffedBackup("base_000000010000000000000002", internal.BackupFileList{})
+	minimalBackup := newDiffedBackup("base_000000010000000000000004", nil)
+	minimalBackup.SentinelDto.FilesMetadataMinimal = true
+
+	_, err := postgres.DiffBackups(backup, minimalBackup)
+	assert.Error(t, err)
+}
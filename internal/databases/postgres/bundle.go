@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"archive/tar"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -67,8 +68,33 @@ type Bundle struct {
 	IncrementFromFiles internal.BackupFileList
 	DeltaMap           PagedFileDeltaMap
 	TablespaceSpec     TablespaceSpec
+	// ExcludedRelPaths are the paths relative to the data directory which are excluded in addition to ExcludedFilenames
+	ExcludedRelPaths map[string]utility.Empty
+	// ExcludedPaths lists the walked paths which matched ExcludedFilenames or ExcludedRelPaths
+	ExcludedPaths []string
+	// DeltaDetection is the way the changed files of the delta backup are detected, mtime by default
+	DeltaDetection string
+	// ParallelTablespaces makes Walk walk the tablespaces concurrently with the data directory
+	ParallelTablespaces bool
+	// SkipUnlogged makes the walk pack only the init forks of the unlogged relations
+	SkipUnlogged bool
+	// SkippedUnloggedFiles lists the skipped forks of the unlogged relations
+	SkippedUnloggedFiles []string
+	// FollowedSymlinks are the symlinks relative to the data directory which targets are packed in place of them
+	FollowedSymlinks map[string]utility.Empty
 
 	forceIncremental bool
+	// ctx stops the walk once it is done, nil means the walk is never stopped
+	ctx context.Context
+	// composerMaker makes the composers of the tablespace walkers
+	composerMaker TarBallComposerMaker
+	// tablespaceComposers are fed by the tablespace walkers, set only if ParallelTablespaces is set
+	tablespaceComposers []internal.TarBallComposer
+	// unloggedDirectory is the last database directory listed for the unlogged relations
+	unloggedDirectory    string
+	unloggedRelFileNodes map[string]bool
+	// labelFiles are returned by the backup stop, set by uploadLabelFiles
+	labelFiles BackupLabelFiles
 }
 
 // TODO: use DiskDataFolder
@@ -106,6 +132,7 @@ func (bundle *Bundle) SetupComposer(composerMaker TarBallComposerMaker) (err err
 		return err
 	}
 	bundle.TarBallComposer = tarBallComposer
+	bundle.composerMaker = composerMaker
 	return nil
 }
 
@@ -186,7 +213,13 @@ func (bundle *Bundle) StartBackup(queryRunner *PgQueryRunner,
 // To see which files and directories are Skipped, please consult
 // ExcludedFilenames. Excluded directories will be created but their
 // contents will not be included in the tar bundle.
+//
+// The symlinks, e.g. pg_wal linked to a separate disk, are packed as the symlinks to the same targets,
+// except the FollowedSymlinks, the targets of which are packed in place of them.
 func (bundle *Bundle) HandleWalkedFSObject(path string, info os.FileInfo, err error) error {
+	if bundle.ctx != nil && bundle.ctx.Err() != nil {
+		return errors.Wrap(bundle.ctx.Err(), "HandleWalkedFSObject: walk interrupted")
+	}
 	if err != nil {
 		if os.IsNotExist(err) {
 			tracelog.WarningLogger.Println(path, " deleted during filepath walk")
@@ -208,7 +241,8 @@ func (bundle *Bundle) HandleWalkedFSObject(path string, info os.FileInfo, err er
 	}
 
 	// Resolve symlinks for tablespaces and save folder structure.
-	if filepath.Base(path) == TablespaceFolder {
+	// With ParallelTablespaces they are registered and walked by Walk instead.
+	if filepath.Base(path) == TablespaceFolder && !bundle.ParallelTablespaces {
 		tablespaceEntries, err := os.ReadDir(path)
 		if err != nil {
 			return fmt.Errorf("could not read directory structure in %s: %v", TablespaceFolder, err)
@@ -245,13 +279,25 @@ func (bundle *Bundle) HandleWalkedFSObject(path string, info os.FileInfo, err er
 
 // TODO : unit tests
 // addToBundle handles one given file.
-// Does not follow symlinks (it seems like it does). If file is in ExcludedFilenames, will not be included
+// Does not follow symlinks unless they are in FollowedSymlinks. If file is in ExcludedFilenames
+// or its relative path is in ExcludedRelPaths, will not be included
 // in the final tarball. EXCLUDED directories are created
 // but their contents are not written to local disk.
 func (bundle *Bundle) addToBundle(path string, info os.FileInfo) error {
 	fileName := info.Name()
-	_, excluded := ExcludedFilenames[fileName]
+	relPath := bundle.getFileRelPath(path)
+	_, excluded := bundle.ExcludedFilenames[fileName]
+	if !excluded {
+		_, excluded = bundle.ExcludedRelPaths[relPath]
+	}
 	isDir := info.IsDir()
+	if excluded {
+		bundle.ExcludedPaths = append(bundle.ExcludedPaths, relPath)
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		return bundle.handleSymlink(path, relPath, info, excluded)
+	}
 
 	if excluded && !isDir {
 		return nil
@@ -262,10 +308,20 @@ func (bundle *Bundle) addToBundle(path string, info os.FileInfo) error {
 		return errors.Wrap(err, "addToBundle: could not grab header info")
 	}
 
-	fileInfoHeader.Name = bundle.getFileRelPath(path)
+	fileInfoHeader.Name = relPath
 	tracelog.DebugLogger.Println(fileInfoHeader.Name)
 
 	if !excluded && info.Mode().IsRegular() {
+		if bundle.SkipUnlogged {
+			isUnloggedFork, err := bundle.isUnloggedRelationFork(path, relPath)
+			if err != nil {
+				return errors.Wrap(err, "addToBundle: could not check the unlogged relation")
+			}
+			if isUnloggedFork {
+				bundle.skipUnloggedRelationFork(relPath)
+				return nil
+			}
+		}
 		baseFiles := bundle.getIncrementBaseFiles()
 		baseFile, wasInBase := baseFiles[fileInfoHeader.Name]
 		// It is important to take MTime before ReadIncrementalFile()
@@ -275,7 +331,10 @@ func (bundle *Bundle) addToBundle(path string, info os.FileInfo) error {
 		// For details see
 		//nolint:lll    // https://www.postgresql.org/message-id/flat/F0627DEB-7D0D-429B-97A9-D321450365B4%40yandex-team.ru#F0627DEB-7D0D-429B-97A9-D321450365B4@yandex-team.ru
 
-		if (wasInBase || bundle.forceIncremental) && (time.Equal(baseFile.MTime)) {
+		// With the lsn delta detection the modification time is not trusted (e.g. it may be preserved by the restore
+		// tools), so the paged files are always incremented by the page LSNs and the other files are packed fully
+		if (wasInBase || bundle.forceIncremental) && bundle.DeltaDetection != DeltaDetectionLsn &&
+			(time.Equal(baseFile.MTime)) {
 			// File was not changed since previous backup
 			tracelog.DebugLogger.Println("Skipped due to unchanged modification time: " + path)
 			bundle.TarBallComposer.SkipFile(fileInfoHeader, info)
@@ -363,6 +422,7 @@ func (bundle *Bundle) uploadLabelFiles(queryRunner *PgQueryRunner) (string, []st
 	if !queryRunner.IsTablespaceMapExists() {
 		return "", nil, lsn, nil
 	}
+	bundle.labelFiles = BackupLabelFiles{BackupLabel: label, TablespaceMap: offsetMap}
 
 	tarBall := bundle.NewTarBall(false)
 	tarBall.SetUp(bundle.Crypter)
@@ -418,9 +478,30 @@ func (bundle *Bundle) DownloadDeltaMap(folder storage.Folder, backupStartLSN LSN
 }
 
 func (bundle *Bundle) FinishTarComposer() (internal.TarFileSets, error) {
+	for _, tablespaceComposer := range bundle.tablespaceComposers {
+		// the tar file sets are shared with the data directory composer
+		_, err := tablespaceComposer.FinishComposing()
+		if err != nil {
+			return nil, err
+		}
+	}
 	return bundle.TarBallComposer.FinishComposing()
 }
 
+// getCopiedTarChecksums returns the checksums of the tarballs copied by the copy composers
+func (bundle *Bundle) getCopiedTarChecksums() map[string]internal.ObjectChecksum {
+	checksums := make(map[string]internal.ObjectChecksum)
+	composers := append([]internal.TarBallComposer{bundle.TarBallComposer}, bundle.tablespaceComposers...)
+	for _, composer := range composers {
+		if copyComposer, ok := composer.(*CopyTarBallComposer); ok {
+			for tarName, checksum := range copyComposer.CopiedTarChecksums() {
+				checksums[tarName] = checksum
+			}
+		}
+	}
+	return checksums
+}
+
 func (bundle *Bundle) GetFiles() *sync.Map {
 	return bundle.TarBallComposer.GetFiles().GetUnderlyingMap()
 }
@@ -31,7 +31,7 @@ func (files *StatBundleFiles) AddFileWithCorruptBlocks(tarHeader *tar.Header,
 	storeAllBlocks bool) {
 	updatesCount := files.fileStats.getFileUpdateCount(tarHeader.Name)
 	fileDescription := internal.BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime(),
-		UpdatesCount: updatesCount}
+		Size: fileInfo.Size(), UpdatesCount: updatesCount}
 	fileDescription.SetCorruptBlocks(corruptedBlocks, storeAllBlocks)
 	files.AddFileDescription(tarHeader.Name, fileDescription)
 }
@@ -40,20 +40,28 @@ func (files *StatBundleFiles) AddSkippedFile(tarHeader *tar.Header, fileInfo os.
 	updatesCount := files.fileStats.getFileUpdateCount(tarHeader.Name)
 	files.AddFileDescription(tarHeader.Name,
 		internal.BackupFileDescription{IsSkipped: true, IsIncremented: false,
-			MTime: fileInfo.ModTime(), UpdatesCount: updatesCount})
+			MTime: fileInfo.ModTime(), Size: fileInfo.Size(), UpdatesCount: updatesCount})
 }
 
 func (files *StatBundleFiles) AddFile(tarHeader *tar.Header, fileInfo os.FileInfo, isIncremented bool) {
 	updatesCount := files.fileStats.getFileUpdateCount(tarHeader.Name)
 	files.AddFileDescription(tarHeader.Name,
 		internal.BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented,
-			MTime: fileInfo.ModTime(), UpdatesCount: updatesCount})
+			MTime: fileInfo.ModTime(), Size: fileInfo.Size(), UpdatesCount: updatesCount})
 }
 
 func (files *StatBundleFiles) AddFileDescription(name string, backupFileDescription internal.BackupFileDescription) {
 	files.Store(name, backupFileDescription)
 }
 
+func (files *StatBundleFiles) AddFileChecksum(name string, crc32c uint32) {
+	internal.SetFileChecksum(&files.Map, name, crc32c)
+}
+
+func (files *StatBundleFiles) AddFileHoles(name string, holes []internal.FileRegion) {
+	internal.SetFileHoles(&files.Map, name, holes)
+}
+
 func (files *StatBundleFiles) GetUnderlyingMap() *sync.Map {
 	return &files.Map
 }
@@ -1,12 +1,29 @@
 package postgres
 
 import (
+	"fmt"
+
+	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
 
+type CatchupSystemIdentifierMismatchError struct {
+	error
+}
+
+func newCatchupSystemIdentifierMismatchError(backupSystemIdentifier, targetSystemIdentifier uint64) error {
+	return CatchupSystemIdentifierMismatchError{errors.Errorf(
+		"catchup backup system identifier %d does not match the target cluster system identifier %d",
+		backupSystemIdentifier, targetSystemIdentifier)}
+}
+
+func (err CatchupSystemIdentifierMismatchError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
 // HandleCatchupFetch is invoked to perform wal-g catchup-fetch
 func HandleCatchupFetch(folder storage.Folder, dbDirectory, backupName string, useNewUnwrap bool) {
 	dbDirectory = utility.ResolveSymlink(dbDirectory)
@@ -21,12 +38,33 @@ func HandleCatchupFetch(folder storage.Folder, dbDirectory, backupName string, u
 	sentinelDto, filesMetaDto, err := pgBackup.GetSentinelAndFilesMetadata()
 	tracelog.ErrorLogger.FatalfOnError("Failed get backup sentinel: %v", err)
 
+	err = verifyCatchupSystemIdentifier(dbDirectory, sentinelDto)
+	tracelog.ErrorLogger.FatalOnError(err)
+
 	// testing the new unwrap implementation
 	if useNewUnwrap {
-		_, err = pgBackup.unwrapNew(dbDirectory, sentinelDto, filesMetaDto, filesToUnwrap, true, false)
+		_, err = pgBackup.unwrapNew(dbDirectory, sentinelDto, filesMetaDto, filesToUnwrap, true, false, nil)
 	} else {
-		err = pgBackup.unwrapOld(dbDirectory, sentinelDto, filesMetaDto, filesToUnwrap, true)
+		err = pgBackup.unwrapOld(dbDirectory, sentinelDto, filesMetaDto, filesToUnwrap, true, nil)
 	}
 
 	tracelog.ErrorLogger.FatalfOnError("Failed unwrap backup: %v", err)
 }
+
+// verifyCatchupSystemIdentifier checks that the catchup backup is taken from the same cluster
+// as the data directory it is applied onto, since its pages are meaningless for any other cluster
+func verifyCatchupSystemIdentifier(dbDirectory string, sentinelDto BackupSentinelDto) error {
+	if sentinelDto.SystemIdentifier == nil {
+		tracelog.WarningLogger.Println("The catchup backup has no system identifier, " +
+			"unable to verify that it matches the target cluster")
+		return nil
+	}
+	pgControlData, err := ExtractPgControl(dbDirectory)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read pg_control of the target cluster")
+	}
+	if pgControlData.GetSystemIdentifier() != *sentinelDto.SystemIdentifier {
+		return newCatchupSystemIdentifierMismatchError(*sentinelDto.SystemIdentifier, pgControlData.GetSystemIdentifier())
+	}
+	return nil
+}
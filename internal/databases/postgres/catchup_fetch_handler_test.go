@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestPgControl(t *testing.T, dbDirectory string, systemIdentifier uint64) {
+	bytes := make([]byte, pgControlSize)
+	binary.LittleEndian.PutUint64(bytes[0:8], systemIdentifier)
+	binary.LittleEndian.PutUint32(bytes[8:12], 1300)
+
+	pgControlPath := filepath.Join(dbDirectory, PgControlPath)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(pgControlPath), 0700))
+	assert.NoError(t, os.WriteFile(pgControlPath, bytes, 0600))
+}
+
+func TestVerifyCatchupSystemIdentifier(t *testing.T) {
+	dbDirectory := t.TempDir()
+	writeTestPgControl(t, dbDirectory, 9876)
+
+	systemIdentifier := uint64(9876)
+	assert.NoError(t, verifyCatchupSystemIdentifier(dbDirectory, BackupSentinelDto{SystemIdentifier: &systemIdentifier}))
+
+	otherSystemIdentifier := uint64(1234)
+	err := verifyCatchupSystemIdentifier(dbDirectory, BackupSentinelDto{SystemIdentifier: &otherSystemIdentifier})
+	assert.IsType(t, CatchupSystemIdentifierMismatchError{}, err)
+}
+
+func TestVerifyCatchupSystemIdentifier_NoSystemIdentifierInBackup(t *testing.T) {
+	assert.NoError(t, verifyCatchupSystemIdentifier(t.TempDir(), BackupSentinelDto{}))
+}
+
+func TestVerifyCatchupSystemIdentifier_NoTargetPgControl(t *testing.T) {
+	systemIdentifier := uint64(9876)
+	assert.Error(t, verifyCatchupSystemIdentifier(t.TempDir(), BackupSentinelDto{SystemIdentifier: &systemIdentifier}))
+}
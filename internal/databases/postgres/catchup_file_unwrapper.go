@@ -36,7 +36,7 @@ func (u *CatchupFileUnwrapper) UnwrapNewFile(reader io.Reader, header *tar.Heade
 		}
 		return NewCreatedFromIncrementResult(missingBlockCount), nil
 	}
-	err := WriteLocalFile(reader, header, file, fsync)
+	err := WriteLocalFile(reader, header, file, u.options.holes, fsync)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +62,7 @@ func (u *CatchupFileUnwrapper) UnwrapExistingFile(reader io.Reader, header *tar.
 	if err != nil {
 		return nil, err
 	}
-	err = WriteLocalFile(reader, header, file, fsync)
+	err = WriteLocalFile(reader, header, file, u.options.holes, fsync)
 	if err != nil {
 		return nil, err
 	}
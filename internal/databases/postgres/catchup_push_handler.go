@@ -34,11 +34,18 @@ func HandleCatchupPush(pgDataDirectory string, fromLSN LSN) {
 		tarBallComposerType: RegularComposer,
 		userData:            userData,
 	}
-	backupConfig, err := NewBackupHandler(backupArguments)
+	ctx, stop := NewInterruptContext()
+	defer stop()
+	uploader, err := ConfigureWalUploader()
+	tracelog.ErrorLogger.FatalOnError(err)
+	backupConfig, err := newBackupHandler(ctx, backupArguments, uploader)
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer backupConfig.cancel()
+	err = backupConfig.checkPgVersionAndPgControl()
 	tracelog.ErrorLogger.FatalOnError(err)
-	backupConfig.checkPgVersionAndPgControl()
 	backupConfig.prevBackupInfo.sentinelDto = fakePreviousBackupSentinelDto
 	backupConfig.prevBackupInfo.filesMetadataDto = FilesMetadataDto{}
 	backupConfig.curBackupInfo.startLSN = fromLSN
-	backupConfig.createAndPushBackup()
+	_, err = backupConfig.createAndPushBackup()
+	internal.FatalOnError(backupConfig.backupError(err))
 }
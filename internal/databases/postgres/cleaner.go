@@ -2,8 +2,10 @@ package postgres
 
 import (
 	"path"
+	"strings"
 
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
 )
 
 // Cleaner interface serves to separate file system logic from prefetch clean logic to make it testable
@@ -32,11 +34,14 @@ func cleanupPrefetchDirectory(directory string, timelineID uint32, logSegNo uint
 	}
 
 	for _, f := range files {
-		fileTimelineID, fileLogSegNo, err := ParseWALFilename(f)
+		walFileName := strings.TrimSuffix(strings.TrimSuffix(f, internal.PartMetadataSuffix), prefetchPartSuffix)
+		fileTimelineID, fileLogSegNo, err := ParseWALFilename(walFileName)
 		if err != nil {
 			continue
 		}
-		if fileTimelineID < timelineID || (fileTimelineID == timelineID && fileLogSegNo < logSegNo) {
+		// the recovery never goes back to the older timelines and segments,
+		// e.g. the segments of the timeline which is not followed after the switch are left behind
+		if fileTimelineID < timelineID || fileLogSegNo < logSegNo {
 			cleaner.Remove(path.Join(directory, f))
 		}
 	}
@@ -49,6 +49,17 @@ var (
 	}
 
 	inputWALFileWithTooMuchLogSegNoLo = "0000000100000001FFFFFFFF"
+
+	inputFilesOfSkippedTimeline = []string{
+		"000000020000000100000087",
+		"000000020000000100000088",
+		"000000030000000100000089",
+	}
+	inputFileAfterSkippedTimeline   = "000000010000000100000088"
+	wantDeletedAfterSkippedTimeline = []string{
+		"/A/.wal-g/prefetch/000000020000000100000087",
+		"/A/.wal-g/prefetch/running/000000020000000100000087",
+	}
 )
 
 type MockCleaner struct {
@@ -195,3 +206,29 @@ func TestCleanupButInFilesWALWithTooMuchLogSegNoLo(t *testing.T) {
 		assert.Contains(t, cleaner.deleted, delFile)
 	}
 }
+
+func TestCleanupFilesOfSkippedTimeline(t *testing.T) {
+	cleaner := MockCleaner{}
+	cleaner.setFilesAndErrorAndClearDeleted(inputFilesOfSkippedTimeline, nil)
+	postgres.CleanupPrefetchDirectories(inputFileAfterSkippedTimeline, "/A", &cleaner)
+
+	assert.Equal(t, len(wantDeletedAfterSkippedTimeline), len(cleaner.deleted))
+	for _, delFile := range wantDeletedAfterSkippedTimeline {
+		assert.Contains(t, cleaner.deleted, delFile)
+	}
+}
+
+func TestCleanupPartFiles(t *testing.T) {
+	cleaner := MockCleaner{}
+	cleaner.setFilesAndErrorAndClearDeleted([]string{
+		"000000010000000100000057.part",
+		"000000010000000100000057.part.json",
+		"000000010000000100000058.part",
+		"000000010000000100000058.part.json",
+	}, nil)
+	postgres.CleanupPrefetchDirectories(inputSimpleFile, "/A", &cleaner)
+
+	assert.Contains(t, cleaner.deleted, "/A/.wal-g/prefetch/running/000000010000000100000057.part")
+	assert.Contains(t, cleaner.deleted, "/A/.wal-g/prefetch/running/000000010000000100000057.part.json")
+	assert.NotContains(t, cleaner.deleted, "/A/.wal-g/prefetch/running/000000010000000100000058.part")
+}
@@ -10,6 +10,14 @@ import (
 	"github.com/wal-g/wal-g/internal/fsutil"
 )
 
+const (
+	// DeltaDetectionMtime skips the files of the delta backup whose modification time is the same as in the base
+	DeltaDetectionMtime = "mtime"
+	// DeltaDetectionLsn reads every page of the paged files and packs the pages changed since the base start LSN,
+	// regardless of the modification time
+	DeltaDetectionLsn = "lsn"
+)
+
 // ConfigureWalUploader connects to storage and creates an uploader. It makes sure
 // that a valid session has started; if invalid, returns AWS error
 // and `<nil>` values.
@@ -28,7 +36,11 @@ func ConfigureWalUploader() (uploader *WalUploader, err error) {
 	}
 
 	uploader = NewWalUploader(compressor, folder, deltaFileManager)
-	return uploader, err
+	err = uploader.SetStorageClass(internal.GetStorageClass())
+	if err != nil {
+		return nil, err
+	}
+	return uploader, nil
 }
 
 func ConfigureWalUploaderWithoutCompressMethod() (uploader *WalUploader, err error) {
@@ -80,3 +92,16 @@ func getStopBackupTimeoutSetting() (time.Duration, error) {
 
 	return timeout, nil
 }
+
+// GetDeltaDetection returns the way the changed files of the delta backup are detected,
+// configured by the WALG_DELTA_DETECTION setting
+func GetDeltaDetection() (string, error) {
+	deltaDetection := viper.GetString(internal.DeltaDetectionSetting)
+	switch deltaDetection {
+	case DeltaDetectionMtime, DeltaDetectionLsn:
+		return deltaDetection, nil
+	default:
+		return "", errors.Errorf("invalid %s '%s', expected %s or %s",
+			internal.DeltaDetectionSetting, deltaDetection, DeltaDetectionMtime, DeltaDetectionLsn)
+	}
+}
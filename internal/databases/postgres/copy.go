@@ -11,17 +11,20 @@ import (
 	"github.com/wal-g/wal-g/utility"
 )
 
-// HandleCopy copy specific or all backups from one storage to another
-func HandleCopy(fromConfigFile string, toConfigFile string, backupName string, withoutHistory bool) {
+// HandleCopy copy specific or all backups from one storage to another.
+// The copied objects are verified in the target storage at the end.
+func HandleCopy(fromConfigFile string, toConfigFile string, backupName string, withoutHistory, withDeltaChain bool) {
 	var from, fromError = internal.FolderFromConfig(fromConfigFile)
 	var to, toError = internal.FolderFromConfig(toConfigFile)
 	if fromError != nil || toError != nil {
 		return
 	}
-	infos, err := getCopyingInfos(backupName, from, to, withoutHistory)
+	infos, err := getCopyingInfos(backupName, from, to, withoutHistory, withDeltaChain)
 	tracelog.ErrorLogger.FatalOnError(err)
 	err = copy.Infos(infos)
 	tracelog.ErrorLogger.FatalOnError(err)
+	err = copy.VerifyInfos(infos)
+	tracelog.ErrorLogger.FatalOnError(err)
 	tracelog.InfoLogger.Println("Success copy.")
 }
 
@@ -41,7 +44,7 @@ func BackupCopyingInfo(backup Backup, from storage.Folder, to storage.Folder) ([
 func getCopyingInfos(backupName string,
 	from storage.Folder,
 	to storage.Folder,
-	withoutHistory bool) ([]copy.InfoProvider, error) {
+	withoutHistory, withDeltaChain bool) ([]copy.InfoProvider, error) {
 	if backupName == "" {
 		tracelog.InfoLogger.Printf("Copy all backups and history.")
 		return WildcardInfo(from, to)
@@ -53,9 +56,22 @@ func getCopyingInfos(backupName string,
 	}
 
 	pgBackup := ToPgBackup(backup)
-	infos, err := BackupCopyingInfo(pgBackup, from, to)
-	if err != nil {
-		return nil, err
+	backupNames := []string{pgBackup.Name}
+	if withDeltaChain {
+		backupNames, err = GetDeltaChain(pgBackup)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		warnIfDeltaBaseIsMissing(pgBackup, to)
+	}
+	var infos []copy.InfoProvider
+	for _, name := range backupNames {
+		backupInfos, err := BackupCopyingInfo(NewBackup(pgBackup.Folder, name), from, to)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, backupInfos...)
 	}
 	if !withoutHistory {
 		var history, err = HistoryCopyingInfo(pgBackup, from, to)
@@ -67,6 +83,22 @@ func getCopyingInfos(backupName string,
 	return infos, nil
 }
 
+// warnIfDeltaBaseIsMissing warns that the delta backup copied without its base can't be restored from the target storage
+func warnIfDeltaBaseIsMissing(backup Backup, to storage.Folder) {
+	sentinelDto, err := backup.GetSentinel()
+	if err != nil || !sentinelDto.IsIncremental() {
+		return
+	}
+	baseName := *sentinelDto.IncrementFrom
+	exists, err := to.GetSubFolder(utility.BaseBackupPath).Exists(internal.SentinelNameFromBackup(baseName))
+	if err != nil || exists {
+		return
+	}
+	tracelog.WarningLogger.Printf("Backup %s is a delta from %s, which is not in the target storage, "+
+		"so the copy can't be restored without it. Use --with-delta-chain to copy the whole delta chain.\n",
+		backup.Name, baseName)
+}
+
 func HistoryCopyingInfo(backup Backup, from storage.Folder, to storage.Folder) ([]copy.InfoProvider, error) {
 	tracelog.DebugLogger.Print("Collecting history files... ")
 
@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/copy"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func TestGetCopyingInfos_WithDeltaChain(t *testing.T) {
+	from := memory.NewFolder("", memory.NewStorage())
+	to := memory.NewFolder("", memory.NewStorage())
+	putDeltaChain(t, from.GetSubFolder(utility.BaseBackupPath), "base_000000010000000000000002",
+		"base_000000010000000000000004_D_000000010000000000000002")
+	deltaName := "base_000000010000000000000004_D_000000010000000000000002"
+
+	infos, err := getCopyingInfos(deltaName, from, to, true, false)
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+
+	infos, err = getCopyingInfos(deltaName, from, to, true, true)
+	require.NoError(t, err)
+	assert.Len(t, infos, 2)
+	require.NoError(t, copy.Infos(infos))
+	require.NoError(t, copy.VerifyInfos(infos))
+
+	chain, err := GetDeltaChain(NewBackup(to.GetSubFolder(utility.BaseBackupPath), deltaName))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base_000000010000000000000002", deltaName}, chain)
+}
@@ -27,6 +27,9 @@ const (
 type fileInfo struct {
 	status copyStatus
 	info   *internal.ComposeFileInfo
+	// skipped files are unchanged since the delta base, they are never packed
+	// and are either copied along with their tarball or left skipped
+	skipped bool
 }
 
 type headerInfo struct {
@@ -52,6 +55,8 @@ type CopyTarBallComposer struct {
 	prevTarFileSets        internal.TarFileSets
 	fileInfo               map[string]*fileInfo
 	headerInfos            map[string]*headerInfo
+	// copiedTarChecksums are the checksums of the copied tarballs taken over from the previous backup
+	copiedTarChecksums map[string]internal.ObjectChecksum
 }
 
 type CopyTarBallComposerMaker struct {
@@ -97,6 +102,7 @@ func NewCopyTarBallComposer(
 		prevTarFileSets:        prevTarFileSets,
 		fileInfo:               make(map[string]*fileInfo),
 		headerInfos:            make(map[string]*headerInfo),
+		copiedTarChecksums:     make(map[string]internal.ObjectChecksum),
 	}, nil
 }
 
@@ -127,7 +133,8 @@ func (c *CopyTarBallComposer) AddFile(info *internal.ComposeFileInfo) {
 	var fileName = info.Header.Name
 	var currFile = fileInfo{}
 	if _, exists := c.prevFileTar[fileName]; exists {
-		if !c.prevBackup.FilesMetadataDto.Files[fileName].MTime.Equal(info.Header.ModTime) {
+		prevFile := c.prevBackup.FilesMetadataDto.Files[fileName]
+		if prevFile.IsIncremented || !prevFile.MTime.Equal(info.Header.ModTime) {
 			currFile.status = doNotCopy
 		} else {
 			c.tarUnchangedFilesCount[c.prevFileTar[fileName]]--
@@ -160,8 +167,24 @@ func (c *CopyTarBallComposer) AddHeader(fileInfoHeader *tar.Header, info os.File
 	return nil
 }
 
-func (c *CopyTarBallComposer) SkipFile(tarHeader *tar.Header, fileInfo os.FileInfo) {
-	c.files.AddSkippedFile(tarHeader, fileInfo)
+// SkipFile is called for the files unchanged since the delta base.
+// If the delta base has stored such a file in full, its tarball can be copied
+// into the new backup, so the file no longer has to be fetched from the base.
+func (c *CopyTarBallComposer) SkipFile(tarHeader *tar.Header, info os.FileInfo) {
+	c.files.AddSkippedFile(tarHeader, info)
+	var fileName = tarHeader.Name
+	if _, exists := c.prevFileTar[fileName]; !exists {
+		return
+	}
+	if c.prevBackup.FilesMetadataDto.Files[fileName].IsIncremented {
+		return
+	}
+	c.tarUnchangedFilesCount[c.prevFileTar[fileName]]--
+	c.fileInfo[fileName] = &fileInfo{
+		status:  possibleCopy,
+		info:    internal.NewComposeFileInfo("", info, true, false, tarHeader),
+		skipped: true,
+	}
 }
 
 func (c *CopyTarBallComposer) copyTar(tarName string) error {
@@ -176,11 +199,20 @@ func (c *CopyTarBallComposer) copyTar(tarName string) error {
 	if err != nil {
 		return err
 	}
+	// the copy is byte-identical, so the checksum of the previous tarball validates it as well
+	if checksum, ok := c.prevBackup.FilesMetadataDto.TarChecksums[tarName]; ok {
+		c.copiedTarChecksums[newTarName] = checksum
+	} else {
+		tracelog.WarningLogger.Printf("No checksum is recorded for %s, the copied %s won't be validated\n", tarName, newTarName)
+	}
 	for _, fileName := range c.prevTarFileSets.Get()[tarName] {
 		if file, exists := c.fileInfo[fileName]; exists {
 			file.status = processed
 			c.tarFileSets.AddFile(newTarName, fileName)
 			c.files.AddFile(file.info.Header, file.info.FileInfo, file.info.IsIncremented)
+			if crc32c := c.prevBackup.FilesMetadataDto.Files[fileName].Crc32c; crc32c != nil {
+				c.files.AddFileChecksum(fileName, *crc32c)
+			}
 		} else if header, exists := c.headerInfos[fileName]; exists {
 			header.status = processed
 			c.tarFileSets.AddFile(newTarName, fileName)
@@ -190,6 +222,12 @@ func (c *CopyTarBallComposer) copyTar(tarName string) error {
 	return nil
 }
 
+// CopiedTarChecksums returns the checksums of the tarballs copied from the previous backup,
+// they are not uploaded by the uploader so it does not record them
+func (c *CopyTarBallComposer) CopiedTarChecksums() map[string]internal.ObjectChecksum {
+	return c.copiedTarChecksums
+}
+
 func (c *CopyTarBallComposer) getTarBall() internal.TarBall {
 	tarBall := c.tarBallQueue.Deque()
 	tarBall.SetUp(c.crypter)
@@ -238,6 +276,9 @@ func (c *CopyTarBallComposer) FinishComposing() (internal.TarFileSets, error) {
 	var tarBall internal.TarBall
 	for fileName := range c.fileInfo {
 		file := c.fileInfo[fileName]
+		if file.skipped {
+			continue
+		}
 		if file.status == doNotCopy || file.status == fromNew {
 			tarBall = c.getTarBall()
 			c.errorGroup.Go(func() error {
@@ -0,0 +1,80 @@
+package postgres_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func TestCopyTarBallComposer_DeltaCopiesUnchangedTars(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	prevTars := map[string][]string{
+		"part_1.tar.lz4": {"/base/1/1", "/base/1/2"},
+		"part_2.tar.lz4": {"/base/1/3", "/base/1/4"},
+	}
+	for tarName := range prevTars {
+		err := folder.PutObject(path.Join("base_prev", internal.TarPartitionFolderName, tarName),
+			bytes.NewReader([]byte(tarName)))
+		assert.NoError(t, err)
+	}
+	prevFiles := internal.BackupFileList{
+		"/base/1/1": {},
+		"/base/1/2": {},
+		"/base/1/3": {},
+		"/base/1/4": {IsIncremented: true},
+	}
+	prevBackup := postgres.NewBackup(folder, "base_prev")
+	prevBackup.SentinelDto = &postgres.BackupSentinelDto{}
+	prevChecksum := internal.ObjectChecksum{Size: int64(len("part_1.tar.lz4")), CRC32: 42}
+	prevBackup.FilesMetadataDto = &postgres.FilesMetadataDto{Files: prevFiles, TarFileSets: prevTars,
+		TarChecksums: map[string]internal.ObjectChecksum{"part_1.tar.lz4": prevChecksum}}
+
+	prevFileTar := make(map[string]string)
+	prevTarFileSets := internal.NewRegularTarFileSets()
+	tarUnchangedFilesCount := make(map[string]int)
+	for tarName, fileNames := range prevTars {
+		for _, fileName := range fileNames {
+			prevFileTar[fileName] = tarName
+			prevTarFileSets.AddFile(tarName, fileName)
+		}
+		tarUnchangedFilesCount[tarName] = len(fileNames)
+	}
+	files := &internal.RegularBundleFiles{}
+	composer, err := postgres.NewCopyTarBallComposer(nil, nil, files, nil, prevBackup, "base_new",
+		tarUnchangedFilesCount, prevFileTar, prevTarFileSets)
+	assert.NoError(t, err)
+
+	dataFile := filepath.Join(t.TempDir(), "1")
+	assert.NoError(t, os.WriteFile(dataFile, []byte("data"), 0600))
+	info, err := os.Stat(dataFile)
+	assert.NoError(t, err)
+	for fileName := range prevFiles {
+		composer.SkipFile(&tar.Header{Name: fileName}, info)
+	}
+
+	tarFileSets, err := composer.FinishComposing()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"copy_0.tar.lz4": {"/base/1/1", "/base/1/2"}}, tarFileSets.Get())
+	exists, err := folder.Exists(path.Join("base_new", internal.TarPartitionFolderName, "copy_0.tar.lz4"))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, map[string]internal.ObjectChecksum{"copy_0.tar.lz4": prevChecksum}, composer.CopiedTarChecksums())
+
+	backupFiles := internal.BackupFileList{}
+	files.Range(func(key, value interface{}) bool {
+		backupFiles[key.(string)] = value.(internal.BackupFileDescription)
+		return true
+	})
+	assert.False(t, backupFiles["/base/1/1"].IsSkipped)
+	assert.False(t, backupFiles["/base/1/2"].IsSkipped)
+	assert.True(t, backupFiles["/base/1/3"].IsSkipped)
+	assert.True(t, backupFiles["/base/1/4"].IsSkipped)
+}
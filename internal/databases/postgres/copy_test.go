@@ -5,9 +5,12 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/copy"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/fs"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/testtools"
 )
@@ -133,3 +136,57 @@ func TestBuildCopyingInfos_WhenComplexCondition(t *testing.T) {
 		assert.True(t, condition(info.SrcObj))
 	}
 }
+
+func TestVerifyCopyInfos(t *testing.T) {
+	var from = testtools.CreateMockStorageFolderWithPermanentBackups(t)
+	var to = testtools.MakeDefaultInMemoryStorageFolder()
+	infos, err := postgres.WildcardInfo(from, to)
+	assert.NoError(t, err)
+	assert.NoError(t, copy.Infos(infos))
+	assert.NoError(t, copy.VerifyInfos(infos))
+
+	assert.NoError(t, to.DeleteObjects([]string{infos[0].SrcObj.GetName()}))
+	assert.Error(t, copy.VerifyInfos(infos))
+}
+
+func TestStartCopy_KeepsObjectMetadata(t *testing.T) {
+	from := memory.NewFolder("in_memory/", memory.NewStorage())
+	metadata := map[string]string{"walg-zstd-dictionary": "0000abcd"}
+	require.NoError(t, from.PutObjectWithMetadata("wal_005/000000010000000000000001.zst",
+		strings.NewReader("content"), "", metadata))
+	objects, err := storage.ListFolderRecursively(from)
+	require.NoError(t, err)
+	acceptAll := func(object storage.Object) bool { return true }
+
+	to := memory.NewFolder("in_memory/", memory.NewStorage())
+	require.NoError(t, copy.Infos(copy.BuildCopyingInfos(from, to, objects, acceptAll, copy.NoopRenameFunc)))
+	copied, err := storage.ReadObjectMetadata(to, "wal_005/000000010000000000000001.zst")
+	require.NoError(t, err)
+	assert.Equal(t, metadata, copied)
+
+	// the copy without the metadata would be unreadable
+	err = copy.Infos(copy.BuildCopyingInfos(from, fs.NewFolder(t.TempDir(), ""), objects, acceptAll, copy.NoopRenameFunc))
+	assert.Error(t, err)
+}
+
+func TestVerifyCopyInfos_ComparesContentAndMetadata(t *testing.T) {
+	from := memory.NewFolder("in_memory/", memory.NewStorage())
+	require.NoError(t, from.PutObjectWithMetadata("wal_005/000000010000000000000001.zst",
+		strings.NewReader("content"), "", map[string]string{"walg-zstd-dictionary": "0000abcd"}))
+	require.NoError(t, from.PutObject("wal_005/000000010000000000000002.lz4", strings.NewReader("content")))
+	objects, err := storage.ListFolderRecursively(from)
+	require.NoError(t, err)
+	to := memory.NewFolder("in_memory/", memory.NewStorage())
+	infos := copy.BuildCopyingInfos(from, to, objects, func(object storage.Object) bool { return true }, copy.NoopRenameFunc)
+	require.NoError(t, copy.Infos(infos))
+	assert.NoError(t, copy.VerifyInfos(infos))
+
+	// the content of the same size differs
+	require.NoError(t, to.PutObject("wal_005/000000010000000000000002.lz4", strings.NewReader("CONTENT")))
+	assert.ErrorContains(t, copy.VerifyInfos(infos), "000000010000000000000002.lz4")
+
+	// the metadata is lost
+	require.NoError(t, to.PutObject("wal_005/000000010000000000000002.lz4", strings.NewReader("content")))
+	require.NoError(t, to.PutObject("wal_005/000000010000000000000001.zst", strings.NewReader("content")))
+	assert.ErrorContains(t, copy.VerifyInfos(infos), "000000010000000000000001.zst")
+}
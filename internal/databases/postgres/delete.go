@@ -205,13 +205,27 @@ func getIncrementInfo(folder storage.Folder, object storage.Object) (string, str
 	return *sentinel.IncrementFullName, *sentinel.IncrementFrom, false, nil
 }
 
-// HandleDeleteGarbage delete outdated WAL archives and leftover backup files
-func (dh *DeleteHandler) HandleDeleteGarbage(args []string, folder storage.Folder, confirm bool) error {
+// HandleDeleteGarbage delete outdated WAL archives and leftover backup files,
+// then the backup objects not referenced by any backup which are older than orphanMinAge
+func (dh *DeleteHandler) HandleDeleteGarbage(args []string, folder storage.Folder, confirm bool,
+	orphanMinAge time.Duration) error {
+	err := dh.deleteGarbageBeforeOldestBackup(args, folder, confirm)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 && args[0] == DeleteGarbageArchivesModifier {
+		return nil
+	}
+	return DeleteOrphanedBackupObjects(folder.GetSubFolder(utility.BaseBackupPath), orphanMinAge, confirm)
+}
+
+func (dh *DeleteHandler) deleteGarbageBeforeOldestBackup(args []string, folder storage.Folder, confirm bool) error {
 	predicate := ExtractDeleteGarbagePredicate(args)
 	oldestBackup, err := findOldestNonPermanentBackup(folder.GetSubFolder(utility.BaseBackupPath))
 	if err != nil {
 		if _, ok := err.(internal.NoBackupsFoundError); ok {
-			tracelog.InfoLogger.Println("Couldn't find any non-permanent backups in storage. Not doing anything.")
+			tracelog.InfoLogger.Println("Couldn't find any non-permanent backups in storage. " +
+				"Not deleting the outdated objects.")
 			return nil
 		}
 		return err
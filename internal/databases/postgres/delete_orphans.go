@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// DefaultOrphanMinAge is the age of the orphaned backup objects below which they are kept,
+// since the backup which is still being uploaded has no sentinel yet
+const DefaultOrphanMinAge = 24 * time.Hour
+
+// FindOrphanedBackupObjects finds the backup objects which are not referenced by any backup with a sentinel:
+// the files of the backups without a sentinel, e.g. the interrupted ones, and the tar members of the finished
+// backups which are not listed in their files metadata. The files of a backup without a sentinel are kept
+// if any of them was modified within minAge, so the backup being uploaded is never selected.
+func FindOrphanedBackupObjects(baseBackupFolder storage.Folder, minAge time.Duration) ([]string, error) {
+	objects, err := storage.ListFolderRecursively(baseBackupFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	liveBackups := make(map[string]bool)
+	objectsByBackup := make(map[string][]storage.Object)
+	for _, object := range objects {
+		name := object.GetName()
+		// the backups of the other sentinel namespaces are live as well
+		if internal.IsSentinelOfAnyNamespace(name) && !strings.Contains(name, "/") {
+			liveBackups[utility.StripRightmostBackupName(name)] = true
+			continue
+		}
+		backupName, _, isBackupObject := strings.Cut(name, "/")
+		if isBackupObject && strings.HasPrefix(backupName, utility.BackupNamePrefix) {
+			objectsByBackup[backupName] = append(objectsByBackup[backupName], object)
+		}
+	}
+
+	minModified := utility.TimeNowCrossPlatformUTC().Add(-minAge)
+	orphans := make([]string, 0)
+	for backupName, backupObjects := range objectsByBackup {
+		if liveBackups[backupName] {
+			orphans = append(orphans, findOrphanedTars(baseBackupFolder, backupName, backupObjects, minModified)...)
+			continue
+		}
+		if lastModified := getLastModified(backupObjects); lastModified.After(minModified) {
+			tracelog.InfoLogger.Printf("Backup %s has no sentinel, but it was modified at %s, "+
+				"it may be still in progress, skipping\n", backupName, lastModified.Format(time.RFC3339))
+			continue
+		}
+		for _, object := range backupObjects {
+			orphans = append(orphans, object.GetName())
+		}
+	}
+	sort.Strings(orphans)
+	return orphans, nil
+}
+
+// findOrphanedTars finds the tar members of the finished backup which are not referenced by its files metadata.
+// The backups without the tar members listed in the files metadata, e.g. the ones made
+// with --without-files-metadata or by the old versions, are skipped.
+func findOrphanedTars(baseBackupFolder storage.Folder, backupName string, backupObjects []storage.Object,
+	minModified time.Time) []string {
+	backup := NewBackup(baseBackupFolder, backupName)
+	_, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to fetch the files metadata of backup %s, "+
+			"its tar members are not checked: %v\n", backupName, err)
+		return nil
+	}
+	if len(filesMeta.TarFileSets) == 0 {
+		return nil
+	}
+
+	tarPartitionsPrefix := backupName + internal.TarPartitionFolderName
+	orphans := make([]string, 0)
+	for _, object := range backupObjects {
+		tarName := strings.TrimPrefix(object.GetName(), tarPartitionsPrefix)
+		if tarName == object.GetName() || strings.HasPrefix(tarName, PgControl) {
+			continue
+		}
+		_, isListed := filesMeta.TarFileSets[tarName]
+		_, isUploaded := filesMeta.TarChecksums[tarName]
+		if !isListed && !isUploaded && object.GetLastModified().Before(minModified) {
+			orphans = append(orphans, object.GetName())
+		}
+	}
+	return orphans
+}
+
+func getLastModified(objects []storage.Object) time.Time {
+	var lastModified time.Time
+	for _, object := range objects {
+		if object.GetLastModified().After(lastModified) {
+			lastModified = object.GetLastModified()
+		}
+	}
+	return lastModified
+}
+
+// DeleteOrphanedBackupObjects deletes the backup objects found by FindOrphanedBackupObjects,
+// only the list of them is printed unless confirmed
+func DeleteOrphanedBackupObjects(baseBackupFolder storage.Folder, minAge time.Duration, confirmed bool) error {
+	tracelog.InfoLogger.Printf("Looking for the backup objects not referenced by any backup, older than %s\n", minAge)
+	orphans, err := FindOrphanedBackupObjects(baseBackupFolder, minAge)
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		tracelog.InfoLogger.Println("No orphaned backup objects found")
+		return nil
+	}
+	for _, orphan := range orphans {
+		tracelog.InfoLogger.Println("\twill be deleted: " + orphan)
+	}
+	if !confirmed {
+		tracelog.InfoLogger.Println("Dry run, nothing were deleted")
+		return nil
+	}
+	return baseBackupFolder.DeleteObjects(orphans)
+}
@@ -0,0 +1,103 @@
+package postgres_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	finishedBackup     = "base_000000010000000000000002"
+	noMetadataBackup   = "base_000000010000000000000004"
+	interruptedBackup  = "base_000000010000000000000006"
+	tarPartitionsInfix = "/tar_partitions/"
+)
+
+func putOrphansTestBackups(t *testing.T) storage.Folder {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	filesMetadata := postgres.FilesMetadataDto{TarFileSets: map[string][]string{"part_1.tar.lz4": {"base/1/1"}}}
+	for objectName, dto := range map[string]interface{}{
+		finishedBackup + utility.SentinelSuffix:           postgres.BackupSentinelDto{},
+		finishedBackup + "/" + postgres.FilesMetadataName: filesMetadata,
+		noMetadataBackup + utility.SentinelSuffix:         postgres.BackupSentinelDto{FilesMetadataDisabled: true},
+	} {
+		bytes, err := json.Marshal(dto)
+		require.NoError(t, err)
+		require.NoError(t, folder.PutObject(objectName, strings.NewReader(string(bytes))))
+	}
+	for _, objectName := range []string{
+		finishedBackup + tarPartitionsInfix + "part_1.tar.lz4",
+		finishedBackup + tarPartitionsInfix + "part_2.tar.lz4",
+		finishedBackup + tarPartitionsInfix + "pg_control.tar.lz4",
+		noMetadataBackup + tarPartitionsInfix + "part_1.tar.lz4",
+		interruptedBackup + tarPartitionsInfix + "part_1.tar.lz4",
+		interruptedBackup + "/" + utility.MetadataFileName,
+	} {
+		require.NoError(t, folder.PutObject(objectName, strings.NewReader("data")))
+	}
+	return folder
+}
+
+func TestFindOrphanedBackupObjects(t *testing.T) {
+	folder := putOrphansTestBackups(t)
+
+	// the negative age makes the objects uploaded just now old enough
+	orphans, err := postgres.FindOrphanedBackupObjects(folder, -time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		finishedBackup + tarPartitionsInfix + "part_2.tar.lz4",
+		interruptedBackup + "/" + utility.MetadataFileName,
+		interruptedBackup + tarPartitionsInfix + "part_1.tar.lz4",
+	}, orphans)
+}
+
+func TestFindOrphanedBackupObjects_SkipsRecentObjects(t *testing.T) {
+	folder := putOrphansTestBackups(t)
+
+	orphans, err := postgres.FindOrphanedBackupObjects(folder, postgres.DefaultOrphanMinAge)
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+}
+
+func TestDeleteOrphanedBackupObjects(t *testing.T) {
+	folder := putOrphansTestBackups(t)
+	orphan := finishedBackup + tarPartitionsInfix + "part_2.tar.lz4"
+
+	require.NoError(t, postgres.DeleteOrphanedBackupObjects(folder, -time.Hour, false))
+	exists, err := folder.Exists(orphan)
+	require.NoError(t, err)
+	assert.True(t, exists, "dry run must not delete anything")
+
+	require.NoError(t, postgres.DeleteOrphanedBackupObjects(folder, -time.Hour, true))
+	for objectName, expected := range map[string]bool{
+		orphan: false,
+		interruptedBackup + "/" + utility.MetadataFileName:         false,
+		finishedBackup + tarPartitionsInfix + "part_1.tar.lz4":     true,
+		finishedBackup + tarPartitionsInfix + "pg_control.tar.lz4": true,
+		noMetadataBackup + tarPartitionsInfix + "part_1.tar.lz4":   true,
+		finishedBackup + utility.SentinelSuffix:                    true,
+	} {
+		exists, err := folder.Exists(objectName)
+		require.NoError(t, err)
+		assert.Equal(t, expected, exists, objectName)
+	}
+}
+
+func TestFindOrphanedBackupObjects_OtherSentinelNamespace(t *testing.T) {
+	folder := putOrphansTestBackups(t)
+	otherNamespaceBackup := "base_000000010000000000000008"
+	require.NoError(t, folder.PutObject(otherNamespaceBackup+"_backup_stop_sentinel.v2.json", strings.NewReader("{}")))
+	require.NoError(t, folder.PutObject(otherNamespaceBackup+tarPartitionsInfix+"part_1.tar.lz4", strings.NewReader("data")))
+
+	orphans, err := postgres.FindOrphanedBackupObjects(folder, -time.Hour)
+	require.NoError(t, err)
+	assert.NotContains(t, orphans, otherNamespaceBackup+tarPartitionsInfix+"part_1.tar.lz4")
+}
@@ -26,17 +26,14 @@ func GetPermanentBackupsAndWals(folder storage.Folder) (map[string]bool, map[str
 			continue
 		}
 		if meta.IsPermanent {
-			timelineID, err := ParseTimelineFromBackupName(backup.Name)
+			walSegments, err := getPermanentWalSegments(backup.Name, meta)
 			if err != nil {
-				tracelog.ErrorLogger.Printf("failed to parse backup timeline for backup %s with error %s, ignoring...",
+				tracelog.ErrorLogger.Printf("failed to get the permanent WAL segments of backup %s with error %s, ignoring...",
 					backupTime.BackupName, err.Error())
 				continue
 			}
-
-			startWalSegmentNo := newWalSegmentNo(meta.StartLsn - 1)
-			endWalSegmentNo := newWalSegmentNo(meta.FinishLsn - 1)
-			for walSegmentNo := startWalSegmentNo; walSegmentNo <= endWalSegmentNo; walSegmentNo = walSegmentNo.next() {
-				permanentWals[walSegmentNo.getFilename(timelineID)] = true
+			for _, walSegment := range walSegments {
+				permanentWals[walSegment] = true
 			}
 			permanentBackups[backupTime.BackupName] = true
 		}
@@ -48,6 +45,20 @@ func GetPermanentBackupsAndWals(folder storage.Folder) (map[string]bool, map[str
 	return permanentBackups, permanentWals
 }
 
+// getPermanentWalSegments returns the WAL segments the permanent backup keeps: the ones recorded
+// by the hold of backup-retain, or the ones from the backup start to its finish
+func getPermanentWalSegments(backupName string, meta ExtendedMetadataDto) ([]string, error) {
+	retention := meta.Retention
+	if retention == nil {
+		var err error
+		retention, err = newBackupRetention(backupName, meta, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return retention.getWalSegments()
+}
+
 func IsPermanent(objectName string, permanentBackups, permanentWals map[string]bool) bool {
 	if strings.HasPrefix(objectName, utility.WalPath) && len(objectName) >= len(utility.WalPath)+24 {
 		wal := objectName[len(utility.WalPath) : len(utility.WalPath)+24]
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	DeltaStepsActionFail = "fail"
+	DeltaStepsActionWarn = "warn"
+)
+
+type DeltaChainTooLongError struct {
+	error
+}
+
+func newDeltaChainTooLongError(backupName string, deltaSteps, maxDeltaSteps int) DeltaChainTooLongError {
+	return DeltaChainTooLongError{errors.Errorf(
+		"restoring backup %s requires %d delta steps, more than the maximum of %d", backupName, deltaSteps, maxDeltaSteps)}
+}
+
+func (err DeltaChainTooLongError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// ParseDeltaStepsAction checks the action on exceeding the maximum delta steps
+func ParseDeltaStepsAction(action string) (string, error) {
+	switch action {
+	case "", DeltaStepsActionFail:
+		return DeltaStepsActionFail, nil
+	case DeltaStepsActionWarn:
+		return DeltaStepsActionWarn, nil
+	default:
+		return "", errors.Errorf("unknown delta steps action '%s', expected '%s' or '%s'",
+			action, DeltaStepsActionFail, DeltaStepsActionWarn)
+	}
+}
+
+// GetPgMaxDeltaStepsFetcher wraps the fetcher to check the number of the delta steps the restore of the backup takes
+// before fetching anything. If there are more than maxDeltaSteps, the restore fails or goes on with the warning
+// depending on the action. The delta chain is printed either way.
+func GetPgMaxDeltaStepsFetcher(fetcher func(rootFolder storage.Folder, backup internal.Backup),
+	maxDeltaSteps int, action string) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		err := checkDeltaSteps(ToPgBackup(backup), maxDeltaSteps, action)
+		internal.FatalOnError(err)
+
+		fetcher(rootFolder, backup)
+	}
+}
+
+func checkDeltaSteps(backup Backup, maxDeltaSteps int, action string) error {
+	chain, err := GetDeltaChain(backup)
+	if err != nil {
+		return err
+	}
+	deltaSteps := len(chain) - 1
+	if deltaSteps == 0 {
+		tracelog.InfoLogger.Printf("Backup %s is a full backup, no delta steps to restore\n", backup.Name)
+		return nil
+	}
+	tracelog.InfoLogger.Printf("Restoring backup %s takes %d delta steps: %s\n",
+		backup.Name, deltaSteps, strings.Join(chain, " -> "))
+	if deltaSteps <= maxDeltaSteps {
+		return nil
+	}
+	err = newDeltaChainTooLongError(backup.Name, deltaSteps, maxDeltaSteps)
+	if action == DeltaStepsActionWarn {
+		tracelog.WarningLogger.Println(err)
+		return nil
+	}
+	return err
+}
+
+// GetDeltaChain returns the names of the backups the restore of the backup traverses, read from their sentinels
+// from the backup itself to its full base backup, and ordered from the full base backup
+func GetDeltaChain(backup Backup) ([]string, error) {
+	baseBackupFolder := backup.Folder
+	chain := []string{backup.Name}
+	visited := map[string]bool{backup.Name: true}
+	for {
+		sentinelDto, err := backup.GetSentinel()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch the sentinel of backup %s", backup.Name)
+		}
+		if !sentinelDto.IsIncremental() {
+			break
+		}
+		baseName := *sentinelDto.IncrementFrom
+		if visited[baseName] {
+			return nil, errors.Errorf("delta chain of backup %s loops at %s", chain[len(chain)-1], baseName)
+		}
+		visited[baseName] = true
+		chain = append([]string{baseName}, chain...)
+		backup = NewBackup(baseBackupFolder, baseName)
+	}
+	return chain, nil
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// putDeltaChain puts the sentinels of the full backup and the deltas on top of each other
+func putDeltaChain(t *testing.T, folder storage.Folder, backupNames ...string) {
+	fullName := backupNames[0]
+	for i, backupName := range backupNames {
+		sentinel := BackupSentinelDto{}
+		if i > 0 {
+			lsn := LSN(i)
+			count := i
+			sentinel.IncrementFrom = &backupNames[i-1]
+			sentinel.IncrementFromLSN = &lsn
+			sentinel.IncrementFullName = &fullName
+			sentinel.IncrementCount = &count
+		}
+		bytes, err := json.Marshal(sentinel)
+		require.NoError(t, err)
+		require.NoError(t, folder.PutObject(backupName+utility.SentinelSuffix, strings.NewReader(string(bytes))))
+	}
+}
+
+func TestGetDeltaChain(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage()).GetSubFolder(utility.BaseBackupPath)
+	putDeltaChain(t, folder, "base_000000010000000000000002", "base_000000010000000000000004_D_000000010000000000000002",
+		"base_000000010000000000000006_D_000000010000000000000004")
+
+	chain, err := GetDeltaChain(NewBackup(folder, "base_000000010000000000000006_D_000000010000000000000004"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base_000000010000000000000002", "base_000000010000000000000004_D_000000010000000000000002",
+		"base_000000010000000000000006_D_000000010000000000000004"}, chain)
+
+	chain, err = GetDeltaChain(NewBackup(folder, "base_000000010000000000000002"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base_000000010000000000000002"}, chain)
+}
+
+func TestGetDeltaChain_MissingBase(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage()).GetSubFolder(utility.BaseBackupPath)
+	putDeltaChain(t, folder, "base_000000010000000000000002", "base_000000010000000000000004_D_000000010000000000000002")
+	require.NoError(t, folder.DeleteObjects([]string{"base_000000010000000000000002" + utility.SentinelSuffix}))
+
+	_, err := GetDeltaChain(NewBackup(folder, "base_000000010000000000000004_D_000000010000000000000002"))
+	assert.Error(t, err)
+}
+
+func TestParseDeltaStepsAction(t *testing.T) {
+	action, err := ParseDeltaStepsAction("")
+	require.NoError(t, err)
+	assert.Equal(t, DeltaStepsActionFail, action)
+	action, err = ParseDeltaStepsAction("warn")
+	require.NoError(t, err)
+	assert.Equal(t, DeltaStepsActionWarn, action)
+	_, err = ParseDeltaStepsAction("ignore")
+	assert.Error(t, err)
+}
+
+func TestCheckDeltaSteps(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage()).GetSubFolder(utility.BaseBackupPath)
+	putDeltaChain(t, folder, "base_000000010000000000000002", "base_000000010000000000000004_D_000000010000000000000002",
+		"base_000000010000000000000006_D_000000010000000000000004")
+	backup := NewBackup(folder, "base_000000010000000000000006_D_000000010000000000000004")
+
+	assert.NoError(t, checkDeltaSteps(backup, 2, DeltaStepsActionFail))
+	assert.IsType(t, DeltaChainTooLongError{}, checkDeltaSteps(backup, 1, DeltaStepsActionFail))
+	assert.NoError(t, checkDeltaSteps(backup, 1, DeltaStepsActionWarn))
+	assert.NoError(t, checkDeltaSteps(NewBackup(folder, "base_000000010000000000000002"), 0, DeltaStepsActionFail))
+}
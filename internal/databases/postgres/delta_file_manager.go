@@ -167,7 +167,7 @@ func (manager *DeltaFileManager) FlushDeltaFiles(uploader *internal.Uploader, co
 					"Failed to upload delta file: '%s' because of saving error: '%v'\n",
 					deltaFilename, err)
 			} else {
-				err = uploader.UploadFile(ioextensions.NewNamedReaderImpl(&deltaFileData, deltaFilename))
+				_, err = uploader.UploadFile(ioextensions.NewNamedReaderImpl(&deltaFileData, deltaFilename))
 				if err != nil {
 					tracelog.WarningLogger.Printf(
 						"Failed to upload delta file: '%s' because of uploading error: '%v'\n",
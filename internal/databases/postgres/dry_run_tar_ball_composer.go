@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/wal-g/wal-g/internal"
+)
+
+const otherFilesLocation = "other"
+
+// DryRunReport describes the backup which would be made
+type DryRunReport struct {
+	FilesCount          int
+	UnchangedFilesCount int
+	TotalSize           int64
+	// LocationSizes stores the total size of the files in
+	// base, global, each pg_tblspc/<oid> and the rest of the data directory
+	LocationSizes map[string]int64
+	ExcludedPaths []string
+}
+
+func newDryRunReport() *DryRunReport {
+	return &DryRunReport{LocationSizes: make(map[string]int64)}
+}
+
+// DryRunTarBallComposer collects the statistics of the files which would be
+// packed into the backup, without reading or uploading their contents.
+type DryRunTarBallComposer struct {
+	report *DryRunReport
+}
+
+func NewDryRunTarBallComposer(report *DryRunReport) *DryRunTarBallComposer {
+	return &DryRunTarBallComposer{report: report}
+}
+
+func (c *DryRunTarBallComposer) AddFile(info *internal.ComposeFileInfo) {
+	size := info.FileInfo.Size()
+	c.report.FilesCount++
+	c.report.TotalSize += size
+	c.report.LocationSizes[getFileLocation(info.Header.Name)] += size
+}
+
+func (c *DryRunTarBallComposer) AddHeader(fileInfoHeader *tar.Header, info os.FileInfo) error {
+	return nil
+}
+
+func (c *DryRunTarBallComposer) SkipFile(tarHeader *tar.Header, fileInfo os.FileInfo) {
+	c.report.UnchangedFilesCount++
+}
+
+func (c *DryRunTarBallComposer) FinishComposing() (internal.TarFileSets, error) {
+	return internal.NewNopTarFileSets(), nil
+}
+
+func (c *DryRunTarBallComposer) GetFiles() internal.BundleFiles {
+	return &internal.NopBundleFiles{}
+}
+
+// getFileLocation returns the tablespace directory which the file belongs to
+func getFileLocation(fileName string) string {
+	parts := strings.Split(strings.TrimPrefix(fileName, "/"), "/")
+	switch {
+	case len(parts) > 1 && (parts[0] == DefaultTablespace || parts[0] == GlobalTablespace):
+		return parts[0]
+	case len(parts) > 2 && parts[0] == NonDefaultTablespace:
+		return parts[0] + "/" + parts[1]
+	default:
+		return otherFilesLocation
+	}
+}
+
+// TODO : unit tests
+func WriteDryRunReport(report *DryRunReport, output io.Writer) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
+	defer writer.Flush()
+	_, err := fmt.Fprintln(writer, "location\tsize")
+	if err != nil {
+		return err
+	}
+	locations := make([]string, 0, len(report.LocationSizes))
+	for location := range report.LocationSizes {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	for _, location := range locations {
+		_, err = fmt.Fprintf(writer, "%s\t%d\n", location, report.LocationSizes[location])
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(writer, "total\t%d\n\nfiles to backup: %d, unchanged files: %d\n",
+		report.TotalSize, report.FilesCount, report.UnchangedFilesCount)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(writer, "excluded paths:")
+	if err != nil {
+		return err
+	}
+	for _, excludedPath := range report.ExcludedPaths {
+		_, err = fmt.Fprintln(writer, excludedPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
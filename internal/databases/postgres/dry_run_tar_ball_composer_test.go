@@ -0,0 +1,86 @@
+package postgres_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+func TestDryRunTarBallComposer_Walk(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{
+		"base/1/1259":         8192,
+		"base/16384/2668":     16384,
+		"global/1262":         8192,
+		"postgresql.conf":     100,
+		"pg_wal/000000010000": 4096,
+		"postmaster.pid":      10,
+	}
+	for name, size := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, make([]byte, size), 0600))
+	}
+
+	bundle := postgres.NewBundle(dir, nil, nil, nil, false, 0)
+	report := &postgres.DryRunReport{LocationSizes: make(map[string]int64)}
+	bundle.TarBallComposer = postgres.NewDryRunTarBallComposer(report)
+	assert.NoError(t, filepath.Walk(dir, bundle.HandleWalkedFSObject))
+
+	assert.Equal(t, 4, report.FilesCount)
+	assert.Equal(t, int64(8192+16384+8192+100), report.TotalSize)
+	assert.Equal(t, map[string]int64{"base": 8192 + 16384, "global": 8192, "other": 100}, report.LocationSizes)
+	assert.ElementsMatch(t, []string{"/pg_wal", "/postmaster.pid"}, bundle.ExcludedPaths)
+
+	report.ExcludedPaths = bundle.ExcludedPaths
+	var output bytes.Buffer
+	assert.NoError(t, postgres.WriteDryRunReport(report, &output))
+	assert.Contains(t, output.String(), "/postmaster.pid")
+}
+
+func TestDryRunTarBallComposer_DeltaDetection(t *testing.T) {
+	dir := t.TempDir()
+	baseFiles := internal.BackupFileList{}
+	for _, name := range []string{"base/1/1259", "postgresql.conf"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, make([]byte, 8192), 0600))
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		baseFiles["/"+name] = internal.BackupFileDescription{MTime: info.ModTime()}
+	}
+	baseLsn := postgres.LSN(0x1000000)
+
+	for deltaDetection, expectedFilesCount := range map[string]int{
+		postgres.DeltaDetectionMtime: 0,
+		postgres.DeltaDetectionLsn:   2,
+	} {
+		bundle := postgres.NewBundle(dir, nil, &baseLsn, baseFiles, false, 0)
+		bundle.DeltaDetection = deltaDetection
+		report := &postgres.DryRunReport{LocationSizes: make(map[string]int64)}
+		bundle.TarBallComposer = postgres.NewDryRunTarBallComposer(report)
+		assert.NoError(t, filepath.Walk(dir, bundle.HandleWalkedFSObject))
+
+		assert.Equal(t, expectedFilesCount, report.FilesCount, deltaDetection)
+		assert.Equal(t, 2-expectedFilesCount, report.UnchangedFilesCount, deltaDetection)
+	}
+}
+
+func TestGetDeltaDetection(t *testing.T) {
+	defer viper.Set(internal.DeltaDetectionSetting, postgres.DeltaDetectionMtime)
+
+	viper.Set(internal.DeltaDetectionSetting, postgres.DeltaDetectionLsn)
+	deltaDetection, err := postgres.GetDeltaDetection()
+	assert.NoError(t, err)
+	assert.Equal(t, postgres.DeltaDetectionLsn, deltaDetection)
+
+	viper.Set(internal.DeltaDetectionSetting, "ctime")
+	_, err = postgres.GetDeltaDetection()
+	assert.Error(t, err)
+}
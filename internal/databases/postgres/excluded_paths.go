@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type InvalidExcludedPathError struct {
+	error
+}
+
+func newInvalidExcludedPathError(excludedPath string) InvalidExcludedPathError {
+	return InvalidExcludedPathError{
+		errors.Errorf("excluded path '%s' must be relative to the data directory", excludedPath)}
+}
+
+func (err InvalidExcludedPathError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// ParseExcludedPaths parses the comma-separated list of paths relative to the data directory.
+// The paths are returned in the form of the backup file names, e.g. "/pg_custom/tmp".
+func ParseExcludedPaths(excludedPathsStr string) (map[string]utility.Empty, error) {
+	excludedPaths := make(map[string]utility.Empty)
+	for _, excludedPath := range strings.Split(excludedPathsStr, ",") {
+		excludedPath = strings.TrimSpace(excludedPath)
+		if excludedPath == "" {
+			continue
+		}
+		relPath, ok := cleanRelPath(excludedPath)
+		if !ok {
+			return nil, newInvalidExcludedPathError(excludedPath)
+		}
+		excludedPaths[relPath] = utility.Empty{}
+	}
+	return excludedPaths, nil
+}
+
+// cleanRelPath converts the path relative to the data directory to the backup file name,
+// it fails on the absolute paths and the paths leading outside of the data directory
+func cleanRelPath(path string) (string, bool) {
+	cleanPath := filepath.ToSlash(filepath.Clean(path))
+	if filepath.IsAbs(path) || cleanPath == "." || cleanPath == ".." || strings.HasPrefix(cleanPath, "../") {
+		return "", false
+	}
+	return utility.PathSeparator + cleanPath, true
+}
+
+// configureExcludedPaths applies the WALG_EXCLUDE_PATHS and WALG_OVERRIDE_DEFAULT_EXCLUDES settings to the bundle
+func (bundle *Bundle) configureExcludedPaths() error {
+	excludedPaths, err := ParseExcludedPaths(viper.GetString(internal.ExcludePathsSetting))
+	if err != nil {
+		return err
+	}
+	bundle.ExcludedRelPaths = excludedPaths
+	if viper.GetBool(internal.OverrideDefaultExcludes) {
+		if len(excludedPaths) == 0 {
+			tracelog.WarningLogger.Printf("%s is set, but %s is empty: nothing will be excluded from the backup",
+				internal.OverrideDefaultExcludes, internal.ExcludePathsSetting)
+		}
+		bundle.ExcludedFilenames = make(map[string]utility.Empty)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package postgres_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func TestParseExcludedPaths(t *testing.T) {
+	excludedPaths, err := postgres.ParseExcludedPaths(" pg_custom/tmp, ext_cache/ ,,./scratch")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]utility.Empty{
+		"/pg_custom/tmp": {},
+		"/ext_cache":     {},
+		"/scratch":       {},
+	}, excludedPaths)
+}
+
+func TestParseExcludedPaths_NotRelative(t *testing.T) {
+	for _, excludedPaths := range []string{"/var/lib/tmp", "../outside", "pg_custom/../..", "."} {
+		_, err := postgres.ParseExcludedPaths(excludedPaths)
+		assert.IsType(t, postgres.InvalidExcludedPathError{}, err, excludedPaths)
+	}
+}
+
+func TestBundle_ExcludedRelPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ext_cache/big.tmp", "base/1/1259"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, make([]byte, 8192), 0600))
+	}
+
+	bundle := postgres.NewBundle(dir, nil, nil, nil, false, 0)
+	bundle.ExcludedRelPaths, _ = postgres.ParseExcludedPaths("ext_cache")
+	report := &postgres.DryRunReport{LocationSizes: make(map[string]int64)}
+	bundle.TarBallComposer = postgres.NewDryRunTarBallComposer(report)
+	assert.NoError(t, filepath.Walk(dir, bundle.HandleWalkedFSObject))
+
+	assert.Equal(t, 1, report.FilesCount)
+	assert.Equal(t, []string{"/ext_cache"}, bundle.ExcludedPaths)
+}
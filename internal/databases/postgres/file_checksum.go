@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type FileChecksumMismatchError struct {
+	error
+}
+
+func newFileChecksumMismatchError(fileName string, expected, actual uint32) FileChecksumMismatchError {
+	return FileChecksumMismatchError{errors.Errorf("checksum mismatch for the file '%s': expected %08x, got %08x",
+		fileName, expected, actual)}
+}
+
+func (err FileChecksumMismatchError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err FileChecksumMismatchError) ExitCode() int {
+	return internal.ExitCodeDataCorruption
+}
+
+// checksumReader calculates the CRC32C of the data being read
+type checksumReader struct {
+	io.Reader
+	hash      hash.Hash32
+	bytesRead int64
+}
+
+func newChecksumReader(reader io.Reader) *checksumReader {
+	checksumHash := crc32.New(crc32cTable)
+	return &checksumReader{Reader: io.TeeReader(reader, checksumHash), hash: checksumHash}
+}
+
+func (reader *checksumReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	reader.bytesRead += int64(n)
+	return n, err
+}
+
+func (reader *checksumReader) checksum() uint32 {
+	return reader.hash.Sum32()
+}
+
+// newRestoredFileChecksumReader returns the reader which checksum is compared to the one recorded
+// in the files metadata, or nil if there is nothing to compare to: old backups and increments have no checksums
+func (tarInterpreter *FileTarInterpreter) newRestoredFileChecksumReader(fileReader io.Reader,
+	fileName string) (*checksumReader, uint32) {
+	fileDescription, ok := tarInterpreter.FilesMetadata.Files[fileName]
+	if !ok || fileDescription.Crc32c == nil || fileDescription.IsIncremented {
+		return nil, 0
+	}
+	return newChecksumReader(fileReader), *fileDescription.Crc32c
+}
+
+func verifyRestoredFileChecksum(reader *checksumReader, fileName string, fileSize int64, expected uint32) error {
+	if reader.bytesRead != fileSize {
+		// the file was not restored this time
+		return nil
+	}
+	if actual := reader.checksum(); actual != expected {
+		err := newFileChecksumMismatchError(fileName, expected, actual)
+		tracelog.ErrorLogger.Println(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// fileHolesChunkSize is the size of the chunks the holes are read by, the zero chunks are skipped
+const fileHolesChunkSize = 64 * 1024
+
+// writeFileHoles writes the file contents leaving the holes of the sparse file unallocated: the zero chunks
+// of the holes are skipped by seeking, and the file is truncated to its size at the end. The chunks of the holes
+// holding any data, written after the holes were found by the backup, are written as usual.
+func writeFileHoles(localFile *os.File, fileReader io.Reader, holes []internal.FileRegion) error {
+	var offset int64
+	chunk := make([]byte, fileHolesChunkSize)
+	for _, hole := range holes {
+		if hole.Offset < offset || hole.Length <= 0 {
+			return errors.Errorf("invalid hole [%d, %d) of file '%s'", hole.Offset, hole.Offset+hole.Length,
+				localFile.Name())
+		}
+		written, err := io.CopyN(localFile, fileReader, hole.Offset-offset)
+		offset += written
+		if err == io.EOF {
+			return localFile.Truncate(offset)
+		}
+		if err != nil {
+			return err
+		}
+		for offset < hole.Offset+hole.Length {
+			chunkSize := int64(fileHolesChunkSize)
+			if hole.Offset+hole.Length-offset < chunkSize {
+				chunkSize = hole.Offset + hole.Length - offset
+			}
+			n, err := io.ReadFull(fileReader, chunk[:chunkSize])
+			if n > 0 {
+				if utility.AllZero(chunk[:n]) {
+					_, err = localFile.Seek(int64(n), io.SeekCurrent)
+				} else {
+					_, err = localFile.Write(chunk[:n])
+				}
+				offset += int64(n)
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return localFile.Truncate(offset)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	written, err := io.Copy(localFile, fileReader)
+	if err != nil {
+		return err
+	}
+	// the trailing hole is not written, so the file is extended to its size
+	return localFile.Truncate(offset + written)
+}
+
+// findFileHoles returns the holes of the file which is less allocated on disk than its size,
+// the holes are not searched for the fully allocated files
+func findFileHoles(path string, fileInfo os.FileInfo) ([]internal.FileRegion, error) {
+	if !isFileSparse(fileInfo) {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open file '%s'", path)
+	}
+	defer utility.LoggedClose(file, "")
+	holes, err := seekFileHoles(file, fileInfo.Size())
+	return holes, errors.Wrapf(err, "failed to find the holes of file '%s'", path)
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package postgres
+
+import (
+	"os"
+
+	"github.com/wal-g/wal-g/internal"
+)
+
+// isFileSparse is not supported, the holes are restored as zeros
+func isFileSparse(fileInfo os.FileInfo) bool {
+	return false
+}
+
+func seekFileHoles(file *os.File, size int64) ([]internal.FileRegion, error) {
+	return nil, nil
+}
@@ -0,0 +1,49 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package postgres
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal"
+	"golang.org/x/sys/unix"
+)
+
+// isFileSparse tells if the file takes fewer blocks on disk than its size requires
+func isFileSparse(fileInfo os.FileInfo) bool {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	// the blocks are counted in 512-byte units regardless of the filesystem block size
+	return ok && stat.Blocks*512 < fileInfo.Size()
+}
+
+// seekFileHoles finds the holes of the file up to its size by SEEK_DATA and SEEK_HOLE. The filesystems
+// not supporting them report the whole file as data, so no holes are found.
+func seekFileHoles(file *os.File, size int64) ([]internal.FileRegion, error) {
+	var holes []internal.FileRegion
+	for offset := int64(0); offset < size; {
+		dataOffset, err := file.Seek(offset, unix.SEEK_DATA)
+		if errors.Is(err, syscall.ENXIO) {
+			// there is no data after the offset, the rest of the file is a hole
+			dataOffset = size
+		} else if err != nil {
+			return nil, err
+		}
+		if dataOffset > size {
+			dataOffset = size
+		}
+		if dataOffset > offset {
+			holes = append(holes, internal.FileRegion{Offset: offset, Length: dataOffset - offset})
+		}
+		if dataOffset == size {
+			break
+		}
+		offset, err = file.Seek(dataOffset, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return holes, nil
+}
@@ -0,0 +1,191 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+const sparseTestFileSize = 1024 * 1024
+
+// createSparseFile creates the file with the data block in the middle of the holes,
+// the test is skipped if the filesystem allocates the holes
+func createSparseFile(t *testing.T, path string) []byte {
+	content := make([]byte, sparseTestFileSize)
+	copy(content[256*1024:], bytes.Repeat([]byte{42}, 8192))
+
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteAt(content[256*1024:256*1024+8192], 256*1024)
+	require.NoError(t, err)
+	require.NoError(t, file.Truncate(sparseTestFileSize))
+
+	fileInfo, err := file.Stat()
+	require.NoError(t, err)
+	if !isFileSparse(fileInfo) {
+		t.Skip("the filesystem of the temporary directory does not support the sparse files")
+	}
+	return content
+}
+
+func getAllocatedSize(t *testing.T, path string) int64 {
+	fileInfo, err := os.Stat(path)
+	require.NoError(t, err)
+	return fileInfo.Sys().(*syscall.Stat_t).Blocks * 512
+}
+
+func TestFindFileHoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "16384")
+	createSparseFile(t, path)
+	fileInfo, err := os.Stat(path)
+	require.NoError(t, err)
+
+	holes, err := findFileHoles(path, fileInfo)
+	require.NoError(t, err)
+	require.Len(t, holes, 2)
+	assert.Equal(t, internal.FileRegion{Offset: 0, Length: 256 * 1024}, holes[0])
+	assert.Equal(t, int64(sparseTestFileSize), holes[1].Offset+holes[1].Length)
+	assert.LessOrEqual(t, holes[1].Offset, int64(256*1024+8192+64*1024))
+}
+
+func TestFindFileHoles_NotSparse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "16384")
+	require.NoError(t, os.WriteFile(path, make([]byte, 64*1024), 0600))
+	fileInfo, err := os.Stat(path)
+	require.NoError(t, err)
+
+	holes, err := findFileHoles(path, fileInfo)
+	require.NoError(t, err)
+	assert.Empty(t, holes)
+}
+
+// bufferTarBall packs the files into the buffer
+type bufferTarBall struct {
+	buffer    bytes.Buffer
+	tarWriter *tar.Writer
+	size      int64
+}
+
+func (tarBall *bufferTarBall) SetUp(crypter crypto.Crypter, args ...string) {
+	tarBall.tarWriter = tar.NewWriter(&tarBall.buffer)
+}
+func (tarBall *bufferTarBall) CloseTar() error        { return tarBall.tarWriter.Close() }
+func (tarBall *bufferTarBall) Size() int64            { return tarBall.size }
+func (tarBall *bufferTarBall) AddSize(size int64)     { tarBall.size += size }
+func (tarBall *bufferTarBall) TarWriter() *tar.Writer { return tarBall.tarWriter }
+func (tarBall *bufferTarBall) AwaitUploads() error    { return nil }
+func (tarBall *bufferTarBall) Name() string           { return "bufferTarBall" }
+
+func TestPackFileIntoTar_RecordsHoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "16384")
+	content := createSparseFile(t, path)
+	fileInfo, err := os.Stat(path)
+	require.NoError(t, err)
+	header, err := tar.FileInfoHeader(fileInfo, "")
+	require.NoError(t, err)
+	header.Name = "base/1/16384"
+
+	files := &internal.RegularBundleFiles{}
+	tarBall := &bufferTarBall{}
+	tarBall.SetUp(nil)
+	packer := newTarBallFilePacker(nil, nil, files, NewTarBallFilePackerOptions(false, false, 1))
+	err = packer.PackFileIntoTar(internal.NewComposeFileInfo(path, fileInfo, false, false, header), tarBall)
+	require.NoError(t, err)
+	require.NoError(t, tarBall.CloseTar())
+
+	description, ok := files.Load(header.Name)
+	require.True(t, ok)
+	holes := description.(internal.BackupFileDescription).Holes
+	require.Len(t, holes, 2)
+	assert.Equal(t, internal.FileRegion{Offset: 0, Length: 256 * 1024}, holes[0])
+
+	// the holes are packed as zeros
+	tarReader := tar.NewReader(&tarBall.buffer)
+	_, err = tarReader.Next()
+	require.NoError(t, err)
+	packed := new(bytes.Buffer)
+	_, err = packed.ReadFrom(tarReader)
+	require.NoError(t, err)
+	assert.Equal(t, content, packed.Bytes())
+}
+
+func TestInterpret_RestoresHoles(t *testing.T) {
+	for _, useNew := range []bool{false, true} {
+		defer func(previous bool) { useNewUnwrapImplementation = previous }(useNewUnwrapImplementation)
+		useNewUnwrapImplementation = useNew
+		viper.Set(internal.TarDisableFsyncSetting, true)
+		defer viper.Set(internal.TarDisableFsyncSetting, false)
+
+		sourcePath := filepath.Join(t.TempDir(), "16384")
+		content := createSparseFile(t, sourcePath)
+		fileInfo, err := os.Stat(sourcePath)
+		require.NoError(t, err)
+		holes, err := findFileHoles(sourcePath, fileInfo)
+		require.NoError(t, err)
+
+		dataDirectory := t.TempDir()
+		fileName := "base/1/16384"
+		filesMetadata := FilesMetadataDto{Files: internal.BackupFileList{
+			fileName: {Size: sparseTestFileSize, Holes: holes},
+		}}
+		interpreter := NewFileTarInterpreter(dataDirectory, BackupSentinelDto{}, filesMetadata, nil, false)
+		err = interpreter.Interpret(bytes.NewReader(content),
+			&tar.Header{Name: fileName, Typeflag: tar.TypeReg, Size: sparseTestFileSize, Mode: 0600})
+		require.NoError(t, err)
+
+		restoredPath := filepath.Join(dataDirectory, fileName)
+		restored, err := os.ReadFile(restoredPath)
+		require.NoError(t, err)
+		assert.Equal(t, content, restored)
+		assert.Less(t, getAllocatedSize(t, restoredPath), int64(sparseTestFileSize/2), "new unwrap: %v", useNew)
+	}
+}
+
+func TestWriteLocalFile_DataInHoles(t *testing.T) {
+	// the data written into the holes after they are found by the backup is restored
+	content := make([]byte, sparseTestFileSize)
+	copy(content[512*1024:], []byte("written after the holes are found"))
+	holes := []internal.FileRegion{{Offset: 0, Length: sparseTestFileSize}}
+
+	path := filepath.Join(t.TempDir(), "16384")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	err = WriteLocalFile(bytes.NewReader(content), &tar.Header{Mode: 0600}, file, holes, false)
+	require.NoError(t, err)
+
+	restored, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, restored)
+}
+
+func TestWriteLocalFile_TruncatedHoles(t *testing.T) {
+	// the file truncated during the backup is restored by its tar member size
+	holes := []internal.FileRegion{{Offset: 8192, Length: 8192}, {Offset: 32768, Length: 8192}}
+	content := bytes.Repeat([]byte{1}, 8192)
+
+	path := filepath.Join(t.TempDir(), "16384")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	err = WriteLocalFile(bytes.NewReader(append(content, make([]byte, 4096)...)), &tar.Header{Mode: 0600},
+		file, holes, false)
+	require.NoError(t, err)
+
+	restored, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, append(content, make([]byte, 4096)...), restored)
+}
@@ -4,6 +4,8 @@ import (
 	"archive/tar"
 	"io"
 	"os"
+
+	"github.com/wal-g/wal-g/internal"
 )
 
 type FileUnwrapperType int
@@ -56,6 +58,8 @@ func NewSkippedResult() *FileUnwrapResult {
 type BackupFileOptions struct {
 	isIncremented bool
 	isPageFile    bool
+	// holes are the holes of the sparse file to leave unallocated
+	holes []internal.FileRegion
 }
 
 type IBackupFileUnwrapper interface {
@@ -64,6 +64,8 @@ func (ms GenericMetaSetter) SetUserData(backupName string, backupFolder storage.
 func (ms GenericMetaSetter) SetIsPermanent(backupName string, backupFolder storage.Folder, isPermanent bool) error {
 	modifier := func(dto ExtendedMetadataDto) ExtendedMetadataDto {
 		dto.IsPermanent = isPermanent
+		// backup-mark replaces the hold of backup-retain
+		dto.Retention = nil
 		return dto
 	}
 	return modifyBackupMetadata(backupName, backupFolder, modifier)
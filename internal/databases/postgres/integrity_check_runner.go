@@ -22,14 +22,23 @@ func (sequences IntegrityCheckDetails) NewPlainTextReader() (io.Reader, error) {
 
 	tableWriter := table.NewWriter()
 	tableWriter.SetOutputMirror(&outputBuffer)
-	defer tableWriter.Render()
 
 	tableWriter.AppendHeader(table.Row{"TLI", "Start", "End", "Segments count", "Status"})
+	var missingSegments []string
 	for _, row := range sequences {
 		tableWriter.AppendRow(table.Row{row.TimelineID,
 			row.StartSegment, row.EndSegment, row.SegmentsCount, row.Status})
+		missingSegments = append(missingSegments, row.MissingSegments...)
 	}
+	tableWriter.Render()
 
+	// list the missing segments one per line, so they can be easily re-archived
+	if len(missingSegments) > 0 {
+		outputBuffer.WriteString("Missing segments:\n")
+		for _, segmentName := range missingSegments {
+			outputBuffer.WriteString(segmentName + "\n")
+		}
+	}
 	return &outputBuffer, nil
 }
 
@@ -44,12 +53,21 @@ type IntegrityCheckRunner struct {
 	walFolderFilenames        []string
 	timelineSwitchMap         map[WalSegmentNo]*TimelineHistoryRecord
 	noBackupsFound            bool
+	// rootFolder is set only if the WalVerifyCheckpoint is stored after the scan
+	rootFolder storage.Folder
+	// fromWalSegmentNo is the start segment of the backup, stopWalSegmentNo is moved up to the checkpoint
+	fromWalSegmentNo WalSegmentNo
 }
 
+// NewIntegrityCheckRunner creates the integrity check that scans the WAL segments down to
+// the start segment of the startBackupName backup or, if it is empty, of the earliest correct backup.
+// Unless fullScan is set, the segments verified by the previous run are not scanned again.
 func NewIntegrityCheckRunner(
 	rootFolder storage.Folder,
 	walFolderFilenames []string,
 	currentWalSegment WalSegmentDescription,
+	startBackupName string,
+	fullScan bool,
 ) (IntegrityCheckRunner, error) {
 	walFolder := rootFolder.GetSubFolder(utility.WalPath)
 
@@ -59,8 +77,15 @@ func NewIntegrityCheckRunner(
 	}
 
 	noBackupsFound := false
-	stopWalSegmentNo, err := getEarliestBackupStartSegmentNo(timelineSwitchMap, currentWalSegment.Timeline, rootFolder)
-	if err != nil {
+	var stopWalSegmentNo WalSegmentNo
+	if startBackupName != "" {
+		stopWalSegmentNo, err = getBackupStartSegmentNo(startBackupName,
+			timelineSwitchMap, currentWalSegment.Timeline, rootFolder)
+		if err != nil {
+			return IntegrityCheckRunner{}, errors.Wrapf(err, "Failed to detect the backup %s WAL segment no", startBackupName)
+		}
+	} else if stopWalSegmentNo, err = getEarliestBackupStartSegmentNo(timelineSwitchMap,
+		currentWalSegment.Timeline, rootFolder); err != nil {
 		tracelog.WarningLogger.Printf("Failed to detect earliest backup WAL segment no: '%v',"+
 			"will scan until the 0000000X0000000000000001 segment.\n", err)
 		stopWalSegmentNo = 1
@@ -74,7 +99,7 @@ func NewIntegrityCheckRunner(
 		return IntegrityCheckRunner{}, errors.Wrap(err, "Failed to resolve MaxUploadConcurrency")
 	}
 
-	return IntegrityCheckRunner{
+	check := IntegrityCheckRunner{
 		startWalSegment:           currentWalSegment,
 		stopWalSegmentNo:          stopWalSegmentNo,
 		uploadingSegmentRangeSize: uploadingSegmentRangeSize,
@@ -82,7 +107,40 @@ func NewIntegrityCheckRunner(
 		walFolderFilenames:        walFolderFilenames,
 		timelineSwitchMap:         timelineSwitchMap,
 		noBackupsFound:            noBackupsFound,
-	}, nil
+		fromWalSegmentNo:          stopWalSegmentNo,
+	}
+	// without backups the first missing segments are not lost, so there is nothing to verify from
+	if !noBackupsFound {
+		check.rootFolder = rootFolder
+		if !fullScan {
+			check.moveStopToCheckpoint(loadWalVerifyCheckpoint(rootFolder))
+		}
+	}
+	return check, nil
+}
+
+// moveStopToCheckpoint makes the check scan only the segments after the verified segment of the checkpoint,
+// the verified segment itself is scanned again to make sure that the verified sequence continues
+func (check *IntegrityCheckRunner) moveStopToCheckpoint(checkpoint *WalVerifyCheckpoint) {
+	if checkpoint == nil {
+		return
+	}
+	fromSegmentNo, ok := checkpoint.getFromSegmentNo()
+	if !ok {
+		return
+	}
+	if fromSegmentNo > check.fromWalSegmentNo {
+		tracelog.InfoLogger.Printf("The checkpoint is verified from the segment %s, "+
+			"which is after the backup start segment, verifying the whole WAL range\n", checkpoint.FromSegment)
+		return
+	}
+	verifiedSegmentNo, ok := checkpoint.getVerifiedSegmentNo(check.startWalSegment.Timeline,
+		getSwitchSegNoByTimeline(check.timelineSwitchMap))
+	if !ok || verifiedSegmentNo <= check.stopWalSegmentNo {
+		return
+	}
+	tracelog.InfoLogger.Printf("Verifying the WAL segments after the checkpoint segment %s\n", checkpoint.VerifiedSegment)
+	check.stopWalSegmentNo = verifiedSegmentNo
 }
 
 func (check IntegrityCheckRunner) Run() (WalVerifyCheckResult, error) {
@@ -99,10 +157,32 @@ func (check IntegrityCheckRunner) Run() (WalVerifyCheckResult, error) {
 	}
 
 	integrityScanSegmentSequences := collapseSegmentsByStatusAndTimeline(segmentScanner.ScannedSegments)
+	if check.rootFolder != nil {
+		check.storeCheckpoint(segmentScanner.ScannedSegments)
+	}
 
 	return check.newWalIntegrityCheckResult(integrityScanSegmentSequences), nil
 }
 
+// storeCheckpoint stores the last segment of the found sequence that starts with the stop segment.
+// The failure to store the checkpoint doesn't fail the check, the next run just scans more segments.
+func (check IntegrityCheckRunner) storeCheckpoint(scannedSegments []ScannedSegmentDescription) {
+	lastVerified, ok := findLastVerifiedSegment(scannedSegments, check.stopWalSegmentNo)
+	if !ok {
+		return
+	}
+	fromTimeline := getHistoryTimeline(check.fromWalSegmentNo, check.startWalSegment.Timeline,
+		getSwitchSegNoByTimeline(check.timelineSwitchMap))
+	checkpoint := WalVerifyCheckpoint{
+		FromSegment:     check.fromWalSegmentNo.getFilename(fromTimeline),
+		VerifiedSegment: lastVerified.GetFileName(),
+	}
+	err := storeWalVerifyCheckpoint(check.rootFolder, checkpoint)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to store %s: %v\n", WalVerifyCheckpointName, err)
+	}
+}
+
 func (check IntegrityCheckRunner) Type() WalVerifyCheckType {
 	return WalVerifyIntegrityCheck
 }
@@ -146,17 +226,34 @@ type IntegrityScanSegmentSequence struct {
 	EndSegment    string               `json:"end_segment"`
 	SegmentsCount int                  `json:"segments_count"`
 	Status        ScannedSegmentStatus `json:"status"`
+	// MissingSegments lists the names of the segments that are not found in storage
+	MissingSegments []string `json:"missing_segments,omitempty"`
 }
 
 func newIntegrityScanSegmentSequence(sequence *WalSegmentsSequence,
 	status ScannedSegmentStatus) *IntegrityScanSegmentSequence {
-	return &IntegrityScanSegmentSequence{
+	integritySequence := &IntegrityScanSegmentSequence{
 		TimelineID:    sequence.TimelineID,
 		StartSegment:  sequence.MinSegmentNo.getFilename(sequence.TimelineID),
 		EndSegment:    sequence.MaxSegmentNo.getFilename(sequence.TimelineID),
 		Status:        status,
 		SegmentsCount: len(sequence.WalSegmentNumbers),
 	}
+	if status != Found {
+		segmentNumbers := make([]WalSegmentNo, 0, len(sequence.WalSegmentNumbers))
+		for segmentNo := range sequence.WalSegmentNumbers {
+			segmentNumbers = append(segmentNumbers, segmentNo)
+		}
+		sort.Slice(segmentNumbers, func(i, j int) bool {
+			return segmentNumbers[i] < segmentNumbers[j]
+		})
+		integritySequence.MissingSegments = make([]string, 0, len(segmentNumbers))
+		for _, segmentNo := range segmentNumbers {
+			integritySequence.MissingSegments = append(integritySequence.MissingSegments,
+				segmentNo.getFilename(sequence.TimelineID))
+		}
+	}
+	return integritySequence
 }
 
 // runWalIntegrityScan invokes the following storage scan series
@@ -242,13 +339,8 @@ func getEarliestBackupStartSegmentNo(timelineSwitchMap map[WalSegmentNo]*Timelin
 		return 0, err
 	}
 
-	// switchLsnBySegNo is used for fast lookup of the timeline switch segment
-	switchLsnBySegNo := make(map[uint32]WalSegmentNo, len(timelineSwitchMap))
-	for _, historyRecord := range timelineSwitchMap {
-		switchLsnBySegNo[historyRecord.timeline] = newWalSegmentNo(historyRecord.lsn)
-	}
 	earliestBackup, earliestBackupSegNo, err :=
-		findEarliestBackup(currentTimeline, backupDetails, switchLsnBySegNo)
+		findEarliestBackup(currentTimeline, backupDetails, getSwitchSegNoByTimeline(timelineSwitchMap))
 	if err != nil {
 		return 0, err
 	}
@@ -258,6 +350,44 @@ func getEarliestBackupStartSegmentNo(timelineSwitchMap map[WalSegmentNo]*Timelin
 	return earliestBackupSegNo, nil
 }
 
+// getBackupStartSegmentNo returns the starting segmentNo of the backup,
+// the backup must belong to the current timeline history
+func getBackupStartSegmentNo(backupName string,
+	timelineSwitchMap map[WalSegmentNo]*TimelineHistoryRecord,
+	currentTimeline uint32,
+	rootFolder storage.Folder) (WalSegmentNo, error) {
+	backup, err := internal.GetBackupByName(backupName, utility.BaseBackupPath, rootFolder)
+	if err != nil {
+		return 0, err
+	}
+	backupTimelineID, backupLogSegNoInt, err := ParseWALFilename(utility.StripWalFileName(backup.Name))
+	if err != nil {
+		return 0, err
+	}
+	backupLogSegNo := WalSegmentNo(backupLogSegNoInt)
+
+	if backupTimelineID != currentTimeline {
+		timelineSwitchSegNo, ok := getSwitchSegNoByTimeline(timelineSwitchMap)[backupTimelineID]
+		if !ok || backupLogSegNo >= timelineSwitchSegNo {
+			return 0, errors.Errorf("backup %s does not belong to the history of the current timeline %d",
+				backup.Name, currentTimeline)
+		}
+	}
+
+	tracelog.InfoLogger.Printf("Scanning WAL segments down to the backup %s start segment %s\n",
+		backup.Name, backupLogSegNo.getFilename(backupTimelineID))
+	return backupLogSegNo, nil
+}
+
+// getSwitchSegNoByTimeline is used for fast lookup of the timeline switch segment
+func getSwitchSegNoByTimeline(timelineSwitchMap map[WalSegmentNo]*TimelineHistoryRecord) map[uint32]WalSegmentNo {
+	switchSegNoByTimeline := make(map[uint32]WalSegmentNo, len(timelineSwitchMap))
+	for _, historyRecord := range timelineSwitchMap {
+		switchSegNoByTimeline[historyRecord.timeline] = newWalSegmentNo(historyRecord.lsn)
+	}
+	return switchSegNoByTimeline
+}
+
 // findEarliestBackup finds earliest correct backup available in storage.
 func findEarliestBackup(
 	currentTimeline uint32,
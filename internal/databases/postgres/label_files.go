@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type LabelFilesNotStoredError struct {
+	error
+}
+
+func newLabelFilesNotStoredError(backupName string) LabelFilesNotStoredError {
+	return LabelFilesNotStoredError{errors.Errorf("%s of backup %s is not stored as a separate object, "+
+		"the backup is made without %s", BackupLabelFilename, backupName, internal.UploadLabelFilesSetting)}
+}
+
+func (err LabelFilesNotStoredError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// BackupLabelFiles are the contents of the backup_label and tablespace_map of the backup
+type BackupLabelFiles struct {
+	BackupLabel string
+	// TablespaceMap is empty if the cluster has no tablespaces
+	TablespaceMap string
+}
+
+func getLabelFilePath(backupName, fileName string) string {
+	return backupName + "/" + fileName
+}
+
+// uploadLabelFileObjects uploads backup_label and tablespace_map next to the sentinel if WALG_UPLOAD_LABEL_FILES
+// is set, so they are read without downloading the tar members. The empty tablespace_map is not uploaded.
+// The objects are encrypted like the tar members, but not compressed.
+func (bh *BackupHandler) uploadLabelFileObjects() error {
+	if !viper.GetBool(internal.UploadLabelFilesSetting) {
+		return nil
+	}
+	labelFiles := bh.curBackupInfo.labelFiles
+	if labelFiles.BackupLabel == "" {
+		tracelog.WarningLogger.Printf("%s of backup %s is not known, it is not uploaded as a separate object",
+			BackupLabelFilename, bh.curBackupInfo.name)
+		return nil
+	}
+	crypter := internal.ConfigureCrypter()
+	if bh.workers.bundle != nil {
+		// the envelope encryption uses the data key of the backup
+		crypter = bh.workers.bundle.Crypter
+	}
+	err := bh.workers.uploader.Upload(getLabelFilePath(bh.curBackupInfo.name, BackupLabelFilename),
+		internal.CompressAndEncrypt(strings.NewReader(labelFiles.BackupLabel), nil, crypter))
+	if err != nil || labelFiles.TablespaceMap == "" {
+		return err
+	}
+	return bh.workers.uploader.Upload(getLabelFilePath(bh.curBackupInfo.name, TablespaceMapFilename),
+		internal.CompressAndEncrypt(strings.NewReader(labelFiles.TablespaceMap), nil, crypter))
+}
+
+// HandleBackupShowLabel prints backup_label and tablespace_map stored as the separate objects
+func HandleBackupShowLabel(folder storage.Folder, backupSelector internal.BackupSelector) {
+	backupName, err := backupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	labelFiles, err := FetchBackupLabelFiles(NewBackup(folder.GetSubFolder(utility.BaseBackupPath), backupName))
+	internal.FatalOnError(err)
+	err = WriteBackupLabelFiles(labelFiles, os.Stdout)
+	tracelog.ErrorLogger.FatalOnError(err)
+}
+
+// FetchBackupLabelFiles downloads backup_label and tablespace_map stored as the separate objects
+func FetchBackupLabelFiles(backup Backup) (BackupLabelFiles, error) {
+	var labelFiles BackupLabelFiles
+	var err error
+	labelFiles.BackupLabel, err = readLabelFile(backup, BackupLabelFilename)
+	if _, ok := errors.Cause(err).(storage.ObjectNotFoundError); ok {
+		return BackupLabelFiles{}, newLabelFilesNotStoredError(backup.Name)
+	}
+	if err != nil {
+		return BackupLabelFiles{}, err
+	}
+	labelFiles.TablespaceMap, err = readLabelFile(backup, TablespaceMapFilename)
+	if _, ok := errors.Cause(err).(storage.ObjectNotFoundError); ok {
+		return labelFiles, nil
+	}
+	return labelFiles, err
+}
+
+func readLabelFile(backup Backup, fileName string) (string, error) {
+	reader, err := backup.Folder.ReadObject(getLabelFilePath(backup.Name, fileName))
+	if err != nil {
+		return "", err
+	}
+	defer utility.LoggedClose(reader, "")
+	decryptReader, err := internal.DecryptBytes(reader)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to decrypt %s of backup %s", fileName, backup.Name)
+	}
+	content, err := io.ReadAll(decryptReader)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s of backup %s", fileName, backup.Name)
+	}
+	return string(content), nil
+}
+
+// WriteBackupLabelFiles writes backup_label, followed by tablespace_map if the backup has tablespaces
+func WriteBackupLabelFiles(labelFiles BackupLabelFiles, output io.Writer) error {
+	_, err := fmt.Fprintf(output, "%s:\n%s", BackupLabelFilename, labelFiles.BackupLabel)
+	if err != nil || labelFiles.TablespaceMap == "" {
+		return err
+	}
+	_, err = fmt.Fprintf(output, "\n%s:\n%s", TablespaceMapFilename, labelFiles.TablespaceMap)
+	return err
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func newLabelFilesTestHandler(labelFiles BackupLabelFiles) *BackupHandler {
+	uploader := internal.NewUploader(&lz4.Compressor{}, memory.NewFolder("in_memory/", memory.NewStorage()))
+	return &BackupHandler{
+		curBackupInfo: CurBackupInfo{name: "base_000000010000000000000004", labelFiles: labelFiles},
+		workers:       BackupWorkers{uploader: &WalUploader{Uploader: uploader}},
+	}
+}
+
+func TestUploadLabelFileObjects(t *testing.T) {
+	viper.Set(internal.UploadLabelFilesSetting, true)
+	defer viper.Set(internal.UploadLabelFilesSetting, false)
+	bh := newLabelFilesTestHandler(BackupLabelFiles{BackupLabel: snapshotBackupLabel,
+		TablespaceMap: "16400 /mnt/tablespace\n"})
+	require.NoError(t, bh.uploadLabelFileObjects())
+
+	backup := NewBackup(bh.workers.uploader.UploadingFolder, bh.curBackupInfo.name)
+	labelFiles, err := FetchBackupLabelFiles(backup)
+	require.NoError(t, err)
+	assert.Equal(t, bh.curBackupInfo.labelFiles, labelFiles)
+
+	var output bytes.Buffer
+	require.NoError(t, WriteBackupLabelFiles(labelFiles, &output))
+	assert.Equal(t, "backup_label:\n"+snapshotBackupLabel+"\ntablespace_map:\n16400 /mnt/tablespace\n",
+		output.String())
+}
+
+func TestUploadLabelFileObjects_WithoutTablespaces(t *testing.T) {
+	viper.Set(internal.UploadLabelFilesSetting, true)
+	defer viper.Set(internal.UploadLabelFilesSetting, false)
+	bh := newLabelFilesTestHandler(BackupLabelFiles{BackupLabel: snapshotBackupLabel})
+	require.NoError(t, bh.uploadLabelFileObjects())
+
+	labelFiles, err := FetchBackupLabelFiles(NewBackup(bh.workers.uploader.UploadingFolder, bh.curBackupInfo.name))
+	require.NoError(t, err)
+	assert.Equal(t, BackupLabelFiles{BackupLabel: snapshotBackupLabel}, labelFiles)
+
+	var output bytes.Buffer
+	require.NoError(t, WriteBackupLabelFiles(labelFiles, &output))
+	assert.Equal(t, "backup_label:\n"+snapshotBackupLabel, output.String())
+}
+
+func TestUploadLabelFileObjects_Encrypted(t *testing.T) {
+	viper.Set(internal.UploadLabelFilesSetting, true)
+	viper.Set(internal.PgpKeyPathSetting, "../../../test/testdata/waleGpgKey")
+	defer viper.Set(internal.UploadLabelFilesSetting, false)
+	defer viper.Set(internal.PgpKeyPathSetting, nil)
+	bh := newLabelFilesTestHandler(BackupLabelFiles{BackupLabel: snapshotBackupLabel})
+	require.NoError(t, bh.uploadLabelFileObjects())
+
+	folder := bh.workers.uploader.UploadingFolder
+	reader, err := folder.ReadObject(getLabelFilePath(bh.curBackupInfo.name, BackupLabelFilename))
+	require.NoError(t, err)
+	stored, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored), "START WAL LOCATION")
+
+	labelFiles, err := FetchBackupLabelFiles(NewBackup(folder, bh.curBackupInfo.name))
+	require.NoError(t, err)
+	assert.Equal(t, BackupLabelFiles{BackupLabel: snapshotBackupLabel}, labelFiles)
+}
+
+func TestFetchBackupLabelFiles_NotStored(t *testing.T) {
+	bh := newLabelFilesTestHandler(BackupLabelFiles{BackupLabel: snapshotBackupLabel})
+	// nothing is uploaded without WALG_UPLOAD_LABEL_FILES
+	require.NoError(t, bh.uploadLabelFileObjects())
+
+	_, err := FetchBackupLabelFiles(NewBackup(bh.workers.uploader.UploadingFolder, bh.curBackupInfo.name))
+	assert.IsType(t, LabelFilesNotStoredError{}, err)
+}
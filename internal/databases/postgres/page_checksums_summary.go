@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wal-g/tracelog"
+)
+
+// PageChecksumsSummary describes the results of the page checksums verification made during the backup
+type PageChecksumsSummary struct {
+	CheckedPagesCount  uint64 `json:"CheckedPagesCount"`
+	CorruptBlocksCount uint64 `json:"CorruptBlocksCount"`
+	// CorruptRelations maps the relation (tablespace/database/relfilenode OIDs) to its corrupt blocks count
+	CorruptRelations map[string]uint64 `json:"CorruptRelations,omitempty"`
+}
+
+// pageChecksumsCollector aggregates the verification results of the files packed in parallel
+type pageChecksumsCollector struct {
+	mutex   sync.Mutex
+	summary PageChecksumsSummary
+}
+
+func newPageChecksumsCollector() *pageChecksumsCollector {
+	return &pageChecksumsCollector{}
+}
+
+func (collector *pageChecksumsCollector) add(path string, checkedPagesCount int, corruptBlocks []uint32) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	collector.summary.CheckedPagesCount += uint64(checkedPagesCount)
+	if len(corruptBlocks) == 0 {
+		return
+	}
+	collector.summary.CorruptBlocksCount += uint64(len(corruptBlocks))
+	if collector.summary.CorruptRelations == nil {
+		collector.summary.CorruptRelations = make(map[string]uint64)
+	}
+	collector.summary.CorruptRelations[getRelationName(path)] += uint64(len(corruptBlocks))
+}
+
+func (collector *pageChecksumsCollector) getSummary() PageChecksumsSummary {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	summary := collector.summary
+	if summary.CorruptRelations != nil {
+		summary.CorruptRelations = make(map[string]uint64, len(collector.summary.CorruptRelations))
+		for relation, count := range collector.summary.CorruptRelations {
+			summary.CorruptRelations[relation] = count
+		}
+	}
+	return summary
+}
+
+// getRelationName returns the relation of the paged file,
+// all the segments of the relation have the same name
+func getRelationName(path string) string {
+	relFileNode, err := GetRelFileNodeFrom(path)
+	if err != nil {
+		return path
+	}
+	return fmt.Sprintf("%d/%d/%d", relFileNode.SpcNode, relFileNode.DBNode, relFileNode.RelNode)
+}
+
+func (summary PageChecksumsSummary) log() {
+	if summary.CorruptBlocksCount == 0 {
+		tracelog.InfoLogger.Printf("Page checksums verification: checked %d pages, no corrupt blocks found",
+			summary.CheckedPagesCount)
+		return
+	}
+	tracelog.WarningLogger.Printf("Page checksums verification: checked %d pages, found %d corrupt blocks "+
+		"in %d relations", summary.CheckedPagesCount, summary.CorruptBlocksCount, len(summary.CorruptRelations))
+	relations := make([]string, 0, len(summary.CorruptRelations))
+	for relation := range summary.CorruptRelations {
+		relations = append(relations, relation)
+	}
+	sort.Strings(relations)
+	for _, relation := range relations {
+		tracelog.WarningLogger.Printf("Relation %s: %d corrupt blocks", relation, summary.CorruptRelations[relation])
+	}
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageChecksumsCollector(t *testing.T) {
+	collector := newPageChecksumsCollector()
+	var wg sync.WaitGroup
+	for _, path := range []string{"/base/16384/16385", "/base/16384/16385.1", "/base/16384/16390"} {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			collector.add(path, 100, nil)
+		}(path)
+	}
+	wg.Wait()
+	collector.add("/base/16384/16385.2", 10, []uint32{1, 5})
+	collector.add("/pg_tblspc/16500/PG_14_202107181/16384/16501", 20, []uint32{7})
+	collector.add("/global/1262", 1, []uint32{0})
+
+	assert.Equal(t, PageChecksumsSummary{
+		CheckedPagesCount:  331,
+		CorruptBlocksCount: 4,
+		CorruptRelations: map[string]uint64{
+			"1663/16384/16385":  2,
+			"16500/16384/16501": 1,
+			"/global/1262":      1,
+		},
+	}, collector.getSummary())
+}
+
+func TestPageChecksumsCollector_NoCorruptBlocks(t *testing.T) {
+	collector := newPageChecksumsCollector()
+	collector.add("/base/16384/16385", 100, nil)
+
+	assert.Equal(t, PageChecksumsSummary{CheckedPagesCount: 100}, collector.getSummary())
+}
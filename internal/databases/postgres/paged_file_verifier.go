@@ -8,6 +8,8 @@ import (
 	"unsafe"
 
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/utility"
+	"golang.org/x/sync/errgroup"
 )
 
 // This code is an adaptation of Postgres data page checksum calculation code written in Go.
@@ -24,6 +26,9 @@ const (
 	PdChecksumOffset = 8
 	// page header checksum length (in bytes)
 	PdChecksumLen = 2
+	// number of pages verified by one worker at a time, the work is split by the chunks instead of
+	// the 1 GiB relation segments, so the pages of one segment are verified in parallel too
+	verifyChunkPagesCount = 64
 )
 
 // There is an unsafe pointer logic with PgDatabasePage and PgChecksummablePage.
@@ -173,65 +178,155 @@ func isPageCorrupted(path string, blockNo uint32, page *PgDatabasePage) (bool, e
 	return corrupted, nil
 }
 
-// VerifyPagedFileIncrement verifies pages of an increment
-func VerifyPagedFileIncrement(path string, fileInfo os.FileInfo, increment io.Reader) ([]uint32, error) {
+// VerifyPagedFileIncrement verifies pages of an increment,
+// returns the corrupt block numbers and the number of checked blocks.
+// See verifyPageBlocks for the recheckLsn.
+func VerifyPagedFileIncrement(path string, fileInfo os.FileInfo, increment io.Reader,
+	concurrency int, recheckLsn LSN) ([]uint32, int, error) {
 	_, diffBlockCount, diffMap, err := GetIncrementHeaderFields(increment)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	blockNumbers := make([]uint32, 0, diffBlockCount)
 	for i := uint32(0); i < diffBlockCount; i++ {
 		blockNo := binary.LittleEndian.Uint32(diffMap[i*sizeofInt32 : (i+1)*sizeofInt32])
 		blockNumbers = append(blockNumbers, blockNo)
 	}
-	return verifyPageBlocks(path, fileInfo, increment, blockNumbers)
+	return verifyPageBlocks(path, fileInfo, increment, blockNumbers, concurrency, recheckLsn)
 }
 
-// VerifyPagedFileBase verifies pages of a standard paged file
-func VerifyPagedFileBase(path string, fileInfo os.FileInfo, pagedFile io.Reader) ([]uint32, error) {
+// VerifyPagedFileBase verifies pages of a standard paged file,
+// returns the corrupt block numbers and the number of checked blocks.
+// See verifyPageBlocks for the recheckLsn.
+func VerifyPagedFileBase(path string, fileInfo os.FileInfo, pagedFile io.Reader,
+	concurrency int, recheckLsn LSN) ([]uint32, int, error) {
 	size := fileInfo.Size()
 	filePageCount := uint32(size / DatabasePageSize)
 	blockNumbers := make([]uint32, 0, filePageCount)
 	for i := uint32(0); i < filePageCount; i++ {
 		blockNumbers = append(blockNumbers, i)
 	}
-	return verifyPageBlocks(path, fileInfo, pagedFile, blockNumbers)
+	return verifyPageBlocks(path, fileInfo, pagedFile, blockNumbers, concurrency, recheckLsn)
 }
 
-// verifyPageBlocks verifies provided page blocks from the pagedBlocks reader
-func verifyPageBlocks(path string, fileInfo os.FileInfo, pageBlocks io.Reader,
-	blockNumbers []uint32) (corruptBlockNumbers []uint32, err error) {
+// verifyPageBlocks verifies provided page blocks from the pagedBlocks reader.
+// The pages are read sequentially and verified by the chunks of verifyChunkPagesCount pages in up to concurrency
+// goroutines, the corrupt block numbers are returned in the order of the blockNumbers.
+// If the recheckLsn is set, the corrupt pages are re-read from the file at the path, as pg_basebackup does:
+// the page torn by the concurrent write is either fixed by then or has the LSN not older than the recheckLsn,
+// such pages are skipped, since they are restored from the full page images in WAL.
+func verifyPageBlocks(path string, fileInfo os.FileInfo, pageBlocks io.Reader, blockNumbers []uint32,
+	concurrency int, recheckLsn LSN) (corruptBlockNumbers []uint32, checkedBlocksCount int, err error) {
 	if _, ignored := ignoredFileNames[fileInfo.Name()]; ignored || !isPagedFile(fileInfo, path) {
 		_, err = io.Copy(io.Discard, pageBlocks)
-		return nil, err
+		return nil, 0, err
 	}
-	for _, blockNo := range blockNumbers {
-		corrupted, err := verifySinglePage(path, blockNo, pageBlocks)
-		if err == io.EOF {
-			break
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	corrupted := make([]bool, len(blockNumbers))
+	workers := make(chan struct{}, concurrency)
+	errorGroup := new(errgroup.Group)
+	for checkedBlocksCount < len(blockNumbers) {
+		pages, readErr := readPageChunk(pageBlocks, len(blockNumbers)-checkedBlocksCount)
+		if readErr != nil && readErr != io.EOF {
+			_ = errorGroup.Wait()
+			return nil, 0, readErr
 		}
-		if err != nil {
-			return nil, err
+		chunkOffset := checkedBlocksCount
+		workers <- struct{}{}
+		errorGroup.Go(func() error {
+			defer func() { <-workers }()
+			return verifyPageChunk(path, pages, blockNumbers[chunkOffset:], corrupted[chunkOffset:], recheckLsn)
+		})
+		checkedBlocksCount += len(pages)
+		if readErr == io.EOF {
+			break
 		}
-		if corrupted {
-			corruptBlockNumbers = append(corruptBlockNumbers, blockNo)
+	}
+	if err = errorGroup.Wait(); err != nil {
+		return nil, 0, err
+	}
+	for i := 0; i < checkedBlocksCount; i++ {
+		if corrupted[i] {
+			corruptBlockNumbers = append(corruptBlockNumbers, blockNumbers[i])
 		}
 	}
+
 	// check if some extra delta blocks left in increment
 	if isEmpty := isTarReaderEmpty(pageBlocks); !isEmpty {
 		tracelog.WarningLogger.Printf("verifyPageBlocks: Unexpected extra bytes: %s\n", path)
 	}
 	tracelog.DebugLogger.Printf("verifyPageBlocks: %s, checked %d blocks, found %d corrupt\n",
-		path, len(blockNumbers), len(corruptBlockNumbers))
-	return corruptBlockNumbers, nil
+		path, checkedBlocksCount, len(corruptBlockNumbers))
+	return corruptBlockNumbers, checkedBlocksCount, nil
 }
 
-// verifySinglePage reads and verifies single paged file block
-func verifySinglePage(path string, blockNo uint32, pageBlocks io.Reader) (bool, error) {
+// readPageChunk reads up to verifyChunkPagesCount pages (but no more than maxPagesCount),
+// returns io.EOF with the pages read if the reader has no more full pages
+func readPageChunk(pageBlocks io.Reader, maxPagesCount int) ([]PgDatabasePage, error) {
+	if maxPagesCount > verifyChunkPagesCount {
+		maxPagesCount = verifyChunkPagesCount
+	}
+	pages := make([]PgDatabasePage, 0, maxPagesCount)
+	for len(pages) < maxPagesCount {
+		page := PgDatabasePage{}
+		_, err := io.ReadFull(pageBlocks, page[:])
+		if err != nil {
+			return pages, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// verifyPageChunk verifies the pages and marks the corrupt ones,
+// each chunk writes only to its own part of the corrupted slice
+func verifyPageChunk(path string, pages []PgDatabasePage, blockNumbers []uint32, corrupted []bool,
+	recheckLsn LSN) error {
+	for i := range pages {
+		isCorrupted, err := isPageCorrupted(path, blockNumbers[i], &pages[i])
+		if err != nil {
+			return err
+		}
+		if isCorrupted && recheckLsn != 0 {
+			isCorrupted, err = recheckCorruptPage(path, blockNumbers[i], recheckLsn)
+			if err != nil {
+				return err
+			}
+		}
+		corrupted[i] = isCorrupted
+	}
+	return nil
+}
+
+// recheckCorruptPage re-reads the corrupt page from the file, the page which is written since the recheckLsn
+// or is truncated away is not corrupt
+func recheckCorruptPage(path string, blockNo uint32, recheckLsn LSN) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer utility.LoggedClose(file, "")
 	page := PgDatabasePage{}
-	_, err := io.ReadFull(pageBlocks, page[:])
+	_, err = file.ReadAt(page[:], int64(blockNo)*DatabasePageSize)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	pageHeader, err := parsePostgresPageHeader(bytes.NewReader(page[:]))
 	if err != nil {
 		return false, err
 	}
+	if pageHeader.lsn() >= recheckLsn {
+		tracelog.DebugLogger.Printf("Skipping the page %s/[%d] written since %s\n", path, blockNo, recheckLsn)
+		return false, nil
+	}
 	return isPageCorrupted(path, blockNo, &page)
 }
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newChecksummedPage returns the initialized page with the valid checksum for the block
+func newChecksummedPage(blockNo uint32) PgDatabasePage {
+	page := PgDatabasePage{}
+	binary.LittleEndian.PutUint32(page[4:], blockNo+1) // pd_lsn
+	binary.LittleEndian.PutUint16(page[12:], 24)       // pd_lower
+	binary.LittleEndian.PutUint16(page[14:], 8192)     // pd_upper
+	binary.LittleEndian.PutUint16(page[16:], 8192)     // pd_special
+	binary.LittleEndian.PutUint16(page[18:], 8192+4)   // pd_pagesize_version
+	page[100] = byte(blockNo)
+	checksumPage := page
+	binary.LittleEndian.PutUint16(page[PdChecksumOffset:], pgChecksumPage(blockNo, &checksumPage))
+	return page
+}
+
+func TestVerifyPagedFileBase_Concurrency(t *testing.T) {
+	const pagesCount = 3*verifyChunkPagesCount + 5
+	corruptBlocks := []uint32{0, 3, verifyChunkPagesCount, 2*verifyChunkPagesCount + 1, pagesCount - 1}
+	var content bytes.Buffer
+	for blockNo := uint32(0); blockNo < pagesCount; blockNo++ {
+		page := newChecksummedPage(blockNo)
+		for _, corruptBlockNo := range corruptBlocks {
+			if corruptBlockNo == blockNo {
+				page[200] = 0xff
+			}
+		}
+		content.Write(page[:])
+	}
+	path := filepath.Join(t.TempDir(), DefaultTablespace, "16384", "16385")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, content.Bytes(), 0644))
+	fileInfo, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	for _, concurrency := range []int{1, 2, 8} {
+		corrupt, checkedCount, err := VerifyPagedFileBase(path, fileInfo, bytes.NewReader(content.Bytes()), concurrency, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, pagesCount, checkedCount)
+		assert.Equal(t, corruptBlocks, corrupt)
+	}
+}
+
+func TestVerifyPagedFileBase_TruncatedPage(t *testing.T) {
+	page := newChecksummedPage(0)
+	path := filepath.Join(t.TempDir(), DefaultTablespace, "16384", "16385")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, append(page[:], page[:]...), 0644))
+	fileInfo, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	_, _, err = VerifyPagedFileBase(path, fileInfo, bytes.NewReader(append(page[:], page[:100]...)), 4, 0)
+	assert.Error(t, err)
+}
+
+func TestVerifyPagedFileBase_RecheckRereadsPage(t *testing.T) {
+	// the page is torn in the read stream, but is already written completely to the file
+	page := newChecksummedPage(0)
+	tornPage := page
+	tornPage[200] = 0xff
+	path := filepath.Join(t.TempDir(), DefaultTablespace, "16384", "16385")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, page[:], 0644))
+	fileInfo, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	corrupt, _, err := VerifyPagedFileBase(path, fileInfo, bytes.NewReader(tornPage[:]), 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0}, corrupt)
+
+	corrupt, _, err = VerifyPagedFileBase(path, fileInfo, bytes.NewReader(tornPage[:]), 1, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, corrupt)
+}
@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"golang.org/x/sync/errgroup"
+)
+
+// enableParallelTablespaces makes the composers made by the maker safe to be fed concurrently:
+// they share the files metadata, which is concurrency-safe, and the tar file sets, which are synchronized here
+func enableParallelTablespaces(tarBallComposerMaker TarBallComposerMaker) error {
+	regularMaker, ok := tarBallComposerMaker.(*RegularTarBallComposerMaker)
+	if !ok {
+		return newBackupPushUsageError("the parallel tablespaces are supported only by the regular composer")
+	}
+	if _, ok := regularMaker.tarFileSets.(*internal.RegularTarFileSets); ok {
+		regularMaker.tarFileSets = NewSynchronizedTarFileSets()
+	}
+	return nil
+}
+
+// Walk walks the data directory and packs the walked files. If ParallelTablespaces is set,
+// each tablespace is walked concurrently with the data directory by its own walker feeding its own composer.
+// The walkers share the tarball queue, the files metadata and the tar file sets, so they make a single backup.
+// Only the data directory walker records pg_control.
+func (bundle *Bundle) Walk() error {
+	if !bundle.ParallelTablespaces {
+		return filepath.Walk(bundle.Directory, bundle.HandleWalkedFSObject)
+	}
+	tablespaceLocations, err := bundle.registerTablespaces()
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Walking %d tablespaces in parallel with the data directory", len(tablespaceLocations))
+
+	ctx := bundle.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	errorGroup, walkCtx := errgroup.WithContext(ctx)
+	walkers := make([]*Bundle, 0, len(tablespaceLocations))
+	for _, location := range tablespaceLocations {
+		walker, err := bundle.newTablespaceWalker(walkCtx)
+		if err != nil {
+			return err
+		}
+		walkers = append(walkers, walker)
+		bundle.tablespaceComposers = append(bundle.tablespaceComposers, walker.TarBallComposer)
+
+		location := location
+		errorGroup.Go(func() error {
+			err := filepath.Walk(location, walker.HandleWalkedFSObject)
+			if err != nil {
+				return fmt.Errorf("could not walk tablespace symlink tree error %v", err)
+			}
+			return nil
+		})
+	}
+	errorGroup.Go(func() error {
+		// the data directory walk stops once any of the tablespace walks fails
+		return filepath.Walk(bundle.Directory, func(path string, info os.FileInfo, err error) error {
+			if walkCtx.Err() != nil {
+				return errors.Wrap(walkCtx.Err(), "HandleWalkedFSObject: walk interrupted")
+			}
+			return bundle.HandleWalkedFSObject(path, info, err)
+		})
+	})
+	err = errorGroup.Wait()
+
+	for _, walker := range walkers {
+		bundle.ExcludedPaths = append(bundle.ExcludedPaths, walker.ExcludedPaths...)
+		bundle.SkippedUnloggedFiles = append(bundle.SkippedUnloggedFiles, walker.SkippedUnloggedFiles...)
+	}
+	return err
+}
+
+// registerTablespaces adds the tablespaces linked from pg_tblspc to the TablespaceSpec before the walk,
+// so the paths inside any of them are resolved by the concurrent walkers. Returns the tablespace locations.
+func (bundle *Bundle) registerTablespaces() ([]string, error) {
+	tablespaceFolder := filepath.Join(bundle.Directory, TablespaceFolder)
+	tablespaceEntries, err := os.ReadDir(tablespaceFolder)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read directory structure in %s: %v", TablespaceFolder, err)
+	}
+	var locations []string
+	for _, tablespaceEntry := range tablespaceEntries {
+		if (tablespaceEntry.Type() & os.ModeSymlink) == 0 {
+			continue
+		}
+		symlinkName := tablespaceEntry.Name()
+		actualPath, err := os.Readlink(filepath.Join(tablespaceFolder, symlinkName))
+		if err != nil {
+			return nil, fmt.Errorf("could not read symlink for tablespace %v", err)
+		}
+		bundle.TablespaceSpec.addTablespace(symlinkName, actualPath)
+		locations = append(locations, actualPath)
+	}
+	return locations, nil
+}
+
+// newTablespaceWalker makes the bundle walking a single tablespace, it has its own composer
+// made by the composer maker of the bundle and reads the shared settings of the bundle only
+func (bundle *Bundle) newTablespaceWalker(ctx context.Context) (*Bundle, error) {
+	walker := &Bundle{
+		Bundle: internal.Bundle{
+			Directory:         bundle.Directory,
+			Crypter:           bundle.Crypter,
+			TarSizeThreshold:  bundle.TarSizeThreshold,
+			ExcludedFilenames: bundle.ExcludedFilenames,
+			TarBallQueue:      bundle.TarBallQueue,
+		},
+		IncrementFromLsn:   bundle.IncrementFromLsn,
+		IncrementFromFiles: bundle.IncrementFromFiles,
+		DeltaMap:           bundle.DeltaMap,
+		TablespaceSpec:     bundle.TablespaceSpec,
+		ExcludedRelPaths:   bundle.ExcludedRelPaths,
+		DeltaDetection:     bundle.DeltaDetection,
+		SkipUnlogged:       bundle.SkipUnlogged,
+		FollowedSymlinks:   bundle.FollowedSymlinks,
+		forceIncremental:   bundle.forceIncremental,
+		ctx:                ctx,
+	}
+	err := walker.SetupComposer(bundle.composerMaker)
+	if err != nil {
+		return nil, err
+	}
+	return walker, nil
+}
@@ -0,0 +1,61 @@
+package postgres_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/testtools"
+)
+
+func writeTestFile(t *testing.T, path string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+	require.NoError(t, os.WriteFile(path, []byte(path), 0600))
+}
+
+func TestBundleWalk_ParallelTablespaces(t *testing.T) {
+	data := t.TempDir()
+	writeTestFile(t, filepath.Join(data, "global", postgres.PgControl))
+	writeTestFile(t, filepath.Join(data, "base", "5", "16384"))
+	tablespaceNames := []string{"16400", "16401"}
+	for _, tablespaceName := range tablespaceNames {
+		location := t.TempDir()
+		writeTestFile(t, filepath.Join(location, "PG_15_202209061", "5", "16500"))
+		require.NoError(t, os.MkdirAll(filepath.Join(data, postgres.TablespaceFolder), 0700))
+		require.NoError(t, os.Symlink(location, filepath.Join(data, postgres.TablespaceFolder, tablespaceName)))
+	}
+
+	bundle := postgres.NewBundle(data, nil, nil, nil, false, int64(10))
+	bundle.ParallelTablespaces = true
+	size := int64(0)
+	require.NoError(t, bundle.StartQueue(&testtools.FileTarBallMaker{Out: t.TempDir(), Size: &size}))
+	filePackOptions := postgres.NewTarBallFilePackerOptions(false, false, 1)
+	require.NoError(t, bundle.SetupComposer(postgres.NewRegularTarBallComposerMaker(filePackOptions,
+		&internal.RegularBundleFiles{}, postgres.NewSynchronizedTarFileSets())))
+
+	require.NoError(t, bundle.Walk())
+	tarFileSets, err := bundle.FinishTarComposer()
+	require.NoError(t, err)
+	require.NoError(t, bundle.FinishQueue())
+
+	assert.Equal(t, filepath.Join(data, "global", postgres.PgControl), bundle.Sentinel.Path)
+	assert.ElementsMatch(t, tablespaceNames, bundle.TablespaceSpec.TablespaceNames())
+	packedFiles := make(map[string]bool)
+	for _, files := range tarFileSets.Get() {
+		for _, file := range files {
+			packedFiles[file] = true
+		}
+	}
+	expectedFiles := []string{"/base/5/16384",
+		"/pg_tblspc/16400/PG_15_202209061/5/16500", "/pg_tblspc/16401/PG_15_202209061/5/16500"}
+	for _, file := range expectedFiles {
+		assert.True(t, packedFiles[file], file)
+		_, ok := bundle.GetFiles().Load(file)
+		assert.True(t, ok, file)
+	}
+	assert.False(t, packedFiles["/global/"+postgres.PgControl])
+}
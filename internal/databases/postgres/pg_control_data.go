@@ -15,6 +15,7 @@ const pgControlSize = 8192
 type PgControlData struct {
 	systemIdentifier uint64 // systemIdentifier represents system ID of PG cluster (f.e. [0-8] bytes in pg_control)
 	currentTimeline  uint32 // currentTimeline represents current timeline of PG cluster (f.e. [48-52] bytes in pg_control v. 1100+)
+	checkpointRedo   LSN    // checkpointRedo is the REDO location of the latest checkpoint (f.e. [40-48] bytes in pg_control v. 1100+)
 	// Any data from pg_control
 }
 
@@ -51,10 +52,14 @@ func extractPgControlData(pgControlReader io.Reader) (*PgControlData, error) {
 	systemID := binary.LittleEndian.Uint64(bytes[0:8])
 	pgControlVersion := binary.LittleEndian.Uint32(bytes[8:12])
 	currentTimeline := uint32(0)
+	checkpointRedo := LSN(0)
 
+	// the prevCheckPoint field is removed in 11, so the checkPointCopy structure is shifted
 	if pgControlVersion < 1100 {
+		checkpointRedo = LSN(binary.LittleEndian.Uint64(bytes[48:56]))
 		currentTimeline = binary.LittleEndian.Uint32(bytes[56:60])
 	} else {
+		checkpointRedo = LSN(binary.LittleEndian.Uint64(bytes[40:48]))
 		currentTimeline = binary.LittleEndian.Uint32(bytes[48:52])
 	}
 
@@ -62,6 +67,7 @@ func extractPgControlData(pgControlReader io.Reader) (*PgControlData, error) {
 	return &PgControlData{
 		systemIdentifier: systemID,
 		currentTimeline:  currentTimeline,
+		checkpointRedo:   checkpointRedo,
 	}, nil
 }
 
@@ -72,3 +78,7 @@ func (data *PgControlData) GetSystemIdentifier() uint64 {
 func (data *PgControlData) GetCurrentTimeline() uint32 {
 	return data.currentTimeline
 }
+
+func (data *PgControlData) GetCheckpointRedo() LSN {
+	return data.checkpointRedo
+}
@@ -48,7 +48,11 @@ func fullBackupFetch(folder storage.Folder, stanza string, backupName string,
 
 	fileInterpreter := postgres.NewFileTarInterpreter(destinationDirectory, postgres.BackupSentinelDto{},
 		postgres.FilesMetadataDto{}, getFilesToUnwrap(files), false)
-	return internal.ExtractAll(fileInterpreter, files)
+	err = internal.ExtractAll(fileInterpreter, files)
+	if err != nil {
+		return err
+	}
+	return fileInterpreter.SyncDirectories()
 }
 
 func getFilesToUnwrap(files []internal.ReaderMaker) map[string]bool {
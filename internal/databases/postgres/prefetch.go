@@ -21,21 +21,23 @@ import (
 	"github.com/wal-g/wal-g/utility"
 )
 
+const prefetchPartSuffix = ".part"
+
 // TODO : unit tests
 // HandleWALPrefetch is invoked by wal-fetch command to speed up database restoration
 func HandleWALPrefetch(uploader *WalUploader, walFileName string, location string) {
 	folder := uploader.UploadingFolder.GetSubFolder(utility.WalPath)
-	var fileName = walFileName
 	location = path.Dir(location)
 	waitGroup := &sync.WaitGroup{}
 	concurrency, err := internal.GetMaxDownloadConcurrency()
 	tracelog.ErrorLogger.FatalOnError(err)
 
-	for i := 0; i < concurrency; i++ {
-		fileName, err = GetNextWalFilename(fileName)
-		if err != nil {
-			tracelog.ErrorLogger.Println("WAL-prefetch failed: ", err, " file: ", fileName)
-		}
+	fileNames, err := GetPrefetchWalFilenames(folder, walFileName, concurrency)
+	if err != nil {
+		tracelog.ErrorLogger.Println("WAL-prefetch failed: ", err, " file: ", walFileName)
+		return
+	}
+	for _, fileName := range fileNames {
 		waitGroup.Add(1)
 		go prefetchFile(location, folder, fileName, waitGroup)
 
@@ -72,11 +74,15 @@ func prefaultData(prefaultStartLsn LSN, timelineID uint32, waitGroup *sync.WaitG
 	archiveDirectory := uploader.DeltaFileManager.dataFolder.(*fsutil.DiskDataFolder).Path
 	archiveDirectory = filepath.Dir(archiveDirectory)
 	archiveDirectory = filepath.Dir(archiveDirectory)
-	bundle := NewBundle(archiveDirectory, nil, &prefaultStartLsn, nil,
-		false, viper.GetInt64(internal.TarSizeThresholdSetting))
+	tarSizeThreshold, err := internal.GetTarSizeThreshold()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Error during reading the tar size threshold: '%+v'.", err)
+		return
+	}
+	bundle := NewBundle(archiveDirectory, nil, &prefaultStartLsn, nil, false, tarSizeThreshold)
 	bundle.Timeline = timelineID
 	startLsn := prefaultStartLsn + LSN(WalSegmentSize*WalFileInDelta)
-	err := bundle.DownloadDeltaMap(uploader.UploadingFolder.GetSubFolder(utility.WalPath), startLsn)
+	err = bundle.DownloadDeltaMap(uploader.UploadingFolder.GetSubFolder(utility.WalPath), startLsn)
 	if err != nil {
 		tracelog.ErrorLogger.Printf("Error during loading delta map: '%+v'.", err)
 		return
@@ -208,8 +214,13 @@ func prefetchFile(location string, folder storage.Folder, walFileName string, wa
 	err := os.MkdirAll(runningLocation, 0755)
 	tracelog.ErrorLogger.PrintOnError(err)
 
-	err = internal.DownloadFileTo(folder, walFileName, oldPath)
+	// the part file left by the broken download of the previous prefetch is resumed
+	ok, err := internal.DownloadFileResumably(folder, walFileName, getPrefetchPartPath(oldPath), oldPath)
 	tracelog.ErrorLogger.PrintOnError(err)
+	if !ok {
+		// another process is downloading the file
+		return
+	}
 
 	_, errO = os.Stat(oldPath)
 	_, errN = os.Stat(newPath)
@@ -233,6 +244,11 @@ func getPrefetchLocations(location string,
 	return prefetchLocation, runningLocation, oldPath, newPath
 }
 
+// getPrefetchPartPath returns the path of the file the raw object is downloaded to before it is decompressed
+func getPrefetchPartPath(runningFile string) string {
+	return runningFile + prefetchPartSuffix
+}
+
 // TODO : unit tests
 func forkPrefetch(walFileName string, location string) {
 	concurrency, err := internal.GetMaxDownloadConcurrency()
@@ -248,6 +264,9 @@ func forkPrefetch(walFileName string, location string) {
 	if internal.CfgFile != "" {
 		prefetchArgs = append(prefetchArgs, "--config", internal.CfgFile)
 	}
+	if internal.CfgProfile != "" {
+		prefetchArgs = append(prefetchArgs, "--profile", internal.CfgProfile)
+	}
 	storagePrefix := viper.GetString(internal.StoragePrefixSetting)
 	if storagePrefix != "" {
 		prefetchArgs = append(prefetchArgs, "--walg-storage-prefix", storagePrefix)
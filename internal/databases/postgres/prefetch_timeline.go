@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"sort"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// timelineSwitch is the timeline which follows the fetched one in the history of the newest timeline
+type timelineSwitch struct {
+	timeline   uint32
+	beginSegNo WalSegmentNo
+}
+
+// GetPrefetchWalFilenames returns the names of the count WAL segments following the fetched one.
+// Postgres follows the newest timeline during the recovery (recovery_target_timeline = 'latest'),
+// so the segments starting from the switch point are taken from the timeline which follows
+// the fetched one in the newest .history file found in storage.
+func GetPrefetchWalFilenames(walFolder storage.Folder, walFileName string, count int) ([]string, error) {
+	timeline, logSegNo, err := ParseWALFilename(walFileName)
+	if err != nil {
+		return nil, err
+	}
+	switches, err := getFollowingTimelineSwitches(walFolder, timeline)
+	if err != nil {
+		// the prefetch of the fetched timeline is still useful if the recovery doesn't switch it
+		tracelog.WarningLogger.Printf("WAL-prefetch failed to find the following timelines of %s: %v\n", walFileName, err)
+	}
+
+	walFilenames := make([]string, 0, count)
+	for i := 1; i <= count; i++ {
+		segNo := WalSegmentNo(logSegNo + uint64(i))
+		walFilenames = append(walFilenames, segNo.getFilename(getSwitchedTimeline(segNo, timeline, switches)))
+	}
+	return walFilenames, nil
+}
+
+// getSwitchedTimeline returns the timeline the segment is read from by the recovery started on the timeline,
+// which follows the switches returned by getFollowingTimelineSwitches
+func getSwitchedTimeline(segNo WalSegmentNo, timeline uint32, switches []timelineSwitch) uint32 {
+	segmentTimeline := timeline
+	for _, timelineSwitch := range switches {
+		if segNo >= timelineSwitch.beginSegNo {
+			segmentTimeline = timelineSwitch.timeline
+		}
+	}
+	return segmentTimeline
+}
+
+// getFollowingTimelineSwitches finds the newest timeline as Postgres does, by probing the .history files
+// of the timelines following the given one, and returns the switches of its history made after the given timeline.
+// There are no switches if the given timeline is not an ancestor of the newest one.
+func getFollowingTimelineSwitches(walFolder storage.Folder, timeline uint32) ([]timelineSwitch, error) {
+	newestTimeline := timeline
+	var historyRecords []*TimelineHistoryRecord
+	for {
+		records, err := GetTimeLineHistoryRecords(newestTimeline+1, walFolder)
+		if _, ok := err.(HistoryFileNotFoundError); ok {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		newestTimeline, historyRecords = newestTimeline+1, records
+	}
+
+	sort.Slice(historyRecords, func(i, j int) bool {
+		return historyRecords[i].timeline < historyRecords[j].timeline
+	})
+	switches := make([]timelineSwitch, 0)
+	isAncestor := false
+	for i, record := range historyRecords {
+		isAncestor = isAncestor || record.timeline == timeline
+		if !isAncestor {
+			continue
+		}
+		// the record is the point where its timeline was switched to the next one
+		nextTimeline := newestTimeline
+		if i+1 < len(historyRecords) {
+			nextTimeline = historyRecords[i+1].timeline
+		}
+		switches = append(switches, timelineSwitch{timeline: nextTimeline, beginSegNo: newWalSegmentNo(record.lsn)})
+	}
+	return switches, nil
+}
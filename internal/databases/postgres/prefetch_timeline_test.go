@@ -0,0 +1,62 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func putHistoryFiles(t *testing.T, historyFiles map[uint32]string) storage.Folder {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	for timeline, contents := range historyFiles {
+		name, data, err := newTimelineHistoryFile(contents, timeline)
+		require.NoError(t, err)
+		require.NoError(t, folder.PutObject(name, data))
+	}
+	return folder
+}
+
+func TestGetPrefetchWalFilenames_NoTimelineSwitch(t *testing.T) {
+	folder := putHistoryFiles(t, nil)
+
+	walFilenames, err := postgres.GetPrefetchWalFilenames(folder, "0000000100000000000000FF", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"000000010000000100000000", "000000010000000100000001"}, walFilenames)
+}
+
+func TestGetPrefetchWalFilenames_FollowsNewestTimeline(t *testing.T) {
+	folder := putHistoryFiles(t, map[uint32]string{
+		2: "1\t0/5000000\tno recovery target specified\n",
+		3: "1\t0/5000000\tno recovery target specified\n\n2\t0/6800000\tno recovery target specified\n",
+	})
+
+	walFilenames, err := postgres.GetPrefetchWalFilenames(folder, "000000010000000000000003", 4)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"000000010000000000000004",
+		"000000020000000000000005",
+		// the segment with the switch point is read from the new timeline
+		"000000030000000000000006",
+		"000000030000000000000007",
+	}, walFilenames)
+}
+
+func TestGetPrefetchWalFilenames_NotAncestorOfNewestTimeline(t *testing.T) {
+	folder := putHistoryFiles(t, map[uint32]string{
+		2: "1\t0/5000000\tno recovery target specified\n",
+		3: "1\t0/5000000\tno recovery target specified\n",
+	})
+
+	walFilenames, err := postgres.GetPrefetchWalFilenames(folder, "000000020000000000000006", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"000000020000000000000007"}, walFilenames)
+}
+
+func TestGetPrefetchWalFilenames_NotWalFile(t *testing.T) {
+	_, err := postgres.GetPrefetchWalFilenames(putHistoryFiles(t, nil), "00000002.history", 1)
+	assert.Error(t, err)
+}
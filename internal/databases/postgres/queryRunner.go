@@ -60,11 +60,13 @@ type PgRelationStat struct {
 
 // PgQueryRunner is implementation for controlling PostgreSQL 9.0+
 type PgQueryRunner struct {
-	Connection        *pgx.Conn
-	Version           int
-	SystemIdentifier  *uint64
-	stopBackupTimeout time.Duration
-	mu                sync.Mutex
+	Connection       *pgx.Conn
+	Version          int
+	SystemIdentifier *uint64
+	// SkipWaitForArchive makes pg_stop_backup return without waiting for the backup WAL to be archived
+	SkipWaitForArchive bool
+	stopBackupTimeout  time.Duration
+	mu                 sync.Mutex
 }
 
 type aoRelPgClassInfo struct {
@@ -124,6 +126,8 @@ func (queryRunner *PgQueryRunner) BuildStartBackup() (string, error) {
 // BuildStopBackup formats a query that stops backup according to server features and version
 func (queryRunner *PgQueryRunner) BuildStopBackup() (string, error) {
 	switch {
+	case queryRunner.Version >= 100000 && queryRunner.SkipWaitForArchive:
+		return "SELECT labelfile, spcmapfile, lsn FROM pg_stop_backup(false, false)", nil
 	case queryRunner.Version >= 90600:
 		return "SELECT labelfile, spcmapfile, lsn FROM pg_stop_backup(false)", nil
 	case queryRunner.Version >= 90000:
@@ -137,6 +141,48 @@ func (queryRunner *PgQueryRunner) BuildStopBackup() (string, error) {
 	}
 }
 
+// BuildPauseWalReplay formats a query that pauses the WAL replay of the standby
+func (queryRunner *PgQueryRunner) BuildPauseWalReplay() (string, error) {
+	switch {
+	case queryRunner.Version >= 100000:
+		return "SELECT pg_wal_replay_pause()", nil
+	case queryRunner.Version >= 90100:
+		return "SELECT pg_xlog_replay_pause()", nil
+	case queryRunner.Version == 0:
+		return "", newNoPostgresVersionError()
+	default:
+		return "", newUnsupportedPostgresVersionError(queryRunner.Version)
+	}
+}
+
+// BuildIsWalReplayPauseRequested formats a query that checks if the pause of the WAL replay is requested
+func (queryRunner *PgQueryRunner) BuildIsWalReplayPauseRequested() (string, error) {
+	switch {
+	case queryRunner.Version >= 100000:
+		return "SELECT pg_is_wal_replay_paused()", nil
+	case queryRunner.Version >= 90100:
+		return "SELECT pg_is_xlog_replay_paused()", nil
+	case queryRunner.Version == 0:
+		return "", newNoPostgresVersionError()
+	default:
+		return "", newUnsupportedPostgresVersionError(queryRunner.Version)
+	}
+}
+
+// BuildResumeWalReplay formats a query that resumes the paused WAL replay of the standby
+func (queryRunner *PgQueryRunner) BuildResumeWalReplay() (string, error) {
+	switch {
+	case queryRunner.Version >= 100000:
+		return "SELECT pg_wal_replay_resume()", nil
+	case queryRunner.Version >= 90100:
+		return "SELECT pg_xlog_replay_resume()", nil
+	case queryRunner.Version == 0:
+		return "", newNoPostgresVersionError()
+	default:
+		return "", newUnsupportedPostgresVersionError(queryRunner.Version)
+	}
+}
+
 // NewPgQueryRunner builds QueryRunner from available connection
 func NewPgQueryRunner(conn *pgx.Conn) (*PgQueryRunner, error) {
 	timeout, err := getStopBackupTimeoutSetting()
@@ -333,6 +379,53 @@ func (queryRunner *PgQueryRunner) getStatistics(
 	return relationsStats, nil
 }
 
+// BuildCatalogRelFileNodesQuery formats a query that fetches the current relfilenodes of the system catalogs.
+// pg_relation_filenode resolves the mapped catalogs through pg_filenode.map, so the catalogs
+// rewritten by VACUUM FULL or CLUSTER are reported with their new relfilenodes
+func (queryRunner *PgQueryRunner) BuildCatalogRelFileNodesQuery() (string, error) {
+	switch {
+	case queryRunner.Version >= 90000:
+		return fmt.Sprintf("SELECT pg_relation_filenode(oid) FROM pg_class "+
+			"WHERE oid < %d AND pg_relation_filenode(oid) IS NOT NULL", firstNormalObjectID), nil
+	case queryRunner.Version == 0:
+		return "", newNoPostgresVersionError()
+	default:
+		return "", newUnsupportedPostgresVersionError(queryRunner.Version)
+	}
+}
+
+// getCatalogRelFileNodes fetches the relfilenodes of the system catalogs of the connected database
+func (queryRunner *PgQueryRunner) getCatalogRelFileNodes() ([]uint32, error) {
+	queryRunner.mu.Lock()
+	defer queryRunner.mu.Unlock()
+
+	query, err := queryRunner.BuildCatalogRelFileNodesQuery()
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetCatalogRelFileNodes: Building query failed")
+	}
+
+	rows, err := queryRunner.Connection.Query(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetCatalogRelFileNodes: pg_class query failed")
+	}
+
+	defer rows.Close()
+	relFileNodes := make([]uint32, 0)
+	for rows.Next() {
+		var relFileNode uint32
+		if err := rows.Scan(&relFileNode); err != nil {
+			return nil, errors.Wrap(err, "QueryRunner GetCatalogRelFileNodes: scan failed")
+		}
+		relFileNodes = append(relFileNodes, relFileNode)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return relFileNodes, nil
+}
+
 // BuildGetDatabasesQuery formats a query to get all databases in cluster which are allowed to connect
 func (queryRunner *PgQueryRunner) BuildGetDatabasesQuery() (string, error) {
 	switch {
@@ -583,6 +676,89 @@ func (queryRunner *PgQueryRunner) readTimeline() (timeline uint32, err error) {
 	return
 }
 
+func (queryRunner *PgQueryRunner) isInRecovery() (inRecovery bool, err error) {
+	queryRunner.mu.Lock()
+	defer queryRunner.mu.Unlock()
+
+	err = queryRunner.Connection.QueryRow("SELECT pg_is_in_recovery()").Scan(&inRecovery)
+	return inRecovery, errors.Wrap(err, "IsInRecovery: checking the recovery state failed")
+}
+
+// pauseWalReplay pauses the WAL replay of the standby
+func (queryRunner *PgQueryRunner) pauseWalReplay() error {
+	queryRunner.mu.Lock()
+	defer queryRunner.mu.Unlock()
+
+	tracelog.InfoLogger.Println("Pausing the WAL replay")
+	query, err := queryRunner.BuildPauseWalReplay()
+	if err != nil {
+		return errors.Wrap(err, "QueryRunner PauseWalReplay: Building pause WAL replay query failed")
+	}
+	_, err = queryRunner.Connection.Exec(query)
+	return errors.Wrap(err, "QueryRunner PauseWalReplay: pausing the WAL replay failed")
+}
+
+// isWalReplayPauseRequested checks if the pause of the WAL replay of the standby is already requested,
+// e.g. by the administrator
+func (queryRunner *PgQueryRunner) isWalReplayPauseRequested() (requested bool, err error) {
+	queryRunner.mu.Lock()
+	defer queryRunner.mu.Unlock()
+
+	query, err := queryRunner.BuildIsWalReplayPauseRequested()
+	if err != nil {
+		return false, errors.Wrap(err, "QueryRunner IsWalReplayPauseRequested: Building query failed")
+	}
+	err = queryRunner.Connection.QueryRow(query).Scan(&requested)
+	return requested, errors.Wrap(err, "QueryRunner IsWalReplayPauseRequested: checking the WAL replay pause failed")
+}
+
+// isWalReplayPaused checks if the requested pause of the WAL replay took effect. Before 14 the replay
+// can't be told paused from the pause requested, so the pause is assumed to take effect at once.
+func (queryRunner *PgQueryRunner) isWalReplayPaused() (paused bool, err error) {
+	if queryRunner.Version < 140000 {
+		return true, nil
+	}
+	queryRunner.mu.Lock()
+	defer queryRunner.mu.Unlock()
+
+	var state string
+	err = queryRunner.Connection.QueryRow("SELECT pg_get_wal_replay_pause_state()").Scan(&state)
+	if err != nil {
+		return false, errors.Wrap(err, "QueryRunner IsWalReplayPaused: getting the WAL replay pause state failed")
+	}
+	return state == "paused", nil
+}
+
+// resumeWalReplay resumes the paused WAL replay of the standby
+func (queryRunner *PgQueryRunner) resumeWalReplay() error {
+	queryRunner.mu.Lock()
+	defer queryRunner.mu.Unlock()
+
+	tracelog.InfoLogger.Println("Resuming the WAL replay")
+	query, err := queryRunner.BuildResumeWalReplay()
+	if err != nil {
+		return errors.Wrap(err, "QueryRunner ResumeWalReplay: Building resume WAL replay query failed")
+	}
+	_, err = queryRunner.Connection.Exec(query)
+	return errors.Wrap(err, "QueryRunner ResumeWalReplay: resuming the WAL replay failed")
+}
+
+// terminateBackend terminates the session of the backend, the non-exclusive backup running in it is aborted
+func (queryRunner *PgQueryRunner) terminateBackend(pid uint32) error {
+	queryRunner.mu.Lock()
+	defer queryRunner.mu.Unlock()
+
+	var terminated bool
+	err := queryRunner.Connection.QueryRow("SELECT pg_terminate_backend($1)", int64(pid)).Scan(&terminated)
+	if err != nil {
+		return errors.Wrap(err, "QueryRunner TerminateBackend: terminating the backend failed")
+	}
+	if !terminated {
+		return errors.Errorf("QueryRunner TerminateBackend: backend %d is not found", pid)
+	}
+	return nil
+}
+
 func (queryRunner *PgQueryRunner) Ping() error {
 	queryRunner.mu.Lock()
 	defer queryRunner.mu.Unlock()
@@ -52,4 +52,35 @@ func TestBuildStopBackup(t *testing.T) {
 	queryBuilder.Version = 100000
 	queryString, err = queryBuilder.BuildStopBackup()
 	assert.Equal(t, "SELECT labelfile, spcmapfile, lsn FROM pg_stop_backup(false)", queryString)
+
+	queryBuilder.SkipWaitForArchive = true
+	queryString, err = queryBuilder.BuildStopBackup()
+	assert.Equal(t, "SELECT labelfile, spcmapfile, lsn FROM pg_stop_backup(false, false)", queryString)
+}
+
+// Tests building the pause and resume WAL replay queries
+func TestBuildPauseResumeWalReplay(t *testing.T) {
+	queryBuilder := &postgres.PgQueryRunner{Version: 0}
+	_, err := queryBuilder.BuildPauseWalReplay()
+	assert.IsType(t, postgres.NoPostgresVersionError{}, err)
+
+	queryBuilder.Version = 90000
+	_, err = queryBuilder.BuildResumeWalReplay()
+	assert.IsType(t, postgres.UnsupportedPostgresVersionError{}, err)
+
+	queryBuilder.Version = 90600
+	queryString, err := queryBuilder.BuildPauseWalReplay()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT pg_xlog_replay_pause()", queryString)
+	queryString, err = queryBuilder.BuildResumeWalReplay()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT pg_xlog_replay_resume()", queryString)
+
+	queryBuilder.Version = 140000
+	queryString, err = queryBuilder.BuildPauseWalReplay()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT pg_wal_replay_pause()", queryString)
+	queryString, err = queryBuilder.BuildResumeWalReplay()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT pg_wal_replay_resume()", queryString)
 }
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+// RatingDump is the input and the result of the rating composer written by backup-push --dump-rating:
+// the relations statistics the update ratings are evaluated from and the files packed into each tarball
+type RatingDump struct {
+	RelFileStatistics RelFileStatisticsDto
+	HeadersTarBall    string
+	TarBalls          []RatingDumpTarBall
+}
+
+type RatingDumpTarBall struct {
+	Name         string
+	ExpectedSize uint64
+	Files        []RatingDumpFile
+}
+
+type RatingDumpFile struct {
+	Name          string
+	UpdateRating  uint64
+	UpdatesCount  uint64
+	ExpectedSize  uint64
+	IsIncremented bool
+}
+
+func newRatingDump(fileStats RelFileStatistics, headersTarBallName string) *RatingDump {
+	statsDto := newRelFileStatisticsDto(fileStats)
+	sort.Slice(statsDto.Relations, func(i, j int) bool {
+		left, right := statsDto.Relations[i], statsDto.Relations[j]
+		if left.SpcNode != right.SpcNode {
+			return left.SpcNode < right.SpcNode
+		}
+		if left.DBNode != right.DBNode {
+			return left.DBNode < right.DBNode
+		}
+		return left.RelNode < right.RelNode
+	})
+	return &RatingDump{RelFileStatistics: statsDto, HeadersTarBall: headersTarBallName}
+}
+
+func (dump *RatingDump) addTarBall(tarBallName string, collection *TarFilesCollection) {
+	tarBall := RatingDumpTarBall{
+		Name:         tarBallName,
+		ExpectedSize: collection.expectedSize,
+		Files:        make([]RatingDumpFile, 0, len(collection.files)),
+	}
+	for _, file := range collection.files {
+		tarBall.Files = append(tarBall.Files, RatingDumpFile{
+			Name:          file.Header.Name,
+			UpdateRating:  file.updateRating,
+			UpdatesCount:  file.updatesCount,
+			ExpectedSize:  file.expectedSize,
+			IsIncremented: file.IsIncremented,
+		})
+	}
+	dump.TarBalls = append(dump.TarBalls, tarBall)
+}
+
+// write writes the dump to the local file, the failure is only logged as it doesn't affect the backup
+func (dump *RatingDump) write(path string) {
+	content, err := json.MarshalIndent(dump, "", "    ")
+	if err == nil {
+		err = os.WriteFile(path, content, 0644)
+	}
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to dump the rating composer decisions: %v",
+			errors.Wrapf(err, "failed to write %s", path))
+		return
+	}
+	tracelog.InfoLogger.Printf("Dumped the rating composer decisions for %d tarballs to %s", len(dump.TarBalls), path)
+}
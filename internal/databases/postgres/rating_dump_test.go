@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+)
+
+func TestRatingDump(t *testing.T) {
+	fileStats := RelFileStatistics{
+		{SpcNode: 1663, DBNode: 5, RelNode: 16390}: {updatedTuplesCount: 100},
+		{SpcNode: 1663, DBNode: 5, RelNode: 16384}: {insertedTuplesCount: 7},
+	}
+	newFile := func(name string, updateRating, size uint64) *RatedComposeFileInfo {
+		return &RatedComposeFileInfo{ComposeFileInfo: internal.ComposeFileInfo{Header: &tar.Header{Name: name}},
+			updateRating: updateRating, updatesCount: updateRating, expectedSize: size}
+	}
+	composer := &RatingTarBallComposer{tarSizeThreshold: 100, filesToCompose: []*RatedComposeFileInfo{
+		newFile("base/5/16390", 100, 50), newFile("base/5/16384", 7, 120), newFile("PG_VERSION", 0, 3),
+	}}
+	_, collections := composer.composeFiles()
+
+	dump := newRatingDump(fileStats, "part_1.tar.lz4")
+	for i, collection := range collections {
+		dump.addTarBall(fmt.Sprintf("part_%d.tar.lz4", i+2), collection)
+	}
+	path := filepath.Join(t.TempDir(), "rating.json")
+	dump.write(path)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var written RatingDump
+	require.NoError(t, json.Unmarshal(content, &written))
+	assert.Equal(t, *dump, written)
+
+	require.Len(t, written.RelFileStatistics.Relations, 2)
+	assert.Equal(t, uint32(16384), written.RelFileStatistics.Relations[0].RelNode)
+	assert.Equal(t, "part_1.tar.lz4", written.HeadersTarBall)
+	// the never updated files are packed apart, then the new tarball is started once the threshold is exceeded
+	assert.Equal(t, []RatingDumpTarBall{
+		{Name: "part_2.tar.lz4", ExpectedSize: 3, Files: []RatingDumpFile{{Name: "PG_VERSION", ExpectedSize: 3}}},
+		{Name: "part_3.tar.lz4", ExpectedSize: 120, Files: []RatingDumpFile{
+			{Name: "base/5/16384", UpdateRating: 7, UpdatesCount: 7, ExpectedSize: 120}}},
+		{Name: "part_4.tar.lz4", ExpectedSize: 50, Files: []RatingDumpFile{
+			{Name: "base/5/16390", UpdateRating: 100, UpdatesCount: 100, ExpectedSize: 50}}},
+	}, written.TarBalls)
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const RatingStatsFilePrefix = "rating_stats_"
+
+// RelFileStatisticsDto is the stored RelFileStatistics of the cluster
+// which the rating composer reuses in the following backups
+type RelFileStatisticsDto struct {
+	Relations []RelFileStatDto `json:"Relations"`
+}
+
+type RelFileStatDto struct {
+	SpcNode             uint32 `json:"SpcNode"`
+	DBNode              uint32 `json:"DBNode"`
+	RelNode             uint32 `json:"RelNode"`
+	InsertedTuplesCount uint64 `json:"InsertedTuplesCount"`
+	UpdatedTuplesCount  uint64 `json:"UpdatedTuplesCount"`
+	DeletedTuplesCount  uint64 `json:"DeletedTuplesCount"`
+}
+
+func getRatingStatsPath(systemIdentifier uint64) string {
+	return fmt.Sprintf("%s%d.json", RatingStatsFilePrefix, systemIdentifier)
+}
+
+func newRelFileStatisticsDto(relFileStats RelFileStatistics) RelFileStatisticsDto {
+	dto := RelFileStatisticsDto{Relations: make([]RelFileStatDto, 0, len(relFileStats))}
+	for relFileNode, stat := range relFileStats {
+		dto.Relations = append(dto.Relations, RelFileStatDto{
+			SpcNode:             uint32(relFileNode.SpcNode),
+			DBNode:              uint32(relFileNode.DBNode),
+			RelNode:             uint32(relFileNode.RelNode),
+			InsertedTuplesCount: stat.insertedTuplesCount,
+			UpdatedTuplesCount:  stat.updatedTuplesCount,
+			DeletedTuplesCount:  stat.deletedTuplesCount,
+		})
+	}
+	return dto
+}
+
+func (dto RelFileStatisticsDto) toRelFileStatistics() RelFileStatistics {
+	relFileStats := make(RelFileStatistics, len(dto.Relations))
+	for _, relation := range dto.Relations {
+		relFileNode := walparser.RelFileNode{
+			SpcNode: walparser.Oid(relation.SpcNode),
+			DBNode:  walparser.Oid(relation.DBNode),
+			RelNode: walparser.Oid(relation.RelNode),
+		}
+		relFileStats[relFileNode] = PgRelationStat{
+			insertedTuplesCount: relation.InsertedTuplesCount,
+			updatedTuplesCount:  relation.UpdatedTuplesCount,
+			deletedTuplesCount:  relation.DeletedTuplesCount,
+		}
+	}
+	return relFileStats
+}
+
+// loadRelFileStatistics fetches the statistics stored by the previous backup of the cluster.
+// Missing or broken statistics are not an error, the composer just starts without them.
+func loadRelFileStatistics(folder storage.Folder, systemIdentifier uint64) RelFileStatistics {
+	statsPath := getRatingStatsPath(systemIdentifier)
+	var dto RelFileStatisticsDto
+	err := internal.FetchDto(folder, &dto, statsPath)
+	if err != nil {
+		if _, ok := errors.Cause(err).(storage.ObjectNotFoundError); ok {
+			tracelog.InfoLogger.Printf("No stored rating statistics found at %s", statsPath)
+		} else {
+			tracelog.WarningLogger.Printf("Ignoring the stored rating statistics %s: %v", statsPath, err)
+		}
+		return nil
+	}
+	tracelog.InfoLogger.Printf("Loaded the rating statistics of %d relations from %s", len(dto.Relations), statsPath)
+	return dto.toRelFileStatistics()
+}
+
+// storeRelFileStatistics uploads the statistics for the next backups of the cluster
+func storeRelFileStatistics(folder storage.Folder, systemIdentifier uint64, relFileStats RelFileStatistics) error {
+	return internal.UploadDto(folder, newRelFileStatisticsDto(relFileStats), getRatingStatsPath(systemIdentifier))
+}
+
+// mergeRelFileStatistics overrides the stored statistics with the current ones.
+// The stored statistics of the databases present in the current statistics are dropped,
+// so the relations which were dropped or rewritten since the previous backup are forgotten,
+// while the databases which statistics couldn't be collected keep the stored values.
+func mergeRelFileStatistics(stored, current RelFileStatistics) RelFileStatistics {
+	currentDatabases := make(map[walparser.Oid]bool)
+	for relFileNode := range current {
+		currentDatabases[relFileNode.DBNode] = true
+	}
+
+	merged := make(RelFileStatistics, len(current))
+	for relFileNode, stat := range stored {
+		if !currentDatabases[relFileNode.DBNode] {
+			merged[relFileNode] = stat
+		}
+	}
+	for relFileNode, stat := range current {
+		merged[relFileNode] = stat
+	}
+	return merged
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+const testSystemIdentifier = 7000000000000000001
+
+func TestRelFileStatistics_StoreAndLoad(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	relFileStats := RelFileStatistics{
+		{SpcNode: 1663, DBNode: 16384, RelNode: 16385}: {insertedTuplesCount: 1, updatedTuplesCount: 2, deletedTuplesCount: 3},
+		{SpcNode: 1663, DBNode: 16384, RelNode: 16390}: {insertedTuplesCount: 10},
+	}
+	assert.NoError(t, storeRelFileStatistics(folder, testSystemIdentifier, relFileStats))
+
+	assert.Equal(t, relFileStats, loadRelFileStatistics(folder, testSystemIdentifier))
+	assert.Nil(t, loadRelFileStatistics(folder, testSystemIdentifier+1))
+}
+
+func TestRelFileStatistics_LoadBrokenStats(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	err := folder.PutObject(getRatingStatsPath(testSystemIdentifier), strings.NewReader("{broken"))
+	assert.NoError(t, err)
+
+	assert.Nil(t, loadRelFileStatistics(folder, testSystemIdentifier))
+}
+
+func TestMergeRelFileStatistics(t *testing.T) {
+	stored := RelFileStatistics{
+		{SpcNode: 1663, DBNode: 16384, RelNode: 16385}: {updatedTuplesCount: 1},
+		// dropped since the previous backup
+		{SpcNode: 1663, DBNode: 16384, RelNode: 16390}: {updatedTuplesCount: 2},
+		// the statistics of this database are not collected this time
+		{SpcNode: 1663, DBNode: 16400, RelNode: 16401}: {updatedTuplesCount: 3},
+	}
+	current := RelFileStatistics{
+		{SpcNode: 1663, DBNode: 16384, RelNode: 16385}: {updatedTuplesCount: 5},
+		{SpcNode: 1663, DBNode: 16384, RelNode: 16395}: {updatedTuplesCount: 6},
+	}
+
+	assert.Equal(t, RelFileStatistics{
+		{SpcNode: 1663, DBNode: 16384, RelNode: 16385}: {updatedTuplesCount: 5},
+		{SpcNode: 1663, DBNode: 16384, RelNode: 16395}: {updatedTuplesCount: 6},
+		{SpcNode: 1663, DBNode: 16400, RelNode: 16401}: {updatedTuplesCount: 3},
+	}, mergeRelFileStatistics(stored, current))
+	assert.Equal(t, stored, mergeRelFileStatistics(stored, nil))
+	assert.Equal(t, current, mergeRelFileStatistics(nil, current))
+	assert.Equal(t, RelFileStatistics{}, mergeRelFileStatistics(nil, nil))
+}
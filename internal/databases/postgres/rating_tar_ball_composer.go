@@ -12,6 +12,7 @@ import (
 	"github.com/wal-g/wal-g/internal"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"github.com/wal-g/wal-g/internal/crypto"
 	"golang.org/x/sync/errgroup"
 )
@@ -20,6 +21,7 @@ type RatingTarBallComposerMaker struct {
 	fileStats         RelFileStatistics
 	bundleFiles       internal.BundleFiles
 	filePackerOptions TarBallFilePackerOptions
+	dumpRatingPath    string
 }
 
 func NewRatingTarBallComposerMaker(relFileStats RelFileStatistics,
@@ -29,13 +31,14 @@ func NewRatingTarBallComposerMaker(relFileStats RelFileStatistics,
 		fileStats:         relFileStats,
 		bundleFiles:       bundleFiles,
 		filePackerOptions: filePackerOptions,
+		dumpRatingPath:    viper.GetString(internal.DumpRatingSetting),
 	}, nil
 }
 
 func (maker *RatingTarBallComposerMaker) Make(bundle *Bundle) (internal.TarBallComposer, error) {
 	composeRatingEvaluator := internal.NewDefaultComposeRatingEvaluator(bundle.IncrementFromFiles)
 	filePacker := newTarBallFilePacker(bundle.DeltaMap, bundle.IncrementFromLsn, maker.bundleFiles, maker.filePackerOptions)
-	return NewRatingTarBallComposer(uint64(bundle.TarSizeThreshold),
+	composer, err := NewRatingTarBallComposer(uint64(bundle.TarSizeThreshold),
 		composeRatingEvaluator,
 		bundle.IncrementFromLsn,
 		bundle.DeltaMap,
@@ -44,6 +47,11 @@ func (maker *RatingTarBallComposerMaker) Make(bundle *Bundle) (internal.TarBallC
 		maker.fileStats,
 		maker.bundleFiles,
 		filePacker)
+	if err != nil {
+		return nil, err
+	}
+	composer.dumpRatingPath = maker.dumpRatingPath
+	return composer, nil
 }
 
 type RatedComposeFileInfo struct {
@@ -100,6 +108,9 @@ type RatingTarBallComposer struct {
 
 	errorGroup *errgroup.Group
 	ctx        context.Context
+
+	// dumpRatingPath is the file the packing decisions are written to, set by backup-push --dump-rating
+	dumpRatingPath string
 }
 
 func NewRatingTarBallComposer(
@@ -182,29 +193,41 @@ func (c *RatingTarBallComposer) FinishComposing() (internal.TarFileSets, error)
 
 	tarFileSets := internal.NewRegularTarFileSets()
 	tarFileSets.AddFiles(headersTarName, headersNames)
+	var ratingDump *RatingDump
+	if c.dumpRatingPath != "" {
+		ratingDump = newRatingDump(c.fileStats, headersTarName)
+	}
 
+	var packGroup errgroup.Group
 	for _, tarFilesCollection := range tarFilesCollections {
 		tarBall := c.tarBallQueue.Deque()
 		tarBall.SetUp(c.crypter)
 		for _, composeFileInfo := range tarFilesCollection.files {
 			tarFileSets.AddFile(tarBall.Name(), composeFileInfo.Header.Name)
 		}
+		if ratingDump != nil {
+			ratingDump.addTarBall(tarBall.Name(), tarFilesCollection)
+		}
 		// tarFilesCollection closure
 		tarFilesCollectionLocal := tarFilesCollection
-		go func() {
+		packGroup.Go(func() error {
 			for _, fileInfo := range tarFilesCollectionLocal.files {
 				err := c.tarFilePacker.PackFileIntoTar(&fileInfo.ComposeFileInfo, tarBall)
 				if err != nil {
-					panic(err)
+					return err
 				}
 			}
-			err := c.tarBallQueue.FinishTarBall(tarBall)
-			if err != nil {
-				panic(err)
-			}
-		}()
+			return c.tarBallQueue.FinishTarBall(tarBall)
+		})
 	}
 
+	if ratingDump != nil {
+		ratingDump.write(c.dumpRatingPath)
+	}
+	err = packGroup.Wait()
+	if err != nil {
+		return nil, err
+	}
 	return tarFileSets, nil
 }
 
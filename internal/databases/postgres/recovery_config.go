@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const StandbySignalName = "standby.signal"
+
+// RecoveryConfig is the recovery configuration written to the restored data directory by backup-fetch:
+// the restored cluster fetches the archived WAL with wal-g and replays it up to the recovery target,
+// or up to the end of the archived WAL if there is no target
+type RecoveryConfig struct {
+	targetSetting     string
+	targetDescription string
+	promote           bool
+	standby           bool
+}
+
+// NewRecoveryConfig creates the recovery configuration without the target: promote sets the recovery
+// to promote the cluster once the target is reached, standby starts the restored cluster as a standby
+func NewRecoveryConfig(promote, standby bool) *RecoveryConfig {
+	return &RecoveryConfig{
+		targetDescription: "end of the archived WAL",
+		promote:           promote,
+		standby:           standby,
+	}
+}
+
+func (config *RecoveryConfig) SetTargetTime(targetTime time.Time) {
+	config.setTarget("recovery_target_time", targetTime.Format(time.RFC3339Nano),
+		"time "+targetTime.Format(time.RFC3339Nano))
+}
+
+func (config *RecoveryConfig) SetTargetLsn(targetLsn LSN) {
+	config.setTarget("recovery_target_lsn", targetLsn.String(), "LSN "+targetLsn.String())
+}
+
+func (config *RecoveryConfig) SetTargetName(restorePointName string) {
+	config.setTarget("recovery_target_name", restorePointName, "restore point "+restorePointName)
+}
+
+func (config *RecoveryConfig) setTarget(name, value, description string) {
+	config.targetSetting = name + " = " + quoteConfigValue(value)
+	config.targetDescription = description
+}
+
+// Write configures the restored cluster to recover with the restore command:
+// PostgreSQL 12+ reads the recovery settings from postgresql.auto.conf when recovery.signal
+// or standby.signal exists, the older versions read them from recovery.conf
+func (config *RecoveryConfig) Write(dbDataDirectory, restoreCommand string) error {
+	majorVersion, err := readPgMajorVersion(dbDataDirectory)
+	if err != nil {
+		return err
+	}
+	configName := RecoveryConfName
+	if majorVersion >= 12 {
+		configName = AutoConfName
+	}
+	recoveryKind := "recovery"
+	if config.standby {
+		recoveryKind = "standby recovery"
+	}
+	settings := fmt.Sprintf("# %s to the %s configured by wal-g backup-fetch\n"+
+		"restore_command = %s\n", recoveryKind, config.targetDescription, quoteConfigValue(restoreCommand))
+	if config.targetSetting != "" {
+		settings += config.targetSetting + "\n"
+		// the recovery without the target always promotes at the end of the archived WAL
+		if config.promote {
+			settings += "recovery_target_action = 'promote'\n"
+		}
+	}
+	if config.standby && majorVersion < 12 {
+		settings += "standby_mode = 'on'\n"
+	}
+	err = appendConfigSettings(dbDataDirectory, configName, settings)
+	if err != nil {
+		return err
+	}
+
+	if majorVersion >= 12 {
+		signalName := RecoverySignalName
+		if config.standby {
+			signalName = StandbySignalName
+		}
+		err = os.WriteFile(filepath.Join(dbDataDirectory, signalName), nil, 0600)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create %s", signalName)
+		}
+	}
+	tracelog.InfoLogger.Printf("Configured the %s to %s in %s\n", recoveryKind, config.targetDescription, configName)
+	return nil
+}
+
+// GetPgRecoveryConfigFetcher wraps the fetcher to write the recovery configuration
+// to the restored data directory after the backup is fetched
+func GetPgRecoveryConfigFetcher(fetcher func(rootFolder storage.Folder, backup internal.Backup),
+	dbDataDirectory string, config *RecoveryConfig) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		fetcher(rootFolder, backup)
+		restoreCommand, err := getRestoreCommand()
+		internal.FatalfOnError("Failed to write the recovery configuration: %v\n", err)
+		err = config.Write(utility.ResolveSymlink(dbDataDirectory), restoreCommand)
+		internal.FatalfOnError("Failed to write the recovery configuration: %v\n", err)
+	}
+}
+
+// getRestoreCommand returns the restore_command which fetches the WAL with this wal-g binary and config
+func getRestoreCommand() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find the wal-g executable")
+	}
+	return buildRestoreCommand(executable, viper.ConfigFileUsed(), internal.CfgProfile,
+		internal.GetStorageLocationFlags()), nil
+}
+
+// buildRestoreCommand passes the storage location explicitly, so the WAL of the cluster is found
+// even if the settings of this run came from the environment which PostgreSQL doesn't have
+func buildRestoreCommand(executable, configFile, profile string, storageFlags []internal.ConfigFlag) string {
+	restoreCommand := fmt.Sprintf(`"%s" wal-fetch "%%f" "%%p"`, executable)
+	if configFile != "" {
+		restoreCommand += fmt.Sprintf(` --config "%s"`, configFile)
+	}
+	if profile != "" {
+		restoreCommand += fmt.Sprintf(` --profile "%s"`, profile)
+	}
+	for _, flag := range storageFlags {
+		restoreCommand += fmt.Sprintf(` --%s "%s"`, flag.Name, flag.Value)
+	}
+	return restoreCommand
+}
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+)
+
+const testRestoreCommand = `"/usr/bin/wal-g" wal-fetch "%f" "%p"`
+
+func writeTestRecoveryConfig(t *testing.T, pgVersion string, config *RecoveryConfig) string {
+	dataDirectory := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, "PG_VERSION"), []byte(pgVersion+"\n"), 0600))
+	require.NoError(t, config.Write(dataDirectory, testRestoreCommand))
+	return dataDirectory
+}
+
+func TestRecoveryConfig_TargetTimePromote(t *testing.T) {
+	config := NewRecoveryConfig(true, false)
+	config.SetTargetTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	dataDirectory := writeTestRecoveryConfig(t, "15", config)
+
+	content, err := os.ReadFile(filepath.Join(dataDirectory, AutoConfName))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(content), "restore_command = '\"/usr/bin/wal-g\" wal-fetch \"%f\" \"%p\"'\n"+
+		"recovery_target_time = '2024-01-02T03:04:05Z'\nrecovery_target_action = 'promote'\n"))
+	_, err = os.Stat(filepath.Join(dataDirectory, RecoverySignalName))
+	assert.NoError(t, err)
+}
+
+func TestRecoveryConfig_Standby(t *testing.T) {
+	dataDirectory := writeTestRecoveryConfig(t, "15", NewRecoveryConfig(false, true))
+	content, err := os.ReadFile(filepath.Join(dataDirectory, AutoConfName))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(content), "restore_command = '\"/usr/bin/wal-g\" wal-fetch \"%f\" \"%p\"'\n"))
+	_, err = os.Stat(filepath.Join(dataDirectory, StandbySignalName))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dataDirectory, RecoverySignalName))
+	assert.True(t, os.IsNotExist(err))
+
+	dataDirectory = writeTestRecoveryConfig(t, "11", NewRecoveryConfig(false, true))
+	content, err = os.ReadFile(filepath.Join(dataDirectory, RecoveryConfName))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(content), "standby_mode = 'on'\n"))
+	_, err = os.Stat(filepath.Join(dataDirectory, StandbySignalName))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRecoveryConfig_NoTarget(t *testing.T) {
+	// the recovery promotes at the end of the archived WAL anyway
+	dataDirectory := writeTestRecoveryConfig(t, "14", NewRecoveryConfig(true, false))
+	content, err := os.ReadFile(filepath.Join(dataDirectory, AutoConfName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "recovery_target")
+	assert.Contains(t, string(content), "# recovery to the end of the archived WAL configured by wal-g backup-fetch\n")
+}
+
+func TestBuildRestoreCommand(t *testing.T) {
+	assert.Equal(t, testRestoreCommand, buildRestoreCommand("/usr/bin/wal-g", "", "", nil))
+	assert.Equal(t, `"/usr/bin/wal-g" wal-fetch "%f" "%p" --config "/etc/wal-g.json" --profile "replica" `+
+		`--walg-s3-prefix "s3://bucket/cluster" --walg-storage-prefix "shard1"`,
+		buildRestoreCommand("/usr/bin/wal-g", "/etc/wal-g.json", "replica", []internal.ConfigFlag{
+			{Name: "walg-s3-prefix", Value: "s3://bucket/cluster"},
+			{Name: "walg-storage-prefix", Value: "shard1"},
+		}))
+}
@@ -3,8 +3,11 @@ package postgres
 import (
 	"archive/tar"
 	"context"
+	"crypto/sha256"
+	"hash"
 	"os"
 
+	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 
 	"github.com/wal-g/wal-g/internal/crypto"
@@ -19,6 +22,11 @@ type RegularTarBallComposer struct {
 	tarFileSets   internal.TarFileSets
 	errorGroup    *errgroup.Group
 	ctx           context.Context
+	// dedup is set only if the identical small files are deduplicated
+	dedup *smallFilesDedup
+	// splitFileSize is the size of the parts of the split large files, zero means that the files are not split
+	splitFileSize int64
+	splitCount    int
 }
 
 func NewRegularTarBallComposer(
@@ -44,6 +52,10 @@ type RegularTarBallComposerMaker struct {
 	filePackerOptions TarBallFilePackerOptions
 	files             internal.BundleFiles
 	tarFileSets       internal.TarFileSets
+	// dedupMaxFileSize is the size of the largest deduplicated file, zero means that the files are not deduplicated
+	dedupMaxFileSize int64
+	// splitFileSize is the size of the parts of the split large files, zero means that the files are not split
+	splitFileSize int64
 }
 
 func NewRegularTarBallComposerMaker(
@@ -61,10 +73,32 @@ func (maker *RegularTarBallComposerMaker) Make(bundle *Bundle) (internal.TarBall
 	tarFileSets := maker.tarFileSets
 	tarBallFilePacker := newTarBallFilePacker(bundle.DeltaMap,
 		bundle.IncrementFromLsn, bundleFiles, maker.filePackerOptions)
-	return NewRegularTarBallComposer(bundle.TarBallQueue, tarBallFilePacker, bundleFiles, tarFileSets, bundle.Crypter), nil
+	composer := NewRegularTarBallComposer(bundle.TarBallQueue, tarBallFilePacker, bundleFiles, tarFileSets, bundle.Crypter)
+	if maker.dedupMaxFileSize > 0 {
+		composer.dedup = newSmallFilesDedup(maker.dedupMaxFileSize)
+	}
+	composer.splitFileSize = maker.splitFileSize
+	return composer, nil
 }
 
 func (c *RegularTarBallComposer) AddFile(info *internal.ComposeFileInfo) {
+	var dedupSource *dedupSource
+	if c.dedup != nil {
+		var deduplicated bool
+		deduplicated, dedupSource = c.dedup.deduplicate(info)
+		if deduplicated {
+			return
+		}
+	}
+	if shouldSplitFile(info, c.splitFileSize) {
+		c.addSplitFile(info)
+		return
+	}
+	c.packFile(info, dedupSource)
+}
+
+// packFile packs the file into the tarball, the bytes packed for the source of the deduplicated files are hashed
+func (c *RegularTarBallComposer) packFile(info *internal.ComposeFileInfo, dedupSource *dedupSource) {
 	tarBall, err := c.tarBallQueue.DequeCtx(c.ctx)
 	if err != nil {
 		return
@@ -72,10 +106,17 @@ func (c *RegularTarBallComposer) AddFile(info *internal.ComposeFileInfo) {
 	tarBall.SetUp(c.crypter)
 	c.tarFileSets.AddFile(tarBall.Name(), info.Header.Name)
 	c.errorGroup.Go(func() error {
-		err := c.tarFilePacker.PackFileIntoTar(info, tarBall)
+		var packedHasher hash.Hash
+		if dedupSource != nil {
+			packedHasher = sha256.New()
+		}
+		packed, err := c.tarFilePacker.packFileIntoTar(info, tarBall, packedHasher)
 		if err != nil {
 			return err
 		}
+		if packed && dedupSource != nil {
+			dedupSource.setPacked(packedHasher)
+		}
 		return c.tarBallQueue.CheckSizeAndEnqueueBack(tarBall)
 	})
 }
@@ -101,6 +142,19 @@ func (c *RegularTarBallComposer) FinishComposing() (internal.TarFileSets, error)
 	if err != nil {
 		return nil, err
 	}
+	if c.dedup != nil {
+		for _, info := range c.dedup.recordCopies(c.files) {
+			c.packFile(info, nil)
+		}
+		err = c.errorGroup.Wait()
+		if err != nil {
+			return nil, err
+		}
+		tracelog.InfoLogger.Printf("Deduplicated %d identical small files", c.dedup.dedupCount)
+	}
+	if c.splitCount > 0 {
+		tracelog.InfoLogger.Printf("Split %d large files into the parts", c.splitCount)
+	}
 	return c.tarFileSets, nil
 }
 
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// pgVersionOutputRegexp matches the output of postgres --version, e.g. "postgres (PostgreSQL) 14.5 (Debian 14.5-1)"
+var pgVersionOutputRegexp = regexp.MustCompile(`\(PostgreSQL\) (\d+)(?:\.(\d+))?`)
+
+// GetPgRestoreChecksFetcher wraps the fetcher to warn about the backups the restored cluster may fail to start
+// or to recover from: the major version of the local postgres binary differs from the one of the backup
+// or the archiving of the backup WAL was not verified
+func GetPgRestoreChecksFetcher(fetcher func(rootFolder storage.Folder, backup internal.Backup),
+) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		pgBackup := ToPgBackup(backup)
+		sentinelDto, err := pgBackup.GetSentinel()
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		warnOnPgMajorVersionMismatch(pgBackup.Name, sentinelDto)
+		if sentinelDto.WalUnverified {
+			tracelog.WarningLogger.Printf("The backup %s is taken without verifying that its WAL is archived, "+
+				"the recovery and the point-in-time recovery from it may be impossible\n", pgBackup.Name)
+		}
+
+		fetcher(rootFolder, backup)
+	}
+}
+
+func warnOnPgMajorVersionMismatch(backupName string, sentinelDto BackupSentinelDto) {
+	// the version is not recorded by the very old versions
+	if sentinelDto.PgVersion == 0 {
+		return
+	}
+	backupVersion := formatPgMajorVersion(sentinelDto.PgVersion)
+	localVersion, err := getLocalPgMajorVersion()
+	if err != nil {
+		tracelog.InfoLogger.Printf("Unable to check that the local PostgreSQL version matches the backup one: %v\n", err)
+		return
+	}
+	if localVersion == backupVersion {
+		return
+	}
+	tracelog.WarningLogger.Printf("!!! The backup %s is taken from PostgreSQL %s, but the local postgres binary is PostgreSQL %s !!!\n",
+		backupName, backupVersion, localVersion)
+	tracelog.WarningLogger.Printf("!!! The restored cluster won't start unless PostgreSQL %s is used !!!\n", backupVersion)
+}
+
+// formatPgMajorVersion returns the major version of the server_version_num, e.g. 14 for 140005 and 9.6 for 90624
+func formatPgMajorVersion(versionNum int) string {
+	if versionNum >= 100000 {
+		return strconv.Itoa(versionNum / 10000)
+	}
+	return fmt.Sprintf("%d.%d", versionNum/10000, versionNum/100%100)
+}
+
+// parsePgMajorVersion returns the major version from the output of postgres --version
+func parsePgMajorVersion(versionOutput string) (string, error) {
+	match := pgVersionOutputRegexp.FindStringSubmatch(versionOutput)
+	if match == nil {
+		return "", errors.Errorf("unexpected postgres --version output: %q", versionOutput)
+	}
+	major, err := strconv.Atoi(match[1])
+	if err != nil {
+		return "", err
+	}
+	if major >= 10 || match[2] == "" {
+		return match[1], nil
+	}
+	return match[1] + "." + match[2], nil
+}
+
+func getLocalPgMajorVersion() (string, error) {
+	postgresPath, err := exec.LookPath("postgres")
+	if err != nil {
+		return "", errors.Wrap(err, "postgres binary is not found in PATH")
+	}
+	output, err := exec.Command(postgresPath, "--version").Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %s --version", postgresPath)
+	}
+	return parsePgMajorVersion(string(output))
+}
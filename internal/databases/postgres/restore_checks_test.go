@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatPgMajorVersion(t *testing.T) {
+	assert.Equal(t, "14", formatPgMajorVersion(140005))
+	assert.Equal(t, "10", formatPgMajorVersion(100023))
+	assert.Equal(t, "9.6", formatPgMajorVersion(90624))
+}
+
+func TestParsePgMajorVersion(t *testing.T) {
+	for output, expected := range map[string]string{
+		"postgres (PostgreSQL) 14.5 (Debian 14.5-1.pgdg110+1)\n": "14",
+		"postgres (PostgreSQL) 16beta1\n":                        "16",
+		"postgres (PostgreSQL) 9.6.24\n":                         "9.6",
+	} {
+		version, err := parsePgMajorVersion(output)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, version, output)
+	}
+
+	_, err := parsePgMajorVersion("pg_ctl: command not found")
+	assert.Error(t, err)
+}
@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type InsufficientDiskSpaceError struct {
+	error
+}
+
+func newInsufficientDiskSpaceError(shortages []string) InsufficientDiskSpaceError {
+	return InsufficientDiskSpaceError{errors.Errorf("not enough disk space to restore the backup: %s",
+		strings.Join(shortages, "; "))}
+}
+
+func (err InsufficientDiskSpaceError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// filesystemRequirement is the space required by the restored files on one filesystem
+type filesystemRequirement struct {
+	paths         []string
+	requiredBytes uint64
+	space         utility.FilesystemSpace
+}
+
+// checkRestoreDiskSpace checks that the restored files fit into the free space of the filesystems
+// of the data directory and the tablespace locations before anything is extracted
+func checkRestoreDiskSpace(backup Backup, dbDataDirectory string, spec *TablespaceSpec,
+	filesToUnwrap map[string]bool, restoreFilter *RestoreFilter) error {
+	sentinelDto, filesMetaDto, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		return err
+	}
+	spec = chooseTablespaceSpecification(sentinelDto.TablespaceSpec, spec)
+	requiredSizes, ok := estimateRestoreSizes(dbDataDirectory, sentinelDto, filesMetaDto, spec,
+		filesToUnwrap, restoreFilter)
+	if !ok {
+		return errors.Errorf("the size of the restored files is unknown, "+
+			"the files metadata of %s has no file sizes", backup.Name)
+	}
+	return checkFilesystemsSpace(requiredSizes, utility.GetFilesystemSpace)
+}
+
+// precheckRestoreDiskSpace fails the restore which doesn't fit into the free disk space,
+// the restore proceeds if the space can't be checked
+func precheckRestoreDiskSpace(backup Backup, dbDataDirectory string, spec *TablespaceSpec,
+	filesToUnwrap map[string]bool, restoreFilter *RestoreFilter) {
+	err := checkRestoreDiskSpace(backup, dbDataDirectory, spec, filesToUnwrap, restoreFilter)
+	if _, ok := err.(InsufficientDiskSpaceError); ok {
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+	}
+	if err != nil {
+		tracelog.WarningLogger.Printf("Skipping the disk space check: %v\n", err)
+	}
+}
+
+// estimateRestoreSizes returns the bytes taken by the restored files in the data directory
+// and in every tablespace location. The files which are not unwrapped and the relation files
+// filtered out by the restore filter (created sparse) take no space.
+// The file sizes are taken from the files metadata or from the tar manifest of the minimal files metadata.
+// The backups without the file sizes are estimated by their uncompressed size
+// if they are restored entirely, otherwise the size is unknown.
+func estimateRestoreSizes(dbDataDirectory string, sentinelDto BackupSentinelDto, filesMetaDto FilesMetadataDto,
+	spec *TablespaceSpec, filesToUnwrap map[string]bool, restoreFilter *RestoreFilter) (map[string]uint64, bool) {
+	fileSizes := filesMetaDto.getFileSizes()
+	hasSizes := false
+	for _, size := range fileSizes {
+		if size > 0 {
+			hasSizes = true
+			break
+		}
+	}
+	if !hasSizes {
+		restoredEntirely := restoreFilter == nil && len(filesToUnwrap) >= len(fileSizes)
+		if sentinelDto.IsIncremental() || !spec.empty() || !restoredEntirely || sentinelDto.UncompressedSize <= 0 {
+			return nil, false
+		}
+		return map[string]uint64{dbDataDirectory: uint64(sentinelDto.UncompressedSize)}, true
+	}
+
+	requiredSizes := map[string]uint64{dbDataDirectory: 0}
+	for fileName, size := range fileSizes {
+		if !filesToUnwrap[fileName] || !restoreFilter.ShouldRestoreData(fileName) {
+			continue
+		}
+		location := dbDataDirectory
+		// the tablespace files are named /pg_tblspc/<oid>/...
+		parts := strings.SplitN(strings.TrimPrefix(fileName, "/"), "/", 3)
+		if len(parts) == 3 && parts[0] == TablespaceFolder {
+			if tablespaceLocation, ok := spec.location(parts[1]); ok {
+				location = tablespaceLocation.Location
+			}
+		}
+		requiredSizes[location] += uint64(size)
+	}
+	return requiredSizes, true
+}
+
+// checkFilesystemsSpace sums the required sizes of the paths on the same filesystem
+// and compares them with the space available there
+func checkFilesystemsSpace(requiredSizes map[string]uint64,
+	getFilesystemSpace func(path string) (utility.FilesystemSpace, error)) error {
+	paths := make([]string, 0, len(requiredSizes))
+	for path := range requiredSizes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var requirements []*filesystemRequirement
+	requirementsByDevice := make(map[uint64]*filesystemRequirement)
+	for _, path := range paths {
+		space, err := getFilesystemSpace(path)
+		if err != nil {
+			return err
+		}
+		requirement, ok := requirementsByDevice[space.DeviceID]
+		if !ok {
+			requirement = &filesystemRequirement{space: space}
+			requirementsByDevice[space.DeviceID] = requirement
+			requirements = append(requirements, requirement)
+		}
+		requirement.paths = append(requirement.paths, path)
+		requirement.requiredBytes += requiredSizes[path]
+	}
+
+	var shortages []string
+	for _, requirement := range requirements {
+		paths := strings.Join(requirement.paths, ", ")
+		tracelog.InfoLogger.Printf("The restore requires %s at %s, %s available\n",
+			utility.FormatSize(float64(requirement.requiredBytes)), paths,
+			utility.FormatSize(float64(requirement.space.AvailableBytes)))
+		if requirement.requiredBytes > requirement.space.AvailableBytes {
+			shortages = append(shortages, fmt.Sprintf("%s required at %s, %s available, %s short",
+				utility.FormatSize(float64(requirement.requiredBytes)), paths,
+				utility.FormatSize(float64(requirement.space.AvailableBytes)),
+				utility.FormatSize(float64(requirement.requiredBytes-requirement.space.AvailableBytes))))
+		}
+	}
+	if len(shortages) > 0 {
+		return newInsufficientDiskSpaceError(shortages)
+	}
+	return nil
+}
+
+// GetPgValidateOnlyFetcher returns the fetcher which only checks that the backup fits
+// into the free disk space of the destination without extracting anything
+func GetPgValidateOnlyFetcher(dbDataDirectory, fileMask, restoreSpecPath string, tablespaceMapping TablespaceMapping,
+	flattenTablespaces bool, restoreOnly []string,
+	skipRelFileNodes []uint32, catalogsOnly bool) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		pgBackup := ToPgBackup(backup)
+		filesToUnwrap, err := pgBackup.GetFilesToUnwrap(fileMask)
+		internal.FatalfOnError("Failed to validate backup: %v\n", err)
+		restoreFilter, err := newBackupRestoreFilter(pgBackup, restoreOnly, skipRelFileNodes, catalogsOnly)
+		internal.FatalfOnError("Failed to validate backup: %v\n", err)
+		spec, err := getRestoreTablespaceSpec(pgBackup, restoreSpecPath, tablespaceMapping, flattenTablespaces)
+		internal.FatalfOnError("Failed to validate backup: %v\n", err)
+
+		err = checkRestoreDiskSpace(pgBackup, utility.ResolveSymlink(dbDataDirectory), spec, filesToUnwrap, restoreFilter)
+		internal.FatalfOnError("Failed to validate backup: %v\n", err)
+		tracelog.InfoLogger.Printf("Backup %s fits into the free disk space\n", pgBackup.Name)
+	}
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func TestEstimateRestoreSizes(t *testing.T) {
+	spec := NewTablespaceSpec("/pgdata")
+	spec.addTablespace("16400", "/mnt/tablespace")
+	filesMetaDto := FilesMetadataDto{Files: internal.BackupFileList{
+		"/base/1/1259":                        {Size: 8192},
+		"/base/16384/16385":                   {Size: 1 << 20},
+		"/global/pg_control":                  {Size: 8192},
+		"/pg_tblspc/16400/PG_14_1/16384/2619": {Size: 2 << 20},
+		"/postgresql.conf":                    {Size: 100},
+	}}
+	filesToUnwrap := make(map[string]bool)
+	for fileName := range filesMetaDto.Files {
+		filesToUnwrap[fileName] = fileName != "/postgresql.conf"
+	}
+	restoreFilter, err := NewRestoreFilter(nil, nil, []uint32{16385})
+	assert.NoError(t, err)
+
+	requiredSizes, ok := estimateRestoreSizes("/pgdata", BackupSentinelDto{}, filesMetaDto, &spec,
+		filesToUnwrap, restoreFilter)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]uint64{"/pgdata": 2 * 8192, "/mnt/tablespace": 2 << 20}, requiredSizes)
+}
+
+func TestEstimateRestoreSizes_WithoutFileSizes(t *testing.T) {
+	spec := NewTablespaceSpec("")
+	sentinelDto := BackupSentinelDto{UncompressedSize: 1 << 30}
+	filesMetaDto := FilesMetadataDto{Files: internal.BackupFileList{"/base/1/1259": {}}}
+
+	requiredSizes, ok := estimateRestoreSizes("/pgdata", sentinelDto, filesMetaDto, &spec,
+		map[string]bool{"/base/1/1259": true}, nil)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]uint64{"/pgdata": 1 << 30}, requiredSizes)
+
+	// the uncompressed size of the partially restored backup overestimates the required space
+	restoreFilter, err := NewRestoreFilter(nil, nil, []uint32{16385})
+	assert.NoError(t, err)
+	_, ok = estimateRestoreSizes("/pgdata", sentinelDto, filesMetaDto, &spec,
+		map[string]bool{"/base/1/1259": true}, restoreFilter)
+	assert.False(t, ok)
+}
+
+func TestCheckFilesystemsSpace(t *testing.T) {
+	spaces := map[string]utility.FilesystemSpace{
+		"/pgdata":          {DeviceID: 1, AvailableBytes: 100},
+		"/pgdata/tblspc":   {DeviceID: 1, AvailableBytes: 100},
+		"/mnt/tablespace":  {DeviceID: 2, AvailableBytes: 1000},
+		"/mnt/tablespace2": {DeviceID: 2, AvailableBytes: 1000},
+	}
+	getFilesystemSpace := func(path string) (utility.FilesystemSpace, error) {
+		return spaces[path], nil
+	}
+
+	err := checkFilesystemsSpace(map[string]uint64{"/pgdata": 60, "/mnt/tablespace": 900}, getFilesystemSpace)
+	assert.NoError(t, err)
+
+	// the paths on the same filesystem share its available space
+	err = checkFilesystemsSpace(map[string]uint64{"/pgdata": 60, "/pgdata/tblspc": 60}, getFilesystemSpace)
+	assert.IsType(t, InsufficientDiskSpaceError{}, err)
+	assert.Contains(t, err.Error(), "120 B required at /pgdata, /pgdata/tblspc, 100 B available, 20 B short")
+
+	err = checkFilesystemsSpace(map[string]uint64{"/mnt/tablespace": 600, "/mnt/tablespace2": 600}, getFilesystemSpace)
+	assert.IsType(t, InsufficientDiskSpaceError{}, err)
+	assert.Contains(t, err.Error(), "200 B short")
+}
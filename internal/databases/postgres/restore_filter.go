@@ -0,0 +1,198 @@
+package postgres
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+// systemDatabaseNames are the databases which are always restored
+// even if they were not requested, the cluster is not usable without them
+var systemDatabaseNames = map[string]bool{
+	"template1": true,
+	"postgres":  true,
+}
+
+// relFileRegexp matches the names of the relation files including
+// all the forks and segments: 16384, 16384.1, 16384_fsm, 16384_vm.2 etc.
+var relFileRegexp = regexp.MustCompile(`^(\d+)(_fsm|_vm|_init)?([.]\d+)?$`)
+
+// firstNormalObjectID is the first OID assigned to the user objects,
+// the relation files below it belong to the system catalogs
+const firstNormalObjectID = 16384
+
+type DatabaseNotFoundError struct {
+	error
+}
+
+func newDatabaseNotFoundError(name string) DatabaseNotFoundError {
+	return DatabaseNotFoundError{errors.Errorf("database '%s' is not found in the backup files metadata", name)}
+}
+
+func (err DatabaseNotFoundError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+type InvalidSkippedRelFileNodeError struct {
+	error
+}
+
+func newInvalidSkippedRelFileNodeError(relFileNode uint32) InvalidSkippedRelFileNodeError {
+	return InvalidSkippedRelFileNodeError{errors.Errorf(
+		"relfilenode %d belongs to the system catalogs and can't be skipped", relFileNode)}
+}
+
+func (err InvalidSkippedRelFileNodeError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// DatabasesByNames maps the database names to their OIDs
+type DatabasesByNames map[string]uint32
+
+func NewDatabasesByNames(databases []PgDatabaseInfo) DatabasesByNames {
+	databasesByNames := make(DatabasesByNames, len(databases))
+	for _, db := range databases {
+		databasesByNames[db.name] = uint32(db.oid)
+	}
+	return databasesByNames
+}
+
+// CatalogRelFileNodes maps the database OIDs to the relfilenodes of their system catalogs
+// recorded at the backup time, including the catalogs rewritten by VACUUM FULL or CLUSTER
+type CatalogRelFileNodes map[uint32][]uint32
+
+// RestoreFilter decides which backup files should be restored with their contents.
+// Relation files of the databases that were not requested and of the skipped relations
+// are created as sparse zero-filled files, so the WAL replay still finds every file it expects.
+// A nil RestoreFilter restores everything.
+type RestoreFilter struct {
+	skippedDatabases    map[uint32]bool
+	skippedRelFileNodes map[uint32]bool
+	// catalogsOnly skips the data of all the relations of the user objects
+	catalogsOnly bool
+	// catalogRelFileNodes are the relfilenodes of the system catalogs by the database OIDs
+	catalogRelFileNodes map[uint32]map[uint32]bool
+}
+
+// NewRestoreFilter builds the filter which restores only the requested databases
+// and skips the data of the specified relfilenodes in every database.
+// The global tablespace, the system databases and the databases unknown
+// to the files metadata (e.g. template0) are always restored.
+func NewRestoreFilter(databases DatabasesByNames, restoreOnly []string,
+	skipRelFileNodes []uint32) (*RestoreFilter, error) {
+	if len(restoreOnly) == 0 && len(skipRelFileNodes) == 0 {
+		return nil, nil
+	}
+	skippedRelFileNodes := make(map[uint32]bool, len(skipRelFileNodes))
+	for _, relFileNode := range skipRelFileNodes {
+		if relFileNode < firstNormalObjectID {
+			return nil, newInvalidSkippedRelFileNodeError(relFileNode)
+		}
+		skippedRelFileNodes[relFileNode] = true
+	}
+	if len(restoreOnly) == 0 {
+		return &RestoreFilter{skippedRelFileNodes: skippedRelFileNodes}, nil
+	}
+
+	requested := make(map[string]bool, len(restoreOnly))
+	for _, name := range restoreOnly {
+		if _, ok := databases[name]; !ok {
+			return nil, newDatabaseNotFoundError(name)
+		}
+		requested[name] = true
+	}
+
+	skippedDatabases := make(map[uint32]bool)
+	for name, oid := range databases {
+		if !requested[name] && !systemDatabaseNames[name] {
+			skippedDatabases[oid] = true
+		}
+	}
+	return &RestoreFilter{skippedDatabases: skippedDatabases, skippedRelFileNodes: skippedRelFileNodes}, nil
+}
+
+// NewCatalogsOnlyRestoreFilter builds the filter which restores only the system catalogs:
+// the global tablespace and the relation files of the catalogs recorded at the backup time
+// in every database. The databases without the recorded catalogs (e.g. template0 or the backups
+// of the older WAL-G versions) fall back to the relfilenodes below the first user OID.
+// The relation files of the user objects are created empty.
+func NewCatalogsOnlyRestoreFilter(catalogs CatalogRelFileNodes) *RestoreFilter {
+	catalogRelFileNodes := make(map[uint32]map[uint32]bool, len(catalogs))
+	for dbOid, relFileNodes := range catalogs {
+		catalogRelFileNodes[dbOid] = make(map[uint32]bool, len(relFileNodes))
+		for _, relFileNode := range relFileNodes {
+			catalogRelFileNodes[dbOid][relFileNode] = true
+		}
+	}
+	return &RestoreFilter{catalogsOnly: true, catalogRelFileNodes: catalogRelFileNodes}
+}
+
+// writeCatalogsOnlyConfig keeps the cluster restored by the catalogs-only filter from touching
+// the empty relation files of the user objects: the transactions are read-only by default
+// and the autovacuum is disabled, which is enough to run pg_dump --schema-only
+func writeCatalogsOnlyConfig(dbDataDirectory string) error {
+	settings := "# catalogs-only restore configured by wal-g backup-fetch, the user relations are empty\n" +
+		"default_transaction_read_only = on\nautovacuum = off\n"
+	err := appendConfigSettings(dbDataDirectory, AutoConfName, settings)
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Configured the read-only access to the restored catalogs in %s\n", AutoConfName)
+	return nil
+}
+
+// ShouldRestoreData checks if the contents of the backup file should be restored
+func (filter *RestoreFilter) ShouldRestoreData(fileName string) bool {
+	if filter == nil {
+		return true
+	}
+	dbOid, relFileNode, ok := parseRelFilePath(fileName)
+	if !ok {
+		return true
+	}
+	if filter.catalogsOnly {
+		return filter.isCatalog(dbOid, relFileNode)
+	}
+	return !filter.skippedDatabases[dbOid] && !filter.skippedRelFileNodes[relFileNode]
+}
+
+func (filter *RestoreFilter) isCatalog(dbOid, relFileNode uint32) bool {
+	if catalogs, ok := filter.catalogRelFileNodes[dbOid]; ok {
+		return catalogs[relFileNode]
+	}
+	return relFileNode < firstNormalObjectID
+}
+
+// parseRelFilePath extracts the database OID and the relfilenode from the relation file name
+// of the form /base/<db>/<relfile> or /pg_tblspc/<tablespace>/<version>/<db>/<relfile>.
+// All the forks and segments of the relation have the same relfilenode.
+func parseRelFilePath(fileName string) (dbOid uint32, relFileNode uint32, ok bool) {
+	match := relFileRegexp.FindStringSubmatch(path.Base(fileName))
+	if match == nil {
+		return 0, 0, false
+	}
+	relFileNodeValue, err := strconv.ParseUint(match[1], 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	parts := strings.Split(strings.TrimPrefix(fileName, "/"), "/")
+	var dbOidPart string
+	switch {
+	case len(parts) == 3 && parts[0] == DefaultTablespace:
+		dbOidPart = parts[1]
+	case len(parts) == 5 && parts[0] == NonDefaultTablespace:
+		dbOidPart = parts[3]
+	default:
+		return 0, 0, false
+	}
+	dbOidValue, err := strconv.ParseUint(dbOidPart, 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint32(dbOidValue), uint32(relFileNodeValue), true
+}
@@ -0,0 +1,147 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+var testDatabasesByNames = postgres.DatabasesByNames{
+	"postgres":  13000,
+	"template1": 1,
+	"first":     16384,
+	"second":    16385,
+}
+
+func TestNewRestoreFilter_NothingRequested(t *testing.T) {
+	filter, err := postgres.NewRestoreFilter(testDatabasesByNames, nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, filter)
+	assert.True(t, filter.ShouldRestoreData("/base/16385/2668"))
+}
+
+func TestNewRestoreFilter_UnknownDatabase(t *testing.T) {
+	_, err := postgres.NewRestoreFilter(testDatabasesByNames, []string{"first", "missing"}, nil)
+	assert.IsType(t, postgres.DatabaseNotFoundError{}, err)
+}
+
+func TestRestoreFilter_ShouldRestoreData(t *testing.T) {
+	filter, err := postgres.NewRestoreFilter(testDatabasesByNames, []string{"first"}, nil)
+	assert.NoError(t, err)
+
+	restored := []string{
+		"/base/16384/2668",
+		"/base/16384/2668.3",
+		"/pg_tblspc/16400/PG_13_202007201/16384/2668_fsm",
+		"/base/1/1259",
+		"/base/13000/1259_vm",
+		"/base/13999/1259",
+		"/base/16385/PG_VERSION",
+		"/base/16385/pg_filenode.map",
+		"/global/1262",
+		"/pg_control",
+	}
+	for _, fileName := range restored {
+		assert.True(t, filter.ShouldRestoreData(fileName), fileName)
+	}
+
+	skipped := []string{
+		"/base/16385/2668",
+		"/base/16385/2668.1",
+		"/base/16385/2668_vm",
+		"/base/16385/2668_init",
+		"/pg_tblspc/16400/PG_13_202007201/16385/2668",
+	}
+	for _, fileName := range skipped {
+		assert.False(t, filter.ShouldRestoreData(fileName), fileName)
+	}
+}
+
+func TestRestoreFilter_SkipRelFileNodes(t *testing.T) {
+	filter, err := postgres.NewRestoreFilter(nil, nil, []uint32{16390})
+	assert.NoError(t, err)
+
+	restored := []string{
+		"/base/16384/16389",
+		"/base/16384/163901",
+		"/base/16384/1259",
+		"/global/16390",
+	}
+	for _, fileName := range restored {
+		assert.True(t, filter.ShouldRestoreData(fileName), fileName)
+	}
+
+	skipped := []string{
+		"/base/16384/16390",
+		"/base/16384/16390.1",
+		"/base/16384/16390.2",
+		"/base/16384/16390_fsm",
+		"/base/16384/16390_vm",
+		"/base/16385/16390",
+		"/pg_tblspc/16400/PG_13_202007201/16384/16390.1",
+	}
+	for _, fileName := range skipped {
+		assert.False(t, filter.ShouldRestoreData(fileName), fileName)
+	}
+}
+
+func TestNewRestoreFilter_SystemRelFileNode(t *testing.T) {
+	_, err := postgres.NewRestoreFilter(nil, nil, []uint32{1259})
+	assert.IsType(t, postgres.InvalidSkippedRelFileNodeError{}, err)
+}
+
+func TestCatalogsOnlyRestoreFilter(t *testing.T) {
+	filter := postgres.NewCatalogsOnlyRestoreFilter(nil)
+
+	restored := []string{
+		"/base/16384/1259",
+		"/base/16384/2619_fsm",
+		"/base/1/16383",
+		"/base/16384/pg_filenode.map",
+		"/global/1262",
+		"/global/16390",
+		"/pg_tblspc/16400/PG_13_202007201/16384/2663",
+		"/PG_VERSION",
+	}
+	for _, fileName := range restored {
+		assert.True(t, filter.ShouldRestoreData(fileName), fileName)
+	}
+
+	skipped := []string{
+		"/base/16384/16384",
+		"/base/1/16390.1",
+		"/base/13000/16390_vm",
+		"/pg_tblspc/16400/PG_13_202007201/16384/16390",
+	}
+	for _, fileName := range skipped {
+		assert.False(t, filter.ShouldRestoreData(fileName), fileName)
+	}
+}
+
+func TestCatalogsOnlyRestoreFilter_RewrittenCatalog(t *testing.T) {
+	// pg_namespace (2615) of database 16384 was rewritten by VACUUM FULL into relfilenode 16500
+	filter := postgres.NewCatalogsOnlyRestoreFilter(postgres.CatalogRelFileNodes{
+		16384: {1259, 16500},
+	})
+
+	restored := []string{
+		"/base/16384/1259",
+		"/base/16384/16500",
+		"/base/16384/16500_fsm",
+		"/base/1/2615",
+		"/global/1262",
+	}
+	for _, fileName := range restored {
+		assert.True(t, filter.ShouldRestoreData(fileName), fileName)
+	}
+
+	skipped := []string{
+		"/base/16384/2615",
+		"/base/16384/16384",
+		"/base/1/16500",
+	}
+	for _, fileName := range skipped {
+		assert.False(t, filter.ShouldRestoreData(fileName), fileName)
+	}
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"archive/tar"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+)
+
+const (
+	// restoreModeMask is cleared from the restored mode bits: PostgreSQL refuses to start if the group
+	// may write the data directory or if the others have any access to it
+	restoreModeMask          = 0027
+	restoreDataDirectoryMode = 0700
+)
+
+// RestoreOwnership changes the owner of the restored files to WALG_RESTORE_UID and WALG_RESTORE_GID
+// and clears the mode bits PostgreSQL does not accept, so the data directory can be restored by root
+// for another OS user than the original one. The owner is not changed if WAL-G does not run as root.
+type RestoreOwnership struct {
+	UID int
+	GID int
+
+	canChown     bool
+	dataDirOnce  sync.Once
+	dataDirError error
+}
+
+// NewRestoreOwnership builds the ownership remapping to the uid and gid, -1 keeps the owner or the group
+func NewRestoreOwnership(uid, gid int) *RestoreOwnership {
+	canChown := os.Geteuid() == 0
+	if !canChown {
+		tracelog.WarningLogger.Printf("WAL-G is not running as root, the owner of the restored files is not changed to %d:%d",
+			uid, gid)
+	}
+	return &RestoreOwnership{UID: uid, GID: gid, canChown: canChown}
+}
+
+// ConfigureRestoreOwnership returns the ownership remapping configured by WALG_RESTORE_UID and WALG_RESTORE_GID,
+// nil if neither is set
+func ConfigureRestoreOwnership() (*RestoreOwnership, error) {
+	uid, err := getRestoreOwnerID(internal.RestoreUIDSetting)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := getRestoreOwnerID(internal.RestoreGIDSetting)
+	if err != nil {
+		return nil, err
+	}
+	if uid == -1 && gid == -1 {
+		return nil, nil
+	}
+	return NewRestoreOwnership(uid, gid), nil
+}
+
+func getRestoreOwnerID(setting string) (int, error) {
+	value, ok := internal.GetSetting(setting)
+	if !ok || value == "" {
+		return -1, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 0 {
+		return -1, errors.Errorf("%s is expected to be a non-negative number but is: %s", setting, value)
+	}
+	return id, nil
+}
+
+// apply changes the owner and the mode of the extracted file, the nil ownership does nothing.
+// The data directory itself is remapped along with the first extracted file.
+func (ownership *RestoreOwnership) apply(dbDataDirectory, targetPath string, fileInfo *tar.Header) error {
+	if ownership == nil {
+		return nil
+	}
+	ownership.dataDirOnce.Do(func() {
+		ownership.dataDirError = ownership.remap(dbDataDirectory, restoreDataDirectoryMode, false)
+	})
+	if ownership.dataDirError != nil {
+		return ownership.dataDirError
+	}
+
+	switch fileInfo.Typeflag {
+	case tar.TypeReg, tar.TypeRegA, tar.TypeDir:
+		err := ownership.remap(targetPath, os.FileMode(fileInfo.Mode)&^restoreModeMask, false)
+		if os.IsNotExist(errors.Cause(err)) {
+			// the file is not restored this time
+			return nil
+		}
+		return err
+	case tar.TypeSymlink:
+		return ownership.remap(targetPath, 0, true)
+	}
+	return nil
+}
+
+func (ownership *RestoreOwnership) remap(targetPath string, mode os.FileMode, isSymlink bool) error {
+	if ownership.canChown {
+		chown := os.Chown
+		if isSymlink {
+			chown = os.Lchown
+		}
+		if err := chown(targetPath, ownership.UID, ownership.GID); err != nil {
+			return errors.Wrapf(err, "Interpret: failed to change the owner of %s", targetPath)
+		}
+	}
+	if isSymlink {
+		return nil
+	}
+	// chown clears the setuid and setgid bits, so the mode is set afterwards
+	return errors.Wrapf(os.Chmod(targetPath, mode), "Interpret: failed to change the mode of %s", targetPath)
+}
@@ -0,0 +1,261 @@
+package postgres
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	RestorePointsName  = "restore_points.json"
+	RecoverySignalName = "recovery.signal"
+	RecoveryConfName   = "recovery.conf"
+	AutoConfName       = "postgresql.auto.conf"
+)
+
+type RestorePointNotFoundError struct {
+	error
+}
+
+func newRestorePointNotFoundError(name, backupName, lastScannedSegment string) RestorePointNotFoundError {
+	if lastScannedSegment == "" {
+		return RestorePointNotFoundError{errors.Errorf("restore point %s is not found: "+
+			"no WAL is archived after backup %s", name, backupName)}
+	}
+	return RestorePointNotFoundError{errors.Errorf("restore point %s is not found in the WAL archived "+
+		"after backup %s up to segment %s, check the name or fetch an older backup", name, backupName, lastScannedSegment)}
+}
+
+func (err RestorePointNotFoundError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err RestorePointNotFoundError) ExitCode() int {
+	return internal.ExitCodeNotFound
+}
+
+// RestorePoint is the named restore point created by pg_create_restore_point
+type RestorePoint struct {
+	Name       string    `json:"name"`
+	Time       time.Time `json:"time"`
+	WalSegment string    `json:"wal_segment"`
+}
+
+// RestorePointsDto records the restore points found in the WAL archived after the backup,
+// so the next lookup scans only the WAL archived since then
+type RestorePointsDto struct {
+	ScannedUpTo   string         `json:"scanned_up_to,omitempty"`
+	RestorePoints []RestorePoint `json:"restore_points"`
+}
+
+func (dto *RestorePointsDto) find(name string) (RestorePoint, bool) {
+	for _, restorePoint := range dto.RestorePoints {
+		if restorePoint.Name == name {
+			return restorePoint, true
+		}
+	}
+	return RestorePoint{}, false
+}
+
+func (dto *RestorePointsDto) add(restorePoint RestorePoint) {
+	for _, known := range dto.RestorePoints {
+		if known.Name == restorePoint.Name && known.WalSegment == restorePoint.WalSegment &&
+			known.Time.Equal(restorePoint.Time) {
+			return
+		}
+	}
+	dto.RestorePoints = append(dto.RestorePoints, restorePoint)
+}
+
+func getRestorePointsPath(backupName string) string {
+	return backupName + "/" + RestorePointsName
+}
+
+// FindRestorePoint looks for the named restore point in the WAL archived after the backup
+// on the backup timeline, the found restore points are recorded next to the backup files
+func FindRestorePoint(rootFolder storage.Folder, backup Backup, name string) (RestorePoint, error) {
+	sentinelDto, err := backup.GetSentinel()
+	if err != nil {
+		return RestorePoint{}, err
+	}
+	if sentinelDto.BackupFinishLSN == nil {
+		return RestorePoint{}, errors.Errorf("backup %s has no finish LSN", backup.Name)
+	}
+	timeline, err := ParseTimelineFromBackupName(backup.Name)
+	if err != nil {
+		return RestorePoint{}, err
+	}
+
+	restorePoints, err := fetchRestorePoints(backup)
+	if err != nil {
+		return RestorePoint{}, err
+	}
+	if restorePoint, ok := restorePoints.find(name); ok {
+		return restorePoint, nil
+	}
+
+	// the last scanned segment is scanned again, since the record may continue in the next segment
+	segmentNo := newWalSegmentNo(*sentinelDto.BackupFinishLSN)
+	if restorePoints.ScannedUpTo != "" {
+		segmentNo, err = newWalSegmentNoFromFilename(restorePoints.ScannedUpTo)
+		if err != nil {
+			return RestorePoint{}, err
+		}
+	}
+	tracelog.InfoLogger.Printf("Looking for restore point %s in the WAL starting from segment %s\n",
+		name, segmentNo.getFilename(timeline))
+	err = scanRestorePoints(rootFolder.GetSubFolder(utility.WalPath), timeline, segmentNo,
+		*sentinelDto.BackupFinishLSN, name, &restorePoints)
+	if err != nil {
+		return RestorePoint{}, err
+	}
+
+	err = internal.UploadDto(backup.Folder, restorePoints, getRestorePointsPath(backup.Name))
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to record the restore points of backup %s: %v\n", backup.Name, err)
+	}
+	if restorePoint, ok := restorePoints.find(name); ok {
+		return restorePoint, nil
+	}
+	return RestorePoint{}, newRestorePointNotFoundError(name, backup.Name, restorePoints.ScannedUpTo)
+}
+
+func fetchRestorePoints(backup Backup) (RestorePointsDto, error) {
+	var restorePoints RestorePointsDto
+	exists, err := backup.Folder.Exists(getRestorePointsPath(backup.Name))
+	if err != nil || !exists {
+		return restorePoints, err
+	}
+	err = internal.FetchDto(backup.Folder, &restorePoints, getRestorePointsPath(backup.Name))
+	return restorePoints, err
+}
+
+// scanRestorePoints records the restore points of the consecutive WAL segments until the named one is found
+// or the next segment is not archived. The restore points written before the backup finish are skipped,
+// since the recovery can't stop before the restored cluster is consistent.
+func scanRestorePoints(walFolder storage.Folder, timeline uint32, segmentNo WalSegmentNo, finishLsn LSN,
+	name string, restorePoints *RestorePointsDto) error {
+	parser := walparser.NewWalParser()
+	for ; ; segmentNo = segmentNo.next() {
+		segmentName := segmentNo.getFilename(timeline)
+		walFile, err := internal.DownloadAndDecompressStorageFile(walFolder, segmentName)
+		if err != nil {
+			if _, ok := err.(internal.ArchiveNonExistenceError); ok {
+				return nil
+			}
+			return err
+		}
+		found, err := scanSegmentRestorePoints(parser, walFile, segmentNo, segmentName, finishLsn, name, restorePoints)
+		utility.LoggedClose(walFile, "")
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse WAL segment %s", segmentName)
+		}
+		restorePoints.ScannedUpTo = segmentName
+		if found {
+			return nil
+		}
+	}
+}
+
+func scanSegmentRestorePoints(parser *walparser.WalParser, walFile io.Reader, segmentNo WalSegmentNo,
+	segmentName string, finishLsn LSN, name string, restorePoints *RestorePointsDto) (found bool, err error) {
+	pageReader := walparser.NewWalPageReader(walFile)
+	for pageLsn := segmentNo.firstLsn(); ; pageLsn += LSN(walparser.WalPageSize) {
+		data, err := pageReader.ReadPageData()
+		if err != nil {
+			if err == io.EOF {
+				return found, nil
+			}
+			return false, err
+		}
+		_, records, err := parser.ParseRecordsFromPage(bytes.NewReader(data))
+		switch err.(type) {
+		case nil:
+		case walparser.PartialPageError:
+		case walparser.ZeroPageError:
+		default:
+			return false, err
+		}
+		// the records end on this page, so the ones of the earlier pages surely end before the backup finish
+		if pageLsn+LSN(walparser.WalPageSize) <= finishLsn {
+			continue
+		}
+		for i := range records {
+			if !records[i].IsRestorePoint() {
+				continue
+			}
+			pointData, err := walparser.ParseRestorePointData(&records[i])
+			if err != nil {
+				return false, err
+			}
+			restorePoints.add(RestorePoint{Name: pointData.Name, Time: pointData.Time, WalSegment: segmentName})
+			found = found || pointData.Name == name
+		}
+	}
+}
+
+// GetPgRestorePointFetcher wraps the fetcher to look up the named restore point before anything is extracted,
+// the recovery configuration targeting it is written by the fetcher of GetPgRecoveryConfigFetcher
+func GetPgRestorePointFetcher(fetcher func(rootFolder storage.Folder, backup internal.Backup),
+	restorePointName string) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		pgBackup := ToPgBackup(backup)
+		restorePoint, err := FindRestorePoint(rootFolder, pgBackup, restorePointName)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+		tracelog.InfoLogger.Printf("Found restore point %s created at %s in WAL segment %s\n",
+			restorePoint.Name, restorePoint.Time.Format(time.RFC3339), restorePoint.WalSegment)
+
+		fetcher(rootFolder, backup)
+	}
+}
+
+// appendConfigSettings appends the settings to the configuration file of the restored cluster
+func appendConfigSettings(dbDataDirectory, configName, settings string) error {
+	configFile, err := os.OpenFile(filepath.Join(dbDataDirectory, configName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", configName)
+	}
+	_, err = configFile.WriteString(settings)
+	if err != nil {
+		utility.LoggedClose(configFile, "")
+		return errors.Wrapf(err, "failed to write %s", configName)
+	}
+	err = configFile.Close()
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %s", configName)
+	}
+	return nil
+}
+
+// readPgMajorVersion reads the major version of the restored cluster from the PG_VERSION file,
+// which is "9.6" for the versions before 10 and "12" for the later ones
+func readPgMajorVersion(dbDataDirectory string) (int, error) {
+	content, err := os.ReadFile(filepath.Join(dbDataDirectory, "PG_VERSION"))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read PG_VERSION")
+	}
+	version := strings.TrimSpace(string(content))
+	major, _, _ := strings.Cut(version, ".")
+	majorVersion, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, errors.Errorf("invalid PG_VERSION '%s'", version)
+	}
+	return majorVersion, nil
+}
+
+// quoteConfigValue quotes the value of the postgres configuration parameter
+func quoteConfigValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const restorePointTestBackup = "base_000000010000000000000002"
+
+var restorePointTestTime = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// makeRestorePointWalSegment makes the WAL segment of one page with the restore point records
+func makeRestorePointWalSegment(segmentNo WalSegmentNo, names ...string) []byte {
+	var page bytes.Buffer
+	write := func(data interface{}) { _ = binary.Write(&page, binary.LittleEndian, data) }
+	write(uint16(0xD10D))
+	write(uint16(walparser.XlpLongHeader))
+	write(uint32(1))
+	write(uint64(segmentNo.firstLsn()))
+	write(uint32(0))
+	write(uint64(0)) // system identifier
+	write(uint32(WalSegmentSize))
+	write(uint32(walparser.WalPageSize))
+	page.Write(make([]byte, 4))
+
+	for _, name := range names {
+		write(uint32(walparser.XLogRecordHeaderSize + 2 + 8 + walparser.RestorePointNameSize))
+		write(uint32(0))
+		write(uint64(0))
+		write(uint8(walparser.XLogRestorePoint))
+		write(uint8(walparser.RmXlogID))
+		write(uint16(0))
+		write(uint32(0))
+		write(uint8(walparser.XlrBlockIDDataShort))
+		write(uint8(8 + walparser.RestorePointNameSize))
+		write(restorePointTestTime.Sub(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)).Microseconds())
+		nameData := make([]byte, walparser.RestorePointNameSize)
+		copy(nameData, name)
+		page.Write(nameData)
+		page.Write(make([]byte, (walparser.XLogRecordAlignment-page.Len()%walparser.XLogRecordAlignment)%
+			walparser.XLogRecordAlignment))
+	}
+	page.Write(make([]byte, int(walparser.WalPageSize)-page.Len()))
+	return page.Bytes()
+}
+
+func putRestorePointTestBackup(t *testing.T, folder storage.Folder, finishLsn LSN) Backup {
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	startLsn := LSN(2 * WalSegmentSize)
+	err := internal.UploadDto(baseBackupFolder, BackupSentinelDto{BackupStartLSN: &startLsn, BackupFinishLSN: &finishLsn},
+		restorePointTestBackup+utility.SentinelSuffix)
+	require.NoError(t, err)
+	return NewBackup(baseBackupFolder, restorePointTestBackup)
+}
+
+func putRestorePointTestSegment(t *testing.T, folder storage.Folder, segmentNo WalSegmentNo, names ...string) {
+	err := folder.GetSubFolder(utility.WalPath).PutObject(segmentNo.getFilename(1),
+		bytes.NewReader(makeRestorePointWalSegment(segmentNo, names...)))
+	require.NoError(t, err)
+}
+
+func TestParseRestorePointData(t *testing.T) {
+	data := makeRestorePointWalSegment(WalSegmentNo(3), "before_migration")
+	_, records, err := walparser.NewWalParser().ParseRecordsFromPage(bytes.NewReader(data))
+	assert.IsType(t, walparser.PartialPageError{}, err)
+	require.Len(t, records, 1)
+	assert.True(t, records[0].IsRestorePoint())
+
+	pointData, err := walparser.ParseRestorePointData(&records[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "before_migration", pointData.Name)
+	assert.True(t, restorePointTestTime.Equal(pointData.Time))
+}
+
+func TestFindRestorePoint(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	backup := putRestorePointTestBackup(t, folder, WalSegmentNo(2).firstLsn()+LSN(walparser.WalPageSize))
+	// the restore point of the finish segment page written before the backup finish is unreachable
+	putRestorePointTestSegment(t, folder, WalSegmentNo(2), "during_backup")
+	putRestorePointTestSegment(t, folder, WalSegmentNo(3), "first")
+	putRestorePointTestSegment(t, folder, WalSegmentNo(4), "second", "third")
+
+	restorePoint, err := FindRestorePoint(folder, backup, "second")
+	assert.NoError(t, err)
+	assert.Equal(t, "second", restorePoint.Name)
+	assert.Equal(t, "000000010000000000000004", restorePoint.WalSegment)
+	assert.True(t, restorePointTestTime.Equal(restorePoint.Time))
+
+	restorePoints, err := fetchRestorePoints(backup)
+	assert.NoError(t, err)
+	assert.Equal(t, "000000010000000000000004", restorePoints.ScannedUpTo)
+	names := make([]string, 0)
+	for _, restorePoint := range restorePoints.RestorePoints {
+		names = append(names, restorePoint.Name)
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, names)
+
+	_, err = FindRestorePoint(folder, backup, "during_backup")
+	assert.IsType(t, RestorePointNotFoundError{}, err)
+
+	// the WAL archived later is scanned from the last scanned segment
+	putRestorePointTestSegment(t, folder, WalSegmentNo(5), "during_backup")
+	restorePoint, err = FindRestorePoint(folder, backup, "during_backup")
+	assert.NoError(t, err)
+	assert.Equal(t, "000000010000000000000005", restorePoint.WalSegment)
+	restorePoints, err = fetchRestorePoints(backup)
+	assert.NoError(t, err)
+	assert.Len(t, restorePoints.RestorePoints, 4)
+}
+
+func TestFindRestorePoint_NoWal(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	backup := putRestorePointTestBackup(t, folder, WalSegmentNo(2).firstLsn())
+
+	_, err := FindRestorePoint(folder, backup, "missing")
+	assert.IsType(t, RestorePointNotFoundError{}, err)
+	assert.Contains(t, err.Error(), "no WAL is archived")
+}
+
+func TestWriteRestorePointRecoveryConfig(t *testing.T) {
+	for _, test := range []struct {
+		pgVersion  string
+		configName string
+		signal     bool
+	}{
+		{"14", AutoConfName, true},
+		{"9.6", RecoveryConfName, false},
+	} {
+		dataDirectory := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, "PG_VERSION"), []byte(test.pgVersion+"\n"), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, AutoConfName), []byte("work_mem = '64MB'\n"), 0600))
+
+		config := NewRecoveryConfig(false, false)
+		config.SetTargetName("before 'drop'")
+		err := config.Write(dataDirectory, `"/usr/bin/wal-g" wal-fetch "%f" "%p"`)
+		assert.NoError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(dataDirectory, test.configName))
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(string(content),
+			"restore_command = '\"/usr/bin/wal-g\" wal-fetch \"%f\" \"%p\"'\nrecovery_target_name = 'before ''drop'''\n"))
+		_, err = os.Stat(filepath.Join(dataDirectory, RecoverySignalName))
+		assert.Equal(t, test.signal, err == nil)
+	}
+}
+
+func TestWriteCatalogsOnlyConfig(t *testing.T) {
+	dataDirectory := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, AutoConfName), []byte("work_mem = '64MB'\n"), 0600))
+
+	assert.NoError(t, writeCatalogsOnlyConfig(dataDirectory))
+
+	content, err := os.ReadFile(filepath.Join(dataDirectory, AutoConfName))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "work_mem = '64MB'\n"))
+	assert.True(t, strings.HasSuffix(string(content), "default_transaction_read_only = on\nautovacuum = off\n"))
+}
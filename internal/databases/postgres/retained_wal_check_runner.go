@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// RetainedWalCheckDetails are the holds of backup-retain with the pinned WAL segments missing in storage
+type RetainedWalCheckDetails []*RetainedWalDetails
+
+type RetainedWalDetails struct {
+	BackupName      string     `json:"backup_name"`
+	Until           *time.Time `json:"until,omitempty"`
+	WalStart        string     `json:"wal_start"`
+	WalEnd          string     `json:"wal_end"`
+	MissingSegments []string   `json:"missing_segments,omitempty"`
+}
+
+func (details RetainedWalCheckDetails) NewPlainTextReader() (io.Reader, error) {
+	var outputBuffer bytes.Buffer
+
+	tableWriter := table.NewWriter()
+	tableWriter.SetOutputMirror(&outputBuffer)
+
+	tableWriter.AppendHeader(table.Row{"Backup", "Until", "WAL start", "WAL end", "Missing segments count"})
+	var missingSegments []string
+	for _, row := range details {
+		until := "-"
+		if row.Until != nil {
+			until = internal.FormatTime(*row.Until)
+		}
+		tableWriter.AppendRow(table.Row{row.BackupName, until, row.WalStart, row.WalEnd, len(row.MissingSegments)})
+		missingSegments = append(missingSegments, row.MissingSegments...)
+	}
+	tableWriter.Render()
+
+	if len(missingSegments) > 0 {
+		outputBuffer.WriteString("Missing segments:\n")
+		for _, segmentName := range missingSegments {
+			outputBuffer.WriteString(segmentName + "\n")
+		}
+	}
+	return &outputBuffer, nil
+}
+
+// RetainedWalCheckRunner checks that the WAL segments pinned by the unexpired holds of backup-retain
+// are all in storage
+type RetainedWalCheckRunner struct {
+	retentions         map[string]BackupRetention
+	walFolderFilenames []string
+}
+
+func NewRetainedWalCheckRunner(rootFolder storage.Folder,
+	walFolderFilenames []string) (RetainedWalCheckRunner, error) {
+	retentions, err := getBackupRetentions(rootFolder.GetSubFolder(utility.BaseBackupPath))
+	if err != nil {
+		return RetainedWalCheckRunner{}, err
+	}
+	return RetainedWalCheckRunner{retentions: retentions, walFolderFilenames: walFolderFilenames}, nil
+}
+
+func (check RetainedWalCheckRunner) Run() (WalVerifyCheckResult, error) {
+	storageSegments := getSegmentsFromFiles(check.walFolderFilenames)
+	backupNames := make([]string, 0, len(check.retentions))
+	for backupName := range check.retentions {
+		backupNames = append(backupNames, backupName)
+	}
+	sort.Strings(backupNames)
+
+	result := WalVerifyCheckResult{Status: StatusOk}
+	details := make(RetainedWalCheckDetails, 0, len(backupNames))
+	for _, backupName := range backupNames {
+		retention := check.retentions[backupName]
+		walSegments, err := retention.getWalSegments()
+		if err != nil {
+			return WalVerifyCheckResult{}, errors.Wrapf(err, "failed to get the retained WAL segments of backup %s",
+				backupName)
+		}
+		row := &RetainedWalDetails{BackupName: backupName, Until: retention.Until,
+			WalStart: retention.WalStart, WalEnd: retention.WalEnd}
+		for _, walSegment := range walSegments {
+			segment, err := NewWalSegmentDescription(walSegment)
+			if err != nil {
+				return WalVerifyCheckResult{}, err
+			}
+			if !storageSegments[segment] {
+				row.MissingSegments = append(row.MissingSegments, walSegment)
+				result.Status = StatusFailure
+			}
+		}
+		details = append(details, row)
+	}
+	result.Details = details
+	return result, nil
+}
+
+func (check RetainedWalCheckRunner) Type() WalVerifyCheckType {
+	return WalVerifyRetainedCheck
+}
+
+// getBackupRetentions returns the unexpired holds of backup-retain by the backup names
+func getBackupRetentions(baseBackupFolder storage.Folder) (map[string]BackupRetention, error) {
+	retentions := make(map[string]BackupRetention)
+	backupTimes, err := internal.GetBackups(baseBackupFolder)
+	if _, ok := err.(internal.NoBackupsFoundError); ok {
+		return retentions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, backupTime := range backupTimes {
+		backup := NewBackup(baseBackupFolder, backupTime.BackupName)
+		meta, err := backup.FetchMeta()
+		if err != nil {
+			internal.FatalOnUnrecoverableMetadataError(backupTime, err)
+			continue
+		}
+		if meta.IsPermanent && meta.Retention != nil {
+			retentions[backupTime.BackupName] = *meta.Retention
+		}
+	}
+	return retentions, nil
+}
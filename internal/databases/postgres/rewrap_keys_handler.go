@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const encryptionKeySentinelField = "EncryptionKey"
+
+type keyRewrapStatus int
+
+const (
+	keyRewrapped keyRewrapStatus = iota
+	keyAlreadyRewrapped
+	keyNotStored
+)
+
+// RewrapKeysResult counts the backups by what rewrap-keys did with their data keys
+type RewrapKeysResult struct {
+	Rewrapped     []string
+	AlreadyNew    []string
+	NotEncrypted  []string
+	FailedBackups []string
+}
+
+// HandleRewrapKeys rewraps the data keys stored in the backup sentinels by the new master key
+func HandleRewrapKeys(folder storage.Folder, oldMasterKeys []*envelope.MasterKey, newMasterKey *envelope.MasterKey) {
+	result, err := RewrapKeys(folder.GetSubFolder(utility.BaseBackupPath), oldMasterKeys, newMasterKey)
+	tracelog.ErrorLogger.FatalOnError(err)
+	tracelog.InfoLogger.Printf("Rewrapped the data keys of %d backups, %d are already wrapped by master key %s, "+
+		"%d have no data key in the sentinel", len(result.Rewrapped), len(result.AlreadyNew), newMasterKey.ID(),
+		len(result.NotEncrypted))
+	if len(result.FailedBackups) > 0 {
+		tracelog.ErrorLogger.Fatalf("Failed to rewrap the data keys of %d backups: %v",
+			len(result.FailedBackups), result.FailedBackups)
+	}
+}
+
+// RewrapKeys unwraps the data key of every backup by the old master key it is wrapped by and wraps it by the new one.
+// Only the sentinels are rewritten, the tarballs keep referencing the sentinels for their keys.
+// The failure of one backup is logged and the rest are rewrapped anyway.
+func RewrapKeys(baseBackupFolder storage.Folder, oldMasterKeys []*envelope.MasterKey,
+	newMasterKey *envelope.MasterKey) (RewrapKeysResult, error) {
+	var result RewrapKeysResult
+	backups, err := internal.GetBackups(baseBackupFolder)
+	if err != nil {
+		return result, err
+	}
+	for _, backup := range backups {
+		status, err := rewrapBackupKey(baseBackupFolder, backup.BackupName, oldMasterKeys, newMasterKey)
+		if err != nil {
+			tracelog.ErrorLogger.Printf("Failed to rewrap the data key of backup %s: %v", backup.BackupName, err)
+			result.FailedBackups = append(result.FailedBackups, backup.BackupName)
+			continue
+		}
+		switch status {
+		case keyRewrapped:
+			tracelog.InfoLogger.Printf("Rewrapped the data key of backup %s", backup.BackupName)
+			result.Rewrapped = append(result.Rewrapped, backup.BackupName)
+		case keyAlreadyRewrapped:
+			result.AlreadyNew = append(result.AlreadyNew, backup.BackupName)
+		case keyNotStored:
+			result.NotEncrypted = append(result.NotEncrypted, backup.BackupName)
+		}
+	}
+	return result, nil
+}
+
+func rewrapBackupKey(baseBackupFolder storage.Folder, backupName string,
+	oldMasterKeys []*envelope.MasterKey, newMasterKey *envelope.MasterKey) (keyRewrapStatus, error) {
+	sentinelPath := internal.SentinelNameFromBackup(backupName)
+	// the sentinel is rewritten field by field, so the fields unknown to this version are preserved
+	sentinel, err := fetchRawSentinel(baseBackupFolder, sentinelPath)
+	if err != nil {
+		return keyNotStored, err
+	}
+	rawKey, ok := sentinel[encryptionKeySentinelField]
+	if !ok {
+		return keyNotStored, nil
+	}
+	var wrappedKey envelope.WrappedKey
+	err = json.Unmarshal(rawKey, &wrappedKey)
+	if err != nil {
+		return keyNotStored, errors.Wrapf(err, "failed to parse the data key of %s", sentinelPath)
+	}
+	if wrappedKey.MasterKeyID == newMasterKey.ID() {
+		return keyAlreadyRewrapped, nil
+	}
+
+	oldMasterKey := findMasterKey(oldMasterKeys, wrappedKey.MasterKeyID)
+	if oldMasterKey == nil {
+		return keyNotStored, errors.Errorf("the data key of %s is wrapped by unknown master key %s",
+			sentinelPath, wrappedKey.MasterKeyID)
+	}
+	newWrappedKey, err := oldMasterKey.Rewrap(&wrappedKey, newMasterKey)
+	if err != nil {
+		return keyNotStored, err
+	}
+	sentinel[encryptionKeySentinelField], err = json.Marshal(newWrappedKey)
+	if err != nil {
+		return keyNotStored, err
+	}
+	content, err := json.Marshal(sentinel)
+	if err != nil {
+		return keyNotStored, err
+	}
+	return keyRewrapped, baseBackupFolder.PutObject(sentinelPath, bytes.NewReader(content))
+}
+
+func findMasterKey(masterKeys []*envelope.MasterKey, id string) *envelope.MasterKey {
+	for _, masterKey := range masterKeys {
+		if masterKey.ID() == id {
+			return masterKey
+		}
+	}
+	return nil
+}
+
+func fetchRawSentinel(baseBackupFolder storage.Folder, sentinelPath string) (map[string]json.RawMessage, error) {
+	reader, err := baseBackupFolder.ReadObject(sentinelPath)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(reader, "")
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var sentinel map[string]json.RawMessage
+	err = json.Unmarshal(content, &sentinel)
+	return sentinel, errors.Wrapf(err, "failed to parse %s", sentinelPath)
+}
@@ -0,0 +1,84 @@
+package postgres_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func newTestEnvelopeMasterKey(t *testing.T) *envelope.MasterKey {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	masterKey, err := envelope.NewMasterKey(key)
+	require.NoError(t, err)
+	return masterKey
+}
+
+func putTestSentinel(t *testing.T, folder storage.Folder, backupName string, wrappedKey *envelope.WrappedKey) {
+	sentinel := postgres.BackupSentinelDto{PgVersion: 150000, EncryptionKey: wrappedKey}
+	content, err := json.Marshal(sentinel)
+	require.NoError(t, err)
+	require.NoError(t, folder.PutObject(internal.SentinelNameFromBackup(backupName), bytes.NewReader(content)))
+}
+
+func fetchTestSentinel(t *testing.T, folder storage.Folder, backupName string) postgres.BackupSentinelDto {
+	reader, err := folder.ReadObject(internal.SentinelNameFromBackup(backupName))
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	var sentinel postgres.BackupSentinelDto
+	require.NoError(t, json.Unmarshal(content, &sentinel))
+	return sentinel
+}
+
+func TestRewrapKeys(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	oldMasterKey, newMasterKey := newTestEnvelopeMasterKey(t), newTestEnvelopeMasterKey(t)
+	dataKey := []byte("the data key of the old backup")
+	oldWrappedKey, err := oldMasterKey.Wrap(dataKey)
+	require.NoError(t, err)
+	newWrappedKey, err := newMasterKey.Wrap([]byte("the data key of the new backup"))
+	require.NoError(t, err)
+	putTestSentinel(t, folder, "base_000000010000000000000002", oldWrappedKey)
+	putTestSentinel(t, folder, "base_000000010000000000000004", newWrappedKey)
+	putTestSentinel(t, folder, "base_000000010000000000000006", nil)
+
+	result, err := postgres.RewrapKeys(folder, []*envelope.MasterKey{newTestEnvelopeMasterKey(t), oldMasterKey}, newMasterKey)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base_000000010000000000000002"}, result.Rewrapped)
+	assert.Equal(t, []string{"base_000000010000000000000004"}, result.AlreadyNew)
+	assert.Equal(t, []string{"base_000000010000000000000006"}, result.NotEncrypted)
+	assert.Empty(t, result.FailedBackups)
+
+	sentinel := fetchTestSentinel(t, folder, "base_000000010000000000000002")
+	assert.Equal(t, 150000, sentinel.PgVersion)
+	require.NotNil(t, sentinel.EncryptionKey)
+	assert.Equal(t, newMasterKey.ID(), sentinel.EncryptionKey.MasterKeyID)
+	unwrapped, err := newMasterKey.Unwrap(sentinel.EncryptionKey)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+}
+
+func TestRewrapKeys_UnknownMasterKey(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	wrappedKey, err := newTestEnvelopeMasterKey(t).Wrap([]byte("data key"))
+	require.NoError(t, err)
+	putTestSentinel(t, folder, "base_000000010000000000000002", wrappedKey)
+
+	result, err := postgres.RewrapKeys(folder, []*envelope.MasterKey{newTestEnvelopeMasterKey(t)},
+		newTestEnvelopeMasterKey(t))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base_000000010000000000000002"}, result.FailedBackups)
+	assert.Equal(t, wrappedKey, fetchTestSentinel(t, folder, "base_000000010000000000000002").EncryptionKey)
+}
@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// dedupKey identifies the contents of the deduplicated file, the copies are restored with the mode of the packed file
+type dedupKey struct {
+	hash [sha256.Size]byte
+	mode int64
+}
+
+// dedupSource is the small file packed into the tarballs, which copies are found during the walk.
+// The copies are recorded only once the source is packed and the bytes written to the tarball
+// are the ones hashed during the walk, otherwise the copies are packed as usual.
+type dedupSource struct {
+	name     string
+	walkHash [sha256.Size]byte
+	mutex    sync.Mutex
+	copies   []*internal.ComposeFileInfo
+	// packedHash is set once the source is packed
+	packedHash *[sha256.Size]byte
+}
+
+func (source *dedupSource) addCopy(info *internal.ComposeFileInfo) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.copies = append(source.copies, info)
+}
+
+// setPacked records the hash of the bytes of the source written to the tarball
+func (source *dedupSource) setPacked(packedHasher hash.Hash) {
+	var packedHash [sha256.Size]byte
+	copy(packedHash[:], packedHasher.Sum(nil))
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.packedHash = &packedHash
+}
+
+// smallFilesDedup finds the byte-identical small files during the walk of the data directory,
+// only the first one of them is packed into the tarballs and the rest are recorded as its copies
+type smallFilesDedup struct {
+	maxFileSize int64
+	sources     map[dedupKey]*dedupSource
+	dedupCount  int
+}
+
+func newSmallFilesDedup(maxFileSize int64) *smallFilesDedup {
+	return &smallFilesDedup{maxFileSize: maxFileSize, sources: make(map[dedupKey]*dedupSource)}
+}
+
+// enableSmallFilesDedup makes the composer deduplicate the small files, only the regular composer supports it
+func enableSmallFilesDedup(tarBallComposerMaker TarBallComposerMaker) error {
+	regularMaker, ok := tarBallComposerMaker.(*RegularTarBallComposerMaker)
+	if !ok {
+		return newBackupPushUsageError("the small files deduplication is supported only by the regular composer")
+	}
+	maxFileSize, err := internal.GetDedupMaxFileSize()
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Deduplicating the identical files not larger than %d bytes", maxFileSize)
+	regularMaker.dedupMaxFileSize = maxFileSize
+	return nil
+}
+
+// deduplicate holds back the file which is identical to the already walked one, its copy is recorded
+// by recordCopies. The file which is not deduplicated is packed as usual, the returned source is set
+// if it is the first one of the identical files, then the bytes packed for it must be hashed.
+// The hashing is best effort: the file which fails to be read is packed, so the packer handles the error.
+func (dedup *smallFilesDedup) deduplicate(info *internal.ComposeFileInfo) (deduplicated bool, source *dedupSource) {
+	if info.IsIncremented || info.FileInfo.Size() > dedup.maxFileSize {
+		return false, nil
+	}
+	walkHash, err := hashSmallFile(info.Path, info.FileInfo.Size())
+	if err != nil {
+		tracelog.DebugLogger.Printf("Not deduplicating '%s': %v\n", info.Header.Name, err)
+		return false, nil
+	}
+	key := dedupKey{hash: walkHash, mode: info.Header.Mode}
+	source, ok := dedup.sources[key]
+	if !ok {
+		source = &dedupSource{name: info.Header.Name, walkHash: walkHash}
+		dedup.sources[key] = source
+		return false, source
+	}
+	tracelog.DebugLogger.Printf("'%s' is identical to '%s'\n", info.Header.Name, source.name)
+	source.addCopy(info)
+	return true, nil
+}
+
+// recordCopies records the copies of the packed sources which are not changed since the walk,
+// and returns the copies of the sources changed, deleted or not hashed while they are packed,
+// which must be packed as usual. It is called once all the sources are packed.
+func (dedup *smallFilesDedup) recordCopies(files internal.BundleFiles) (notDeduplicated []*internal.ComposeFileInfo) {
+	for _, source := range dedup.sources {
+		if len(source.copies) == 0 {
+			continue
+		}
+		if source.packedHash == nil || *source.packedHash != source.walkHash {
+			tracelog.WarningLogger.Printf("'%s' changed while it was packed, packing its %d copies as usual\n",
+				source.name, len(source.copies))
+			notDeduplicated = append(notDeduplicated, source.copies...)
+			continue
+		}
+		for _, info := range source.copies {
+			files.AddFileDescription(info.Header.Name, internal.BackupFileDescription{
+				MTime:   info.FileInfo.ModTime(),
+				Size:    info.FileInfo.Size(),
+				DedupOf: source.name,
+			})
+		}
+		dedup.dedupCount += len(source.copies)
+	}
+	return notDeduplicated
+}
+
+func hashSmallFile(filePath string, expectedSize int64) (hash [sha256.Size]byte, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return hash, err
+	}
+	defer utility.LoggedClose(file, "")
+	hasher := sha256.New()
+	// the file growing during the walk is not deduplicated
+	size, err := io.Copy(hasher, io.LimitReader(file, expectedSize+1))
+	if err != nil {
+		return hash, err
+	}
+	if size != expectedSize {
+		return hash, errors.Errorf("the file size changed from %d to %d bytes", expectedSize, size)
+	}
+	copy(hash[:], hasher.Sum(nil))
+	return hash, nil
+}
+
+// getDedupCopies returns the names of the deduplicated copies of the files, sorted, by the names of the packed files
+func (dto *FilesMetadataDto) getDedupCopies() map[string][]string {
+	dedupCopies := make(map[string][]string)
+	for fileName, description := range dto.Files {
+		if description.DedupOf != "" {
+			dedupCopies[description.DedupOf] = append(dedupCopies[description.DedupOf], fileName)
+		}
+	}
+	for _, copies := range dedupCopies {
+		sort.Strings(copies)
+	}
+	return dedupCopies
+}
+
+// hasDedupCopyToUnwrap checks whether some deduplicated copy of the file has to be unwrapped
+func hasDedupCopyToUnwrap(copies []string, filesToUnwrap map[string]bool) bool {
+	for _, copyName := range copies {
+		if filesToUnwrap[copyName] {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapWithDedupCopies restores the file and its deduplicated copies from the same tar member
+func (tarInterpreter *FileTarInterpreter) unwrapWithDedupCopies(fileReader io.Reader, fileInfo *tar.Header,
+	copies []string, fsync bool) error {
+	content, err := io.ReadAll(fileReader)
+	if err != nil {
+		return errors.Wrapf(err, "Interpret: failed to read %s", fileInfo.Name)
+	}
+	for _, fileName := range append([]string{fileInfo.Name}, copies...) {
+		header := *fileInfo
+		header.Name = fileName
+		if fileName != fileInfo.Name && tarInterpreter.inplace != nil {
+			tarInterpreter.inplace.addRestoredFile(fileName)
+		}
+		targetPath := path.Join(tarInterpreter.DBDataDirectory, fileName)
+		if fsync {
+			tarInterpreter.extractedDirs.add(tarInterpreter.DBDataDirectory, targetPath)
+		}
+		err = tarInterpreter.interpretRegularFile(bytes.NewReader(content), &header, targetPath, fsync)
+		if err != nil {
+			return err
+		}
+		if fileName != fileInfo.Name {
+			err = tarInterpreter.Ownership.apply(tarInterpreter.DBDataDirectory, targetPath, &header)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
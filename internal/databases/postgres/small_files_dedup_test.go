@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+)
+
+func newDedupTestFileInfo(t *testing.T, directory, name, content string) *internal.ComposeFileInfo {
+	filePath := filepath.Join(directory, name)
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0600))
+	fileInfo, err := os.Stat(filePath)
+	require.NoError(t, err)
+	header, err := tar.FileInfoHeader(fileInfo, name)
+	require.NoError(t, err)
+	header.Name = "/base/1/" + name
+	return internal.NewComposeFileInfo(filePath, fileInfo, false, false, header)
+}
+
+// packDedupSource emulates the packing of the source, the packed bytes are hashed
+func packDedupSource(source *dedupSource, packedContent string) {
+	packedHasher := sha256.New()
+	packedHasher.Write([]byte(packedContent))
+	source.setPacked(packedHasher)
+}
+
+func TestSmallFilesDedup_Deduplicate(t *testing.T) {
+	directory := t.TempDir()
+	dedup := newSmallFilesDedup(8)
+	files := &internal.RegularBundleFiles{}
+
+	deduplicated, templateSource := dedup.deduplicate(newDedupTestFileInfo(t, directory, "1", "template"))
+	assert.False(t, deduplicated)
+	require.NotNil(t, templateSource)
+	deduplicated, _ = dedup.deduplicate(newDedupTestFileInfo(t, directory, "2", "template"))
+	assert.True(t, deduplicated)
+	deduplicated, modifiedSource := dedup.deduplicate(newDedupTestFileInfo(t, directory, "3", "modified"))
+	assert.False(t, deduplicated)
+	deduplicated, largeSource := dedup.deduplicate(newDedupTestFileInfo(t, directory, "4", "large file"))
+	assert.False(t, deduplicated)
+	assert.Nil(t, largeSource)
+	deduplicated, _ = dedup.deduplicate(newDedupTestFileInfo(t, directory, "5", "large file"))
+	assert.False(t, deduplicated)
+	deduplicated, emptySource := dedup.deduplicate(newDedupTestFileInfo(t, directory, "6", ""))
+	assert.False(t, deduplicated)
+	deduplicated, _ = dedup.deduplicate(newDedupTestFileInfo(t, directory, "7", ""))
+	assert.True(t, deduplicated)
+	incremented := newDedupTestFileInfo(t, directory, "8", "template")
+	incremented.IsIncremented = true
+	deduplicated, _ = dedup.deduplicate(incremented)
+	assert.False(t, deduplicated)
+
+	packDedupSource(templateSource, "template")
+	packDedupSource(modifiedSource, "modified")
+	packDedupSource(emptySource, "")
+	assert.Empty(t, dedup.recordCopies(files))
+	assert.Equal(t, 2, dedup.dedupCount)
+
+	description, ok := files.Load("/base/1/2")
+	require.True(t, ok)
+	assert.Equal(t, "/base/1/1", description.(internal.BackupFileDescription).DedupOf)
+	assert.Equal(t, int64(len("template")), description.(internal.BackupFileDescription).Size)
+	description, ok = files.Load("/base/1/7")
+	require.True(t, ok)
+	assert.Equal(t, "/base/1/6", description.(internal.BackupFileDescription).DedupOf)
+}
+
+func TestSmallFilesDedup_SourceChangedWhilePacked(t *testing.T) {
+	directory := t.TempDir()
+	dedup := newSmallFilesDedup(8)
+	files := &internal.RegularBundleFiles{}
+
+	_, changedSource := dedup.deduplicate(newDedupTestFileInfo(t, directory, "1", "template"))
+	copyInfo := newDedupTestFileInfo(t, directory, "2", "template")
+	deduplicated, _ := dedup.deduplicate(copyInfo)
+	require.True(t, deduplicated)
+	// the source is deleted before it is packed
+	dedup.deduplicate(newDedupTestFileInfo(t, directory, "3", "deleted"))
+	deletedCopyInfo := newDedupTestFileInfo(t, directory, "4", "deleted")
+	dedup.deduplicate(deletedCopyInfo)
+
+	packDedupSource(changedSource, "modified")
+	notDeduplicated := dedup.recordCopies(files)
+	assert.ElementsMatch(t, []*internal.ComposeFileInfo{copyInfo, deletedCopyInfo}, notDeduplicated)
+	assert.Equal(t, 0, dedup.dedupCount)
+	_, ok := files.Load("/base/1/2")
+	assert.False(t, ok)
+}
+
+func newDedupFilesMetadata() FilesMetadataDto {
+	filesMeta := FilesMetadataDto{Files: make(internal.BackupFileList)}
+	filesMeta.Files["/base/1/1"] = internal.BackupFileDescription{Size: 8}
+	filesMeta.Files["/base/1/2"] = internal.BackupFileDescription{Size: 8, DedupOf: "/base/1/1"}
+	filesMeta.Files["/base/2/1"] = internal.BackupFileDescription{Size: 8, DedupOf: "/base/1/1"}
+	return filesMeta
+}
+
+func TestFileTarInterpreter_RestoresDedupCopies(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		filesToUnwrap map[string]bool
+		restored      []string
+		notRestored   []string
+	}{
+		"all files":        {nil, []string{"/base/1/1", "/base/1/2", "/base/2/1"}, nil},
+		"only one copy":    {map[string]bool{"/base/2/1": true}, []string{"/base/2/1"}, []string{"/base/1/1", "/base/1/2"}},
+		"only packed file": {map[string]bool{"/base/1/1": true}, []string{"/base/1/1"}, []string{"/base/1/2", "/base/2/1"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dbDirectory := t.TempDir()
+			tarInterpreter := NewFileTarInterpreter(dbDirectory, BackupSentinelDto{}, newDedupFilesMetadata(),
+				testCase.filesToUnwrap, false)
+			err := tarInterpreter.Interpret(bytes.NewBufferString("template"),
+				&tar.Header{Name: "/base/1/1", Typeflag: tar.TypeReg, Size: 8, Mode: 0600})
+			require.NoError(t, err)
+
+			for _, fileName := range testCase.restored {
+				content, err := os.ReadFile(filepath.Join(dbDirectory, fileName))
+				require.NoError(t, err)
+				assert.Equal(t, "template", string(content))
+			}
+			for _, fileName := range testCase.notRestored {
+				_, err := os.Stat(filepath.Join(dbDirectory, fileName))
+				assert.True(t, os.IsNotExist(err), fileName)
+			}
+		})
+	}
+}
+
+func TestStreamTarInterpreter_StreamsDedupCopies(t *testing.T) {
+	var output bytes.Buffer
+	tarInterpreter := NewStreamTarInterpreter(&output, nil)
+	filesMeta := newDedupFilesMetadata()
+	tarInterpreter.dedupCopies = filesMeta.getDedupCopies()
+	err := tarInterpreter.Interpret(bytes.NewBufferString("template"),
+		&tar.Header{Name: "/base/1/1", Typeflag: tar.TypeReg, Size: 8, Mode: 0600})
+	require.NoError(t, err)
+	require.NoError(t, tarInterpreter.Close())
+
+	streamed := make([]string, 0)
+	tarReader := tar.NewReader(&output)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tarReader)
+		require.NoError(t, err)
+		assert.Equal(t, "template", string(content))
+		streamed = append(streamed, header.Name)
+	}
+	assert.Equal(t, []string{"base/1/1", "base/1/2", "base/2/1"}, streamed)
+}
+
+func TestShouldUnwrapTar_DedupCopies(t *testing.T) {
+	filesMeta := newDedupFilesMetadata()
+	filesMeta.TarFileSets = map[string][]string{"part_1.tar.lz4": {"/base/1/1"}}
+	dedupCopies := filesMeta.getDedupCopies()
+
+	assert.True(t, shouldUnwrapTar("part_1.tar.lz4", filesMeta, map[string]bool{"/base/2/1": true}, dedupCopies))
+	assert.False(t, shouldUnwrapTar("part_1.tar.lz4", filesMeta, map[string]bool{"/base/3/1": true}, dedupCopies))
+}
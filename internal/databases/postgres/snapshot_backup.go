@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+var backupHistoryStopRegexp = regexp.MustCompile(`(?m)^STOP WAL LOCATION: ([0-9A-F]+/[0-9A-F]+) \(file ([0-9A-F]{24})\)$`)
+
+type SnapshotBackupError struct {
+	error
+}
+
+func newSnapshotBackupError(format string, args ...interface{}) SnapshotBackupError {
+	return SnapshotBackupError{errors.Errorf("The snapshot can't be backed up: "+format, args...)}
+}
+
+func (err SnapshotBackupError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// getSnapshotPgInfo reads the version and the system identifier of the cluster from the snapshot
+// of its data directory, the live cluster is not connected to
+func getSnapshotPgInfo(dataDirectory string) (pgInfo BackupPgInfo, err error) {
+	if dataDirectory == "" {
+		return pgInfo, newBackupPushUsageError("Backup from snapshot requires the path of the snapshot, supply [db_directory].")
+	}
+	pgInfo.pgDataDirectory = utility.ResolveSymlink(dataDirectory)
+	tracelog.DebugLogger.Printf("Snapshot datadir: %s", pgInfo.pgDataDirectory)
+
+	content, err := os.ReadFile(filepath.Join(pgInfo.pgDataDirectory, "PG_VERSION"))
+	if err != nil {
+		return pgInfo, newSnapshotBackupError("failed to read PG_VERSION: %v", err)
+	}
+	pgInfo.pgVersion, err = parsePgVersionFile(string(content))
+	if err != nil {
+		return pgInfo, err
+	}
+	tracelog.DebugLogger.Printf("Postgres version: %d", pgInfo.pgVersion)
+
+	pgControlData, err := ExtractPgControl(pgInfo.pgDataDirectory)
+	if err != nil {
+		return pgInfo, newSnapshotBackupError("failed to read pg_control: %v", err)
+	}
+	systemIdentifier := pgControlData.GetSystemIdentifier()
+	pgInfo.systemIdentifier = &systemIdentifier
+	tracelog.DebugLogger.Printf("Postgres SystemIdentifier: %d", systemIdentifier)
+	return pgInfo, nil
+}
+
+// parsePgVersionFile converts the major version of PG_VERSION to the version number of the server,
+// "9.6" is 90600 and "14" is 140000, the minor version is unknown without the server
+func parsePgVersionFile(content string) (int, error) {
+	version := strings.TrimSpace(content)
+	major, minor, hasMinor := strings.Cut(version, ".")
+	majorVersion, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, errors.Errorf("invalid PG_VERSION '%s'", version)
+	}
+	if !hasMinor {
+		return majorVersion * 10000, nil
+	}
+	minorVersion, err := strconv.Atoi(minor)
+	if err != nil {
+		return 0, errors.Errorf("invalid PG_VERSION '%s'", version)
+	}
+	return majorVersion*10000 + minorVersion*100, nil
+}
+
+// checkSnapshotArguments fails on the options which need the connection to the live cluster
+func checkSnapshotArguments(arguments BackupArguments) error {
+	if arguments.fromStdin {
+		return newBackupPushUsageError("Backup from snapshot cannot read the backup from stdin.")
+	}
+	if arguments.tarBallComposerType != RegularComposer || arguments.resumeBackupName != "" {
+		return newBackupPushUsageError("Backup from snapshot is available only for the regular composer without resume.")
+	}
+	if arguments.whileStandby || arguments.skipWalValidation {
+		return newBackupPushUsageError("Backup from snapshot neither pauses the WAL replay nor skips the WAL validation.")
+	}
+	return nil
+}
+
+// startSnapshotBackup names the backup after the backup_label of the snapshot instead of starting the backup
+// on the server. The finish LSN is read from the backup history file archived by pg_backup_stop.
+func (bh *BackupHandler) startSnapshotBackup(folder storage.Folder) error {
+	err := checkSnapshotTablespaces(bh.pgInfo.pgDataDirectory)
+	if err != nil {
+		return err
+	}
+	label, err := os.ReadFile(filepath.Join(bh.pgInfo.pgDataDirectory, BackupLabelFilename))
+	if os.IsNotExist(err) {
+		return newSnapshotBackupError("no %s in the snapshot, write the label returned by pg_backup_stop there",
+			BackupLabelFilename)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", BackupLabelFilename)
+	}
+	match := backupLabelStartRegexp.FindSubmatch(label)
+	if match == nil {
+		return newSnapshotBackupError("no START WAL LOCATION in %s", BackupLabelFilename)
+	}
+	bh.curBackupInfo.startLSN, err = ParseLSN(string(match[1]))
+	if err != nil {
+		return err
+	}
+	bh.curBackupInfo.name = "base_" + string(match[2])
+	// the snapshot has no tablespaces, so there is no tablespace_map
+	bh.curBackupInfo.labelFiles.BackupLabel = string(label)
+
+	historyFileName := backupHistoryFileName(string(match[2]), bh.curBackupInfo.startLSN)
+	bh.curBackupInfo.endLSN, err = readBackupHistoryFinishLsn(folder.GetSubFolder(utility.WalPath), historyFileName)
+	if err != nil {
+		return err
+	}
+	tracelog.DebugLogger.Printf("Backup name: %s\nBackup start LSN: %s\nBackup finish LSN: %s",
+		bh.curBackupInfo.name, bh.curBackupInfo.startLSN, bh.curBackupInfo.endLSN)
+	return nil
+}
+
+// checkSnapshotTablespaces fails on the tablespaces, their symlinks point to the directories of the live cluster
+func checkSnapshotTablespaces(dataDirectory string) error {
+	entries, err := os.ReadDir(filepath.Join(dataDirectory, TablespaceFolder))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to read %s", TablespaceFolder)
+	}
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink != 0 {
+			return newSnapshotBackupError("the tablespace %s is not supported", TablespaceFolder+"/"+entry.Name())
+		}
+	}
+	return nil
+}
+
+// backupHistoryFileName is the name of the file archived by pg_backup_stop,
+// e.g. 000000010000000000000004.00000028.backup
+func backupHistoryFileName(startSegment string, startLSN LSN) string {
+	return fmt.Sprintf("%s.%08X.backup", startSegment, uint64(startLSN)%WalSegmentSize)
+}
+
+func readBackupHistoryFinishLsn(walFolder storage.Folder, historyFileName string) (LSN, error) {
+	reader, err := internal.DownloadAndDecompressStorageFile(walFolder, historyFileName)
+	if _, ok := err.(internal.ArchiveNonExistenceError); ok {
+		return 0, newSnapshotBackupError("the backup history file %s is not archived, stop the backup by "+
+			"pg_backup_stop waiting for the WAL archiving", historyFileName)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to download the backup history file %s", historyFileName)
+	}
+	defer utility.LoggedClose(reader, "")
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read the backup history file %s", historyFileName)
+	}
+	match := backupHistoryStopRegexp.FindSubmatch(content)
+	if match == nil {
+		return 0, newSnapshotBackupError("no STOP WAL LOCATION in the backup history file %s", historyFileName)
+	}
+	return ParseLSN(string(match[1]))
+}
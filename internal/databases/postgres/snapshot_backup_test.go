@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const snapshotBackupLabel = "START WAL LOCATION: 0/4000028 (file 000000010000000000000004)\n" +
+	"CHECKPOINT LOCATION: 0/4000060\nBACKUP METHOD: streamed\nBACKUP FROM: primary\nSTART TIMELINE: 1\n"
+
+func writeTestSnapshot(t *testing.T, label string) string {
+	dataDirectory := t.TempDir()
+	writeTestPgControl(t, dataDirectory, 9876)
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, "PG_VERSION"), []byte("14\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, BackupLabelFilename), []byte(label), 0600))
+	return dataDirectory
+}
+
+func putTestBackupHistoryFile(t *testing.T, folder storage.Folder, name, content string) {
+	var compressed bytes.Buffer
+	writer := lz4.Compressor{}.NewWriter(&compressed)
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, folder.GetSubFolder(utility.WalPath).PutObject(name+".lz4", &compressed))
+}
+
+func TestParsePgVersionFile(t *testing.T) {
+	version, err := parsePgVersionFile("9.6\n")
+	require.NoError(t, err)
+	assert.Equal(t, 90600, version)
+	version, err = parsePgVersionFile("14\n")
+	require.NoError(t, err)
+	assert.Equal(t, 140000, version)
+	_, err = parsePgVersionFile("x")
+	assert.Error(t, err)
+}
+
+func TestGetSnapshotPgInfo(t *testing.T) {
+	dataDirectory := writeTestSnapshot(t, snapshotBackupLabel)
+
+	pgInfo, err := getSnapshotPgInfo(dataDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, 140000, pgInfo.pgVersion)
+	require.NotNil(t, pgInfo.systemIdentifier)
+	assert.Equal(t, uint64(9876), *pgInfo.systemIdentifier)
+
+	_, err = getSnapshotPgInfo("")
+	assert.IsType(t, BackupPushUsageError{}, err)
+}
+
+func TestStartSnapshotBackup(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	putTestBackupHistoryFile(t, folder, "000000010000000000000004.00000028.backup",
+		"START WAL LOCATION: 0/4000028 (file 000000010000000000000004)\n"+
+			"STOP WAL LOCATION: 0/4000138 (file 000000010000000000000004)\n"+
+			"CHECKPOINT LOCATION: 0/4000060\nBACKUP METHOD: streamed\n")
+	bh := &BackupHandler{pgInfo: BackupPgInfo{pgDataDirectory: writeTestSnapshot(t, snapshotBackupLabel)}}
+
+	require.NoError(t, bh.startSnapshotBackup(folder))
+	assert.Equal(t, "base_000000010000000000000004", bh.curBackupInfo.name)
+	assert.Equal(t, LSN(0x4000028), bh.curBackupInfo.startLSN)
+	assert.Equal(t, LSN(0x4000138), bh.curBackupInfo.endLSN)
+}
+
+func TestStartSnapshotBackup_NoBackupHistoryFile(t *testing.T) {
+	bh := &BackupHandler{pgInfo: BackupPgInfo{pgDataDirectory: writeTestSnapshot(t, snapshotBackupLabel)}}
+
+	err := bh.startSnapshotBackup(memory.NewFolder("", memory.NewStorage()))
+	assert.IsType(t, SnapshotBackupError{}, err)
+}
+
+func TestStartSnapshotBackup_NoBackupLabel(t *testing.T) {
+	dataDirectory := writeTestSnapshot(t, snapshotBackupLabel)
+	require.NoError(t, os.Remove(filepath.Join(dataDirectory, BackupLabelFilename)))
+	bh := &BackupHandler{pgInfo: BackupPgInfo{pgDataDirectory: dataDirectory}}
+
+	err := bh.startSnapshotBackup(memory.NewFolder("", memory.NewStorage()))
+	assert.IsType(t, SnapshotBackupError{}, err)
+}
+
+func TestStartSnapshotBackup_Tablespace(t *testing.T) {
+	dataDirectory := writeTestSnapshot(t, snapshotBackupLabel)
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDirectory, TablespaceFolder), 0700))
+	require.NoError(t, os.Symlink(t.TempDir(), filepath.Join(dataDirectory, TablespaceFolder, "16400")))
+	bh := &BackupHandler{pgInfo: BackupPgInfo{pgDataDirectory: dataDirectory}}
+
+	err := bh.startSnapshotBackup(memory.NewFolder("", memory.NewStorage()))
+	assert.IsType(t, SnapshotBackupError{}, err)
+}
+
+func TestCheckSnapshotArguments(t *testing.T) {
+	assert.NoError(t, checkSnapshotArguments(BackupArguments{tarBallComposerType: RegularComposer}))
+	assert.Error(t, checkSnapshotArguments(BackupArguments{tarBallComposerType: RatingComposer}))
+	assert.Error(t, checkSnapshotArguments(BackupArguments{tarBallComposerType: RegularComposer, whileStandby: true}))
+	assert.Error(t, checkSnapshotArguments(BackupArguments{tarBallComposerType: RegularComposer, fromStdin: true}))
+}
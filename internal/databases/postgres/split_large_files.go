@@ -0,0 +1,346 @@
+package postgres
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/internal/limiters"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// splitFilePartSuffix separates the name of the split file and the number of its part in the name of the tar entry
+const splitFilePartSuffix = ".walg_part_"
+
+// enableLargeFilesSplit makes the composer split the large files, only the regular composer supports it
+func enableLargeFilesSplit(tarBallComposerMaker TarBallComposerMaker) error {
+	regularMaker, ok := tarBallComposerMaker.(*RegularTarBallComposerMaker)
+	if !ok {
+		return newBackupPushUsageError("the large files split is supported only by the regular composer")
+	}
+	splitFileSize, err := internal.GetSplitFileSize()
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Splitting the files larger than %d bytes into the parts packed in parallel", splitFileSize)
+	regularMaker.splitFileSize = splitFileSize
+	return nil
+}
+
+// splitFilePartName returns the name of the tar entry of the part of the split file, the parts are numbered from one
+func splitFilePartName(fileName string, index int) string {
+	return fileName + splitFilePartSuffix + strconv.Itoa(index+1)
+}
+
+// splitFileRegions returns the regions of the file split into the parts of the given size, the last part may be shorter
+func splitFileRegions(fileSize, partSize int64) []internal.FileRegion {
+	regions := make([]internal.FileRegion, 0, (fileSize+partSize-1)/partSize)
+	for offset := int64(0); offset < fileSize; offset += partSize {
+		length := partSize
+		if fileSize-offset < partSize {
+			length = fileSize - offset
+		}
+		regions = append(regions, internal.FileRegion{Offset: offset, Length: length})
+	}
+	return regions
+}
+
+// shouldSplitFile returns whether the file is packed in the parts, the increments are always packed whole
+func shouldSplitFile(info *internal.ComposeFileInfo, splitFileSize int64) bool {
+	return splitFileSize > 0 && !info.IsIncremented && info.FileInfo.Size() > splitFileSize
+}
+
+// addSplitFile packs the parts of the large file concurrently, each of them into the tarball dequeued for it
+func (c *RegularTarBallComposer) addSplitFile(info *internal.ComposeFileInfo) {
+	parts := splitFileRegions(info.FileInfo.Size(), c.splitFileSize)
+	tracelog.DebugLogger.Printf("Splitting '%s' into %d parts\n", info.Header.Name, len(parts))
+	c.files.AddFileDescription(info.Header.Name, internal.BackupFileDescription{
+		MTime: info.FileInfo.ModTime(),
+		Size:  info.FileInfo.Size(),
+		Parts: parts,
+	})
+	for index, part := range parts {
+		tarBall, err := c.tarBallQueue.DequeCtx(c.ctx)
+		if err != nil {
+			return
+		}
+		tarBall.SetUp(c.crypter)
+		partHeader := *info.Header
+		partHeader.Name = splitFilePartName(info.Header.Name, index)
+		partHeader.Size = part.Length
+		c.tarFileSets.AddFile(tarBall.Name(), partHeader.Name)
+		part := part
+		c.errorGroup.Go(func() error {
+			err := c.tarFilePacker.PackFilePartIntoTar(info, &partHeader, part, tarBall)
+			if err != nil {
+				return err
+			}
+			return c.tarBallQueue.CheckSizeAndEnqueueBack(tarBall)
+		})
+	}
+	c.splitCount++
+}
+
+// PackFilePartIntoTar packs the region of the file as the tar entry with the given header.
+// The region of the file shrunk during the backup is padded with zeros, as the whole files are.
+func (p *TarBallFilePackerImpl) PackFilePartIntoTar(cfi *internal.ComposeFileInfo, partHeader *tar.Header,
+	part internal.FileRegion, tarBall internal.TarBall) error {
+	file, err := os.Open(cfi.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// the file deleted during the backup is recreated and deleted again by the WAL replay
+			tracelog.WarningLogger.Println(newFileNotExistError(cfi.Path))
+			return nil
+		}
+		return errors.Wrapf(err, "PackFilePartIntoTar: failed to open file '%s'\n", cfi.Path)
+	}
+	defer utility.LoggedClose(file, "")
+	if _, err = file.Seek(part.Offset, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "PackFilePartIntoTar: failed to seek file '%s'\n", cfi.Path)
+	}
+	partReader := &io.LimitedReader{
+		R: io.MultiReader(limiters.NewDiskLimitReader(file), &ioextensions.ZeroReader{}),
+		N: part.Length,
+	}
+	packedPartSize, err := internal.PackFileTo(tarBall, partHeader, partReader)
+	if err != nil {
+		return errors.Wrap(err, "PackFilePartIntoTar: operation failed")
+	}
+	if packedPartSize != partHeader.Size {
+		return newTarSizeError(packedPartSize, partHeader.Size)
+	}
+	BackupPushMetrics.readBytesTotal.Add(float64(packedPartSize))
+	if part.Offset == 0 {
+		BackupPushMetrics.packedFilesTotal.Inc()
+	}
+	return nil
+}
+
+// splitFilePart is the part of the split file packed as the tar entry
+type splitFilePart struct {
+	fileName string
+	fileSize int64
+	index    int
+	parts    []internal.FileRegion
+}
+
+func (part splitFilePart) region() internal.FileRegion {
+	return part.parts[part.index]
+}
+
+// getSplitFilePart returns the part of the split file packed as the tar entry with the given name
+func (dto *FilesMetadataDto) getSplitFilePart(entryName string) (splitFilePart, bool) {
+	suffixIndex := strings.LastIndex(entryName, splitFilePartSuffix)
+	if suffixIndex < 0 {
+		return splitFilePart{}, false
+	}
+	fileName := entryName[:suffixIndex]
+	number, err := strconv.Atoi(entryName[suffixIndex+len(splitFilePartSuffix):])
+	if err != nil {
+		return splitFilePart{}, false
+	}
+	description, ok := dto.Files[fileName]
+	if !ok || number < 1 || number > len(description.Parts) {
+		return splitFilePart{}, false
+	}
+	return splitFilePart{fileName: fileName, fileSize: description.Size, index: number - 1,
+		parts: description.Parts}, true
+}
+
+// getEntryFileName returns the name of the file restored from the tar entry
+func (dto *FilesMetadataDto) getEntryFileName(entryName string) string {
+	if part, ok := dto.getSplitFilePart(entryName); ok {
+		return part.fileName
+	}
+	return entryName
+}
+
+// findSplitFile returns a split file among the files to unwrap, all the files are unwrapped if the map is nil
+func (dto *FilesMetadataDto) findSplitFile(filesToUnwrap map[string]bool) (string, bool) {
+	for fileName, description := range dto.Files {
+		if len(description.Parts) > 0 && (filesToUnwrap == nil || filesToUnwrap[fileName]) {
+			return fileName, true
+		}
+	}
+	return "", false
+}
+
+func newSplitFileError(fileName, action string) error {
+	return errors.Errorf("file '%s' is split into the parts by backup-push --split-large-files, "+
+		"it can't be %s", fileName, action)
+}
+
+// checkSplitFileParts makes sure the parts don't overlap, so the concurrent writes of the parts never touch the same bytes
+func checkSplitFileParts(fileName string, fileSize int64, parts []internal.FileRegion) error {
+	var end int64
+	for _, part := range parts {
+		if part.Offset < end || part.Length < 0 {
+			return errors.Errorf("the parts of the split file '%s' overlap at offset %d", fileName, part.Offset)
+		}
+		end = part.Offset + part.Length
+	}
+	if end > fileSize {
+		return errors.Errorf("the parts of the split file '%s' exceed its size %d", fileName, fileSize)
+	}
+	return nil
+}
+
+// splitFilesRestore coordinates the parts of the split files, which are extracted from the different tar members
+// concurrently. The first part to arrive creates the file of the full size, then the parts write their own regions
+// through the separate descriptors and the file is completed once all of its parts are written.
+type splitFilesRestore struct {
+	mutex sync.Mutex
+	files map[string]*splitFileRestore
+}
+
+type splitFileRestore struct {
+	mutex        sync.Mutex
+	created      bool
+	writtenParts map[int]bool
+	completed    bool
+}
+
+func (restore *splitFilesRestore) getFile(fileName string) *splitFileRestore {
+	restore.mutex.Lock()
+	defer restore.mutex.Unlock()
+	if restore.files == nil {
+		restore.files = make(map[string]*splitFileRestore)
+	}
+	file, ok := restore.files[fileName]
+	if !ok {
+		file = &splitFileRestore{writtenParts: make(map[int]bool)}
+		restore.files[fileName] = file
+	}
+	return file
+}
+
+// create creates the file of the full size once, the parts are then written into it concurrently
+func (file *splitFileRestore) create(targetPath string, part splitFilePart, mode os.FileMode) error {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	if file.created {
+		return nil
+	}
+	err := checkSplitFileParts(part.fileName, part.fileSize, part.parts)
+	if err != nil {
+		return err
+	}
+	if useNewUnwrapImplementation {
+		// the reverse delta unpack restores the newer backups first, their blocks can't be merged with the parts
+		if _, err = os.Stat(targetPath); err == nil {
+			return newSplitFileError(part.fileName, "merged with the newer backups by the reverse delta unpack")
+		}
+	}
+	err = PrepareDirs(part.fileName, targetPath)
+	if err != nil {
+		return errors.Wrap(err, "Interpret: failed to create all directories")
+	}
+	localFile, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create new file: '%s'", targetPath)
+	}
+	defer utility.LoggedClose(localFile, "")
+	if err = localFile.Truncate(part.fileSize); err != nil {
+		return errors.Wrapf(err, "failed to allocate file: '%s'", targetPath)
+	}
+	if err = localFile.Chmod(mode); err != nil {
+		return errors.Wrap(err, "Interpret: chmod failed")
+	}
+	file.created = true
+	return nil
+}
+
+// addWrittenPart records the written part and returns whether the file is completed by it,
+// the part written again by the retried tar member is counted once
+func (file *splitFileRestore) addWrittenPart(part splitFilePart) bool {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	file.writtenParts[part.index] = true
+	if file.completed || len(file.writtenParts) < len(part.parts) {
+		return false
+	}
+	file.completed = true
+	return true
+}
+
+// writeSplitFilePart writes the part at its offset of the created file
+func writeSplitFilePart(fileReader io.Reader, targetPath string, part splitFilePart, fsync bool) error {
+	region := part.region()
+	localFile, err := os.OpenFile(targetPath, os.O_WRONLY, 0)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file: '%s'", targetPath)
+	}
+	defer utility.LoggedClose(localFile, "")
+	if _, err = localFile.Seek(region.Offset, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "failed to seek file: '%s'", targetPath)
+	}
+	written, err := io.Copy(localFile, io.LimitReader(fileReader, region.Length))
+	if err != nil {
+		return errors.Wrapf(err, "Interpret: failed to write part %d of '%s'", part.index+1, part.fileName)
+	}
+	if written != region.Length {
+		return errors.Errorf("Interpret: part %d of '%s' is %d bytes, expected %d",
+			part.index+1, part.fileName, written, region.Length)
+	}
+	if fsync {
+		return errors.Wrap(localFile.Sync(), "Interpret: fsync failed")
+	}
+	return nil
+}
+
+// interpretSplitFilePart restores the part of the split file packed as the tar entry
+func (tarInterpreter *FileTarInterpreter) interpretSplitFilePart(fileReader io.Reader, partHeader *tar.Header,
+	part splitFilePart) error {
+	if tarInterpreter.FilesToUnwrap != nil && !tarInterpreter.FilesToUnwrap[part.fileName] {
+		tracelog.DebugLogger.Printf("Don't have to unwrap '%s' this time\n", part.fileName)
+		return nil
+	}
+	tracelog.DebugLogger.Printf("Interpreting part %d of %s\n", part.index+1, part.fileName)
+	targetPath := path.Join(tarInterpreter.DBDataDirectory, part.fileName)
+	fsync := !viper.GetBool(internal.TarDisableFsyncSetting)
+	if tarInterpreter.inplace != nil {
+		tarInterpreter.inplace.addRestoredFile(part.fileName)
+	}
+	if fsync {
+		tarInterpreter.extractedDirs.add(tarInterpreter.DBDataDirectory, targetPath)
+	}
+	fileHeader := *partHeader
+	fileHeader.Name = part.fileName
+	fileHeader.Size = part.fileSize
+
+	if !tarInterpreter.RestoreFilter.ShouldRestoreData(part.fileName) {
+		if part.index != 0 {
+			return nil
+		}
+		err := tarInterpreter.unwrapSkippedFile(&fileHeader, targetPath, fsync)
+		if err != nil {
+			return err
+		}
+		return tarInterpreter.Ownership.apply(tarInterpreter.DBDataDirectory, targetPath, &fileHeader)
+	}
+
+	splitFile := tarInterpreter.splitFiles.getFile(part.fileName)
+	err := splitFile.create(targetPath, part, os.FileMode(fileHeader.Mode))
+	if err != nil {
+		return err
+	}
+	err = writeSplitFilePart(fileReader, targetPath, part, fsync)
+	if err != nil {
+		return err
+	}
+	if !splitFile.addWrittenPart(part) {
+		return nil
+	}
+	tracelog.DebugLogger.Printf("All %d parts of '%s' are restored\n", len(part.parts), part.fileName)
+	tarInterpreter.addToCompletedFiles(part.fileName)
+	return tarInterpreter.Ownership.apply(tarInterpreter.DBDataDirectory, targetPath, &fileHeader)
+}
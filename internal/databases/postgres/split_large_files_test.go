@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+const splitBackupName = "base_000000010000000000000004"
+
+func TestSplitFileRegions(t *testing.T) {
+	assert.Equal(t, []internal.FileRegion{{Offset: 0, Length: 4}, {Offset: 4, Length: 4}, {Offset: 8, Length: 2}},
+		splitFileRegions(10, 4))
+	assert.Equal(t, []internal.FileRegion{{Offset: 0, Length: 4}, {Offset: 4, Length: 4}}, splitFileRegions(8, 4))
+}
+
+func TestSplitLargeFiles_PackAndRestore(t *testing.T) {
+	data := t.TempDir()
+	largeContent := make([]byte, 10000)
+	for i := range largeContent {
+		largeContent[i] = byte(i % 251)
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(data, "base", "5"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(data, "base", "5", "16384"), largeContent, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(data, "base", "5", "16385"), []byte("small file"), 0600))
+
+	folder := memory.NewFolder("", memory.NewStorage())
+	bundle := NewBundle(data, nil, nil, nil, false, int64(10))
+	require.NoError(t, bundle.StartQueue(internal.NewStorageTarBallMaker(splitBackupName,
+		internal.NewUploader(lz4.Compressor{}, folder))))
+	maker := NewRegularTarBallComposerMaker(NewTarBallFilePackerOptions(false, false, 1),
+		&internal.RegularBundleFiles{}, internal.NewRegularTarFileSets())
+	maker.splitFileSize = 3000
+	require.NoError(t, bundle.SetupComposer(maker))
+	require.NoError(t, bundle.Walk())
+	tarFileSets, err := bundle.FinishTarComposer()
+	require.NoError(t, err)
+	require.NoError(t, bundle.FinishQueue())
+
+	filesMeta := FilesMetadataDto{Files: make(internal.BackupFileList), TarFileSets: tarFileSets.Get()}
+	bundle.GetFiles().Range(func(name, description interface{}) bool {
+		filesMeta.Files[name.(string)] = description.(internal.BackupFileDescription)
+		return true
+	})
+	assert.Len(t, filesMeta.Files["/base/5/16384"].Parts, 4)
+	assert.Empty(t, filesMeta.Files["/base/5/16385"].Parts)
+	partTars := make(map[string]string)
+	for tarName, entries := range filesMeta.TarFileSets {
+		for _, entryName := range entries {
+			if part, ok := filesMeta.getSplitFilePart(entryName); ok {
+				assert.Equal(t, "/base/5/16384", part.fileName)
+				partTars[entryName] = tarName
+				assert.True(t, shouldUnwrapTar(tarName, filesMeta, map[string]bool{"/base/5/16384": true}, nil))
+			}
+		}
+	}
+	// each part is packed into its own tar member
+	assert.Len(t, partTars, 4)
+	assert.Len(t, map[string]bool{partTars["/base/5/16384.walg_part_1"]: true, partTars["/base/5/16384.walg_part_2"]: true,
+		partTars["/base/5/16384.walg_part_3"]: true, partTars["/base/5/16384.walg_part_4"]: true}, 4)
+
+	restored := t.TempDir()
+	tarInterpreter := NewFileTarInterpreter(restored, BackupSentinelDto{}, filesMeta, nil, false)
+	backup := NewBackup(folder, splitBackupName)
+	tarsToExtract, _, err := backup.getTarsToExtract(filesMeta, nil, false)
+	require.NoError(t, err)
+	// the tar members are extracted concurrently
+	require.NoError(t, internal.ExtractAll(tarInterpreter, tarsToExtract))
+
+	content, err := os.ReadFile(filepath.Join(restored, "base", "5", "16384"))
+	require.NoError(t, err)
+	assert.Equal(t, largeContent, content)
+	content, err = os.ReadFile(filepath.Join(restored, "base", "5", "16385"))
+	require.NoError(t, err)
+	assert.Equal(t, "small file", string(content))
+	assert.NoFileExists(t, filepath.Join(restored, "base", "5", "16384.walg_part_1"))
+	assert.Equal(t, 1, countCompletedFile(tarInterpreter.UnwrapResult, "/base/5/16384"))
+}
+
+func countCompletedFile(result *UnwrapResult, fileName string) (count int) {
+	for _, completedFile := range result.completedFiles {
+		if completedFile == fileName {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSplitLargeFiles_RestoreRetriedPart(t *testing.T) {
+	filesMeta := FilesMetadataDto{Files: internal.BackupFileList{"/base/5/16384": {Size: 6,
+		Parts: []internal.FileRegion{{Offset: 0, Length: 3}, {Offset: 3, Length: 3}}}}}
+	restored := t.TempDir()
+	tarInterpreter := NewFileTarInterpreter(restored, BackupSentinelDto{}, filesMeta, nil, false)
+
+	for _, part := range []struct{ name, content string }{
+		{"/base/5/16384.walg_part_2", "def"},
+		// the tar member of the part is extracted again after the retry
+		{"/base/5/16384.walg_part_2", "def"},
+		{"/base/5/16384.walg_part_1", "abc"},
+	} {
+		err := tarInterpreter.Interpret(bytes.NewBufferString(part.content),
+			&tar.Header{Name: part.name, Typeflag: tar.TypeReg, Size: 3, Mode: 0600})
+		require.NoError(t, err)
+	}
+	content, err := os.ReadFile(filepath.Join(restored, "base", "5", "16384"))
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef", string(content))
+	assert.Equal(t, 1, countCompletedFile(tarInterpreter.UnwrapResult, "/base/5/16384"))
+}
+
+func TestSplitLargeFiles_OverlappingParts(t *testing.T) {
+	filesMeta := FilesMetadataDto{Files: internal.BackupFileList{"/base/5/16384": {Size: 6,
+		Parts: []internal.FileRegion{{Offset: 0, Length: 4}, {Offset: 3, Length: 3}}}}}
+	tarInterpreter := NewFileTarInterpreter(t.TempDir(), BackupSentinelDto{}, filesMeta, nil, false)
+
+	err := tarInterpreter.Interpret(bytes.NewBufferString("abcd"),
+		&tar.Header{Name: "/base/5/16384.walg_part_1", Typeflag: tar.TypeReg, Size: 4, Mode: 0600})
+	assert.Error(t, err)
+}
+
+func TestSplitLargeFiles_NotStreamed(t *testing.T) {
+	filesMeta := FilesMetadataDto{Files: internal.BackupFileList{"/base/5/16384": {Size: 6,
+		Parts: []internal.FileRegion{{Offset: 0, Length: 3}, {Offset: 3, Length: 3}}}}}
+	backup := NewBackup(nil, splitBackupName)
+
+	err := backup.unwrapToStream(io.Discard, filesMeta, nil)
+	assert.Error(t, err)
+	_, _, err = backup.findFileTar(filesMeta, "/base/5/16384")
+	assert.Error(t, err)
+}
@@ -0,0 +1,281 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/utility"
+)
+
+var backupLabelStartRegexp = regexp.MustCompile(`(?m)^START WAL LOCATION: ([0-9A-F]+/[0-9A-F]+) \(file ([0-9A-F]{24})\)$`)
+
+type StdinBackupFormatError struct {
+	error
+}
+
+func newStdinBackupFormatError(format string, args ...interface{}) StdinBackupFormatError {
+	return StdinBackupFormatError{errors.Errorf("The tar stream is not made by pg_basebackup --format=tar -D -: "+
+		format, args...)}
+}
+
+func (err StdinBackupFormatError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// StdinBaseBackup re-frames the tar stream of the base tablespace made by pg_basebackup into the tar members
+// of WAL-G. PostgreSQL sends backup_label first, so it names the backup before any tar member is uploaded.
+// pg_control is kept aside and uploaded last, as the backup-push of the data directory does.
+type StdinBaseBackup struct {
+	input        *tar.Reader
+	tarBallQueue *internal.TarBallQueue
+	crypter      crypto.Crypter
+	Files        internal.BundleFiles
+	TarFileSets  internal.TarFileSets
+
+	Name     string
+	StartLSN LSN
+
+	labelHeader     *tar.Header
+	label           []byte
+	pgControlHeader *tar.Header
+	pgControl       []byte
+}
+
+func NewStdinBaseBackup(input io.Reader, crypter crypto.Crypter, files internal.BundleFiles,
+	tarFileSets internal.TarFileSets) *StdinBaseBackup {
+	return &StdinBaseBackup{
+		input:       tar.NewReader(input),
+		crypter:     crypter,
+		Files:       files,
+		TarFileSets: tarFileSets,
+	}
+}
+
+// ReadBackupLabel reads backup_label at the beginning of the stream and names the backup after its start WAL segment
+func (backup *StdinBaseBackup) ReadBackupLabel() error {
+	header, err := backup.input.Next()
+	if err == io.EOF {
+		return newStdinBackupFormatError("the stream is empty")
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read the tar stream")
+	}
+	if header.Name != BackupLabelFilename {
+		return newStdinBackupFormatError("%s is expected first, but %s is found", BackupLabelFilename, header.Name)
+	}
+	label, err := io.ReadAll(backup.input)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", BackupLabelFilename)
+	}
+	match := backupLabelStartRegexp.FindSubmatch(label)
+	if match == nil {
+		return newStdinBackupFormatError("no START WAL LOCATION in %s", BackupLabelFilename)
+	}
+	backup.StartLSN, err = ParseLSN(string(match[1]))
+	if err != nil {
+		return err
+	}
+	backup.Name = "base_" + string(match[2])
+	// backup_label is packed into the first tar member as any other file
+	backup.labelHeader, backup.label = header, label
+	return nil
+}
+
+// Upload packs the files of the stream into the tar members until the end of the stream. The tar members
+// are uploaded in the background, the caller waits for the uploader to finish.
+func (backup *StdinBaseBackup) Upload(ctx context.Context, tarBallQueue *internal.TarBallQueue) error {
+	backup.tarBallQueue = tarBallQueue
+	err := backup.packFile(ctx, backup.labelHeader, bytes.NewReader(backup.label))
+	if err != nil {
+		return err
+	}
+
+	for {
+		header, err := backup.input.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read the tar stream")
+		}
+		// the files are named as in the regular backups: relative to the data directory, with the leading separator
+		header.Name = utility.PathSeparator + strings.TrimSuffix(strings.TrimPrefix(header.Name, "./"), "/")
+		if header.Name == PgControlPath {
+			backup.pgControlHeader = header
+			backup.pgControl, err = io.ReadAll(backup.input)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s", header.Name)
+			}
+			continue
+		}
+		isTablespace := strings.HasPrefix(header.Name, utility.PathSeparator+TablespaceFolder+utility.PathSeparator)
+		if isTablespace && header.Typeflag == tar.TypeSymlink {
+			return newStdinBackupFormatError("the tablespace %s can't be streamed to stdin", header.Name)
+		}
+		err = backup.packFile(ctx, header, backup.input)
+		if err != nil {
+			return err
+		}
+	}
+	if backup.pgControlHeader == nil {
+		return newStdinBackupFormatError("no %s in the stream", strings.TrimPrefix(PgControlPath, "/"))
+	}
+	return nil
+}
+
+func (backup *StdinBaseBackup) packFile(ctx context.Context, header *tar.Header, content io.Reader) error {
+	tarBall, err := backup.tarBallQueue.DequeCtx(ctx)
+	if err != nil {
+		return err
+	}
+	tarBall.SetUp(backup.crypter)
+	backup.TarFileSets.AddFile(tarBall.Name(), header.Name)
+	backup.Files.AddFile(header, header.FileInfo(), false)
+	_, err = internal.PackFileTo(tarBall, header, content)
+	if err != nil {
+		return errors.Wrapf(err, "failed to pack %s", header.Name)
+	}
+	return backup.tarBallQueue.CheckSizeAndEnqueueBack(tarBall)
+}
+
+// UploadPgControl uploads pg_control of the stream into its own tar member, once all the other tar members are uploaded
+func (backup *StdinBaseBackup) UploadPgControl(compressorFileExtension string) error {
+	tarBall := backup.tarBallQueue.NewTarBall(false)
+	tarBall.SetUp(backup.crypter, "pg_control.tar."+compressorFileExtension)
+	_, err := internal.PackFileTo(tarBall, backup.pgControlHeader, bytes.NewReader(backup.pgControl))
+	if err != nil {
+		return errors.Wrap(err, "UploadPgControl: failed to pack pg_control")
+	}
+	return backup.tarBallQueue.CloseTarball(tarBall)
+}
+
+// createAndPushStdinBackup uploads the base backup made by pg_basebackup --format=tar -D - and read from stdin.
+// The finish LSN is not in the stream, so the current LSN of the server is recorded once the stream is over.
+func (bh *BackupHandler) createAndPushStdinBackup(input io.Reader) (BackupSentinelDto, error) {
+	folder := bh.workers.uploader.UploadingFolder
+	bh.workers.uploader.UploadingFolder = folder.GetSubFolder(bh.arguments.backupsFolder)
+	tracelog.DebugLogger.Printf("Uploading folder: %s", bh.workers.uploader.UploadingFolder)
+	tarSizeThreshold, err := internal.GetTarSizeThreshold()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+
+	var bundleFiles internal.BundleFiles = &internal.RegularBundleFiles{}
+	if bh.arguments.withoutFilesMetadata {
+		bundleFiles = &internal.NopBundleFiles{}
+	}
+	backup := NewStdinBaseBackup(input, internal.ConfigureCrypter(), bundleFiles, internal.NewRegularTarFileSets())
+	tracelog.InfoLogger.Println("Reading the base backup from stdin")
+	err = backup.ReadBackupLabel()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	bh.curBackupInfo.name = backup.Name
+	bh.curBackupInfo.startLSN = backup.StartLSN
+	bh.curBackupInfo.labelFiles.BackupLabel = string(backup.label)
+	tracelog.DebugLogger.Printf("Backup name: %s\nBackup start LSN: %s", backup.Name, backup.StartLSN)
+
+	tarBallQueue := internal.NewTarBallQueue(tarSizeThreshold,
+		internal.NewStorageTarBallMaker(backup.Name, bh.workers.uploader.Uploader))
+	err = tarBallQueue.StartQueue()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = backup.Upload(bh.ctx, tarBallQueue)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = tarBallQueue.FinishQueue()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = bh.checkStdinSystemIdentifier(backup.pgControl)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	err = backup.UploadPgControl(bh.workers.uploader.Compressor.FileExtension())
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	bh.workers.uploader.Finish()
+	if bh.workers.uploader.Failed.Load().(bool) {
+		return BackupSentinelDto{}, newBackupUploadError(backup.Name)
+	}
+	err = checkTarsUploaded(NewBackup(bh.workers.uploader.UploadingFolder, backup.Name), backup.TarFileSets)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+
+	err = bh.readStdinBackupFinishLsn()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+	bh.curBackupInfo.uncompressedSize = *tarBallQueue.AllTarballsSize
+	bh.curBackupInfo.compressedSize, err = bh.workers.uploader.UploadedDataSize()
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+
+	sentinelDto := NewBackupSentinelDto(bh, nil)
+	var filesMeta FilesMetadataDto
+	filesMeta.setFiles(backup.Files.GetUnderlyingMap())
+	filesMeta.TarFileSets = backup.TarFileSets.Get()
+	filesMeta.TarChecksums = bh.workers.uploader.TarChecksums()
+	if bh.arguments.withoutFilesMetadata {
+		sentinelDto.TarChecksums = filesMeta.TarChecksums
+	}
+	filesMeta.DatabasesByNames = bh.collectDatabasesByNames()
+	filesMeta.CatalogRelFileNodes = bh.collectCatalogRelFileNodes()
+	err = bh.uploadMetadata(sentinelDto, filesMeta)
+	if err != nil {
+		return BackupSentinelDto{}, err
+	}
+
+	internal.AddLogFields(internal.LogFields{"backup_name": bh.curBackupInfo.name})
+	internal.InfoLogWithFields(internal.LogFields{
+		"uncompressed_size": bh.curBackupInfo.uncompressedSize,
+		"compressed_size":   bh.curBackupInfo.compressedSize,
+	}, "Wrote backup with name %s", bh.curBackupInfo.name)
+	return sentinelDto, nil
+}
+
+// checkStdinSystemIdentifier checks that the stream is the backup of the cluster WAL-G is configured for
+func (bh *BackupHandler) checkStdinSystemIdentifier(pgControl []byte) error {
+	pgControlData, err := extractPgControlData(bytes.NewReader(pgControl))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse pg_control of the stream")
+	}
+	if bh.pgInfo.systemIdentifier != nil && *bh.pgInfo.systemIdentifier != pgControlData.GetSystemIdentifier() {
+		return errors.Errorf("The system identifier of the stream %d differs from the one of the server %d",
+			pgControlData.GetSystemIdentifier(), *bh.pgInfo.systemIdentifier)
+	}
+	return nil
+}
+
+// readStdinBackupFinishLsn records the current LSN of the server as the finish LSN of the backup.
+// pg_basebackup stops the backup before the stream is over, so the current LSN is not less than the real one.
+func (bh *BackupHandler) readStdinBackupFinishLsn() error {
+	conn, err := Connect()
+	if err != nil {
+		return err
+	}
+	bh.workers.queryRunner, err = NewPgQueryRunner(conn)
+	if err != nil {
+		return fmt.Errorf("failed to build query runner: %v", err)
+	}
+	lsnStr, err := bh.workers.queryRunner.getCurrentLsn()
+	if err != nil {
+		return err
+	}
+	bh.curBackupInfo.endLSN, err = ParseLSN(lsnStr)
+	return err
+}
@@ -0,0 +1,99 @@
+package postgres_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/testtools"
+)
+
+const stdinBackupLabel = "START WAL LOCATION: 0/2000028 (file 000000010000000000000002)\n" +
+	"CHECKPOINT LOCATION: 0/2000060\nBACKUP METHOD: streamed\nBACKUP FROM: primary\nSTART TIMELINE: 1\n"
+
+func makeBaseBackupTar(t *testing.T, files map[string]string, order []string) *bytes.Buffer {
+	var buffer bytes.Buffer
+	tarWriter := tar.NewWriter(&buffer)
+	for _, name := range order {
+		if strings.HasSuffix(name, "/") {
+			require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0700}))
+			continue
+		}
+		content := files[name]
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0600,
+			Size: int64(len(content))}))
+		_, err := tarWriter.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	return &buffer
+}
+
+func TestStdinBaseBackup_Upload(t *testing.T) {
+	files := map[string]string{
+		"backup_label":      stdinBackupLabel,
+		"PG_VERSION":        "15\n",
+		"base/1/1":          strings.Repeat("a", 8192),
+		"base/1/2":          strings.Repeat("b", 8192),
+		"global/pg_control": strings.Repeat("\x00", 8192),
+	}
+	input := makeBaseBackupTar(t, files,
+		[]string{"backup_label", "PG_VERSION", "base/", "base/1/", "base/1/1", "global/", "global/pg_control", "base/1/2"})
+
+	storage := memory.NewStorage()
+	uploader := testtools.NewStoringMockUploader(storage, nil)
+	backup := postgres.NewStdinBaseBackup(input, nil, &internal.RegularBundleFiles{}, internal.NewRegularTarFileSets())
+	require.NoError(t, backup.ReadBackupLabel())
+	assert.Equal(t, "base_000000010000000000000002", backup.Name)
+	assert.Equal(t, postgres.LSN(0x2000028), backup.StartLSN)
+
+	tarBallQueue := internal.NewTarBallQueue(1, internal.NewStorageTarBallMaker(backup.Name, uploader))
+	require.NoError(t, tarBallQueue.StartQueue())
+	require.NoError(t, backup.Upload(context.Background(), tarBallQueue))
+	require.NoError(t, tarBallQueue.FinishQueue())
+	require.NoError(t, backup.UploadPgControl(uploader.Compressor.FileExtension()))
+	uploader.Finish()
+
+	packedFiles := make(map[string]string)
+	for tarName, tarFiles := range backup.TarFileSets.Get() {
+		for _, fileName := range tarFiles {
+			packedFiles[fileName] = tarName
+		}
+		_, exists := storage.Load("in_memory/" + backup.Name + internal.TarPartitionFolderName + tarName)
+		assert.True(t, exists, tarName)
+	}
+	// every file exceeds the threshold, so the files are packed into the different tar members
+	assert.Len(t, packedFiles, 7)
+	assert.NotEqual(t, packedFiles["/base/1/1"], packedFiles["/base/1/2"])
+	assert.Contains(t, packedFiles, "/PG_VERSION")
+	assert.NotContains(t, packedFiles, "/global/pg_control")
+	_, exists := storage.Load("in_memory/" + backup.Name + internal.TarPartitionFolderName +
+		"pg_control.tar." + uploader.Compressor.FileExtension())
+	assert.True(t, exists)
+
+	description, ok := backup.Files.GetUnderlyingMap().Load("/base/1/2")
+	require.True(t, ok)
+	assert.Equal(t, int64(8192), description.(internal.BackupFileDescription).Size)
+}
+
+func TestStdinBaseBackup_NotBaseBackup(t *testing.T) {
+	input := makeBaseBackupTar(t, map[string]string{"PG_VERSION": "15\n"}, []string{"PG_VERSION"})
+	backup := postgres.NewStdinBaseBackup(input, nil, &internal.RegularBundleFiles{}, internal.NewRegularTarFileSets())
+	assert.IsType(t, postgres.StdinBackupFormatError{}, backup.ReadBackupLabel())
+
+	input = makeBaseBackupTar(t, map[string]string{"backup_label": stdinBackupLabel}, []string{"backup_label"})
+	backup = postgres.NewStdinBaseBackup(input, nil, &internal.RegularBundleFiles{}, internal.NewRegularTarFileSets())
+	require.NoError(t, backup.ReadBackupLabel())
+	tarBallQueue := internal.NewTarBallQueue(1, internal.NewStorageTarBallMaker(backup.Name,
+		testtools.NewStoringMockUploader(memory.NewStorage(), nil)))
+	require.NoError(t, tarBallQueue.StartQueue())
+	// the stream without pg_control is not the complete backup
+	assert.IsType(t, postgres.StdinBackupFormatError{}, backup.Upload(context.Background(), tarBallQueue))
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+// StreamTarInterpreter writes the extracted backup files into a single tar stream
+// instead of the data directory. The member names are relative to the data directory.
+type StreamTarInterpreter struct {
+	FilesToUnwrap map[string]bool
+	// dedupCopies are the deduplicated copies of the files streamed after their tar members
+	dedupCopies map[string][]string
+
+	tarWriter *tar.Writer
+	// the members of the different tarballs must not interleave in the stream
+	mutex sync.Mutex
+}
+
+func NewStreamTarInterpreter(output io.Writer, filesToUnwrap map[string]bool) *StreamTarInterpreter {
+	return &StreamTarInterpreter{FilesToUnwrap: filesToUnwrap, tarWriter: tar.NewWriter(output)}
+}
+
+// Interpret writes the tar member to the output stream
+func (tarInterpreter *StreamTarInterpreter) Interpret(fileReader io.Reader, fileInfo *tar.Header) error {
+	copies, ok := tarInterpreter.dedupCopies[fileInfo.Name]
+	if ok && (fileInfo.Typeflag == tar.TypeReg || fileInfo.Typeflag == tar.TypeRegA) {
+		return tarInterpreter.streamWithDedupCopies(fileReader, fileInfo, copies)
+	}
+	return tarInterpreter.streamMember(fileReader, fileInfo)
+}
+
+func (tarInterpreter *StreamTarInterpreter) streamMember(fileReader io.Reader, fileInfo *tar.Header) error {
+	tracelog.DebugLogger.Println("Streaming: ", fileInfo.Name)
+	header := *fileInfo
+	switch header.Typeflag {
+	case tar.TypeReg, tar.TypeRegA:
+		if tarInterpreter.FilesToUnwrap != nil && !tarInterpreter.FilesToUnwrap[header.Name] {
+			tracelog.DebugLogger.Printf("Don't have to stream '%s'\n", header.Name)
+			return nil
+		}
+		header.Typeflag = tar.TypeReg
+	case tar.TypeDir, tar.TypeLink, tar.TypeSymlink:
+	default:
+		return nil
+	}
+	header.Name = strings.TrimPrefix(header.Name, "/")
+	if header.Name == "" {
+		return nil
+	}
+	if header.Typeflag == tar.TypeDir && !strings.HasSuffix(header.Name, "/") {
+		header.Name += "/"
+	}
+
+	tarInterpreter.mutex.Lock()
+	defer tarInterpreter.mutex.Unlock()
+	err := tarInterpreter.tarWriter.WriteHeader(&header)
+	if err != nil {
+		return errors.Wrapf(err, "Interpret: failed to write the header of '%s'", header.Name)
+	}
+	if header.Typeflag == tar.TypeReg {
+		_, err = io.CopyN(tarInterpreter.tarWriter, fileReader, header.Size)
+		if err != nil {
+			return errors.Wrapf(err, "Interpret: failed to stream '%s'", header.Name)
+		}
+	}
+	return nil
+}
+
+// streamWithDedupCopies writes the file and its deduplicated copies with the contents of the same tar member
+func (tarInterpreter *StreamTarInterpreter) streamWithDedupCopies(fileReader io.Reader, fileInfo *tar.Header,
+	copies []string) error {
+	content, err := io.ReadAll(fileReader)
+	if err != nil {
+		return errors.Wrapf(err, "Interpret: failed to read %s", fileInfo.Name)
+	}
+	for _, fileName := range append([]string{fileInfo.Name}, copies...) {
+		header := *fileInfo
+		header.Name = fileName
+		err = tarInterpreter.streamMember(bytes.NewReader(content), &header)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes the tar stream, the underlying output is not closed
+func (tarInterpreter *StreamTarInterpreter) Close() error {
+	tarInterpreter.mutex.Lock()
+	defer tarInterpreter.mutex.Unlock()
+	return tarInterpreter.tarWriter.Close()
+}
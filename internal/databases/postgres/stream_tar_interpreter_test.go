@@ -0,0 +1,52 @@
+package postgres_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+func TestStreamTarInterpreter_Interpret(t *testing.T) {
+	var output bytes.Buffer
+	interpreter := postgres.NewStreamTarInterpreter(&output, map[string]bool{"/base/1/1259": true})
+
+	members := []struct {
+		header  *tar.Header
+		content string
+	}{
+		{&tar.Header{Name: "/pg_wal", Typeflag: tar.TypeDir, Mode: 0700}, ""},
+		{&tar.Header{Name: "/base/1/1259", Typeflag: tar.TypeReg, Mode: 0600, Size: 4}, "data"},
+		{&tar.Header{Name: "/base/1/2608", Typeflag: tar.TypeReg, Mode: 0600, Size: 5}, "other"},
+		{&tar.Header{Name: "/pg_tblspc/16400", Typeflag: tar.TypeSymlink, Linkname: "/mnt/tbs"}, ""},
+	}
+	for _, member := range members {
+		err := interpreter.Interpret(strings.NewReader(member.content), member.header)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, interpreter.Close())
+
+	reader := tar.NewReader(&output)
+	var names []string
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, header.Name)
+		if header.Name == "base/1/1259" {
+			content, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Equal(t, "data", string(content))
+		}
+		if header.Name == "pg_tblspc/16400" {
+			assert.Equal(t, "/mnt/tbs", header.Linkname)
+		}
+	}
+	assert.Equal(t, []string{"pg_wal/", "base/1/1259", "pg_tblspc/16400"}, names)
+}
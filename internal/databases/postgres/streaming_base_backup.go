@@ -216,7 +216,9 @@ func (bb *StreamingBaseBackup) streamFromPostgres() (err error) {
 		if pgconn.Timeout(err) {
 			continue
 		}
-		tracelog.ErrorLogger.FatalOnError(err)
+		if err != nil {
+			return err
+		}
 		switch msg := message.(type) {
 		case *pgproto3.CopyData:
 			bb.buffer = msg.Data
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type InvalidFollowedSymlinkError struct {
+	error
+}
+
+func newInvalidFollowedSymlinkError(symlinkPath string) InvalidFollowedSymlinkError {
+	return InvalidFollowedSymlinkError{
+		errors.Errorf("followed symlink '%s' must be relative to the data directory", symlinkPath)}
+}
+
+func (err InvalidFollowedSymlinkError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// ParseFollowedSymlinks parses the comma-separated list of the symlinks relative to the data directory.
+// The paths are returned in the form of the backup file names, e.g. "/pg_wal".
+func ParseFollowedSymlinks(followedSymlinksStr string) (map[string]utility.Empty, error) {
+	followedSymlinks := make(map[string]utility.Empty)
+	for _, symlinkPath := range strings.Split(followedSymlinksStr, ",") {
+		symlinkPath = strings.TrimSpace(symlinkPath)
+		if symlinkPath == "" {
+			continue
+		}
+		relPath, ok := cleanRelPath(symlinkPath)
+		if !ok {
+			return nil, newInvalidFollowedSymlinkError(symlinkPath)
+		}
+		followedSymlinks[relPath] = utility.Empty{}
+	}
+	return followedSymlinks, nil
+}
+
+// configureFollowedSymlinks applies the WALG_FOLLOW_SYMLINKS setting to the bundle
+func (bundle *Bundle) configureFollowedSymlinks() error {
+	followedSymlinks, err := ParseFollowedSymlinks(viper.GetString(internal.FollowSymlinksSetting))
+	if err != nil {
+		return err
+	}
+	bundle.FollowedSymlinks = followedSymlinks
+	return nil
+}
+
+// handleSymlink records the symlink found by the walk in the data directory. The symlinks are not followed
+// by default, they are packed as the symlinks and restored pointing to the same targets. The targets of
+// the FollowedSymlinks are packed in place of the symlinks, as if they were in the data directory.
+// The excluded directories (e.g. pg_wal on a separate disk) are packed as the plain directories, so the restore
+// creates them empty in the data directory instead of the symlinks to the paths of the backed up host.
+// The tablespace symlinks are handled by the TablespaceSpec before.
+func (bundle *Bundle) handleSymlink(path, relPath string, info os.FileInfo, excluded bool) error {
+	if _, ok := bundle.FollowedSymlinks[relPath]; ok {
+		return bundle.followSymlink(path, relPath)
+	}
+	if excluded {
+		return bundle.addExcludedSymlinkedDir(path, relPath)
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return errors.Wrapf(err, "handleSymlink: failed to read the symlink %s", path)
+	}
+	tracelog.DebugLogger.Printf("Recording the symlink %s -> %s", relPath, target)
+	fileInfoHeader, err := tar.FileInfoHeader(info, target)
+	if err != nil {
+		return errors.Wrap(err, "handleSymlink: could not grab header info")
+	}
+	fileInfoHeader.Name = relPath
+	return bundle.TarBallComposer.AddHeader(fileInfoHeader, info)
+}
+
+// addExcludedSymlinkedDir packs the excluded symlink to a directory as the directory itself,
+// the excluded symlinks to the other files and the dangling ones are skipped as the excluded files
+func (bundle *Bundle) addExcludedSymlinkedDir(path, relPath string) error {
+	// os.Stat names the target after the symlink
+	targetInfo, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "handleSymlink: failed to stat the target of %s", path)
+	}
+	if !targetInfo.IsDir() {
+		return nil
+	}
+	tracelog.DebugLogger.Printf("Recording the excluded symlink %s as the directory", relPath)
+	fileInfoHeader, err := tar.FileInfoHeader(targetInfo, "")
+	if err != nil {
+		return errors.Wrap(err, "handleSymlink: could not grab header info")
+	}
+	fileInfoHeader.Name = relPath
+	return bundle.TarBallComposer.AddHeader(fileInfoHeader, targetInfo)
+}
+
+// followSymlink walks the target of the symlink under the path of the symlink,
+// so the files of the target are read through the symlink
+func (bundle *Bundle) followSymlink(path, relPath string) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return errors.Wrapf(err, "followSymlink: failed to resolve the symlink %s", path)
+	}
+	tracelog.InfoLogger.Printf("Following the symlink %s to %s", relPath, target)
+	// os.Stat names the target after the symlink, so the excludes apply to it
+	targetInfo, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "followSymlink: failed to stat the target of %s", path)
+	}
+	err = bundle.addToBundle(path, targetInfo)
+	if err == filepath.SkipDir {
+		// the excluded directory is created empty on restore
+		return nil
+	}
+	if err != nil || !targetInfo.IsDir() {
+		return err
+	}
+	return filepath.Walk(target, func(targetPath string, info os.FileInfo, err error) error {
+		if targetPath == target {
+			return nil
+		}
+		return bundle.HandleWalkedFSObject(filepath.Join(path, utility.GetSubdirectoryRelativePath(targetPath, target)),
+			info, err)
+	})
+}
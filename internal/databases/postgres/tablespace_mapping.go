@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type UnmappedTablespacesError struct {
+	error
+}
+
+func newUnmappedTablespacesError(locations []string) UnmappedTablespacesError {
+	return UnmappedTablespacesError{errors.Errorf("tablespaces at %s are not remapped, "+
+		"map each of them to the new path, or to the same path to keep it", strings.Join(locations, ", "))}
+}
+
+func (err UnmappedTablespacesError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// TablespaceMapping maps the original tablespace locations to the locations they are restored to.
+// The location mapped to itself is explicitly allowed to keep its original path.
+type TablespaceMapping map[string]string
+
+// ParseTablespaceMapping parses the mappings in the /old/path=/new/path form
+func ParseTablespaceMapping(mappings []string) (TablespaceMapping, error) {
+	tablespaceMapping := make(TablespaceMapping, len(mappings))
+	for _, mapping := range mappings {
+		oldPath, newPath, ok := strings.Cut(mapping, "=")
+		if !ok || !filepath.IsAbs(oldPath) || !filepath.IsAbs(newPath) {
+			return nil, errors.Errorf("invalid tablespace mapping '%s', expected /old/path=/new/path", mapping)
+		}
+		oldPath = utility.NormalizePath(oldPath)
+		if _, ok := tablespaceMapping[oldPath]; ok {
+			return nil, errors.Errorf("tablespace %s is mapped more than once", oldPath)
+		}
+		tablespaceMapping[oldPath] = utility.NormalizePath(newPath)
+	}
+	return tablespaceMapping, nil
+}
+
+// apply returns the copy of the spec with the remapped tablespace locations.
+// Every tablespace of the spec must be mapped, and every mapping must match a tablespace,
+// so the restore fails before anything is extracted to the wrong place.
+func (tablespaceMapping TablespaceMapping) apply(spec *TablespaceSpec) (*TablespaceSpec, error) {
+	basePrefix, ok := spec.BasePrefix()
+	if !ok && !spec.empty() {
+		return nil, ErrorBasePrefixMissing
+	}
+	remappedSpec := NewTablespaceSpec(basePrefix)
+
+	var unmapped []string
+	matched := make(map[string]bool)
+	for _, symlinkName := range spec.TablespaceNames() {
+		location, _ := spec.location(symlinkName)
+		newLocation, ok := tablespaceMapping[utility.NormalizePath(location.Location)]
+		if !ok {
+			unmapped = append(unmapped, location.Location)
+			continue
+		}
+		matched[utility.NormalizePath(location.Location)] = true
+		if newLocation != utility.NormalizePath(location.Location) {
+			tracelog.InfoLogger.Printf("Tablespace %s is restored to %s instead of %s\n",
+				symlinkName, newLocation, location.Location)
+		}
+		remappedSpec.addTablespace(symlinkName, newLocation)
+	}
+	if len(unmapped) > 0 {
+		return nil, newUnmappedTablespacesError(unmapped)
+	}
+
+	var unknown []string
+	for oldPath := range tablespaceMapping {
+		if !matched[oldPath] {
+			unknown = append(unknown, oldPath)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, errors.Errorf("the backup has no tablespaces at %s", strings.Join(unknown, ", "))
+	}
+	return &remappedSpec, nil
+}
+
+// getRestoreTablespaceSpec returns the tablespace specification to restore the backup with:
+// the one read from the restore spec file, the backup one remapped by the tablespace mapping,
+// the empty one to restore the tablespaces inline, or nil to use the backup one as is
+func getRestoreTablespaceSpec(backup Backup, restoreSpecPath string,
+	tablespaceMapping TablespaceMapping, flattenTablespaces bool) (*TablespaceSpec, error) {
+	if restoreSpecPath != "" && len(tablespaceMapping) > 0 {
+		return nil, errors.New("the restore specification and the tablespace mapping can't be used together")
+	}
+	if flattenTablespaces {
+		if restoreSpecPath != "" || len(tablespaceMapping) > 0 {
+			return nil, errors.New("the tablespaces can't be flattened " +
+				"when the restore specification or the tablespace mapping is used")
+		}
+		// without the tablespace locations no symlinks are created,
+		// so the tablespace files are written to the pg_tblspc/<oid> directories
+		return &TablespaceSpec{}, nil
+	}
+	if restoreSpecPath != "" {
+		spec := &TablespaceSpec{}
+		err := readRestoreSpec(restoreSpecPath, spec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid restore specification path %s", restoreSpecPath)
+		}
+		return spec, nil
+	}
+	if len(tablespaceMapping) == 0 {
+		return nil, nil
+	}
+
+	sentinelDto, err := backup.GetSentinel()
+	if err != nil {
+		return nil, err
+	}
+	spec := chooseTablespaceSpecification(sentinelDto.TablespaceSpec, nil)
+	return tablespaceMapping.apply(spec)
+}
+
+// rewriteTablespaceMap replaces the tablespace paths in the tablespace_map file content
+// with the locations of the spec, otherwise PostgreSQL recreates the tablespace symlinks
+// pointing to the original paths on startup
+func rewriteTablespaceMap(content []byte, spec *TablespaceSpec) []byte {
+	var result bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		// each line is "<tablespace oid> <tablespace path>"
+		oid, _, ok := strings.Cut(line, " ")
+		if location, found := spec.location(oid); ok && found {
+			line = oid + " " + location.Location
+		}
+		result.WriteString(line)
+		result.WriteString("\n")
+	}
+	return result.Bytes()
+}
+
+// removeTablespaceMap removes the restored tablespace_map file of the flattened tablespaces,
+// otherwise PostgreSQL replaces the pg_tblspc/<oid> directories with the symlinks to the original paths on startup
+func removeTablespaceMap(dbDataDirectory string) error {
+	err := os.Remove(filepath.Join(dbDataDirectory, TablespaceMapFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to remove %s", TablespaceMapFilename)
+	}
+	tracelog.InfoLogger.Printf("Removed %s, the tablespaces are restored inline to %s/<oid>\n",
+		TablespaceMapFilename, TablespaceFolder)
+	return nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTablespaceSpec() *TablespaceSpec {
+	spec := NewTablespaceSpec("/var/lib/postgresql/data")
+	spec.addTablespace("16400", "/mnt/disk1/ts1")
+	spec.addTablespace("16401", "/mnt/disk2/ts2")
+	return &spec
+}
+
+func TestParseTablespaceMapping(t *testing.T) {
+	mapping, err := ParseTablespaceMapping([]string{"/mnt/disk1/ts1/=/data/ts1", "/mnt/disk2/ts2=/mnt/disk2/ts2"})
+	assert.NoError(t, err)
+	assert.Equal(t, TablespaceMapping{"/mnt/disk1/ts1": "/data/ts1", "/mnt/disk2/ts2": "/mnt/disk2/ts2"}, mapping)
+
+	for _, invalid := range [][]string{
+		{"/mnt/disk1/ts1"},
+		{"relative=/data/ts1"},
+		{"/mnt/disk1/ts1=relative"},
+		{"/mnt/disk1/ts1=/data/ts1", "/mnt/disk1/ts1/=/data/other"},
+	} {
+		_, err := ParseTablespaceMapping(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestTablespaceMapping_Apply(t *testing.T) {
+	mapping := TablespaceMapping{"/mnt/disk1/ts1": "/data/ts1", "/mnt/disk2/ts2": "/mnt/disk2/ts2"}
+
+	spec, err := mapping.apply(newTestTablespaceSpec())
+	assert.NoError(t, err)
+	basePrefix, _ := spec.BasePrefix()
+	assert.Equal(t, "/var/lib/postgresql/data", basePrefix)
+	assert.Equal(t, []string{"16400", "16401"}, spec.TablespaceNames())
+	location, _ := spec.location("16400")
+	assert.Equal(t, TablespaceLocation{Location: "/data/ts1", Symlink: "pg_tblspc/16400"}, location)
+	location, _ = spec.location("16401")
+	assert.Equal(t, "/mnt/disk2/ts2", location.Location)
+}
+
+func TestTablespaceMapping_ApplyUnmappedTablespace(t *testing.T) {
+	mapping := TablespaceMapping{"/mnt/disk1/ts1": "/data/ts1"}
+
+	_, err := mapping.apply(newTestTablespaceSpec())
+	assert.IsType(t, UnmappedTablespacesError{}, err)
+	assert.Contains(t, err.Error(), "/mnt/disk2/ts2")
+}
+
+func TestTablespaceMapping_ApplyUnknownTablespace(t *testing.T) {
+	mapping := TablespaceMapping{"/mnt/disk1/ts1": "/data/ts1", "/mnt/disk2/ts2": "/data/ts2", "/mnt/typo": "/data/ts3"}
+
+	_, err := mapping.apply(newTestTablespaceSpec())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "/mnt/typo")
+}
+
+func TestRewriteTablespaceMap(t *testing.T) {
+	mapping := TablespaceMapping{"/mnt/disk1/ts1": "/data/ts1", "/mnt/disk2/ts2": "/mnt/disk2/ts2"}
+	spec, err := mapping.apply(newTestTablespaceSpec())
+	assert.NoError(t, err)
+
+	content := rewriteTablespaceMap([]byte("16400 /mnt/disk1/ts1\n16401 /mnt/disk2/ts2\n16500 /mnt/unknown\n"), spec)
+	assert.Equal(t, "16400 /data/ts1\n16401 /mnt/disk2/ts2\n16500 /mnt/unknown\n", string(content))
+}
+
+func TestInterpretRewritesTablespaceMap(t *testing.T) {
+	mapping := TablespaceMapping{"/mnt/disk1/ts1": "/data/ts1", "/mnt/disk2/ts2": "/mnt/disk2/ts2"}
+	spec, err := mapping.apply(newTestTablespaceSpec())
+	assert.NoError(t, err)
+	dbDataDirectory := t.TempDir()
+	tarInterpreter := NewFileTarInterpreter(dbDataDirectory, BackupSentinelDto{TablespaceSpec: spec},
+		FilesMetadataDto{}, nil, false)
+
+	content := "16400 /mnt/disk1/ts1\n16401 /mnt/disk2/ts2\n"
+	err = tarInterpreter.Interpret(strings.NewReader(content), &tar.Header{
+		Name:     TablespaceMapFilename,
+		Typeflag: tar.TypeReg,
+		Size:     int64(len(content)),
+		Mode:     0600,
+	})
+	assert.NoError(t, err)
+
+	restored, err := os.ReadFile(filepath.Join(dbDataDirectory, TablespaceMapFilename))
+	assert.NoError(t, err)
+	assert.Equal(t, "16400 /data/ts1\n16401 /mnt/disk2/ts2\n", string(restored))
+}
+
+func TestGetRestoreTablespaceSpec_Flatten(t *testing.T) {
+	spec, err := getRestoreTablespaceSpec(Backup{}, "", nil, true)
+	assert.NoError(t, err)
+	assert.True(t, spec.empty())
+
+	_, err = getRestoreTablespaceSpec(Backup{}, "", TablespaceMapping{"/mnt/disk1/ts1": "/data/ts1"}, true)
+	assert.Error(t, err)
+	_, err = getRestoreTablespaceSpec(Backup{}, "/path/to/spec", nil, true)
+	assert.Error(t, err)
+}
+
+func TestRemoveTablespaceMap(t *testing.T) {
+	dbDataDirectory := t.TempDir()
+	tablespaceMapPath := filepath.Join(dbDataDirectory, TablespaceMapFilename)
+	assert.NoError(t, os.WriteFile(tablespaceMapPath, []byte("16400 /mnt/disk1/ts1\n"), 0600))
+
+	assert.NoError(t, removeTablespaceMap(dbDataDirectory))
+	_, err := os.Stat(tablespaceMapPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// the backups without tablespaces have no tablespace_map
+	assert.NoError(t, removeTablespaceMap(dbDataDirectory))
+}
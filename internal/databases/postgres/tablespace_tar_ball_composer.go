@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"golang.org/x/sync/errgroup"
+)
+
+// dataDirectoryLocation is the location of the files outside of pg_tblspc
+const dataDirectoryLocation = ""
+
+type TablespaceTarBallComposerMaker struct {
+	filePackerOptions TarBallFilePackerOptions
+	files             internal.BundleFiles
+	tarFileSets       internal.TarFileSets
+}
+
+func NewTablespaceTarBallComposerMaker(filePackerOptions TarBallFilePackerOptions) *TablespaceTarBallComposerMaker {
+	return &TablespaceTarBallComposerMaker{
+		filePackerOptions: filePackerOptions,
+		files:             &internal.RegularBundleFiles{},
+		tarFileSets:       internal.NewRegularTarFileSets(),
+	}
+}
+
+func (maker *TablespaceTarBallComposerMaker) Make(bundle *Bundle) (internal.TarBallComposer, error) {
+	tarBallFilePacker := newTarBallFilePacker(bundle.DeltaMap,
+		bundle.IncrementFromLsn, maker.files, maker.filePackerOptions)
+	return NewTablespaceTarBallComposer(uint64(bundle.TarSizeThreshold), bundle.TarBallQueue, tarBallFilePacker,
+		maker.files, maker.tarFileSets, bundle.Crypter), nil
+}
+
+// TablespaceTarBallComposer receives all files and tar headers
+// that are going to be written to the backup,
+// and packs the files of each tablespace into their own tarballs,
+// so that each tarball is restored to the disk of a single tablespace
+type TablespaceTarBallComposer struct {
+	// filesToCompose are grouped by the location, pg_tblspc/<oid> or the data directory
+	filesToCompose   map[string][]*internal.ComposeFileInfo
+	headersToCompose []*tar.Header
+
+	tarBallQueue     *internal.TarBallQueue
+	tarFilePacker    *TarBallFilePackerImpl
+	crypter          crypto.Crypter
+	files            internal.BundleFiles
+	tarFileSets      internal.TarFileSets
+	tarSizeThreshold uint64
+}
+
+func NewTablespaceTarBallComposer(
+	tarSizeThreshold uint64,
+	tarBallQueue *internal.TarBallQueue,
+	tarBallFilePacker *TarBallFilePackerImpl,
+	files internal.BundleFiles,
+	tarFileSets internal.TarFileSets,
+	crypter crypto.Crypter,
+) *TablespaceTarBallComposer {
+	return &TablespaceTarBallComposer{
+		filesToCompose:   make(map[string][]*internal.ComposeFileInfo),
+		headersToCompose: make([]*tar.Header, 0),
+		tarBallQueue:     tarBallQueue,
+		tarFilePacker:    tarBallFilePacker,
+		crypter:          crypter,
+		files:            files,
+		tarFileSets:      tarFileSets,
+		tarSizeThreshold: tarSizeThreshold,
+	}
+}
+
+func (c *TablespaceTarBallComposer) AddFile(info *internal.ComposeFileInfo) {
+	location := getTablespaceLocation(info.Header.Name)
+	c.filesToCompose[location] = append(c.filesToCompose[location], info)
+}
+
+func (c *TablespaceTarBallComposer) AddHeader(fileInfoHeader *tar.Header, info os.FileInfo) error {
+	c.headersToCompose = append(c.headersToCompose, fileInfoHeader)
+	c.files.AddFile(fileInfoHeader, info, false)
+	return nil
+}
+
+func (c *TablespaceTarBallComposer) SkipFile(tarHeader *tar.Header, fileInfo os.FileInfo) {
+	c.files.AddSkippedFile(tarHeader, fileInfo)
+}
+
+func (c *TablespaceTarBallComposer) FinishComposing() (internal.TarFileSets, error) {
+	err := c.writeHeaders()
+	if err != nil {
+		return nil, err
+	}
+
+	errorGroup, ctx := errgroup.WithContext(context.Background())
+	for _, filesCollection := range c.composeFiles() {
+		tarBall, err := c.tarBallQueue.DequeCtx(ctx)
+		if err != nil {
+			// one of the tarballs has failed, its error is returned by Wait
+			break
+		}
+		tarBall.SetUp(c.crypter)
+		for _, composeFileInfo := range filesCollection {
+			c.tarFileSets.AddFile(tarBall.Name(), composeFileInfo.Header.Name)
+		}
+		// filesCollection closure
+		filesCollectionLocal := filesCollection
+		errorGroup.Go(func() error {
+			for _, composeFileInfo := range filesCollectionLocal {
+				err := c.tarFilePacker.PackFileIntoTar(composeFileInfo, tarBall)
+				if err != nil {
+					return err
+				}
+			}
+			return c.tarBallQueue.FinishTarBall(tarBall)
+		})
+	}
+	err = errorGroup.Wait()
+	if err != nil {
+		return nil, err
+	}
+	tracelog.InfoLogger.Printf("Packed the files of %d locations into separate tarballs", len(c.filesToCompose))
+	return c.tarFileSets, nil
+}
+
+func (c *TablespaceTarBallComposer) GetFiles() internal.BundleFiles {
+	return c.files
+}
+
+// composeFiles splits the files of each location into the collections of the tarball size.
+// The collections never mix the locations, and the files of a location are kept in the path order.
+// The size of the increments is unknown before packing, so their tarballs may be smaller.
+func (c *TablespaceTarBallComposer) composeFiles() [][]*internal.ComposeFileInfo {
+	locations := make([]string, 0, len(c.filesToCompose))
+	for location := range c.filesToCompose {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	filesCollections := make([][]*internal.ComposeFileInfo, 0)
+	for _, location := range locations {
+		files := c.filesToCompose[location]
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Header.Name < files[j].Header.Name
+		})
+		currentCollection := make([]*internal.ComposeFileInfo, 0)
+		currentSize := uint64(0)
+		for _, file := range files {
+			if len(currentCollection) > 0 && currentSize > c.tarSizeThreshold {
+				filesCollections = append(filesCollections, currentCollection)
+				currentCollection = make([]*internal.ComposeFileInfo, 0)
+				currentSize = 0
+			}
+			currentCollection = append(currentCollection, file)
+			currentSize += uint64(file.FileInfo.Size())
+		}
+		filesCollections = append(filesCollections, currentCollection)
+	}
+	return filesCollections
+}
+
+// writeHeaders writes the directories and the other headers without data into one tarball,
+// it is reused by the files of one of the locations
+func (c *TablespaceTarBallComposer) writeHeaders() error {
+	headersTarBall := c.tarBallQueue.Deque()
+	headersTarBall.SetUp(c.crypter)
+	defer c.tarBallQueue.EnqueueBack(headersTarBall)
+	for _, header := range c.headersToCompose {
+		err := headersTarBall.TarWriter().WriteHeader(header)
+		if err != nil {
+			return errors.Wrap(err, "writeHeaders: failed to write header")
+		}
+		c.tarFileSets.AddFile(headersTarBall.Name(), header.Name)
+	}
+	return nil
+}
+
+// getTablespaceLocation returns pg_tblspc/<oid> for the files of the tablespaces,
+// the files of base, global and the rest of the data directory share its disk
+func getTablespaceLocation(fileName string) string {
+	location := getFileLocation(fileName)
+	if strings.HasPrefix(location, NonDefaultTablespace+"/") {
+		return location
+	}
+	return dataDirectoryLocation
+}
@@ -0,0 +1,55 @@
+package postgres_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/testtools"
+)
+
+func TestTablespaceTarBallComposer(t *testing.T) {
+	data := t.TempDir()
+	writeTestFile(t, filepath.Join(data, "global", postgres.PgControl))
+	writeTestFile(t, filepath.Join(data, "base", "5", "16384"))
+	writeTestFile(t, filepath.Join(data, "base", "5", "16385"))
+	for _, tablespaceName := range []string{"16400", "16401"} {
+		location := t.TempDir()
+		writeTestFile(t, filepath.Join(location, "PG_15_202209061", "5", "16500"))
+		writeTestFile(t, filepath.Join(location, "PG_15_202209061", "5", "16501"))
+		require.NoError(t, os.MkdirAll(filepath.Join(data, postgres.TablespaceFolder), 0700))
+		require.NoError(t, os.Symlink(location, filepath.Join(data, postgres.TablespaceFolder, tablespaceName)))
+	}
+
+	// all files would fit into one tarball
+	bundle := postgres.NewBundle(data, nil, nil, nil, false, int64(1<<20))
+	size := int64(0)
+	require.NoError(t, bundle.StartQueue(&testtools.FileTarBallMaker{Out: t.TempDir(), Size: &size}))
+	require.NoError(t, bundle.SetupComposer(postgres.NewTablespaceTarBallComposerMaker(
+		postgres.NewTarBallFilePackerOptions(false, false, 1))))
+	require.NoError(t, bundle.Walk())
+	tarFileSets, err := bundle.FinishTarComposer()
+	require.NoError(t, err)
+	require.NoError(t, bundle.FinishQueue())
+
+	fileTars := make(map[string]string)
+	for tarName, files := range tarFileSets.Get() {
+		for _, file := range files {
+			fileTars[file] = tarName
+		}
+	}
+	baseTar := fileTars["/base/5/16384"]
+	firstTablespaceTar := fileTars["/pg_tblspc/16400/PG_15_202209061/5/16500"]
+	secondTablespaceTar := fileTars["/pg_tblspc/16401/PG_15_202209061/5/16500"]
+	assert.Equal(t, baseTar, fileTars["/base/5/16385"])
+	assert.Equal(t, firstTablespaceTar, fileTars["/pg_tblspc/16400/PG_15_202209061/5/16501"])
+	assert.Equal(t, secondTablespaceTar, fileTars["/pg_tblspc/16401/PG_15_202209061/5/16501"])
+	assert.Len(t, map[string]bool{baseTar: true, firstTablespaceTar: true, secondTablespaceTar: true}, 3)
+	for _, file := range []string{"/base/5/16384", "/pg_tblspc/16401/PG_15_202209061/5/16501"} {
+		_, ok := bundle.GetFiles().Load(file)
+		assert.True(t, ok, file)
+	}
+}
@@ -15,6 +15,7 @@ const (
 	RatingComposer
 	CopyComposer
 	GreenplumComposer
+	TablespaceComposer
 )
 
 // TarBallComposerMaker is used to make an instance of TarBallComposer
@@ -22,8 +23,10 @@ type TarBallComposerMaker interface {
 	Make(bundle *Bundle) (internal.TarBallComposer, error)
 }
 
+// NewTarBallComposerMaker creates the maker of the composer of the specified type.
+// The deltaBaseName is the base backup of the delta backup being made, it is empty for the full backups.
 func NewTarBallComposerMaker(composerType TarBallComposerType, queryRunner *PgQueryRunner, uploader *internal.Uploader,
-	newBackupName string, filePackOptions TarBallFilePackerOptions,
+	newBackupName, deltaBaseName string, filePackOptions TarBallFilePackerOptions,
 	withoutFilesMetadata bool) (TarBallComposerMaker, error) {
 	folder := uploader.UploadingFolder
 	switch composerType {
@@ -39,6 +42,15 @@ func NewTarBallComposerMaker(composerType TarBallComposerType, queryRunner *PgQu
 		}
 		return NewRatingTarBallComposerMaker(relFileStats, filePackOptions)
 	case CopyComposer:
+		if deltaBaseName != "" {
+			// copy the unchanged tarballs of the delta base, the rest of the files are restored from the delta chain
+			deltaBase := NewBackup(folder, deltaBaseName)
+			_, _, err := deltaBase.GetSentinelAndFilesMetadata()
+			if err != nil {
+				return nil, err
+			}
+			return NewCopyTarBallComposerMaker(deltaBase, newBackupName, filePackOptions), nil
+		}
 		previousBackupName, err := internal.GetLatestBackupName(folder)
 		if err != nil {
 			tracelog.InfoLogger.Printf(
@@ -67,6 +79,8 @@ func NewTarBallComposerMaker(composerType TarBallComposerType, queryRunner *PgQu
 		}
 
 		return NewGpTarBallComposerMaker(relStorageMap, uploader, newBackupName)
+	case TablespaceComposer:
+		return NewTablespaceTarBallComposerMaker(filePackOptions), nil
 	default:
 		return nil, errors.New("NewTarBallComposerMaker: Unknown TarBallComposerType")
 	}
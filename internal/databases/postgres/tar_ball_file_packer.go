@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"context"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 
@@ -46,13 +47,26 @@ func (err FileNotExistError) Error() string {
 type TarBallFilePackerOptions struct {
 	verifyPageChecksums   bool
 	storeAllCorruptBlocks bool
+	// verifyConcurrency is the number of workers verifying the pages of one file
+	verifyConcurrency int
+	// pageChecksums collects the verification results of all the packed files
+	pageChecksums *pageChecksumsCollector
+	// pageRecheckLsn makes the pages failing the verification re-read from the files, the pages written
+	// since this LSN are skipped as being changed while they are read, zero disables the re-read
+	pageRecheckLsn LSN
 }
 
-func NewTarBallFilePackerOptions(verifyPageChecksums, storeAllCorruptBlocks bool) TarBallFilePackerOptions {
-	return TarBallFilePackerOptions{
+func NewTarBallFilePackerOptions(verifyPageChecksums, storeAllCorruptBlocks bool,
+	verifyConcurrency int) TarBallFilePackerOptions {
+	options := TarBallFilePackerOptions{
 		verifyPageChecksums:   verifyPageChecksums,
 		storeAllCorruptBlocks: storeAllCorruptBlocks,
+		verifyConcurrency:     verifyConcurrency,
 	}
+	if verifyPageChecksums {
+		options.pageChecksums = newPageChecksumsCollector()
+	}
+	return options
 }
 
 // TarBallFilePackerImpl is used to pack bundle file into tarball.
@@ -86,19 +100,35 @@ func (p *TarBallFilePackerImpl) UpdateDeltaMap(deltaMap PagedFileDeltaMap) {
 
 // TODO : unit tests
 func (p *TarBallFilePackerImpl) PackFileIntoTar(cfi *internal.ComposeFileInfo, tarBall internal.TarBall) error {
+	_, err := p.packFileIntoTar(cfi, tarBall, nil)
+	return err
+}
+
+// packFileIntoTar packs the file and writes the packed bytes to the packedHasher unless it is nil,
+// the file which is skipped or deleted before it is opened is not packed
+func (p *TarBallFilePackerImpl) packFileIntoTar(cfi *internal.ComposeFileInfo, tarBall internal.TarBall,
+	packedHasher hash.Hash) (packed bool, err error) {
 	fileReadCloser, err := p.createFileReadCloser(cfi)
 	if err != nil {
 		switch err.(type) {
 		case SkippedFileError:
 			p.files.AddSkippedFile(cfi.Header, cfi.FileInfo)
-			return nil
+			return false, nil
 		case FileNotExistError:
 			// File was deleted before opening.
 			// We should ignore file here as if it did not exist.
 			tracelog.WarningLogger.Println(err)
-			return nil
+			return false, nil
 		default:
-			return err
+			return false, err
+		}
+	}
+	var holes []internal.FileRegion
+	if !cfi.IsIncremented {
+		holes, err = findFileHoles(cfi.Path, cfi.FileInfo)
+		if err != nil {
+			utility.LoggedClose(fileReadCloser, "")
+			return false, err
 		}
 	}
 	errorGroup, _ := errgroup.WithContext(context.Background())
@@ -109,10 +139,14 @@ func (p *TarBallFilePackerImpl) PackFileIntoTar(cfi *internal.ComposeFileInfo, t
 		// fileReadCloser is needed for PackFileTo, secondReadCloser is for the page verification
 		fileReadCloser, secondReadCloser = newTeeReadCloser(fileReadCloser)
 		errorGroup.Go(func() (err error) {
-			corruptBlocks, err := verifyFile(cfi.Path, cfi.FileInfo, secondReadCloser, cfi.IsIncremented)
+			corruptBlocks, checkedBlocksCount, err := verifyFile(cfi.Path, cfi.FileInfo, secondReadCloser,
+				cfi.IsIncremented, p.options.verifyConcurrency, p.options.pageRecheckLsn)
 			if err != nil {
 				return err
 			}
+			if p.options.pageChecksums != nil {
+				p.options.pageChecksums.add(cfi.Header.Name, checkedBlocksCount, corruptBlocks)
+			}
 			p.files.AddFileWithCorruptBlocks(cfi.Header, cfi.FileInfo, cfi.IsIncremented,
 				corruptBlocks, p.options.storeAllCorruptBlocks)
 			return nil
@@ -121,19 +155,37 @@ func (p *TarBallFilePackerImpl) PackFileIntoTar(cfi *internal.ComposeFileInfo, t
 		p.files.AddFile(cfi.Header, cfi.FileInfo, cfi.IsIncremented)
 	}
 
+	fileChecksumReader := newChecksumReader(fileReadCloser)
+	var packedReader io.Reader = fileChecksumReader
+	if packedHasher != nil {
+		packedReader = io.TeeReader(fileChecksumReader, packedHasher)
+	}
 	errorGroup.Go(func() error {
 		defer utility.LoggedClose(fileReadCloser, "")
-		packedFileSize, err := internal.PackFileTo(tarBall, cfi.Header, fileReadCloser)
+		packedFileSize, err := internal.PackFileTo(tarBall, cfi.Header, packedReader)
 		if err != nil {
 			return errors.Wrap(err, "PackFileIntoTar: operation failed")
 		}
 		if packedFileSize != cfi.Header.Size {
 			return newTarSizeError(packedFileSize, cfi.Header.Size)
 		}
+		BackupPushMetrics.readBytesTotal.Add(float64(packedFileSize))
 		return nil
 	})
 
-	return errorGroup.Wait()
+	err = errorGroup.Wait()
+	if err != nil {
+		return false, err
+	}
+	// the increments are not checksummed since the restored file is composed of several backups
+	if !cfi.IsIncremented {
+		p.files.AddFileChecksum(cfi.Header.Name, fileChecksumReader.checksum())
+		if len(holes) > 0 {
+			p.files.AddFileHoles(cfi.Header.Name, holes)
+		}
+	}
+	BackupPushMetrics.packedFilesTotal.Inc()
+	return true, nil
 }
 
 func (p *TarBallFilePackerImpl) createFileReadCloser(cfi *internal.ComposeFileInfo) (io.ReadCloser, error) {
@@ -199,16 +251,17 @@ func startReadingFile(fileInfoHeader *tar.Header, info os.FileInfo, path string)
 	return fileReader, nil
 }
 
-func verifyFile(path string, fileInfo os.FileInfo, fileReader io.Reader, isIncremented bool) ([]uint32, error) {
+func verifyFile(path string, fileInfo os.FileInfo, fileReader io.Reader,
+	isIncremented bool, concurrency int, recheckLsn LSN) ([]uint32, int, error) {
 	if !isPagedFile(fileInfo, path) {
 		_, err := io.Copy(io.Discard, fileReader)
-		return nil, err
+		return nil, 0, err
 	}
 
 	if isIncremented {
-		return VerifyPagedFileIncrement(path, fileInfo, fileReader)
+		return VerifyPagedFileIncrement(path, fileInfo, fileReader, concurrency, recheckLsn)
 	}
-	return VerifyPagedFileBase(path, fileInfo, fileReader)
+	return VerifyPagedFileBase(path, fileInfo, fileReader, concurrency, recheckLsn)
 }
 
 // TeeReadCloser creates two io.ReadClosers from one
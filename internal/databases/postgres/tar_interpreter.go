@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"archive/tar"
+	"bytes"
 	"io"
 	"os"
 	"path"
@@ -22,21 +23,39 @@ type FileTarInterpreter struct {
 	FilesMetadata   FilesMetadataDto
 	FilesToUnwrap   map[string]bool
 	UnwrapResult    *UnwrapResult
+	RestoreFilter   *RestoreFilter
+	// Ownership remaps the owner of the extracted files if WALG_RESTORE_UID or WALG_RESTORE_GID is set
+	Ownership *RestoreOwnership
 
 	createNewIncrementalFiles bool
+	// set for the in-place restore into the existing data directory
+	inplace *inplaceRestore
+	// dedupCopies are the deduplicated copies of the files restored from their tar members
+	dedupCopies map[string][]string
+	// extractedDirs are the directories of the extracted files to fsync once the extraction completes
+	extractedDirs extractedDirs
+	// splitFiles coordinates the parts of the split files extracted from the different tar members
+	splitFiles splitFilesRestore
 }
 
 func NewFileTarInterpreter(
 	dbDataDirectory string, sentinel BackupSentinelDto, filesMetadata FilesMetadataDto,
 	filesToUnwrap map[string]bool, createNewIncrementalFiles bool,
 ) *FileTarInterpreter {
-	return &FileTarInterpreter{dbDataDirectory, sentinel, filesMetadata,
-		filesToUnwrap, newUnwrapResult(), createNewIncrementalFiles}
+	return &FileTarInterpreter{DBDataDirectory: dbDataDirectory, Sentinel: sentinel, FilesMetadata: filesMetadata,
+		FilesToUnwrap: filesToUnwrap, UnwrapResult: newUnwrapResult(), createNewIncrementalFiles: createNewIncrementalFiles,
+		dedupCopies: filesMetadata.getDedupCopies()}
 }
 
-// write file from reader to local file
-func WriteLocalFile(fileReader io.Reader, header *tar.Header, localFile *os.File, fsync bool) error {
-	_, err := io.Copy(localFile, fileReader)
+// write file from reader to local file, the holes of the sparse file are left unallocated
+func WriteLocalFile(fileReader io.Reader, header *tar.Header, localFile *os.File,
+	holes []internal.FileRegion, fsync bool) error {
+	var err error
+	if len(holes) > 0 {
+		err = writeFileHoles(localFile, fileReader, holes)
+	} else {
+		_, err = io.Copy(localFile, fileReader)
+	}
 	if err != nil {
 		err1 := os.Remove(localFile.Name())
 		if err1 != nil {
@@ -88,23 +107,85 @@ func (tarInterpreter *FileTarInterpreter) unwrapRegularFileOld(fileReader io.Rea
 	}
 	defer utility.LoggedClose(file, "")
 
-	return WriteLocalFile(fileReader, fileInfo, file, fsync)
+	return WriteLocalFile(fileReader, fileInfo, file, fileDescription.Holes, fsync)
+}
+
+// unwrapSkippedFile creates the file without restoring its contents.
+// Data files are allocated as sparse zero-filled files of the original size,
+// increments leave the already created file untouched.
+func (tarInterpreter *FileTarInterpreter) unwrapSkippedFile(fileInfo *tar.Header, targetPath string, fsync bool) error {
+	if tarInterpreter.FilesToUnwrap != nil {
+		if _, ok := tarInterpreter.FilesToUnwrap[fileInfo.Name]; !ok {
+			return nil
+		}
+	}
+	tracelog.DebugLogger.Printf("Skipping the contents of '%s'\n", fileInfo.Name)
+	err := PrepareDirs(fileInfo.Name, targetPath)
+	if err != nil {
+		return errors.Wrap(err, "Interpret: failed to create all directories")
+	}
+	file, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create new file: '%s'", targetPath)
+	}
+	defer utility.LoggedClose(file, "")
+
+	fileDescription, haveFileDescription := tarInterpreter.FilesMetadata.Files[fileInfo.Name]
+	if !haveFileDescription || !fileDescription.IsIncremented {
+		localFileInfo, err := file.Stat()
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat file: '%s'", targetPath)
+		}
+		if localFileInfo.Size() < fileInfo.Size {
+			if err = file.Truncate(fileInfo.Size); err != nil {
+				return errors.Wrapf(err, "failed to allocate file: '%s'", targetPath)
+			}
+		}
+	}
+	if err = file.Chmod(os.FileMode(fileInfo.Mode)); err != nil {
+		return errors.Wrap(err, "Interpret: chmod failed")
+	}
+	if fsync {
+		if err = file.Sync(); err != nil {
+			return errors.Wrap(err, "Interpret: fsync failed")
+		}
+	}
+	// there is nothing more to restore for this file
+	tarInterpreter.addToCompletedFiles(fileInfo.Name)
+	return nil
 }
 
 // Interpret extracts a tar file to disk and creates needed directories.
 // Returns the first error encountered. Calls fsync after each file
-// is written successfully.
+// is written successfully, the directories are fsynced by SyncDirectories.
 func (tarInterpreter *FileTarInterpreter) Interpret(fileReader io.Reader, fileInfo *tar.Header) error {
+	if part, ok := tarInterpreter.FilesMetadata.getSplitFilePart(fileInfo.Name); ok {
+		return tarInterpreter.interpretSplitFilePart(fileReader, fileInfo, part)
+	}
 	tracelog.DebugLogger.Println("Interpreting: ", fileInfo.Name)
 	targetPath := path.Join(tarInterpreter.DBDataDirectory, fileInfo.Name)
 	fsync := !viper.GetBool(internal.TarDisableFsyncSetting)
+	if tarInterpreter.inplace != nil {
+		tarInterpreter.inplace.addRestoredFile(fileInfo.Name)
+	}
+	if fsync {
+		tarInterpreter.extractedDirs.add(tarInterpreter.DBDataDirectory, targetPath)
+	}
+	err := tarInterpreter.interpretEntry(fileReader, fileInfo, targetPath, fsync)
+	if err != nil {
+		return err
+	}
+	return tarInterpreter.Ownership.apply(tarInterpreter.DBDataDirectory, targetPath, fileInfo)
+}
+
+func (tarInterpreter *FileTarInterpreter) interpretEntry(fileReader io.Reader, fileInfo *tar.Header,
+	targetPath string, fsync bool) error {
 	switch fileInfo.Typeflag {
 	case tar.TypeReg, tar.TypeRegA:
-		// temporary switch to determine if new unwrap logic should be used
-		if useNewUnwrapImplementation {
-			return tarInterpreter.unwrapRegularFileNew(fileReader, fileInfo, targetPath, fsync)
+		if copies, ok := tarInterpreter.dedupCopies[fileInfo.Name]; ok {
+			return tarInterpreter.unwrapWithDedupCopies(fileReader, fileInfo, copies, fsync)
 		}
-		return tarInterpreter.unwrapRegularFileOld(fileReader, fileInfo, targetPath, fsync)
+		return tarInterpreter.interpretRegularFile(fileReader, fileInfo, targetPath, fsync)
 	case tar.TypeDir:
 		err := os.MkdirAll(targetPath, 0755)
 		if err != nil {
@@ -114,17 +195,96 @@ func (tarInterpreter *FileTarInterpreter) Interpret(fileReader io.Reader, fileIn
 			return errors.Wrap(err, "Interpret: chmod failed")
 		}
 	case tar.TypeLink:
+		if err := tarInterpreter.removeInplaceLink(targetPath); err != nil {
+			return err
+		}
 		if err := os.Link(fileInfo.Name, targetPath); err != nil {
 			return errors.Wrapf(err, "Interpret: failed to create hardlink %s", targetPath)
 		}
 	case tar.TypeSymlink:
-		if err := os.Symlink(fileInfo.Name, targetPath); err != nil {
+		if err := tarInterpreter.removeInplaceLink(targetPath); err != nil {
+			return err
+		}
+		linkname := fileInfo.Linkname
+		if linkname == "" {
+			// keep the previous behavior for the headers without the target
+			linkname = fileInfo.Name
+		}
+		if err := os.Symlink(linkname, targetPath); err != nil {
 			return errors.Wrapf(err, "Interpret: failed to create symlink %s", targetPath)
 		}
 	}
 	return nil
 }
 
+func (tarInterpreter *FileTarInterpreter) interpretRegularFile(fileReader io.Reader, fileInfo *tar.Header,
+	targetPath string, fsync bool) error {
+	if !tarInterpreter.RestoreFilter.ShouldRestoreData(fileInfo.Name) {
+		return tarInterpreter.unwrapSkippedFile(fileInfo, targetPath, fsync)
+	}
+	if fileInfo.Name == TablespaceMapFilename && tarInterpreter.Sentinel.TablespaceSpec != nil &&
+		!tarInterpreter.Sentinel.TablespaceSpec.empty() {
+		return tarInterpreter.unwrapTablespaceMap(fileReader, fileInfo, targetPath, fsync)
+	}
+	if tarInterpreter.inplace != nil {
+		unchanged, err := tarInterpreter.skipUnchangedFile(fileInfo, targetPath)
+		if err != nil || unchanged {
+			return err
+		}
+	}
+	if viper.GetBool(internal.VerifyOnFetchSetting) {
+		fileChecksumReader, expectedChecksum := tarInterpreter.newRestoredFileChecksumReader(fileReader, fileInfo.Name)
+		if fileChecksumReader != nil {
+			err := tarInterpreter.unwrapRegularFile(fileChecksumReader, fileInfo, targetPath, fsync)
+			if err != nil {
+				return err
+			}
+			return verifyRestoredFileChecksum(fileChecksumReader, fileInfo.Name, fileInfo.Size, expectedChecksum)
+		}
+	}
+	return tarInterpreter.unwrapRegularFile(fileReader, fileInfo, targetPath, fsync)
+}
+
+// removeInplaceLink removes the existing file the link of the backup replaces during the in-place restore
+func (tarInterpreter *FileTarInterpreter) removeInplaceLink(targetPath string) error {
+	if tarInterpreter.inplace == nil {
+		return nil
+	}
+	err := os.Remove(targetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "Interpret: failed to remove %s", targetPath)
+	}
+	return nil
+}
+
+func (tarInterpreter *FileTarInterpreter) unwrapRegularFile(fileReader io.Reader,
+	fileInfo *tar.Header,
+	targetPath string,
+	fsync bool) error {
+	// temporary switch to determine if new unwrap logic should be used
+	if useNewUnwrapImplementation {
+		return tarInterpreter.unwrapRegularFileNew(fileReader, fileInfo, targetPath, fsync)
+	}
+	return tarInterpreter.unwrapRegularFileOld(fileReader, fileInfo, targetPath, fsync)
+}
+
+// unwrapTablespaceMap writes the tablespace_map file with the tablespace paths
+// the tablespaces are actually restored to
+func (tarInterpreter *FileTarInterpreter) unwrapTablespaceMap(fileReader io.Reader,
+	fileInfo *tar.Header,
+	targetPath string,
+	fsync bool) error {
+	content, err := io.ReadAll(fileReader)
+	if err != nil {
+		return errors.Wrapf(err, "Interpret: failed to read %s", fileInfo.Name)
+	}
+	content = rewriteTablespaceMap(content, tarInterpreter.Sentinel.TablespaceSpec)
+
+	rewrittenInfo := *fileInfo
+	rewrittenInfo.Size = int64(len(content))
+	return tarInterpreter.unwrapRegularFile(bytes.NewReader(content), &rewrittenInfo, targetPath, fsync)
+}
+
 // PrepareDirs makes sure all dirs exist
 func PrepareDirs(fileName string, targetPath string) error {
 	if fileName == targetPath {
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+	"golang.org/x/sync/errgroup"
+)
+
+// extractedDirs tracks the directories holding the entries of the extracted files. The file fsync
+// does not make its directory entry durable, so the directories are fsynced after the extraction.
+type extractedDirs struct {
+	mutex sync.Mutex
+	paths map[string]struct{}
+}
+
+// add records the directories of the extracted path up to the data directory, which entries
+// may be created by the extraction
+func (dirs *extractedDirs) add(dataDirectory, extractedPath string) {
+	dirs.mutex.Lock()
+	defer dirs.mutex.Unlock()
+	if dirs.paths == nil {
+		dirs.paths = make(map[string]struct{})
+	}
+	dataDirectory = filepath.Clean(dataDirectory)
+	for dir := filepath.Dir(filepath.Clean(extractedPath)); ; dir = filepath.Dir(dir) {
+		if _, ok := dirs.paths[dir]; ok {
+			return
+		}
+		dirs.paths[dir] = struct{}{}
+		if dir == dataDirectory || dir == filepath.Dir(dir) {
+			return
+		}
+	}
+}
+
+// takeAll returns the recorded directories and forgets them, so the next extraction
+// into the same directories syncs them again
+func (dirs *extractedDirs) takeAll() []string {
+	dirs.mutex.Lock()
+	defer dirs.mutex.Unlock()
+	paths := make([]string, 0, len(dirs.paths))
+	for path := range dirs.paths {
+		paths = append(paths, path)
+	}
+	dirs.paths = nil
+	return paths
+}
+
+// SyncDirectories fsyncs the directories of the extracted files, so the restored files can't disappear
+// on the host crash. The directories are fsynced in parallel by the download workers.
+func (tarInterpreter *FileTarInterpreter) SyncDirectories() error {
+	paths := tarInterpreter.extractedDirs.takeAll()
+	if len(paths) == 0 || viper.GetBool(internal.TarDisableFsyncSetting) {
+		return nil
+	}
+	concurrency, err := internal.GetMaxDownloadConcurrency()
+	if err != nil {
+		return err
+	}
+	tracelog.DebugLogger.Printf("Syncing %d directories of the extracted files\n", len(paths))
+
+	pathsToSync := make(chan string)
+	errGroup := new(errgroup.Group)
+	for i := 0; i < utility.Min(concurrency, len(paths)); i++ {
+		errGroup.Go(func() error {
+			// the worker keeps draining the channel after the error, so the sender is not blocked
+			var syncErr error
+			for path := range pathsToSync {
+				if syncErr == nil {
+					syncErr = syncDirectory(path)
+				}
+			}
+			return syncErr
+		})
+	}
+	for _, path := range paths {
+		pathsToSync <- path
+	}
+	close(pathsToSync)
+	return errGroup.Wait()
+}
+
+func syncDirectory(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open directory '%s' for fsync", path)
+	}
+	defer utility.LoggedClose(dir, "")
+	return errors.Wrapf(dir.Sync(), "failed to fsync directory '%s'", path)
+}
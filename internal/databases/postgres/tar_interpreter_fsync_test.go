@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+)
+
+func TestExtractedDirs_Add(t *testing.T) {
+	var dirs extractedDirs
+	dirs.add("/pgdata", "/pgdata/base/5/16384")
+	dirs.add("/pgdata/", "/pgdata/base/5/16385")
+	dirs.add("/pgdata", "/pgdata/global")
+
+	assert.ElementsMatch(t, []string{"/pgdata", "/pgdata/base", "/pgdata/base/5"}, dirs.takeAll())
+	assert.Empty(t, dirs.takeAll())
+}
+
+func TestFileTarInterpreter_SyncDirectories(t *testing.T) {
+	dataDirectory := t.TempDir()
+	interpreter := NewFileTarInterpreter(dataDirectory, BackupSentinelDto{}, FilesMetadataDto{}, nil, false)
+	content := "relation data"
+	header := &tar.Header{Name: "base/5/16384", Typeflag: tar.TypeReg, Mode: 0600, Size: int64(len(content))}
+	require.NoError(t, interpreter.Interpret(strings.NewReader(content), header))
+	restored, err := os.ReadFile(filepath.Join(dataDirectory, "base", "5", "16384"))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(restored))
+
+	require.NoError(t, interpreter.SyncDirectories())
+	assert.Empty(t, interpreter.extractedDirs.takeAll())
+
+	require.NoError(t, os.RemoveAll(filepath.Join(dataDirectory, "base")))
+	interpreter.extractedDirs.add(dataDirectory, filepath.Join(dataDirectory, "base", "5", "16384"))
+	assert.Error(t, interpreter.SyncDirectories())
+}
+
+func TestFileTarInterpreter_NoFsync(t *testing.T) {
+	viper.Set(internal.TarDisableFsyncSetting, true)
+	defer viper.Set(internal.TarDisableFsyncSetting, false)
+	dataDirectory := t.TempDir()
+	interpreter := NewFileTarInterpreter(dataDirectory, BackupSentinelDto{}, FilesMetadataDto{}, nil, false)
+	header := &tar.Header{Name: "base/5/16384", Typeflag: tar.TypeReg, Mode: 0600}
+	require.NoError(t, interpreter.Interpret(strings.NewReader(""), header))
+	assert.Empty(t, interpreter.extractedDirs.takeAll())
+}
@@ -62,7 +62,8 @@ func getFileUnwrapper(tarInterpreter *FileTarInterpreter, header *tar.Header, ta
 	if localFileInfo, _ := getLocalFileInfo(targetPath); localFileInfo != nil {
 		isPageFile = isPagedFile(localFileInfo, targetPath)
 	}
-	options := &BackupFileOptions{isIncremented: isIncremented, isPageFile: isPageFile}
+	options := &BackupFileOptions{isIncremented: isIncremented, isPageFile: isPageFile,
+		holes: fileDescription.Holes}
 
 	// todo: clearer catchup backup detection logic
 	isCatchup := tarInterpreter.createNewIncrementalFiles
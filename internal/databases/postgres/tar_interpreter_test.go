@@ -3,10 +3,14 @@ package postgres_test
 import (
 	"archive/tar"
 	"bytes"
+	"hash/crc32"
 	"os"
 	"path"
+	"syscall"
 	"testing"
 
+	"github.com/spf13/viper"
+	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
 
 	"github.com/stretchr/testify/assert"
@@ -159,3 +163,79 @@ func TestPrepareDirsForLocalDirectory(t *testing.T) {
 	err := postgres.PrepareDirs("filename", "filename")
 	assert.NoError(t, err)
 }
+
+func TestInterpretVerifiesChecksum(t *testing.T) {
+	viper.Set(internal.VerifyOnFetchSetting, true)
+	defer viper.Set(internal.VerifyOnFetchSetting, false)
+
+	content := []byte("relation file contents")
+	validChecksum := crc32.Checksum(content, crc32.MakeTable(crc32.Castagnoli))
+	invalidChecksum := validChecksum + 1
+	filesMetadata := postgres.FilesMetadataDto{Files: internal.BackupFileList{
+		"valid":   {Crc32c: &validChecksum},
+		"invalid": {Crc32c: &invalidChecksum},
+		"unknown": {},
+	}}
+	tarInterpreter := postgres.NewFileTarInterpreter(t.TempDir(), postgres.BackupSentinelDto{}, filesMetadata,
+		nil, false)
+
+	for _, name := range []string{"valid", "unknown"} {
+		err := tarInterpreter.Interpret(bytes.NewReader(content),
+			&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0600, Size: int64(len(content))})
+		assert.NoError(t, err)
+	}
+	err := tarInterpreter.Interpret(bytes.NewReader(content),
+		&tar.Header{Name: "invalid", Typeflag: tar.TypeReg, Mode: 0600, Size: int64(len(content))})
+	assert.IsType(t, postgres.FileChecksumMismatchError{}, err)
+}
+
+func TestInterpretRemapsOwnership(t *testing.T) {
+	dbDataDirectory := t.TempDir()
+	tarInterpreter := postgres.NewFileTarInterpreter(dbDataDirectory, postgres.BackupSentinelDto{},
+		postgres.FilesMetadataDto{}, nil, false)
+	tarInterpreter.Ownership = postgres.NewRestoreOwnership(os.Getuid(), os.Getgid())
+
+	headers := []*tar.Header{
+		{Name: "base", Typeflag: tar.TypeDir, Mode: 0777},
+		{Name: "base/1", Typeflag: tar.TypeDir, Mode: 0750},
+		{Name: "base/1/1259", Typeflag: tar.TypeReg, Mode: 0666},
+		{Name: "base/1/1249", Typeflag: tar.TypeReg, Mode: 0640},
+	}
+	for _, header := range headers {
+		assert.NoError(t, tarInterpreter.Interpret(&bytes.Buffer{}, header))
+	}
+
+	expectedModes := map[string]os.FileMode{
+		"":            0700,
+		"base":        0750,
+		"base/1":      0750,
+		"base/1/1259": 0640,
+		"base/1/1249": 0640,
+	}
+	for name, expectedMode := range expectedModes {
+		fileInfo, err := os.Stat(path.Join(dbDataDirectory, name))
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMode, fileInfo.Mode().Perm(), name)
+		stat := fileInfo.Sys().(*syscall.Stat_t)
+		assert.Equal(t, uint32(os.Getuid()), stat.Uid, name)
+		assert.Equal(t, uint32(os.Getgid()), stat.Gid, name)
+	}
+}
+
+func TestConfigureRestoreOwnership(t *testing.T) {
+	ownership, err := postgres.ConfigureRestoreOwnership()
+	assert.NoError(t, err)
+	assert.Nil(t, ownership)
+
+	viper.Set(internal.RestoreUIDSetting, "999")
+	defer viper.Set(internal.RestoreUIDSetting, "")
+	ownership, err = postgres.ConfigureRestoreOwnership()
+	assert.NoError(t, err)
+	assert.Equal(t, 999, ownership.UID)
+	assert.Equal(t, -1, ownership.GID)
+
+	viper.Set(internal.RestoreGIDSetting, "postgres")
+	defer viper.Set(internal.RestoreGIDSetting, "")
+	_, err = postgres.ConfigureRestoreOwnership()
+	assert.Error(t, err)
+}
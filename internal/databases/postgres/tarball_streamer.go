@@ -149,7 +149,8 @@ func (streamer *TarballStreamer) addFile() (err error) {
 	if !streamer.curHeader.FileInfo().IsDir() {
 		filePath := streamer.curHeader.Name
 		filePath = strings.TrimPrefix(filePath, "./")
-		streamer.Files.AddFileDescription(filePath, internal.BackupFileDescription{MTime: streamer.curHeader.ModTime})
+		streamer.Files.AddFileDescription(filePath, internal.BackupFileDescription{MTime: streamer.curHeader.ModTime,
+			Size: streamer.curHeader.Size})
 		streamer.tarFileReadIndex += streamer.curHeader.Size
 	}
 	return nil
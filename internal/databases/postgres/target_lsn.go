@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// recovery_target_lsn is supported since PostgreSQL 10
+const minTargetLsnPgVersion = 100000
+
+type NoBackupBeforeLsnError struct {
+	error
+}
+
+func newNoBackupBeforeLsnError(targetLsn LSN) NoBackupBeforeLsnError {
+	return NoBackupBeforeLsnError{errors.Errorf("no backup finished at or before LSN %s "+
+		"to start the recovery to it from", targetLsn)}
+}
+
+func (err NoBackupBeforeLsnError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err NoBackupBeforeLsnError) ExitCode() int {
+	return internal.ExitCodeNotFound
+}
+
+type TargetLsnWalMissingError struct {
+	error
+}
+
+func newTargetLsnWalMissingError(targetLsn LSN, backupName string, missingSegments []string) TargetLsnWalMissingError {
+	return TargetLsnWalMissingError{errors.Errorf("the WAL to recover backup %s up to LSN %s is not archived: "+
+		"%d segments are missing starting from %s, archive them or choose an earlier target LSN",
+		backupName, targetLsn, len(missingSegments), missingSegments[0])}
+}
+
+func (err TargetLsnWalMissingError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err TargetLsnWalMissingError) ExitCode() int {
+	return internal.ExitCodeNotFound
+}
+
+// ParseTargetLsn parses the recovery target LSN in the X/X form
+func ParseTargetLsn(targetLsn string) (LSN, error) {
+	lsn, err := pglogrepl.ParseLSN(targetLsn)
+	if err != nil {
+		return 0, errors.Errorf("invalid target LSN '%s', expected the X/X form, e.g. 1/AB000000", targetLsn)
+	}
+	return LSN(lsn), nil
+}
+
+// TargetLsnBackupSelector selects the newest backup finished at or before the target LSN. The recovery
+// can't stop before the restored cluster is consistent, which happens at the backup finish LSN,
+// so the backups started before the target LSN but finished after it are not selected.
+type TargetLsnBackupSelector struct {
+	targetLsn LSN
+}
+
+func NewTargetLsnBackupSelector(targetLsn LSN) TargetLsnBackupSelector {
+	return TargetLsnBackupSelector{targetLsn: targetLsn}
+}
+
+func (s TargetLsnBackupSelector) Select(folder storage.Folder) (string, error) {
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	backups, err := internal.GetBackups(baseBackupFolder)
+	if err != nil {
+		if _, ok := err.(internal.NoBackupsFoundError); ok {
+			return "", newNoBackupBeforeLsnError(s.targetLsn)
+		}
+		return "", err
+	}
+
+	var selected string
+	var selectedFinishLsn LSN
+	for _, backupTime := range backups {
+		backup := NewBackup(baseBackupFolder, backupTime.BackupName)
+		meta, err := backup.FetchMeta()
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to fetch the metadata of backup %s", backupTime.BackupName)
+		}
+		// the metadata of the older backups has no LSNs
+		if meta.FinishLsn == 0 || meta.FinishLsn > s.targetLsn || meta.FinishLsn < selectedFinishLsn {
+			continue
+		}
+		selected, selectedFinishLsn = backupTime.BackupName, meta.FinishLsn
+	}
+	if selected == "" {
+		return "", newNoBackupBeforeLsnError(s.targetLsn)
+	}
+
+	tracelog.InfoLogger.Printf("Backup %s finished at LSN %s is the newest backup finished before LSN %s\n",
+		selected, selectedFinishLsn, s.targetLsn)
+	return selected, nil
+}
+
+// CheckTargetLsnWal checks that the WAL segments from the backup start up to the one of the target LSN
+// are archived. Each segment is looked up on the timeline the recovery reads it from: the backup timeline
+// until it is switched to the next one in the history of the newest timeline, as Postgres does
+// with recovery_target_timeline = 'latest'. The segments of the other timelines are not counted.
+func CheckTargetLsnWal(rootFolder storage.Folder, backup Backup, targetLsn LSN) error {
+	meta, err := backup.FetchMeta()
+	if err != nil {
+		return err
+	}
+	if meta.FinishLsn > targetLsn {
+		return errors.Errorf("backup %s finished at LSN %s, after the target LSN %s, "+
+			"the recovery can't stop before the backup finish", backup.Name, meta.FinishLsn, targetLsn)
+	}
+	if meta.PgVersion != 0 && meta.PgVersion < minTargetLsnPgVersion {
+		return errors.Errorf("backup %s is of PostgreSQL %s, the recovery to the target LSN requires PostgreSQL 10+",
+			backup.Name, formatPgMajorVersion(meta.PgVersion))
+	}
+	timeline, err := ParseTimelineFromBackupName(backup.Name)
+	if err != nil {
+		return err
+	}
+
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	switches, err := getFollowingTimelineSwitches(walFolder, timeline)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the timeline history of backup %s", backup.Name)
+	}
+	walFilenames, err := getFolderFilenames(walFolder)
+	if err != nil {
+		return errors.Wrap(err, "failed to list the archived WAL")
+	}
+	archivedSegments := getSegmentsFromFiles(walFilenames)
+
+	var missingSegments []string
+	lastSegmentNo := newWalSegmentNo(targetLsn)
+	for segmentNo := newWalSegmentNo(meta.StartLsn); segmentNo <= lastSegmentNo; segmentNo = segmentNo.next() {
+		segmentTimeline := getSwitchedTimeline(segmentNo, timeline, switches)
+		if !archivedSegments[WalSegmentDescription{Timeline: segmentTimeline, Number: segmentNo}] {
+			missingSegments = append(missingSegments, segmentNo.getFilename(segmentTimeline))
+		}
+	}
+	if len(missingSegments) > 0 {
+		return newTargetLsnWalMissingError(targetLsn, backup.Name, missingSegments)
+	}
+	return nil
+}
+
+// GetPgTargetLsnFetcher wraps the fetcher to check the WAL up to the target LSN before anything is extracted,
+// the recovery configuration targeting it is written by the fetcher of GetPgRecoveryConfigFetcher
+func GetPgTargetLsnFetcher(fetcher func(rootFolder storage.Folder, backup internal.Backup),
+	targetLsn LSN) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		err := CheckTargetLsnWal(rootFolder, ToPgBackup(backup), targetLsn)
+		internal.FatalfOnError("Failed to fetch backup: %v\n", err)
+
+		fetcher(rootFolder, backup)
+	}
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func putTargetLsnTestBackup(t *testing.T, folder storage.Folder, backupName string, startSegmentNo, finishSegmentNo WalSegmentNo) {
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	startLsn, finishLsn := startSegmentNo.firstLsn()+0x28, finishSegmentNo.firstLsn()+0x100
+	err := internal.UploadDto(baseBackupFolder, BackupSentinelDto{BackupStartLSN: &startLsn, BackupFinishLSN: &finishLsn},
+		backupName+utility.SentinelSuffix)
+	require.NoError(t, err)
+	err = internal.UploadDto(baseBackupFolder, ExtendedMetadataDto{StartLsn: startLsn, FinishLsn: finishLsn, PgVersion: 140000},
+		backupName+"/"+utility.MetadataFileName)
+	require.NoError(t, err)
+}
+
+func putTargetLsnTestSegments(t *testing.T, folder storage.Folder, timeline uint32, segmentNos ...WalSegmentNo) {
+	for _, segmentNo := range segmentNos {
+		err := folder.GetSubFolder(utility.WalPath).PutObject(segmentNo.getFilename(timeline)+".lz4", &bytes.Buffer{})
+		require.NoError(t, err)
+	}
+}
+
+func TestParseTargetLsn(t *testing.T) {
+	lsn, err := ParseTargetLsn("1/AB000000")
+	assert.NoError(t, err)
+	assert.Equal(t, LSN(0x1AB000000), lsn)
+
+	_, err = ParseTargetLsn("1AB000000")
+	assert.Error(t, err)
+}
+
+func TestTargetLsnBackupSelector(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	putTargetLsnTestBackup(t, folder, "base_000000010000000000000002", 2, 3)
+	putTargetLsnTestBackup(t, folder, "base_000000010000000000000008", 8, 9)
+
+	backupName, err := NewTargetLsnBackupSelector(WalSegmentNo(9).firstLsn() + 0x100).Select(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000008", backupName)
+
+	// the second backup started before the target LSN, but the recovery can't stop before it finishes
+	backupName, err = NewTargetLsnBackupSelector(WalSegmentNo(9).firstLsn()).Select(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, "base_000000010000000000000002", backupName)
+
+	_, err = NewTargetLsnBackupSelector(WalSegmentNo(3).firstLsn()).Select(folder)
+	assert.IsType(t, NoBackupBeforeLsnError{}, err)
+}
+
+func TestCheckTargetLsnWal(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	putTargetLsnTestBackup(t, folder, "base_000000010000000000000002", 2, 3)
+	backup := NewBackup(folder.GetSubFolder(utility.BaseBackupPath), "base_000000010000000000000002")
+	putTargetLsnTestSegments(t, folder, 1, 2, 3, 4)
+	// the timeline is switched to the next one in the segment 5
+	require.NoError(t, folder.GetSubFolder(utility.WalPath).PutObject("00000002.history",
+		strings.NewReader("1\t0/5000100\tno recovery target specified\n")))
+	putTargetLsnTestSegments(t, folder, 2, 5, 6)
+	// the segments of the timelines outside the history of the backup timeline are not counted
+	putTargetLsnTestSegments(t, folder, 1, 7)
+	putTargetLsnTestSegments(t, folder, 3, 7)
+
+	assert.NoError(t, CheckTargetLsnWal(folder, backup, WalSegmentNo(6).firstLsn()+0x100))
+
+	err := CheckTargetLsnWal(folder, backup, WalSegmentNo(8).firstLsn())
+	assert.IsType(t, TargetLsnWalMissingError{}, err)
+	assert.Contains(t, err.Error(), "2 segments are missing starting from 000000020000000000000007")
+
+	err = CheckTargetLsnWal(folder, backup, WalSegmentNo(3).firstLsn())
+	assert.ErrorContains(t, err, "the recovery can't stop before the backup finish")
+}
+
+func TestWriteTargetLsnRecoveryConfig(t *testing.T) {
+	dataDirectory := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, "PG_VERSION"), []byte("14\n"), 0600))
+
+	config := NewRecoveryConfig(false, false)
+	config.SetTargetLsn(LSN(0x1AB000000))
+	err := config.Write(dataDirectory, `"/usr/bin/wal-g" wal-fetch "%f" "%p"`)
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(dataDirectory, AutoConfName))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(content), "recovery_target_lsn = '1/AB000000'\n"))
+	_, err = os.Stat(filepath.Join(dataDirectory, RecoverySignalName))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/wal-g/tracelog"
+)
+
+const initForkSuffix = "_init"
+
+// isUnloggedRelationFork checks if the file is a fork of the unlogged relation other than its init fork.
+// The unlogged relation is told by its init fork. The recovery removes its other forks and recreates
+// the main fork from the init fork, so the data of the other forks is never restored.
+func (bundle *Bundle) isUnloggedRelationFork(path, relPath string) (bool, error) {
+	if _, _, ok := parseRelFilePath(relPath); !ok {
+		return false, nil
+	}
+	match := relFileRegexp.FindStringSubmatch(filepath.Base(path))
+	if match[2] == initForkSuffix {
+		return false, nil
+	}
+	unloggedRelFileNodes, err := bundle.getUnloggedRelFileNodes(filepath.Dir(path))
+	if err != nil {
+		return false, err
+	}
+	return unloggedRelFileNodes[match[1]], nil
+}
+
+// getUnloggedRelFileNodes lists the relfilenodes of the unlogged relations of the database directory.
+// The directory is walked file by file, so only the listing of the last one is kept.
+func (bundle *Bundle) getUnloggedRelFileNodes(directory string) (map[string]bool, error) {
+	if bundle.unloggedDirectory == directory {
+		return bundle.unloggedRelFileNodes, nil
+	}
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	unloggedRelFileNodes := make(map[string]bool)
+	for _, entry := range entries {
+		match := relFileRegexp.FindStringSubmatch(entry.Name())
+		if match != nil && match[2] == initForkSuffix {
+			unloggedRelFileNodes[match[1]] = true
+		}
+	}
+	bundle.unloggedDirectory, bundle.unloggedRelFileNodes = directory, unloggedRelFileNodes
+	return unloggedRelFileNodes, nil
+}
+
+// skipUnloggedRelationFork records the skipped fork of the unlogged relation
+func (bundle *Bundle) skipUnloggedRelationFork(relPath string) {
+	tracelog.DebugLogger.Println("Skipped the fork of the unlogged relation: " + relPath)
+	bundle.SkippedUnloggedFiles = append(bundle.SkippedUnloggedFiles, relPath)
+}
@@ -0,0 +1,38 @@
+package postgres_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+func TestBundle_SkipUnlogged(t *testing.T) {
+	dir := t.TempDir()
+	// 16390 is unlogged, 16384 is a regular table
+	for _, name := range []string{"base/5/16384", "base/5/16384_fsm", "base/5/16390", "base/5/16390.1",
+		"base/5/16390_fsm", "base/5/16390_init", "base/5/16390_vm", "global/16390"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, make([]byte, 8192), 0600))
+	}
+
+	for _, skipUnlogged := range []bool{false, true} {
+		bundle := postgres.NewBundle(dir, nil, nil, nil, false, 0)
+		bundle.SkipUnlogged = skipUnlogged
+		report := &postgres.DryRunReport{LocationSizes: make(map[string]int64)}
+		bundle.TarBallComposer = postgres.NewDryRunTarBallComposer(report)
+		assert.NoError(t, filepath.Walk(dir, bundle.HandleWalkedFSObject))
+
+		if !skipUnlogged {
+			assert.Equal(t, 8, report.FilesCount)
+			assert.Empty(t, bundle.SkippedUnloggedFiles)
+			continue
+		}
+		assert.Equal(t, 4, report.FilesCount)
+		assert.Equal(t, []string{"/base/5/16390", "/base/5/16390.1", "/base/5/16390_fsm", "/base/5/16390_vm"},
+			bundle.SkippedUnloggedFiles)
+	}
+}
@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+)
+
+type CorruptBlocksFoundError struct {
+	error
+}
+
+func newCorruptBlocksFoundError(summary PageChecksumsSummary) CorruptBlocksFoundError {
+	return CorruptBlocksFoundError{errors.Errorf("found %d corrupt blocks in %d relations",
+		summary.CorruptBlocksCount, len(summary.CorruptRelations))}
+}
+
+func (err CorruptBlocksFoundError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err CorruptBlocksFoundError) ExitCode() int {
+	return internal.ExitCodeDataCorruption
+}
+
+// runVerifyChecksumsOnly verifies the page checksums of the data directory without making the backup,
+// the corrupt blocks are reported per relation to stdout
+func (bh *BackupHandler) runVerifyChecksumsOnly() error {
+	summary, err := verifyDataDirectoryChecksums(bh.pgInfo.pgDataDirectory, bh.arguments.skipUnlogged)
+	if err != nil {
+		return err
+	}
+	err = WritePageChecksumsReport(summary, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if summary.CorruptBlocksCount > 0 {
+		return newCorruptBlocksFoundError(summary)
+	}
+	tracelog.InfoLogger.Println("Page checksums verification finished, nothing was uploaded")
+	return nil
+}
+
+// verifyDataDirectoryChecksums walks the data directory as the full backup does and verifies the pages
+// of the walked files by the tar packer, the packed files are discarded instead of uploading.
+// The cluster may be running: the failed pages are re-read, and the ones written since the REDO location
+// of the checkpoint preceding the scan are skipped, as pg_basebackup does.
+func verifyDataDirectoryChecksums(dataDirectory string, skipUnlogged bool) (PageChecksumsSummary, error) {
+	pgControl, err := ExtractPgControl(dataDirectory)
+	if err != nil {
+		return PageChecksumsSummary{}, errors.Wrap(err, "failed to read the checkpoint location from pg_control")
+	}
+	tarSizeThreshold, err := internal.GetTarSizeThreshold()
+	if err != nil {
+		return PageChecksumsSummary{}, err
+	}
+	verifyConcurrency, err := internal.GetVerifyConcurrency()
+	if err != nil {
+		return PageChecksumsSummary{}, err
+	}
+	bundle := NewBundle(dataDirectory, nil, nil, nil, false, tarSizeThreshold)
+	bundle.SkipUnlogged = skipUnlogged
+	err = bundle.configureExcludedPaths()
+	if err != nil {
+		return PageChecksumsSummary{}, err
+	}
+	err = bundle.configureFollowedSymlinks()
+	if err != nil {
+		return PageChecksumsSummary{}, err
+	}
+
+	err = bundle.StartQueue(internal.NewNopTarBallMaker())
+	if err != nil {
+		return PageChecksumsSummary{}, err
+	}
+	filePackOptions := NewTarBallFilePackerOptions(true, false, verifyConcurrency)
+	filePackOptions.pageRecheckLsn = pgControl.GetCheckpointRedo()
+	err = bundle.SetupComposer(NewRegularTarBallComposerMaker(filePackOptions, &internal.NopBundleFiles{},
+		internal.NewNopTarFileSets()))
+	if err != nil {
+		return PageChecksumsSummary{}, err
+	}
+
+	tracelog.InfoLogger.Println("Walking (verifying page checksums only) ...")
+	err = bundle.Walk()
+	if err != nil {
+		return PageChecksumsSummary{}, err
+	}
+	_, err = bundle.FinishTarComposer()
+	if err != nil {
+		return PageChecksumsSummary{}, err
+	}
+	err = bundle.FinishQueue()
+	if err != nil {
+		return PageChecksumsSummary{}, err
+	}
+	summary := filePackOptions.pageChecksums.getSummary()
+	summary.log()
+	return summary, nil
+}
+
+// WritePageChecksumsReport writes the corrupt blocks count of each relation
+// and the totals of the page checksums verification
+func WritePageChecksumsReport(summary PageChecksumsSummary, output io.Writer) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
+	defer writer.Flush()
+	_, err := fmt.Fprintln(writer, "relation\tcorrupt blocks")
+	if err != nil {
+		return err
+	}
+	relations := make([]string, 0, len(summary.CorruptRelations))
+	for relation := range summary.CorruptRelations {
+		relations = append(relations, relation)
+	}
+	sort.Strings(relations)
+	for _, relation := range relations {
+		_, err = fmt.Fprintf(writer, "%s\t%d\n", relation, summary.CorruptRelations[relation])
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(writer, "\nchecked pages: %d, corrupt blocks: %d\n",
+		summary.CheckedPagesCount, summary.CorruptBlocksCount)
+	return err
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeChecksummedTestFile(t *testing.T, path string, pagesCount uint32, corruptBlocks ...uint32) {
+	var content bytes.Buffer
+	for blockNo := uint32(0); blockNo < pagesCount; blockNo++ {
+		page := newChecksummedPage(blockNo)
+		for _, corruptBlockNo := range corruptBlocks {
+			if corruptBlockNo == blockNo {
+				page[200] = 0xff
+			}
+		}
+		content.Write(page[:])
+	}
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, content.Bytes(), 0600))
+}
+
+// writeCheckpointTestPgControl writes the pg_control of version 13 with the checkpoint REDO location
+func writeCheckpointTestPgControl(t *testing.T, dataDirectory string, checkpointRedo LSN) {
+	writeTestPgControl(t, dataDirectory, 9876)
+	pgControlPath := filepath.Join(dataDirectory, PgControlPath)
+	pgControl, err := os.ReadFile(pgControlPath)
+	require.NoError(t, err)
+	binary.LittleEndian.PutUint64(pgControl[40:48], uint64(checkpointRedo))
+	require.NoError(t, os.WriteFile(pgControlPath, pgControl, 0600))
+}
+
+func TestVerifyDataDirectoryChecksums(t *testing.T) {
+	dataDirectory := t.TempDir()
+	writeCheckpointTestPgControl(t, dataDirectory, 100)
+	writeChecksummedTestFile(t, filepath.Join(dataDirectory, DefaultTablespace, "5", "16384"), 4, 1, 3)
+	writeChecksummedTestFile(t, filepath.Join(dataDirectory, DefaultTablespace, "5", "16385"), 2)
+	writeChecksummedTestFile(t, filepath.Join(dataDirectory, DefaultTablespace, "16400", "16500"), 3, 0)
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, "PG_VERSION"), []byte("15\n"), 0600))
+
+	summary, err := verifyDataDirectoryChecksums(dataDirectory, false)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9), summary.CheckedPagesCount)
+	assert.Equal(t, uint64(3), summary.CorruptBlocksCount)
+	assert.Equal(t, map[string]uint64{"1663/5/16384": 2, "1663/16400/16500": 1}, summary.CorruptRelations)
+
+	var report bytes.Buffer
+	require.NoError(t, WritePageChecksumsReport(summary, &report))
+	assert.Equal(t, "relation         corrupt blocks\n1663/16400/16500 1\n1663/5/16384     2\n\n"+
+		"checked pages: 9, corrupt blocks: 3\n", report.String())
+}
+
+func TestVerifyDataDirectoryChecksums_SkipsPagesWrittenSinceCheckpoint(t *testing.T) {
+	dataDirectory := t.TempDir()
+	// the LSN of the test page is its block number + 1, so the blocks starting from 2 are written since the checkpoint
+	writeCheckpointTestPgControl(t, dataDirectory, 3)
+	writeChecksummedTestFile(t, filepath.Join(dataDirectory, DefaultTablespace, "5", "16384"), 4, 1, 3)
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, "PG_VERSION"), []byte("15\n"), 0600))
+
+	summary, err := verifyDataDirectoryChecksums(dataDirectory, false)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), summary.CheckedPagesCount)
+	assert.Equal(t, uint64(1), summary.CorruptBlocksCount)
+	assert.Equal(t, map[string]uint64{"1663/5/16384": 1}, summary.CorruptRelations)
+}
@@ -17,9 +17,6 @@ import (
 	"github.com/wal-g/wal-g/utility"
 )
 
-// Looking at sysexits.h, EX_IOERR (74) is defined as a generic exit code for input/output errors
-const exIoError = 74
-
 type InvalidWalFileMagicError struct {
 	error
 }
@@ -32,10 +29,17 @@ func (err InvalidWalFileMagicError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+func (err InvalidWalFileMagicError) ExitCode() int {
+	return internal.ExitCodeDataCorruption
+}
+
 // TODO : unit tests
-// HandleWALFetch is invoked to performa wal-g wal-fetch
-func HandleWALFetch(folder storage.Folder, walFileName string, location string, triggerPrefetch bool) {
+// HandleWALFetch is invoked to performa wal-g wal-fetch. The WAL file missing in the folder is looked up
+// in the failover storages, where wal-push puts it if the upload to the folder fails.
+func HandleWALFetch(folder storage.Folder, failoverStorages []internal.FailoverStorage, walFileName string,
+	location string, triggerPrefetch bool) {
 	tracelog.DebugLogger.Printf("HandleWALFetch(folder, %s, %s, %v)\n", walFileName, location, triggerPrefetch)
+	internal.AddLogFields(internal.LogFields{"operation": "wal-fetch", "wal_file": walFileName})
 	folder = folder.GetSubFolder(utility.WalPath)
 	location = utility.ResolveSymlink(location)
 	if triggerPrefetch {
@@ -46,7 +50,8 @@ func HandleWALFetch(folder storage.Folder, walFileName string, location string,
 		defer forkPrefetch(walFileName, prefetchLocation)
 	}
 
-	_, _, running, prefetched := getPrefetchLocations(path.Dir(location), walFileName)
+	_, runningLocation, running, prefetched := getPrefetchLocations(path.Dir(location), walFileName)
+	part := getPrefetchPartPath(running)
 	seenSize := int64(-1)
 
 	sizeStallInterations := 0
@@ -59,7 +64,7 @@ func HandleWALFetch(folder storage.Folder, walFileName string, location string,
 			}
 
 			err = os.Rename(prefetched, location)
-			tracelog.ErrorLogger.FatalOnError(err)
+			internal.FatalOnError(err)
 
 			err := checkWALFileMagic(location)
 			if err != nil {
@@ -70,13 +75,14 @@ func HandleWALFetch(folder storage.Folder, walFileName string, location string,
 
 			return
 		} else if !os.IsNotExist(err) {
-			tracelog.ErrorLogger.FatalError(err)
+			internal.FatalOnError(err)
 		}
 
 		// We have race condition here, if running is renamed here, but it's OK
 
-		if runStat, err := os.Stat(running); err == nil {
-			observedSize := runStat.Size() // If there is no progress in 200 ms (100 iterations for 2ms)- start downloading myself
+		// the prefetch downloads the part file first and then decompresses it to the running file
+		if observedSize, err := getPrefetchProgress(running, part); err == nil {
+			// If there is no progress in 200 ms (100 iterations for 2ms)- start downloading myself
 			if observedSize <= seenSize {
 				sizeStallInterations++
 				if sizeStallInterations >= maxSizeStallTerations {
@@ -98,13 +104,56 @@ func HandleWALFetch(folder storage.Folder, walFileName string, location string,
 		time.Sleep(2 * time.Millisecond)
 	}
 
-	err := internal.DownloadFileTo(folder, walFileName, location)
-	if _, isArchNonExistErr := err.(internal.ArchiveNonExistenceError); isArchNonExistErr {
-		tracelog.ErrorLogger.Print(err.Error())
-		os.Exit(exIoError)
-	} else {
-		tracelog.ErrorLogger.FatalOnError(err)
+	// the missing WAL file exits with internal.ExitCodeNotFound, so Postgres ends the recovery
+	err := downloadWALFile(folder, walFileName, runningLocation, part, location)
+	if _, ok := errors.Cause(err).(internal.ArchiveNonExistenceError); ok && len(failoverStorages) > 0 {
+		err = internal.DownloadFileFromFailoverStorages(getWalFailoverStorages(failoverStorages), walFileName, location)
+	}
+	internal.FatalOnError(err)
+}
+
+// downloadWALFile resumes the part file left by the stalled prefetch or by the broken download of the previous
+// wal-fetch. The file is downloaded at once if the prefetch is not used or the part file is still being written.
+func downloadWALFile(folder storage.Folder, walFileName, runningLocation, part, location string) error {
+	if _, err := os.Stat(runningLocation); err != nil {
+		return internal.DownloadFileTo(folder, walFileName, location)
+	}
+	ok, err := internal.DownloadFileResumably(folder, walFileName, part, location)
+	if !ok {
+		tracelog.WarningLogger.Printf("%s is being downloaded by another process, downloading it anew", part)
+		return internal.DownloadFileTo(folder, walFileName, location)
+	}
+	return err
+}
+
+func getWalFailoverStorages(failoverStorages []internal.FailoverStorage) []internal.FailoverStorage {
+	walFailoverStorages := make([]internal.FailoverStorage, 0, len(failoverStorages))
+	for _, failoverStorage := range failoverStorages {
+		walFailoverStorages = append(walFailoverStorages,
+			internal.FailoverStorage{Name: failoverStorage.Name, Folder: failoverStorage.Folder.GetSubFolder(utility.WalPath)})
+	}
+	return walFailoverStorages
+}
+
+// getPrefetchProgress returns the total size of the running and the part files, os.ErrNotExist if there are none
+func getPrefetchProgress(running, part string) (int64, error) {
+	size := int64(0)
+	exists := false
+	for _, file := range []string{running, part} {
+		stat, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += stat.Size()
+		exists = true
+	}
+	if !exists {
+		return 0, os.ErrNotExist
 	}
+	return size, nil
 }
 
 // TODO : unit tests
@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"golang.org/x/sync/semaphore"
+)
+
+// WalPushBatchResult tracks the outcome of every WAL file of the batch individually
+type WalPushBatchResult struct {
+	// Uploaded lists the WAL files archived by the batch
+	Uploaded []string
+	// Failed maps the WAL files which are left for the next archive_command call to their upload errors
+	Failed map[string]error
+}
+
+// WalPushBatch archives the WAL file passed by archive_command together with the other WAL files
+// PostgreSQL has marked ready in archive_status. All files are uploaded through the shared uploader,
+// so one wal-push invocation archives up to batchSize files in any order.
+type WalPushBatch struct {
+	uploader            *WalUploader
+	dir                 string
+	firstWalName        string
+	batchSize           int
+	concurrency         int
+	preventWalOverwrite bool
+
+	mutex  sync.Mutex
+	result WalPushBatchResult
+}
+
+func NewWalPushBatch(uploader *WalUploader,
+	walFilePath string,
+	batchSize int,
+	concurrency int,
+	preventWalOverwrite bool) *WalPushBatch {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &WalPushBatch{
+		uploader:            uploader,
+		dir:                 filepath.Dir(walFilePath),
+		firstWalName:        filepath.Base(walFilePath),
+		batchSize:           batchSize,
+		concurrency:         concurrency,
+		preventWalOverwrite: preventWalOverwrite,
+		result:              WalPushBatchResult{Failed: make(map[string]error)},
+	}
+}
+
+// Push uploads the batch. The error of the WAL file passed by archive_command is returned, since PostgreSQL
+// retries it. The other files failed to upload stay ready, their ".ready" status files are renamed
+// to ".done" only once their uploads complete.
+func (batch *WalPushBatch) Push() (*WalPushBatchResult, error) {
+	readyWalNames, err := batch.findReadyWalNames()
+	if err != nil {
+		// the WAL file passed by archive_command is still archived alone
+		tracelog.WarningLogger.Printf("Failed to find the ready WAL files for the batch: %v", err)
+	}
+	tracelog.InfoLogger.Printf("Pushing %d ready WAL files along with %s", len(readyWalNames), batch.firstWalName)
+
+	workerCountSem := semaphore.NewWeighted(int64(batch.concurrency))
+	var wg sync.WaitGroup
+	var firstWalErr error
+	for _, walName := range append([]string{batch.firstWalName}, readyWalNames...) {
+		walName := walName
+		_ = workerCountSem.Acquire(context.Background(), 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer workerCountSem.Release(1)
+			err := batch.upload(walName)
+			if walName == batch.firstWalName {
+				firstWalErr = err
+			}
+		}()
+	}
+	wg.Wait()
+
+	tracelog.InfoLogger.Printf("Pushed %d WAL files of the batch, %d failed",
+		len(batch.result.Uploaded), len(batch.result.Failed))
+	return &batch.result, firstWalErr
+}
+
+// findReadyWalNames returns up to batchSize-1 WAL files marked ready in archive_status, except
+// the one passed by archive_command and the ones already uploaded by the previous wal-push calls
+func (batch *WalPushBatch) findReadyWalNames() ([]string, error) {
+	statusFiles, err := os.ReadDir(filepath.Join(batch.dir, archiveStatusDir))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the archive status directory")
+	}
+	readyWalNames := make([]string, 0, batch.batchSize-1)
+	for _, statusFile := range statusFiles {
+		if len(readyWalNames) >= batch.batchSize-1 {
+			break
+		}
+		statusFileName := statusFile.Name()
+		if !strings.HasSuffix(statusFileName, readySuffix) {
+			continue
+		}
+		walName := strings.TrimSuffix(statusFileName, readySuffix)
+		if walName == batch.firstWalName || batch.uploader.ArchiveStatusManager.IsWalAlreadyUploaded(walName) {
+			continue
+		}
+		readyWalNames = append(readyWalNames, walName)
+	}
+	return readyWalNames, nil
+}
+
+// upload archives one WAL file of the batch and records its outcome. The WAL files found in archive_status
+// are marked done right after their uploads, the WAL file passed by archive_command is marked done by PostgreSQL.
+func (batch *WalPushBatch) upload(walName string) error {
+	walFilePath := filepath.Join(batch.dir, walName)
+	uploader := batch.uploader.clone()
+	// .history files must not be overwritten, see https://github.com/wal-g/wal-g/issues/420
+	preventWalOverwrite := batch.preventWalOverwrite || strings.HasSuffix(walName, ".history")
+	err := uploadWALFile(uploader, walFilePath, preventWalOverwrite)
+	if err == nil {
+		err = uploadLocalWalMetadata(walFilePath, uploader.Uploader)
+	}
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to push WAL file %s of the batch: %v", walName, err)
+		batch.mutex.Lock()
+		batch.result.Failed[walName] = err
+		batch.mutex.Unlock()
+		return err
+	}
+
+	if walName != batch.firstWalName {
+		batch.markDone(walName)
+	}
+	batch.mutex.Lock()
+	batch.result.Uploaded = append(batch.result.Uploaded, walName)
+	batch.mutex.Unlock()
+	return nil
+}
+
+// markDone renames the ".ready" status file of the uploaded WAL file to ".done", so PostgreSQL does not
+// archive it again. If the rename fails, the WAL file is marked uploaded to make the next wal-push skip it.
+func (batch *WalPushBatch) markDone(walName string) {
+	err := batch.uploader.PGArchiveStatusManager.RenameReady(walName)
+	if err == nil {
+		return
+	}
+	tracelog.WarningLogger.Printf("Failed to mark WAL file %s done: %v", walName, err)
+	err = batch.uploader.ArchiveStatusManager.MarkWalUploaded(walName)
+	tracelog.ErrorLogger.PrintOnError(err)
+}
@@ -0,0 +1,81 @@
+package postgres_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/asm"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/fsutil"
+	"github.com/wal-g/wal-g/testtools"
+)
+
+func setupWalPushBatch(t *testing.T, walCount int) (string, *postgres.WalUploader) {
+	walDir := filepath.Join(t.TempDir(), "pg_wal")
+	archiveStatusDir := filepath.Join(walDir, "archive_status")
+	require.NoError(t, os.MkdirAll(archiveStatusDir, 0700))
+	for i := 0; i < walCount; i++ {
+		addTestDataFile(t, walDir, fmt.Sprint(i))
+	}
+
+	uploader := testtools.NewMockWalDirUploader(false, false)
+	uploader.ArchiveStatusManager = asm.NewFakeASM()
+	archiveStatusFolder, err := fsutil.ExistingDiskDataFolder(archiveStatusDir)
+	require.NoError(t, err)
+	uploader.PGArchiveStatusManager = asm.NewDataFolderASM(archiveStatusFolder)
+	return walDir, uploader
+}
+
+func TestWalPushBatch_Push(t *testing.T) {
+	viper.Set(internal.UploadWalMetadata, postgres.WalNoMetadataLevel)
+	walDir, uploader := setupWalPushBatch(t, 6)
+	firstWalName := testFilename("0")
+
+	result, err := postgres.NewWalPushBatch(uploader, filepath.Join(walDir, firstWalName), 4, 2, false).Push()
+	require.NoError(t, err)
+	assert.Len(t, result.Uploaded, 4)
+	assert.Empty(t, result.Failed)
+
+	for i := 0; i < 6; i++ {
+		walName := testFilename(fmt.Sprint(i))
+		_, err := uploader.UploadingFolder.ReadObject(walName + ".mock")
+		assert.Equal(t, i < 4, err == nil, walName)
+		// PostgreSQL marks the WAL file passed by archive_command done by itself
+		_, err = os.Stat(filepath.Join(walDir, "archive_status", walName+".done"))
+		assert.Equal(t, i > 0 && i < 4, err == nil, walName)
+	}
+}
+
+func TestWalPushBatch_PushTracksFailures(t *testing.T) {
+	viper.Set(internal.UploadWalMetadata, postgres.WalNoMetadataLevel)
+	walDir, uploader := setupWalPushBatch(t, 3)
+	missingWalName := testFilename("1")
+	require.NoError(t, os.Remove(filepath.Join(walDir, missingWalName)))
+
+	result, err := postgres.NewWalPushBatch(uploader, filepath.Join(walDir, testFilename("0")), 8, 4, false).Push()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{testFilename("0"), testFilename("2")}, result.Uploaded)
+	assert.Contains(t, result.Failed, missingWalName)
+
+	_, err = os.Stat(filepath.Join(walDir, "archive_status", missingWalName+".ready"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(walDir, "archive_status", testFilename("2")+".done"))
+	assert.NoError(t, err)
+}
+
+func TestWalPushBatch_PushFailsOnFirstWal(t *testing.T) {
+	viper.Set(internal.UploadWalMetadata, postgres.WalNoMetadataLevel)
+	walDir, uploader := setupWalPushBatch(t, 2)
+	firstWalPath := filepath.Join(walDir, testFilename("0"))
+	require.NoError(t, os.Remove(firstWalPath))
+
+	result, err := postgres.NewWalPushBatch(uploader, firstWalPath, 2, 1, false).Push()
+	assert.Error(t, err)
+	assert.Equal(t, []string{testFilename("1")}, result.Uploaded)
+}
@@ -9,10 +9,12 @@ import (
 	"strings"
 
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
 
@@ -33,9 +35,18 @@ func (err CantOverwriteWalFileError) Error() string {
 // TODO : unit tests
 // HandleWALPush is invoked to perform wal-g wal-push
 func HandleWALPush(uploader *WalUploader, walFilePath string) {
-	uploader.UploadingFolder = uploader.UploadingFolder.GetSubFolder(utility.WalPath)
+	internal.AddLogFields(internal.LogFields{"operation": "wal-push", "wal_file": filepath.Base(walFilePath)})
+	uploader.ChangeDirectory(utility.WalPath)
+	err := uploader.SetStorageClass(internal.GetWalStorageClass())
+	tracelog.ErrorLogger.FatalOnError(err)
+	if viper.GetBool(internal.WalZstdDictSetting) {
+		err = configureWalZstdDictionary(uploader, filepath.Dir(walFilePath))
+		if err != nil {
+			tracelog.WarningLogger.Printf("Pushing WAL without the zstd dictionary: %v", err)
+		}
+	}
 	if uploader.ArchiveStatusManager.IsWalAlreadyUploaded(walFilePath) {
-		err := uploader.ArchiveStatusManager.UnmarkWalFile(walFilePath)
+		err = uploader.ArchiveStatusManager.UnmarkWalFile(walFilePath)
 
 		if err != nil {
 			tracelog.ErrorLogger.Printf("unmark wal-g status for %s file failed due following error %+v", walFilePath, err)
@@ -53,6 +64,15 @@ func HandleWALPush(uploader *WalUploader, walFilePath string) {
 	preventWalOverwrite := viper.GetBool(internal.PreventWalOverwriteSetting) || strings.HasSuffix(walFilePath, ".history")
 	readyRename := viper.GetBool(internal.PgReadyRename)
 
+	if batchSize := viper.GetInt(internal.WalPushBatchSizeSetting); batchSize > 1 {
+		_, err = NewWalPushBatch(uploader, walFilePath, batchSize, concurrency, preventWalOverwrite).Push()
+		tracelog.ErrorLogger.FatalOnError(err)
+		if uploader.getUseWalDelta() {
+			uploader.FlushFiles()
+		}
+		return
+	}
+
 	bgUploader := NewBgUploader(walFilePath, int32(concurrency-1), totalBgUploadedLimit-1, uploader, preventWalOverwrite, readyRename)
 	// Look for new WALs while doing main upload
 	bgUploader.Start()
@@ -70,15 +90,14 @@ func HandleWALPush(uploader *WalUploader, walFilePath string) {
 	}
 }
 
-// TODO : unit tests
-// uploadWALFile from FS to the cloud
+// uploadWALFile from FS to the cloud. The WAL file already archived with equal content is not uploaded again,
+// as PostgreSQL may retry archive_command for it after a crash. The different archived content is overwritten
+// unless preventWalOverwrite is set. WALG_WAL_PUSH_OVERWRITE uploads the WAL file without the check.
 func uploadWALFile(uploader *WalUploader, walFilePath string, preventWalOverwrite bool) error {
-	if preventWalOverwrite {
-		overwriteAttempt, err := checkWALOverwrite(uploader, walFilePath)
-		if overwriteAttempt {
+	if preventWalOverwrite || !viper.GetBool(internal.WalPushOverwriteSetting) {
+		alreadyArchived, err := checkWALOverwrite(uploader, walFilePath, preventWalOverwrite)
+		if alreadyArchived || err != nil {
 			return err
-		} else if err != nil {
-			return errors.Wrap(err, "Couldn't check whether there is an overwrite attempt due to inner error")
 		}
 	}
 	walFile, err := os.Open(walFilePath)
@@ -89,29 +108,56 @@ func uploadWALFile(uploader *WalUploader, walFilePath string, preventWalOverwrit
 	return errors.Wrapf(err, "upload: could not Upload '%s'\n", walFilePath)
 }
 
-// TODO : unit tests
-func checkWALOverwrite(uploader *WalUploader, walFilePath string) (overwriteAttempt bool, err error) {
-	walFileReader, err := internal.DownloadAndDecompressStorageFile(uploader.UploadingFolder, filepath.Base(walFilePath))
+// checkWALOverwrite compares the local WAL file with the archived one, if any. The different archived content
+// fails the upload only if preventWalOverwrite is set, otherwise the WAL file is uploaded again, as it is
+// when the existence of the archived file can't be checked, e.g. with the write-only storage credentials.
+func checkWALOverwrite(uploader *WalUploader, walFilePath string, preventWalOverwrite bool) (alreadyArchived bool, err error) {
+	localBytes, err := os.ReadFile(walFilePath)
 	if err != nil {
-		if _, ok := err.(internal.ArchiveNonExistenceError); ok {
-			err = nil
-		}
 		return false, err
 	}
-
-	archived, err := io.ReadAll(walFileReader)
+	objectName, decompressor, err := internal.FindCompressedObject(uploader.UploadingFolder,
+		utility.SanitizePath(filepath.Base(walFilePath)))
 	if err != nil {
-		return false, err
+		if preventWalOverwrite {
+			return false, errors.Wrap(err, "Couldn't check whether there is an overwrite attempt due to inner error")
+		}
+		tracelog.WarningLogger.Printf("Couldn't check whether WAL file '%s' is already archived, uploading it: %v",
+			walFilePath, err)
+		return false, nil
 	}
-
-	localBytes, err := os.ReadFile(walFilePath)
+	if objectName == "" {
+		return false, nil
+	}
+	// the archived file exists, so the failure to read it is not hidden by the upload
+	archived, err := readArchivedWALFile(uploader.UploadingFolder, objectName, decompressor, int64(len(localBytes)))
 	if err != nil {
-		return false, err
+		return false, errors.Wrapf(err, "Couldn't read the archived WAL file '%s'", objectName)
 	}
-
 	if !bytes.Equal(archived, localBytes) {
-		return true, newCantOverwriteWalFileError(walFilePath)
+		if preventWalOverwrite {
+			return false, newCantOverwriteWalFileError(walFilePath)
+		}
+		tracelog.WarningLogger.Printf("WAL file '%s' already archived with different content, overwriting", walFilePath)
+		return false, nil
 	}
 	tracelog.InfoLogger.Printf("WAL file '%s' already archived with equal content, skipping", walFilePath)
 	return true, nil
 }
+
+// readArchivedWALFile reads the archived object of the WAL file. The archived content is read no further
+// than the local size, as the longer one differs anyway, so the rest of the object is not downloaded.
+func readArchivedWALFile(folder storage.Folder, objectName string, decompressor compression.Decompressor,
+	localSize int64) ([]byte, error) {
+	archiveReader, metadata, err := storage.ReadObjectWithMetadata(folder, objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(archiveReader, "")
+	walFileReader, err := internal.DecompressDecryptBytes(archiveReader, compression.WithMetadata(decompressor, metadata))
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(walFileReader, "")
+	return io.ReadAll(io.LimitReader(walFileReader, localSize+1))
+}
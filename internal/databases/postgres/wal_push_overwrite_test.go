@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/compression/lzma"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// readCountingFolder counts the existence checks and the reads of the objects
+type readCountingFolder struct {
+	storage.Folder
+	existsCount int
+	readCount   int
+}
+
+func (folder *readCountingFolder) Exists(objectRelativePath string) (bool, error) {
+	folder.existsCount++
+	return folder.Folder.Exists(objectRelativePath)
+}
+
+func (folder *readCountingFolder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	folder.readCount++
+	return folder.Folder.ReadObject(objectRelativePath)
+}
+
+const overwriteTestWalName = "000000010000000000000003"
+
+func pushOverwriteTestWal(t *testing.T, uploader *WalUploader, walDir, content string, preventWalOverwrite bool) error {
+	walPath := filepath.Join(walDir, overwriteTestWalName)
+	require.NoError(t, os.WriteFile(walPath, []byte(content), 0600))
+	return uploadWALFile(uploader, walPath, preventWalOverwrite)
+}
+
+func readOverwriteTestWal(t *testing.T, uploader *WalUploader) (string, time.Time) {
+	objects, _, err := uploader.UploadingFolder.ListFolder()
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	reader, err := internal.DownloadAndDecompressStorageFile(uploader.UploadingFolder, overwriteTestWalName)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content), objects[0].GetLastModified()
+}
+
+func TestUploadWALFile_EqualContentIsNotUploadedAgain(t *testing.T) {
+	uploader := NewWalUploader(lz4.Compressor{}, memory.NewFolder("", memory.NewStorage()), nil)
+	walDir := t.TempDir()
+	require.NoError(t, pushOverwriteTestWal(t, uploader, walDir, "WAL content", false))
+	_, firstModified := readOverwriteTestWal(t, uploader)
+	time.Sleep(time.Millisecond)
+
+	// archive_command is retried for the WAL file already archived
+	require.NoError(t, pushOverwriteTestWal(t, uploader, walDir, "WAL content", false))
+	_, modified := readOverwriteTestWal(t, uploader)
+	assert.Equal(t, firstModified, modified)
+
+	require.NoError(t, pushOverwriteTestWal(t, uploader, walDir, "WAL content", true))
+}
+
+func TestUploadWALFile_DifferentContentIsOverwritten(t *testing.T) {
+	uploader := NewWalUploader(lz4.Compressor{}, memory.NewFolder("", memory.NewStorage()), nil)
+	walDir := t.TempDir()
+	require.NoError(t, pushOverwriteTestWal(t, uploader, walDir, "WAL content", false))
+
+	err := pushOverwriteTestWal(t, uploader, walDir, "other WAL content", true)
+	assert.IsType(t, CantOverwriteWalFileError{}, err)
+	content, _ := readOverwriteTestWal(t, uploader)
+	assert.Equal(t, "WAL content", content)
+
+	require.NoError(t, pushOverwriteTestWal(t, uploader, walDir, "other WAL content", false))
+	content, _ = readOverwriteTestWal(t, uploader)
+	assert.Equal(t, "other WAL content", content)
+}
+
+func TestUploadWALFile_ForceOverwrite(t *testing.T) {
+	viper.Set(internal.WalPushOverwriteSetting, true)
+	defer viper.Set(internal.WalPushOverwriteSetting, false)
+	uploader := NewWalUploader(lz4.Compressor{}, memory.NewFolder("", memory.NewStorage()), nil)
+	walDir := t.TempDir()
+	require.NoError(t, pushOverwriteTestWal(t, uploader, walDir, "WAL content", false))
+	_, firstModified := readOverwriteTestWal(t, uploader)
+	time.Sleep(time.Millisecond)
+
+	require.NoError(t, pushOverwriteTestWal(t, uploader, walDir, "WAL content", false))
+	_, modified := readOverwriteTestWal(t, uploader)
+	assert.True(t, modified.After(firstModified))
+
+	// the overwrite is still prevented if WALG_PREVENT_WAL_OVERWRITE is set
+	err := pushOverwriteTestWal(t, uploader, walDir, "other WAL content", true)
+	assert.IsType(t, CantOverwriteWalFileError{}, err)
+}
+
+func TestUploadWALFile_NotArchivedIsNotRead(t *testing.T) {
+	folder := &readCountingFolder{Folder: memory.NewFolder("", memory.NewStorage())}
+	uploader := NewWalUploader(lz4.Compressor{}, folder, nil)
+	require.NoError(t, pushOverwriteTestWal(t, uploader, t.TempDir(), "WAL content", true))
+	// every supported extension and the uncompressed name are checked
+	assert.Equal(t, len(compression.Decompressors)+1, folder.existsCount)
+	assert.Equal(t, 0, folder.readCount)
+
+	content, _ := readOverwriteTestWal(t, uploader)
+	assert.Equal(t, "WAL content", content)
+}
+
+func TestUploadWALFile_ArchivedWithOtherCompression(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	walDir := t.TempDir()
+	require.NoError(t, pushOverwriteTestWal(t, NewWalUploader(lz4.Compressor{}, folder, nil), walDir, "WAL content", false))
+
+	// WALG_COMPRESSION_METHOD is changed after the WAL file is archived
+	uploader := NewWalUploader(lzma.Compressor{}, folder, nil)
+	err := pushOverwriteTestWal(t, uploader, walDir, "other WAL content", true)
+	assert.IsType(t, CantOverwriteWalFileError{}, err)
+	require.NoError(t, pushOverwriteTestWal(t, uploader, walDir, "WAL content", true))
+	objects, _, err := folder.ListFolder()
+	require.NoError(t, err)
+	assert.Len(t, objects, 1)
+}
+
+func TestUploadWALFile_BrokenArchivedFileFails(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	require.NoError(t, folder.PutObject(overwriteTestWalName+"."+lz4.FileExtension, strings.NewReader("not lz4")))
+	uploader := NewWalUploader(lz4.Compressor{}, folder, nil)
+
+	err := pushOverwriteTestWal(t, uploader, t.TempDir(), "WAL content", false)
+	assert.Error(t, err)
+	reader, err := folder.ReadObject(overwriteTestWalName + "." + lz4.FileExtension)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "not lz4", string(content))
+}
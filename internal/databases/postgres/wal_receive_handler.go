@@ -55,6 +55,8 @@ func HandleWALReceive(uploader *WalUploader) {
 	var segment *WalSegment
 
 	uploader.UploadingFolder = uploader.UploadingFolder.GetSubFolder(utility.WalPath)
+	err := uploader.SetStorageClass(internal.GetWalStorageClass())
+	tracelog.ErrorLogger.FatalOnError(err)
 
 	slot, walSegmentBytes, err := getCurrentWalInfo()
 	tracelog.ErrorLogger.FatalOnError(err)
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+)
+
+const walReplayPauseCheckInterval = 100 * time.Millisecond
+
+type NotStandbyError struct {
+	error
+}
+
+func newNotStandbyError() NotStandbyError {
+	return NotStandbyError{errors.New("The WAL replay can be paused only on a standby, " +
+		"but the server is not in recovery")}
+}
+
+func (err NotStandbyError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err NotStandbyError) ExitCode() int {
+	return internal.ExitCodeUsage
+}
+
+// checkStandby fails the backup of the server which is not in recovery before the backup is started
+func (bh *BackupHandler) checkStandby() error {
+	inRecovery, err := bh.workers.queryRunner.isInRecovery()
+	if err != nil {
+		return err
+	}
+	if !inRecovery {
+		return newNotStandbyError()
+	}
+	return nil
+}
+
+// walReplayResumer resumes the paused WAL replay once, either when the backup is finished or as soon as
+// the backup context is done, so the replay is resumed before the repeated interruption signal kills WAL-G.
+// The nil resume leaves the replay paused by someone else than the backup as it is.
+type walReplayResumer struct {
+	once     sync.Once
+	resume   func() error
+	err      error
+	finished chan struct{}
+}
+
+func newWalReplayResumer(ctx context.Context, resume func() error) *walReplayResumer {
+	resumer := &walReplayResumer{resume: resume, finished: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := resumer.resumeOnce(); err != nil {
+				tracelog.ErrorLogger.Printf("%v, resume it by pg_wal_replay_resume()", err)
+			}
+		case <-resumer.finished:
+		}
+	}()
+	return resumer
+}
+
+func (resumer *walReplayResumer) resumeOnce() error {
+	resumer.once.Do(func() {
+		if resumer.resume != nil {
+			resumer.err = resumer.resume()
+		}
+	})
+	return resumer.err
+}
+
+// finish resumes the WAL replay unless it is already resumed on the context cancellation
+func (resumer *walReplayResumer) finish() error {
+	close(resumer.finished)
+	return resumer.resumeOnce()
+}
+
+// pauseWalReplay pauses the WAL replay of the standby and waits for the pause to take effect,
+// so the files are not changed by the replay while they are read. The returned resumer is set
+// even if the pause fails, since the pause may be requested anyway. The replay paused before
+// the backup is not resumed by the backup.
+func (bh *BackupHandler) pauseWalReplay() (*walReplayResumer, error) {
+	pauseRequested, err := bh.workers.queryRunner.isWalReplayPauseRequested()
+	if err != nil {
+		return newWalReplayResumer(bh.ctx, nil), err
+	}
+	if pauseRequested {
+		tracelog.InfoLogger.Println("The WAL replay is already paused, it is left paused after the backup")
+		return newWalReplayResumer(bh.ctx, nil), nil
+	}
+	resumer := newWalReplayResumer(bh.ctx, bh.workers.queryRunner.resumeWalReplay)
+	err = bh.workers.queryRunner.pauseWalReplay()
+	if err != nil {
+		return resumer, err
+	}
+	ticker := time.NewTicker(walReplayPauseCheckInterval)
+	defer ticker.Stop()
+	for {
+		paused, err := bh.workers.queryRunner.isWalReplayPaused()
+		if err != nil || paused {
+			return resumer, err
+		}
+		select {
+		case <-ticker.C:
+		case <-bh.ctx.Done():
+			return resumer, bh.terminationCause()
+		}
+	}
+}
+
+// resumeWalReplay resumes the WAL replay paused by pauseWalReplay. The error of the resume is
+// returned only if the backup has not failed already, otherwise it is logged.
+func resumeWalReplay(resumer *walReplayResumer, backupErr error) error {
+	err := resumer.finish()
+	if err == nil {
+		return backupErr
+	}
+	if backupErr != nil {
+		tracelog.ErrorLogger.Printf("%v, resume it by pg_wal_replay_resume()", err)
+		return backupErr
+	}
+	return errors.Wrap(err, "the WAL replay is left paused, resume it by pg_wal_replay_resume()")
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalReplayResumer_ResumesOnFinish(t *testing.T) {
+	var resumes int32
+	resumer := newWalReplayResumer(context.Background(), func() error {
+		atomic.AddInt32(&resumes, 1)
+		return nil
+	})
+
+	assert.NoError(t, resumeWalReplay(resumer, nil))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&resumes))
+}
+
+func TestWalReplayResumer_ResumesOnCancel(t *testing.T) {
+	var resumes int32
+	ctx, cancel := context.WithCancel(context.Background())
+	resumer := newWalReplayResumer(ctx, func() error {
+		atomic.AddInt32(&resumes, 1)
+		return nil
+	})
+
+	// the replay is resumed while the walk is still running
+	cancel()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&resumes) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	backupErr := errors.New("interrupted")
+	assert.Equal(t, backupErr, resumeWalReplay(resumer, backupErr))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&resumes))
+}
+
+func TestWalReplayResumer_ReturnsResumeError(t *testing.T) {
+	resumer := newWalReplayResumer(context.Background(), func() error {
+		return errors.New("connection is lost")
+	})
+
+	assert.Error(t, resumeWalReplay(resumer, nil))
+}
+
+func TestWalReplayResumer_KeepsPausedBeforeBackup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	resumer := newWalReplayResumer(ctx, nil)
+
+	cancel()
+	assert.NoError(t, resumeWalReplay(resumer, nil))
+}
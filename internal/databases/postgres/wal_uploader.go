@@ -60,7 +60,8 @@ func (walUploader *WalUploader) UploadWalFile(file ioextensions.NamedReader) err
 		walFileReader = file
 	}
 
-	return walUploader.UploadFile(ioextensions.NewNamedReaderImpl(walFileReader, file.Name()))
+	_, err := walUploader.UploadFile(ioextensions.NewNamedReaderImpl(walFileReader, file.Name()))
+	return err
 }
 
 func (walUploader *WalUploader) FlushFiles() {
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const WalVerifyCheckpointName = "wal_verify_checkpoint.json"
+
+// WalVerifyCheckpoint is stored by the integrity check of wal-verify,
+// so the next run scans only the WAL archived after the VerifiedSegment.
+// All segments from the FromSegment up to the VerifiedSegment were found in storage.
+type WalVerifyCheckpoint struct {
+	FromSegment     string `json:"from_segment"`
+	VerifiedSegment string `json:"verified_segment"`
+}
+
+// loadWalVerifyCheckpoint fetches the checkpoint of the previous run.
+// Missing or broken checkpoint is not an error, the check just scans the whole range.
+func loadWalVerifyCheckpoint(rootFolder storage.Folder) *WalVerifyCheckpoint {
+	var checkpoint WalVerifyCheckpoint
+	err := internal.FetchDto(rootFolder, &checkpoint, WalVerifyCheckpointName)
+	if err != nil {
+		if _, ok := errors.Cause(err).(storage.ObjectNotFoundError); ok {
+			tracelog.InfoLogger.Printf("No %s found, verifying the whole WAL range", WalVerifyCheckpointName)
+		} else {
+			tracelog.WarningLogger.Printf("Ignoring %s: %v", WalVerifyCheckpointName, err)
+		}
+		return nil
+	}
+	return &checkpoint
+}
+
+func storeWalVerifyCheckpoint(rootFolder storage.Folder, checkpoint WalVerifyCheckpoint) error {
+	return internal.UploadDto(rootFolder, checkpoint, WalVerifyCheckpointName)
+}
+
+// getVerifiedSegmentNo returns the number of the last verified segment which belongs
+// to the history of the current timeline. If the checkpoint timeline was switched from
+// after the verified segment, only the segments before the switch are on the history.
+func (checkpoint WalVerifyCheckpoint) getVerifiedSegmentNo(currentTimeline uint32,
+	switchSegNoByTimeline map[uint32]WalSegmentNo) (WalSegmentNo, bool) {
+	verifiedSegment, err := NewWalSegmentDescription(checkpoint.VerifiedSegment)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Ignoring %s: %v", WalVerifyCheckpointName, err)
+		return 0, false
+	}
+	if verifiedSegment.Timeline == currentTimeline {
+		return verifiedSegment.Number, true
+	}
+	switchSegNo, ok := switchSegNoByTimeline[verifiedSegment.Timeline]
+	if !ok {
+		tracelog.WarningLogger.Printf("Ignoring %s: the timeline of the segment %s is not on the history "+
+			"of the current timeline %d", WalVerifyCheckpointName, checkpoint.VerifiedSegment, currentTimeline)
+		return 0, false
+	}
+	if verifiedSegment.Number < switchSegNo {
+		return verifiedSegment.Number, true
+	}
+	return switchSegNo.previous(), true
+}
+
+// getFromSegmentNo returns the number of the segment the checkpoint was verified from
+func (checkpoint WalVerifyCheckpoint) getFromSegmentNo() (WalSegmentNo, bool) {
+	fromSegment, err := NewWalSegmentDescription(checkpoint.FromSegment)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Ignoring %s: %v", WalVerifyCheckpointName, err)
+		return 0, false
+	}
+	return fromSegment.Number, true
+}
+
+// getHistoryTimeline returns the timeline of the segment on the history of the current timeline
+func getHistoryTimeline(segmentNo WalSegmentNo, currentTimeline uint32,
+	switchSegNoByTimeline map[uint32]WalSegmentNo) uint32 {
+	historyTimeline := currentTimeline
+	for timeline, switchSegNo := range switchSegNoByTimeline {
+		if segmentNo < switchSegNo && timeline < historyTimeline {
+			historyTimeline = timeline
+		}
+	}
+	return historyTimeline
+}
+
+// findLastVerifiedSegment returns the last segment of the found segments sequence,
+// that starts with the stop segment of the scan. The scanned segments must be ordered.
+func findLastVerifiedSegment(scannedSegments []ScannedSegmentDescription,
+	stopWalSegmentNo WalSegmentNo) (WalSegmentDescription, bool) {
+	if len(scannedSegments) == 0 || scannedSegments[0].Number != stopWalSegmentNo {
+		return WalSegmentDescription{}, false
+	}
+	var lastVerified WalSegmentDescription
+	for i, segment := range scannedSegments {
+		if segment.status != Found || segment.Number != stopWalSegmentNo.add(uint64(i)) {
+			break
+		}
+		lastVerified = segment.WalSegmentDescription
+	}
+	return lastVerified, scannedSegments[0].status == Found
+}
@@ -15,10 +15,11 @@ type WalVerifyCheckType int
 const (
 	WalVerifyIntegrityCheck = iota + 1
 	WalVerifyTimelineCheck
+	WalVerifyRetainedCheck
 )
 
 func (checkType WalVerifyCheckType) String() string {
-	return [...]string{"", "integrity", "timeline"}[checkType]
+	return [...]string{"", "integrity", "timeline", "retained"}[checkType]
 }
 
 func (checkType WalVerifyCheckType) MarshalText() (text []byte, err error) {
@@ -110,6 +111,8 @@ func BuildWalVerifyCheckRunner(
 	rootFolder storage.Folder,
 	walFolderFilenames []string,
 	currentWalSegment WalSegmentDescription,
+	startBackupName string,
+	fullScan bool,
 ) (WalVerifyCheckRunner, error) {
 	var checkRunner WalVerifyCheckRunner
 	var err error
@@ -117,7 +120,10 @@ func BuildWalVerifyCheckRunner(
 	case WalVerifyTimelineCheck:
 		checkRunner, err = NewTimelineCheckRunner(walFolderFilenames, currentWalSegment)
 	case WalVerifyIntegrityCheck:
-		checkRunner, err = NewIntegrityCheckRunner(rootFolder, walFolderFilenames, currentWalSegment)
+		checkRunner, err = NewIntegrityCheckRunner(rootFolder, walFolderFilenames, currentWalSegment, startBackupName,
+			fullScan)
+	case WalVerifyRetainedCheck:
+		checkRunner, err = NewRetainedWalCheckRunner(rootFolder, walFolderFilenames)
 	default:
 		return nil, NewUnknownWalVerifyCheckError(checkType)
 	}
@@ -129,11 +135,15 @@ func BuildWalVerifyCheckRunner(
 }
 
 // HandleWalVerify builds a check runner for each check type
-// and writes the check results to the provided output writer
+// and writes the check results to the provided output writer.
+// If startBackupName is not empty, the integrity check starts from that backup instead of the earliest one.
+// Unless fullScan is set, the integrity check scans only the segments archived after its previous run.
 func HandleWalVerify(
 	checkTypes []WalVerifyCheckType,
 	rootFolder storage.Folder,
 	currentWalSegment WalSegmentDescription,
+	startBackupName string,
+	fullScan bool,
 	outputWriter WalVerifyOutputWriter,
 ) {
 	checkResults := make(map[WalVerifyCheckType]WalVerifyCheckResult, len(checkTypes))
@@ -144,7 +154,8 @@ func HandleWalVerify(
 
 	for _, checkType := range checkTypes {
 		tracelog.InfoLogger.Printf("Building check runner: %s\n", checkType)
-		runner, err := BuildWalVerifyCheckRunner(checkType, rootFolder, walFolderFilenames, currentWalSegment)
+		runner, err := BuildWalVerifyCheckRunner(checkType, rootFolder, walFolderFilenames,
+			currentWalSegment, startBackupName, fullScan)
 		tracelog.ErrorLogger.FatalfOnError(
 			fmt.Sprintf("Failed to build check runner %s:", checkType), err)
 
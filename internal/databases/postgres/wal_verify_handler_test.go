@@ -13,6 +13,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
 
@@ -34,6 +35,8 @@ type WalVerifyTestSetup struct {
 	storageSegments []string
 	// list of other mock storage files
 	storageFiles map[string]*bytes.Buffer
+	// startBackupName is the backup to start the integrity check from
+	startBackupName string
 }
 
 // MockWalVerifyOutputWriter is used to capture wal-verify command output
@@ -62,11 +65,12 @@ func TestWalVerify_EmptyStorage(t *testing.T) {
 		Status: postgres.StatusWarning,
 		Details: postgres.IntegrityCheckDetails{
 			{
-				TimelineID:    3,
-				StartSegment:  "000000030000000000000001",
-				EndSegment:    "000000030000000000000002",
-				SegmentsCount: 2,
-				Status:        postgres.Lost,
+				TimelineID:      3,
+				StartSegment:    "000000030000000000000001",
+				EndSegment:      "000000030000000000000002",
+				SegmentsCount:   2,
+				Status:          postgres.Lost,
+				MissingSegments: []string{"000000030000000000000001", "000000030000000000000002"},
 			},
 			{
 				TimelineID:    3,
@@ -74,6 +78,12 @@ func TestWalVerify_EmptyStorage(t *testing.T) {
 				EndSegment:    "000000030000000000000006",
 				SegmentsCount: 4, //uploadingSegmentRangeSize
 				Status:        postgres.ProbablyUploading,
+				MissingSegments: []string{
+					"000000030000000000000003",
+					"000000030000000000000004",
+					"000000030000000000000005",
+					"000000030000000000000006",
+				},
 			},
 			{
 				TimelineID:    3,
@@ -81,6 +91,11 @@ func TestWalVerify_EmptyStorage(t *testing.T) {
 				EndSegment:    "000000030000000000000009",
 				SegmentsCount: 3, //delayedSegmentRangeSize
 				Status:        postgres.ProbablyDelayed,
+				MissingSegments: []string{
+					"000000030000000000000007",
+					"000000030000000000000008",
+					"000000030000000000000009",
+				},
 			},
 		},
 	}
@@ -121,11 +136,12 @@ func TestWalVerify_OnlyGarbageInStorage(t *testing.T) {
 		Status: postgres.StatusWarning,
 		Details: postgres.IntegrityCheckDetails{
 			{
-				TimelineID:    3,
-				StartSegment:  "000000030000000000000001",
-				EndSegment:    "000000030000000000000002",
-				SegmentsCount: 2,
-				Status:        postgres.Lost,
+				TimelineID:      3,
+				StartSegment:    "000000030000000000000001",
+				EndSegment:      "000000030000000000000002",
+				SegmentsCount:   2,
+				Status:          postgres.Lost,
+				MissingSegments: []string{"000000030000000000000001", "000000030000000000000002"},
 			},
 			{
 				TimelineID:    3,
@@ -133,6 +149,12 @@ func TestWalVerify_OnlyGarbageInStorage(t *testing.T) {
 				EndSegment:    "000000030000000000000006",
 				SegmentsCount: 4, //uploadingSegmentRangeSize
 				Status:        postgres.ProbablyUploading,
+				MissingSegments: []string{
+					"000000030000000000000003",
+					"000000030000000000000004",
+					"000000030000000000000005",
+					"000000030000000000000006",
+				},
 			},
 			{
 				TimelineID:    3,
@@ -140,6 +162,11 @@ func TestWalVerify_OnlyGarbageInStorage(t *testing.T) {
 				EndSegment:    "000000030000000000000009",
 				SegmentsCount: 3, //delayedSegmentRangeSize
 				Status:        postgres.ProbablyDelayed,
+				MissingSegments: []string{
+					"000000030000000000000007",
+					"000000030000000000000008",
+					"000000030000000000000009",
+				},
 			},
 		},
 	}
@@ -231,6 +258,11 @@ func TestWalVerify_SingleTimeline_SomeDelayed(t *testing.T) {
 				EndSegment:    "000000050000000000000007",
 				SegmentsCount: 3,
 				Status:        postgres.ProbablyDelayed,
+				MissingSegments: []string{
+					"000000050000000000000005",
+					"000000050000000000000006",
+					"000000050000000000000007",
+				},
 			},
 		},
 	}
@@ -276,11 +308,12 @@ func TestWalVerify_SingleTimeline_SomeUploading(t *testing.T) {
 				Status:        postgres.Found,
 			},
 			{
-				TimelineID:    5,
-				StartSegment:  "000000050000000000000004",
-				EndSegment:    "000000050000000000000004",
-				SegmentsCount: 1,
-				Status:        postgres.ProbablyUploading,
+				TimelineID:      5,
+				StartSegment:    "000000050000000000000004",
+				EndSegment:      "000000050000000000000004",
+				SegmentsCount:   1,
+				Status:          postgres.ProbablyUploading,
+				MissingSegments: []string{"000000050000000000000004"},
 			},
 			{
 				TimelineID:    5,
@@ -290,11 +323,12 @@ func TestWalVerify_SingleTimeline_SomeUploading(t *testing.T) {
 				Status:        postgres.Found,
 			},
 			{
-				TimelineID:    5,
-				StartSegment:  "000000050000000000000006",
-				EndSegment:    "000000050000000000000006",
-				SegmentsCount: 1,
-				Status:        postgres.ProbablyUploading,
+				TimelineID:      5,
+				StartSegment:    "000000050000000000000006",
+				EndSegment:      "000000050000000000000006",
+				SegmentsCount:   1,
+				Status:          postgres.ProbablyUploading,
+				MissingSegments: []string{"000000050000000000000006"},
 			},
 			{
 				TimelineID:    5,
@@ -430,11 +464,12 @@ func TestWalVerify_TwoTimelines_SomeLost(t *testing.T) {
 				Status:        postgres.Found,
 			},
 			{
-				TimelineID:    5,
-				StartSegment:  "000000050000000000000003",
-				EndSegment:    "000000050000000000000003",
-				SegmentsCount: 1,
-				Status:        postgres.Lost,
+				TimelineID:      5,
+				StartSegment:    "000000050000000000000003",
+				EndSegment:      "000000050000000000000003",
+				SegmentsCount:   1,
+				Status:          postgres.Lost,
+				MissingSegments: []string{"000000050000000000000003"},
 			},
 			{
 				TimelineID:    5,
@@ -444,11 +479,12 @@ func TestWalVerify_TwoTimelines_SomeLost(t *testing.T) {
 				Status:        postgres.Found,
 			},
 			{
-				TimelineID:    6,
-				StartSegment:  "000000060000000000000005",
-				EndSegment:    "000000060000000000000006",
-				SegmentsCount: 2,
-				Status:        postgres.ProbablyUploading,
+				TimelineID:      6,
+				StartSegment:    "000000060000000000000005",
+				EndSegment:      "000000060000000000000006",
+				SegmentsCount:   2,
+				Status:          postgres.ProbablyUploading,
+				MissingSegments: []string{"000000060000000000000005", "000000060000000000000006"},
 			},
 			{
 				TimelineID:    6,
@@ -610,6 +646,223 @@ func TestWalVerify_WalkUntilFirstBackup(t *testing.T) {
 	})
 }
 
+// Check that the integrity check starts from the specified backup instead of the earliest one
+func TestWalVerify_StartFromBackupName(t *testing.T) {
+	storageSegments := []string{
+		"000000010000000000000001",
+		"000000010000000000000002",
+		"000000010000000000000004",
+		"000000010000000000000005",
+		"000000010000000000000006",
+		"000000010000000000000007",
+		"000000010000000000000008",
+		"000000010000000000000009",
+		"00000001000000000000000A",
+	}
+
+	storageFiles := make(map[string]*bytes.Buffer, 4)
+	addMockBackupsStorageFiles(map[string]postgres.ExtendedMetadataDto{
+		"000000010000000000000001": newMockExtendedMetadataDto(false),
+		"000000010000000000000005": newMockExtendedMetadataDto(false),
+	}, storageFiles)
+
+	currentSegmentName := "00000001000000000000000B"
+	currentSegment, _ := postgres.NewWalSegmentDescription(currentSegmentName)
+
+	expectedTimelineCheck := postgres.WalVerifyCheckResult{
+		Status: postgres.StatusOk,
+		Details: postgres.TimelineCheckDetails{
+			CurrentTimelineID:        currentSegment.Timeline,
+			HighestStorageTimelineID: currentSegment.Timeline,
+		},
+	}
+
+	// the missing segment is older than the backup, so it is not needed
+	testWalVerify(t, WalVerifyTestSetup{
+		expectedIntegrityCheck: postgres.WalVerifyCheckResult{
+			Status: postgres.StatusOk,
+			Details: postgres.IntegrityCheckDetails{
+				{
+					TimelineID:    1,
+					StartSegment:  "000000010000000000000005",
+					EndSegment:    "00000001000000000000000A",
+					SegmentsCount: 6,
+					Status:        postgres.Found,
+				},
+			},
+		},
+		expectedTimelineCheck: expectedTimelineCheck,
+		currentWalSegment:     currentSegment,
+		storageSegments:       storageSegments,
+		storageFiles:          storageFiles,
+		startBackupName:       utility.BackupNamePrefix + "000000010000000000000005",
+	})
+
+	// the earliest backup needs the missing segment
+	testWalVerify(t, WalVerifyTestSetup{
+		expectedIntegrityCheck: postgres.WalVerifyCheckResult{
+			Status: postgres.StatusFailure,
+			Details: postgres.IntegrityCheckDetails{
+				{
+					TimelineID:    1,
+					StartSegment:  "000000010000000000000001",
+					EndSegment:    "000000010000000000000002",
+					SegmentsCount: 2,
+					Status:        postgres.Found,
+				},
+				{
+					TimelineID:      1,
+					StartSegment:    "000000010000000000000003",
+					EndSegment:      "000000010000000000000003",
+					SegmentsCount:   1,
+					Status:          postgres.Lost,
+					MissingSegments: []string{"000000010000000000000003"},
+				},
+				{
+					TimelineID:    1,
+					StartSegment:  "000000010000000000000004",
+					EndSegment:    "00000001000000000000000A",
+					SegmentsCount: 7,
+					Status:        postgres.Found,
+				},
+			},
+		},
+		expectedTimelineCheck: expectedTimelineCheck,
+		currentWalSegment:     currentSegment,
+		storageSegments:       storageSegments,
+		storageFiles:          storageFiles,
+	})
+}
+
+// check that wal-verify scans only the segments archived after the previous run, unless the full scan is requested
+func TestWalVerify_Incremental(t *testing.T) {
+	rootFolder := setupTestStorageFolder()
+	storageFiles := make(map[string]*bytes.Buffer)
+	addMockBackupsStorageFiles(map[string]postgres.ExtendedMetadataDto{
+		"000000010000000000000001": newMockExtendedMetadataDto(false),
+	}, storageFiles)
+	for name, content := range storageFiles {
+		assert.NoError(t, rootFolder.PutObject(name, content))
+	}
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	putWalSegments([]string{
+		"000000010000000000000001",
+		"000000010000000000000002",
+		"000000010000000000000003",
+		"000000010000000000000004",
+	}, walFolder)
+
+	result := executeIntegrityCheck(rootFolder, "000000010000000000000005", false)
+	assert.Equal(t, postgres.StatusOk, result.Status)
+	assert.Equal(t, postgres.IntegrityCheckDetails{{
+		TimelineID:    1,
+		StartSegment:  "000000010000000000000001",
+		EndSegment:    "000000010000000000000004",
+		SegmentsCount: 4,
+		Status:        postgres.Found,
+	}}, result.Details)
+
+	// the segment verified by the previous run is not checked again
+	assert.NoError(t, walFolder.DeleteObjects([]string{"000000010000000000000002"}))
+	putWalSegments([]string{
+		"000000010000000000000005",
+		"000000010000000000000006",
+		"000000010000000000000007",
+		"000000010000000000000008",
+		"000000010000000000000009",
+	}, walFolder)
+	result = executeIntegrityCheck(rootFolder, "00000001000000000000000A", false)
+	assert.Equal(t, postgres.StatusOk, result.Status)
+	assert.Equal(t, postgres.IntegrityCheckDetails{{
+		TimelineID:    1,
+		StartSegment:  "000000010000000000000004",
+		EndSegment:    "000000010000000000000009",
+		SegmentsCount: 6,
+		Status:        postgres.Found,
+	}}, result.Details)
+
+	result = executeIntegrityCheck(rootFolder, "00000001000000000000000A", true)
+	assert.Equal(t, postgres.StatusFailure, result.Status)
+}
+
+// check that the checkpoint on the previous timeline is used only up to the timeline switch
+func TestWalVerify_IncrementalTimelineSwitch(t *testing.T) {
+	rootFolder := setupTestStorageFolder()
+	storageFiles := make(map[string]*bytes.Buffer)
+	addMockBackupsStorageFiles(map[string]postgres.ExtendedMetadataDto{
+		"000000010000000000000001": newMockExtendedMetadataDto(false),
+	}, storageFiles)
+	for name, content := range storageFiles {
+		assert.NoError(t, rootFolder.PutObject(name, content))
+	}
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	putWalSegments([]string{
+		"000000010000000000000001",
+		"000000010000000000000002",
+		"000000010000000000000003",
+		"000000010000000000000004",
+		"000000010000000000000005",
+		"000000010000000000000006",
+	}, walFolder)
+	result := executeIntegrityCheck(rootFolder, "000000010000000000000007", false)
+	assert.Equal(t, postgres.StatusOk, result.Status)
+
+	// the timeline 1 is switched from in the 4th segment, after that the checkpoint segments 4-6 are not on the history
+	historyContents := fmt.Sprintf("%d\t0/%X\tsome comment...\n\n", 1, 4*postgres.WalSegmentSize+100)
+	historyName, historyFile, err := newTimelineHistoryFile(historyContents, 2)
+	assert.NoError(t, err)
+	assert.NoError(t, walFolder.PutObject(historyName, historyFile))
+	putWalSegments([]string{
+		"000000020000000000000004",
+		"000000020000000000000005",
+	}, walFolder)
+
+	result = executeIntegrityCheck(rootFolder, "000000020000000000000006", false)
+	assert.Equal(t, postgres.StatusOk, result.Status)
+	assert.Equal(t, postgres.IntegrityCheckDetails{
+		{
+			TimelineID:    1,
+			StartSegment:  "000000010000000000000003",
+			EndSegment:    "000000010000000000000003",
+			SegmentsCount: 1,
+			Status:        postgres.Found,
+		},
+		{
+			TimelineID:    2,
+			StartSegment:  "000000020000000000000004",
+			EndSegment:    "000000020000000000000005",
+			SegmentsCount: 2,
+			Status:        postgres.Found,
+		},
+	}, result.Details)
+
+	var checkpoint postgres.WalVerifyCheckpoint
+	assert.NoError(t, internal.FetchDto(rootFolder, &checkpoint, postgres.WalVerifyCheckpointName))
+	assert.Equal(t, postgres.WalVerifyCheckpoint{
+		FromSegment:     "000000010000000000000001",
+		VerifiedSegment: "000000020000000000000005",
+	}, checkpoint)
+}
+
+func TestIntegrityCheckDetails_PlainTextListsMissingSegments(t *testing.T) {
+	details := postgres.IntegrityCheckDetails{
+		{
+			TimelineID:      1,
+			StartSegment:    "000000010000000000000003",
+			EndSegment:      "000000010000000000000004",
+			SegmentsCount:   2,
+			Status:          postgres.Lost,
+			MissingSegments: []string{"000000010000000000000003", "000000010000000000000004"},
+		},
+	}
+	reader, err := details.NewPlainTextReader()
+	assert.NoError(t, err)
+	output := new(bytes.Buffer)
+	_, err = output.ReadFrom(reader)
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "Missing segments:\n000000010000000000000003\n000000010000000000000004\n")
+}
+
 func addMockBackupsStorageFiles(backups map[string]postgres.ExtendedMetadataDto, storageFiles map[string]*bytes.Buffer) {
 	for name, meta := range backups {
 		// sentinel
@@ -629,7 +882,8 @@ func testWalVerify(t *testing.T, setup WalVerifyTestSetup) {
 	result, outputCallsCount := executeWalVerify(
 		setup.storageSegments,
 		setup.storageFiles,
-		setup.currentWalSegment)
+		setup.currentWalSegment,
+		setup.startBackupName)
 
 	assert.Equal(t, 1, outputCallsCount)
 	compareResults(t, expectedResult, result)
@@ -641,6 +895,7 @@ func executeWalVerify(
 	walFilenames []string,
 	storageFiles map[string]*bytes.Buffer,
 	currentWalSegment postgres.WalSegmentDescription,
+	startBackupName string,
 ) (map[postgres.WalVerifyCheckType]postgres.WalVerifyCheckResult, int) {
 	rootFolder := setupTestStorageFolder()
 	walFolder := rootFolder.GetSubFolder(utility.WalPath)
@@ -653,11 +908,20 @@ func executeWalVerify(
 	checkTypes := []postgres.WalVerifyCheckType{
 		postgres.WalVerifyTimelineCheck, postgres.WalVerifyIntegrityCheck}
 
-	postgres.HandleWalVerify(checkTypes, rootFolder, currentWalSegment, mockOutputWriter)
+	postgres.HandleWalVerify(checkTypes, rootFolder, currentWalSegment, startBackupName, false, mockOutputWriter)
 
 	return mockOutputWriter.lastResult, mockOutputWriter.writeCallsCount
 }
 
+// executeIntegrityCheck invokes the HandleWalVerify() integrity check on the provided storage folder
+func executeIntegrityCheck(rootFolder storage.Folder, currentSegmentName string, fullScan bool) postgres.WalVerifyCheckResult {
+	currentWalSegment, _ := postgres.NewWalSegmentDescription(currentSegmentName)
+	mockOutputWriter := &MockWalVerifyOutputWriter{}
+	postgres.HandleWalVerify([]postgres.WalVerifyCheckType{postgres.WalVerifyIntegrityCheck},
+		rootFolder, currentWalSegment, "", fullScan, mockOutputWriter)
+	return mockOutputWriter.lastResult[postgres.WalVerifyIntegrityCheck]
+}
+
 func compareResults(
 	t *testing.T,
 	expected map[postgres.WalVerifyCheckType]postgres.WalVerifyCheckResult,
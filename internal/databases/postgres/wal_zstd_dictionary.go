@@ -0,0 +1,236 @@
+package postgres
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	// WalZstdDictionariesPath is the folder of the zstd dictionaries inside the WAL folder
+	WalZstdDictionariesPath = "zstd_dictionaries/"
+
+	walZstdDictionaryExtension = ".dict"
+	// walZstdDictionaryMaxAge is the age of the dictionary after which wal-push trains the new one,
+	// so the dictionary follows the changes of the WAL content
+	walZstdDictionaryMaxAge = 24 * time.Hour
+	// the dictionary is trained on the beginnings of the most recent WAL files
+	walZstdDictionarySampleCount = 8
+	walZstdDictionarySampleSize  = 1 << 20
+)
+
+// walZstdDictionaries caches the downloaded dictionaries by their ids
+var walZstdDictionaries sync.Map
+
+// LoadWalZstdDictionary returns the zstd dictionary the WAL file is compressed with. The dictionary is looked up
+// in the local cache of wal-push first, then downloaded from the WAL folder of the storage.
+func LoadWalZstdDictionary(id uint32) ([]byte, error) {
+	if dictionary, ok := walZstdDictionaries.Load(id); ok {
+		return dictionary.([]byte), nil
+	}
+	dictionary, err := os.ReadFile(filepath.Join(getWalZstdDictionaryCachePath(), getWalZstdDictionaryName(id)))
+	if err != nil || zstd.DictionaryID(dictionary) != id {
+		folder, err := internal.ConfigureFolder()
+		if err != nil {
+			return nil, err
+		}
+		dictionary, err = downloadWalZstdDictionary(folder.GetSubFolder(utility.WalPath), id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	walZstdDictionaries.Store(id, dictionary)
+	return dictionary, nil
+}
+
+func downloadWalZstdDictionary(walFolder storage.Folder, id uint32) ([]byte, error) {
+	reader, err := internal.DownloadAndDecompressStorageFile(walFolder.GetSubFolder(WalZstdDictionariesPath),
+		getWalZstdDictionaryName(id))
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(reader, "")
+	dictionary, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if zstd.DictionaryID(dictionary) != id {
+		return nil, errors.Errorf("zstd dictionary %08x is corrupted", id)
+	}
+	return dictionary, nil
+}
+
+// configureWalZstdDictionary makes the uploader compress the WAL files with the zstd dictionary and store
+// the dictionary id in their object metadata, so the storages must keep it. The dictionary is trained
+// on the recent WAL files of walDir and uploaded before the first WAL file compressed with it.
+// It is cached locally and retrained once it is older than walZstdDictionaryMaxAge.
+func configureWalZstdDictionary(uploader *WalUploader, walDir string) error {
+	compressor, ok := uploader.Compressor.(zstd.Compressor)
+	if !ok {
+		return errors.Errorf("%s requires the %s compression method", internal.WalZstdDictSetting, zstd.AlgorithmName)
+	}
+	err := uploader.CheckObjectMetadata()
+	if err != nil {
+		return err
+	}
+
+	dictionary, err := loadCachedWalZstdDictionary()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to load the cached zstd dictionary: %v", err)
+	}
+	if dictionary == nil {
+		dictionary, err = trainWalZstdDictionary(walDir)
+		if err != nil {
+			return err
+		}
+		err = uploadWalZstdDictionary(uploader, dictionary)
+		if err != nil {
+			return err
+		}
+		err = cacheWalZstdDictionary(dictionary)
+		tracelog.WarningLogger.PrintOnError(err)
+	}
+	err = uploader.SetObjectMetadata(zstd.DictionaryMetadata(dictionary))
+	if err != nil {
+		return err
+	}
+	uploader.Compressor = zstd.DictionaryCompressor{Level: compressor.Level, Dictionary: dictionary}
+	return nil
+}
+
+// trainWalZstdDictionary trains the dictionary on the beginnings of the most recent WAL files of walDir
+func trainWalZstdDictionary(walDir string) ([]byte, error) {
+	entries, err := os.ReadDir(walDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the WAL directory")
+	}
+	walNames := make([]string, 0)
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isWalFilename(entry.Name()) {
+			walNames = append(walNames, entry.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(walNames)))
+	if len(walNames) > walZstdDictionarySampleCount {
+		walNames = walNames[:walZstdDictionarySampleCount]
+	}
+
+	samples := make([][]byte, 0, len(walNames))
+	for _, walName := range walNames {
+		sample, err := readWalZstdDictionarySample(filepath.Join(walDir, walName))
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	dictionary, err := zstd.TrainDictionary(samples, zstd.DefaultDictionarySize)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to train the zstd dictionary on %d WAL files", len(samples))
+	}
+	tracelog.InfoLogger.Printf("Trained zstd dictionary %08x of %d bytes on %d WAL files",
+		zstd.DictionaryID(dictionary), len(dictionary), len(samples))
+	return dictionary, nil
+}
+
+func readWalZstdDictionarySample(walFilePath string) ([]byte, error) {
+	file, err := os.Open(walFilePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the WAL file")
+	}
+	defer utility.LoggedClose(file, "")
+	return io.ReadAll(io.LimitReader(file, walZstdDictionarySampleSize))
+}
+
+// uploadWalZstdDictionary uploads the dictionary to the storages the WAL files are uploaded to, encrypted
+// as the WAL files are
+func uploadWalZstdDictionary(uploader *WalUploader, dictionary []byte) error {
+	dictionaryUploader := uploader.Uploader.Clone()
+	dictionaryUploader.Compressor = zstd.Compressor{}
+	// the dictionary itself is compressed without any dictionary
+	err := dictionaryUploader.SetObjectMetadata(nil)
+	if err != nil {
+		return err
+	}
+	dictionaryUploader.ChangeDirectory(WalZstdDictionariesPath)
+	dictionaryName := getWalZstdDictionaryName(zstd.DictionaryID(dictionary))
+	_, err = dictionaryUploader.UploadFile(ioextensions.NewNamedReaderImpl(bytes.NewReader(dictionary), dictionaryName))
+	return errors.Wrapf(err, "failed to upload the zstd dictionary")
+}
+
+// loadCachedWalZstdDictionary returns the most recent cached dictionary, or nil if it is older than walZstdDictionaryMaxAge
+func loadCachedWalZstdDictionary() ([]byte, error) {
+	entries, err := os.ReadDir(getWalZstdDictionaryCachePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var latestName string
+	var latestModTime time.Time
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), walZstdDictionaryExtension) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().After(latestModTime) {
+			latestName, latestModTime = entry.Name(), info.ModTime()
+		}
+	}
+	if latestName == "" || time.Since(latestModTime) > walZstdDictionaryMaxAge {
+		return nil, nil
+	}
+	dictionary, err := os.ReadFile(filepath.Join(getWalZstdDictionaryCachePath(), latestName))
+	if err != nil {
+		return nil, err
+	}
+	if getWalZstdDictionaryName(zstd.DictionaryID(dictionary)) != latestName {
+		return nil, errors.Errorf("cached zstd dictionary %s is corrupted", latestName)
+	}
+	return dictionary, nil
+}
+
+// cacheWalZstdDictionary saves the dictionary to the local cache and removes the previous dictionaries from it
+func cacheWalZstdDictionary(dictionary []byte) error {
+	cachePath := getWalZstdDictionaryCachePath()
+	err := os.MkdirAll(cachePath, 0700)
+	if err != nil {
+		return err
+	}
+	dictionaryName := getWalZstdDictionaryName(zstd.DictionaryID(dictionary))
+	entries, err := os.ReadDir(cachePath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() != dictionaryName {
+			tracelog.WarningLogger.PrintOnError(os.Remove(filepath.Join(cachePath, entry.Name())))
+		}
+	}
+	return os.WriteFile(filepath.Join(cachePath, dictionaryName), dictionary, 0600)
+}
+
+func getWalZstdDictionaryCachePath() string {
+	return filepath.Join(internal.GetDataFolderPath(), "walg_zstd_dictionaries")
+}
+
+func getWalZstdDictionaryName(id uint32) string {
+	return fmt.Sprintf("%08x%s", id, walZstdDictionaryExtension)
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
+	"github.com/wal-g/wal-g/pkg/storages/fs"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func writeDictionaryTestWals(t *testing.T, walDir string, count int) {
+	require.NoError(t, os.MkdirAll(walDir, 0700))
+	for i := 1; i <= count; i++ {
+		var content bytes.Buffer
+		for j := 0; j < 2000; j++ {
+			fmt.Fprintf(&content, "%-64s", fmt.Sprintf("shared WAL record %d", j%100))
+			fmt.Fprintf(&content, "%-64s", fmt.Sprintf("WAL %d record %d", i, j))
+		}
+		walName := fmt.Sprintf("0000000100000000000000%02X", i)
+		require.NoError(t, os.WriteFile(filepath.Join(walDir, walName), content.Bytes(), 0600))
+	}
+}
+
+func TestConfigureWalZstdDictionary(t *testing.T) {
+	pgData := t.TempDir()
+	viper.Set(internal.PgDataSetting, pgData)
+	defer viper.Set(internal.PgDataSetting, "")
+	walDir := filepath.Join(pgData, "pg_wal")
+	writeDictionaryTestWals(t, walDir, 3)
+
+	folder := memory.NewFolder("", memory.NewStorage())
+	uploader := NewWalUploader(zstd.Compressor{}, folder.GetSubFolder(utility.WalPath), nil)
+	require.NoError(t, configureWalZstdDictionary(uploader, walDir))
+	compressor, ok := uploader.Compressor.(zstd.DictionaryCompressor)
+	require.True(t, ok)
+	dictionaryID := zstd.DictionaryID(compressor.Dictionary)
+
+	cached, err := loadCachedWalZstdDictionary()
+	require.NoError(t, err)
+	assert.Equal(t, compressor.Dictionary, cached)
+	dictionary, err := downloadWalZstdDictionary(folder.GetSubFolder(utility.WalPath), dictionaryID)
+	require.NoError(t, err)
+	assert.Equal(t, compressor.Dictionary, dictionary)
+
+	walPath := filepath.Join(walDir, "000000010000000000000002")
+	require.NoError(t, uploadWALFile(uploader, walPath, false))
+	// the dictionary id is kept in the object metadata, the compressed content is not changed
+	archiveReader, metadata, err := storage.ReadObjectWithMetadata(folder.GetSubFolder(utility.WalPath),
+		filepath.Base(walPath)+"."+zstd.FileExtension)
+	require.NoError(t, err)
+	assert.Equal(t, zstd.DictionaryMetadata(dictionary), metadata)
+	archived, err := io.ReadAll(archiveReader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x28, 0xb5, 0x2f, 0xfd}, archived[:4])
+	zstd.SetDictionaryLoader(func(id uint32) ([]byte, error) {
+		assert.Equal(t, dictionaryID, id)
+		return dictionary, nil
+	})
+	defer zstd.SetDictionaryLoader(nil)
+	// the archived WAL file is compared with the local one when archive_command is retried
+	require.NoError(t, uploadWALFile(uploader, walPath, true))
+	reader, err := internal.DownloadAndDecompressStorageFile(folder.GetSubFolder(utility.WalPath), filepath.Base(walPath))
+	require.NoError(t, err)
+	fetched, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	expected, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+	assert.Equal(t, expected, fetched)
+}
+
+func TestConfigureWalZstdDictionary_NoObjectMetadata(t *testing.T) {
+	walDir := t.TempDir()
+	writeDictionaryTestWals(t, walDir, 3)
+	folder := fs.NewFolder(t.TempDir(), "")
+	uploader := NewWalUploader(zstd.Compressor{}, folder, nil)
+
+	err := configureWalZstdDictionary(uploader, walDir)
+	assert.IsType(t, internal.UnsupportedObjectMetadataError{}, err)
+	assert.Equal(t, zstd.Compressor{}, uploader.Compressor)
+	exists, err := folder.Exists(WalZstdDictionariesPath)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestConfigureWalZstdDictionary_NotZstd(t *testing.T) {
+	uploader := NewWalUploader(nil, memory.NewFolder("", memory.NewStorage()), nil)
+	assert.Error(t, configureWalZstdDictionary(uploader, t.TempDir()))
+}
@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
 	"github.com/wal-g/wal-g/testtools"
@@ -427,7 +428,7 @@ func testWalk(t *testing.T, composer postgres.TarBallComposerType, withoutFilesM
 	walFileName := filepath.Join(data, "1")
 	walFile, err := os.Open(walFileName)
 	assert.NoError(t, err)
-	err = uploader.UploadFile(walFile)
+	_, err = uploader.UploadFile(walFile)
 
 	if err != nil {
 		// t.Errorf("upload: expected no error to occur but got %+v", err)
@@ -436,7 +437,7 @@ func testWalk(t *testing.T, composer postgres.TarBallComposerType, withoutFilesM
 }
 
 func setupTestTarBallComposerMaker(composer postgres.TarBallComposerType, withoutFilesMetadata bool) postgres.TarBallComposerMaker {
-	filePackOptions := postgres.NewTarBallFilePackerOptions(false, false)
+	filePackOptions := postgres.NewTarBallFilePackerOptions(false, false, 1)
 	switch composer {
 	case postgres.RegularComposer:
 		if withoutFilesMetadata {
@@ -455,3 +456,83 @@ func setupTestTarBallComposerMaker(composer postgres.TarBallComposerType, withou
 		return nil
 	}
 }
+
+// walkWithSymlinks walks the data directory with the pg_wal and the ext_dir symlinked
+// to the directories outside of it, and restores the backup
+func walkWithSymlinks(t *testing.T, followedSymlinks map[string]utility.Empty) (string, string, string) {
+	data := t.TempDir()
+	writeTestFile(t, filepath.Join(data, "base", "5", "16384"))
+	walTarget := t.TempDir()
+	writeTestFile(t, filepath.Join(walTarget, "000000010000000000000001"))
+	extTarget := t.TempDir()
+	writeTestFile(t, filepath.Join(extTarget, "nested", "file"))
+	require.NoError(t, os.Symlink(walTarget, filepath.Join(data, "pg_wal")))
+	require.NoError(t, os.Symlink(extTarget, filepath.Join(data, "ext_dir")))
+
+	bundle := postgres.NewBundle(data, nil, nil, nil, false, int64(10))
+	bundle.FollowedSymlinks = followedSymlinks
+	compressed := t.TempDir()
+	size := int64(0)
+	require.NoError(t, bundle.StartQueue(&testtools.FileTarBallMaker{Out: compressed, Size: &size}))
+	require.NoError(t, bundle.SetupComposer(setupTestTarBallComposerMaker(postgres.RegularComposer, false)))
+	require.NoError(t, filepath.Walk(data, bundle.HandleWalkedFSObject))
+	_, err := bundle.FinishTarComposer()
+	require.NoError(t, err)
+	require.NoError(t, bundle.FinishQueue())
+
+	files, err := os.ReadDir(compressed)
+	require.NoError(t, err)
+	tars := make([]internal.ReaderMaker, 0, len(files))
+	for _, file := range files {
+		tars = append(tars, &testtools.FileReaderMaker{Key: filepath.Join(compressed, file.Name())})
+	}
+	restored := t.TempDir()
+	tarInterpreter := postgres.NewFileTarInterpreter(restored, postgres.BackupSentinelDto{},
+		postgres.FilesMetadataDto{}, nil, false)
+	require.NoError(t, internal.ExtractAll(tarInterpreter, tars))
+	return restored, walTarget, extTarget
+}
+
+func TestWalk_SymlinksAreNotFollowed(t *testing.T) {
+	restored, walTarget, extTarget := walkWithSymlinks(t, nil)
+
+	assert.FileExists(t, filepath.Join(restored, "base", "5", "16384"))
+	// the symlink is restored pointing to the same target
+	info, err := os.Lstat(filepath.Join(restored, "ext_dir"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+	restoredTarget, err := os.Readlink(filepath.Join(restored, "ext_dir"))
+	require.NoError(t, err)
+	assert.Equal(t, extTarget, restoredTarget)
+	// the excluded pg_wal is restored as the empty directory instead of the symlink to the original path
+	info, err = os.Lstat(filepath.Join(restored, "pg_wal"))
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.True(t, isEmpty(t, filepath.Join(restored, "pg_wal")))
+	assert.FileExists(t, filepath.Join(walTarget, "000000010000000000000001"))
+}
+
+func TestWalk_FollowedSymlinks(t *testing.T) {
+	restored, walTarget, extTarget := walkWithSymlinks(t, map[string]utility.Empty{"/pg_wal": {}, "/ext_dir": {}})
+
+	// the targets are restored in place of the symlinks, the excluded pg_wal is created empty
+	for _, name := range []string{"pg_wal", "ext_dir"} {
+		info, err := os.Lstat(filepath.Join(restored, name))
+		require.NoError(t, err)
+		assert.True(t, info.IsDir(), name)
+	}
+	assert.True(t, isEmpty(t, filepath.Join(restored, "pg_wal")))
+	content, err := os.ReadFile(filepath.Join(restored, "ext_dir", "nested", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(extTarget, "nested", "file"), string(content))
+	assert.FileExists(t, filepath.Join(walTarget, "000000010000000000000001"))
+}
+
+func TestParseFollowedSymlinks(t *testing.T) {
+	followedSymlinks, err := postgres.ParseFollowedSymlinks(" pg_wal, ext/dir/ ,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]utility.Empty{"/pg_wal": {}, "/ext/dir": {}}, followedSymlinks)
+
+	_, err = postgres.ParseFollowedSymlinks("pg_wal,/var/lib/wal")
+	assert.IsType(t, postgres.InvalidFollowedSymlinkError{}, err)
+}
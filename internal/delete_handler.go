@@ -3,6 +3,7 @@ package internal
 import (
 	"fmt"
 	"os"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
@@ -339,9 +340,11 @@ func (h *DeleteHandler) FindTargetRetainAfterTime(retentionCount int, timeLine t
 }
 
 func (h *DeleteHandler) DeleteEverything(confirmed bool) {
-	filter := func(object storage.Object) bool { return true }
+	lockFilter := h.newObjectLockFilter(h.Folder, time.Now())
+	filter := func(object storage.Object) bool { return !lockFilter.isLocked(object) }
 	folderFilter := func(path string) bool { return true }
 	err := storage.DeleteObjectsWhere(h.Folder, confirmed, filter, folderFilter)
+	lockFilter.report()
 	tracelog.ErrorLogger.FatalOnError(err)
 }
 
@@ -360,8 +363,11 @@ func (h *DeleteHandler) DeleteBeforeTargetWhere(target BackupObject, confirmed b
 	}
 	tracelog.InfoLogger.Println("Start delete")
 
+	lockFilter := h.newObjectLockFilter(h.Folder, time.Now())
+	defer lockFilter.report()
 	return storage.DeleteObjectsWhere(h.Folder, confirmed, func(object storage.Object) bool {
-		return objSelector(object) && h.less(object, target) && !h.isPermanent(object) && !h.isIgnored(object)
+		return objSelector(object) && h.less(object, target) && !h.isPermanent(object) && !h.isIgnored(object) &&
+			!lockFilter.isLocked(object)
 	}, folderFilter)
 }
 
@@ -375,12 +381,109 @@ func (h *DeleteHandler) DeleteTargets(targets []BackupObject, confirmed bool) er
 	}
 
 	folderFilter := func(path string) bool { return true }
+	lockFilter := h.newObjectLockFilter(h.Folder.GetSubFolder(utility.BaseBackupPath), time.Now())
+	defer lockFilter.report()
 	return storage.DeleteObjectsWhere(h.Folder.GetSubFolder(utility.BaseBackupPath),
 		confirmed, func(object storage.Object) bool {
-			return backupNamesToDelete[utility.StripLeftmostBackupName(object.GetName())] && !h.isPermanent(object) && !h.isIgnored(object)
+			return backupNamesToDelete[utility.StripLeftmostBackupName(object.GetName())] && !h.isPermanent(object) && !h.isIgnored(object) &&
+				!lockFilter.isLocked(object)
 		}, folderFilter)
 }
 
+// objectLockFilter keeps the objects which are still protected by the object lock of the storage
+// and all the objects of the backups which sentinels are still locked. The sentinel is uploaded last,
+// so the backup may have the files which are not locked anymore, and they must not be deleted before it.
+// The retention of every object is read from the storage, since it may differ from the configured one,
+// e.g. if the retention setting is changed or the bucket has the longer default retention.
+type objectLockFilter struct {
+	// folder is the one the names of the filtered objects are relative to
+	folder     storage.Folder
+	rootFolder storage.Folder
+	now        time.Time
+	// backupSentinels are the paths of the backup sentinels in the root folder by the backup names
+	backupSentinels map[string]string
+	sentinelLocks   map[string]objectLock
+	keptCount       int
+}
+
+// objectLock is the time the lock of the object expires, zero if it is not locked
+type objectLock struct {
+	retainUntil time.Time
+	err         error
+}
+
+// newObjectLockFilter returns nil, which keeps nothing, if the storage doesn't lock the uploaded objects.
+// The names of the filtered objects are relative to the folder.
+func (h *DeleteHandler) newObjectLockFilter(folder storage.Folder, now time.Time) *objectLockFilter {
+	if storage.GetObjectLockRetention(h.Folder) <= 0 {
+		return nil
+	}
+	filter := &objectLockFilter{folder: folder, rootFolder: h.Folder, now: now,
+		backupSentinels: make(map[string]string), sentinelLocks: make(map[string]objectLock)}
+	for _, backup := range h.backups {
+		filter.backupSentinels[backup.GetBackupName()] = path.Join(utility.BaseBackupPath, backup.GetName())
+	}
+	return filter
+}
+
+func (filter *objectLockFilter) isLocked(object storage.Object) bool {
+	if filter == nil {
+		return false
+	}
+	retainUntil, err := storage.GetObjectRetainUntil(filter.folder, object.GetName())
+	if err != nil {
+		tracelog.WarningLogger.Printf("\tkept, failed to read the object lock: %s: %v\n", object.GetName(), err)
+		filter.keptCount++
+		return true
+	}
+	if retainUntil.After(filter.now) {
+		tracelog.WarningLogger.Printf("\tkept, locked until %s: %s\n", retainUntil.Format(time.RFC3339), object.GetName())
+		filter.keptCount++
+		return true
+	}
+	for _, pathPart := range strings.Split(object.GetName(), "/") {
+		backupName := utility.StripLeftmostBackupName(pathPart)
+		sentinelLock, ok := filter.getSentinelLock(backupName)
+		if !ok {
+			continue
+		}
+		if sentinelLock.err != nil {
+			tracelog.WarningLogger.Printf("\tkept, failed to read the object lock of the sentinel of backup %s: %s: %v\n",
+				backupName, object.GetName(), sentinelLock.err)
+			filter.keptCount++
+			return true
+		}
+		if sentinelLock.retainUntil.After(filter.now) {
+			tracelog.WarningLogger.Printf("\tkept, sentinel of backup %s is locked until %s: %s\n",
+				backupName, sentinelLock.retainUntil.Format(time.RFC3339), object.GetName())
+			filter.keptCount++
+			return true
+		}
+	}
+	return false
+}
+
+// getSentinelLock reads the lock of the sentinel of the backup once, false if there is no such backup
+func (filter *objectLockFilter) getSentinelLock(backupName string) (objectLock, bool) {
+	sentinelPath, ok := filter.backupSentinels[backupName]
+	if !ok {
+		return objectLock{}, false
+	}
+	lock, ok := filter.sentinelLocks[backupName]
+	if !ok {
+		lock.retainUntil, lock.err = storage.GetObjectRetainUntil(filter.rootFolder, sentinelPath)
+		filter.sentinelLocks[backupName] = lock
+	}
+	return lock, true
+}
+
+func (filter *objectLockFilter) report() {
+	if filter != nil && filter.keptCount > 0 {
+		tracelog.WarningLogger.Printf("%d objects are protected by the object lock, "+
+			"they are kept until their retention expires\n", filter.keptCount)
+	}
+}
+
 // Find all backups related to the target.
 // All delta backups with the same base backup are considered as related.
 func (h *DeleteHandler) findRelatedBackups(target BackupObject) []BackupObject {
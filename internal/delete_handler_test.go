@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// objectLockTestFolder locks the objects until the times by their paths in the root folder
+type objectLockTestFolder struct {
+	*memory.Folder
+	retention   time.Duration
+	retainUntil map[string]time.Time
+}
+
+func (folder *objectLockTestFolder) GetObjectLockRetention() time.Duration {
+	return folder.retention
+}
+
+func (folder *objectLockTestFolder) GetObjectRetainUntil(objectRelativePath string) (time.Time, error) {
+	return folder.retainUntil[objectRelativePath], nil
+}
+
+func (folder *objectLockTestFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	subFolder := &objectLockTestFolder{Folder: folder.Folder.GetSubFolder(subFolderRelativePath).(*memory.Folder),
+		retention: folder.retention, retainUntil: make(map[string]time.Time)}
+	prefix := strings.TrimSuffix(subFolderRelativePath, "/") + "/"
+	for objectPath, retainUntil := range folder.retainUntil {
+		if strings.HasPrefix(objectPath, prefix) {
+			subFolder.retainUntil[strings.TrimPrefix(objectPath, prefix)] = retainUntil
+		}
+	}
+	return subFolder
+}
+
+type objectLockTestBackup struct {
+	storage.Object
+}
+
+func (backup objectLockTestBackup) GetBackupTime() time.Time {
+	return backup.GetLastModified()
+}
+
+func (backup objectLockTestBackup) GetBackupName() string {
+	return utility.StripRightmostBackupName(backup.GetName())
+}
+
+func (backup objectLockTestBackup) IsFullBackup() bool {
+	return true
+}
+
+func (backup objectLockTestBackup) GetBaseBackupName() string {
+	return backup.GetBackupName()
+}
+
+func (backup objectLockTestBackup) GetIncrementFromName() string {
+	return backup.GetBackupName()
+}
+
+func TestObjectLockFilter(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	oldSentinel := storage.NewLocalObject("base_000000010000000000000002"+utility.SentinelSuffix, now.AddDate(0, 0, -10), 1)
+	lockedSentinel := storage.NewLocalObject("base_000000010000000000000004"+utility.SentinelSuffix, now.AddDate(0, 0, -6), 1)
+	folder := &objectLockTestFolder{Folder: memory.NewFolder("", memory.NewStorage()), retention: 7 * 24 * time.Hour,
+		retainUntil: map[string]time.Time{
+			"basebackups_005/" + oldSentinel.GetName():    now.AddDate(0, 0, -3),
+			"basebackups_005/" + lockedSentinel.GetName(): now.AddDate(0, 0, 1),
+			// the segment is locked longer than the configured retention, e.g. by the default retention of the bucket
+			"wal_005/000000010000000000000003.lz4": now.AddDate(0, 0, 20),
+			"wal_005/000000010000000000000005.lz4": now.AddDate(0, 0, 6),
+		}}
+	handler := NewDeleteHandler(folder, []BackupObject{objectLockTestBackup{oldSentinel}, objectLockTestBackup{lockedSentinel}},
+		func(object1, object2 storage.Object) bool {
+			return object1.GetLastModified().Before(object2.GetLastModified())
+		})
+
+	filter := handler.newObjectLockFilter(folder, now)
+	assert.False(t, filter.isLocked(storage.NewLocalObject("basebackups_005/"+oldSentinel.GetName(), oldSentinel.GetLastModified(), 1)))
+	assert.False(t, filter.isLocked(storage.NewLocalObject("basebackups_005/base_000000010000000000000002/tar_partitions/part_1.tar.lz4",
+		now.AddDate(0, 0, -10), 1)))
+	assert.True(t, filter.isLocked(storage.NewLocalObject("basebackups_005/"+lockedSentinel.GetName(), lockedSentinel.GetLastModified(), 1)))
+	// the part of the backup is not locked anymore, but its sentinel is still locked
+	assert.True(t, filter.isLocked(storage.NewLocalObject("basebackups_005/base_000000010000000000000004/tar_partitions/part_1.tar.lz4",
+		now.AddDate(0, 0, -8), 1)))
+	assert.True(t, filter.isLocked(storage.NewLocalObject("wal_005/000000010000000000000003.lz4", now.AddDate(0, 0, -8), 1)))
+	assert.True(t, filter.isLocked(storage.NewLocalObject("wal_005/000000010000000000000005.lz4", now.AddDate(0, 0, -1), 1)))
+	assert.False(t, filter.isLocked(storage.NewLocalObject("wal_005/000000010000000000000007.lz4", now.AddDate(0, 0, -1), 1)))
+	assert.Equal(t, 4, filter.keptCount)
+
+	// the names of the backup objects are relative to the folder of the backups
+	baseBackupFilter := handler.newObjectLockFilter(folder.GetSubFolder(utility.BaseBackupPath), now)
+	assert.True(t, baseBackupFilter.isLocked(lockedSentinel))
+	assert.True(t, baseBackupFilter.isLocked(storage.NewLocalObject("base_000000010000000000000004/tar_partitions/part_1.tar.lz4",
+		now.AddDate(0, 0, -8), 1)))
+	assert.False(t, baseBackupFilter.isLocked(oldSentinel))
+}
+
+func TestObjectLockFilter_NotLocked(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	handler := NewDeleteHandler(folder, nil, func(object1, object2 storage.Object) bool { return false })
+
+	filter := handler.newObjectLockFilter(folder, time.Now())
+	assert.Nil(t, filter)
+	assert.False(t, filter.isLocked(storage.NewLocalObject("wal_005/000000010000000000000005.lz4", time.Now(), 1)))
+	filter.report()
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// The exit codes of the failure categories, they are a part of the interface and must not be changed.
+// The codes follow sysexits.h, except for ExitCodeNotFound: wal-fetch has always exited with
+// EX_IOERR (74) when the WAL file is missing, so 74 is kept for all the missing objects.
+const (
+	ExitCodeError          = 1
+	ExitCodeUsage          = 64 // EX_USAGE: the invalid arguments or configuration
+	ExitCodeDataCorruption = 65 // EX_DATAERR: the checksum mismatch, the truncated or malformed object
+	ExitCodeStorageIO      = 69 // EX_UNAVAILABLE: the storage is unreachable or failed the request
+	ExitCodeNotFound       = 74 // the backup, the WAL file or another object doesn't exist
+	ExitCodeTimeout        = 75 // EX_TEMPFAIL: the operation exceeded its timeout
+	ExitCodePermission     = 77 // EX_NOPERM: the storage denied the access or the local file isn't accessible
+)
+
+// exitCoder is implemented by the errors which belong to the failure category by their type
+type exitCoder interface {
+	ExitCode() int
+}
+
+// ExitCodeOf returns the exit code of the error category, ExitCodeError if the error is not categorized
+func ExitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var categorized exitCoder
+	if errors.As(err, &categorized) {
+		return categorized.ExitCode()
+	}
+	var notFoundErr storage.ObjectNotFoundError
+	if errors.As(err, &notFoundErr) {
+		return ExitCodeNotFound
+	}
+	if statusCode, ok := getStorageStatusCode(err); ok {
+		switch statusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ExitCodePermission
+		case http.StatusNotFound:
+			return ExitCodeNotFound
+		default:
+			return ExitCodeStorageIO
+		}
+	}
+	if errors.Is(err, os.ErrPermission) {
+		return ExitCodePermission
+	}
+	var netErr net.Error
+	var storageErr storage.Error
+	if errors.As(err, &netErr) || errors.As(err, &storageErr) {
+		return ExitCodeStorageIO
+	}
+	return ExitCodeError
+}
+
+var exitHooks struct {
+	sync.Mutex
+	hooks []func()
+}
+
+// AddExitHook adds the function called before the Fatal* functions below exit, e.g. to export the collected
+// telemetry: the process exits then without running the deferred functions and the post-run hooks
+func AddExitHook(hook func()) {
+	exitHooks.Lock()
+	defer exitHooks.Unlock()
+	exitHooks.hooks = append(exitHooks.hooks, hook)
+}
+
+func exit(exitCode int) {
+	exitHooks.Lock()
+	hooks := exitHooks.hooks
+	exitHooks.hooks = nil
+	exitHooks.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+	os.Exit(exitCode)
+}
+
+// FatalOnError logs the error and exits with the exit code of its category,
+// as tracelog.ErrorLogger.FatalOnError does with the exit code 1
+func FatalOnError(err error) {
+	if err != nil {
+		tracelog.ErrorLogger.PrintError(err)
+		exit(ExitCodeOf(err))
+	}
+}
+
+// FatalfOnError logs the error with the format and exits with the exit code of its category
+func FatalfOnError(format string, err error) {
+	if err != nil {
+		tracelog.ErrorLogger.Printf(format, err)
+		exit(ExitCodeOf(err))
+	}
+}
+
+// FatalfWithExitCode logs the message and exits with the exit code,
+// e.g. ExitCodeUsage for the incompatible options
+func FatalfWithExitCode(exitCode int, format string, args ...interface{}) {
+	tracelog.ErrorLogger.Printf(format, args...)
+	exit(exitCode)
+}
+
+// ExitWithCode exits with the exit code after the exit hooks, e.g. for the command whose exit code is its result
+func ExitWithCode(exitCode int) {
+	exit(exitCode)
+}
+
+// FatalUsageOnError logs the error and exits with ExitCodeUsage, e.g. on the invalid flag value
+func FatalUsageOnError(err error) {
+	if err != nil {
+		tracelog.ErrorLogger.PrintError(err)
+		exit(ExitCodeUsage)
+	}
+}
@@ -0,0 +1,48 @@
+package internal_test
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"google.golang.org/api/googleapi"
+)
+
+func TestExitCodeOf(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	archiveErr := internal.DownloadFileTo(folder, "000000010000000000000001", filepath.Join(t.TempDir(), "wal"))
+	truncatedErr := internal.CheckObjectSize("part_1.tar.lz4", 10, internal.ObjectChecksum{Size: 20})
+	corruptedErr := internal.CheckObjectSize("part_1.tar.lz4", 30, internal.ObjectChecksum{Size: 20})
+
+	for name, testCase := range map[string]struct {
+		err      error
+		exitCode int
+	}{
+		"nil":                {nil, 0},
+		"uncategorized":      {errors.New("unknown"), internal.ExitCodeError},
+		"backup not found":   {internal.NewBackupNonExistenceError("base_000000010000000000000002"), internal.ExitCodeNotFound},
+		"no backups":         {errors.Wrap(internal.NewNoBackupsFoundError(), "select"), internal.ExitCodeNotFound},
+		"archive not found":  {archiveErr, internal.ExitCodeNotFound},
+		"object not found":   {storage.NewObjectNotFoundError("wal_005/1"), internal.ExitCodeNotFound},
+		"truncated object":   {truncatedErr, internal.ExitCodeDataCorruption},
+		"corrupted object":   {corruptedErr, internal.ExitCodeDataCorruption},
+		"unset setting":      {internal.NewUnsetRequiredSettingError("WALG_S3_PREFIX"), internal.ExitCodeUsage},
+		"access denied":      {storage.NewError(statusCodeError{http.StatusForbidden}, "S3", "read"), internal.ExitCodePermission},
+		"missing key":        {&googleapi.Error{Code: http.StatusNotFound}, internal.ExitCodeNotFound},
+		"server error":       {statusCodeError{http.StatusServiceUnavailable}, internal.ExitCodeStorageIO},
+		"network error":      {&net.OpError{Op: "dial", Err: errors.New("connection refused")}, internal.ExitCodeStorageIO},
+		"storage error":      {storage.NewError(errors.New("broken pipe"), "GCS", "write"), internal.ExitCodeStorageIO},
+		"local access error": {&os.PathError{Op: "open", Path: "/pgdata", Err: os.ErrPermission}, internal.ExitCodePermission},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, testCase.exitCode, internal.ExitCodeOf(testCase.err))
+		})
+	}
+}
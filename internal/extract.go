@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/compression"
 	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/tracing"
 	"github.com/wal-g/wal-g/utility"
 	"golang.org/x/sync/semaphore"
 )
@@ -139,36 +141,88 @@ func DecryptAndDecompressTar(reader io.Reader, filePath string, crypter crypto.C
 // ExtractAll Handles all files passed in. Supports `.lzo`, `.lz4`, `.lzma`, and `.tar`.
 // File type `.nop` is used for testing purposes. Each file is extracted
 // in its own goroutine and ExtractAll will wait for all goroutines to finish.
+// At most WALG_DOWNLOAD_CONCURRENCY files are extracted at the same time.
 // Retries unsuccessful attempts log2(MaxConcurrency) times, dividing concurrency by two each time.
+//
+// Concurrent extraction is safe for the TarInterpreter as long as each local file is contained
+// in exactly one of the passed files, which holds for the tar members of a single backup.
+// Delta backups must be extracted by separate ExtractAll calls, one backup at a time.
 func ExtractAll(tarInterpreter TarInterpreter, files []ReaderMaker) error {
-	return ExtractAllWithSleeper(tarInterpreter, files, NewExponentialSleeper(MinExtractRetryWait, MaxExtractRetryWait))
+	return ExtractAllWithProgress(tarInterpreter, files, nil)
+}
+
+// ExtractAllWithProgress is ExtractAll that counts the extracted bytes of all the workers in the progress,
+// the bytes of the failed attempts are discarded from it. The progress may be nil.
+func ExtractAllWithProgress(tarInterpreter TarInterpreter, files []ReaderMaker, progress *ExtractProgress) error {
+	return extractAll(tarInterpreter, files,
+		NewExponentialSleeper(MinExtractRetryWait, MaxExtractRetryWait), progress)
 }
 
 func ExtractAllWithSleeper(tarInterpreter TarInterpreter, files []ReaderMaker, sleeper Sleeper) error {
+	return extractAll(tarInterpreter, files, sleeper, nil)
+}
+
+func extractAll(tarInterpreter TarInterpreter, files []ReaderMaker, sleeper Sleeper, progress *ExtractProgress) error {
+	failed, err := extractAllWithRetries(tarInterpreter, files, sleeper, progress)
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to extract files:\n%s\n", strings.Join(failedFilePaths(failed), "\n"))
+	}
+	return nil
+}
+
+// ExtractAllKeepGoing is ExtractAllWithProgress which doesn't fail if some files still fail after all the retries,
+// it returns their extraction errors by the storage paths instead, so the caller can restore the rest
+func ExtractAllKeepGoing(tarInterpreter TarInterpreter, files []ReaderMaker, progress *ExtractProgress) (map[string]error, error) {
+	return extractAllWithRetries(tarInterpreter, files,
+		NewExponentialSleeper(MinExtractRetryWait, MaxExtractRetryWait), progress)
+}
+
+// extractAllWithRetries retries the failed files halving the concurrency each time,
+// the files which fail all at once with the concurrency of 1 are returned with their errors
+func extractAllWithRetries(tarInterpreter TarInterpreter, files []ReaderMaker, sleeper Sleeper,
+	progress *ExtractProgress) (map[string]error, error) {
 	if len(files) == 0 {
-		return newNoFilesToExtractError()
+		return nil, newNoFilesToExtractError()
 	}
 
 	// Set maximum number of goroutines spun off by ExtractAll
 	downloadingConcurrency, err := GetMaxDownloadConcurrency()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for currentRun := files; len(currentRun) > 0; {
-		failed := tryExtractFiles(currentRun, tarInterpreter, downloadingConcurrency)
+		failed := extractFiles(currentRun, tarInterpreter, downloadingConcurrency, progress)
 		if downloadingConcurrency > 1 {
 			downloadingConcurrency /= 2
 		} else if len(failed) == len(currentRun) {
-			return errors.Errorf("failed to extract files:\n%s\n",
-				strings.Join(readerMakersToFilePaths(failed), "\n"))
+			failedByPath := make(map[string]error, len(failed))
+			for file, err := range failed {
+				failedByPath[file.StoragePath()] = err
+			}
+			return failedByPath, nil
+		}
+		currentRun = currentRun[:0:0]
+		for file := range failed {
+			currentRun = append(currentRun, file)
 		}
-		currentRun = failed
 		if len(failed) > 0 {
 			sleeper.Sleep()
 		}
 	}
 
-	return nil
+	return nil, nil
+}
+
+func failedFilePaths(failed map[string]error) []string {
+	paths := make([]string, 0, len(failed))
+	for path := range failed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
 }
 
 // Extract single file from backup
@@ -190,25 +244,58 @@ func extractFile(tarInterpreter TarInterpreter, extractingReader io.Reader, file
 	}
 }
 
-// TODO : unit tests
-func tryExtractFiles(files []ReaderMaker,
+// ExtractAllOnce extracts the files without retries, as opposed to the ExtractAll.
+// Returns the extraction errors of the failed files by their storage paths.
+func ExtractAllOnce(tarInterpreter TarInterpreter, files []ReaderMaker) (map[string]error, error) {
+	if len(files) == 0 {
+		return nil, newNoFilesToExtractError()
+	}
+	downloadingConcurrency, err := GetMaxDownloadConcurrency()
+	if err != nil {
+		return nil, err
+	}
+	failed := make(map[string]error)
+	for file, err := range extractFiles(files, tarInterpreter, downloadingConcurrency, nil) {
+		failed[file.StoragePath()] = err
+	}
+	return failed, nil
+}
+
+func extractFiles(files []ReaderMaker,
 	tarInterpreter TarInterpreter,
-	downloadingConcurrency int) (failed []ReaderMaker) {
+	downloadingConcurrency int,
+	progress *ExtractProgress) (failed map[ReaderMaker]error) {
 	downloadingContext := context.TODO()
 	downloadingSemaphore := semaphore.NewWeighted(int64(downloadingConcurrency))
 	crypter := ConfigureCrypter()
 	isFailed := sync.Map{}
+	// Should never happen, but if we are asked to cancel - consider all files unfinished
+	allFailed := func(err error) map[ReaderMaker]error {
+		failed = make(map[ReaderMaker]error, len(files))
+		for _, file := range files {
+			failed[file] = err
+		}
+		return failed
+	}
 
 	for _, file := range files {
 		err := downloadingSemaphore.Acquire(downloadingContext, 1)
 		if err != nil {
 			tracelog.ErrorLogger.Println(err)
-			return files //Should never happen, but if we are asked to cancel - consider all files unfinished
+			return allFailed(err)
 		}
 		fileClosure := file
 
 		go func() {
 			defer downloadingSemaphore.Release(1)
+			_, span := tracing.StartSpan(downloadingContext, "extract tar member",
+				tracing.String("tar.member", fileClosure.StoragePath()))
+			var err error
+			var extractedSize int64
+			defer func() {
+				span.SetAttributes(tracing.Int64("tar.extracted_bytes", extractedSize))
+				span.EndWithError(err)
+			}()
 
 			readCloser, err := fileClosure.Reader()
 			if err == nil {
@@ -219,14 +306,27 @@ func tryExtractFiles(files []ReaderMaker,
 				extractingReader, err = DecryptAndDecompressTar(readCloser, filePath, crypter)
 				if err == nil {
 					defer extractingReader.Close()
-					err = extractFile(tarInterpreter, extractingReader, fileClosure)
+					var source io.Reader = NewWithSizeReader(extractingReader, &extractedSize)
+					var trackedReader *progressReader
+					if progress != nil {
+						trackedReader = progress.trackReader(source)
+						source = trackedReader
+					}
+					err = extractFile(tarInterpreter, source, fileClosure)
+					if validatingReader, ok := readCloser.(*ValidatingReader); ok && err == nil {
+						err = validatingReader.Validate()
+					}
+					if err != nil && trackedReader != nil {
+						trackedReader.discard()
+					}
 					err = errors.Wrapf(err, "Extraction error in %s", filePath)
-					tracelog.InfoLogger.Printf("Finished extraction of %s", filePath)
+					InfoLogWithFields(LogFields{"file": filePath, "bytes": extractedSize},
+						"Finished extraction of %s", filePath)
 				}
 			}
 
 			if err != nil {
-				isFailed.Store(fileClosure, true)
+				isFailed.Store(fileClosure, err)
 				tracelog.ErrorLogger.Println(err)
 			}
 		}()
@@ -235,11 +335,12 @@ func tryExtractFiles(files []ReaderMaker,
 	err := downloadingSemaphore.Acquire(downloadingContext, int64(downloadingConcurrency))
 	if err != nil {
 		tracelog.ErrorLogger.Println(err)
-		return files //Should never happen, but if we are asked to cancel - consider all files unfinished
+		return allFailed(err)
 	}
 
-	isFailed.Range(func(failedFile, _ interface{}) bool {
-		failed = append(failed, failedFile.(ReaderMaker))
+	failed = make(map[ReaderMaker]error)
+	isFailed.Range(func(failedFile, err interface{}) bool {
+		failed[failedFile.(ReaderMaker)] = err.(error)
 		return true
 	})
 	return failed
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	// terminalProgressInterval is the refresh interval of the progress line in the terminal
+	terminalProgressInterval = time.Second
+	// logProgressInterval is the interval between the progress lines when the output is not a terminal
+	logProgressInterval = 30 * time.Second
+)
+
+// ExtractProgress reports the progress of the extraction made by all the ExtractAll workers.
+// The extracted bytes are the bytes of the decrypted and decompressed tar streams,
+// so they are comparable with the uncompressed backup size recorded in the sentinel.
+type ExtractProgress struct {
+	name           string
+	totalBytes     int64
+	extractedBytes int64
+	output         io.Writer
+	refresh        bool
+	interval       time.Duration
+	startTime      time.Time
+	stop           chan struct{}
+	done           sync.WaitGroup
+}
+
+// NewExtractProgress creates the progress of the named backup extraction printed to stderr,
+// the progress line is refreshed in place when stderr is a terminal.
+// The totalBytes may be zero if the size is unknown, then only the speed is printed.
+func NewExtractProgress(name string, totalBytes int64) *ExtractProgress {
+	refresh := isTerminal(os.Stderr)
+	interval := logProgressInterval
+	if refresh {
+		interval = terminalProgressInterval
+	}
+	return newExtractProgress(name, totalBytes, os.Stderr, refresh, interval)
+}
+
+func newExtractProgress(name string, totalBytes int64, output io.Writer,
+	refresh bool, interval time.Duration) *ExtractProgress {
+	return &ExtractProgress{
+		name:       name,
+		totalBytes: totalBytes,
+		output:     output,
+		refresh:    refresh,
+		interval:   interval,
+	}
+}
+
+// Start starts printing the progress periodically, it does nothing for the nil progress
+func (progress *ExtractProgress) Start() {
+	if progress == nil {
+		return
+	}
+	progress.startTime = time.Now()
+	progress.stop = make(chan struct{})
+	progress.done.Add(1)
+	go func() {
+		defer progress.done.Done()
+		ticker := time.NewTicker(progress.interval)
+		defer ticker.Stop()
+		prevBytes, prevTime := int64(0), progress.startTime
+		for {
+			select {
+			case <-progress.stop:
+				return
+			case now := <-ticker.C:
+				extractedBytes := atomic.LoadInt64(&progress.extractedBytes)
+				speed := float64(extractedBytes-prevBytes) / now.Sub(prevTime).Seconds()
+				progress.print(progress.formatLine(extractedBytes, speed, now))
+				prevBytes, prevTime = extractedBytes, now
+			}
+		}
+	}()
+}
+
+// Stop stops printing the progress and prints the final summary
+func (progress *ExtractProgress) Stop() {
+	if progress == nil {
+		return
+	}
+	close(progress.stop)
+	progress.done.Wait()
+	extractedBytes := atomic.LoadInt64(&progress.extractedBytes)
+	elapsed := time.Since(progress.startTime)
+	line := fmt.Sprintf("Extracted %s of %s in %s, %s/s", progress.name, utility.FormatSize(float64(extractedBytes)),
+		elapsed.Round(time.Second), utility.FormatSize(float64(extractedBytes)/elapsed.Seconds()))
+	progress.print(line)
+	if progress.refresh {
+		_, _ = fmt.Fprintln(progress.output)
+	}
+}
+
+// trackReader counts the bytes read from the reader as extracted
+func (progress *ExtractProgress) trackReader(reader io.Reader) *progressReader {
+	return &progressReader{Reader: reader, progress: progress}
+}
+
+func (progress *ExtractProgress) formatLine(extractedBytes int64, speed float64, now time.Time) string {
+	if progress.totalBytes <= 0 {
+		return fmt.Sprintf("Extracting %s: %s, %s/s", progress.name, utility.FormatSize(float64(extractedBytes)),
+			utility.FormatSize(speed))
+	}
+	line := fmt.Sprintf("Extracting %s: %.1f%% (%s of %s), %s/s", progress.name,
+		100*float64(extractedBytes)/float64(progress.totalBytes), utility.FormatSize(float64(extractedBytes)),
+		utility.FormatSize(float64(progress.totalBytes)), utility.FormatSize(speed))
+	// the ETA is estimated by the average speed, since the current one fluctuates a lot
+	averageSpeed := float64(extractedBytes) / now.Sub(progress.startTime).Seconds()
+	if averageSpeed > 0 && extractedBytes < progress.totalBytes {
+		eta := time.Duration(float64(progress.totalBytes-extractedBytes) / averageSpeed * float64(time.Second))
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	return line
+}
+
+func (progress *ExtractProgress) print(line string) {
+	if progress.refresh {
+		// the trailing spaces clear the rest of the previous longer line
+		_, _ = fmt.Fprintf(progress.output, "\r%-100s", line)
+		return
+	}
+	_, _ = fmt.Fprintln(progress.output, line)
+}
+
+// progressReader counts the bytes read by one extraction attempt,
+// so they can be discarded if the attempt fails and the file is extracted again
+type progressReader struct {
+	io.Reader
+	progress  *ExtractProgress
+	readBytes int64
+}
+
+func (reader *progressReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	reader.readBytes += int64(n)
+	atomic.AddInt64(&reader.progress.extractedBytes, int64(n))
+	return n, err
+}
+
+func (reader *progressReader) discard() {
+	atomic.AddInt64(&reader.progress.extractedBytes, -reader.readBytes)
+	reader.readBytes = 0
+}
+
+func isTerminal(file *os.File) bool {
+	fileInfo, err := file.Stat()
+	return err == nil && fileInfo.Mode()&os.ModeCharDevice != 0
+}
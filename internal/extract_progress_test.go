@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractProgress_FormatLine(t *testing.T) {
+	progress := newExtractProgress("base_000000010000000000000002", 4*1024*1024, io.Discard, false, time.Second)
+	progress.startTime = time.Unix(0, 0)
+
+	line := progress.formatLine(1024*1024, 512*1024, time.Unix(2, 0))
+	assert.Equal(t, "Extracting base_000000010000000000000002: 25.0% (1.0 MiB of 4.0 MiB), 512.0 KiB/s, ETA 6s", line)
+
+	progress.totalBytes = 0
+	line = progress.formatLine(1024*1024, 512*1024, time.Unix(2, 0))
+	assert.Equal(t, "Extracting base_000000010000000000000002: 1.0 MiB, 512.0 KiB/s", line)
+}
+
+func TestExtractProgress_AggregatesConcurrentReaders(t *testing.T) {
+	progress := newExtractProgress("backup", 0, io.Discard, false, time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = io.Copy(io.Discard, progress.trackReader(strings.NewReader(strings.Repeat("x", 1000))))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(8000), progress.extractedBytes)
+}
+
+func TestExtractProgress_DiscardsFailedAttempt(t *testing.T) {
+	progress := newExtractProgress("backup", 0, io.Discard, false, time.Hour)
+	reader := progress.trackReader(strings.NewReader("content"))
+	_, _ = io.Copy(io.Discard, reader)
+	assert.Equal(t, int64(7), progress.extractedBytes)
+
+	reader.discard()
+	assert.Equal(t, int64(0), progress.extractedBytes)
+}
+
+func TestExtractProgress_StopPrintsSummary(t *testing.T) {
+	output := new(bytes.Buffer)
+	progress := newExtractProgress("backup", 7, output, true, time.Hour)
+	progress.Start()
+	_, _ = io.Copy(io.Discard, progress.trackReader(strings.NewReader("content")))
+	progress.Stop()
+
+	assert.True(t, strings.HasPrefix(output.String(), "\rExtracted backup of 7 B in 0s"))
+	assert.True(t, strings.HasSuffix(output.String(), "\n"))
+}
@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	fetchCacheTempPrefix = ".tmp-"
+	// fetchCacheStaleTempAge is the age of the temporary file which is surely left by the interrupted download
+	fetchCacheStaleTempAge = 24 * time.Hour
+)
+
+// FetchCache is the local disk cache of the downloaded tar members shared by the restores on the host.
+// The entries are keyed by the full storage path and the checksum of the tar member, so the tar member is never
+// taken from the cache in place of different content, e.g. of the same part of the other backup or storage.
+// The entry is written to a temporary file and renamed once the whole tar member is downloaded and matches
+// the checksum, so the concurrent restores, including the ones of other processes, never read a partial entry.
+// The least recently used entries are evicted once the cache exceeds its maximum size.
+type FetchCache struct {
+	directory string
+	maxSize   int64
+}
+
+// GetFetchCache returns the fetch cache configured by WALG_FETCH_CACHE_DIR and WALG_FETCH_CACHE_SIZE,
+// nil if the cache directory is not set
+func GetFetchCache() (*FetchCache, error) {
+	directory := viper.GetString(FetchCacheDirSetting)
+	if directory == "" {
+		return nil, nil
+	}
+	maxSize, err := utility.ParseSizeInBytes(viper.GetString(FetchCacheSizeSetting))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", FetchCacheSizeSetting)
+	}
+	if maxSize <= 0 {
+		return nil, errors.Errorf("%s must be positive", FetchCacheSizeSetting)
+	}
+	return NewFetchCache(directory, maxSize)
+}
+
+func NewFetchCache(directory string, maxSize int64) (*FetchCache, error) {
+	err := os.MkdirAll(directory, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the fetch cache directory")
+	}
+	return &FetchCache{directory: directory, maxSize: maxSize}, nil
+}
+
+// Reader returns the reader of the cached tar member. The tar member which is not cached is downloaded,
+// and the cache is filled while it is read. The storagePath includes the path of the storage folder.
+func (cache *FetchCache) Reader(storagePath string, checksum ObjectChecksum,
+	download func() (io.ReadCloser, error)) (io.ReadCloser, error) {
+	if checksum.Size > cache.maxSize {
+		return download()
+	}
+	entryPath := filepath.Join(cache.directory, getFetchCacheKey(storagePath, checksum))
+	file, err := os.Open(entryPath)
+	if err == nil {
+		tracelog.DebugLogger.Printf("Reading %s from the fetch cache", storagePath)
+		// the modification time orders the entries for the eviction
+		now := time.Now()
+		_ = os.Chtimes(entryPath, now, now)
+		return &cachedEntryReader{file: file, checksumReader: newChecksumReader(file),
+			entryPath: entryPath, expected: checksum}, nil
+	}
+	if !os.IsNotExist(err) {
+		tracelog.WarningLogger.Printf("Failed to read %s from the fetch cache: %v", storagePath, err)
+	}
+
+	reader, err := download()
+	if err != nil {
+		return nil, err
+	}
+	tempFile, err := os.CreateTemp(cache.directory, fetchCacheTempPrefix+"*")
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to cache %s: %v", storagePath, err)
+		return reader, nil
+	}
+	return &fillingReader{ReadCloser: reader, checksumReader: newChecksumReader(reader), tempFile: tempFile,
+		cache: cache, entryPath: entryPath, expected: checksum}, nil
+}
+
+// evict removes the least recently used entries until the cache fits into the maximum size,
+// the temporary files of the interrupted downloads are removed once they are stale.
+// The entries removed by the concurrent evictions are skipped.
+func (cache *FetchCache) evict() {
+	dirEntries, err := os.ReadDir(cache.directory)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to list the fetch cache: %v", err)
+		return
+	}
+	entries := make([]os.FileInfo, 0, len(dirEntries))
+	totalSize := int64(0)
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if strings.HasPrefix(info.Name(), fetchCacheTempPrefix) {
+			if time.Since(info.ModTime()) > fetchCacheStaleTempAge {
+				cache.remove(info.Name())
+			}
+			continue
+		}
+		entries = append(entries, info)
+		totalSize += info.Size()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for _, entry := range entries {
+		if totalSize <= cache.maxSize {
+			break
+		}
+		tracelog.DebugLogger.Printf("Evicting %s from the fetch cache", entry.Name())
+		cache.remove(entry.Name())
+		totalSize -= entry.Size()
+	}
+}
+
+func (cache *FetchCache) remove(name string) {
+	err := os.Remove(filepath.Join(cache.directory, name))
+	if err != nil && !os.IsNotExist(err) {
+		tracelog.WarningLogger.Printf("Failed to remove %s from the fetch cache: %v", name, err)
+	}
+}
+
+// getFetchCacheKey returns the name of the cache entry of the tar member,
+// the storage path is hashed since it doesn't fit into the file name
+func getFetchCacheKey(storagePath string, checksum ObjectChecksum) string {
+	return fmt.Sprintf("%x.%d.%08x", sha256.Sum256([]byte(storagePath)), checksum.Size, checksum.CRC32)
+}
+
+// cachedEntryReader removes the cache entry which doesn't match the checksum,
+// so the retry of the failed extraction downloads the tar member again
+type cachedEntryReader struct {
+	file           *os.File
+	checksumReader *checksumReader
+	entryPath      string
+	expected       ObjectChecksum
+}
+
+func (reader *cachedEntryReader) Read(p []byte) (int, error) {
+	n, err := reader.checksumReader.Read(p)
+	if err == io.EOF && reader.checksumReader.Checksum() != reader.expected {
+		tracelog.WarningLogger.Printf("Removing the corrupt fetch cache entry %s", reader.entryPath)
+		removeErr := os.Remove(reader.entryPath)
+		if removeErr != nil && !os.IsNotExist(removeErr) {
+			tracelog.WarningLogger.Printf("Failed to remove %s: %v", reader.entryPath, removeErr)
+		}
+		return n, newObjectCorruptedError(reader.entryPath, "the fetch cache entry doesn't match the checksum")
+	}
+	return n, err
+}
+
+func (reader *cachedEntryReader) Close() error {
+	return reader.file.Close()
+}
+
+// fillingReader writes the downloaded tar member to the temporary file, which becomes the cache entry
+// once the whole tar member is read and matches the checksum. The temporary file is removed otherwise.
+type fillingReader struct {
+	io.ReadCloser
+	checksumReader *checksumReader
+	// tempFile is nil once the entry is stored or discarded
+	tempFile  *os.File
+	cache     *FetchCache
+	entryPath string
+	expected  ObjectChecksum
+}
+
+func (reader *fillingReader) Read(p []byte) (int, error) {
+	n, err := reader.checksumReader.Read(p)
+	if n > 0 && reader.tempFile != nil {
+		_, writeErr := reader.tempFile.Write(p[:n])
+		if writeErr != nil {
+			tracelog.WarningLogger.Printf("Failed to write the fetch cache entry %s: %v", reader.entryPath, writeErr)
+			reader.discard()
+		}
+	}
+	if err == io.EOF && reader.tempFile != nil {
+		reader.store()
+	}
+	return n, err
+}
+
+func (reader *fillingReader) Close() error {
+	if reader.tempFile != nil {
+		reader.discard()
+	}
+	return reader.ReadCloser.Close()
+}
+
+// store renames the temporary file to the cache entry if the downloaded tar member is valid,
+// the invalid one is reported by the ValidatingReader
+func (reader *fillingReader) store() {
+	if reader.checksumReader.Checksum() != reader.expected {
+		reader.discard()
+		return
+	}
+	tempPath := reader.tempFile.Name()
+	err := reader.tempFile.Close()
+	reader.tempFile = nil
+	if err == nil {
+		err = os.Rename(tempPath, reader.entryPath)
+	}
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to store the fetch cache entry %s: %v", reader.entryPath, err)
+		_ = os.Remove(tempPath)
+		return
+	}
+	reader.cache.evict()
+}
+
+func (reader *fillingReader) discard() {
+	tempPath := reader.tempFile.Name()
+	utility.LoggedClose(reader.tempFile, "")
+	reader.tempFile = nil
+	err := os.Remove(tempPath)
+	if err != nil && !os.IsNotExist(err) {
+		tracelog.WarningLogger.Printf("Failed to remove %s: %v", tempPath, err)
+	}
+}
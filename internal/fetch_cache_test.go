@@ -0,0 +1,171 @@
+package internal_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/fs"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func readCachedMember(folder storage.Folder, name, content string) (string, error) {
+	readerMaker := internal.NewStorageReaderMaker(folder, name)
+	checksum := checksumOf(content)
+	readerMaker.Checksum = &checksum
+	reader, err := readerMaker.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	return string(data), err
+}
+
+func listFetchCache(t *testing.T, cacheDir string) []string {
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestFetchCache_ReadsCachedMember(t *testing.T) {
+	cacheDir := t.TempDir()
+	viper.Set(internal.FetchCacheDirSetting, cacheDir)
+	defer viper.Set(internal.FetchCacheDirSetting, "")
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	require.NoError(t, folder.PutObject("part_001.tar", strings.NewReader(checksummedContent)))
+
+	// concurrent restores fill the same entry
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			data, err := readCachedMember(folder, "part_001.tar", checksummedContent)
+			assert.NoError(t, err)
+			assert.Equal(t, checksummedContent, data)
+		}()
+	}
+	waitGroup.Wait()
+	assert.Len(t, listFetchCache(t, cacheDir), 1)
+
+	require.NoError(t, folder.DeleteObjects([]string{"part_001.tar"}))
+	data, err := readCachedMember(folder, "part_001.tar", checksummedContent)
+	assert.NoError(t, err)
+	assert.Equal(t, checksummedContent, data)
+}
+
+func TestFetchCache_CorruptEntryIsRemoved(t *testing.T) {
+	cacheDir := t.TempDir()
+	viper.Set(internal.FetchCacheDirSetting, cacheDir)
+	defer viper.Set(internal.FetchCacheDirSetting, "")
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	require.NoError(t, folder.PutObject("part_001.tar", strings.NewReader(checksummedContent)))
+
+	_, err := readCachedMember(folder, "part_001.tar", checksummedContent)
+	require.NoError(t, err)
+	entries := listFetchCache(t, cacheDir)
+	require.Len(t, entries, 1)
+	entryPath := filepath.Join(cacheDir, entries[0])
+	require.NoError(t, os.WriteFile(entryPath, []byte(strings.ToUpper(checksummedContent)), 0600))
+
+	_, err = readCachedMember(folder, "part_001.tar", checksummedContent)
+	assert.IsType(t, internal.ObjectCorruptedError{}, err)
+	assert.NoFileExists(t, entryPath)
+
+	// the retry downloads the tar member again
+	data, err := readCachedMember(folder, "part_001.tar", checksummedContent)
+	assert.NoError(t, err)
+	assert.Equal(t, checksummedContent, data)
+	assert.FileExists(t, entryPath)
+}
+
+func TestFetchCache_InvalidDownloadIsNotCached(t *testing.T) {
+	cacheDir := t.TempDir()
+	viper.Set(internal.FetchCacheDirSetting, cacheDir)
+	defer viper.Set(internal.FetchCacheDirSetting, "")
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	require.NoError(t, folder.PutObject("part_001.tar", strings.NewReader(checksummedContent[:5])))
+
+	_, err := readCachedMember(folder, "part_001.tar", checksummedContent)
+	assert.IsType(t, internal.ObjectTruncatedError{}, err)
+	assert.Empty(t, listFetchCache(t, cacheDir))
+}
+
+func TestFetchCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cacheDir := t.TempDir()
+	viper.Set(internal.FetchCacheDirSetting, cacheDir)
+	viper.Set(internal.FetchCacheSizeSetting, "40")
+	defer func() {
+		viper.Set(internal.FetchCacheDirSetting, "")
+		viper.Set(internal.FetchCacheSizeSetting, "10GB")
+	}()
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	contents := map[string]string{
+		"part_001.tar": strings.Repeat("1", 15),
+		"part_002.tar": strings.Repeat("2", 15),
+		"part_003.tar": strings.Repeat("3", 15),
+	}
+	for name, content := range contents {
+		require.NoError(t, folder.PutObject(name, strings.NewReader(content)))
+	}
+
+	for _, name := range []string{"part_001.tar", "part_002.tar", "part_001.tar", "part_003.tar"} {
+		_, err := readCachedMember(folder, name, contents[name])
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(t, listFetchCache(t, cacheDir), 2)
+
+	// only the evicted tar member is downloaded again
+	require.NoError(t, folder.DeleteObjects([]string{"part_001.tar", "part_002.tar", "part_003.tar"}))
+	for _, name := range []string{"part_001.tar", "part_003.tar"} {
+		data, err := readCachedMember(folder, name, contents[name])
+		assert.NoError(t, err)
+		assert.Equal(t, contents[name], data)
+	}
+	_, err := readCachedMember(folder, "part_002.tar", contents["part_002.tar"])
+	assert.Error(t, err)
+}
+
+func TestFetchCache_KeyedByFullPath(t *testing.T) {
+	cacheDir := t.TempDir()
+	viper.Set(internal.FetchCacheDirSetting, cacheDir)
+	defer viper.Set(internal.FetchCacheDirSetting, "")
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	// the tar members of the different backups have the same names
+	for _, backupName := range []string{"base_000000010000000000000002", "base_000000010000000000000004"} {
+		backupFolder := folder.GetSubFolder(backupName)
+		require.NoError(t, backupFolder.PutObject("part_001.tar", strings.NewReader(checksummedContent)))
+		_, err := readCachedMember(backupFolder, "part_001.tar", checksummedContent)
+		require.NoError(t, err)
+	}
+	assert.Len(t, listFetchCache(t, cacheDir), 2)
+}
+
+func TestFetchCache_KeyedByStorage(t *testing.T) {
+	cacheDir := t.TempDir()
+	viper.Set(internal.FetchCacheDirSetting, cacheDir)
+	defer viper.Set(internal.FetchCacheDirSetting, "")
+	// the folders of the different storages have the same paths
+	for i := 0; i < 2; i++ {
+		folder := fs.NewFolder(t.TempDir(), "")
+		require.NoError(t, folder.PutObject("part_001.tar", strings.NewReader(checksummedContent)))
+		_, err := readCachedMember(folder, "part_001.tar", checksummedContent)
+		require.NoError(t, err)
+	}
+	assert.Len(t, listFetchCache(t, cacheDir), 2)
+}
@@ -28,6 +28,10 @@ func (err ArchiveNonExistenceError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
+func (err ArchiveNonExistenceError) ExitCode() int {
+	return ExitCodeNotFound
+}
+
 // DownloadFile downloads, decompresses and decrypts
 func DownloadFile(folder storage.Folder, filename, ext string, writeCloser io.WriteCloser) error {
 	utility.LoggedClose(writeCloser, "")
@@ -38,7 +42,7 @@ func DownloadFile(folder storage.Folder, filename, ext string, writeCloser io.Wr
 	}
 	tracelog.DebugLogger.Printf("Found decompressor for %s", decompressor.FileExtension())
 
-	archiveReader, exists, err := TryDownloadFile(folder, filename)
+	archiveReader, metadata, exists, err := tryDownloadFileWithMetadata(folder, filename)
 	if err != nil {
 		return err
 	}
@@ -47,7 +51,7 @@ func DownloadFile(folder storage.Folder, filename, ext string, writeCloser io.Wr
 	}
 	defer utility.LoggedClose(archiveReader, "")
 
-	decompressedReader, err := DecompressDecryptBytes(archiveReader, decompressor)
+	decompressedReader, err := DecompressDecryptBytes(archiveReader, compression.WithMetadata(decompressor, metadata))
 	if err != nil {
 		return err
 	}
@@ -69,6 +73,19 @@ func TryDownloadFile(folder storage.Folder, path string) (fileReader io.ReadClos
 	return
 }
 
+// tryDownloadFileWithMetadata is TryDownloadFile which also returns the metadata the object is stored with,
+// so the object is decompressed with the metadata, e.g. with the zstd dictionary
+func tryDownloadFileWithMetadata(folder storage.Folder, path string) (io.ReadCloser, map[string]string, bool, error) {
+	fileReader, metadata, err := storage.ReadObjectWithMetadata(folder, path)
+	if err == nil {
+		return fileReader, metadata, true, nil
+	}
+	if _, ok := errors.Cause(err).(storage.ObjectNotFoundError); ok {
+		return nil, nil, false, nil
+	}
+	return nil, nil, false, err
+}
+
 func DecompressDecryptBytes(archiveReader io.Reader, decompressor compression.Decompressor) (io.ReadCloser, error) {
 	decryptReader, err := DecryptBytes(archiveReader)
 	if err != nil {
@@ -185,29 +202,89 @@ func DownloadAndDecompressStorageFile(folder storage.Folder, fileName string) (i
 	}, nil
 }
 
+// findDecompressorAndDownload looks up the file among the names with the extensions of the supported compression
+// methods, the last used one goes first. The decompressor is chosen by the extension of the object found,
+// so the files compressed with the different methods, e.g. after WALG_COMPRESSION_METHOD is changed,
+// are read from the same folder. The decompressor is given the metadata the object is stored with.
 func findDecompressorAndDownload(folder storage.Folder, fileName string) (io.ReadCloser, compression.Decompressor, error) {
-	for _, decompressor := range putCachedDecompressorInFirstPlace(compression.Decompressors) {
-		archiveReader, exists, err := TryDownloadFile(folder, fileName+"."+decompressor.FileExtension())
+	for _, objectName := range getCompressedObjectNames(fileName) {
+		archiveReader, metadata, exists, err := tryDownloadFileWithMetadata(folder, objectName)
 		if err != nil {
 			return nil, nil, err
 		}
 		if !exists {
 			continue
 		}
-		_ = SetLastDecompressor(decompressor)
-
-		return archiveReader, decompressor, nil
+		decompressor := findObjectDecompressor(fileName, objectName)
+		if decompressor != nil {
+			_ = SetLastDecompressor(decompressor)
+		}
+		return archiveReader, compression.WithMetadata(decompressor, metadata), nil
 	}
 
-	reader, exists, err := TryDownloadFile(folder, fileName)
+	err := checkUnsupportedCompression(folder, fileName)
 	if err != nil {
 		return nil, nil, err
 	}
-	if exists {
-		return reader, nil, nil
+	return nil, nil, newArchiveNonExistenceError(fileName)
+}
+
+// FindCompressedObject looks up the object of the file among the names with the extensions of the supported
+// compression methods by the existence checks, without reading it. Returns the empty name if the file is not stored.
+func FindCompressedObject(folder storage.Folder, fileName string) (string, compression.Decompressor, error) {
+	for _, objectName := range getCompressedObjectNames(fileName) {
+		exists, err := folder.Exists(objectName)
+		if err != nil {
+			return "", nil, err
+		}
+		if exists {
+			return objectName, findObjectDecompressor(fileName, objectName), nil
+		}
+	}
+	return "", nil, nil
+}
+
+// findObjectDecompressor returns the decompressor of the object of the file by its extension,
+// nil for the object stored without the compression
+func findObjectDecompressor(fileName, objectName string) compression.Decompressor {
+	if objectName == fileName {
+		return nil
 	}
+	return compression.FindDecompressor(utility.GetFileExtension(objectName))
+}
 
-	return nil, nil, newArchiveNonExistenceError(fileName)
+// UnsupportedCompressionError is returned for the file which is stored compressed
+// with the method this build of WAL-G doesn't support, e.g. brotli without the brotli build tag
+type UnsupportedCompressionError struct {
+	error
+}
+
+func newUnsupportedCompressionError(objectName, extension string) UnsupportedCompressionError {
+	return UnsupportedCompressionError{errors.Errorf("'%s' is compressed with '%s', which is not supported by this build",
+		objectName, extension)}
+}
+
+func (err UnsupportedCompressionError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// checkUnsupportedCompression checks that the file which is not found with the supported compression methods
+// isn't stored with the other one. Otherwise, the missing WAL file would end the recovery too early.
+func checkUnsupportedCompression(folder storage.Folder, fileName string) error {
+	for _, extension := range compression.KnownFileExtensions {
+		if compression.FindDecompressor(extension) != nil {
+			continue
+		}
+		objectName := fileName + "." + extension
+		exists, err := folder.Exists(objectName)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return newUnsupportedCompressionError(objectName, extension)
+		}
+	}
+	return nil
 }
 
 // TODO : unit tests
@@ -232,3 +309,21 @@ func DownloadFileTo(folder storage.Folder, fileName string, dstPath string) erro
 	// In case of error we may have some content within file. Leave it alone.
 	return err
 }
+
+// DownloadFileFromFailoverStorages downloads the file missing in the primary storage from the first failover
+// storage which has it, since wal-push puts the file only to the failover storages if the upload
+// to the primary one fails. Returns ArchiveNonExistenceError if no failover storage has the file.
+func DownloadFileFromFailoverStorages(failoverStorages []FailoverStorage, fileName string, dstPath string) error {
+	for _, failoverStorage := range failoverStorages {
+		err := DownloadFileTo(failoverStorage.Folder, fileName, dstPath)
+		if _, ok := errors.Cause(err).(ArchiveNonExistenceError); ok {
+			continue
+		}
+		if err == nil {
+			tracelog.WarningLogger.Printf("'%s' is missing in the primary storage, fetched it from the '%s' storage\n",
+				fileName, failoverStorage.Name)
+		}
+		return err
+	}
+	return newArchiveNonExistenceError(fileName)
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/compression/lzma"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// putMixedWalSegments puts the WAL segments compressed with the different methods, as after the changes
+// of WALG_COMPRESSION_METHOD, and returns the contents of the segments by their names
+func putMixedWalSegments(t *testing.T, folder storage.Folder) map[string][]byte {
+	// the last used method is looked up first, so the segments are fetched in the different orders of the lookup
+	compressors := []compression.Compressor{lz4.Compressor{}, zstd.Compressor{Level: zstd.DefaultLevel},
+		lzma.Compressor{}, nil}
+	segments := make(map[string][]byte)
+	for i, compressor := range compressors {
+		segmentName := fmt.Sprintf("0000000100000000000000%02X", i+1)
+		content := bytes.Repeat([]byte(segmentName), 1000)
+		segments[segmentName] = content
+		if compressor == nil {
+			// gzip is only decompressed, WAL-E compressed the segments with it
+			var compressed bytes.Buffer
+			writer := gzip.NewWriter(&compressed)
+			_, err := writer.Write(content)
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+			require.NoError(t, folder.PutObject(segmentName+".gz", &compressed))
+			continue
+		}
+		var compressed bytes.Buffer
+		writer := compressor.NewWriter(&compressed)
+		_, err := writer.Write(content)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		require.NoError(t, folder.PutObject(segmentName+"."+compressor.FileExtension(), &compressed))
+	}
+	return segments
+}
+
+func TestDownloadAndDecompressStorageFile_MixedCompression(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	segments := putMixedWalSegments(t, folder)
+
+	for segmentName, content := range segments {
+		reader, err := DownloadAndDecompressStorageFile(folder, segmentName)
+		require.NoError(t, err, segmentName)
+		downloaded, err := io.ReadAll(reader)
+		require.NoError(t, err, segmentName)
+		assert.NoError(t, reader.Close())
+		assert.Equal(t, content, downloaded, segmentName)
+	}
+
+	_, err := DownloadAndDecompressStorageFile(folder, "000000010000000000000010")
+	assert.IsType(t, ArchiveNonExistenceError{}, err)
+}
+
+func TestDownloadFileResumably_MixedCompression(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	segments := putMixedWalSegments(t, folder)
+	dir := t.TempDir()
+
+	for segmentName, content := range segments {
+		dstPath := filepath.Join(dir, segmentName)
+		ok, err := DownloadFileResumably(folder, segmentName, dstPath+".part", dstPath)
+		require.True(t, ok)
+		require.NoError(t, err, segmentName)
+		downloaded, err := os.ReadFile(dstPath)
+		require.NoError(t, err)
+		assert.Equal(t, content, downloaded, segmentName)
+	}
+}
+
+func TestDownloadAndDecompressStorageFile_UnsupportedCompression(t *testing.T) {
+	if compression.FindDecompressor("br") != nil {
+		t.Skip("brotli is built in")
+	}
+	folder := memory.NewFolder("", memory.NewStorage())
+	require.NoError(t, folder.PutObject("000000010000000000000001.br", bytes.NewReader([]byte("brotli"))))
+
+	_, err := DownloadAndDecompressStorageFile(folder, "000000010000000000000001")
+	assert.IsType(t, UnsupportedCompressionError{}, err)
+
+	dstPath := filepath.Join(t.TempDir(), "000000010000000000000001")
+	_, err = DownloadFileResumably(folder, "000000010000000000000001", dstPath+".part", dstPath)
+	assert.IsType(t, UnsupportedCompressionError{}, err)
+	assert.NoFileExists(t, dstPath+".part")
+}
+
+func TestDownloadFileFromFailoverStorages(t *testing.T) {
+	emptyFolder := memory.NewFolder("", memory.NewStorage())
+	failoverFolder := memory.NewFolder("", memory.NewStorage())
+	segments := putMixedWalSegments(t, failoverFolder)
+	failoverStorages := []FailoverStorage{{Name: "empty", Folder: emptyFolder}, {Name: "secondary", Folder: failoverFolder}}
+	dir := t.TempDir()
+
+	for segmentName, content := range segments {
+		dstPath := filepath.Join(dir, segmentName)
+		require.NoError(t, DownloadFileFromFailoverStorages(failoverStorages, segmentName, dstPath))
+		fetched, err := os.ReadFile(dstPath)
+		require.NoError(t, err)
+		assert.Equal(t, content, fetched)
+	}
+
+	err := DownloadFileFromFailoverStorages(failoverStorages, "000000010000000000000010", filepath.Join(dir, "missing"))
+	assert.IsType(t, ArchiveNonExistenceError{}, err)
+	assert.NoFileExists(t, filepath.Join(dir, "missing"))
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+// The log formats supported by WALG_LOG_FORMAT
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogFields are the contextual fields of the structured log entries
+type LogFields map[string]interface{}
+
+// jsonLog holds the state of the JSON log format, the output is nil in the text format
+var jsonLog = &jsonLogState{fields: LogFields{}}
+
+type jsonLogState struct {
+	mutex  sync.Mutex
+	output io.Writer
+	fields LogFields
+}
+
+// configurableLogger is the part of the log.Logger embedded by the tracelog loggers
+type configurableLogger interface {
+	Writer() io.Writer
+	SetOutput(w io.Writer)
+	SetPrefix(prefix string)
+	SetFlags(flag int)
+}
+
+// jsonLogWriter turns the lines of a tracelog logger into the JSON entries of its level
+type jsonLogWriter struct {
+	level string
+}
+
+func (writer jsonLogWriter) Write(p []byte) (int, error) {
+	jsonLog.write(writer.level, string(p), nil)
+	return len(p), nil
+}
+
+// ConfigureLogFormat switches the tracelog loggers to the given format. In the JSON format
+// each line is written to the output as a JSON object with the level, the timestamp, the message
+// and the fields added by AddLogFields. The text format leaves the loggers as they are.
+func ConfigureLogFormat(format string, output io.Writer) error {
+	switch format {
+	case LogFormatText:
+		return nil
+	case LogFormatJSON:
+	default:
+		return errors.Errorf("unknown log format '%s', supported formats are: %s, %s", format, LogFormatText, LogFormatJSON)
+	}
+
+	jsonLog.mutex.Lock()
+	jsonLog.output = output
+	jsonLog.mutex.Unlock()
+
+	for level, logger := range map[string]configurableLogger{
+		"DEBUG":   tracelog.DebugLogger,
+		"INFO":    tracelog.InfoLogger,
+		"WARNING": tracelog.WarningLogger,
+		"ERROR":   tracelog.ErrorLogger,
+	} {
+		// the debug logger discards everything below the DEVEL log level
+		if logger.Writer() == io.Discard {
+			continue
+		}
+		logger.SetOutput(jsonLogWriter{level: level})
+		logger.SetPrefix("")
+		logger.SetFlags(0)
+	}
+	return nil
+}
+
+// AddLogFields adds the fields attached to every following JSON log entry of the process,
+// e.g. the operation and the backup name
+func AddLogFields(fields LogFields) {
+	jsonLog.mutex.Lock()
+	defer jsonLog.mutex.Unlock()
+	for key, value := range fields {
+		jsonLog.fields[key] = value
+	}
+}
+
+// InfoLogWithFields logs the message with the fields of this entry only, e.g. the byte counts.
+// The text format prints the message alone, as tracelog.InfoLogger does.
+func InfoLogWithFields(fields LogFields, format string, args ...interface{}) {
+	jsonLog.mutex.Lock()
+	isJSON := jsonLog.output != nil
+	jsonLog.mutex.Unlock()
+	if !isJSON {
+		tracelog.InfoLogger.Printf(format, args...)
+		return
+	}
+	jsonLog.write("INFO", fmt.Sprintf(format, args...), fields)
+}
+
+func (state *jsonLogState) write(level, message string, fields LogFields) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	entry := make(LogFields, len(state.fields)+len(fields)+3)
+	for key, value := range state.fields {
+		entry[key] = value
+	}
+	for key, value := range fields {
+		entry[key] = value
+	}
+	entry["level"] = level
+	entry["timestamp"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["msg"] = strings.TrimSuffix(message, "\n")
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(LogFields{"level": level, "timestamp": entry["timestamp"], "msg": entry["msg"],
+			"log_error": err.Error()})
+	}
+	_, _ = state.output.Write(append(line, '\n'))
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/tracelog"
+)
+
+// useJSONLog switches the logging to the JSON format written to the buffer until the test ends
+func useJSONLog(t *testing.T) *bytes.Buffer {
+	for _, logger := range []interface {
+		configurableLogger
+		Prefix() string
+		Flags() int
+	}{tracelog.InfoLogger, tracelog.WarningLogger, tracelog.ErrorLogger} {
+		logger, writer, prefix, flags := logger, logger.Writer(), logger.Prefix(), logger.Flags()
+		t.Cleanup(func() {
+			logger.SetOutput(writer)
+			logger.SetPrefix(prefix)
+			logger.SetFlags(flags)
+		})
+	}
+	t.Cleanup(func() { jsonLog = &jsonLogState{fields: LogFields{}} })
+
+	var buffer bytes.Buffer
+	require.NoError(t, ConfigureLogFormat(LogFormatJSON, &buffer))
+	return &buffer
+}
+
+func readJSONLog(t *testing.T, buffer *bytes.Buffer) []map[string]interface{} {
+	entries := make([]map[string]interface{}, 0)
+	for _, line := range strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJSONLog(t *testing.T) {
+	buffer := useJSONLog(t)
+	AddLogFields(LogFields{"operation": "backup-push"})
+	tracelog.InfoLogger.Printf("Starting %s\n", "backup")
+	AddLogFields(LogFields{"backup_name": "base_000000010000000000000002"})
+	InfoLogWithFields(LogFields{"compressed_size": 1024}, "Wrote backup with name %s", "base_000000010000000000000002")
+	tracelog.ErrorLogger.Println("upload failed")
+
+	entries := readJSONLog(t, buffer)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "INFO", entries[0]["level"])
+	assert.Equal(t, "Starting backup", entries[0]["msg"])
+	assert.Equal(t, "backup-push", entries[0]["operation"])
+	assert.NotContains(t, entries[0], "backup_name")
+	assert.NotEmpty(t, entries[0]["timestamp"])
+
+	assert.Equal(t, "Wrote backup with name base_000000010000000000000002", entries[1]["msg"])
+	assert.Equal(t, "base_000000010000000000000002", entries[1]["backup_name"])
+	assert.Equal(t, float64(1024), entries[1]["compressed_size"])
+
+	assert.Equal(t, "ERROR", entries[2]["level"])
+	assert.Equal(t, "upload failed", entries[2]["msg"])
+	assert.NotContains(t, entries[2], "compressed_size")
+}
+
+func TestConfigureLogFormat_Text(t *testing.T) {
+	assert.NoError(t, ConfigureLogFormat(LogFormatText, nil))
+	assert.Nil(t, jsonLog.output)
+	assert.Error(t, ConfigureLogFormat("xml", nil))
+}
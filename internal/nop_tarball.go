@@ -25,7 +25,7 @@ func (tarBall *NOPTarBall) CloseTar() error                                { ret
 func (tarBall *NOPTarBall) Size() int64            { return atomic.LoadInt64(tarBall.partSize) }
 func (tarBall *NOPTarBall) AddSize(i int64)        { atomic.AddInt64(tarBall.partSize, i) }
 func (tarBall *NOPTarBall) TarWriter() *tar.Writer { return tarBall.tarWriter }
-func (tarBall *NOPTarBall) AwaitUploads()          {}
+func (tarBall *NOPTarBall) AwaitUploads() error    { return nil }
 
 // NOPTarBallMaker creates a new NOPTarBall. Used
 // for testing purposes.
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+// ObjectChecksum is the size and the CRC-32 of the object as it is stored,
+// i.e. of the compressed and encrypted bytes
+type ObjectChecksum struct {
+	Size  int64  `json:"Size"`
+	CRC32 uint32 `json:"CRC32"`
+}
+
+type ObjectTruncatedError struct {
+	error
+}
+
+func newObjectTruncatedError(path string, expectedSize, actualSize int64) ObjectTruncatedError {
+	return ObjectTruncatedError{errors.Errorf("object %s truncated: expected %d got %d",
+		path, expectedSize, actualSize)}
+}
+
+func (err ObjectTruncatedError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err ObjectTruncatedError) ExitCode() int {
+	return ExitCodeDataCorruption
+}
+
+type ObjectCorruptedError struct {
+	error
+}
+
+func newObjectCorruptedError(path string, format string, args ...interface{}) ObjectCorruptedError {
+	return ObjectCorruptedError{errors.Errorf("object %s corrupted: "+format, append([]interface{}{path}, args...)...)}
+}
+
+func (err ObjectCorruptedError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err ObjectCorruptedError) ExitCode() int {
+	return ExitCodeDataCorruption
+}
+
+// CheckObjectSize checks the size of the stored object before it is downloaded
+func CheckObjectSize(path string, size int64, checksum ObjectChecksum) error {
+	if size < checksum.Size {
+		return newObjectTruncatedError(path, checksum.Size, size)
+	}
+	if size > checksum.Size {
+		return newObjectCorruptedError(path, "expected %d bytes got %d", checksum.Size, size)
+	}
+	return nil
+}
+
+// checksumReader computes the checksum of the bytes read through it
+type checksumReader struct {
+	io.Reader
+	hash hash.Hash32
+	size int64
+}
+
+func newChecksumReader(reader io.Reader) *checksumReader {
+	return &checksumReader{Reader: reader, hash: crc32.NewIEEE()}
+}
+
+func (reader *checksumReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	reader.hash.Write(p[:n])
+	reader.size += int64(n)
+	return n, err
+}
+
+func (reader *checksumReader) Checksum() ObjectChecksum {
+	return ObjectChecksum{Size: reader.size, CRC32: reader.hash.Sum32()}
+}
+
+// ValidatingReader fails the read of the object which doesn't match its recorded checksum.
+// The mismatch is returned instead of the io.EOF, so the truncated object is never taken as complete.
+type ValidatingReader struct {
+	io.ReadCloser
+	checksumReader *checksumReader
+	path           string
+	expected       ObjectChecksum
+	err            error
+}
+
+func NewValidatingReader(readCloser io.ReadCloser, path string, expected ObjectChecksum) *ValidatingReader {
+	return &ValidatingReader{ReadCloser: readCloser, checksumReader: newChecksumReader(readCloser),
+		path: path, expected: expected}
+}
+
+func (reader *ValidatingReader) Read(p []byte) (int, error) {
+	if reader.err != nil {
+		return 0, reader.err
+	}
+	n, err := reader.checksumReader.Read(p)
+	if reader.checksumReader.size > reader.expected.Size {
+		reader.err = newObjectCorruptedError(reader.path, "expected %d bytes got more", reader.expected.Size)
+		return n, reader.err
+	}
+	if err == io.EOF {
+		reader.err = reader.compare()
+		if reader.err != nil {
+			return n, reader.err
+		}
+	}
+	return n, err
+}
+
+// Validate reads the rest of the object and compares it with the recorded checksum,
+// since the decompressor may stop reading before the end of the object
+func (reader *ValidatingReader) Validate() error {
+	_, err := io.Copy(io.Discard, reader)
+	if err != nil {
+		return err
+	}
+	return reader.err
+}
+
+func (reader *ValidatingReader) compare() error {
+	actual := reader.checksumReader.Checksum()
+	if actual.Size != reader.expected.Size {
+		return newObjectTruncatedError(reader.path, reader.expected.Size, actual.Size)
+	}
+	if actual.CRC32 != reader.expected.CRC32 {
+		return newObjectCorruptedError(reader.path, "expected CRC-32 %08x got %08x", reader.expected.CRC32, actual.CRC32)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package internal_test
+
+import (
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+const checksummedContent = "tar member content"
+
+func checksumOf(content string) internal.ObjectChecksum {
+	return internal.ObjectChecksum{Size: int64(len(content)), CRC32: crc32.ChecksumIEEE([]byte(content))}
+}
+
+func readValidated(content string, expected internal.ObjectChecksum) ([]byte, error) {
+	reader := internal.NewValidatingReader(io.NopCloser(strings.NewReader(content)), "part_001.tar.lz4", expected)
+	return io.ReadAll(reader)
+}
+
+func TestValidatingReader_Valid(t *testing.T) {
+	data, err := readValidated(checksummedContent, checksumOf(checksummedContent))
+	assert.NoError(t, err)
+	assert.Equal(t, checksummedContent, string(data))
+}
+
+func TestValidatingReader_Truncated(t *testing.T) {
+	_, err := readValidated(checksummedContent[:5], checksumOf(checksummedContent))
+	assert.IsType(t, internal.ObjectTruncatedError{}, err)
+	assert.Contains(t, err.Error(), "object part_001.tar.lz4 truncated: expected 18 got 5")
+}
+
+func TestValidatingReader_Corrupted(t *testing.T) {
+	_, err := readValidated(strings.ToUpper(checksummedContent), checksumOf(checksummedContent))
+	assert.IsType(t, internal.ObjectCorruptedError{}, err)
+
+	_, err = readValidated(checksummedContent+"garbage", checksumOf(checksummedContent))
+	assert.IsType(t, internal.ObjectCorruptedError{}, err)
+}
+
+func TestValidatingReader_ValidateReadsTheRest(t *testing.T) {
+	reader := internal.NewValidatingReader(io.NopCloser(strings.NewReader(checksummedContent[:5])),
+		"part_001.tar.lz4", checksumOf(checksummedContent))
+	_, err := reader.Read(make([]byte, 2))
+	assert.NoError(t, err)
+	assert.IsType(t, internal.ObjectTruncatedError{}, reader.Validate())
+}
+
+func TestCheckObjectSize(t *testing.T) {
+	checksum := checksumOf(checksummedContent)
+	assert.NoError(t, internal.CheckObjectSize("part_001.tar.lz4", checksum.Size, checksum))
+	assert.IsType(t, internal.ObjectTruncatedError{}, internal.CheckObjectSize("part_001.tar.lz4", 3, checksum))
+	assert.IsType(t, internal.ObjectCorruptedError{}, internal.CheckObjectSize("part_001.tar.lz4", 30, checksum))
+}
+
+func TestStorageReaderMaker_ValidatesChecksum(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	assert.NoError(t, folder.PutObject("part_001.tar", strings.NewReader(checksummedContent[:5])))
+
+	readerMaker := internal.NewStorageReaderMaker(folder, "part_001.tar")
+	checksum := checksumOf(checksummedContent)
+	readerMaker.Checksum = &checksum
+	reader, err := readerMaker.Reader()
+	assert.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.IsType(t, internal.ObjectTruncatedError{}, err)
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+type UnsupportedObjectMetadataError struct {
+	error
+}
+
+func newUnsupportedObjectMetadataError(storageName string) UnsupportedObjectMetadataError {
+	return UnsupportedObjectMetadataError{errors.Errorf(
+		"the '%s' storage doesn't keep the object metadata", storageName)}
+}
+
+func (err UnsupportedObjectMetadataError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// CheckObjectMetadata checks that both the UploadingFolder and the FailoverStorages keep the object metadata
+func (uploader *Uploader) CheckObjectMetadata() error {
+	if _, ok := uploader.UploadingFolder.(storage.MetadataFolder); !ok {
+		return newUnsupportedObjectMetadataError(PrimaryStorageName)
+	}
+	for _, failoverStorage := range uploader.FailoverStorages {
+		if _, ok := failoverStorage.Folder.(storage.MetadataFolder); !ok {
+			return newUnsupportedObjectMetadataError(failoverStorage.Name)
+		}
+	}
+	return nil
+}
+
+// SetObjectMetadata makes the uploader put the objects with the metadata, which the storages must keep
+func (uploader *Uploader) SetObjectMetadata(metadata map[string]string) error {
+	if len(metadata) > 0 {
+		err := uploader.CheckObjectMetadata()
+		if err != nil {
+			return err
+		}
+	}
+	uploader.metadata = metadata
+	return nil
+}
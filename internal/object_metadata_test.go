@@ -0,0 +1,50 @@
+package internal_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/fs"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func readObjectMetadata(t *testing.T, folder storage.Folder, name string) map[string]string {
+	reader, metadata, err := storage.ReadObjectWithMetadata(folder, name)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	return metadata
+}
+
+func TestUploader_SetObjectMetadata(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	uploader := internal.NewUploader(lz4.Compressor{}, folder)
+
+	require.NoError(t, uploader.Upload("plain", strings.NewReader("content")))
+	require.NoError(t, uploader.SetObjectMetadata(map[string]string{"Walg-Key": "value"}))
+	require.NoError(t, uploader.Upload("described", strings.NewReader("content")))
+	require.NoError(t, uploader.Clone().Upload("cloned", strings.NewReader("content")))
+
+	assert.Empty(t, readObjectMetadata(t, folder, "plain"))
+	assert.Equal(t, map[string]string{"walg-key": "value"}, readObjectMetadata(t, folder, "described"))
+	assert.Equal(t, map[string]string{"walg-key": "value"}, readObjectMetadata(t, folder, "cloned"))
+}
+
+func TestUploader_SetObjectMetadata_Unsupported(t *testing.T) {
+	uploader := internal.NewUploader(lz4.Compressor{}, fs.NewFolder(t.TempDir(), ""))
+	assert.NoError(t, uploader.SetObjectMetadata(nil))
+	err := uploader.SetObjectMetadata(map[string]string{"walg-key": "value"})
+	assert.IsType(t, internal.UnsupportedObjectMetadataError{}, err)
+
+	uploader = internal.NewUploader(lz4.Compressor{}, memory.NewFolder("in_memory/", memory.NewStorage()))
+	uploader.FailoverStorages = []internal.FailoverStorage{{Name: "failover", Folder: fs.NewFolder(t.TempDir(), "")}}
+	err = uploader.SetObjectMetadata(map[string]string{"walg-key": "value"})
+	assert.IsType(t, internal.UnsupportedObjectMetadataError{}, err)
+}
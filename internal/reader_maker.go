@@ -18,11 +18,3 @@ type ReaderMaker interface {
 	FileType() FileType
 	Mode() int64
 }
-
-func readerMakersToFilePaths(readerMakers []ReaderMaker) []string {
-	paths := make([]string, 0)
-	for _, readerMaker := range readerMakers {
-		paths = append(paths, readerMaker.StoragePath())
-	}
-	return paths
-}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// ResumableDownloadMaxRetries is the number of times the broken download is resumed in one run
+const ResumableDownloadMaxRetries = 5
+
+// PartMetadataSuffix is appended to the part file name to name the file which records the downloaded object
+const PartMetadataSuffix = ".json"
+
+type PartSizeMismatchError struct {
+	error
+}
+
+func newPartSizeMismatchError(objectName string, partSize, objectSize int64) PartSizeMismatchError {
+	return PartSizeMismatchError{errors.Errorf(
+		"the downloaded part of '%s' has %d bytes while the object has %d bytes", objectName, partSize, objectSize)}
+}
+
+func (err PartSizeMismatchError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// partMetadata identifies the object the part file is downloaded from, the size recorded on the first read
+// of the object validates the resumed reads. The object metadata is recorded along, since the part file
+// is decompressed with it, e.g. with the zstd dictionary.
+type partMetadata struct {
+	ObjectName     string
+	Size           int64
+	ObjectMetadata map[string]string `json:",omitempty"`
+}
+
+// DownloadFileResumably downloads the file like DownloadFileTo, but if the storage supports the range reads,
+// the raw object is downloaded to the part file first. The download broken in the middle is resumed from
+// the end of the part file, in this run or in the next one, and the object is decompressed to dstPath once
+// the part file has the whole object. Returns false if the part file is being downloaded by another process.
+func DownloadFileResumably(folder storage.Folder, fileName, partPath, dstPath string) (bool, error) {
+	rangeFolder, ok := folder.(storage.RangeFolder)
+	if !ok {
+		return true, DownloadFileTo(folder, fileName, dstPath)
+	}
+	part, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return true, err
+	}
+	defer utility.LoggedClose(part, "")
+	locked, err := utility.TryLockFile(part.Fd())
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to lock %s, downloading %s without resuming: %v", partPath, fileName, err)
+		return true, DownloadFileTo(folder, fileName, dstPath)
+	}
+	if !locked {
+		return false, nil
+	}
+
+	metadata, err := downloadPart(rangeFolder, fileName, part)
+	if err != nil {
+		switch err.(type) {
+		case PartSizeMismatchError, ArchiveNonExistenceError, UnsupportedCompressionError:
+			removePart(partPath)
+		}
+		return true, err
+	}
+	err = decompressPart(part, fileName, metadata, dstPath)
+	if err != nil {
+		// the part is complete, but doesn't decompress, so it is downloaded again the next time
+		removePart(partPath)
+		return true, err
+	}
+	removePart(partPath)
+	return true, nil
+}
+
+// downloadPart appends the rest of the object to the part file, resuming the broken reads
+func downloadPart(folder storage.RangeFolder, fileName string, part *os.File) (partMetadata, error) {
+	metadata, reader, err := openPartObject(folder, fileName, part)
+	if err != nil {
+		return partMetadata{}, err
+	}
+	for retry := 0; ; retry++ {
+		if reader != nil {
+			_, err = io.Copy(part, reader)
+			utility.LoggedClose(reader, "")
+		}
+		partSize, statErr := part.Seek(0, io.SeekEnd)
+		if statErr != nil {
+			return partMetadata{}, statErr
+		}
+		if err == nil {
+			if partSize != metadata.Size {
+				return partMetadata{}, newPartSizeMismatchError(metadata.ObjectName, partSize, metadata.Size)
+			}
+			return metadata, nil
+		}
+		if retry >= ResumableDownloadMaxRetries {
+			return partMetadata{}, errors.Wrapf(err, "failed to download '%s'", metadata.ObjectName)
+		}
+		tracelog.WarningLogger.Printf("Download of %s is broken at %d of %d bytes, resuming: %v",
+			metadata.ObjectName, partSize, metadata.Size, err)
+		reader, err = openObjectFrom(folder, metadata, partSize)
+		if err != nil {
+			return partMetadata{}, err
+		}
+	}
+}
+
+// openPartObject opens the object of the part file from the end of the part file,
+// or finds the object and starts the part file anew if there is nothing to resume.
+// The returned reader is nil if the part file already has the whole object.
+func openPartObject(folder storage.RangeFolder, fileName string,
+	part *os.File) (partMetadata, io.ReadCloser, error) {
+	partSize, err := part.Seek(0, io.SeekEnd)
+	if err != nil {
+		return partMetadata{}, nil, err
+	}
+	metadata, err := readPartMetadata(part.Name())
+	if err == nil && partSize <= metadata.Size {
+		if partSize == metadata.Size {
+			return metadata, nil, nil
+		}
+		reader, err := openObjectFrom(folder, metadata, partSize)
+		if err == nil {
+			tracelog.InfoLogger.Printf("Resuming the download of %s from %d of %d bytes",
+				metadata.ObjectName, partSize, metadata.Size)
+			return metadata, reader, nil
+		}
+		tracelog.WarningLogger.Printf("Failed to resume the download of %s, restarting it: %v", metadata.ObjectName, err)
+	}
+
+	err = part.Truncate(0)
+	if err != nil {
+		return partMetadata{}, nil, err
+	}
+	_, err = part.Seek(0, io.SeekStart)
+	if err != nil {
+		return partMetadata{}, nil, err
+	}
+	for _, objectName := range getCompressedObjectNames(fileName) {
+		reader, size, err := folder.ReadObjectFrom(objectName, 0)
+		if _, ok := errors.Cause(err).(storage.ObjectNotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return partMetadata{}, nil, err
+		}
+		objectMetadata, err := storage.ReadObjectMetadata(folder, objectName)
+		if err != nil {
+			utility.LoggedClose(reader, "")
+			return partMetadata{}, nil, err
+		}
+		metadata = partMetadata{ObjectName: objectName, Size: size, ObjectMetadata: objectMetadata}
+		err = writePartMetadata(part.Name(), metadata)
+		if err != nil {
+			utility.LoggedClose(reader, "")
+			return partMetadata{}, nil, err
+		}
+		return metadata, reader, nil
+	}
+	err = checkUnsupportedCompression(folder, fileName)
+	if err != nil {
+		return partMetadata{}, nil, err
+	}
+	return partMetadata{}, nil, newArchiveNonExistenceError(fileName)
+}
+
+// openObjectFrom opens the object from the offset and checks that it is still the object of the part file
+func openObjectFrom(folder storage.RangeFolder, metadata partMetadata, offset int64) (io.ReadCloser, error) {
+	reader, size, err := folder.ReadObjectFrom(metadata.ObjectName, offset)
+	if err != nil {
+		return nil, err
+	}
+	if size != metadata.Size {
+		utility.LoggedClose(reader, "")
+		return nil, newPartSizeMismatchError(metadata.ObjectName, offset, size)
+	}
+	return reader, nil
+}
+
+// getCompressedObjectNames returns the possible object names of the file, the last used compression goes first
+func getCompressedObjectNames(fileName string) []string {
+	decompressors := putCachedDecompressorInFirstPlace(compression.Decompressors)
+	objectNames := make([]string, 0, len(decompressors)+1)
+	for _, decompressor := range decompressors {
+		objectNames = append(objectNames, fileName+"."+decompressor.FileExtension())
+	}
+	return append(objectNames, fileName)
+}
+
+func decompressPart(part *os.File, fileName string, metadata partMetadata, dstPath string) error {
+	_, err := part.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	decompressor := findObjectDecompressor(fileName, metadata.ObjectName)
+	if decompressor != nil {
+		_ = SetLastDecompressor(decompressor)
+	}
+	reader, err := DecompressDecryptBytes(part, compression.WithMetadata(decompressor, metadata.ObjectMetadata))
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(reader, "")
+
+	file, err := os.OpenFile(dstPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(file, "")
+	_, err = utility.FastCopy(file, reader)
+	if err != nil {
+		_ = os.Remove(dstPath)
+		return errors.Wrapf(err, "failed to decompress '%s'", metadata.ObjectName)
+	}
+	return nil
+}
+
+func readPartMetadata(partPath string) (partMetadata, error) {
+	var metadata partMetadata
+	content, err := os.ReadFile(partPath + PartMetadataSuffix)
+	if err != nil {
+		return metadata, err
+	}
+	err = json.Unmarshal(content, &metadata)
+	return metadata, err
+}
+
+func writePartMetadata(partPath string, metadata partMetadata) error {
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(partPath+PartMetadataSuffix, content, 0644)
+}
+
+func removePart(partPath string) {
+	_ = os.Remove(partPath + PartMetadataSuffix)
+	_ = os.Remove(partPath)
+}
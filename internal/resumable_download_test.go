@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const resumableTestFileName = "000000010000000000000001"
+
+// brokenRangeFolder breaks the first reads of the objects after the given number of bytes
+type brokenRangeFolder struct {
+	*memory.Folder
+	breakAfter int64
+	breaks     int
+	offsets    []int64
+}
+
+func (folder *brokenRangeFolder) ReadObjectFrom(objectRelativePath string, offset int64) (io.ReadCloser, int64, error) {
+	folder.offsets = append(folder.offsets, offset)
+	reader, size, err := folder.Folder.ReadObjectFrom(objectRelativePath, offset)
+	if err != nil || folder.breaks == 0 {
+		return reader, size, err
+	}
+	folder.breaks--
+	return io.NopCloser(io.MultiReader(io.LimitReader(reader, folder.breakAfter),
+		&failingReader{errors.New("connection reset")})), size, nil
+}
+
+type failingReader struct {
+	err error
+}
+
+func (reader *failingReader) Read([]byte) (int, error) {
+	return 0, reader.err
+}
+
+func putCompressedTestObject(t *testing.T, folder storage.Folder, content []byte) []byte {
+	var compressed bytes.Buffer
+	writer := lz4.Compressor{}.NewWriter(&compressed)
+	_, err := writer.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, folder.PutObject(resumableTestFileName+"."+lz4.FileExtension, bytes.NewReader(compressed.Bytes())))
+	return compressed.Bytes()
+}
+
+func testContent() []byte {
+	return bytes.Repeat([]byte("resumable download "), 10000)
+}
+
+func TestDownloadFileResumably_ResumesPartFile(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	content := testContent()
+	compressed := putCompressedTestObject(t, folder, content)
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, resumableTestFileName+".part")
+	dstPath := filepath.Join(dir, resumableTestFileName)
+
+	// the previous download is broken in the middle
+	half := int64(len(compressed) / 2)
+	require.NoError(t, os.WriteFile(partPath, compressed[:half], 0644))
+	require.NoError(t, writePartMetadata(partPath, partMetadata{
+		ObjectName: resumableTestFileName + "." + lz4.FileExtension,
+		Size:       int64(len(compressed)),
+	}))
+
+	rangeFolder := &brokenRangeFolder{Folder: folder}
+	ok, err := DownloadFileResumably(rangeFolder, resumableTestFileName, partPath, dstPath)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []int64{half}, rangeFolder.offsets)
+
+	restored, err := os.ReadFile(dstPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, restored)
+	assert.NoFileExists(t, partPath)
+	assert.NoFileExists(t, partPath+PartMetadataSuffix)
+}
+
+func TestDownloadFileResumably_ResumesBrokenRead(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	content := testContent()
+	putCompressedTestObject(t, folder, content)
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, resumableTestFileName+".part")
+	dstPath := filepath.Join(dir, resumableTestFileName)
+
+	rangeFolder := &brokenRangeFolder{Folder: folder, breakAfter: 100, breaks: 2}
+	ok, err := DownloadFileResumably(rangeFolder, resumableTestFileName, partPath, dstPath)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []int64{0, 100, 200}, rangeFolder.offsets)
+
+	restored, err := os.ReadFile(dstPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, restored)
+}
+
+func TestDownloadFileResumably_RestartsIfObjectSizeChanged(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	content := testContent()
+	compressed := putCompressedTestObject(t, folder, content)
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, resumableTestFileName+".part")
+	dstPath := filepath.Join(dir, resumableTestFileName)
+
+	// the part file is left by the download of another object with the same name
+	require.NoError(t, os.WriteFile(partPath, []byte("garbage"), 0644))
+	require.NoError(t, writePartMetadata(partPath, partMetadata{
+		ObjectName: resumableTestFileName + "." + lz4.FileExtension,
+		Size:       int64(len(compressed)) + 1,
+	}))
+
+	ok, err := DownloadFileResumably(folder, resumableTestFileName, partPath, dstPath)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	restored, err := os.ReadFile(dstPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, restored)
+}
+
+func TestDownloadFileResumably_MissingFile(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, resumableTestFileName+".part")
+
+	_, err := DownloadFileResumably(folder, resumableTestFileName, partPath, filepath.Join(dir, resumableTestFileName))
+	assert.IsType(t, ArchiveNonExistenceError{}, err)
+	assert.NoFileExists(t, partPath)
+}
+
+func TestDownloadFileResumably_ZstdDictionary(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	content := testContent()
+	dictionary := content[:4096]
+	var compressed bytes.Buffer
+	writer := zstd.DictionaryCompressor{Dictionary: dictionary}.NewWriter(&compressed)
+	_, err := writer.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, folder.PutObjectWithMetadata(resumableTestFileName+"."+zstd.FileExtension,
+		&compressed, "", zstd.DictionaryMetadata(dictionary)))
+	zstd.SetDictionaryLoader(func(id uint32) ([]byte, error) {
+		return dictionary, nil
+	})
+	defer zstd.SetDictionaryLoader(nil)
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, resumableTestFileName+".part")
+	dstPath := filepath.Join(dir, resumableTestFileName)
+
+	// the download is broken in this run and resumed in the next one
+	rangeFolder := &brokenRangeFolder{Folder: folder, breakAfter: 10, breaks: ResumableDownloadMaxRetries + 1}
+	_, err = DownloadFileResumably(rangeFolder, resumableTestFileName, partPath, dstPath)
+	require.Error(t, err)
+	metadata, err := readPartMetadata(partPath)
+	require.NoError(t, err)
+	assert.Equal(t, zstd.DictionaryMetadata(dictionary), metadata.ObjectMetadata)
+
+	ok, err := DownloadFileResumably(rangeFolder, resumableTestFileName, partPath, dstPath)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	restored, err := os.ReadFile(dstPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, restored)
+}
@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gofrs/flock"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	scratchDirPrefix      = "wal-g-scratch."
+	scratchDirInitPrefix  = ".wal-g-scratch-init."
+	scratchDirCheckPrefix = ".wal-g-write-check."
+	scratchLockFileName   = ".lock"
+)
+
+type InvalidScratchDirError struct {
+	error
+}
+
+func newInvalidScratchDirError(scratchDir string, cause error) InvalidScratchDirError {
+	return InvalidScratchDirError{errors.Wrapf(cause, "%s '%s' is not a writable directory", ScratchDirSetting, scratchDir)}
+}
+
+func (err InvalidScratchDirError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err InvalidScratchDirError) ExitCode() int {
+	return ExitCodeUsage
+}
+
+// processScratchDir is the scratch directory of the running process, it is locked until the process exits
+var processScratchDir struct {
+	sync.Mutex
+	path string
+	lock *flock.Flock
+}
+
+// GetScratchDir returns the directory of the temporary files, the system temp directory by default
+func GetScratchDir() string {
+	if scratchDir := viper.GetString(ScratchDirSetting); scratchDir != "" {
+		return scratchDir
+	}
+	return os.TempDir()
+}
+
+// ConfigureScratchDir checks that WALG_SCRATCH_DIR is a writable directory and removes the scratch directories
+// left there by the crashed processes. Nothing is checked if the setting is not set.
+func ConfigureScratchDir() error {
+	scratchDir := viper.GetString(ScratchDirSetting)
+	if scratchDir == "" {
+		return nil
+	}
+	info, err := os.Stat(scratchDir)
+	if err != nil {
+		return newInvalidScratchDirError(scratchDir, err)
+	}
+	if !info.IsDir() {
+		return newInvalidScratchDirError(scratchDir, errors.New("not a directory"))
+	}
+	checkFile, err := os.CreateTemp(scratchDir, scratchDirCheckPrefix)
+	if err != nil {
+		return newInvalidScratchDirError(scratchDir, err)
+	}
+	utility.LoggedClose(checkFile, "")
+	err = os.Remove(checkFile.Name())
+	if err != nil {
+		return newInvalidScratchDirError(scratchDir, err)
+	}
+	removeStaleScratchDirs(scratchDir)
+	return nil
+}
+
+// CreateScratchFile creates the temporary file in the scratch directory of the process,
+// the caller removes the file once it is not needed
+func CreateScratchFile(pattern string) (*os.File, error) {
+	scratchDir, err := getProcessScratchDir()
+	if err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(scratchDir, pattern)
+}
+
+// RemoveScratchDir removes the scratch directory of the process with all the files left there.
+// The directory of the process which exited without removing it is removed by the next process.
+func RemoveScratchDir() {
+	processScratchDir.Lock()
+	defer processScratchDir.Unlock()
+	if processScratchDir.path == "" {
+		return
+	}
+	tracelog.WarningLogger.PrintOnError(processScratchDir.lock.Unlock())
+	tracelog.WarningLogger.PrintOnError(os.RemoveAll(processScratchDir.path))
+	processScratchDir.path = ""
+	processScratchDir.lock = nil
+}
+
+// getProcessScratchDir creates the scratch directory of the process on the first call. The directory is locked
+// until the process exits, so the directory whose lock can be taken is left by the exited process.
+func getProcessScratchDir() (string, error) {
+	processScratchDir.Lock()
+	defer processScratchDir.Unlock()
+	if processScratchDir.path != "" {
+		return processScratchDir.path, nil
+	}
+
+	root := GetScratchDir()
+	removeStaleScratchDirs(root)
+	// the directory is renamed to the scratch directory name once it is locked,
+	// so the concurrent processes never take it for the stale one
+	initPath, err := os.MkdirTemp(root, scratchDirInitPrefix)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create the scratch directory in '%s'", root)
+	}
+	lock := flock.New(filepath.Join(initPath, scratchLockFileName))
+	locked, err := lock.TryLock()
+	if err != nil || !locked {
+		tracelog.WarningLogger.PrintOnError(os.RemoveAll(initPath))
+		return "", errors.Errorf("failed to lock the scratch directory '%s': %v", initPath, err)
+	}
+	path := filepath.Join(root, scratchDirPrefix+filepath.Base(initPath)[len(scratchDirInitPrefix):])
+	err = os.Rename(initPath, path)
+	if err != nil {
+		tracelog.WarningLogger.PrintOnError(lock.Unlock())
+		tracelog.WarningLogger.PrintOnError(os.RemoveAll(initPath))
+		return "", errors.Wrapf(err, "failed to create the scratch directory in '%s'", root)
+	}
+	tracelog.DebugLogger.Printf("Using the scratch directory '%s'", path)
+	processScratchDir.path = path
+	processScratchDir.lock = lock
+	return path, nil
+}
+
+// removeStaleScratchDirs removes the scratch directories which are not locked by the running processes.
+// The directory of this process is kept as well, since its lock is held through another open file.
+func removeStaleScratchDirs(root string) {
+	stalePaths, err := filepath.Glob(filepath.Join(root, scratchDirPrefix+"*"))
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to find the stale scratch directories: %v", err)
+		return
+	}
+	for _, stalePath := range stalePaths {
+		lock := flock.New(filepath.Join(stalePath, scratchLockFileName))
+		locked, err := lock.TryLock()
+		if err != nil || !locked {
+			continue
+		}
+		tracelog.InfoLogger.Printf("Removing the stale scratch directory '%s'", stalePath)
+		tracelog.WarningLogger.PrintOnError(lock.Unlock())
+		tracelog.WarningLogger.PrintOnError(os.RemoveAll(stalePath))
+	}
+}
@@ -0,0 +1,54 @@
+package internal_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+)
+
+func TestConfigureScratchDir_Invalid(t *testing.T) {
+	defer viper.Set(internal.ScratchDirSetting, "")
+	viper.Set(internal.ScratchDirSetting, "")
+	assert.NoError(t, internal.ConfigureScratchDir())
+
+	viper.Set(internal.ScratchDirSetting, filepath.Join(t.TempDir(), "missing"))
+	assert.IsType(t, internal.InvalidScratchDirError{}, internal.ConfigureScratchDir())
+
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0600))
+	viper.Set(internal.ScratchDirSetting, file)
+	assert.IsType(t, internal.InvalidScratchDirError{}, internal.ConfigureScratchDir())
+}
+
+func TestCreateScratchFile(t *testing.T) {
+	defer viper.Set(internal.ScratchDirSetting, "")
+	scratchDir := t.TempDir()
+	viper.Set(internal.ScratchDirSetting, scratchDir)
+	staleDir := filepath.Join(scratchDir, "wal-g-scratch.12345")
+	require.NoError(t, os.MkdirAll(staleDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(staleDir, "binlog"), nil, 0600))
+
+	require.NoError(t, internal.ConfigureScratchDir())
+	_, err := os.Stat(staleDir)
+	assert.True(t, os.IsNotExist(err))
+
+	file, err := internal.CreateScratchFile("binlog")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	processDir := filepath.Dir(file.Name())
+	assert.Equal(t, scratchDir, filepath.Dir(processDir))
+
+	// the directory of the running process is not stale
+	require.NoError(t, internal.ConfigureScratchDir())
+	_, err = os.Stat(file.Name())
+	assert.NoError(t, err)
+
+	internal.RemoveScratchDir()
+	_, err = os.Stat(processDir)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/utility"
+)
+
+var sentinelNamespaceRegexp = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// anyNamespaceSentinelRegexp matches the sentinel names of the default and of all the other namespaces
+var anyNamespaceSentinelRegexp = regexp.MustCompile(
+	regexp.QuoteMeta(strings.TrimSuffix(utility.SentinelSuffix, ".json")) + `(\.[A-Za-z0-9-]+)?\.json$`)
+
+type InvalidSentinelNamespaceError struct {
+	error
+}
+
+func newInvalidSentinelNamespaceError(namespace string) InvalidSentinelNamespaceError {
+	return InvalidSentinelNamespaceError{errors.Errorf(
+		"%s '%s' must consist of the latin letters, digits and dashes only", SentinelNamespaceSetting, namespace)}
+}
+
+func (err InvalidSentinelNamespaceError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err InvalidSentinelNamespaceError) ExitCode() int {
+	return ExitCodeUsage
+}
+
+// ConfigureSentinelNamespace checks that WALG_SENTINEL_NAMESPACE can be a part of the object names
+func ConfigureSentinelNamespace() error {
+	namespace := viper.GetString(SentinelNamespaceSetting)
+	if namespace != "" && !sentinelNamespaceRegexp.MatchString(namespace) {
+		return newInvalidSentinelNamespaceError(namespace)
+	}
+	return nil
+}
+
+// NamespacedName inserts WALG_SENTINEL_NAMESPACE before the extension of the sentinel or metadata file name,
+// e.g. metadata.json becomes metadata.v2.json. The name is not changed if the namespace is not set.
+func NamespacedName(fileName string) string {
+	namespace := viper.GetString(SentinelNamespaceSetting)
+	if namespace == "" {
+		return fileName
+	}
+	extension := path.Ext(fileName)
+	return strings.TrimSuffix(fileName, extension) + "." + namespace + extension
+}
+
+// SentinelSuffix returns the suffix of the backup sentinel names of WALG_SENTINEL_NAMESPACE
+func SentinelSuffix() string {
+	return NamespacedName(utility.SentinelSuffix)
+}
+
+// IsSentinelOfAnyNamespace checks if the object is the backup sentinel of any namespace, so the backups
+// of the other namespaces are never taken for the garbage
+func IsSentinelOfAnyNamespace(objectName string) bool {
+	return anyNamespaceSentinelRegexp.MatchString(objectName)
+}
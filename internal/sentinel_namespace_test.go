@@ -0,0 +1,63 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func TestConfigureSentinelNamespace(t *testing.T) {
+	defer viper.Set(internal.SentinelNamespaceSetting, "")
+	for namespace, valid := range map[string]bool{"": true, "v2": true, "walg-new": true, "v2.1": false, "a/b": false} {
+		viper.Set(internal.SentinelNamespaceSetting, namespace)
+		err := internal.ConfigureSentinelNamespace()
+		if valid {
+			assert.NoError(t, err, namespace)
+		} else {
+			assert.IsType(t, internal.InvalidSentinelNamespaceError{}, err, namespace)
+		}
+	}
+}
+
+func TestNamespacedNames(t *testing.T) {
+	defer viper.Set(internal.SentinelNamespaceSetting, "")
+	assert.Equal(t, "base_1_backup_stop_sentinel.json", internal.SentinelNameFromBackup("base_1"))
+	assert.Equal(t, "base_1/metadata.json", internal.MetadataNameFromBackup("base_1"))
+
+	viper.Set(internal.SentinelNamespaceSetting, "v2")
+	assert.Equal(t, "base_1_backup_stop_sentinel.v2.json", internal.SentinelNameFromBackup("base_1"))
+	assert.Equal(t, "base_1/metadata.v2.json", internal.MetadataNameFromBackup("base_1"))
+	assert.Equal(t, "files_metadata.v2.json", internal.NamespacedName("files_metadata.json"))
+}
+
+func TestGetBackupsAndGarbage_SentinelNamespace(t *testing.T) {
+	defer viper.Set(internal.SentinelNamespaceSetting, "")
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	for _, name := range []string{
+		"base_000000010000000000000002_backup_stop_sentinel.json",
+		"base_000000010000000000000002/tar_partitions/part_1.tar.lz4",
+		"base_000000010000000000000004_backup_stop_sentinel.v2.json",
+		"base_000000010000000000000004/tar_partitions/part_1.tar.lz4",
+		"base_000000010000000000000006/tar_partitions/part_1.tar.lz4",
+	} {
+		require.NoError(t, folder.PutObject(name, strings.NewReader("{}")))
+	}
+
+	for namespace, backupName := range map[string]string{
+		"":   "base_000000010000000000000002",
+		"v2": "base_000000010000000000000004",
+	} {
+		viper.Set(internal.SentinelNamespaceSetting, namespace)
+		backups, garbage, err := internal.GetBackupsAndGarbage(folder)
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+		assert.Equal(t, backupName, backups[0].BackupName)
+		// the backups of the other namespace are not the garbage
+		assert.Equal(t, []string{"base_000000010000000000000006"}, garbage)
+	}
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+type UnsupportedStorageClassError struct {
+	error
+}
+
+func newUnsupportedStorageClassError(storageClass, storageName string) UnsupportedStorageClassError {
+	return UnsupportedStorageClassError{errors.Errorf(
+		"storage class '%s' is set, but the '%s' storage doesn't support the storage classes", storageClass, storageName)}
+}
+
+func (err UnsupportedStorageClassError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// GetStorageClass returns the storage class of the uploaded objects,
+// the empty storage class means the default one of the storage
+func GetStorageClass() string {
+	return viper.GetString(StorageClassSetting)
+}
+
+// GetWalStorageClass returns the storage class of the uploaded WAL files, which defaults to GetStorageClass
+func GetWalStorageClass() string {
+	if storageClass := viper.GetString(WalStorageClassSetting); storageClass != "" {
+		return storageClass
+	}
+	return GetStorageClass()
+}
+
+// SetStorageClass makes the uploader put the objects into the storage class,
+// both the UploadingFolder and the FailoverStorages must support it
+func (uploader *Uploader) SetStorageClass(storageClass string) error {
+	if storageClass != "" {
+		if _, ok := uploader.UploadingFolder.(storage.StorageClassFolder); !ok {
+			return newUnsupportedStorageClassError(storageClass, PrimaryStorageName)
+		}
+		for _, failoverStorage := range uploader.FailoverStorages {
+			if _, ok := failoverStorage.Folder.(storage.StorageClassFolder); !ok {
+				return newUnsupportedStorageClassError(storageClass, failoverStorage.Name)
+			}
+		}
+		tracelog.DebugLogger.Printf("Uploading the objects into the '%s' storage class\n", storageClass)
+	}
+	uploader.storageClass = storageClass
+	return nil
+}
+
+// putObject puts the object into the storage class and with the metadata if they are set
+func putObject(folder storage.Folder, path string, content io.Reader, storageClass string,
+	metadata map[string]string) error {
+	if len(metadata) > 0 {
+		metadataFolder, ok := folder.(storage.MetadataFolder)
+		if !ok {
+			return newUnsupportedObjectMetadataError(folder.GetPath())
+		}
+		return metadataFolder.PutObjectWithMetadata(path, content, storageClass, metadata)
+	}
+	if storageClass == "" {
+		return folder.PutObject(path, content)
+	}
+	storageClassFolder, ok := folder.(storage.StorageClassFolder)
+	if !ok {
+		return newUnsupportedStorageClassError(storageClass, folder.GetPath())
+	}
+	return storageClassFolder.PutObjectWithStorageClass(path, content, storageClass)
+}
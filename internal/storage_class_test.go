@@ -0,0 +1,69 @@
+package internal_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// storageClassFolder records the storage classes the objects are put into
+type storageClassFolder struct {
+	storage.Folder
+	storageClasses map[string]string
+}
+
+func (folder *storageClassFolder) PutObjectWithStorageClass(name string, content io.Reader, storageClass string) error {
+	folder.storageClasses[name] = storageClass
+	return folder.Folder.PutObject(name, content)
+}
+
+func TestUploader_SetStorageClass(t *testing.T) {
+	folder := &storageClassFolder{memory.NewFolder("in_memory/", memory.NewStorage()), make(map[string]string)}
+	uploader := internal.NewUploader(lz4.Compressor{}, folder)
+
+	require.NoError(t, uploader.Upload("default", strings.NewReader("content")))
+	require.NoError(t, uploader.SetStorageClass("STANDARD_IA"))
+	require.NoError(t, uploader.Upload("infrequent", strings.NewReader("content")))
+	require.NoError(t, uploader.Clone().Upload("cloned", strings.NewReader("content")))
+
+	assert.Equal(t, map[string]string{"infrequent": "STANDARD_IA", "cloned": "STANDARD_IA"}, folder.storageClasses)
+	exists, err := folder.Exists("default")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestUploader_SetStorageClass_Unsupported(t *testing.T) {
+	uploader := internal.NewUploader(lz4.Compressor{}, memory.NewFolder("in_memory/", memory.NewStorage()))
+	assert.NoError(t, uploader.SetStorageClass(""))
+	err := uploader.SetStorageClass("STANDARD_IA")
+	assert.IsType(t, internal.UnsupportedStorageClassError{}, err)
+
+	folder := &storageClassFolder{memory.NewFolder("in_memory/", memory.NewStorage()), make(map[string]string)}
+	uploader = internal.NewUploader(lz4.Compressor{}, folder)
+	uploader.FailoverStorages = []internal.FailoverStorage{
+		{Name: "failover", Folder: memory.NewFolder("in_memory/", memory.NewStorage())},
+	}
+	err = uploader.SetStorageClass("STANDARD_IA")
+	assert.IsType(t, internal.UnsupportedStorageClassError{}, err)
+}
+
+func TestGetWalStorageClass(t *testing.T) {
+	defer viper.Set(internal.StorageClassSetting, "")
+	defer viper.Set(internal.WalStorageClassSetting, "")
+
+	viper.Set(internal.StorageClassSetting, "STANDARD")
+	assert.Equal(t, "STANDARD", internal.GetStorageClass())
+	assert.Equal(t, "STANDARD", internal.GetWalStorageClass())
+
+	viper.Set(internal.WalStorageClassSetting, "STANDARD_IA")
+	assert.Equal(t, "STANDARD", internal.GetStorageClass())
+	assert.Equal(t, "STANDARD_IA", internal.GetWalStorageClass())
+}
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"io"
+	"sync"
 
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
@@ -13,22 +14,68 @@ type StorageReaderMaker struct {
 	localPath       string
 	StorageFileType FileType
 	FileMode        int64
+	// Checksum is validated against the downloaded object if it is recorded
+	Checksum *ObjectChecksum
+
+	// fetchCache is configured by the first Reader call, so the retries don't configure it again
+	fetchCacheOnce sync.Once
+	fetchCache     *FetchCache
+	fetchCacheErr  error
 }
 
 func NewStorageReaderMaker(folder storage.Folder, relativePath string) *StorageReaderMaker {
-	return &StorageReaderMaker{folder, relativePath, relativePath, TarFileType, 0}
+	return &StorageReaderMaker{Folder: folder, storagePath: relativePath, localPath: relativePath,
+		StorageFileType: TarFileType}
 }
 
 func NewRegularFileStorageReaderMarker(folder storage.Folder, storagePath, localPath string, fileMode int64) *StorageReaderMaker {
-	return &StorageReaderMaker{folder, storagePath, localPath, RegularFileType, fileMode}
+	return &StorageReaderMaker{Folder: folder, storagePath: storagePath, localPath: localPath,
+		StorageFileType: RegularFileType, FileMode: fileMode}
 }
 
 func (readerMaker *StorageReaderMaker) StoragePath() string { return readerMaker.storagePath }
 
 func (readerMaker *StorageReaderMaker) LocalPath() string { return readerMaker.localPath }
 
+// Reader reads the object from storage. The object with the recorded checksum is validated,
+// and read from the fetch cache if WALG_FETCH_CACHE_DIR is set. The object without the checksum
+// is never cached, since the cache entry can't be told from the other object stored under the same path.
 func (readerMaker *StorageReaderMaker) Reader() (io.ReadCloser, error) {
-	return readerMaker.Folder.ReadObject(readerMaker.storagePath)
+	if readerMaker.Checksum == nil {
+		return readerMaker.download()
+	}
+	readerMaker.fetchCacheOnce.Do(func() {
+		readerMaker.fetchCache, readerMaker.fetchCacheErr = GetFetchCache()
+	})
+	if readerMaker.fetchCacheErr != nil {
+		return nil, readerMaker.fetchCacheErr
+	}
+	var reader io.ReadCloser
+	var err error
+	if readerMaker.fetchCache != nil {
+		// the URL of the folder includes the bucket, so the objects of the different buckets don't share the entry
+		reader, err = readerMaker.fetchCache.Reader(storage.GetFolderURL(readerMaker.Folder)+readerMaker.storagePath,
+			*readerMaker.Checksum, readerMaker.download)
+	} else {
+		reader, err = readerMaker.download()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewValidatingReader(reader, readerMaker.storagePath, *readerMaker.Checksum), nil
+}
+
+func (readerMaker *StorageReaderMaker) download() (io.ReadCloser, error) {
+	retryPolicy, err := GetStorageRetryPolicy()
+	if err != nil {
+		return nil, err
+	}
+	var reader io.ReadCloser
+	err = retryStorageOperation(retryPolicy, readerMaker.storagePath, func() error {
+		reader, err = readerMaker.Folder.ReadObject(readerMaker.storagePath)
+		return err
+	})
+	return reader, err
 }
 
 func (readerMaker *StorageReaderMaker) FileType() FileType { return readerMaker.StorageFileType }
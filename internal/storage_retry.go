@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"google.golang.org/api/googleapi"
+)
+
+const MaxStorageRetryWait = time.Minute
+
+type InvalidStorageRetryPolicyError struct {
+	error
+}
+
+func newInvalidStorageRetryPolicyError(setting string, value string) InvalidStorageRetryPolicyError {
+	return InvalidStorageRetryPolicyError{errors.Errorf("%s must be non-negative, got: %s", setting, value)}
+}
+
+func (err InvalidStorageRetryPolicyError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// StorageRetryPolicy describes how the failed storage operations are retried
+type StorageRetryPolicy struct {
+	MaxRetries int
+	RetryWait  time.Duration
+}
+
+func (policy StorageRetryPolicy) Enabled() bool {
+	return policy.MaxRetries > 0
+}
+
+// GetStorageRetryPolicy returns the retry policy configured by
+// the WALG_STORAGE_MAX_RETRIES and WALG_STORAGE_RETRY_WAIT settings
+func GetStorageRetryPolicy() (StorageRetryPolicy, error) {
+	maxRetries := viper.GetInt(StorageMaxRetriesSetting)
+	if maxRetries < 0 {
+		return StorageRetryPolicy{}, newInvalidStorageRetryPolicyError(StorageMaxRetriesSetting,
+			viper.GetString(StorageMaxRetriesSetting))
+	}
+	retryWait, err := time.ParseDuration(viper.GetString(StorageRetryWaitSetting))
+	if err != nil {
+		return StorageRetryPolicy{}, errors.Wrapf(err, "failed to parse %s", StorageRetryWaitSetting)
+	}
+	if retryWait < 0 {
+		return StorageRetryPolicy{}, newInvalidStorageRetryPolicyError(StorageRetryWaitSetting,
+			viper.GetString(StorageRetryWaitSetting))
+	}
+	return StorageRetryPolicy{MaxRetries: maxRetries, RetryWait: retryWait}, nil
+}
+
+// retryStorageOperation runs the operation on the object until it succeeds,
+// fails with the non-retryable error or the retries are exhausted.
+// The wait between the attempts grows exponentially and is jittered
+// so the parallel workers don't hit the storage at the same moment.
+func retryStorageOperation(policy StorageRetryPolicy, objectKey string, operation func() error) error {
+	wait := policy.RetryWait
+	for attempt := 1; ; attempt++ {
+		err := operation()
+		if err == nil || attempt > policy.MaxRetries || !IsRetryableStorageError(err) {
+			return err
+		}
+		sleepDuration := jitter(wait)
+		tracelog.WarningLogger.Printf("Retrying the operation on '%s' in %v (retry %d of %d): %v\n",
+			objectKey, sleepDuration, attempt, policy.MaxRetries, err)
+		time.Sleep(sleepDuration)
+		wait *= 2
+		if wait > MaxStorageRetryWait {
+			wait = MaxStorageRetryWait
+		}
+	}
+}
+
+// jitter returns the random duration between the half of the wait and the wait
+func jitter(wait time.Duration) time.Duration {
+	if wait <= 1 {
+		return wait
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// IsRetryableStorageError reports whether the storage operation failed
+// with the transient error: the timeout, the throttling (429) or the server-side (5xx) error
+func IsRetryableStorageError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := errors.Cause(err).(storage.ObjectNotFoundError); ok {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if statusCode, ok := getStorageStatusCode(err); ok {
+		return isRetryableStatusCode(statusCode)
+	}
+	return false
+}
+
+// getStorageStatusCode returns the HTTP status code of the failed storage request
+func getStorageStatusCode(err error) (int, bool) {
+	var statusCodeErr interface{ StatusCode() int } // S3
+	if errors.As(err, &statusCodeErr) {
+		return statusCodeErr.StatusCode(), true
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		return googleErr.Code, true
+	}
+	var azureErr *azcore.ResponseError
+	if errors.As(err, &azureErr) {
+		return azureErr.StatusCode, true
+	}
+	return 0, false
+}
+
+func isRetryableStatusCode(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
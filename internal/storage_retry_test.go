@@ -0,0 +1,161 @@
+package internal_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/testtools"
+)
+
+type statusCodeError struct {
+	statusCode int
+}
+
+func (err statusCodeError) Error() string {
+	return fmt.Sprintf("status code: %d", err.statusCode)
+}
+
+func (err statusCodeError) StatusCode() int {
+	return err.statusCode
+}
+
+// flakyFolder fails the first failuresCount operations with the given error
+type flakyFolder struct {
+	storage.Folder
+	failuresCount int
+	failure       error
+	calls         int
+}
+
+func (folder *flakyFolder) fail() error {
+	folder.calls++
+	if folder.calls <= folder.failuresCount {
+		return errors.Wrap(folder.failure, "flaky folder")
+	}
+	return nil
+}
+
+func (folder *flakyFolder) PutObject(name string, content io.Reader) error {
+	if err := folder.fail(); err != nil {
+		return err
+	}
+	return folder.Folder.PutObject(name, content)
+}
+
+func (folder *flakyFolder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	if err := folder.fail(); err != nil {
+		return nil, err
+	}
+	return folder.Folder.ReadObject(objectRelativePath)
+}
+
+func setStorageRetries(t *testing.T, maxRetries int) {
+	viper.Set(internal.StorageMaxRetriesSetting, maxRetries)
+	viper.Set(internal.StorageRetryWaitSetting, "1ms")
+	t.Cleanup(func() {
+		viper.Set(internal.StorageMaxRetriesSetting, "0")
+		viper.Set(internal.StorageRetryWaitSetting, "1s")
+	})
+}
+
+func TestIsRetryableStorageError(t *testing.T) {
+	assert.True(t, internal.IsRetryableStorageError(statusCodeError{500}))
+	assert.True(t, internal.IsRetryableStorageError(errors.Wrap(statusCodeError{503}, "wrapped")))
+	assert.True(t, internal.IsRetryableStorageError(statusCodeError{429}))
+	assert.True(t, internal.IsRetryableStorageError(io.ErrUnexpectedEOF))
+	assert.False(t, internal.IsRetryableStorageError(statusCodeError{403}))
+	assert.False(t, internal.IsRetryableStorageError(storage.NewObjectNotFoundError("path")))
+	assert.False(t, internal.IsRetryableStorageError(errors.New("broken")))
+	assert.False(t, internal.IsRetryableStorageError(nil))
+}
+
+func TestGetStorageRetryPolicy_Invalid(t *testing.T) {
+	setStorageRetries(t, -1)
+	_, err := internal.GetStorageRetryPolicy()
+	assert.Error(t, err)
+
+	setStorageRetries(t, 1)
+	viper.Set(internal.StorageRetryWaitSetting, "soon")
+	_, err = internal.GetStorageRetryPolicy()
+	assert.Error(t, err)
+}
+
+func TestUploadFile_RetriesRetryableErrors(t *testing.T) {
+	setStorageRetries(t, 3)
+	uploader := testtools.NewStoringMockUploader(memory.NewStorage(), nil)
+	folder := &flakyFolder{Folder: uploader.UploadingFolder, failuresCount: 2, failure: statusCodeError{503}}
+	uploader.UploadingFolder = folder
+
+	_, err := uploader.UploadFile(
+		ioextensions.NewNamedReaderImpl(strings.NewReader("wal"), "000000010000000000000001"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, folder.calls)
+
+	exists, err := folder.Exists("000000010000000000000001.mock")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestUploadFile_DoesNotRetryNonRetryableErrors(t *testing.T) {
+	setStorageRetries(t, 3)
+	uploader := testtools.NewStoringMockUploader(memory.NewStorage(), nil)
+	folder := &flakyFolder{Folder: uploader.UploadingFolder, failuresCount: 1, failure: statusCodeError{403}}
+	uploader.UploadingFolder = folder
+
+	_, err := uploader.UploadFile(
+		ioextensions.NewNamedReaderImpl(strings.NewReader("wal"), "000000010000000000000001"))
+	assert.Error(t, err)
+	assert.Equal(t, 1, folder.calls)
+}
+
+func TestUploadFile_DoesNotReuploadToSucceededStorages(t *testing.T) {
+	setStorageRetries(t, 3)
+	uploader := testtools.NewStoringMockUploader(memory.NewStorage(), nil)
+	primaryFolder := &flakyFolder{Folder: uploader.UploadingFolder}
+	uploader.UploadingFolder = primaryFolder
+	failoverFolder := &flakyFolder{Folder: memory.NewFolder("in_memory/", memory.NewStorage()),
+		failuresCount: 2, failure: statusCodeError{500}}
+	uploader.FailoverStorages = []internal.FailoverStorage{{Name: "secondary", Folder: failoverFolder}}
+
+	statuses, err := uploader.UploadFile(
+		ioextensions.NewNamedReaderImpl(strings.NewReader("wal"), "000000010000000000000001"))
+	assert.NoError(t, err)
+	assert.Equal(t, []internal.UploadStatus{{Storage: internal.PrimaryStorageName}, {Storage: "secondary"}}, statuses)
+	assert.Equal(t, 1, primaryFolder.calls)
+	assert.Equal(t, 3, failoverFolder.calls)
+}
+
+func TestUploadFile_FailsWhenRetriesAreExhausted(t *testing.T) {
+	setStorageRetries(t, 2)
+	uploader := testtools.NewStoringMockUploader(memory.NewStorage(), nil)
+	folder := &flakyFolder{Folder: uploader.UploadingFolder, failuresCount: 5, failure: statusCodeError{500}}
+	uploader.UploadingFolder = folder
+
+	_, err := uploader.UploadFile(
+		ioextensions.NewNamedReaderImpl(strings.NewReader("wal"), "000000010000000000000001"))
+	assert.Error(t, err)
+	assert.Equal(t, 3, folder.calls)
+}
+
+func TestStorageReaderMaker_RetriesRetryableErrors(t *testing.T) {
+	setStorageRetries(t, 3)
+	memoryFolder := memory.NewFolder("in_memory/", memory.NewStorage())
+	assert.NoError(t, memoryFolder.PutObject("object", strings.NewReader("content")))
+	folder := &flakyFolder{Folder: memoryFolder, failuresCount: 1, failure: statusCodeError{429}}
+
+	reader, err := internal.NewStorageReaderMaker(folder, "object").Reader()
+	assert.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	assert.Equal(t, 2, folder.calls)
+}
@@ -2,19 +2,40 @@ package internal
 
 import (
 	"archive/tar"
+	"context"
 	"fmt"
 	"io"
+	"sync"
 	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/limiters"
+	"github.com/wal-g/wal-g/internal/tracing"
 	"github.com/wal-g/wal-g/utility"
 )
 
 const TarPartitionFolderName = "/tar_partitions/"
 
+// TarBallUploadError is returned when the tarball failed to encrypt or upload
+type TarBallUploadError struct {
+	error
+}
+
+func newTarBallUploadError(err error, partNumber int) TarBallUploadError {
+	return TarBallUploadError{errors.Wrapf(err,
+		"Unable to continue the backup process because of the loss of a part %d", partNumber)}
+}
+
+func (err TarBallUploadError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (err TarBallUploadError) ExitCode() int {
+	return ExitCodeStorageIO
+}
+
 // StorageTarBall represents a tar file that is
 // going to be uploaded to storage.
 type StorageTarBall struct {
@@ -25,6 +46,10 @@ type StorageTarBall struct {
 	tarWriter   *tar.Writer
 	uploader    *Uploader
 	name        string
+
+	errMutex sync.Mutex
+	// uploadErr is the first upload or encryption error of the tarball
+	uploadErr error
 }
 
 func (tarBall *StorageTarBall) Name() string {
@@ -51,30 +76,66 @@ func (tarBall *StorageTarBall) SetUp(crypter crypto.Crypter, names ...string) {
 
 // CloseTar closes the tar writer, flushing any unwritten data
 // to the underlying writer before also closing the underlying writer.
+// The upload or encryption error of the tarball known by now is returned in place of the write error it causes.
 func (tarBall *StorageTarBall) CloseTar() error {
 	err := tarBall.tarWriter.Close()
 	if err != nil {
-		return errors.Wrap(err, "CloseTar: failed to close tar writer")
+		return tarBall.errorOr(errors.Wrap(err, "CloseTar: failed to close tar writer"))
 	}
 
 	err = tarBall.writeCloser.Close()
 	if err != nil {
-		return errors.Wrap(err, "CloseTar: failed to close underlying writer")
+		return tarBall.errorOr(errors.Wrap(err, "CloseTar: failed to close underlying writer"))
+	}
+	err = tarBall.getUploadErr()
+	if err != nil {
+		return err
 	}
 	tracelog.InfoLogger.Printf("Finished writing part %d.\n", tarBall.partNumber)
 	return nil
 }
 
-func (tarBall *StorageTarBall) AwaitUploads() {
+// AwaitUploads waits for the uploads and returns the upload or encryption error of the tarball,
+// or the failure of the other uploads of its uploader
+func (tarBall *StorageTarBall) AwaitUploads() error {
 	tarBall.uploader.waitGroup.Wait()
-	if tarBall.uploader.Failed.Load().(bool) {
-		tracelog.ErrorLogger.Fatal("Unable to complete uploads")
+	err := tarBall.getUploadErr()
+	if err != nil {
+		return err
 	}
+	// the canceled uploads are handled by the one who canceled them
+	if tarBall.uploader.Failed.Load().(bool) && !tarBall.uploader.canceled() {
+		return TarBallUploadError{errors.New("Unable to complete uploads")}
+	}
+	return nil
+}
+
+func (tarBall *StorageTarBall) setUploadErr(err error) {
+	tarBall.errMutex.Lock()
+	defer tarBall.errMutex.Unlock()
+	if tarBall.uploadErr == nil {
+		tarBall.uploadErr = err
+	}
+}
+
+func (tarBall *StorageTarBall) getUploadErr() error {
+	tarBall.errMutex.Lock()
+	defer tarBall.errMutex.Unlock()
+	return tarBall.uploadErr
+}
+
+// errorOr returns the upload or encryption error of the tarball if there is one, err otherwise
+func (tarBall *StorageTarBall) errorOr(err error) error {
+	if uploadErr := tarBall.getUploadErr(); uploadErr != nil {
+		return uploadErr
+	}
+	return err
 }
 
 // TODO : unit tests
 // startUpload creates a compressing writer and runs upload in the background once
-// a compressed tar member is finished writing.
+// a compressed tar member is finished writing. The upload and encryption errors are kept by the tarball
+// and fail the writes to it.
 func (tarBall *StorageTarBall) startUpload(name string, crypter crypto.Crypter) io.WriteCloser {
 	pipeReader, pipeWriter := io.Pipe()
 	uploader := tarBall.uploader
@@ -83,34 +144,54 @@ func (tarBall *StorageTarBall) startUpload(name string, crypter crypto.Crypter)
 
 	tracelog.InfoLogger.Printf("Starting part %d ...\n", tarBall.partNumber)
 
+	spanCtx := uploader.ctx
+	if spanCtx == nil {
+		spanCtx = context.Background()
+	}
+	_, span := tracing.StartSpan(spanCtx, "upload tar member",
+		tracing.String("backup.name", tarBall.backupName), tracing.String("tar.member", name))
+
 	uploader.waitGroup.Add(1)
 	go func() {
 		defer uploader.waitGroup.Done()
 
-		err := uploader.Upload(path, limiters.NewNetworkLimitReader(pipeReader))
+		// the checksum of the stored bytes is validated when the tarball is downloaded
+		checksumReader := newChecksumReader(pipeReader)
+		err := uploader.Upload(path, limiters.NewNetworkLimitReader(checksumReader))
+		if err == nil {
+			uploader.recordTarChecksum(name, checksumReader.Checksum())
+		}
+		span.SetAttributes(tracing.Int64("tar.uncompressed_bytes", tarBall.Size()),
+			tracing.Int64("tar.stored_bytes", checksumReader.Checksum().Size))
+		span.EndWithError(err)
 		if compressingError, ok := err.(CompressAndEncryptError); ok {
 			tracelog.ErrorLogger.Printf("could not upload '%s' due to compression error\n%+v\n", path, compressingError)
 		}
+		if err != nil && uploader.canceled() {
+			// the writes to the tarball fail as well, so the backup stops without the loss of a part being fatal
+			tracelog.ErrorLogger.Printf("upload: '%s' is canceled: %v\n", path, err)
+			_ = pipeReader.CloseWithError(err)
+			return
+		}
 		if err != nil {
 			tracelog.ErrorLogger.Printf("upload: could not upload '%s'\n", path)
-			tracelog.ErrorLogger.Printf("%v\n", err)
-			err = pipeReader.Close()
-			tracelog.ErrorLogger.FatalfOnError("Failed to close pipe: %v", err)
-			tracelog.ErrorLogger.Fatalf(
-				"Unable to continue the backup process because of the loss of a part %d.\n",
-				tarBall.partNumber)
+			err = newTarBallUploadError(err, tarBall.partNumber)
+			tarBall.setUploadErr(err)
+			// the writes to the tarball fail with the upload error
+			_ = pipeReader.CloseWithError(err)
 		}
 	}()
 
 	var writerToCompress io.WriteCloser = pipeWriter
-
 	if crypter != nil {
 		encryptedWriter, err := crypter.Encrypt(pipeWriter)
-
 		if err != nil {
-			tracelog.ErrorLogger.Fatal("upload: encryption error ", err)
+			err = newTarBallUploadError(errors.Wrap(err, "upload: encryption error"), tarBall.partNumber)
+			tarBall.setUploadErr(err)
+			// the upload fails on the closed pipe, the writes to the tarball fail as well
+			_ = pipeWriter.CloseWithError(err)
+			return pipeWriter
 		}
-
 		writerToCompress = &utility.CascadeWriteCloser{WriteCloser: encryptedWriter, Underlying: pipeWriter}
 	}
 
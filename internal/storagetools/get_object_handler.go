@@ -47,7 +47,7 @@ func getTargetFilePath(dstPath string, fileName string) (string, error) {
 }
 
 func downloadObject(objectPath string, folder storage.Folder, fileWriter io.Writer, decrypt, decompress bool) error {
-	objReadCloser, err := folder.ReadObject(objectPath)
+	objReadCloser, metadata, err := storage.ReadObjectWithMetadata(folder, objectPath)
 	if err != nil {
 		return err
 	}
@@ -70,7 +70,7 @@ func downloadObject(objectPath string, folder storage.Folder, fileWriter io.Writ
 				"decompressor for extension '%s' was not found (supported methods: %v), will download uncompressed",
 				fileExt, compression.CompressingAlgorithms)
 		} else {
-			decrypterObjReadCloser, err := decompressor.Decompress(objReader)
+			decrypterObjReadCloser, err := compression.WithMetadata(decompressor, metadata).Decompress(objReader)
 			if err != nil {
 				return err
 			}
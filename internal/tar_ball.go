@@ -15,7 +15,8 @@ type TarBall interface {
 	Size() int64
 	AddSize(int64)
 	TarWriter() *tar.Writer
-	AwaitUploads()
+	// AwaitUploads waits for the tarball to be uploaded and returns the upload error
+	AwaitUploads() error
 	Name() string
 }
 
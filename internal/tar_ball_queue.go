@@ -4,8 +4,10 @@ import (
 	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/abool"
 )
 
@@ -17,6 +19,12 @@ type TarBallQueue struct {
 	maxUploadQueue   int
 	mutex            sync.Mutex
 	started          *abool.AtomicBool
+	// finishedTarBalls stores the names of the closed tarballs, their contents won't change anymore
+	finishedTarBalls      []string
+	finishedTarBallsMutex sync.Mutex
+	// blockedTime is the total time in nanoseconds the packing waited for the full upload queue
+	blockedTime int64
+	stopLogging chan struct{}
 
 	TarSizeThreshold   int64
 	AllTarballsSize    *int64
@@ -46,6 +54,10 @@ func (tarQueue *TarBallQueue) StartQueue() error {
 	if err != nil {
 		return err
 	}
+	logInterval, err := getUploadQueueLogInterval()
+	if err != nil {
+		return err
+	}
 
 	tarQueue.tarsToFillQueue = make(chan TarBall, tarQueue.parallelTarballs)
 	tarQueue.uploadQueue = make(chan TarBall, tarQueue.parallelTarballs+tarQueue.maxUploadQueue)
@@ -53,11 +65,31 @@ func (tarQueue *TarBallQueue) StartQueue() error {
 		tarQueue.NewTarBall(true)
 		tarQueue.tarsToFillQueue <- tarQueue.LastCreatedTarball
 	}
+	if logInterval > 0 {
+		tarQueue.stopLogging = make(chan struct{})
+		go tarQueue.logUploadQueue(logInterval, tarQueue.stopLogging)
+	}
 
 	tarQueue.started.Set()
 	return nil
 }
 
+// logUploadQueue logs the upload queue depth periodically, so WALG_UPLOAD_QUEUE can be tuned
+func (tarQueue *TarBallQueue) logUploadQueue(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			tracelog.InfoLogger.Printf("Upload queue: %d of %d finished tarballs are being uploaded, "+
+				"packing waited for the uploads for %s in total", tarQueue.UploadQueueLen(), tarQueue.maxUploadQueue,
+				tarQueue.UploadQueueBlockedTime().Truncate(time.Second))
+		}
+	}
+}
+
 // DequeCtx returns a TarBall from the queue. If the context finishes before it
 // can do so, it returns the result of ctx.Err().
 func (tarQueue *TarBallQueue) DequeCtx(ctx context.Context) (TarBall, error) {
@@ -84,8 +116,14 @@ func (tarQueue *TarBallQueue) FinishQueue() error {
 		panic("Trying to stop not started Queue")
 	}
 	tarQueue.started.UnSet()
+	if tarQueue.stopLogging != nil {
+		close(tarQueue.stopLogging)
+		tarQueue.stopLogging = nil
+	}
 
-	// We have to deque exactly this count of workers
+	// We have to deque exactly this count of workers, all the uploads are awaited
+	// before the first error is returned
+	var uploadErr error
 	for i := 0; i < tarQueue.parallelTarballs; i++ {
 		tarBall := <-tarQueue.tarsToFillQueue
 		if tarBall.TarWriter() == nil {
@@ -93,21 +131,34 @@ func (tarQueue *TarBallQueue) FinishQueue() error {
 			continue
 		}
 		err := tarQueue.CloseTarball(tarBall)
-		if err != nil {
-			return errors.Wrap(err, "HandleWalkedFSObject: failed to close tarball")
+		if err != nil && uploadErr == nil {
+			uploadErr = errors.Wrap(err, "HandleWalkedFSObject: failed to close tarball")
+		}
+		err = tarBall.AwaitUploads()
+		if err != nil && uploadErr == nil {
+			uploadErr = err
 		}
-		tarBall.AwaitUploads()
 	}
 
 	// At this point no new tarballs should be put into uploadQueue
 	for len(tarQueue.uploadQueue) > 0 {
 		select {
 		case otb := <-tarQueue.uploadQueue:
-			otb.AwaitUploads()
+			err := otb.AwaitUploads()
+			if err != nil && uploadErr == nil {
+				uploadErr = err
+			}
 		default:
 		}
 	}
+	if uploadErr != nil {
+		return uploadErr
+	}
 
+	if blockedTime := tarQueue.UploadQueueBlockedTime(); blockedTime > 0 {
+		tracelog.InfoLogger.Printf("Packing waited for the uploads of the full upload queue (%s=%d) for %s in total",
+			UploadQueueSetting, tarQueue.maxUploadQueue, blockedTime.Truncate(time.Millisecond))
+	}
 	return nil
 }
 
@@ -125,12 +176,21 @@ func (tarQueue *TarBallQueue) FinishTarBall(tarBall TarBall) error {
 	}
 
 	tarQueue.uploadQueue <- tarBall
-	for len(tarQueue.uploadQueue) > tarQueue.maxUploadQueue {
-		select {
-		case otb := <-tarQueue.uploadQueue:
-			otb.AwaitUploads()
-		default:
+	// the packing blocks until the oldest tarballs are uploaded, so at most WALG_UPLOAD_QUEUE
+	// finished tarballs hold their upload buffers at the same time
+	if len(tarQueue.uploadQueue) > tarQueue.maxUploadQueue {
+		blockedSince := time.Now()
+		for len(tarQueue.uploadQueue) > tarQueue.maxUploadQueue {
+			select {
+			case otb := <-tarQueue.uploadQueue:
+				err = otb.AwaitUploads()
+				if err != nil {
+					return err
+				}
+			default:
+			}
 		}
+		atomic.AddInt64(&tarQueue.blockedTime, int64(time.Since(blockedSince)))
 	}
 
 	tarQueue.NewTarBall(true)
@@ -147,6 +207,16 @@ func (tarQueue *TarBallQueue) CheckSizeAndEnqueueBack(tarBall TarBall) error {
 	return nil
 }
 
+// UploadQueueLen returns the number of finished tarballs which are still being uploaded
+func (tarQueue *TarBallQueue) UploadQueueLen() int {
+	return len(tarQueue.uploadQueue)
+}
+
+// UploadQueueBlockedTime returns the total time the packing waited for the uploads of the full upload queue
+func (tarQueue *TarBallQueue) UploadQueueBlockedTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&tarQueue.blockedTime))
+}
+
 // NewTarBall starts writing new tarball
 func (tarQueue *TarBallQueue) NewTarBall(dedicatedUploader bool) TarBall {
 	tarQueue.LastCreatedTarball = tarQueue.TarBallMaker.Make(dedicatedUploader)
@@ -155,5 +225,22 @@ func (tarQueue *TarBallQueue) NewTarBall(dedicatedUploader bool) TarBall {
 
 func (tarQueue *TarBallQueue) CloseTarball(tarBall TarBall) error {
 	atomic.AddInt64(tarQueue.AllTarballsSize, tarBall.Size())
-	return tarBall.CloseTar()
+	err := tarBall.CloseTar()
+	if err != nil {
+		return err
+	}
+	tarQueue.finishedTarBallsMutex.Lock()
+	tarQueue.finishedTarBalls = append(tarQueue.finishedTarBalls, tarBall.Name())
+	tarQueue.finishedTarBallsMutex.Unlock()
+	return nil
+}
+
+// FinishedTarBallNames returns the names of the tarballs which are closed and
+// either uploaded or being uploaded
+func (tarQueue *TarBallQueue) FinishedTarBallNames() []string {
+	tarQueue.finishedTarBallsMutex.Lock()
+	defer tarQueue.finishedTarBallsMutex.Unlock()
+	names := make([]string, len(tarQueue.finishedTarBalls))
+	copy(names, tarQueue.finishedTarBalls)
+	return names
 }
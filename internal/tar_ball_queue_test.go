@@ -0,0 +1,60 @@
+package internal_test
+
+import (
+	"archive/tar"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+// slowTarBall is the empty tarball which upload takes the delay
+type slowTarBall struct {
+	delay time.Duration
+}
+
+func (tarBall *slowTarBall) SetUp(crypto.Crypter, ...string) {}
+func (tarBall *slowTarBall) CloseTar() error                 { return nil }
+func (tarBall *slowTarBall) Size() int64                     { return 0 }
+func (tarBall *slowTarBall) AddSize(int64)                   {}
+func (tarBall *slowTarBall) TarWriter() *tar.Writer          { return nil }
+func (tarBall *slowTarBall) Name() string                    { return "slowTarBall" }
+func (tarBall *slowTarBall) AwaitUploads() error {
+	time.Sleep(tarBall.delay)
+	return nil
+}
+
+type slowTarBallMaker struct {
+	delay time.Duration
+}
+
+func (maker *slowTarBallMaker) Make(bool) internal.TarBall {
+	return &slowTarBall{delay: maker.delay}
+}
+
+func finishSlowTarBalls(t *testing.T, uploadQueue, count int) *internal.TarBallQueue {
+	viper.Set(internal.UploadQueueSetting, uploadQueue)
+	defer viper.Set(internal.UploadQueueSetting, "2")
+	queue := internal.NewTarBallQueue(1, &slowTarBallMaker{delay: 10 * time.Millisecond})
+	require.NoError(t, queue.StartQueue())
+	for i := 0; i < count; i++ {
+		require.NoError(t, queue.FinishTarBall(queue.Deque()))
+		assert.LessOrEqual(t, queue.UploadQueueLen(), uploadQueue)
+	}
+	require.NoError(t, queue.FinishQueue())
+	return queue
+}
+
+func TestTarBallQueue_BlocksOnFullUploadQueue(t *testing.T) {
+	queue := finishSlowTarBalls(t, 1, 4)
+	assert.GreaterOrEqual(t, queue.UploadQueueBlockedTime(), 30*time.Millisecond)
+}
+
+func TestTarBallQueue_DoesNotBlockBelowUploadQueue(t *testing.T) {
+	queue := finishSlowTarBalls(t, 5, 4)
+	assert.Equal(t, time.Duration(0), queue.UploadQueueBlockedTime())
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/testtools"
 )
 
@@ -74,6 +75,28 @@ func TestS3DependentFunctions(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestStorageTarBall_RecordsChecksum(t *testing.T) {
+	storage := memory.NewStorage()
+	uploader := testtools.NewStoringMockUploader(storage, nil)
+	tarBall := internal.NewStorageTarBallMaker("mockBackup", uploader).Make(false)
+	tarBall.SetUp(nil, "part_001.tar.mock")
+	mockData := []byte("a")
+	assert.NoError(t, tarBall.TarWriter().WriteHeader(&tar.Header{Name: "mock", Size: int64(len(mockData))}))
+	_, err := tarBall.TarWriter().Write(mockData)
+	assert.NoError(t, err)
+	assert.NoError(t, tarBall.CloseTar())
+	assert.NoError(t, tarBall.AwaitUploads())
+
+	checksum, ok := uploader.TarChecksums()["part_001.tar.mock"]
+	assert.True(t, ok)
+	readerMaker := internal.NewStorageReaderMaker(uploader.UploadingFolder, "mockBackup"+internal.TarPartitionFolderName+"part_001.tar.mock")
+	readerMaker.Checksum = &checksum
+	reader, err := readerMaker.Reader()
+	assert.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.NoError(t, err)
+}
+
 func TestPackFileTo(t *testing.T) {
 	mockData := "mock"
 	mockHeader := &tar.Header{
@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	otlpTracesPath = "/v1/traces"
+	serviceName    = "wal-g"
+	exportTimeout  = 10 * time.Second
+
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// otlpExporter sends the spans to the OTLP/HTTP endpoint in the JSON encoding,
+// the failed exports are logged and the spans are dropped
+type otlpExporter struct {
+	url      string
+	client   *http.Client
+	resource otlpResource
+}
+
+func newOtlpExporter(endpoint string) *otlpExporter {
+	attributes := []otlpAttribute{newOtlpAttribute(String("service.name", serviceName))}
+	if hostname, err := os.Hostname(); err == nil {
+		attributes = append(attributes, newOtlpAttribute(String("host.name", hostname)))
+	}
+	return &otlpExporter{
+		url:      strings.TrimSuffix(endpoint, "/") + otlpTracesPath,
+		client:   &http.Client{Timeout: exportTimeout},
+		resource: otlpResource{Attributes: attributes},
+	}
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+// otlpAttributeValue holds one of the values, the 64-bit integers are encoded as strings
+type otlpAttributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func newOtlpAttribute(attribute Attribute) otlpAttribute {
+	value := attribute.stringValue
+	if attribute.isInt {
+		value = strconv.FormatInt(attribute.intValue, 10)
+		return otlpAttribute{Key: attribute.Key, Value: otlpAttributeValue{IntValue: &value}}
+	}
+	return otlpAttribute{Key: attribute.Key, Value: otlpAttributeValue{StringValue: &value}}
+}
+
+func newOtlpSpan(span *Span) otlpSpan {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	result := otlpSpan{
+		TraceID:           hex.EncodeToString(span.traceID[:]),
+		SpanID:            hex.EncodeToString(span.spanID[:]),
+		Name:              span.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+	}
+	if span.parentID != (spanID{}) {
+		result.ParentSpanID = hex.EncodeToString(span.parentID[:])
+	}
+	for _, attribute := range span.attributes {
+		result.Attributes = append(result.Attributes, newOtlpAttribute(attribute))
+	}
+	if span.err != nil {
+		result.Status = &otlpStatus{Code: statusCodeError, Message: span.err.Error()}
+	}
+	return result
+}
+
+func (exporter *otlpExporter) export(spans []*Span) {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, newOtlpSpan(span))
+	}
+	body, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   exporter.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: serviceName}, Spans: otlpSpans}},
+	}}})
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to encode %d spans: %v", len(spans), err)
+		return
+	}
+	response, err := exporter.client.Post(exporter.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to export %d spans to %s: %v", len(spans), exporter.url, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		tracelog.WarningLogger.Printf("Failed to export %d spans to %s: %s", len(spans), exporter.url, response.Status)
+	}
+}
@@ -0,0 +1,214 @@
+// Package tracing records the OpenTelemetry spans of the WAL-G operations and exports them
+// to the OTLP/HTTP endpoint. The tracing is disabled until Start is called: the spans are nil then,
+// and starting or ending them does nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// Attribute is the key-value pair describing the span
+type Attribute struct {
+	Key         string
+	stringValue string
+	intValue    int64
+	isInt       bool
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, stringValue: value}
+}
+
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, intValue: value, isInt: true}
+}
+
+type traceID [16]byte
+type spanID [8]byte
+
+// Span is the timed operation, the nil span of the disabled tracing does nothing
+type Span struct {
+	tracer   *tracer
+	traceID  traceID
+	spanID   spanID
+	parentID spanID
+	name     string
+	start    time.Time
+
+	mutex      sync.Mutex
+	end        time.Time
+	attributes []Attribute
+	err        error
+}
+
+type spanContextKey struct{}
+
+// tracer is set by Start only, so the disabled tracing costs a nil check
+var activeTracer *tracer
+
+// StartSpan starts the span which is the child of the span of the context, or of the operation span
+// if the context has none. The returned context carries the started span.
+func StartSpan(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	if activeTracer == nil {
+		return ctx, nil
+	}
+	parent := FromContext(ctx)
+	if parent == nil {
+		parent = activeTracer.operation
+	}
+	span := activeTracer.newSpan(name, parent, attributes)
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// FromContext returns the span carried by the context, nil if there is none
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// SetAttributes adds the attributes to the span
+func (span *Span) SetAttributes(attributes ...Attribute) {
+	if span == nil {
+		return
+	}
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.attributes = append(span.attributes, attributes...)
+}
+
+// SetError marks the span failed with the error, the nil error is ignored
+func (span *Span) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.err = err
+}
+
+// EndWithError marks the span failed with the error if it is not nil and finishes the span
+func (span *Span) EndWithError(err error) {
+	span.SetError(err)
+	span.End()
+}
+
+// End finishes the span and queues it for the export, the ended span is not changed anymore
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+	span.mutex.Lock()
+	if !span.end.IsZero() {
+		span.mutex.Unlock()
+		return
+	}
+	span.end = time.Now()
+	span.mutex.Unlock()
+	span.tracer.queue(span)
+}
+
+// Start enables the tracing: the spans are exported to the endpoint in batches, and the spans started
+// without a parent are the children of the operation span. Returns the function which ends the operation
+// span and exports the rest of the spans, the calls after the first one do nothing.
+func Start(endpoint, operationName string, attributes ...Attribute) (stop func()) {
+	activeTracer = newTracer(newOtlpExporter(endpoint))
+	activeTracer.operation = activeTracer.newSpan(operationName, nil, attributes)
+	activeTracer.start()
+	var stopOnce sync.Once
+	return func() {
+		stopOnce.Do(func() {
+			activeTracer.operation.End()
+			activeTracer.stop()
+			activeTracer = nil
+		})
+	}
+}
+
+const (
+	exportInterval  = 5 * time.Second
+	exportBatchSize = 512
+)
+
+// tracer queues the ended spans and exports them in the background
+type tracer struct {
+	exporter  *otlpExporter
+	operation *Span
+
+	mutex   sync.Mutex
+	ended   []*Span
+	flush   chan struct{}
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+func newTracer(exporter *otlpExporter) *tracer {
+	return &tracer{
+		exporter: exporter,
+		flush:    make(chan struct{}, 1),
+		stopped:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (tracer *tracer) newSpan(name string, parent *Span, attributes []Attribute) *Span {
+	span := &Span{tracer: tracer, name: name, start: time.Now(), attributes: attributes}
+	if parent != nil {
+		span.traceID, span.parentID = parent.traceID, parent.spanID
+	} else {
+		_, _ = rand.Read(span.traceID[:])
+	}
+	_, _ = rand.Read(span.spanID[:])
+	return span
+}
+
+func (tracer *tracer) queue(span *Span) {
+	tracer.mutex.Lock()
+	tracer.ended = append(tracer.ended, span)
+	full := len(tracer.ended) >= exportBatchSize
+	tracer.mutex.Unlock()
+	if full {
+		select {
+		case tracer.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (tracer *tracer) start() {
+	go func() {
+		defer close(tracer.done)
+		ticker := time.NewTicker(exportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-tracer.flush:
+			case <-tracer.stopped:
+				tracer.export()
+				return
+			}
+			tracer.export()
+		}
+	}()
+}
+
+func (tracer *tracer) stop() {
+	close(tracer.stopped)
+	<-tracer.done
+}
+
+func (tracer *tracer) export() {
+	tracer.mutex.Lock()
+	spans := tracer.ended
+	tracer.ended = nil
+	tracer.mutex.Unlock()
+	if len(spans) > 0 {
+		tracer.exporter.export(spans)
+	}
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartSpan_Disabled(t *testing.T) {
+	ctx, span := StartSpan(context.Background(), "walk", String("backup.name", "base_000000010000000000000002"))
+	assert.Nil(t, span)
+	assert.Nil(t, FromContext(ctx))
+
+	span.SetAttributes(Int64("bytes", 1))
+	span.EndWithError(errors.New("failed"))
+}
+
+func TestStart_ExportsSpans(t *testing.T) {
+	var mutex sync.Mutex
+	var exported []otlpSpan
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, otlpTracesPath, request.URL.Path)
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+		var traces otlpTraces
+		assert.NoError(t, json.NewDecoder(request.Body).Decode(&traces))
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, resourceSpans := range traces.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				exported = append(exported, scopeSpans.Spans...)
+			}
+		}
+	}))
+	defer server.Close()
+
+	stop := Start(server.URL+"/", "wal-g backup-push")
+	ctx, backupSpan := StartSpan(context.Background(), "backup-push")
+	_, walkSpan := StartSpan(ctx, "walk", String("backup.name", "base_000000010000000000000002"))
+	walkSpan.End()
+	_, uploadSpan := StartSpan(context.Background(), "upload tar member", String("tar.member", "part_001.tar.lz4"))
+	uploadSpan.SetAttributes(Int64("tar.stored_bytes", 1024))
+	uploadSpan.EndWithError(errors.New("storage is unavailable"))
+	backupSpan.End()
+	stop()
+	// the exit hook stops the tracing again after the failed command
+	stop()
+
+	spans := make(map[string]otlpSpan)
+	for _, span := range exported {
+		spans[span.Name] = span
+	}
+	require.Len(t, spans, 4)
+	operation := spans["wal-g backup-push"]
+	assert.Empty(t, operation.ParentSpanID)
+	assert.Len(t, operation.TraceID, 32)
+	for _, name := range []string{"backup-push", "walk", "upload tar member"} {
+		assert.Equal(t, operation.TraceID, spans[name].TraceID, name)
+	}
+	assert.Equal(t, operation.SpanID, spans["backup-push"].ParentSpanID)
+	assert.Equal(t, spans["backup-push"].SpanID, spans["walk"].ParentSpanID)
+	// the spans without the parent in the context are the children of the operation span
+	assert.Equal(t, operation.SpanID, spans["upload tar member"].ParentSpanID)
+
+	uploadAttributes := spans["upload tar member"].Attributes
+	require.Len(t, uploadAttributes, 2)
+	assert.Equal(t, "part_001.tar.lz4", *uploadAttributes[0].Value.StringValue)
+	assert.Equal(t, "1024", *uploadAttributes[1].Value.IntValue)
+	assert.Equal(t, &otlpStatus{Code: statusCodeError, Message: "storage is unavailable"}, spans["upload tar member"].Status)
+	assert.Nil(t, spans["walk"].Status)
+
+	_, span := StartSpan(context.Background(), "after stop")
+	assert.Nil(t, span)
+}
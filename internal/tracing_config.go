@@ -0,0 +1,19 @@
+package internal
+
+import (
+	"github.com/spf13/viper"
+	"github.com/wal-g/wal-g/internal/tracing"
+)
+
+// StartTracing starts exporting the spans of the command to WALG_OTEL_ENDPOINT if it is set.
+// Returns the function which exports the rest of the spans once the command is finished,
+// it is also called if the command fails by FatalOnError, which exits without running the post-run hooks.
+func StartTracing(commandPath string) (stop func()) {
+	endpoint := viper.GetString(OtelEndpointSetting)
+	if endpoint == "" {
+		return func() {}
+	}
+	stop = tracing.Start(endpoint, commandPath)
+	AddExitHook(stop)
+	return stop
+}
@@ -8,6 +8,11 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/testtools"
+	"github.com/wal-g/wal-g/utility"
 )
 
 func TestConfigure(t *testing.T) {
@@ -67,3 +72,50 @@ func doConfigureWithBucketPath(t *testing.T, bucketPath string, expectedServer s
 	_, err = internal.ConfigureUploader()
 	assert.NoError(t, err)
 }
+
+func TestUploadFile_MirrorsToFailoverStorages(t *testing.T) {
+	uploader := testtools.NewStoringMockUploader(memory.NewStorage(), nil)
+	failoverFolder := memory.NewFolder("in_memory/", memory.NewStorage())
+	uploader.FailoverStorages = []internal.FailoverStorage{{Name: "secondary", Folder: failoverFolder}}
+	uploader.ChangeDirectory(utility.WalPath)
+
+	statuses, err := uploader.UploadFile(
+		ioextensions.NewNamedReaderImpl(strings.NewReader("wal"), "000000010000000000000001"))
+	assert.NoError(t, err)
+	assert.Equal(t, []internal.UploadStatus{{Storage: internal.PrimaryStorageName}, {Storage: "secondary"}}, statuses)
+
+	for _, folder := range []storage.Folder{uploader.UploadingFolder, failoverFolder.GetSubFolder(utility.WalPath)} {
+		exists, err := folder.Exists("000000010000000000000001.mock")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	}
+}
+
+func TestUploadFile_FailsOverWhenPrimaryFails(t *testing.T) {
+	uploader := testtools.NewMockUploader(false, true)
+	failoverFolder := memory.NewFolder("in_memory/", memory.NewStorage())
+	uploader.FailoverStorages = []internal.FailoverStorage{{Name: "secondary", Folder: failoverFolder}}
+
+	statuses, err := uploader.UploadFile(
+		ioextensions.NewNamedReaderImpl(strings.NewReader("wal"), "000000010000000000000001"))
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+	assert.Error(t, statuses[0].Err)
+	assert.Equal(t, internal.UploadStatus{Storage: "secondary"}, statuses[1])
+
+	exists, err := failoverFolder.Exists("000000010000000000000001.mock")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestUploadFile_FailsWhenAllStoragesFail(t *testing.T) {
+	uploader := testtools.NewMockUploader(false, true)
+	failoverFolder := testtools.NewMockUploader(false, true).UploadingFolder
+	uploader.FailoverStorages = []internal.FailoverStorage{{Name: "secondary", Folder: failoverFolder}}
+
+	statuses, err := uploader.UploadFile(
+		ioextensions.NewNamedReaderImpl(strings.NewReader("wal"), "000000010000000000000001"))
+	assert.Error(t, err)
+	assert.Len(t, statuses, 2)
+	assert.Error(t, statuses[1].Err)
+}
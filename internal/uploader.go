@@ -1,12 +1,15 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
 
+	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/asm"
 	"github.com/wal-g/wal-g/internal/compression"
@@ -19,7 +22,7 @@ var ErrorSizeTrackingDisabled = fmt.Errorf("size tracking disabled by DisableSiz
 
 type UploaderProvider interface {
 	Upload(path string, content io.Reader) error
-	UploadFile(file ioextensions.NamedReader) error
+	UploadFile(file ioextensions.NamedReader) ([]UploadStatus, error)
 	PushStream(stream io.Reader) (string, error)
 	PushStreamToDestination(stream io.Reader, dstPath string) error
 	Compression() compression.Compressor
@@ -28,6 +31,7 @@ type UploaderProvider interface {
 	RawDataSize() (int64, error)
 	ChangeDirectory(relativePath string)
 	Folder() storage.Folder
+	SetStorageClass(storageClass string) error
 }
 
 // Uploader contains fields associated with uploading tarballs.
@@ -41,10 +45,35 @@ type Uploader struct {
 	Failed                 atomic.Value
 	tarSize                *int64
 	dataSize               *int64
+	// tarChecksums maps the names of the uploaded tarballs to their checksums
+	tarChecksums *sync.Map
+	// FailoverStorages receive the copies of the files uploaded by UploadFile
+	FailoverStorages []FailoverStorage
+	// ctx cancels the uploads in progress once it is done, nil means the uploads are never canceled
+	ctx context.Context
+	// storageClass of the uploaded objects, the default storage class of the storage is used if empty
+	storageClass string
+	// metadata the objects are uploaded with
+	metadata map[string]string
 }
 
 var _ UploaderProvider = &Uploader{}
 
+// PrimaryStorageName is the name of the UploadingFolder storage in the upload statuses
+const PrimaryStorageName = "default"
+
+// FailoverStorage is an additional storage which keeps the copies of the uploaded files
+type FailoverStorage struct {
+	Name   string
+	Folder storage.Folder
+}
+
+// UploadStatus is the result of the file upload to a single storage
+type UploadStatus struct {
+	Storage string
+	Err     error
+}
+
 // SplitStreamUploader - new UploaderProvider implementation that enable us to split upload streams into blocks
 //   of blockSize bytes, then puts it in at most `partitions` streams that are compressed and pushed to storage
 type SplitStreamUploader struct {
@@ -72,6 +101,7 @@ func NewUploader(
 		waitGroup:       &sync.WaitGroup{},
 		tarSize:         new(int64),
 		dataSize:        new(int64),
+		tarChecksums:    &sync.Map{},
 	}
 	uploader.Failed.Store(false)
 	return uploader
@@ -95,6 +125,7 @@ func NewSplitStreamUploader(
 			waitGroup:       &sync.WaitGroup{},
 			tarSize:         new(int64),
 			dataSize:        new(int64),
+			tarChecksums:    &sync.Map{},
 		},
 		partitions: partitions,
 		blockSize:  blockSize,
@@ -138,24 +169,124 @@ func (uploader *Uploader) Clone() *Uploader {
 		Failed:               uploader.Failed,
 		tarSize:              uploader.tarSize,
 		dataSize:             uploader.dataSize,
+		tarChecksums:         uploader.tarChecksums,
+		FailoverStorages:     uploader.FailoverStorages,
+		ctx:                  uploader.ctx,
+		storageClass:         uploader.storageClass,
+		metadata:             uploader.metadata,
 	}
 }
 
-// TODO : unit tests
-// UploadFile compresses a file and uploads it.
-func (uploader *Uploader) UploadFile(file ioextensions.NamedReader) error {
+// SetContext makes the uploads fail once the context is done, including the ones in progress
+func (uploader *Uploader) SetContext(ctx context.Context) {
+	uploader.ctx = ctx
+}
+
+// canceled tells whether the uploads fail because the context of the uploader is done
+func (uploader *Uploader) canceled() bool {
+	return uploader.ctx != nil && uploader.ctx.Err() != nil
+}
+
+// TarChecksums returns the checksums of the tarballs uploaded by this uploader and its clones
+func (uploader *Uploader) TarChecksums() map[string]ObjectChecksum {
+	tarChecksums := make(map[string]ObjectChecksum)
+	if uploader.tarChecksums == nil {
+		return tarChecksums
+	}
+	uploader.tarChecksums.Range(func(name, checksum interface{}) bool {
+		tarChecksums[name.(string)] = checksum.(ObjectChecksum)
+		return true
+	})
+	return tarChecksums
+}
+
+func (uploader *Uploader) recordTarChecksum(name string, checksum ObjectChecksum) {
+	if uploader.tarChecksums != nil {
+		uploader.tarChecksums.Store(name, checksum)
+	}
+}
+
+// UploadFile compresses a file and uploads it. When there are failover storages,
+// the file is uploaded to all of them, and the upload fails only if no storage has received it.
+// The returned statuses start with the PrimaryStorageName one, then follow the FailoverStorages order.
+func (uploader *Uploader) UploadFile(file ioextensions.NamedReader) ([]UploadStatus, error) {
 	filename := file.Name()
+	retryPolicy, err := GetStorageRetryPolicy()
+	if err != nil {
+		return nil, err
+	}
 
-	fileReader := file.(io.Reader)
+	var fileSize int64
+	fileReader := io.Reader(NewWithSizeReader(file, &fileSize))
 	if uploader.dataSize != nil {
 		fileReader = NewWithSizeReader(fileReader, uploader.dataSize)
 	}
 	compressedFile := CompressAndEncrypt(fileReader, uploader.Compressor, ConfigureCrypter())
 	dstPath := utility.SanitizePath(filepath.Base(filename) + "." + uploader.Compressor.FileExtension())
 
-	err := uploader.Upload(dstPath, compressedFile)
-	tracelog.InfoLogger.Println("FILE PATH:", dstPath)
-	return err
+	if len(uploader.FailoverStorages) == 0 && !retryPolicy.Enabled() {
+		err := uploader.Upload(dstPath, compressedFile)
+		InfoLogWithFields(LogFields{"file_path": dstPath, "bytes": fileSize}, "FILE PATH: %s", dstPath)
+		return []UploadStatus{{Storage: PrimaryStorageName, Err: err}}, err
+	}
+
+	statuses, err := uploader.uploadBuffered(dstPath, compressedFile, retryPolicy)
+	InfoLogWithFields(LogFields{"file_path": dstPath, "bytes": fileSize}, "FILE PATH: %s", dstPath)
+	return statuses, err
+}
+
+// uploadBuffered uploads the content to the UploadingFolder and to the failover storages in parallel.
+// The content is read only once, so the file is not compressed and encrypted again for each storage
+// and for each retry. The retries of each storage are independent, so the storages which already
+// succeeded don't receive the content again.
+func (uploader *Uploader) uploadBuffered(path string, content io.Reader,
+	retryPolicy StorageRetryPolicy) ([]UploadStatus, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		uploader.Failed.Store(true)
+		return nil, errors.Wrapf(err, "failed to read the content of '%s'", path)
+	}
+	if uploader.tarSize != nil {
+		atomic.AddInt64(uploader.tarSize, int64(len(data)))
+	}
+
+	storages := append([]FailoverStorage{{Name: PrimaryStorageName, Folder: uploader.UploadingFolder}},
+		uploader.FailoverStorages...)
+	statuses := make([]UploadStatus, len(storages))
+	var wg sync.WaitGroup
+	for i := range storages {
+		statuses[i].Storage = storages[i].Name
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			WalgMetrics.uploadedFilesTotal.Inc()
+			statuses[i].Err = retryStorageOperation(retryPolicy, path, func() error {
+				return putObject(storages[i].Folder, path, bytes.NewReader(data), uploader.storageClass, uploader.metadata)
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	var succeeded []string
+	for _, status := range statuses {
+		if status.Err != nil {
+			WalgMetrics.uploadedFilesFailedTotal.Inc()
+			tracelog.WarningLogger.Printf("Failed to upload '%s' to the '%s' storage: %v\n", path, status.Storage, status.Err)
+			continue
+		}
+		succeeded = append(succeeded, status.Storage)
+	}
+	if len(succeeded) == 0 {
+		uploader.Failed.Store(true)
+		return statuses, errors.Wrapf(statuses[0].Err, "failed to upload '%s' to any of the %d storages",
+			path, len(statuses))
+	}
+	if statuses[0].Err != nil {
+		tracelog.WarningLogger.Printf("'%s' failed over to the storages: %v\n", path, succeeded)
+	} else {
+		tracelog.InfoLogger.Printf("'%s' is uploaded to the storages: %v\n", path, succeeded)
+	}
+	return statuses, nil
 }
 
 // DisableSizeTracking stops bandwidth tracking
@@ -175,7 +306,10 @@ func (uploader *Uploader) Upload(path string, content io.Reader) error {
 	if uploader.tarSize != nil {
 		content = NewWithSizeReader(content, uploader.tarSize)
 	}
-	err := uploader.UploadingFolder.PutObject(path, content)
+	if uploader.ctx != nil {
+		content = &contextReader{ctx: uploader.ctx, reader: content}
+	}
+	err := putObject(uploader.UploadingFolder, path, content, uploader.storageClass, uploader.metadata)
 	if err != nil {
 		WalgMetrics.uploadedFilesFailedTotal.Inc()
 		uploader.Failed.Store(true)
@@ -185,6 +319,20 @@ func (uploader *Uploader) Upload(path string, content io.Reader) error {
 	return nil
 }
 
+// contextReader fails the read once the context is done,
+// so the storage aborts the upload of the content
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (reader *contextReader) Read(p []byte) (int, error) {
+	if err := reader.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return reader.reader.Read(p)
+}
+
 // UploadMultiple uploads multiple objects from the start of the slice,
 // returning the first error if any. Note that this operation is not atomic
 // TODO : unit tests
@@ -201,6 +349,12 @@ func (uploader *Uploader) UploadMultiple(objects []UploadObject) error {
 
 func (uploader *Uploader) ChangeDirectory(relativePath string) {
 	uploader.UploadingFolder = uploader.UploadingFolder.GetSubFolder(relativePath)
+	failoverStorages := make([]FailoverStorage, 0, len(uploader.FailoverStorages))
+	for _, failoverStorage := range uploader.FailoverStorages {
+		failoverStorages = append(failoverStorages,
+			FailoverStorage{Name: failoverStorage.Name, Folder: failoverStorage.Folder.GetSubFolder(relativePath)})
+	}
+	uploader.FailoverStorages = failoverStorages
 }
 
 func (uploader *Uploader) Folder() storage.Folder {
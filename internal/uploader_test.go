@@ -0,0 +1,33 @@
+package internal_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func TestUploader_SetContext(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	uploader := internal.NewUploader(&lz4.Compressor{}, folder)
+	ctx, cancel := context.WithCancel(context.Background())
+	uploader.SetContext(ctx)
+
+	assert.NoError(t, uploader.Upload("before", strings.NewReader("content")))
+	cancel()
+	err := uploader.Upload("after", strings.NewReader("content"))
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, uploader.Failed.Load().(bool))
+	exists, err := folder.Exists("after")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// the clones of the uploader are canceled as well
+	err = uploader.Clone().Upload("clone", strings.NewReader("content"))
+	assert.True(t, errors.Is(err, context.Canceled))
+}
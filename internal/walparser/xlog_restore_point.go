@@ -0,0 +1,52 @@
+package walparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	XLogRestorePoint = 0x70
+	// RestorePointNameSize corresponds to postgres MAXFNAMELEN, the size of the restore point name buffer
+	RestorePointNameSize = 64
+	restorePointDataSize = 8 + RestorePointNameSize
+)
+
+// postgresEpoch is the origin of the postgres timestamps
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+/* This struct corresponds to postgres struct xl_restore_point.
+ * For clarification you can find it in postgres:
+ * src/include/access/xlog_internal.h
+ */
+type XLogRestorePointData struct {
+	Time time.Time
+	Name string
+}
+
+// IsRestorePoint checks if the record is written by pg_create_restore_point
+func (record *XLogRecord) IsRestorePoint() bool {
+	return record.Header.ResourceManagerID == RmXlogID &&
+		(record.Header.Info&^XlrInfoMask) == XLogRestorePoint
+}
+
+// ParseRestorePointData parses the main data of the restore point record
+func ParseRestorePointData(record *XLogRecord) (*XLogRestorePointData, error) {
+	if len(record.MainData) < restorePointDataSize {
+		return nil, errors.Errorf("restore point record data is too short: %d, expected %d",
+			len(record.MainData), restorePointDataSize)
+	}
+	// the time is the number of microseconds since the postgres epoch
+	microseconds := int64(binary.LittleEndian.Uint64(record.MainData[:8]))
+	name := record.MainData[8:restorePointDataSize]
+	if end := bytes.IndexByte(name, 0); end >= 0 {
+		name = name[:end]
+	}
+	return &XLogRestorePointData{
+		Time: postgresEpoch.Add(time.Duration(microseconds) * time.Microsecond),
+		Name: string(name),
+	}, nil
+}
@@ -0,0 +1,35 @@
+// Package backups lets the Go programs built together with WAL-G read the backups of the storage
+// without running the CLI. The signatures of its functions are kept stable.
+package backups
+
+import (
+	"github.com/spf13/viper"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// BackupTime is the name of the backup with the modification time of its sentinel
+type BackupTime = internal.BackupTime
+
+// BackupNonExistenceError is returned for the missing backup
+type BackupNonExistenceError = internal.BackupNonExistenceError
+
+// ConfigureFolder returns the root folder of the storage described by the WAL-G settings of the config,
+// the folder the functions of the package expect, e.g. of the viper instance which read the WAL-G config file.
+// Call config.AutomaticEnv() to take the settings from the environment variables as the CLI does.
+func ConfigureFolder(config *viper.Viper) (storage.Folder, error) {
+	return internal.ConfigureFolderFromConfig(config)
+}
+
+// ListBackups returns the backups of the storage sorted from the oldest to the newest, the list is empty
+// if there are no backups. The folder is the root folder of the storage returned by ConfigureFolder.
+func ListBackups(folder storage.Folder) ([]BackupTime, error) {
+	return ListBackupsInFolder(folder.GetSubFolder(utility.BaseBackupPath))
+}
+
+// ListBackupsInFolder returns the backups with the sentinels in the folder sorted from the oldest to the newest,
+// e.g. of the catchup folder. This is the listing backup-list prints.
+func ListBackupsInFolder(folder storage.Folder) ([]BackupTime, error) {
+	return internal.ListSortedBackups(folder)
+}
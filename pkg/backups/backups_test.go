@@ -0,0 +1,55 @@
+package backups_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/backups"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func TestListBackups(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	backupTimes, err := backups.ListBackups(folder)
+	assert.NoError(t, err)
+	assert.Empty(t, backupTimes)
+
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	for _, backupName := range []string{"base_000000010000000000000004", "base_000000010000000000000002"} {
+		assert.NoError(t, baseBackupFolder.PutObject(backupName+utility.SentinelSuffix, strings.NewReader("{}")))
+		time.Sleep(time.Millisecond)
+	}
+	// the folder of the backup without the sentinel is not listed
+	assert.NoError(t, baseBackupFolder.PutObject("base_000000010000000000000006/metadata.json", strings.NewReader("{}")))
+
+	backupTimes, err = backups.ListBackups(folder)
+	assert.NoError(t, err)
+	assert.Len(t, backupTimes, 2)
+	assert.Equal(t, "base_000000010000000000000004", backupTimes[0].BackupName)
+	assert.Equal(t, "base_000000010000000000000002", backupTimes[1].BackupName)
+	assert.Equal(t, "000000010000000000000002", backupTimes[1].WalFileName)
+}
+
+func TestConfigureFolder(t *testing.T) {
+	config := viper.New()
+	_, err := backups.ConfigureFolder(config)
+	assert.Error(t, err)
+
+	config.Set("WALG_FILE_PREFIX", t.TempDir())
+	config.Set(internal.StoragePrefixSetting, "cluster1")
+	folder, err := backups.ConfigureFolder(config)
+	require.NoError(t, err)
+	assert.NoError(t, folder.GetSubFolder(utility.BaseBackupPath).PutObject(
+		"base_000000010000000000000002"+utility.SentinelSuffix, strings.NewReader("{}")))
+
+	backupTimes, err := backups.ListBackups(folder)
+	require.NoError(t, err)
+	require.Len(t, backupTimes, 1)
+	assert.Equal(t, "base_000000010000000000000002", backupTimes[0].BackupName)
+}
@@ -0,0 +1,60 @@
+// Package postgres lets the Go programs built together with WAL-G read and push the PostgreSQL backups
+// of the storage without running the CLI. The signatures of its functions are kept stable.
+package postgres
+
+import (
+	"context"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// BackupSentinel is the metadata of the backup stored next to its tar members
+type BackupSentinel = postgres.BackupSentinelDto
+
+// GetBackupSentinel returns the sentinel of the backup, the name may be LATEST. The folder is the root folder
+// of the storage returned by backups.ConfigureFolder. backups.BackupNonExistenceError is returned
+// for the missing backup.
+func GetBackupSentinel(folder storage.Folder, backupName string) (BackupSentinel, error) {
+	backup, err := internal.GetBackupByName(backupName, utility.BaseBackupPath, folder)
+	if err != nil {
+		return BackupSentinel{}, err
+	}
+	pgBackup := postgres.ToPgBackup(backup)
+	return pgBackup.GetSentinel()
+}
+
+// BackupPushOptions are the options of backup-push, see the flags of the command
+type BackupPushOptions = postgres.BackupPushOptions
+
+// TarBallComposerType is the composer of the tar members, the regular one by default
+type TarBallComposerType = postgres.TarBallComposerType
+
+const (
+	RegularComposer    = postgres.RegularComposer
+	RatingComposer     = postgres.RatingComposer
+	CopyComposer       = postgres.CopyComposer
+	TablespaceComposer = postgres.TablespaceComposer
+)
+
+// BackupSelector chooses the base of the delta backup, the latest backup by default
+type BackupSelector = internal.BackupSelector
+
+// PushBackup makes the backup of the PostgreSQL cluster as backup-push does and returns its sentinel.
+// The storage and the uploading are configured by the usual settings. The running backup is stopped
+// once the context is canceled. The process is not exited on failure: ExitCodeOf tells the exit code
+// backup-push would exit with for the returned error.
+func PushBackup(ctx context.Context, options BackupPushOptions) (BackupSentinel, error) {
+	uploader, err := postgres.ConfigureWalUploader()
+	if err != nil {
+		return BackupSentinel{}, err
+	}
+	return postgres.RunBackupPush(ctx, postgres.NewBackupArguments(options), uploader)
+}
+
+// ExitCodeOf returns the exit code of the CLI command for the error returned by the functions of the package
+func ExitCodeOf(err error) int {
+	return internal.ExitCodeOf(err)
+}
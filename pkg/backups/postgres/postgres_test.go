@@ -0,0 +1,53 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	pgbackups "github.com/wal-g/wal-g/pkg/backups/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func init() {
+	internal.ConfigureSettings(internal.PG)
+	internal.InitConfig()
+	internal.Configure()
+}
+
+func TestGetBackupSentinel(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	const backupName = "base_000000010000000000000002"
+	finishLsn := postgres.LSN(0x4000100)
+	sentinel := postgres.BackupSentinelDto{BackupFinishLSN: &finishLsn, PgVersion: 150002, Hostname: "db1.example.com"}
+	assert.NoError(t, internal.UploadDto(folder.GetSubFolder(utility.BaseBackupPath), sentinel,
+		internal.SentinelNameFromBackup(backupName)))
+
+	for _, name := range []string{backupName, internal.LatestString} {
+		fetched, err := pgbackups.GetBackupSentinel(folder, name)
+		assert.NoError(t, err)
+		assert.Equal(t, finishLsn, *fetched.BackupFinishLSN)
+		assert.Equal(t, 150002, fetched.PgVersion)
+		assert.Equal(t, "db1.example.com", fetched.Hostname)
+	}
+
+	_, err := pgbackups.GetBackupSentinel(folder, "base_000000010000000000000004")
+	assert.IsType(t, internal.BackupNonExistenceError{}, err)
+}
+
+func TestPushBackup_ReturnsConfigurationError(t *testing.T) {
+	viper.Set("WALG_FILE_PREFIX", t.TempDir())
+	viper.Set(internal.KmsProviderSetting, "unknown")
+	defer func() {
+		viper.Set("WALG_FILE_PREFIX", nil)
+		viper.Set(internal.KmsProviderSetting, nil)
+	}()
+
+	_, err := pgbackups.PushBackup(context.Background(), pgbackups.BackupPushOptions{DataDirectory: t.TempDir()})
+	assert.IsType(t, internal.UnsupportedKmsProviderError{}, err)
+	assert.Equal(t, internal.ExitCodeUsage, pgbackups.ExitCodeOf(err))
+}
@@ -28,12 +28,20 @@ const (
 	BufferSizeSetting = "AZURE_BUFFER_SIZE"
 	MaxBuffersSetting = "AZURE_MAX_BUFFERS"
 	TryTimeoutSetting = "AZURE_TRY_TIMEOUT"
-	minBufferSize     = 1024
-	defaultBufferSize = 8 * 1024 * 1024
-	minBuffers        = 1
-	defaultBuffers    = 4
-	defaultTryTimeout = 5
-	defaultEnvName    = "AzurePublicCloud"
+	// RehydrateTierSetting is the tier (Hot or Cool) the archived blobs are rehydrated to on read
+	RehydrateTierSetting = "AZURE_REHYDRATE_TIER"
+	// RehydratePrioritySetting is the rehydration priority (Standard or High) of the archived blobs
+	RehydratePrioritySetting = "AZURE_REHYDRATE_PRIORITY"
+	// WaitRehydrateSetting makes the read of the archived blob wait for its rehydration instead of failing
+	WaitRehydrateSetting = "AZURE_WAIT_REHYDRATE"
+	// RehydrateTimeoutSetting limits the wait for the rehydration of the archived blobs, unlimited if not set
+	RehydrateTimeoutSetting = "AZURE_REHYDRATE_TIMEOUT"
+	minBufferSize           = 1024
+	defaultBufferSize       = 8 * 1024 * 1024
+	minBuffers              = 1
+	defaultBuffers          = 4
+	defaultTryTimeout       = 5
+	defaultEnvName          = "AzurePublicCloud"
 )
 
 type AzureAuthType string
@@ -51,6 +59,10 @@ var SettingList = []string{
 	EndpointSuffix,
 	BufferSizeSetting,
 	MaxBuffersSetting,
+	RehydrateTierSetting,
+	RehydratePrioritySetting,
+	WaitRehydrateSetting,
+	RehydrateTimeoutSetting,
 }
 
 func NewFolderError(err error, format string, args ...interface{}) storage.Error {
@@ -67,12 +79,14 @@ func NewFolder(
 	containerClient azblob.ContainerClient,
 	credential *azblob.SharedKeyCredential,
 	timeout time.Duration,
+	rehydrateOptions rehydrateOptions,
 	path string) *Folder {
 	return &Folder{
 		uploadStreamOptions,
 		containerClient,
 		credential,
 		timeout,
+		rehydrateOptions,
 		path,
 	}
 }
@@ -207,22 +221,32 @@ func ConfigureFolder(prefix string, settings map[string]string) (storage.Folder,
 	if err != nil {
 		return nil, NewFolderError(err, "Unable to create service client")
 	}
+	rehydrateOptions, err := getRehydrateOptions(settings)
+	if err != nil {
+		return nil, NewFolderError(err, "Invalid azure rehydration settings")
+	}
 	path = storage.AddDelimiterToPath(path)
-	return NewFolder(getUploadStreamOptions(settings), *containerClient, credential, timeout, path), nil
+	return NewFolder(getUploadStreamOptions(settings), *containerClient, credential, timeout, rehydrateOptions, path), nil
 }
 
 type Folder struct {
 	uploadStreamOptions azblob.UploadStreamOptions
-	containerClient                azblob.ContainerClient
-	credential                     *azblob.SharedKeyCredential
-	timeout                        time.Duration
-	path                           string
+	containerClient     azblob.ContainerClient
+	credential          *azblob.SharedKeyCredential
+	timeout             time.Duration
+	rehydrateOptions    rehydrateOptions
+	path                string
 }
 
 func (folder *Folder) GetPath() string {
 	return folder.path
 }
 
+// GetURL returns the URL of the folder in the container
+func (folder *Folder) GetURL() string {
+	return folder.containerClient.URL() + "/" + folder.path
+}
+
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
 	path := storage.JoinPath(folder.path, objectRelativePath)
 	ctx := context.Background()
@@ -264,6 +288,7 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 				folder.containerClient,
 				folder.credential,
 				folder.timeout,
+				folder.rehydrateOptions,
 				subFolderPath))
 		}
 
@@ -281,6 +306,7 @@ func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder
 		folder.containerClient,
 		folder.credential,
 		folder.timeout,
+		folder.rehydrateOptions,
 		storage.AddDelimiterToPath(storage.JoinPath(folder.path, subFolderRelativePath)))
 }
 
@@ -292,10 +318,17 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 	}
 
 	get, err := blobClient.Download(context.Background(), nil)
+	var storageError *azblob.StorageError
+	if err != nil && errors.As(err, &storageError) && (storageError.ErrorCode == azblob.StorageErrorCodeBlobArchived ||
+		storageError.ErrorCode == azblob.StorageErrorCodeBlobBeingRehydrated) {
+		err = folder.rehydrate(&blobClient.BlobClient, path)
+		if err != nil {
+			return nil, err
+		}
+		get, err = blobClient.Download(context.Background(), nil)
+	}
 	if err != nil {
-		var storageError *azblob.StorageError
-		errors.As(err, &storageError)
-		if storageError.ErrorCode == azblob.StorageErrorCodeBlobNotFound {
+		if errors.As(err, &storageError) && storageError.ErrorCode == azblob.StorageErrorCodeBlobNotFound {
 			return nil, storage.NewObjectNotFoundError(path)
 		}
 		return nil, NewFolderError(err, "Unable to download blob %s.", path)
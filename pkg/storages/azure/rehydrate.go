@@ -0,0 +1,241 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	defaultRehydratePollInterval = time.Minute
+	rehydratePendingStatusPrefix = "rehydrate-pending"
+)
+
+type BlobArchivedError struct {
+	error
+}
+
+// newBlobArchivedError describes the archived blobs, e.g. "blob basebackups_005/base_000000010000000000000002/metadata.json"
+func newBlobArchivedError(blobs string, options rehydrateOptions) BlobArchivedError {
+	return BlobArchivedError{errors.Errorf("%s in the %s tier can't be read until rehydrated, "+
+		"the rehydration to the %s tier with %s priority is started and takes %s, "+
+		"retry later or set %s (--wait-rehydrate) to wait for it",
+		blobs, azblob.AccessTierArchive, options.tier, options.priority, estimateRehydrationTime(options.priority),
+		WaitRehydrateSetting)}
+}
+
+func (err BlobArchivedError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+type RehydrateTimeoutError struct {
+	error
+}
+
+func newRehydrateTimeoutError(blobs string, timeout time.Duration) RehydrateTimeoutError {
+	return RehydrateTimeoutError{errors.Errorf("%s not rehydrated in %s (%s)", blobs, timeout, RehydrateTimeoutSetting)}
+}
+
+func (err RehydrateTimeoutError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// rehydrateOptions describes how the blobs in the Archive tier are rehydrated on read
+type rehydrateOptions struct {
+	tier     azblob.AccessTier
+	priority azblob.RehydratePriority
+	wait     bool
+	// timeout limits the wait for the rehydration, zero means no limit
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+func getRehydrateOptions(settings map[string]string) (rehydrateOptions, error) {
+	options := rehydrateOptions{
+		tier:         azblob.AccessTierHot,
+		priority:     azblob.RehydratePriorityStandard,
+		pollInterval: defaultRehydratePollInterval,
+	}
+	if tier, ok := settings[RehydrateTierSetting]; ok {
+		switch azblob.AccessTier(tier) {
+		case azblob.AccessTierHot, azblob.AccessTierCool:
+			options.tier = azblob.AccessTier(tier)
+		default:
+			return rehydrateOptions{}, errors.Errorf("invalid %s '%s', expected %s or %s",
+				RehydrateTierSetting, tier, azblob.AccessTierHot, azblob.AccessTierCool)
+		}
+	}
+	if priority, ok := settings[RehydratePrioritySetting]; ok {
+		switch azblob.RehydratePriority(priority) {
+		case azblob.RehydratePriorityStandard, azblob.RehydratePriorityHigh:
+			options.priority = azblob.RehydratePriority(priority)
+		default:
+			return rehydrateOptions{}, errors.Errorf("invalid %s '%s', expected %s or %s",
+				RehydratePrioritySetting, priority, azblob.RehydratePriorityStandard, azblob.RehydratePriorityHigh)
+		}
+	}
+	if wait, ok := settings[WaitRehydrateSetting]; ok {
+		var err error
+		options.wait, err = strconv.ParseBool(wait)
+		if err != nil {
+			return rehydrateOptions{}, errors.Wrapf(err, "invalid %s", WaitRehydrateSetting)
+		}
+	}
+	if timeout, ok := settings[RehydrateTimeoutSetting]; ok {
+		var err error
+		options.timeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return rehydrateOptions{}, errors.Wrapf(err, "invalid %s", RehydrateTimeoutSetting)
+		}
+	}
+	return options, nil
+}
+
+// estimateRehydrationTime returns the rehydration time documented by Azure for the priority
+func estimateRehydrationTime(priority azblob.RehydratePriority) string {
+	if priority == azblob.RehydratePriorityHigh {
+		return "less than 1 hour for the blobs smaller than 10 GB"
+	}
+	return "up to 15 hours"
+}
+
+// rehydrate starts the rehydration of the archived blob unless it is already started,
+// then either waits until the blob leaves the Archive tier or fails with BlobArchivedError
+func (folder *Folder) rehydrate(blobClient *azblob.BlobClient, path string) error {
+	ctx := context.Background()
+	properties, err := blobClient.GetProperties(ctx, nil)
+	if err != nil {
+		return NewFolderError(err, "Unable to get the properties of blob %s", path)
+	}
+	if !isArchived(properties.AccessTier, properties.ArchiveStatus) {
+		return nil
+	}
+	if !isRehydrationPending(properties.ArchiveStatus) {
+		err = folder.startRehydration(ctx, blobClient, path)
+		if err != nil {
+			return err
+		}
+	}
+	blobDescription := "blob " + path
+	if !folder.rehydrateOptions.wait {
+		return newBlobArchivedError(blobDescription, folder.rehydrateOptions)
+	}
+
+	return folder.waitRehydration(blobDescription, func() (bool, error) {
+		properties, err = blobClient.GetProperties(ctx, nil)
+		if err != nil {
+			return false, NewFolderError(err, "Unable to get the properties of blob %s", path)
+		}
+		return !isArchived(properties.AccessTier, properties.ArchiveStatus), nil
+	})
+}
+
+// RehydrateSubFolders starts the rehydration of all the archived blobs under the subfolders at once,
+// so the rehydrations run in parallel, then waits for all of them together or fails with BlobArchivedError.
+// The archived blobs are found by listing, so the blobs which are not archived cost no request.
+func (folder *Folder) RehydrateSubFolders(subFolderRelativePaths []string) error {
+	ctx := context.Background()
+	archivedCount, err := folder.rehydrateArchivedBlobs(ctx, subFolderRelativePaths)
+	if err != nil || archivedCount == 0 {
+		return err
+	}
+	blobsDescription := fmt.Sprintf("%d blobs under %s", archivedCount, strings.Join(subFolderRelativePaths, ", "))
+	if !folder.rehydrateOptions.wait {
+		return newBlobArchivedError(blobsDescription, folder.rehydrateOptions)
+	}
+
+	return folder.waitRehydration(blobsDescription, func() (bool, error) {
+		archivedCount, err = folder.rehydrateArchivedBlobs(ctx, subFolderRelativePaths)
+		if err != nil {
+			return false, err
+		}
+		tracelog.InfoLogger.Printf("%d blobs are still in the %s tier\n", archivedCount, azblob.AccessTierArchive)
+		return archivedCount == 0, nil
+	})
+}
+
+// rehydrateArchivedBlobs lists the blobs under the subfolders and starts the rehydration of the archived ones
+// unless it is already started, returns the number of the archived blobs
+func (folder *Folder) rehydrateArchivedBlobs(ctx context.Context, subFolderRelativePaths []string) (int, error) {
+	archivedCount := 0
+	for _, subFolder := range subFolderRelativePaths {
+		prefix := storage.AddDelimiterToPath(storage.JoinPath(folder.path, subFolder))
+		blobPager := folder.containerClient.ListBlobsFlat(&azblob.ContainerListBlobsFlatOptions{Prefix: &prefix})
+		for blobPager.NextPage(ctx) {
+			for _, blob := range blobPager.PageResponse().Segment.BlobItems {
+				var archiveStatus *string
+				if blob.Properties.ArchiveStatus != nil {
+					archiveStatus = (*string)(blob.Properties.ArchiveStatus)
+				}
+				if !isArchived((*string)(blob.Properties.AccessTier), archiveStatus) {
+					continue
+				}
+				archivedCount++
+				if isRehydrationPending(archiveStatus) {
+					continue
+				}
+				blobClient, err := folder.containerClient.NewBlobClient(*blob.Name)
+				if err != nil {
+					return 0, NewFolderError(err, "Unable to init Azure Blob client")
+				}
+				err = folder.startRehydration(ctx, blobClient, *blob.Name)
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+		if err := blobPager.Err(); err != nil {
+			return 0, NewFolderError(err, "Unable to iterate %v", prefix)
+		}
+	}
+	return archivedCount, nil
+}
+
+func (folder *Folder) startRehydration(ctx context.Context, blobClient *azblob.BlobClient, path string) error {
+	_, err := blobClient.SetTier(ctx, folder.rehydrateOptions.tier,
+		&azblob.BlobSetTierOptions{RehydratePriority: folder.rehydrateOptions.priority.ToPtr()})
+	if err != nil {
+		return NewFolderError(err, "Unable to start the rehydration of blob %s", path)
+	}
+	tracelog.InfoLogger.Printf("Started the rehydration of blob %s to the %s tier with %s priority\n",
+		path, folder.rehydrateOptions.tier, folder.rehydrateOptions.priority)
+	return nil
+}
+
+// waitRehydration polls until the blobs are rehydrated or the AZURE_REHYDRATE_TIMEOUT is exceeded
+func (folder *Folder) waitRehydration(blobs string, isRehydrated func() (bool, error)) error {
+	start := time.Now()
+	for {
+		tracelog.InfoLogger.Printf("Waiting for the rehydration of %s, it takes %s\n",
+			blobs, estimateRehydrationTime(folder.rehydrateOptions.priority))
+		time.Sleep(folder.rehydrateOptions.pollInterval)
+		rehydrated, err := isRehydrated()
+		if err != nil {
+			return err
+		}
+		if rehydrated {
+			tracelog.InfoLogger.Printf("%s rehydrated\n", blobs)
+			return nil
+		}
+		if folder.rehydrateOptions.timeout > 0 && time.Since(start) > folder.rehydrateOptions.timeout {
+			return newRehydrateTimeoutError(blobs, folder.rehydrateOptions.timeout)
+		}
+	}
+}
+
+// isArchived tells if the blob can't be read yet: it is in the Archive tier or its rehydration is not finished
+func isArchived(accessTier *string, archiveStatus *string) bool {
+	return accessTier != nil && azblob.AccessTier(*accessTier) == azblob.AccessTierArchive ||
+		isRehydrationPending(archiveStatus)
+}
+
+func isRehydrationPending(archiveStatus *string) bool {
+	return archiveStatus != nil && strings.HasPrefix(*archiveStatus, rehydratePendingStatusPrefix)
+}
@@ -0,0 +1,223 @@
+package azure
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// archivedBlob is the blob in the Archive tier, its rehydration is finished after pendingPolls
+// properties requests or listings once it is started
+type archivedBlob struct {
+	pendingPolls  int
+	tier          string
+	archiveStatus string
+}
+
+func (blob *archivedBlob) poll() {
+	if blob.archiveStatus == "" {
+		return
+	}
+	if blob.pendingPolls == 0 {
+		blob.tier = string(azblob.AccessTierHot)
+		blob.archiveStatus = ""
+	} else {
+		blob.pendingPolls--
+	}
+}
+
+// archivedBlobServer emulates the Blob service holding the blobs of the container, the blob paths
+// are relative to the container
+type archivedBlobServer struct {
+	mutex             sync.Mutex
+	blobs             map[string]*archivedBlob
+	setTierCalls      int
+	rehydratePriority string
+}
+
+func newArchivedBlobServer(pendingPolls int, paths ...string) *archivedBlobServer {
+	server := &archivedBlobServer{blobs: map[string]*archivedBlob{}}
+	for _, path := range paths {
+		server.blobs[path] = &archivedBlob{pendingPolls: pendingPolls, tier: string(azblob.AccessTierArchive)}
+	}
+	return server
+}
+
+func (server *archivedBlobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if r.URL.Query().Get("comp") == "list" {
+		server.listBlobs(w, r.URL.Query().Get("prefix"))
+		return
+	}
+	blob, ok := server.blobs[strings.TrimPrefix(r.URL.Path, "/container/")]
+	if !ok {
+		w.Header().Set("x-ms-error-code", string(azblob.StorageErrorCodeBlobNotFound))
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPut && r.URL.Query().Get("comp") == "tier":
+		server.setTierCalls++
+		server.rehydratePriority = r.Header.Get("x-ms-rehydrate-priority")
+		blob.archiveStatus = "rehydrate-pending-to-" + r.Header.Get("x-ms-access-tier")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodHead:
+		blob.poll()
+		w.Header().Set("x-ms-access-tier", blob.tier)
+		if blob.archiveStatus != "" {
+			w.Header().Set("x-ms-archive-status", blob.archiveStatus)
+		}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet:
+		if blob.tier == string(azblob.AccessTierArchive) {
+			w.Header().Set("x-ms-error-code", string(azblob.StorageErrorCodeBlobArchived))
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (server *archivedBlobServer) listBlobs(w http.ResponseWriter, prefix string) {
+	paths := make([]string, 0, len(server.blobs))
+	for path := range server.blobs {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	var blobItems strings.Builder
+	for _, path := range paths {
+		blob := server.blobs[path]
+		blob.poll()
+		archiveStatus := ""
+		if blob.archiveStatus != "" {
+			archiveStatus = "<ArchiveStatus>" + blob.archiveStatus + "</ArchiveStatus>"
+		}
+		fmt.Fprintf(&blobItems, "<Blob><Name>%s</Name><Properties>"+
+			"<Last-Modified>Mon, 01 Jan 2024 00:00:00 GMT</Last-Modified><Content-Length>7</Content-Length>"+
+			"<AccessTier>%s</AccessTier>%s</Properties></Blob>", path, blob.tier, archiveStatus)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="container">`+
+		"<Prefix>%s</Prefix><Blobs>%s</Blobs><NextMarker/></EnumerationResults>", prefix, blobItems.String())
+}
+
+func newArchivedBlobFolder(t *testing.T, server *archivedBlobServer, wait bool) *Folder {
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	containerClient, err := azblob.NewContainerClientWithNoCredential(httpServer.URL+"/container", nil)
+	assert.NoError(t, err)
+	options := rehydrateOptions{
+		tier:         azblob.AccessTierHot,
+		priority:     azblob.RehydratePriorityHigh,
+		wait:         wait,
+		pollInterval: time.Millisecond,
+	}
+	return NewFolder(azblob.UploadStreamOptions{}, *containerClient, nil, time.Minute, options, "folder/")
+}
+
+func TestReadObject_ArchivedBlobStartsRehydration(t *testing.T) {
+	server := newArchivedBlobServer(100, "folder/blob")
+	folder := newArchivedBlobFolder(t, server, false)
+
+	_, err := folder.ReadObject("blob")
+	assert.IsType(t, BlobArchivedError{}, err)
+	assert.Equal(t, 1, server.setTierCalls)
+	assert.Equal(t, string(azblob.RehydratePriorityHigh), server.rehydratePriority)
+
+	// the pending rehydration is not started again
+	_, err = folder.ReadObject("blob")
+	assert.IsType(t, BlobArchivedError{}, err)
+	assert.Equal(t, 1, server.setTierCalls)
+}
+
+func TestReadObject_WaitsForRehydration(t *testing.T) {
+	server := newArchivedBlobServer(3, "folder/blob")
+	folder := newArchivedBlobFolder(t, server, true)
+
+	reader, err := folder.ReadObject("blob")
+	assert.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	assert.Equal(t, 1, server.setTierCalls)
+}
+
+func TestRehydrateSubFolders_StartsAllRehydrations(t *testing.T) {
+	server := newArchivedBlobServer(100, "folder/base_1/tar_partitions/part_1.tar.lz4",
+		"folder/base_1/tar_partitions/part_2.tar.lz4", "folder/base_2/metadata.json", "folder/base_3/metadata.json")
+	server.blobs["folder/base_1/metadata.json"] = &archivedBlob{tier: string(azblob.AccessTierHot)}
+	folder := newArchivedBlobFolder(t, server, false)
+
+	err := storage.RehydrateSubFolders(folder, []string{"base_1", "base_2"})
+	assert.IsType(t, BlobArchivedError{}, err)
+	assert.Contains(t, err.Error(), "3 blobs")
+	assert.Equal(t, 3, server.setTierCalls)
+
+	// the pending rehydrations are not started again
+	err = storage.RehydrateSubFolders(folder, []string{"base_1", "base_2"})
+	assert.IsType(t, BlobArchivedError{}, err)
+	assert.Equal(t, 3, server.setTierCalls)
+}
+
+func TestRehydrateSubFolders_WaitsForAllBlobs(t *testing.T) {
+	server := newArchivedBlobServer(3, "folder/base_1/tar_partitions/part_1.tar.lz4",
+		"folder/base_1/tar_partitions/part_2.tar.lz4")
+	folder := newArchivedBlobFolder(t, server, true)
+
+	assert.NoError(t, folder.RehydrateSubFolders([]string{"base_1"}))
+	assert.Equal(t, 2, server.setTierCalls)
+	for _, blob := range server.blobs {
+		assert.Equal(t, string(azblob.AccessTierHot), blob.tier)
+	}
+}
+
+func TestRehydrateSubFolders_Timeout(t *testing.T) {
+	server := newArchivedBlobServer(math.MaxInt32, "folder/base_1/tar_partitions/part_1.tar.lz4")
+	folder := newArchivedBlobFolder(t, server, true)
+	folder.rehydrateOptions.timeout = 10 * time.Millisecond
+
+	err := folder.RehydrateSubFolders([]string{"base_1"})
+	assert.IsType(t, RehydrateTimeoutError{}, err)
+}
+
+func TestGetRehydrateOptions(t *testing.T) {
+	options, err := getRehydrateOptions(map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, azblob.AccessTierHot, options.tier)
+	assert.Equal(t, azblob.RehydratePriorityStandard, options.priority)
+	assert.False(t, options.wait)
+
+	options, err = getRehydrateOptions(map[string]string{
+		RehydrateTierSetting:     "Cool",
+		RehydratePrioritySetting: "High",
+		WaitRehydrateSetting:     "true",
+		RehydrateTimeoutSetting:  "12h",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, azblob.AccessTierCool, options.tier)
+	assert.Equal(t, azblob.RehydratePriorityHigh, options.priority)
+	assert.True(t, options.wait)
+	assert.Equal(t, 12*time.Hour, options.timeout)
+
+	_, err = getRehydrateOptions(map[string]string{RehydrateTierSetting: "Archive"})
+	assert.Error(t, err)
+	_, err = getRehydrateOptions(map[string]string{RehydratePrioritySetting: "Urgent"})
+	assert.Error(t, err)
+}
@@ -42,6 +42,11 @@ func (folder *Folder) GetPath() string {
 	return folder.subpath
 }
 
+// GetURL returns the URL of the folder with the root path of the storage
+func (folder *Folder) GetURL() string {
+	return "file://" + path.Join(folder.rootPath, folder.subpath) + "/"
+}
+
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
 	files, err := ioutil.ReadDir(path.Join(folder.rootPath, folder.subpath))
 	if err != nil {
@@ -105,6 +110,23 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 	return file, nil
 }
 
+func (folder *Folder) ReadObjectFrom(objectRelativePath string, offset int64) (io.ReadCloser, int64, error) {
+	file, err := folder.ReadObject(objectRelativePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	osFile := file.(*os.File)
+	fileInfo, err := osFile.Stat()
+	if err == nil {
+		_, err = osFile.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		_ = osFile.Close()
+		return nil, 0, NewError(err, "Unable to read object %v from %d", osFile.Name(), offset)
+	}
+	return osFile, fileInfo.Size(), nil
+}
+
 func (folder *Folder) PutObject(name string, content io.Reader) error {
 	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.subpath)
 	filePath := folder.GetFilePath(name)
@@ -17,6 +17,7 @@ import (
 
 	gcs "cloud.google.com/go/storage"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
 const (
@@ -25,6 +26,8 @@ const (
 	EncryptionKey   = "GCS_ENCRYPTION_KEY"
 	MaxChunkSize    = "GCS_MAX_CHUNK_SIZE"
 	MaxRetries      = "GCS_MAX_RETRIES"
+	Endpoint        = "GCS_ENDPOINT"
+	BillingProject  = "GCS_BILLING_PROJECT"
 
 	defaultContextTimeout = 60 * 60 // 1 hour
 	maxRetryDelay         = 5 * time.Minute
@@ -44,6 +47,8 @@ var (
 		EncryptionKey,
 		MaxChunkSize,
 		MaxRetries,
+		Endpoint,
+		BillingProject,
 	}
 )
 
@@ -83,7 +88,7 @@ func ConfigureFolder(prefix string, settings map[string]string) (storage.Folder,
 
 	ctx := context.Background()
 
-	client, err := gcs.NewClient(ctx)
+	client, err := gcs.NewClient(ctx, getClientOptions(settings)...)
 	if err != nil {
 		return nil, NewError(err, "Unable to create client")
 	}
@@ -103,7 +108,7 @@ func ConfigureFolder(prefix string, settings map[string]string) (storage.Folder,
 		return nil, NewError(err, "Unable to parse prefix %v", prefix)
 	}
 
-	bucket := client.Bucket(bucketName)
+	bucket := configureBucket(client, bucketName, settings)
 
 	path = storage.AddDelimiterToPath(path)
 
@@ -137,6 +142,26 @@ func ConfigureFolder(prefix string, settings map[string]string) (storage.Folder,
 	return NewFolder(bucket, path, contextTimeout, normalizePrefix, encryptionKey, uploaderOptions), nil
 }
 
+// getClientOptions returns the options of the GCS client, the custom endpoint is used
+// by both the JSON API requests, including the resumable uploads, and the object reads
+func getClientOptions(settings map[string]string) []option.ClientOption {
+	clientOptions := []option.ClientOption{}
+	if endpoint, ok := settings[Endpoint]; ok {
+		clientOptions = append(clientOptions, option.WithEndpoint(endpoint))
+	}
+	return clientOptions
+}
+
+// configureBucket returns the bucket handle, the handles of its objects inherit the billing project
+// of the requester pays bucket, so it is attached to all the object reads and writes
+func configureBucket(client *gcs.Client, bucketName string, settings map[string]string) *gcs.BucketHandle {
+	bucket := client.Bucket(bucketName)
+	if billingProject, ok := settings[BillingProject]; ok {
+		bucket = bucket.UserProject(billingProject)
+	}
+	return bucket
+}
+
 func getUploaderOptions(settings map[string]string) ([]UploaderOption, error) {
 	uploaderOptions := []UploaderOption{}
 
@@ -277,7 +302,35 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 	return io.NopCloser(reader), err
 }
 
+func (folder *Folder) ReadObjectFrom(objectRelativePath string, offset int64) (io.ReadCloser, int64, error) {
+	path := folder.joinPath(folder.path, objectRelativePath)
+	object := folder.BuildObjectHandle(path)
+	reader, err := object.NewRangeReader(context.Background(), offset, -1)
+	if err == gcs.ErrObjectNotExist {
+		return nil, 0, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "failed to read object: '%s' from %d", path, offset)
+	}
+	return reader, reader.Attrs.Size, nil
+}
+
 func (folder *Folder) PutObject(name string, content io.Reader) error {
+	return folder.putObject(name, content, folder.uploaderOptions)
+}
+
+// PutObjectWithStorageClass puts the object into the storage class which overrides the default one of the bucket,
+// the temporary chunks the object is composed of are stored in the default storage class
+func (folder *Folder) PutObjectWithStorageClass(name string, content io.Reader, storageClass string) error {
+	objectUploaderOptions := make([]UploaderOption, 0, len(folder.uploaderOptions)+1)
+	objectUploaderOptions = append(objectUploaderOptions, folder.uploaderOptions...)
+	objectUploaderOptions = append(objectUploaderOptions, func(uploader *Uploader) { uploader.storageClass = storageClass })
+	return folder.putObject(name, content, objectUploaderOptions)
+}
+
+// putObject uploads the content as the chunks and composes the object from them,
+// objectUploaderOptions configure the upload of the composed object
+func (folder *Folder) putObject(name string, content io.Reader, objectUploaderOptions []UploaderOption) error {
 	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.path)
 	object := folder.BuildObjectHandle(folder.joinPath(folder.path, name))
 
@@ -339,7 +392,7 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 
 	tracelog.DebugLogger.Printf("Compose file %v from chunks\n", object.ObjectName())
 
-	if err := composeChunks(ctx, NewUploader(object, folder.uploaderOptions...), tmpChunks); err != nil {
+	if err := composeChunks(ctx, NewUploader(object, objectUploaderOptions...), tmpChunks); err != nil {
 		return NewError(err, "Failed to compose temporary chunks into an object")
 	}
 
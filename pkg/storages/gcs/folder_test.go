@@ -1,12 +1,22 @@
 package gcs
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	gcs "cloud.google.com/go/storage"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
 
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 
@@ -189,3 +199,92 @@ func TestInvalidUploaderOptions(t *testing.T) {
 		assert.EqualError(t, err, tc.errString)
 	}
 }
+
+// newFakeGCSServer serves the object reads and writes made by the folder,
+// recording the billing project of each request
+func newFakeGCSServer(t *testing.T, billingProjects *[]string) *httptest.Server {
+	var mutex sync.Mutex
+	objects := map[string][]byte{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		billingProject := r.URL.Query().Get("userProject")
+		if billingProject == "" {
+			billingProject = r.Header.Get("X-Goog-User-Project")
+		}
+		*billingProjects = append(*billingProjects, r.Method+" "+billingProject)
+
+		switch {
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/bucket/o"):
+			_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			require.NoError(t, err)
+			reader := multipart.NewReader(r.Body, params["boundary"])
+			metadataPart, err := reader.NextPart()
+			require.NoError(t, err)
+			var metadata map[string]interface{}
+			require.NoError(t, json.NewDecoder(metadataPart).Decode(&metadata))
+			dataPart, err := reader.NextPart()
+			require.NoError(t, err)
+			data, err := io.ReadAll(dataPart)
+			require.NoError(t, err)
+			name := metadata["name"].(string)
+			objects[name] = data
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"bucket": "bucket", "name": name})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/compose"):
+			var request struct {
+				SourceObjects []struct{ Name string } `json:"sourceObjects"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/"), "/compose")
+			var data []byte
+			for _, source := range request.SourceObjects {
+				data = append(data, objects[source.Name]...)
+			}
+			objects[name] = data
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"bucket": "bucket", "name": name})
+		case r.Method == http.MethodDelete:
+			delete(objects, strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/"))
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/bucket/"):
+			data, ok := objects[strings.TrimPrefix(r.URL.Path, "/bucket/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestEndpointAndBillingProject(t *testing.T) {
+	var billingProjects []string
+	server := newFakeGCSServer(t, &billingProjects)
+	settings := map[string]string{
+		Endpoint:       server.URL + "/storage/v1/",
+		BillingProject: "billing-project",
+	}
+
+	client, err := gcs.NewClient(context.Background(),
+		append(getClientOptions(settings), option.WithHTTPClient(server.Client()))...)
+	require.NoError(t, err)
+	folder := NewFolder(configureBucket(client, "bucket", settings), "walg/", defaultContextTimeout, true, nil, nil)
+
+	require.NoError(t, folder.PutObject("object", strings.NewReader("content")))
+	reader, err := folder.ReadObject("object")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+
+	assert.Equal(t, []string{
+		"POST billing-project",   // the chunk upload
+		"POST billing-project",   // the compose of the chunks
+		"DELETE billing-project", // the cleanup of the chunks
+		"GET billing-project",    // the object read
+	}, billingProjects)
+}
@@ -27,6 +27,8 @@ type Uploader struct {
 	baseRetryDelay   time.Duration
 	maxRetryDelay    time.Duration
 	maxUploadRetries int
+	// storageClass of the composed object, the default storage class of the bucket is used if empty
+	storageClass string
 }
 
 type UploaderOption func(*Uploader)
@@ -103,7 +105,9 @@ func (u *Uploader) ComposeObject(ctx context.Context, tmpChunks []*storage.Objec
 
 func (u *Uploader) getComposeFunc(tmpChunks []*storage.ObjectHandle) func(context.Context) error {
 	return func(ctx context.Context) error {
-		_, err := u.objHandle.ComposerFrom(tmpChunks...).Run(ctx)
+		composer := u.objHandle.ComposerFrom(tmpChunks...)
+		composer.StorageClass = u.storageClass
+		_, err := composer.Run(ctx)
 		// Since compose sources must not have an encryption key, clean up it.
 		if err == nil {
 			*u.objHandle = *u.objHandle.Key(nil)
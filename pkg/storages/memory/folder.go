@@ -77,6 +77,39 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 	return io.NopCloser(&object.Data), nil
 }
 
+// ReadObjectWithMetadata reads the object and returns the metadata it is put with, the keys are lower case
+func (folder *Folder) ReadObjectWithMetadata(objectRelativePath string) (io.ReadCloser, map[string]string, error) {
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	object, exists := folder.Storage.Load(objectAbsPath)
+	if !exists {
+		return nil, nil, storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	return io.NopCloser(&object.Data), object.Metadata, nil
+}
+
+// ReadObjectMetadata returns the metadata the object is put with
+func (folder *Folder) ReadObjectMetadata(objectRelativePath string) (map[string]string, error) {
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	object, exists := folder.Storage.Load(objectAbsPath)
+	if !exists {
+		return nil, storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	return object.Metadata, nil
+}
+
+func (folder *Folder) ReadObjectFrom(objectRelativePath string, offset int64) (io.ReadCloser, int64, error) {
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	object, exists := folder.Storage.Load(objectAbsPath)
+	if !exists {
+		return nil, 0, storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	data := object.Data.Bytes()
+	if offset > int64(len(data)) {
+		return nil, 0, errors.Errorf("offset %d is beyond the size %d of '%s'", offset, len(data), objectAbsPath)
+	}
+	return io.NopCloser(bytes.NewReader(data[offset:])), int64(len(data)), nil
+}
+
 func (folder *Folder) PutObject(name string, content io.Reader) error {
 	data, err := io.ReadAll(content)
 	objectPath := path.Join(folder.path, name)
@@ -87,6 +120,22 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 	return nil
 }
 
+// PutObjectWithMetadata puts the object with the metadata, the memory storage has no storage classes
+func (folder *Folder) PutObjectWithMetadata(name string, content io.Reader, storageClass string,
+	metadata map[string]string) error {
+	data, err := io.ReadAll(content)
+	objectPath := path.Join(folder.path, name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to put '%s' in memory storage", objectPath)
+	}
+	lowerCaseMetadata := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		lowerCaseMetadata[strings.ToLower(key)] = value
+	}
+	folder.Storage.StoreWithMetadata(objectPath, *bytes.NewBuffer(data), lowerCaseMetadata)
+	return nil
+}
+
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 	if exists, err := folder.Exists(srcPath); !exists {
 		if err == nil {
@@ -19,10 +19,12 @@ type TimeStampedData struct {
 	Data      bytes.Buffer
 	Timestamp time.Time
 	Size      int
+	// Metadata is the user metadata the object is stored with
+	Metadata map[string]string
 }
 
 func TimeStampData(data bytes.Buffer) TimeStampedData {
-	return TimeStampedData{data, CeilTimeUpToMicroseconds(time.Now()), data.Len()}
+	return TimeStampedData{Data: data, Timestamp: CeilTimeUpToMicroseconds(time.Now()), Size: data.Len()}
 }
 
 // Storage is supposed to be used for tests. It doesn't guarantee data safety!
@@ -46,6 +48,13 @@ func (storage *Storage) Store(key string, value bytes.Buffer) {
 	storage.underlying.Store(key, TimeStampData(value))
 }
 
+// StoreWithMetadata stores the value along with the user metadata
+func (storage *Storage) StoreWithMetadata(key string, value bytes.Buffer, metadata map[string]string) {
+	timeStampedData := TimeStampData(value)
+	timeStampedData.Metadata = metadata
+	storage.underlying.Store(key, timeStampedData)
+}
+
 func (storage *Storage) Delete(key string) {
 	storage.underlying.Delete(key)
 }
@@ -1,6 +1,7 @@
 package s3
 
 import (
+	"fmt"
 	"io"
 	"path"
 	"strconv"
@@ -33,6 +34,7 @@ const (
 	SseKmsIdSetting          = "S3_SSE_KMS_ID"
 	StorageClassSetting      = "S3_STORAGE_CLASS"
 	UploadConcurrencySetting = "UPLOAD_CONCURRENCY"
+	UploadPartSizeSetting    = "UPLOAD_PART_SIZE"
 	s3CertFile               = "S3_CA_CERT_FILE"
 	MaxPartSize              = "S3_MAX_PART_SIZE"
 	EndpointSourceSetting    = "S3_ENDPOINT_SOURCE"
@@ -41,6 +43,8 @@ const (
 	UseListObjectsV1         = "S3_USE_LIST_OBJECTS_V1"
 	RangeBatchEnabled        = "S3_RANGE_BATCH_ENABLED"
 	RangeQueriesMaxRetries   = "S3_RANGE_MAX_RETRIES"
+	ObjectLockDaysSetting    = "OBJECT_LOCK_DAYS"
+	ObjectLockModeSetting    = "S3_OBJECT_LOCK_MODE"
 	// MaxRetriesSetting limits retries during interaction with S3
 	MaxRetriesSetting = "S3_MAX_RETRIES"
 
@@ -71,6 +75,7 @@ var (
 		SseKmsIdSetting,
 		StorageClassSetting,
 		UploadConcurrencySetting,
+		UploadPartSizeSetting,
 		s3CertFile,
 		MaxPartSize,
 		UseListObjectsV1,
@@ -78,6 +83,8 @@ var (
 		RangeBatchEnabled,
 		RangeQueriesMaxRetries,
 		MaxRetriesSetting,
+		ObjectLockDaysSetting,
+		ObjectLockModeSetting,
 	}
 )
 
@@ -156,7 +163,44 @@ func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
 }
 
 func (folder *Folder) PutObject(name string, content io.Reader) error {
-	return folder.uploader.upload(*folder.Bucket, folder.Path+name, content)
+	return folder.uploader.upload(*folder.Bucket, folder.Path+name, content, folder.uploader.StorageClass, nil)
+}
+
+// GetObjectLockRetention returns the retention period of the object lock set on the uploaded objects
+func (folder *Folder) GetObjectLockRetention() time.Duration {
+	return folder.uploader.objectLockRetention
+}
+
+// GetObjectRetainUntil returns the retention of the current version of the object with the HEAD request,
+// it is set either by the upload or by the default retention of the bucket
+func (folder *Folder) GetObjectRetainUntil(objectRelativePath string) (time.Time, error) {
+	objectPath := folder.Path + objectRelativePath
+	object, err := folder.S3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: folder.Bucket,
+		Key:    aws.String(objectPath),
+	})
+	if err != nil {
+		if isAwsNotExist(err) {
+			return time.Time{}, storage.NewObjectNotFoundError(objectPath)
+		}
+		return time.Time{}, errors.Wrapf(err, "failed to read the retention of object: '%s' from S3", objectPath)
+	}
+	return aws.TimeValue(object.ObjectLockRetainUntilDate), nil
+}
+
+// PutObjectWithStorageClass puts the object into the storage class which overrides S3_STORAGE_CLASS
+func (folder *Folder) PutObjectWithStorageClass(name string, content io.Reader, storageClass string) error {
+	return folder.uploader.upload(*folder.Bucket, folder.Path+name, content, storageClass, nil)
+}
+
+// PutObjectWithMetadata puts the object with the user metadata into the storage class,
+// the empty storage class means S3_STORAGE_CLASS
+func (folder *Folder) PutObjectWithMetadata(name string, content io.Reader, storageClass string,
+	metadata map[string]string) error {
+	if storageClass == "" {
+		storageClass = folder.uploader.StorageClass
+	}
+	return folder.uploader.upload(*folder.Bucket, folder.Path+name, content, storageClass, metadata)
 }
 
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
@@ -169,7 +213,7 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 	}
 	source := path.Join(*folder.Bucket, folder.Path, srcPath)
 	dst := path.Join(folder.Path, dstPath)
-	input := &s3.CopyObjectInput{CopySource: &source, Bucket: folder.Bucket, Key: &dst}
+	input := folder.uploader.createCopyObjectInput(*folder.Bucket, source, dst)
 	_, err := folder.S3API.CopyObject(input)
 	if err != nil {
 		return err
@@ -178,6 +222,13 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	reader, _, err := folder.ReadObjectWithMetadata(objectRelativePath)
+	return reader, err
+}
+
+// ReadObjectWithMetadata reads the object and returns its user metadata, which S3 returns in the response
+// to the same request. The metadata keys are lower cased, as S3 changes their case.
+func (folder *Folder) ReadObjectWithMetadata(objectRelativePath string) (io.ReadCloser, map[string]string, error) {
 	objectPath := folder.Path + objectRelativePath
 	input := &s3.GetObjectInput{
 		Bucket: folder.Bucket,
@@ -187,10 +238,11 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 	object, err := folder.S3API.GetObject(input)
 	if err != nil {
 		if isAwsNotExist(err) {
-			return nil, storage.NewObjectNotFoundError(objectPath)
+			return nil, nil, storage.NewObjectNotFoundError(objectPath)
 		}
-		return nil, errors.Wrapf(err, "failed to read object: '%s' from S3", objectPath)
+		return nil, nil, errors.Wrapf(err, "failed to read object: '%s' from S3", objectPath)
 	}
+	metadata := lowerCaseMetadata(object.Metadata)
 
 	rangeEnabled, maxRetries, minRetryDelay, maxRetryDelay := folder.getReaderSettings()
 
@@ -198,7 +250,63 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 	if rangeEnabled {
 		reader = NewS3Reader(object.Body, objectPath, maxRetries, folder, minRetryDelay, maxRetryDelay)
 	}
-	return reader, nil
+	return reader, metadata, nil
+}
+
+// ReadObjectMetadata returns the user metadata of the object with the HEAD request
+func (folder *Folder) ReadObjectMetadata(objectRelativePath string) (map[string]string, error) {
+	objectPath := folder.Path + objectRelativePath
+	object, err := folder.S3API.HeadObject(&s3.HeadObjectInput{
+		Bucket: folder.Bucket,
+		Key:    aws.String(objectPath),
+	})
+	if err != nil {
+		if isAwsNotExist(err) {
+			return nil, storage.NewObjectNotFoundError(objectPath)
+		}
+		return nil, errors.Wrapf(err, "failed to read the metadata of object: '%s' from S3", objectPath)
+	}
+	return lowerCaseMetadata(object.Metadata), nil
+}
+
+// lowerCaseMetadata lower cases the metadata keys, as S3 changes their case
+func lowerCaseMetadata(metadata map[string]*string) map[string]string {
+	lowerCased := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		lowerCased[strings.ToLower(key)] = aws.StringValue(value)
+	}
+	return lowerCased
+}
+
+// ReadObjectFrom reads the object from the offset with the range request
+func (folder *Folder) ReadObjectFrom(objectRelativePath string, offset int64) (io.ReadCloser, int64, error) {
+	objectPath := folder.Path + objectRelativePath
+	input := &s3.GetObjectInput{
+		Bucket: folder.Bucket,
+		Key:    aws.String(objectPath),
+	}
+	if offset > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	object, err := folder.S3API.GetObject(input)
+	if err != nil {
+		if isAwsNotExist(err) {
+			return nil, 0, storage.NewObjectNotFoundError(objectPath)
+		}
+		return nil, 0, errors.Wrapf(err, "failed to read object: '%s' from S3 from %d", objectPath, offset)
+	}
+	size := aws.Int64Value(object.ContentLength)
+	if offset > 0 {
+		// Content-Range is "bytes <first>-<last>/<size>"
+		contentRange := aws.StringValue(object.ContentRange)
+		size, err = strconv.ParseInt(contentRange[strings.LastIndex(contentRange, "/")+1:], 10, 64)
+		if err != nil {
+			_ = object.Body.Close()
+			return nil, 0, errors.Wrapf(err, "failed to parse the content range '%s' of '%s'", contentRange, objectPath)
+		}
+	}
+	return object.Body, size, nil
 }
 
 func (folder *Folder) getReaderSettings() (rangeEnabled bool, retriesCount int, minRetryDelay, maxRetryDelay time.Duration) {
@@ -238,6 +346,15 @@ func (folder *Folder) GetPath() string {
 	return folder.Path
 }
 
+// GetURL returns the URL of the folder with the bucket and the endpoint, if it is set
+func (folder *Folder) GetURL() string {
+	bucketURL := "s3://" + *folder.Bucket
+	if endpoint, ok := folder.settings[EndpointSetting]; ok {
+		bucketURL = strings.TrimSuffix(endpoint, "/") + "/" + *folder.Bucket
+	}
+	return bucketURL + "/" + folder.Path
+}
+
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
 	listFunc := func(commonPrefixes []*s3.CommonPrefix, contents []*s3.Object) {
 		for _, prefix := range commonPrefixes {
@@ -303,14 +420,28 @@ func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
 		input := &s3.DeleteObjectsInput{Bucket: folder.Bucket, Delete: &s3.Delete{
 			Objects: folder.partitionToObjects(part),
 		}}
-		_, err := folder.S3API.DeleteObjects(input)
+		output, err := folder.S3API.DeleteObjects(input)
 		if err != nil {
 			return errors.Wrapf(err, "failed to delete s3 object: '%s'", part)
 		}
+		if len(output.Errors) > 0 {
+			return newDeleteObjectsError(output.Errors)
+		}
 	}
 	return nil
 }
 
+// newDeleteObjectsError reports the objects S3 refused to delete. The locked objects are not among them:
+// the bucket with the object lock is versioned, so the delete only adds the delete marker and keeps the locked version.
+func newDeleteObjectsError(deleteErrors []*s3.Error) error {
+	messages := make([]string, 0, len(deleteErrors))
+	for _, deleteError := range deleteErrors {
+		messages = append(messages, fmt.Sprintf("'%s': %s %s", aws.StringValue(deleteError.Key),
+			aws.StringValue(deleteError.Code), aws.StringValue(deleteError.Message)))
+	}
+	return errors.Errorf("failed to delete %d s3 objects:\n%s", len(deleteErrors), strings.Join(messages, "\n"))
+}
+
 func (folder *Folder) partitionToObjects(keys []string) []*s3.ObjectIdentifier {
 	objects := make([]*s3.ObjectIdentifier, len(keys))
 	for id, key := range keys {
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -14,10 +17,14 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/utility"
 )
 
 const (
 	DefaultMaxPartSize = 20 << 20
+	// the part size limits of S3 multipart upload
+	minUploadPartSize = s3manager.MinUploadPartSize
+	maxUploadPartSize = 5 << 30
 )
 
 type SseKmsIdNotSetError struct {
@@ -38,28 +45,36 @@ type Uploader struct {
 	SSECustomerKey       string
 	SSEKMSKeyId          string
 	StorageClass         string
+	partSize             int64
+	// objectLockMode and objectLockRetention set the S3 Object Lock on the uploaded objects if the retention is not zero
+	objectLockMode      string
+	objectLockRetention time.Duration
 }
 
 func NewUploader(uploaderAPI s3manageriface.UploaderAPI, serverSideEncryption, sseCustomerKey, sseKmsKeyId, storageClass string) *Uploader {
-	return &Uploader{uploaderAPI, serverSideEncryption, sseCustomerKey, sseKmsKeyId, storageClass}
+	return &Uploader{uploaderAPI: uploaderAPI, serverSideEncryption: serverSideEncryption, SSECustomerKey: sseCustomerKey,
+		SSEKMSKeyId: sseKmsKeyId, StorageClass: storageClass}
 }
 
 // TODO : unit tests
-func (uploader *Uploader) createUploadInput(bucket, path string, content io.Reader) *s3manager.UploadInput {
+func (uploader *Uploader) createUploadInput(bucket, path string, content io.Reader,
+	storageClass string) *s3manager.UploadInput {
 	uploadInput := &s3manager.UploadInput{
 		Bucket:       aws.String(bucket),
 		Key:          aws.String(path),
 		Body:         content,
-		StorageClass: aws.String(uploader.StorageClass),
+		StorageClass: aws.String(storageClass),
+	}
+	if uploader.objectLockRetention > 0 {
+		uploadInput.ObjectLockMode = aws.String(uploader.objectLockMode)
+		uploadInput.ObjectLockRetainUntilDate = aws.Time(uploader.objectLockRetainUntil())
 	}
 
 	if uploader.serverSideEncryption != "" {
 		if uploader.SSECustomerKey != "" {
 			uploadInput.SSECustomerAlgorithm = aws.String(uploader.serverSideEncryption)
 			uploadInput.SSECustomerKey = aws.String(uploader.SSECustomerKey)
-			hash := md5.Sum([]byte(uploader.SSECustomerKey))
-			customerKeyMD5 := base64.StdEncoding.EncodeToString(hash[:])
-			uploadInput.SSECustomerKeyMD5 = aws.String(customerKeyMD5)
+			uploadInput.SSECustomerKeyMD5 = aws.String(uploader.sseCustomerKeyMD5())
 		} else {
 			uploadInput.ServerSideEncryption = aws.String(uploader.serverSideEncryption)
 		}
@@ -73,10 +88,152 @@ func (uploader *Uploader) createUploadInput(bucket, path string, content io.Read
 	return uploadInput
 }
 
-func (uploader *Uploader) upload(bucket, path string, content io.Reader) error {
-	input := uploader.createUploadInput(bucket, path, content)
+// createCopyObjectInput carries the server side encryption settings to the copy,
+// otherwise S3 stores the copy unencrypted and the buckets that deny unencrypted writes reject it
+func (uploader *Uploader) createCopyObjectInput(bucket, source, path string) *s3.CopyObjectInput {
+	copyInput := &s3.CopyObjectInput{
+		CopySource: aws.String(source),
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(path),
+	}
+	// the copy is the new object which doesn't inherit the retention of the source one
+	if uploader.objectLockRetention > 0 {
+		copyInput.ObjectLockMode = aws.String(uploader.objectLockMode)
+		copyInput.ObjectLockRetainUntilDate = aws.Time(uploader.objectLockRetainUntil())
+	}
+
+	if uploader.serverSideEncryption != "" {
+		if uploader.SSECustomerKey != "" {
+			// The source object is encrypted with the same customer key, S3 needs it to read the source
+			copyInput.CopySourceSSECustomerAlgorithm = aws.String(uploader.serverSideEncryption)
+			copyInput.CopySourceSSECustomerKey = aws.String(uploader.SSECustomerKey)
+			copyInput.CopySourceSSECustomerKeyMD5 = aws.String(uploader.sseCustomerKeyMD5())
+			copyInput.SSECustomerAlgorithm = aws.String(uploader.serverSideEncryption)
+			copyInput.SSECustomerKey = aws.String(uploader.SSECustomerKey)
+			copyInput.SSECustomerKeyMD5 = aws.String(uploader.sseCustomerKeyMD5())
+		} else {
+			copyInput.ServerSideEncryption = aws.String(uploader.serverSideEncryption)
+		}
+
+		if uploader.SSEKMSKeyId != "" {
+			copyInput.SSEKMSKeyId = aws.String(uploader.SSEKMSKeyId)
+		}
+	}
+
+	return copyInput
+}
+
+func (uploader *Uploader) objectLockRetainUntil() time.Time {
+	return time.Now().Add(uploader.objectLockRetention)
+}
+
+func (uploader *Uploader) sseCustomerKeyMD5() string {
+	hash := md5.Sum([]byte(uploader.SSECustomerKey))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func (uploader *Uploader) upload(bucket, path string, content io.Reader, storageClass string,
+	metadata map[string]string) error {
+	countingContent, bytesRead := newCountingReader(content)
+	input := uploader.createUploadInput(bucket, path, countingContent, storageClass)
+	if len(metadata) > 0 {
+		input.Metadata = aws.StringMap(metadata)
+	}
 	_, err := uploader.uploaderAPI.Upload(input)
-	return errors.Wrapf(err, "failed to upload '%s' to bucket '%s'", path, bucket)
+	if err != nil {
+		return errors.Wrapf(err, "failed to upload '%s' to bucket '%s'", path, bucket)
+	}
+	if uploader.partSize > 0 {
+		tracelog.InfoLogger.Printf("Uploaded '%s' of %d bytes in %d parts of %d bytes\n", path,
+			*bytesRead, getPartsCount(*bytesRead, uploader.partSize), uploader.partSize)
+	}
+	return nil
+}
+
+// getPartsCount returns the number of parts the object is uploaded in, the small objects are uploaded in one part
+func getPartsCount(size, partSize int64) int64 {
+	if size <= partSize {
+		return 1
+	}
+	return (size + partSize - 1) / partSize
+}
+
+// newCountingReader returns the reader of the content which counts the bytes read. The seekable content
+// is kept seekable, so the S3 manager still learns its size. The content which is also an io.ReaderAt
+// keeps it too, so the S3 manager reads the parts from it directly instead of buffering them.
+func newCountingReader(content io.Reader) (io.Reader, *int64) {
+	reader := &countingReader{Reader: content}
+	seeker, ok := content.(io.Seeker)
+	if !ok {
+		return reader, &reader.bytesRead
+	}
+	readSeeker := &countingReadSeeker{countingReader: reader, seeker: seeker}
+	if readerAt, ok := content.(io.ReaderAt); ok {
+		return &countingReadAtSeeker{countingReadSeeker: readSeeker, readerAt: readerAt}, &reader.bytesRead
+	}
+	return readSeeker, &reader.bytesRead
+}
+
+type countingReader struct {
+	io.Reader
+	bytesRead int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	reader.bytesRead += int64(n)
+	return n, err
+}
+
+// countReadUpTo counts the bytes up to the furthest position read, so the content read again is not counted twice
+func (reader *countingReader) countReadUpTo(position int64) {
+	if position > reader.bytesRead {
+		reader.bytesRead = position
+	}
+}
+
+// countingReadSeeker counts the bytes up to the furthest position read, so the content read again
+// after the seek back is not counted twice
+type countingReadSeeker struct {
+	*countingReader
+	seeker   io.Seeker
+	position int64
+}
+
+func (reader *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := reader.countingReader.Reader.Read(p)
+	reader.position += int64(n)
+	reader.countReadUpTo(reader.position)
+	return n, err
+}
+
+func (reader *countingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	position, err := reader.seeker.Seek(offset, whence)
+	if err == nil {
+		reader.position = position
+	}
+	return position, err
+}
+
+// countingReadAtSeeker also counts the bytes read by ReadAt, which the S3 manager calls concurrently for the parts
+type countingReadAtSeeker struct {
+	*countingReadSeeker
+	readerAt io.ReaderAt
+	mutex    sync.Mutex
+}
+
+func (reader *countingReadAtSeeker) Read(p []byte) (int, error) {
+	reader.mutex.Lock()
+	defer reader.mutex.Unlock()
+	return reader.countingReadSeeker.Read(p)
+}
+
+func (reader *countingReadAtSeeker) ReadAt(p []byte, offset int64) (int, error) {
+	n, err := reader.readerAt.ReadAt(p, offset)
+	reader.mutex.Lock()
+	reader.countReadUpTo(offset + int64(n))
+	reader.mutex.Unlock()
+	return n, err
 }
 
 // CreateUploaderAPI returns an uploader with customizable concurrency
@@ -129,14 +286,9 @@ func configureUploader(s3Client *s3.S3, settings map[string]string) (*Uploader,
 		return nil, NewConfiguringError(UploadConcurrencySetting)
 	}
 
-	var maxPartSize int
-	if strMaxPartSize, ok := settings[MaxPartSize]; ok {
-		maxPartSize, err = strconv.Atoi(strMaxPartSize)
-		if err != nil {
-			return nil, NewFolderError(err, "Invalid s3 max part size setting")
-		}
-	} else {
-		maxPartSize = DefaultMaxPartSize
+	maxPartSize, err := configurePartSize(settings)
+	if err != nil {
+		return nil, err
 	}
 
 	uploaderApi := CreateUploaderAPI(s3Client, maxPartSize, concurrency)
@@ -151,5 +303,66 @@ func configureUploader(s3Client *s3.S3, settings map[string]string) (*Uploader,
 	if storageClass, ok = settings[StorageClassSetting]; !ok {
 		storageClass = "STANDARD"
 	}
-	return NewUploader(uploaderApi, serverSideEncryption, sseCustomerKey, sseKmsKeyId, storageClass), nil
+	uploader := NewUploader(uploaderApi, serverSideEncryption, sseCustomerKey, sseKmsKeyId, storageClass)
+	uploader.partSize = int64(maxPartSize)
+	uploader.objectLockMode, uploader.objectLockRetention, err = configureObjectLock(settings)
+	if err != nil {
+		return nil, err
+	}
+	return uploader, nil
+}
+
+// configureObjectLock returns the S3 Object Lock mode and the retention period of the uploaded objects,
+// the objects are not locked unless WALG_OBJECT_LOCK_DAYS is set. The bucket must have the Object Lock enabled.
+func configureObjectLock(settings map[string]string) (mode string, retention time.Duration, err error) {
+	strDays, ok := settings[ObjectLockDaysSetting]
+	if !ok {
+		return "", 0, nil
+	}
+	days, err := strconv.Atoi(strDays)
+	if err != nil || days < 0 {
+		return "", 0, NewFolderError(errors.Errorf("%q is not a non-negative number of days", strDays),
+			"Invalid %s setting", ObjectLockDaysSetting)
+	}
+	if days == 0 {
+		return "", 0, nil
+	}
+
+	mode = s3.ObjectLockModeGovernance
+	if strMode, ok := settings[ObjectLockModeSetting]; ok {
+		mode = strings.ToUpper(strMode)
+	}
+	if mode != s3.ObjectLockModeGovernance && mode != s3.ObjectLockModeCompliance {
+		return "", 0, NewFolderError(errors.Errorf("%q is not one of %v", mode, s3.ObjectLockMode_Values()),
+			"Invalid %s setting", ObjectLockModeSetting)
+	}
+	retention = time.Duration(days) * 24 * time.Hour
+	tracelog.InfoLogger.Printf("Uploaded objects are locked in %s mode for %d days\n", mode, days)
+	return mode, retention, nil
+}
+
+// configurePartSize returns the multipart upload part size set by UPLOAD_PART_SIZE,
+// which accepts the size suffixes and takes precedence over S3_MAX_PART_SIZE
+func configurePartSize(settings map[string]string) (int, error) {
+	if strPartSize, ok := settings[UploadPartSizeSetting]; ok {
+		partSize, err := utility.ParseSizeInBytes(strPartSize)
+		if err != nil {
+			return 0, NewFolderError(err, "Invalid upload part size setting")
+		}
+		if partSize < minUploadPartSize || partSize > maxUploadPartSize {
+			return 0, NewFolderError(errors.Errorf("%d is out of range [%d, %d]",
+				partSize, minUploadPartSize, maxUploadPartSize), "Invalid upload part size setting")
+		}
+		tracelog.InfoLogger.Printf("S3 multipart upload part size is %d bytes\n", partSize)
+		return int(partSize), nil
+	}
+
+	if strMaxPartSize, ok := settings[MaxPartSize]; ok {
+		maxPartSize, err := strconv.Atoi(strMaxPartSize)
+		if err != nil {
+			return 0, NewFolderError(err, "Invalid s3 max part size setting")
+		}
+		return maxPartSize, nil
+	}
+	return DefaultMaxPartSize, nil
 }
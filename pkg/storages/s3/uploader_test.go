@@ -0,0 +1,213 @@
+package s3
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKmsKeyID = "arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+func TestConfigureServerSideEncryption_KmsKeyIdRequired(t *testing.T) {
+	_, _, _, err := configureServerSideEncryption(map[string]string{SseSetting: "aws:kms"})
+	assert.IsType(t, SseKmsIdNotSetError{}, err)
+
+	_, _, _, err = configureServerSideEncryption(map[string]string{SseSetting: "AES256", SseKmsIdSetting: testKmsKeyID})
+	assert.IsType(t, SseKmsIdNotSetError{}, err)
+
+	sse, _, kmsKeyID, err := configureServerSideEncryption(map[string]string{
+		SseSetting:      "aws:kms",
+		SseKmsIdSetting: testKmsKeyID,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "aws:kms", sse)
+	assert.Equal(t, testKmsKeyID, kmsKeyID)
+}
+
+func TestUploader_KmsEncryptionHeaders(t *testing.T) {
+	uploader := NewUploader(nil, "aws:kms", "", testKmsKeyID, "STANDARD")
+
+	uploadInput := uploader.createUploadInput("bucket", "path", strings.NewReader("content"), uploader.StorageClass)
+	assert.Equal(t, "aws:kms", aws.StringValue(uploadInput.ServerSideEncryption))
+	assert.Equal(t, testKmsKeyID, aws.StringValue(uploadInput.SSEKMSKeyId))
+	assert.Nil(t, uploadInput.SSECustomerKey)
+
+	copyInput := uploader.createCopyObjectInput("bucket", "bucket/src", "dst")
+	assert.Equal(t, "aws:kms", aws.StringValue(copyInput.ServerSideEncryption))
+	assert.Equal(t, testKmsKeyID, aws.StringValue(copyInput.SSEKMSKeyId))
+	assert.Equal(t, "bucket/src", aws.StringValue(copyInput.CopySource))
+	assert.Equal(t, "dst", aws.StringValue(copyInput.Key))
+}
+
+func TestUploader_CustomerKeyEncryptionHeaders(t *testing.T) {
+	uploader := NewUploader(nil, "AES256", "01234567890123456789012345678901", "", "STANDARD")
+
+	uploadInput := uploader.createUploadInput("bucket", "path", strings.NewReader("content"), uploader.StorageClass)
+	assert.Nil(t, uploadInput.ServerSideEncryption)
+	assert.Equal(t, "AES256", aws.StringValue(uploadInput.SSECustomerAlgorithm))
+	assert.NotEmpty(t, aws.StringValue(uploadInput.SSECustomerKeyMD5))
+
+	copyInput := uploader.createCopyObjectInput("bucket", "bucket/src", "dst")
+	assert.Equal(t, uploadInput.SSECustomerKeyMD5, copyInput.SSECustomerKeyMD5)
+	assert.Equal(t, uploadInput.SSECustomerKeyMD5, copyInput.CopySourceSSECustomerKeyMD5)
+}
+
+func TestUploader_NoEncryptionHeaders(t *testing.T) {
+	uploader := NewUploader(nil, "", "", "", "STANDARD")
+
+	copyInput := uploader.createCopyObjectInput("bucket", "bucket/src", "dst")
+	assert.Nil(t, copyInput.ServerSideEncryption)
+	assert.Nil(t, copyInput.SSECustomerKey)
+}
+
+func TestUploader_StorageClass(t *testing.T) {
+	uploader := NewUploader(nil, "", "", "", "STANDARD")
+
+	uploadInput := uploader.createUploadInput("bucket", "path", strings.NewReader("content"), uploader.StorageClass)
+	assert.Equal(t, "STANDARD", aws.StringValue(uploadInput.StorageClass))
+	uploadInput = uploader.createUploadInput("bucket", "path", strings.NewReader("content"), "STANDARD_IA")
+	assert.Equal(t, "STANDARD_IA", aws.StringValue(uploadInput.StorageClass))
+}
+
+// recordingUploaderAPI records the input of the last upload
+type recordingUploaderAPI struct {
+	s3manageriface.UploaderAPI
+	input *s3manager.UploadInput
+}
+
+func (uploaderAPI *recordingUploaderAPI) Upload(input *s3manager.UploadInput,
+	options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	uploaderAPI.input = input
+	return &s3manager.UploadOutput{}, nil
+}
+
+func TestUploader_Metadata(t *testing.T) {
+	uploaderAPI := &recordingUploaderAPI{}
+	uploader := NewUploader(uploaderAPI, "", "", "", "STANDARD")
+
+	assert.NoError(t, uploader.upload("bucket", "path", strings.NewReader("content"), "STANDARD", nil))
+	assert.Nil(t, uploaderAPI.input.Metadata)
+
+	assert.NoError(t, uploader.upload("bucket", "path", strings.NewReader("content"), "STANDARD",
+		map[string]string{"walg-zstd-dictionary": "0000abcd"}))
+	assert.Equal(t, map[string]*string{"walg-zstd-dictionary": aws.String("0000abcd")}, uploaderAPI.input.Metadata)
+}
+
+func TestConfigurePartSize(t *testing.T) {
+	partSize, err := configurePartSize(map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultMaxPartSize, partSize)
+
+	partSize, err = configurePartSize(map[string]string{MaxPartSize: "10485760"})
+	assert.NoError(t, err)
+	assert.Equal(t, 10<<20, partSize)
+
+	partSize, err = configurePartSize(map[string]string{UploadPartSizeSetting: "64MB", MaxPartSize: "10485760"})
+	assert.NoError(t, err)
+	assert.Equal(t, 64<<20, partSize)
+
+	for _, invalidPartSize := range []string{"1MB", "6GB", "large"} {
+		_, err = configurePartSize(map[string]string{UploadPartSizeSetting: invalidPartSize})
+		assert.Error(t, err, invalidPartSize)
+	}
+}
+
+func TestGetPartsCount(t *testing.T) {
+	assert.Equal(t, int64(1), getPartsCount(0, 64<<20))
+	assert.Equal(t, int64(1), getPartsCount(64<<20, 64<<20))
+	assert.Equal(t, int64(2), getPartsCount(64<<20+1, 64<<20))
+	assert.Equal(t, int64(16), getPartsCount(1<<30, 64<<20))
+}
+
+func TestCountingReader(t *testing.T) {
+	reader, bytesRead := newCountingReader(io.MultiReader(strings.NewReader("content")))
+	_, ok := reader.(io.Seeker)
+	assert.False(t, ok)
+	_, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), *bytesRead)
+
+	reader, bytesRead = newCountingReader(strings.NewReader("content"))
+	seeker, ok := reader.(io.ReadSeeker)
+	require.True(t, ok)
+	size, err := seeker.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), size)
+	_, err = seeker.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	_, err = io.ReadAll(seeker)
+	assert.NoError(t, err)
+	// the content read again is not counted twice
+	_, err = seeker.Seek(3, io.SeekStart)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(seeker)
+	assert.NoError(t, err)
+	assert.Equal(t, "tent", string(data))
+	assert.Equal(t, int64(7), *bytesRead)
+
+	// the parts are read by ReadAt
+	reader, bytesRead = newCountingReader(strings.NewReader("content"))
+	readerAt, ok := reader.(io.ReaderAt)
+	require.True(t, ok)
+	part := make([]byte, 3)
+	_, err = readerAt.ReadAt(part, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "ten", string(part))
+	_, err = readerAt.ReadAt(part, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), *bytesRead)
+
+	// the seekable content without ReadAt stays seekable
+	reader, _ = newCountingReader(struct{ io.ReadSeeker }{strings.NewReader("content")})
+	_, ok = reader.(io.ReaderAt)
+	assert.False(t, ok)
+	_, ok = reader.(io.Seeker)
+	assert.True(t, ok)
+}
+
+func TestConfigureObjectLock(t *testing.T) {
+	mode, retention, err := configureObjectLock(map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", mode)
+	assert.Zero(t, retention)
+
+	mode, retention, err = configureObjectLock(map[string]string{ObjectLockDaysSetting: "30"})
+	assert.NoError(t, err)
+	assert.Equal(t, s3.ObjectLockModeGovernance, mode)
+	assert.Equal(t, 30*24*time.Hour, retention)
+
+	mode, _, err = configureObjectLock(map[string]string{ObjectLockDaysSetting: "30", ObjectLockModeSetting: "compliance"})
+	assert.NoError(t, err)
+	assert.Equal(t, s3.ObjectLockModeCompliance, mode)
+
+	_, _, err = configureObjectLock(map[string]string{ObjectLockDaysSetting: "30", ObjectLockModeSetting: "LEGAL_HOLD"})
+	assert.Error(t, err)
+	_, _, err = configureObjectLock(map[string]string{ObjectLockDaysSetting: "-1"})
+	assert.Error(t, err)
+}
+
+func TestUploader_ObjectLockHeaders(t *testing.T) {
+	uploader := NewUploader(nil, "", "", "", "STANDARD")
+	uploader.objectLockMode, uploader.objectLockRetention = s3.ObjectLockModeCompliance, 24*time.Hour
+
+	uploadInput := uploader.createUploadInput("bucket", "path", strings.NewReader("content"), uploader.StorageClass)
+	assert.Equal(t, s3.ObjectLockModeCompliance, aws.StringValue(uploadInput.ObjectLockMode))
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), aws.TimeValue(uploadInput.ObjectLockRetainUntilDate), time.Minute)
+
+	copyInput := uploader.createCopyObjectInput("bucket", "bucket/src", "dst")
+	assert.Equal(t, s3.ObjectLockModeCompliance, aws.StringValue(copyInput.ObjectLockMode))
+	assert.NotNil(t, copyInput.ObjectLockRetainUntilDate)
+
+	uploadInput = NewUploader(nil, "", "", "", "STANDARD").createUploadInput("bucket", "path",
+		strings.NewReader("content"), "STANDARD")
+	assert.Nil(t, uploadInput.ObjectLockMode)
+	assert.Nil(t, uploadInput.ObjectLockRetainUntilDate)
+}
@@ -30,3 +30,8 @@ func NewError(err error, storageName, format string, args ...interface{}) Error
 func (err Error) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
+
+// Unwrap exposes the error of the storage SDK, e.g. to check its status code
+func (err Error) Unwrap() error {
+	return err.error
+}
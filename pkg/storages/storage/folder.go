@@ -4,6 +4,7 @@ import (
 	"io"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/wal-g/tracelog"
 )
@@ -31,6 +32,122 @@ type Folder interface {
 	CopyObject(srcPath string, dstPath string) error
 }
 
+// StorageClassFolder is the Folder of the storage which can put the object
+// into the specified storage class instead of the default one
+type StorageClassFolder interface {
+	Folder
+
+	PutObjectWithStorageClass(name string, content io.Reader, storageClass string) error
+}
+
+// RangeFolder is the Folder of the storage which can read the object from the offset,
+// so the interrupted download is resumed instead of restarted and the tar entry is read without the preceding ones
+type RangeFolder interface {
+	Folder
+
+	// ReadObjectFrom reads the object from the offset, which must be less than the object size,
+	// and returns the full size of the object. Should return ObjectNotFoundError in case there is no such object
+	ReadObjectFrom(objectRelativePath string, offset int64) (io.ReadCloser, int64, error)
+}
+
+// ObjectLockFolder is the Folder of the storage which locks the uploaded objects,
+// so they can't be deleted until the retention period since their upload expires
+type ObjectLockFolder interface {
+	Folder
+
+	// GetObjectLockRetention returns the retention period of the uploaded objects, zero if they are not locked
+	GetObjectLockRetention() time.Duration
+	// GetObjectRetainUntil returns the time the lock of the stored object expires, zero if it is not locked.
+	// The object may be locked longer than GetObjectLockRetention, e.g. by the default retention of the bucket.
+	GetObjectRetainUntil(objectRelativePath string) (time.Time, error)
+}
+
+// URLFolder is the Folder whose path doesn't tell it from the folders of the other storages,
+// e.g. the path of the S3 folder is relative to the bucket
+type URLFolder interface {
+	Folder
+
+	// GetURL returns the URL of the folder including the storage it is in, e.g. s3://bucket/path/
+	GetURL() string
+}
+
+// MetadataFolder is the Folder of the storage which keeps the user metadata along with the object,
+// so the object is described without changing its content
+type MetadataFolder interface {
+	Folder
+
+	// PutObjectWithMetadata puts the object with the metadata into the storage class,
+	// the empty storage class means the default one
+	PutObjectWithMetadata(name string, content io.Reader, storageClass string, metadata map[string]string) error
+
+	// ReadObjectWithMetadata reads the object and returns the metadata it is stored with, the metadata keys are lower case.
+	// Should return ObjectNotFoundError in case there is no such object
+	ReadObjectWithMetadata(objectRelativePath string) (io.ReadCloser, map[string]string, error)
+
+	// ReadObjectMetadata returns the metadata the object is stored with without reading the object.
+	// Should return ObjectNotFoundError in case there is no such object
+	ReadObjectMetadata(objectRelativePath string) (map[string]string, error)
+}
+
+// RehydrateFolder is the Folder of the storage which archives the objects, so they can't be read
+// until they are rehydrated, which takes hours
+type RehydrateFolder interface {
+	Folder
+
+	// RehydrateSubFolders starts the rehydration of all the archived objects under the subfolders,
+	// then waits for all of them together unless the storage is configured to fail instead of waiting
+	RehydrateSubFolders(subFolderRelativePaths []string) error
+}
+
+// RehydrateSubFolders rehydrates the archived objects under the subfolders if the storage archives the objects
+func RehydrateSubFolders(folder Folder, subFolderRelativePaths []string) error {
+	if rehydrateFolder, ok := folder.(RehydrateFolder); ok {
+		return rehydrateFolder.RehydrateSubFolders(subFolderRelativePaths)
+	}
+	return nil
+}
+
+// ReadObjectWithMetadata reads the object with its metadata if the storage keeps the metadata, the metadata is nil otherwise
+func ReadObjectWithMetadata(folder Folder, objectRelativePath string) (io.ReadCloser, map[string]string, error) {
+	if metadataFolder, ok := folder.(MetadataFolder); ok {
+		return metadataFolder.ReadObjectWithMetadata(objectRelativePath)
+	}
+	reader, err := folder.ReadObject(objectRelativePath)
+	return reader, nil, err
+}
+
+// ReadObjectMetadata returns the metadata of the object if the storage keeps the metadata, nil otherwise
+func ReadObjectMetadata(folder Folder, objectRelativePath string) (map[string]string, error) {
+	if metadataFolder, ok := folder.(MetadataFolder); ok {
+		return metadataFolder.ReadObjectMetadata(objectRelativePath)
+	}
+	return nil, nil
+}
+
+// GetFolderURL returns the URL of the folder, it is the path of the folder which doesn't implement URLFolder
+func GetFolderURL(folder Folder) string {
+	if urlFolder, ok := folder.(URLFolder); ok {
+		return urlFolder.GetURL()
+	}
+	return folder.GetPath()
+}
+
+// GetObjectLockRetention returns the retention period of the objects locked by the folder, zero if they are not locked
+func GetObjectLockRetention(folder Folder) time.Duration {
+	if lockFolder, ok := folder.(ObjectLockFolder); ok {
+		return lockFolder.GetObjectLockRetention()
+	}
+	return 0
+}
+
+// GetObjectRetainUntil returns the time the lock of the object expires, zero if the folder doesn't lock the objects
+func GetObjectRetainUntil(folder Folder, objectRelativePath string) (time.Time, error) {
+	if lockFolder, ok := folder.(ObjectLockFolder); ok {
+		return lockFolder.GetObjectRetainUntil(objectRelativePath)
+	}
+	return time.Time{}, nil
+}
+
 func DeleteObjectsWhere(folder Folder, confirm bool, objFilter func(object1 Object) bool, folderFilter func(name string) bool) error {
 	relativePathObjects, err := ListFolderRecursivelyWithFilter(folder, folderFilter)
 	if err != nil {
@@ -71,6 +71,11 @@ func (folder *Folder) GetPath() string {
 	return folder.path
 }
 
+// GetURL returns the URL of the folder in the container
+func (folder *Folder) GetURL() string {
+	return "swift://" + folder.container.Name + "/" + folder.path
+}
+
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
 	path := storage.JoinPath(folder.path, objectRelativePath)
 	_, _, err := folder.connection.Object(folder.container.Name, path)
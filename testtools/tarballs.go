@@ -76,7 +76,7 @@ func (tarBall *FileTarBall) CloseTar() error {
 func (tarBall *FileTarBall) Size() int64            { return atomic.LoadInt64(tarBall.partSize) }
 func (tarBall *FileTarBall) AddSize(i int64)        { atomic.AddInt64(tarBall.partSize, i) }
 func (tarBall *FileTarBall) TarWriter() *tar.Writer { return tarBall.tarWriter }
-func (tarBall *FileTarBall) AwaitUploads()          {}
+func (tarBall *FileTarBall) AwaitUploads() error    { return nil }
 
 // BufferTarBall represents a tarball that is
 // written to buffer.
@@ -111,4 +111,4 @@ func (tarBall *BufferTarBall) TarWriter() *tar.Writer {
 	return tarBall.tarWriter
 }
 
-func (tarBall *BufferTarBall) AwaitUploads() {}
+func (tarBall *BufferTarBall) AwaitUploads() error { return nil }
@@ -0,0 +1,48 @@
+package utility
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// FilesystemSpace describes the free space of the filesystem
+type FilesystemSpace struct {
+	// DeviceID identifies the filesystem, the paths with the same DeviceID share the available space
+	DeviceID       uint64
+	AvailableBytes uint64
+}
+
+// GetFilesystemSpace returns the space available to the unprivileged users on the filesystem
+// the path will be created at, the path itself may not exist yet
+func GetFilesystemSpace(path string) (FilesystemSpace, error) {
+	existingPath, err := findExistingPath(path)
+	if err != nil {
+		return FilesystemSpace{}, err
+	}
+	space, err := statFilesystem(existingPath)
+	if err != nil {
+		return FilesystemSpace{}, errors.Wrapf(err, "failed to get the filesystem space of %s", existingPath)
+	}
+	return space, nil
+}
+
+// findExistingPath returns the path or its nearest existing parent directory
+func findExistingPath(path string) (string, error) {
+	path = filepath.Clean(path)
+	for {
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,20 @@
+package utility_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func TestGetFilesystemSpace_MissingPath(t *testing.T) {
+	dir := t.TempDir()
+	dirSpace, err := utility.GetFilesystemSpace(dir)
+	assert.NoError(t, err)
+
+	// the missing path is checked at its nearest existing parent directory
+	space, err := utility.GetFilesystemSpace(filepath.Join(dir, "missing", "pgdata"))
+	assert.NoError(t, err)
+	assert.Equal(t, dirSpace.DeviceID, space.DeviceID)
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package utility
+
+import (
+	"syscall"
+)
+
+func statFilesystem(path string) (FilesystemSpace, error) {
+	var fsStat syscall.Statfs_t
+	err := syscall.Statfs(path, &fsStat)
+	if err != nil {
+		return FilesystemSpace{}, err
+	}
+	var fileStat syscall.Stat_t
+	err = syscall.Stat(path, &fileStat)
+	if err != nil {
+		return FilesystemSpace{}, err
+	}
+	//nolint:unconvert // the types of the fields differ between the platforms
+	return FilesystemSpace{
+		DeviceID:       uint64(fileStat.Dev),
+		AvailableBytes: uint64(fsStat.Bavail) * uint64(fsStat.Bsize),
+	}, nil
+}
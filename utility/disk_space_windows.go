@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package utility
+
+import (
+	"github.com/pkg/errors"
+)
+
+func statFilesystem(path string) (FilesystemSpace, error) {
+	return FilesystemSpace{}, errors.New("the filesystem space check is not supported on Windows")
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package utility
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// TryLockFile takes the exclusive advisory lock of the open file without waiting, the lock is released
+// when the file is closed or the process exits. Returns false if another process holds the lock.
+func TryLockFile(fd uintptr) (bool, error) {
+	err := syscall.Flock(int(fd), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to lock file")
+	}
+	return true, nil
+}
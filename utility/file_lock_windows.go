@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package utility
+
+import (
+	"github.com/pkg/errors"
+)
+
+func TryLockFile(fd uintptr) (bool, error) {
+	return false, errors.New("the file locks are not supported on Windows")
+}
@@ -13,6 +13,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -377,6 +378,66 @@ func ParseUntilTS(untilTS string) (time.Time, error) {
 	return MaxTime, nil
 }
 
+// ParseSizeInBytes parses the size with an optional binary suffix
+// (K, M, G or T, optionally followed by B), e.g. "512", "50M" or "1GB".
+func ParseSizeInBytes(sizeStr string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(sizeStr))
+	value = strings.TrimSuffix(value, "B")
+	multiplier := int64(1)
+	if len(value) > 0 {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = strings.TrimSpace(value[:len(value)-1])
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, errors.Errorf("invalid size '%s', expected a non-negative number with an optional K, M, G or T suffix",
+			sizeStr)
+	}
+	return size * multiplier, nil
+}
+
+// FormatSize formats the size in bytes with a binary suffix, e.g. "512 B" or "1.5 KiB"
+func FormatSize(bytes float64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%.0f B", bytes)
+	}
+	exp := 0
+	for value := bytes / unit; value >= unit && exp < 4; value /= unit {
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", bytes/float64(int64(1)<<(10*(exp+1))), "KMGTP"[exp])
+}
+
+// ParseDuration parses the duration in the time.ParseDuration format,
+// additionally accepting the number of days with the d suffix, e.g. "7d".
+func ParseDuration(durationStr string) (time.Duration, error) {
+	value := strings.TrimSpace(durationStr)
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseUint(strings.TrimSuffix(value, "d"), 10, 32)
+		if err != nil {
+			return 0, errors.Errorf("invalid duration '%s', expected a number of days like 7d", durationStr)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, errors.Errorf("invalid duration '%s', expected a non-negative duration like 7d or 12h", durationStr)
+	}
+	return duration, nil
+}
+
 // MarshalEnumToString is used to write the string enum representation
 // instead of int enum value to JSON
 func MarshalEnumToString(enum fmt.Stringer) ([]byte, error) {
@@ -492,3 +492,52 @@ func TestLoggedCloseWithErrorAndCustomMessage(t *testing.T) {
 
 	assert.Equal(t, "custom error message: mock close: close error\n", string(loggedData))
 }
+
+func TestParseSizeInBytes(t *testing.T) {
+	testCases := map[string]int64{
+		"0":     0,
+		"512":   512,
+		"10K":   10 << 10,
+		"50M":   50 << 20,
+		"50mb":  50 << 20,
+		"1G":    1 << 30,
+		"2TB":   2 << 40,
+		" 3 M ": 3 << 20,
+	}
+	for input, expected := range testCases {
+		actual, err := utility.ParseSizeInBytes(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	for _, input := range []string{"", "M", "-1", "10X", "1.5G"} {
+		_, err := utility.ParseSizeInBytes(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", utility.FormatSize(512))
+	assert.Equal(t, "1.5 KiB", utility.FormatSize(1536))
+	assert.Equal(t, "2.0 GiB", utility.FormatSize(2*1024*1024*1024))
+}
+
+func TestParseDuration(t *testing.T) {
+	testCases := map[string]time.Duration{
+		"0s":  0,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+		"1d":  24 * time.Hour,
+		" 7d": 7 * 24 * time.Hour,
+	}
+	for input, expected := range testCases {
+		actual, err := utility.ParseDuration(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	for _, input := range []string{"", "d", "-1h", "-1d", "1.5d", "7days"} {
+		_, err := utility.ParseDuration(input)
+		assert.Error(t, err, input)
+	}
+}